@@ -0,0 +1,77 @@
+// Package artifact 登记本库各组件创建的临时文件和目录的命名规则。
+//
+// 组件在写入过程中创建的临时文件（例如原子写入使用的 .part 文件、
+// 原子导入的暂存目录）在进程崩溃后会残留在磁盘上。仓库的清理扫描
+// 只删除与这里登记的规则匹配的条目，因此新功能创建临时文件时
+// 必须使用这里的常量并在 Patterns 中登记其规则，否则残留永远不会被清理。
+package artifact
+
+import "strings"
+
+const (
+	// PartFileSuffix 是原子写入时临时文件的后缀，写入完成后重命名为目标文件。
+	// 由导出器的文件写入和状态文件使用。
+	PartFileSuffix = ".part"
+
+	// StagingDirPrefix 是原子导入在磁盘上的暂存目录的前缀，
+	// 目录名的其余部分由 os.MkdirTemp 生成。
+	StagingDirPrefix = "import-staging-"
+
+	// SpecTempSuffix 是存储配置文件原子重写时临时文件的后缀。
+	SpecTempSuffix = ".tmp"
+
+	// specFileName 是存储配置文件名，与 storage.DatastoreSpecPath 一致。
+	specFileName = "datastore_spec"
+)
+
+// Pattern 描述一类临时条目的命名规则。
+type Pattern struct {
+	Kind   string // 条目类别，用于报告
+	Prefix string // 名称必须以此开头，为空表示不限制
+	Suffix string // 名称必须以此结尾，为空表示不限制
+	Dir    bool   // 为 true 时只匹配目录，否则只匹配普通文件
+}
+
+// patterns 是所有已登记的规则。
+var patterns = []Pattern{
+	{Kind: "part", Suffix: PartFileSuffix},
+	{Kind: "staging", Prefix: StagingDirPrefix, Dir: true},
+	{Kind: "spec", Prefix: specFileName, Suffix: SpecTempSuffix},
+}
+
+// Patterns 返回所有已登记规则的副本。
+//
+// 返回：
+//
+//	[]Pattern - 已登记的规则
+func Patterns() []Pattern {
+	return append([]Pattern(nil), patterns...)
+}
+
+// Match 返回与条目名称匹配的规则。
+//
+// 参数：
+//
+//	name - 条目的名称（不含目录）
+//	isDir - 条目是否为目录
+//
+// 返回：
+//
+//	Pattern - 匹配的规则
+//	bool - 是否有规则匹配
+func Match(name string, isDir bool) (Pattern, bool) {
+	for _, p := range patterns {
+		if p.matches(name, isDir) {
+			return p, true
+		}
+	}
+	return Pattern{}, false
+}
+
+// matches 报告 name 是否符合规则 p。
+func (p Pattern) matches(name string, isDir bool) bool {
+	if p.Dir != isDir || len(name) < len(p.Prefix)+len(p.Suffix) {
+		return false
+	}
+	return strings.HasPrefix(name, p.Prefix) && strings.HasSuffix(name, p.Suffix)
+}
@@ -0,0 +1,27 @@
+package artifact
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		isDir bool
+		kind  string
+	}{
+		{"file.bin.part", false, "part"},
+		{"state.json.part", false, "part"},
+		{"import-staging-4821", true, "staging"},
+		{"datastore_spec.tmp", false, "spec"},
+		{"file.bin.part", true, ""},
+		{"import-staging-4821", false, ""},
+		{"file.bin.partial", false, ""},
+		{"datastore_spec", false, ""},
+		{"notes.tmp", false, ""},
+	}
+	for _, tt := range tests {
+		p, ok := Match(tt.name, tt.isDir)
+		if ok != (tt.kind != "") || p.Kind != tt.kind {
+			t.Errorf("Match(%q, %v) = %q, %v, want %q", tt.name, tt.isDir, p.Kind, ok, tt.kind)
+		}
+	}
+}
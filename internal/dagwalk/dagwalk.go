@@ -0,0 +1,240 @@
+// Package dagwalk 提供仓库中唯一的 DAG 遍历实现
+//
+// 校验、交叉检查和打包都需要找出根 CID 可达的所有块。Walk 用一组 worker 并行
+// 获取和解码节点，已访问的 CID 以二进制形式保存；待访问的 CID 放在有界的队列中，
+// 队列满时 worker 以深度优先的方式自己处理子节点，因此无论 DAG 多宽（一个目录
+// 有上千万个链接）或多深（上千万层的链），内存都不会无限增长。结果与节点完成的
+// 顺序无关：缺失和无法解码的块按 CID 排序。
+package dagwalk
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+const (
+	// DefaultConcurrency 是默认的 worker 数
+	DefaultConcurrency = 32
+
+	// DefaultMaxFrontier 是默认的待访问队列长度
+	DefaultMaxFrontier = 4096
+)
+
+// Options 配置 Walk。
+type Options struct {
+	// Concurrency 是并行获取节点的 worker 数，0 或负数使用 DefaultConcurrency，
+	// 1 表示串行遍历
+	Concurrency int
+
+	// MaxFrontier 是等待 worker 获取的 CID 数上限，0 或负数使用 DefaultMaxFrontier
+	MaxFrontier int
+
+	// Stat 返回 raw 块的大小而不读取其数据。raw 块没有链接，只需要检查它是否
+	// 存在，例如传入 blockstore 的 GetSize。为 nil 时不检查 raw 块：它们被记为
+	// 已访问，大小计为 0
+	Stat func(ctx context.Context, c cid.Cid) (int, error)
+
+	// RecordSizes 为 true 时在 Result.Sizes 中记录每个读取到的块的大小，
+	// 内存随块数线性增长
+	RecordSizes bool
+}
+
+// Failure 是一个无法获取或解码的块。
+type Failure struct {
+	Cid cid.Cid // 块的 CID
+	Err error   // 获取或解码的错误
+}
+
+// Result 是一次遍历的结果。
+type Result struct {
+	// Visited 包含所有可达的 CID，包括缺失和无法解码的块
+	Visited *Set
+
+	// Missing 是不存在的块，按 CID 字符串排序，它们下面的块无法得知
+	Missing []cid.Cid
+
+	// Invalid 是存在但无法读取或解码的块，按 CID 字符串排序
+	Invalid []Failure
+
+	// Size 是所有读取到的块的字节数之和
+	Size int64
+
+	// Sizes 是每个读取到的块的字节数，只在 Options.RecordSizes 为 true 时设置
+	Sizes map[cid.Cid]int64
+}
+
+// Walk 遍历 root 可达的所有块。
+//
+// 缺失和无法解码的块不会中止遍历，它们被记录在结果中，下面的块被跳过。
+// 每个块只访问一次，无论它被链接多少次。
+//
+// 参数：
+//
+//	ctx - 用于取消遍历的上下文
+//	ng - 用于获取节点的 NodeGetter
+//	root - 根 CID
+//	opts - 遍历选项
+//
+// 返回：
+//
+//	*Result - 遍历结果
+//	error - 只有上下文被取消时返回错误
+func Walk(ctx context.Context, ng ipld.NodeGetter, root cid.Cid, opts Options) (*Result, error) {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	maxFrontier := opts.MaxFrontier
+	if maxFrontier <= 0 {
+		maxFrontier = DefaultMaxFrontier
+	}
+
+	w := &walker{
+		ctx:      ctx,
+		ng:       ng,
+		stat:     opts.Stat,
+		frontier: make(chan cid.Cid, maxFrontier),
+		visited:  NewSet(),
+	}
+	if opts.RecordSizes {
+		w.sizes = make(map[cid.Cid]int64)
+	}
+
+	w.visited.Visit(root)
+	w.pending.Add(1)
+	w.frontier <- root
+
+	var workers sync.WaitGroup
+	for range concurrency {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for c := range w.frontier {
+				w.process(c)
+				w.pending.Done()
+			}
+		}()
+	}
+
+	w.pending.Wait()
+	close(w.frontier)
+	workers.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(w.missing, func(i, j int) bool { return w.missing[i].String() < w.missing[j].String() })
+	sort.Slice(w.invalid, func(i, j int) bool { return w.invalid[i].Cid.String() < w.invalid[j].Cid.String() })
+	return &Result{Visited: w.visited, Missing: w.missing, Invalid: w.invalid, Size: w.size, Sizes: w.sizes}, nil
+}
+
+// walker 是一次遍历的共享状态。
+type walker struct {
+	ctx      context.Context
+	ng       ipld.NodeGetter
+	stat     func(ctx context.Context, c cid.Cid) (int, error)
+	frontier chan cid.Cid   // 等待 worker 获取的 CID
+	pending  sync.WaitGroup // 已进入队列但尚未处理完的 CID
+
+	mu      sync.Mutex
+	visited *Set
+	missing []cid.Cid
+	invalid []Failure
+	size    int64
+	sizes   map[cid.Cid]int64 // 未设置 RecordSizes 时为 nil
+}
+
+// process 访问 c 以及队列放不下的所有后代。
+//
+// 子节点优先放入队列交给其他 worker；队列满时在这里深度优先地处理，
+// 栈中只保存路径上每个节点剩余的链接。
+func (w *walker) process(c cid.Cid) {
+	if w.ctx.Err() != nil {
+		return
+	}
+
+	var stack [][]*ipld.Link
+	if links := w.fetch(c); len(links) > 0 {
+		stack = append(stack, links)
+	}
+	for len(stack) > 0 {
+		if w.ctx.Err() != nil {
+			return
+		}
+
+		top := stack[len(stack)-1]
+		next := top[0].Cid
+		if len(top) == 1 {
+			stack = stack[:len(stack)-1]
+		} else {
+			stack[len(stack)-1] = top[1:]
+		}
+
+		if !w.visit(next) {
+			continue
+		}
+		w.pending.Add(1)
+		select {
+		case w.frontier <- next:
+			continue
+		default:
+			w.pending.Done()
+		}
+		if links := w.fetch(next); len(links) > 0 {
+			stack = append(stack, links)
+		}
+	}
+}
+
+// visit 把 c 记为已访问，c 第一次出现时返回 true。
+func (w *walker) visit(c cid.Cid) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.visited.Visit(c)
+}
+
+// fetch 获取并记录 c，返回它的链接。
+func (w *walker) fetch(c cid.Cid) []*ipld.Link {
+	if c.Type() == cid.Raw {
+		if w.stat == nil {
+			return nil
+		}
+		size, err := w.stat(w.ctx, c)
+		w.record(c, int64(size), err)
+		return nil
+	}
+
+	nd, err := w.ng.Get(w.ctx, c)
+	if err != nil {
+		w.record(c, 0, err)
+		return nil
+	}
+	w.record(c, int64(len(nd.RawData())), nil)
+	return nd.Links()
+}
+
+// record 记录访问 c 的结果。上下文取消引起的错误不记录。
+func (w *walker) record(c cid.Cid, size int64, err error) {
+	if err != nil && w.ctx.Err() != nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch {
+	case err == nil:
+		w.size += size
+		if w.sizes != nil {
+			w.sizes[c] = size
+		}
+	case ipld.IsNotFound(err):
+		w.missing = append(w.missing, c)
+	default:
+		w.invalid = append(w.invalid, Failure{Cid: c, Err: err})
+	}
+}
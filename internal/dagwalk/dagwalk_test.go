@@ -0,0 +1,297 @@
+package dagwalk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// newDAG 创建一个基于内存 blockstore 的 DAG 服务
+func newDAG(tb testing.TB) (ipld.DAGService, blockstore.Blockstore) {
+	tb.Helper()
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	return merkledag.NewDAGService(blockservice.New(bs, nil)), bs
+}
+
+// addNode 创建并保存一个链接到 children 的节点
+func addNode(tb testing.TB, dag ipld.DAGService, data string, children ...ipld.Node) ipld.Node {
+	tb.Helper()
+	nd := merkledag.NodeWithData([]byte(data))
+	for i, child := range children {
+		if err := nd.AddNodeLink(fmt.Sprintf("%d", i), child); err != nil {
+			tb.Fatalf("AddNodeLink failed: %v", err)
+		}
+	}
+	if err := dag.Add(context.Background(), nd); err != nil {
+		tb.Fatalf("Add failed: %v", err)
+	}
+	return nd
+}
+
+// addRaw 创建并保存一个 raw 叶子
+func addRaw(tb testing.TB, dag ipld.DAGService, data string) ipld.Node {
+	tb.Helper()
+	nd := merkledag.NewRawNode([]byte(data))
+	if err := dag.Add(context.Background(), nd); err != nil {
+		tb.Fatalf("Add failed: %v", err)
+	}
+	return nd
+}
+
+// buildWide 创建一个有 width 个叶子的根节点
+func buildWide(tb testing.TB, dag ipld.DAGService, width int) ipld.Node {
+	tb.Helper()
+	leaves := make([]ipld.Node, width)
+	for i := range leaves {
+		leaves[i] = addNode(tb, dag, fmt.Sprintf("leaf %d", i))
+	}
+	return addNode(tb, dag, "wide", leaves...)
+}
+
+// buildDeep 创建一条 depth 层的链
+func buildDeep(tb testing.TB, dag ipld.DAGService, depth int) ipld.Node {
+	tb.Helper()
+	nd := addNode(tb, dag, "bottom")
+	for i := range depth - 1 {
+		nd = addNode(tb, dag, fmt.Sprintf("level %d", i), nd)
+	}
+	return nd
+}
+
+// buildMixed 创建一个有共享子树、raw 叶子、既宽又深的 DAG
+func buildMixed(tb testing.TB, dag ipld.DAGService) ipld.Node {
+	tb.Helper()
+	shared := addNode(tb, dag, "shared", addRaw(tb, dag, "shared raw"))
+	var dirs []ipld.Node
+	for i := range 20 {
+		var files []ipld.Node
+		for j := range 50 {
+			files = append(files, addNode(tb, dag, fmt.Sprintf("file %d/%d", i, j), addRaw(tb, dag, fmt.Sprintf("raw %d/%d", i, j)), shared))
+		}
+		dirs = append(dirs, addNode(tb, dag, fmt.Sprintf("dir %d", i), files...))
+	}
+	dirs = append(dirs, buildDeep(tb, dag, 500))
+	return addNode(tb, dag, "root", dirs...)
+}
+
+func TestWalk_ParallelMatchesSerial(t *testing.T) {
+	dag, bs := newDAG(t)
+	root := buildMixed(t, dag)
+	ctx := context.Background()
+
+	serial, err := Walk(ctx, dag, root.Cid(), Options{Concurrency: 1, Stat: bs.GetSize})
+	if err != nil {
+		t.Fatalf("serial Walk failed: %v", err)
+	}
+	// 1 + 20 dirs + 20*50 files + 20*50 raws + shared + shared raw + 500 chain
+	if got, want := serial.Visited.Len(), 1+20+1000+1000+2+500; got != want {
+		t.Fatalf("serial walk visited %d blocks, want %d", got, want)
+	}
+
+	for _, opts := range []Options{
+		{Concurrency: 8},
+		{Concurrency: 8, MaxFrontier: 1},
+		{Concurrency: 32, MaxFrontier: 16},
+	} {
+		opts.Stat = bs.GetSize
+		parallel, err := Walk(ctx, dag, root.Cid(), opts)
+		if err != nil {
+			t.Fatalf("%+v: Walk failed: %v", opts, err)
+		}
+		if !slices.Equal(parallel.Visited.Strings(), serial.Visited.Strings()) {
+			t.Errorf("%+v: visited %d blocks, serial walk visited %d", opts, parallel.Visited.Len(), serial.Visited.Len())
+		}
+		if parallel.Size != serial.Size {
+			t.Errorf("%+v: size %d, serial walk %d", opts, parallel.Size, serial.Size)
+		}
+	}
+}
+
+func TestWalk_Missing(t *testing.T) {
+	dag, bs := newDAG(t)
+	ctx := context.Background()
+
+	raw := addRaw(t, dag, "raw leaf")
+	below := addNode(t, dag, "below")
+	gone := addNode(t, dag, "gone", below)
+	broken := addNode(t, dag, "broken")
+	root := addNode(t, dag, "root", gone, raw, broken)
+
+	for _, c := range []cid.Cid{gone.Cid(), raw.Cid()} {
+		if err := bs.DeleteBlock(ctx, c); err != nil {
+			t.Fatalf("DeleteBlock failed: %v", err)
+		}
+	}
+	// 同一个 CID 下保存无法解码的数据
+	corrupt, err := blocks.NewBlockWithCid([]byte("not a protobuf node"), broken.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bs.DeleteBlock(ctx, broken.Cid()); err != nil {
+		t.Fatalf("DeleteBlock failed: %v", err)
+	}
+	if err := bs.Put(ctx, corrupt); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	for _, concurrency := range []int{1, 8} {
+		result, err := Walk(ctx, dag, root.Cid(), Options{Concurrency: concurrency, Stat: bs.GetSize})
+		if err != nil {
+			t.Fatalf("Walk failed: %v", err)
+		}
+
+		want := []cid.Cid{gone.Cid(), raw.Cid()}
+		slices.SortFunc(want, func(a, b cid.Cid) int {
+			return strings.Compare(a.String(), b.String())
+		})
+		if !slices.Equal(result.Missing, want) {
+			t.Errorf("Missing = %v, want %v", result.Missing, want)
+		}
+		if len(result.Invalid) != 1 || !result.Invalid[0].Cid.Equals(broken.Cid()) || result.Invalid[0].Err == nil {
+			t.Errorf("Invalid = %v, want only %s", result.Invalid, broken.Cid())
+		}
+		if result.Visited.Has(below.Cid()) {
+			t.Error("walk descended below a missing block")
+		}
+		if !result.Visited.Has(gone.Cid()) || !result.Visited.Has(raw.Cid()) {
+			t.Error("missing blocks not in the visited set")
+		}
+	}
+
+	// 不检查 raw 叶子时，缺失的 raw 叶子不被报告
+	result, err := Walk(ctx, dag, root.Cid(), Options{})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	if len(result.Missing) != 1 || !result.Missing[0].Equals(gone.Cid()) {
+		t.Errorf("Missing = %v without Stat, want only %s", result.Missing, gone.Cid())
+	}
+}
+
+func TestWalk_Deep(t *testing.T) {
+	dag, _ := newDAG(t)
+	root := buildDeep(t, dag, 20000)
+
+	result, err := Walk(context.Background(), dag, root.Cid(), Options{Concurrency: 4, MaxFrontier: 1})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	if result.Visited.Len() != 20000 {
+		t.Errorf("visited %d blocks, want 20000", result.Visited.Len())
+	}
+}
+
+func TestWalk_RecordSizes(t *testing.T) {
+	ctx := context.Background()
+	dag, bs := newDAG(t)
+	root := buildMixed(t, dag)
+
+	result, err := Walk(ctx, dag, root.Cid(), Options{Stat: bs.GetSize, RecordSizes: true})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	if len(result.Sizes) != result.Visited.Len() {
+		t.Fatalf("recorded %d sizes for %d blocks", len(result.Sizes), result.Visited.Len())
+	}
+	var total int64
+	err = result.Visited.ForEach(func(c cid.Cid) error {
+		size, err := bs.GetSize(ctx, c)
+		if err != nil {
+			return err
+		}
+		if result.Sizes[c] != int64(size) {
+			t.Errorf("Sizes[%s] = %d, want %d", c, result.Sizes[c], size)
+		}
+		total += int64(size)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("GetSize failed: %v", err)
+	}
+	if total != result.Size {
+		t.Errorf("sizes add up to %d, Size = %d", total, result.Size)
+	}
+
+	// 未设置 RecordSizes 时不记录
+	result, err = Walk(ctx, dag, root.Cid(), Options{Stat: bs.GetSize})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	if result.Sizes != nil {
+		t.Errorf("Sizes = %d entries without RecordSizes, want nil", len(result.Sizes))
+	}
+}
+
+func TestWalk_Cancelled(t *testing.T) {
+	dag, _ := newDAG(t)
+	root := buildWide(t, dag, 1000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Walk(ctx, dag, root.Cid(), Options{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Walk returned %v, want context.Canceled", err)
+	}
+}
+
+func TestSet(t *testing.T) {
+	dag, _ := newDAG(t)
+	a := addNode(t, dag, "a").Cid()
+	b := addRaw(t, dag, "b").Cid()
+
+	s := NewSet()
+	if !s.Visit(a) || !s.Visit(b) || s.Visit(a) {
+		t.Fatal("Visit did not report first visits")
+	}
+	if !s.Has(a) || !s.Has(b) || s.Len() != 2 {
+		t.Errorf("set does not hold both CIDs")
+	}
+	want := []string{a.String(), b.String()}
+	slices.Sort(want)
+	if got := s.Strings(); !slices.Equal(got, want) {
+		t.Errorf("Strings() = %v, want %v", got, want)
+	}
+}
+
+func BenchmarkWalk_Wide(b *testing.B) {
+	dag, _ := newDAG(b)
+	root := buildWide(b, dag, 100000)
+
+	for _, concurrency := range []int{1, 8, 32} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := Walk(context.Background(), dag, root.Cid(), Options{Concurrency: concurrency}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkWalk_Deep(b *testing.B) {
+	dag, _ := newDAG(b)
+	root := buildDeep(b, dag, 100000)
+
+	for _, concurrency := range []int{1, 8, 32} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := Walk(context.Background(), dag, root.Cid(), Options{Concurrency: concurrency}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package dagwalk
+
+import (
+	"sort"
+
+	"github.com/ipfs/go-cid"
+)
+
+// Set 是一组 CID，以二进制形式保存，比 CID 字符串节省约一半的内存。
+//
+// Set 不是并发安全的。
+type Set struct {
+	m map[string]struct{}
+}
+
+// NewSet 创建一个空的 Set。
+func NewSet() *Set {
+	return &Set{m: make(map[string]struct{})}
+}
+
+// Visit 把 c 加入集合，c 原本不在集合中时返回 true。
+func (s *Set) Visit(c cid.Cid) bool {
+	key := c.KeyString()
+	if _, ok := s.m[key]; ok {
+		return false
+	}
+	s.m[key] = struct{}{}
+	return true
+}
+
+// Has 返回 c 是否在集合中。
+func (s *Set) Has(c cid.Cid) bool {
+	_, ok := s.m[c.KeyString()]
+	return ok
+}
+
+// Len 返回集合中的 CID 数。
+func (s *Set) Len() int {
+	return len(s.m)
+}
+
+// ForEach 以任意顺序对集合中的每个 CID 调用 fn，fn 返回错误时停止并返回该错误。
+func (s *Set) ForEach(fn func(c cid.Cid) error) error {
+	for key := range s.m {
+		c, err := cid.Cast([]byte(key))
+		if err != nil {
+			return err
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Strings 返回集合中所有 CID 的字符串形式，按字符串排序。
+func (s *Set) Strings() []string {
+	out := make([]string, 0, len(s.m))
+	_ = s.ForEach(func(c cid.Cid) error {
+		out = append(out, c.String())
+		return nil
+	})
+	sort.Strings(out)
+	return out
+}
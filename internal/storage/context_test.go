@@ -2,8 +2,14 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
+
+	ds "github.com/ipfs/go-datastore"
 )
 
 func TestNewStorageWithContext(t *testing.T) {
@@ -246,3 +252,139 @@ func TestContextMethods_Equivalence(t *testing.T) {
 		CleanupTestData(t, tmpDir2)
 	})
 }
+
+// countdownContext 在 Err 被调用 n 次之后变为已取消，用于在操作中途取消。
+type countdownContext struct {
+	context.Context
+	n int
+}
+
+func (c *countdownContext) Err() error {
+	if c.n <= 0 {
+		return context.Canceled
+	}
+	c.n--
+	return nil
+}
+
+// slowCloseDatastore 的 Close 等待 release 被关闭后才关闭底层 datastore。
+type slowCloseDatastore struct {
+	Datastore
+	release chan struct{}
+}
+
+func (d *slowCloseDatastore) Close() error {
+	<-d.release
+	return d.Datastore.Close()
+}
+
+func TestNewStorageWithContext_LockWait(t *testing.T) {
+	tmpDir := SetupTempDir(t, "storage-ctx-lock-*")
+	defer CleanupTestData(t, tmpDir)
+
+	holder, err := NewStorage(tmpDir)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	defer holder.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = NewStorageWithContext(ctx, tmpDir)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("NewStorageWithContext error = %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("NewStorageWithContext returned after %v", elapsed)
+	}
+
+	// 锁释放后可以正常打开
+	if err := holder.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	s, err := NewStorageWithContext(context.Background(), tmpDir)
+	if err != nil {
+		t.Fatalf("NewStorageWithContext after release failed: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+func TestDestroyWithContext_CancelledMidway(t *testing.T) {
+	s, tmpDir := SetupStorage(t)
+
+	bulk := filepath.Join(tmpDir, "bulk")
+	if err := os.MkdirAll(bulk, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 200; i++ {
+		if err := os.WriteFile(filepath.Join(bulk, fmt.Sprintf("f%03d", i)), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := s.DestroyWithContext(&countdownContext{Context: context.Background(), n: 50})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("DestroyWithContext error = %v, want Canceled", err)
+	}
+	entries, err := os.ReadDir(bulk)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) == 0 || len(entries) == 200 {
+		t.Errorf("%d of 200 files left, want a partial removal", len(entries))
+	}
+
+	// 中断后锁已释放，重新调用可以完成删除
+	if err := s.Destroy(); err != nil {
+		t.Fatalf("Destroy after cancellation failed: %v", err)
+	}
+	AssertFileNotExists(t, tmpDir)
+}
+
+func TestCloseWithContext_AbandonedWait(t *testing.T) {
+	s, tmpDir := SetupStorage(t)
+
+	ctx := context.Background()
+	d := s.Datastore()
+	for i := 0; i < 100; i++ {
+		if err := d.Put(ctx, ds.NewKey(fmt.Sprintf("/block-%d", i)), []byte("dirty")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	slow := &slowCloseDatastore{Datastore: s.datastore, release: make(chan struct{})}
+	s.datastore = slow
+
+	cctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := s.CloseWithContext(cctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("CloseWithContext error = %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("CloseWithContext returned after %v", elapsed)
+	}
+
+	// 关闭在后台完成之前仍然持有锁
+	lockCtx, lockCancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer lockCancel()
+	if _, err := NewStorageWithContext(lockCtx, tmpDir); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("NewStorageWithContext during close error = %v, want DeadlineExceeded", err)
+	}
+
+	close(slow.release)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	reopened, err := NewStorage(tmpDir)
+	if err != nil {
+		t.Fatalf("NewStorage after close failed: %v", err)
+	}
+	if err := reopened.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"fmt"
+	"strings"
 )
 
 // StorageError 表示存储操作期间的错误。
@@ -78,3 +79,53 @@ type InvalidPathError struct {
 func (e *InvalidPathError) Error() string {
 	return fmt.Sprintf("invalid path '%s': %s", e.Path, e.Reason)
 }
+
+// RedactedPathPlaceholder 是脱敏后的错误信息中替代存储根路径的文本。
+const RedactedPathPlaceholder = "<repo>"
+
+// RedactedError 包装一个错误，在错误信息中用 RedactedPathPlaceholder 替换存储根路径。
+//
+// 底层错误保持不变，可以通过 errors.As 取得包含完整路径的原始错误，用于本地日志。
+type RedactedError struct {
+	// Root 是被替换的存储根路径
+	Root string
+	// Err 是底层错误
+	Err error
+}
+
+// Error 实现 error 接口。
+func (e *RedactedError) Error() string {
+	return strings.ReplaceAll(e.Err.Error(), e.Root, RedactedPathPlaceholder)
+}
+
+// Unwrap 返回底层错误，支持 errors.Is 和 errors.As。
+func (e *RedactedError) Unwrap() error {
+	return e.Err
+}
+
+// VersionError 表示仓库的磁盘格式版本与本库不匹配。
+type VersionError struct {
+	// Path 是仓库路径
+	Path string
+	// Version 是仓库的磁盘格式版本
+	Version int
+	// Supported 是本库支持的版本
+	Supported int
+	// Err 是 ErrMigrationRequired 或 ErrVersionTooNew
+	Err error
+}
+
+// Error 实现 error 接口。
+func (e *VersionError) Error() string {
+	if e.Version > e.Supported {
+		return fmt.Sprintf("%v: repository at %s has version %d, this library supports up to %d; use a newer version of the library",
+			e.Err, e.Path, e.Version, e.Supported)
+	}
+	return fmt.Sprintf("%v: repository at %s has version %d, this library requires %d; run storage.Upgrade to migrate it",
+		e.Err, e.Path, e.Version, e.Supported)
+}
+
+// Unwrap 返回底层错误，支持 errors.Is 和 errors.As。
+func (e *VersionError) Unwrap() error {
+	return e.Err
+}
@@ -66,11 +66,15 @@ func (cfg *levelDBDatastoreConfig) DiskSpec() DiskSpec {
 // 返回：
 //
 //	Datastore - LevelDB datastore 实例
-//	error - 如果创建失败，返回错误
+//	error - 如果创建失败，返回错误；数据库损坏时返回 *CorruptionError
 func (cfg *levelDBDatastoreConfig) Create(path string) (Datastore, error) {
 	fullPath := resolvePath(path, cfg.path)
-
-	return levelds.NewDatastore(fullPath, &levelds.Options{
+	opts := &levelds.Options{
 		Compression: cfg.compression,
-	})
+	}
+
+	if err := checkLevelDB(fullPath, opts); err != nil {
+		return nil, err
+	}
+	return levelds.NewDatastore(fullPath, opts)
 }
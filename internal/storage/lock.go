@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rogpeppe/go-internal/lockedfile"
+)
+
+// maxLockPoll 是等待其他实例释放锁时两次尝试之间的最长间隔。
+const maxLockPoll = 100 * time.Millisecond
+
+// NoLockWait 作为 Options.LockWait 时，锁被其他实例持有立即返回错误。
+const NoLockWait time.Duration = -1
+
+// takeoverSuffix 是接管失效锁时串行化接管者的锁文件的后缀。
+const takeoverSuffix = ".takeover"
+
+// ErrInUse 表示存储被其他实例（同一进程或其他进程）打开，不能销毁。
+var ErrInUse = errors.New("repository is in use")
+
+// InUseError 表示存储的锁被其他实例持有。
+//
+// PID、Hostname 和 Since 来自锁文件，只有持有者启用 LockMetadata（和
+// LockHostname）时才有值。
+type InUseError struct {
+	// Path 是存储路径
+	Path string
+	// PID 是持有锁的进程 ID，未知时为 0
+	PID int
+	// Hostname 是持有锁的主机名，未知时为空
+	Hostname string
+	// Since 是持有者获得锁的时间，未知时为零值
+	Since time.Time
+}
+
+// Error 实现 error 接口。
+func (e *InUseError) Error() string {
+	holder := "another instance"
+	if e.PID != 0 {
+		holder = "pid " + strconv.Itoa(e.PID)
+		if e.Hostname != "" {
+			holder += " on " + e.Hostname
+		}
+	}
+	if !e.Since.IsZero() {
+		holder += " since " + formatSince(e.Since)
+	}
+	return fmt.Sprintf("%v: %s is locked by %s", ErrInUse, e.Path, holder)
+}
+
+// formatSince 格式化获得锁的时间，当天的时间省略日期。
+func formatSince(t time.Time) string {
+	t = t.Local()
+	now := time.Now()
+	if y, m, d := t.Date(); y == now.Year() && m == now.Month() && d == now.Day() {
+		return t.Format(time.TimeOnly)
+	}
+	return t.Format(time.DateTime)
+}
+
+// Unwrap 返回 ErrInUse，支持 errors.Is。
+func (e *InUseError) Unwrap() error {
+	return ErrInUse
+}
+
+// acquireLock 不阻塞地获取 lockPath 的锁，锁文件不存在时创建它。
+//
+// 锁被其他实例持有时返回 *InUseError，其中包含锁文件记录的持有者信息。
+// 返回的文件关闭时释放锁，但不删除锁文件。
+func acquireLock(path, lockPath string) (*os.File, error) {
+	f, locked, err := tryLock(lockPath)
+	if err != nil {
+		return nil, &LockError{Path: lockPath, Err: err}
+	}
+	if !locked {
+		return nil, newInUseError(path, lockPath)
+	}
+	return f, nil
+}
+
+// newInUseError 返回存储 path 被锁定的错误，包含锁文件记录的持有者信息。
+func newInUseError(path, lockPath string) *InUseError {
+	holder := readLockFile(lockPath)
+	return &InUseError{Path: path, PID: holder.pid, Hostname: holder.hostname, Since: holder.since}
+}
+
+// lockHolder 是锁文件记录的持有者信息。
+type lockHolder struct {
+	pid      int
+	hostname string
+	since    time.Time
+}
+
+// readLockHolder 读取锁文件中记录的 PID 和主机名，没有记录时返回零值。
+func readLockHolder(lockPath string) (int, string) {
+	holder := readLockFile(lockPath)
+	return holder.pid, holder.hostname
+}
+
+// readLockFile 读取锁文件中记录的持有者信息，没有记录时返回零值。
+//
+// 锁文件依次记录 PID、主机名（可以为空行）和获得锁的时间（RFC 3339），
+// 旧版本只写入前两行。
+func readLockFile(lockPath string) lockHolder {
+	data, err := os.ReadFile(lockPath)
+	if err != nil {
+		return lockHolder{}
+	}
+
+	lines := strings.SplitN(strings.TrimSpace(string(data)), "\n", 3)
+	pid, err := strconv.Atoi(strings.TrimSpace(lines[0]))
+	if err != nil {
+		return lockHolder{}
+	}
+	holder := lockHolder{pid: pid}
+	if len(lines) > 1 {
+		holder.hostname = strings.TrimSpace(lines[1])
+	}
+	if len(lines) > 2 {
+		holder.since, _ = time.Parse(time.RFC3339, strings.TrimSpace(lines[2]))
+	}
+	return holder
+}
+
+// lockMetadata 返回当前进程获得锁时写入锁文件的内容。
+func lockMetadata(opts Options) string {
+	var hostname string
+	if opts.LockHostname {
+		hostname, _ = os.Hostname()
+	}
+	return strconv.Itoa(os.Getpid()) + "\n" + hostname + "\n" + time.Now().Format(time.RFC3339)
+}
+
+// lockHandle 是已加锁的锁文件，关闭时释放锁。
+type lockHandle interface {
+	io.Writer
+	Name() string
+	Close() error
+}
+
+// waitLock 获取 lockPath 的锁，锁被其他实例持有时按 opts.LockWait 等待其释放：
+// 0 表示直到 ctx 结束，正值表示最多等待这么久，NoLockWait 表示不等待。
+// 不再等待时返回 *InUseError，ctx 结束时返回 ctx.Err()。启用
+// opts.StaleLockTakeover 时接管持有者已不存在的锁，见 takeOverStaleLock。
+//
+// 在不支持不阻塞加锁的平台上使用 lockedfile 阻塞等待，此时不响应 ctx 和 opts。
+// 返回的锁文件内容被清空。
+func waitLock(ctx context.Context, lockPath string, opts Options) (lockHandle, error) {
+	if !canTryLock {
+		f, err := lockedfile.Create(lockPath)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+
+	waitCtx := ctx
+	if opts.LockWait > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, opts.LockWait)
+		defer cancel()
+	}
+
+	poll := time.Millisecond
+	for {
+		f, locked, err := tryLock(lockPath)
+		if err != nil {
+			return nil, err
+		}
+		if !locked && opts.StaleLockTakeover {
+			if f, locked, err = takeOverStaleLock(lockPath); err != nil {
+				return nil, err
+			}
+		}
+		if locked {
+			if err := f.Truncate(0); err != nil {
+				_ = f.Close()
+				return nil, err
+			}
+			return f, nil
+		}
+
+		if opts.LockWait < 0 {
+			return nil, newInUseError(filepath.Dir(lockPath), lockPath)
+		}
+		select {
+		case <-waitCtx.Done():
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("gave up after %v: %w", opts.LockWait, newInUseError(filepath.Dir(lockPath), lockPath))
+		case <-time.After(poll):
+		}
+		if poll *= 2; poll > maxLockPoll {
+			poll = maxLockPoll
+		}
+	}
+}
+
+// takeOverStaleLock 在锁文件记录的持有者进程已不存在时删除锁文件并重新加锁。
+//
+// 持有者异常退出时锁通常随之释放，但继承了锁文件描述符的子进程会继续持有它。
+// 只接管记录了 PID、主机名为空或与本机相同、且该进程在本机不存在的锁。
+// 接管者之间通过另一个锁文件串行化，之后的接管者看到的是新持有者的 PID。
+// 不能接管时返回 false。
+func takeOverStaleLock(lockPath string) (*os.File, bool, error) {
+	guard, locked, err := tryLock(lockPath + takeoverSuffix)
+	if err != nil || !locked {
+		return nil, false, err
+	}
+	defer func() {
+		_ = guard.Close()
+	}()
+
+	// 持有锁时重新检查，期间锁可能已被释放或接管
+	f, locked, err := tryLock(lockPath)
+	if err != nil || locked {
+		return f, locked, err
+	}
+	holder := readLockFile(lockPath)
+	if holder.pid <= 0 || processAlive(holder.pid) {
+		return nil, false, nil
+	}
+	if holder.hostname != "" {
+		if hostname, err := os.Hostname(); err != nil || hostname != holder.hostname {
+			return nil, false, nil
+		}
+	}
+
+	if err := os.Remove(lockPath); err != nil && !os.IsNotExist(err) {
+		return nil, false, err
+	}
+	return tryLock(lockPath)
+}
@@ -0,0 +1,22 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package storage
+
+import "os"
+
+// canTryLock 表示 tryLock 能不阻塞地检查其他实例持有的锁，这些平台上不能。
+const canTryLock = false
+
+// tryLock 在不支持不阻塞加锁的平台上只打开 path，不检查其他实例。
+func tryLock(path string) (*os.File, bool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o666)
+	if err != nil {
+		return nil, false, err
+	}
+	return f, true, nil
+}
+
+// processAlive 在这些平台上不能检查进程是否存在，总是返回 true，因此不会接管锁。
+func processAlive(int) bool {
+	return true
+}
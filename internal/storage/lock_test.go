@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDestroy_InUse 测试已关闭的实例在其他实例打开存储时不能销毁
+func TestDestroy_InUse(t *testing.T) {
+	tmpDir := SetupTempDir(t, "destroy-in-use-*")
+	defer CleanupTestData(t, tmpDir)
+
+	stale, err := NewStorage(tmpDir)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := stale.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	s, err := NewStorageWithOptions(context.Background(), tmpDir, Options{LockMetadata: true})
+	if err != nil {
+		t.Fatalf("NewStorageWithOptions failed: %v", err)
+	}
+
+	err = stale.Destroy()
+	var inUse *InUseError
+	if !errors.As(err, &inUse) || !errors.Is(err, ErrInUse) {
+		t.Fatalf("Destroy() error = %v, want *InUseError", err)
+	}
+	if inUse.PID != os.Getpid() || inUse.Hostname != "" {
+		t.Errorf("holder = %d on %q, want pid %d", inUse.PID, inUse.Hostname, os.Getpid())
+	}
+	AssertFileExists(t, DatastoreSpecPath(tmpDir))
+	AssertFileExists(t, filepath.Join(tmpDir, LockFile))
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := stale.Destroy(); err != nil {
+		t.Fatalf("Destroy failed: %v", err)
+	}
+	AssertFileNotExists(t, tmpDir)
+}
+
+// TestDestroy_OrphanedLockFile 测试没有被锁定的残留锁文件不妨碍销毁
+func TestDestroy_OrphanedLockFile(t *testing.T) {
+	s, tmpDir := SetupStorage(t)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, LockFile), []byte("12345\nelsewhere"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Destroy(); err != nil {
+		t.Fatalf("Destroy failed: %v", err)
+	}
+	AssertFileNotExists(t, tmpDir)
+}
+
+func TestReadLockHolder(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		pid      int
+		hostname string
+	}{
+		{name: "empty", content: ""},
+		{name: "pid", content: "42", pid: 42},
+		{name: "pid and hostname", content: "42\nbuild-host\n", pid: 42, hostname: "build-host"},
+		{name: "pid and time", content: "42\n\n2026-10-16T14:02:11Z\n", pid: 42},
+		{name: "garbage", content: "not a pid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), LockFile)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			pid, hostname := readLockHolder(path)
+			if pid != tt.pid || hostname != tt.hostname {
+				t.Errorf("readLockHolder() = %d, %q; want %d, %q", pid, hostname, tt.pid, tt.hostname)
+			}
+		})
+	}
+}
+
+// TestNewStorage_NoLockWait 测试不等待锁时立即返回包含持有者信息的错误
+func TestNewStorage_NoLockWait(t *testing.T) {
+	if !canTryLock {
+		t.Skip("platform cannot check the lock without blocking")
+	}
+	tmpDir := SetupTempDir(t, "lock-no-wait-*")
+	defer CleanupTestData(t, tmpDir)
+
+	s, err := NewStorageWithOptions(context.Background(), tmpDir, Options{LockMetadata: true})
+	if err != nil {
+		t.Fatalf("NewStorageWithOptions failed: %v", err)
+	}
+	defer s.Close()
+
+	start := time.Now()
+	_, err = NewStorageWithOptions(context.Background(), tmpDir, Options{LockWait: NoLockWait})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("open took %v, want it to fail at once", elapsed)
+	}
+	var lockErr *LockError
+	var inUse *InUseError
+	if !errors.As(err, &lockErr) || !errors.As(err, &inUse) || !errors.Is(err, ErrInUse) {
+		t.Fatalf("error = %v, want *LockError wrapping *InUseError", err)
+	}
+	if inUse.PID != os.Getpid() {
+		t.Errorf("PID = %d, want %d", inUse.PID, os.Getpid())
+	}
+	if time.Since(inUse.Since) > time.Minute {
+		t.Errorf("Since = %v, want the time the lock was taken", inUse.Since)
+	}
+	want := "locked by pid " + strconv.Itoa(os.Getpid()) + " since "
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q does not contain %q", err, want)
+	}
+}
+
+// TestNewStorage_LockWait 测试等待锁超时后返回持有者信息而不是 ctx 的错误
+func TestNewStorage_LockWait(t *testing.T) {
+	if !canTryLock {
+		t.Skip("platform cannot check the lock without blocking")
+	}
+	tmpDir := SetupTempDir(t, "lock-wait-*")
+	defer CleanupTestData(t, tmpDir)
+
+	s, err := NewStorage(tmpDir)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+
+	const wait = 50 * time.Millisecond
+	start := time.Now()
+	_, err = NewStorageWithOptions(context.Background(), tmpDir, Options{LockWait: wait})
+	if elapsed := time.Since(start); elapsed < wait {
+		t.Errorf("open gave up after %v, want at least %v", elapsed, wait)
+	}
+	if !errors.Is(err, ErrInUse) || errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want ErrInUse", err)
+	}
+
+	// The lock is taken once it is released within the wait
+	time.AfterFunc(wait, func() { _ = s.Close() })
+	s2, err := NewStorageWithOptions(context.Background(), tmpDir, Options{LockWait: time.Minute})
+	if err != nil {
+		t.Fatalf("NewStorageWithOptions failed: %v", err)
+	}
+	if err := s2.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
+
+// TestNewStorage_StaleLockTakeover 测试锁文件记录的进程已不存在时接管锁
+func TestNewStorage_StaleLockTakeover(t *testing.T) {
+	if !canTryLock {
+		t.Skip("platform cannot check the lock without blocking")
+	}
+	tmpDir := SetupTempDir(t, "lock-stale-*")
+	defer CleanupTestData(t, tmpDir)
+
+	s, err := NewStorage(tmpDir)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// A process that exited recorded the lock, which is still held through
+	// a descriptor it left behind
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("failed to run a process: %v", err)
+	}
+	lockPath := filepath.Join(tmpDir, LockFile)
+	held, locked, err := tryLock(lockPath)
+	if err != nil || !locked {
+		t.Fatalf("tryLock = %v, %v", locked, err)
+	}
+	defer held.Close()
+	dead := strconv.Itoa(cmd.Process.Pid) + "\n\n" + time.Now().Format(time.RFC3339)
+	if _, err := held.WriteString(dead); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewStorageWithOptions(context.Background(), tmpDir, Options{LockWait: NoLockWait})
+	var inUse *InUseError
+	if !errors.As(err, &inUse) || inUse.PID != cmd.Process.Pid {
+		t.Fatalf("error = %v, want *InUseError of pid %d", err, cmd.Process.Pid)
+	}
+
+	s, err = NewStorageWithOptions(context.Background(), tmpDir, Options{LockWait: NoLockWait, StaleLockTakeover: true, LockMetadata: true})
+	if err != nil {
+		t.Fatalf("taking over the stale lock failed: %v", err)
+	}
+	defer s.Close()
+	if pid, _ := readLockHolder(lockPath); pid != os.Getpid() {
+		t.Errorf("lock file records pid %d, want %d", pid, os.Getpid())
+	}
+}
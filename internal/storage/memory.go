@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"context"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	dssync "github.com/ipfs/go-datastore/sync"
+)
+
+// blocksPrefix 是数据块在 datastore 中的命名空间。
+const blocksPrefix = "/blocks"
+
+// NewMemoryStorage 创建一个数据保存在内存中的存储实例。
+//
+// 内存存储没有目录、锁文件和哨兵文件：Paths 返回空列表，CheckSentinel
+// 总是成功，Destroy 和 ForceDestroy 与 Close 相同。数据只在实例的生命周期
+// 内存在。GetStorageUsage 返回所有数据块的字节数之和。
+//
+// 返回：
+//
+//	*Storage - 存储实例
+func NewMemoryStorage() *Storage {
+	return &Storage{
+		datastore: dssync.MutexWrap(ds.NewMapDatastore()),
+		memory:    true,
+	}
+}
+
+// memoryUsage 返回内存存储中所有数据块的字节数之和。
+func (s *Storage) memoryUsage(ctx context.Context) (uint64, error) {
+	results, err := s.datastore.Query(ctx, query.Query{Prefix: blocksPrefix, KeysOnly: true, ReturnsSizes: true})
+	if err != nil {
+		return 0, err
+	}
+	defer results.Close()
+
+	var total uint64
+	for r := range results.Next() {
+		if r.Error != nil {
+			return 0, r.Error
+		}
+		total += uint64(r.Size)
+	}
+	return total, ctx.Err()
+}
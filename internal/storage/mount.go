@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 	"sort"
 
@@ -13,6 +14,12 @@ type mountDatastoreConfig struct {
 	mounts []mountItem
 }
 
+// mountedDatastore 是 mount datastore 及其挂载点，用于按挂载点统计用量。
+type mountedDatastore struct {
+	*mount.Datastore
+	mounts []mount.Mount
+}
+
 // mountItem 表示单个挂载点。
 type mountItem struct {
 	ds     DatastoreConfig
@@ -98,12 +105,21 @@ func (cfg *mountDatastoreConfig) DiskSpec() DiskSpec {
 }
 
 // Create 使用此配置创建 mount datastore 实例。
+//
+// 某个挂载点创建失败时关闭已创建的挂载点；*CorruptionError 会被补上挂载点。
 func (cfg *mountDatastoreConfig) Create(path string) (Datastore, error) {
 	mounts := make([]mount.Mount, len(cfg.mounts))
 
 	for i, m := range cfg.mounts {
 		store, err := m.ds.Create(path)
 		if err != nil {
+			for _, created := range mounts[:i] {
+				_ = created.Datastore.Close()
+			}
+			var corrupt *CorruptionError
+			if errors.As(err, &corrupt) && corrupt.Mount == "" {
+				corrupt.Mount = m.prefix.String()
+			}
 			return nil, err
 		}
 
@@ -111,5 +127,5 @@ func (cfg *mountDatastoreConfig) Create(path string) (Datastore, error) {
 		mounts[i].Prefix = m.prefix
 	}
 
-	return mount.New(mounts), nil
+	return &mountedDatastore{Datastore: mount.New(mounts), mounts: mounts}, nil
 }
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/tragoedia0722/repository/internal/artifact"
+)
+
+// OverrideMountPaths 返回 spec 的副本，其中指定挂载点的存储路径被替换。
+//
+// overrides 的键是挂载点（如 "/blocks" 或 "/"），值是新的存储路径，
+// 通常是其他磁盘上的绝对路径；相对路径仍相对于仓库根目录解析。
+// 路径写入挂载点最内层的 datastore（跳过 measure 等包装）。
+// spec 中不存在的挂载点会被忽略，原 spec 不会被修改。
+//
+// 参数：
+//
+//	spec - 原始存储配置
+//	overrides - 挂载点到存储路径的映射
+//
+// 返回：
+//
+//	DiskSpec - 替换路径后的存储配置
+//
+// 示例：
+//
+//	spec := OverrideMountPaths(DefaultDiskSpec(), map[string]string{
+//	    "/blocks": "/mnt/hdd/blocks",
+//	})
+func OverrideMountPaths(spec DiskSpec, overrides map[string]string) DiskSpec {
+	out := copySpec(spec)
+	for _, m := range specMounts(out) {
+		path, ok := overrides[mountpointOf(m)]
+		if !ok {
+			continue
+		}
+		leaf := leafSpec(m)
+		if _, hasPath := leaf["path"]; hasPath {
+			leaf["path"] = path
+		}
+	}
+	return out
+}
+
+// copySpec 深拷贝 spec。
+func copySpec(spec DiskSpec) DiskSpec {
+	var out DiskSpec
+	if err := json.Unmarshal(spec.Bytes(), &out); err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// parseSpec 解析磁盘上的存储配置。
+func parseSpec(data string) (DiskSpec, error) {
+	var spec DiskSpec
+	if err := json.Unmarshal([]byte(data), &spec); err != nil {
+		return nil, err
+	}
+	if spec == nil {
+		return nil, fmt.Errorf("empty datastore spec")
+	}
+	return spec, nil
+}
+
+// specMounts 返回 mount 类型配置中的挂载点列表，其他类型返回 nil。
+func specMounts(spec DiskSpec) []map[string]interface{} {
+	items, _ := spec["mounts"].([]interface{})
+	mounts := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			mounts = append(mounts, m)
+		}
+	}
+	return mounts
+}
+
+// mountpointOf 返回挂载点配置中的挂载路径。
+func mountpointOf(m map[string]interface{}) string {
+	mountpoint, _ := m["mountpoint"].(string)
+	return mountpoint
+}
+
+// leafSpec 沿 "child" 字段找到最内层的 datastore 配置。
+func leafSpec(m map[string]interface{}) map[string]interface{} {
+	for {
+		child, ok := m["child"].(map[string]interface{})
+		if !ok {
+			return m
+		}
+		m = child
+	}
+}
+
+// logicalSpec 返回去掉所有存储路径后的配置，用于比较逻辑布局。
+//
+// 两个只在存储路径上不同的配置具有相同的逻辑布局。
+func logicalSpec(spec DiskSpec) string {
+	out := copySpec(spec)
+	stripPaths(out)
+	return out.String()
+}
+
+// stripPaths 递归删除配置中的 "path" 字段。
+func stripPaths(v interface{}) {
+	switch node := v.(type) {
+	case DiskSpec:
+		stripPaths(map[string]interface{}(node))
+	case map[string]interface{}:
+		delete(node, "path")
+		for _, child := range node {
+			stripPaths(child)
+		}
+	case []interface{}:
+		for _, child := range node {
+			stripPaths(child)
+		}
+	}
+}
+
+// externalMountPaths 返回 spec 中位于仓库目录之外的存储路径，按字典序排列。
+func externalMountPaths(root string, spec DiskSpec) []string {
+	var paths []string
+	for _, m := range specMounts(spec) {
+		path, ok := leafSpec(m)["path"].(string)
+		if !ok || !filepath.IsAbs(path) {
+			continue
+		}
+		if rel, err := filepath.Rel(root, path); err == nil && filepath.IsLocal(rel) {
+			continue
+		}
+		paths = append(paths, filepath.Clean(path))
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// checkMountOverrides 检查 overrides 中的挂载点都存在于 spec 中。
+func checkMountOverrides(spec DiskSpec, overrides map[string]string) error {
+	known := make(map[string]bool)
+	for _, m := range specMounts(spec) {
+		known[mountpointOf(m)] = true
+	}
+	for mountpoint := range overrides {
+		if !known[mountpoint] {
+			return &ConfigError{
+				Field: "MountPaths",
+				Value: mountpoint,
+				Err:   fmt.Errorf("no such mountpoint in datastore spec"),
+			}
+		}
+	}
+	return nil
+}
+
+// writeSpec 原子地写入存储配置。
+func writeSpec(path string, spec DiskSpec) error {
+	specPath := DatastoreSpecPath(path)
+	tmp := specPath + artifact.SpecTempSuffix
+	if err := os.WriteFile(tmp, spec.Bytes(), 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, specPath); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+func TestOverrideMountPaths(t *testing.T) {
+	spec := DefaultDiskSpec()
+	before := spec.String()
+
+	out := OverrideMountPaths(spec, map[string]string{
+		"/blocks":  "/mnt/hdd/blocks",
+		"/unknown": "/ignored",
+	})
+
+	if spec.String() != before {
+		t.Error("OverrideMountPaths modified its input")
+	}
+
+	paths := make(map[string]string)
+	for _, m := range specMounts(out) {
+		paths[mountpointOf(m)], _ = leafSpec(m)["path"].(string)
+	}
+	if paths["/blocks"] != "/mnt/hdd/blocks" {
+		t.Errorf("/blocks path = %q", paths["/blocks"])
+	}
+	if paths["/"] != "datastore" {
+		t.Errorf("/ path = %q, want unchanged", paths["/"])
+	}
+	if logicalSpec(out) != logicalSpec(spec) {
+		t.Error("overriding paths changed the logical layout")
+	}
+}
+
+// dirSize returns the total size of regular files below dir.
+func dirSize(t *testing.T, dir string) int64 {
+	t.Helper()
+
+	var total int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			total += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to walk %s: %v", dir, err)
+	}
+	return total
+}
+
+func TestNewStorageWithOptions_MountPaths(t *testing.T) {
+	ctx := context.Background()
+	repoDir := SetupTempDir(t, "storage-relocate-*")
+	blocksDir := filepath.Join(SetupTempDir(t, "storage-relocate-hdd-*"), "blocks")
+	defer os.RemoveAll(repoDir)
+	defer os.RemoveAll(filepath.Dir(blocksDir))
+
+	opts := Options{MountPaths: map[string]string{"/blocks": blocksDir}}
+	s, err := NewStorageWithOptions(ctx, repoDir, opts)
+	if err != nil {
+		t.Fatalf("NewStorageWithOptions failed: %v", err)
+	}
+
+	block := bytes.Repeat([]byte("b"), 256*1024)
+	meta := bytes.Repeat([]byte("m"), 64*1024)
+	if err := s.Datastore().Put(ctx, ds.NewKey("/blocks/CIQRELOCATEDBLOCK"), block); err != nil {
+		t.Fatalf("Put block failed: %v", err)
+	}
+	if err := s.Datastore().Put(ctx, ds.NewKey("/meta"), meta); err != nil {
+		t.Fatalf("Put metadata failed: %v", err)
+	}
+	if err := s.Datastore().Sync(ctx, ds.NewKey("/")); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	// Blocks land on the relocated path, not under the repository
+	if size := dirSize(t, blocksDir); size < int64(len(block)) {
+		t.Errorf("relocated blocks directory holds %d bytes, want at least %d", size, len(block))
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, "blocks")); !os.IsNotExist(err) {
+		t.Errorf("expected no blocks directory in the repository, got %v", err)
+	}
+
+	usage, err := s.GetStorageUsage(ctx)
+	if err != nil {
+		t.Fatalf("GetStorageUsage failed: %v", err)
+	}
+	if want := uint64(len(block) + len(meta)); usage < want {
+		t.Errorf("usage = %d, want at least %d covering both locations", usage, want)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// The overridden path is persisted, so a plain open finds the blocks
+	spec, err := os.ReadFile(DatastoreSpecPath(repoDir))
+	if err != nil {
+		t.Fatalf("failed to read spec: %v", err)
+	}
+	if !strings.Contains(string(spec), blocksDir) {
+		t.Errorf("spec does not record the relocated path: %s", spec)
+	}
+
+	s, err = NewStorage(repoDir)
+	if err != nil {
+		t.Fatalf("reopen without overrides failed: %v", err)
+	}
+	got, err := s.Datastore().Get(ctx, ds.NewKey("/blocks/CIQRELOCATEDBLOCK"))
+	if err != nil || !bytes.Equal(got, block) {
+		t.Errorf("Get after reopen = %d bytes, %v", len(got), err)
+	}
+
+	// Destroy removes the relocated directory as well
+	if err := s.Destroy(); err != nil {
+		t.Fatalf("Destroy failed: %v", err)
+	}
+	if _, err := os.Stat(blocksDir); !os.IsNotExist(err) {
+		t.Errorf("expected relocated blocks to be removed, got %v", err)
+	}
+}
+
+func TestNewStorageWithOptions_UnknownMountpoint(t *testing.T) {
+	tmpDir := SetupTempDir(t, "storage-relocate-bad-*")
+	defer os.RemoveAll(tmpDir)
+
+	_, err := NewStorageWithOptions(context.Background(), tmpDir, Options{
+		MountPaths: map[string]string{"/nope": filepath.Join(tmpDir, "x")},
+	})
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("expected ConfigError, got %v", err)
+	}
+}
+
+func TestNewStorage_SpecWithRelocatedPathAccepted(t *testing.T) {
+	tmpDir := SetupTempDir(t, "storage-relocate-spec-*")
+	defer os.RemoveAll(tmpDir)
+
+	blocksDir := filepath.Join(tmpDir, "elsewhere", "blocks")
+	expected, err := AnyDatastoreConfig(DefaultDiskSpec())
+	if err != nil {
+		t.Fatalf("AnyDatastoreConfig failed: %v", err)
+	}
+	spec := OverrideMountPaths(expected.DiskSpec(), map[string]string{"/blocks": blocksDir})
+	if err := os.WriteFile(DatastoreSpecPath(tmpDir), spec.Bytes(), 0o600); err != nil {
+		t.Fatalf("failed to write spec: %v", err)
+	}
+
+	s, err := NewStorage(tmpDir)
+	if err != nil {
+		t.Fatalf("NewStorage with a relocated spec failed: %v", err)
+	}
+	defer s.Close()
+
+	if _, err := os.Stat(blocksDir); err != nil {
+		t.Errorf("expected blocks at the recorded path: %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// Options 配置存储实例。
+type Options struct {
+	// RedactPaths 为 true 时，返回的错误信息中的存储根路径会被替换为 "<repo>"。
+	// 原始错误仍可通过 errors.As 获取。
+	RedactPaths bool
+
+	// LockMetadata 为 true 时，在锁文件中写入当前进程的 PID 和获得锁的时间。
+	// 默认锁文件为空，避免在共享存储上泄露主机信息。
+	LockMetadata bool
+
+	// LockHostname 为 true 时，在锁文件中额外写入主机名。仅在 LockMetadata 为 true 时生效。
+	LockHostname bool
+
+	// LockWait 是锁被其他实例持有时等待其释放的最长时间，0（默认）表示一直
+	// 等待到 ctx 结束，NoLockWait 表示不等待。不再等待时返回包装 *InUseError
+	// 的 *LockError，持有者启用了 LockMetadata 时错误信息包含其 PID 和获得
+	// 锁的时间。在不支持不阻塞加锁的平台上总是等待。
+	LockWait time.Duration
+
+	// StaleLockTakeover 为 true 时，锁被持有但锁文件记录的进程在本机已不存在
+	// （例如持有者退出后锁被它的子进程继承）时删除锁文件并接管锁。
+	// 只有持有者启用了 LockMetadata 的锁才能被接管；主机名为空的记录被当作本机，
+	// 共享存储上的多台主机应同时启用 LockHostname。
+	StaleLockTakeover bool
+
+	// MountPaths 覆盖挂载点的存储路径，键为挂载点（"/blocks" 或 "/"），
+	// 值通常是其他磁盘上的绝对路径，用于在不使用符号链接的情况下把数据块
+	// 和元数据放到不同磁盘。覆盖后的路径会写入 datastore_spec，之后不带
+	// 覆盖打开时继续使用；已有数据不会被移动。
+	MountPaths map[string]string
+
+	// AutoRepair 为 true 时，打开过程中发现 LevelDB 损坏会自动调用与
+	// RepairDatastore 相同的修复，然后继续打开；修复结果可以通过
+	// Storage.RepairReport 获取。默认返回 *CorruptionError，不修改数据。
+	AutoRepair bool
+}
+
+// NewStorageWithOptions 使用指定配置创建或打开一个存储实例。
+//
+// 其他实例持有存储的锁时等待其释放，ctx 结束时返回包装 ctx.Err() 的错误。
+// 获取锁之后打开 datastore（例如 leveldb 回放日志）不可中断。
+//
+// 参数：
+//
+//	ctx - 用于取消操作或设置超时的上下文
+//	path - 存储目录路径
+//	opts - 存储配置
+//
+// 返回：
+//
+//	*Storage - 存储实例
+//	error - 如果创建或打开失败或上下文取消，返回错误
+func NewStorageWithOptions(ctx context.Context, path string, opts Options) (*Storage, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	root := ""
+	if opts.RedactPaths {
+		root = redactionRoot(path)
+	}
+
+	if err := checkMountOverrides(DefaultDiskSpec(), opts.MountPaths); err != nil {
+		return nil, redact(err, root)
+	}
+
+	if err := checkVersion(path); err != nil {
+		return nil, redact(err, root)
+	}
+
+	if err := initSpec(path, OverrideMountPaths(DefaultDiskSpec(), opts.MountPaths)); err != nil {
+		return nil, redact(err, root)
+	}
+
+	s, err := openWithOptions(ctx, path, opts)
+	if err != nil {
+		return nil, redact(err, root)
+	}
+	s.redactRoot = root
+
+	if err := ensureVersionFile(path); err != nil {
+		_ = s.Close()
+		return nil, redact(err, root)
+	}
+	return s, nil
+}
+
+// RedactError 在启用 RedactPaths 时包装 err，隐藏其中的存储根路径。
+//
+// 用于在公共 API 边界处理来自存储或 datastore 的错误。
+// 未启用时原样返回 err。
+//
+// 参数：
+//
+//	err - 原始错误
+//
+// 返回：
+//
+//	error - 脱敏后的错误，err 为 nil 时返回 nil
+func (s *Storage) RedactError(err error) error {
+	return redact(err, s.redactRoot)
+}
+
+// redact 用 RedactedError 包装 err。root 为空时原样返回。
+func redact(err error, root string) error {
+	if err == nil || root == "" {
+		return err
+	}
+
+	var redacted *RedactedError
+	if errors.As(err, &redacted) && redacted.Root == root {
+		return err
+	}
+	return &RedactedError{Root: root, Err: err}
+}
+
+// redactionRoot 返回错误信息中需要隐藏的存储根路径（绝对路径）。
+//
+// 只替换绝对路径，避免相对路径（如 "data"）误伤错误信息中的其他文本。
+func redactionRoot(path string) string {
+	expPath, err := homedir.Expand(filepath.Clean(path))
+	if err != nil {
+		return ""
+	}
+
+	abs, err := filepath.Abs(expPath)
+	if err != nil || abs == string(filepath.Separator) {
+		return ""
+	}
+	return abs
+}
+
+// Redact 包装 err，在错误信息中隐藏存储根路径 path。
+//
+// 用于存储实例创建之前（例如创建仓库目录时）产生的错误。
+//
+// 参数：
+//
+//	err - 原始错误
+//	path - 存储根路径
+//
+// 返回：
+//
+//	error - 脱敏后的错误，err 为 nil 时返回 nil
+func Redact(err error, path string) error {
+	return redact(err, redactionRoot(path))
+}
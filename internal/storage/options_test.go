@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNewStorageWithOptions_RedactPaths(t *testing.T) {
+	tmpDir := SetupTempDir(t, "storage-redact-*")
+	defer os.RemoveAll(tmpDir)
+
+	// 存储路径是普通文件，写入配置时会返回包含完整路径的错误
+	path := filepath.Join(tmpDir, "not-a-dir")
+	CreateTestFile(t, path, []byte("x"))
+
+	_, err := NewStorageWithOptions(context.Background(), path, Options{RedactPaths: true})
+	if err == nil {
+		t.Fatal("expected error for a file path")
+	}
+
+	if strings.Contains(err.Error(), tmpDir) {
+		t.Errorf("error message leaks the path: %v", err)
+	}
+	if !strings.Contains(err.Error(), RedactedPathPlaceholder) {
+		t.Errorf("error message should contain %s: %v", RedactedPathPlaceholder, err)
+	}
+
+	var pathErr *os.PathError
+	if !errors.As(err, &pathErr) {
+		t.Fatalf("expected *os.PathError inside %v", err)
+	}
+	if !strings.HasPrefix(pathErr.Path, path) {
+		t.Errorf("unwrapped error should keep the full path, got %q", pathErr.Path)
+	}
+
+	// 未启用时错误信息保持原样
+	_, err = NewStorageWithOptions(context.Background(), path, Options{})
+	if err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("expected full path without redaction, got %v", err)
+	}
+}
+
+func TestStorage_RedactError(t *testing.T) {
+	s, tmpDir := SetupStorage(t)
+	defer CleanupTestData(t, tmpDir, s)
+
+	inner := &StorageError{Operation: "read", Path: filepath.Join(tmpDir, "blocks"), Err: errors.New("boom")}
+	if got := s.RedactError(inner); got != inner {
+		t.Errorf("expected error unchanged without RedactPaths, got %v", got)
+	}
+
+	redacting, err := NewStorageWithOptions(context.Background(), SetupTempDir(t, "storage-redact-*"), Options{RedactPaths: true})
+	if err != nil {
+		t.Fatalf("NewStorageWithOptions failed: %v", err)
+	}
+	defer redacting.Destroy()
+
+	inner = &StorageError{Operation: "read", Path: filepath.Join(redacting.path, "blocks"), Err: errors.New("boom")}
+	got := redacting.RedactError(inner)
+	if want := "read failed at " + filepath.Join(RedactedPathPlaceholder, "blocks") + ": boom"; got.Error() != want {
+		t.Errorf("Error() = %q, want %q", got.Error(), want)
+	}
+
+	var storageErr *StorageError
+	if !errors.As(got, &storageErr) || storageErr.Path != inner.Path {
+		t.Errorf("expected original StorageError via errors.As, got %v", got)
+	}
+
+	if redacting.RedactError(nil) != nil {
+		t.Error("RedactError(nil) should return nil")
+	}
+}
+
+func TestNewStorageWithOptions_LockMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		wantPID  bool
+		wantHost bool
+	}{
+		{name: "default writes nothing", opts: Options{}},
+		{name: "pid only", opts: Options{LockMetadata: true}, wantPID: true},
+		{name: "pid and hostname", opts: Options{LockMetadata: true, LockHostname: true}, wantPID: true, wantHost: true},
+		{name: "hostname requires metadata", opts: Options{LockHostname: true}},
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skipf("hostname unavailable: %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := SetupTempDir(t, "storage-lock-meta-*")
+			defer os.RemoveAll(tmpDir)
+
+			s, err := NewStorageWithOptions(context.Background(), tmpDir, tt.opts)
+			if err != nil {
+				t.Fatalf("NewStorageWithOptions failed: %v", err)
+			}
+			defer s.Close()
+
+			data, err := os.ReadFile(filepath.Join(tmpDir, LockFile))
+			if err != nil {
+				t.Fatalf("failed to read lock file: %v", err)
+			}
+			content := string(data)
+
+			if !tt.wantPID && !tt.wantHost && content != "" {
+				t.Errorf("expected empty lock file, got %q", content)
+			}
+			if tt.wantPID && !strings.HasPrefix(content, strconv.Itoa(os.Getpid())) {
+				t.Errorf("expected lock file to start with PID, got %q", content)
+			}
+			if got := strings.Contains(content, hostname); got != tt.wantHost {
+				t.Errorf("hostname in lock file = %v, want %v (%q)", got, tt.wantHost, content)
+			}
+		})
+	}
+}
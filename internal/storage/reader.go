@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	ds "github.com/ipfs/go-datastore"
+	flatfs "github.com/ipfs/go-ds-flatfs"
+)
+
+// flatfsDataExtension 是 FlatFS 中每个值所在文件的扩展名。
+const flatfsDataExtension = ".data"
+
+// fileMount 是一个 FlatFS 挂载点，其中每个值保存在单独的文件中。
+type fileMount struct {
+	prefix ds.Key
+	dir    string
+	shard  flatfs.ShardFunc
+}
+
+// fileMounts 返回 spec 中所有 FlatFS 挂载点，存储路径相对于 root 解析。
+func fileMounts(root string, spec DiskSpec) ([]fileMount, error) {
+	var mounts []fileMount
+	for _, m := range specMounts(spec) {
+		leaf := leafSpec(m)
+		if leaf["type"] != "flatfs" {
+			continue
+		}
+		dir, _ := leaf["path"].(string)
+		shardFunc, _ := leaf["shardFunc"].(string)
+		shard, err := flatfs.ParseShardFunc(shardFunc)
+		if err != nil {
+			return nil, err
+		}
+		mounts = append(mounts, fileMount{
+			prefix: ds.NewKey(mountpointOf(m)),
+			dir:    resolvePath(root, dir),
+			shard:  shard.Func(),
+		})
+	}
+	return mounts, nil
+}
+
+// OpenReader 返回 key 对应的值的读取器及其长度。
+//
+// 位于 FlatFS 挂载点中的值直接从其文件流式读取，不会整体读入内存；
+// 其他值（例如内存存储或 LevelDB 中的值）通过 Datastore().Get 读取后
+// 在内存中返回。值不存在时返回 ds.ErrNotFound。调用者必须关闭读取器。
+//
+// 参数：
+//
+//	ctx - 用于取消操作的上下文
+//	key - 值的 datastore 键
+//
+// 返回：
+//
+//	io.ReadCloser - 值的读取器
+//	int64 - 值的长度（字节）
+//	error - 如果值不存在或读取失败，返回错误
+func (s *Storage) OpenReader(ctx context.Context, key ds.Key) (io.ReadCloser, int64, error) {
+	for _, m := range s.files {
+		if !m.prefix.IsAncestorOf(key) {
+			continue
+		}
+		// 与 FlatFS 的 encode 相同：挂载点下的键去掉开头的 "/" 后作为文件名
+		name := key.String()[len(m.prefix.String())+1:]
+		f, err := os.Open(filepath.Join(m.dir, m.shard(name), name+flatfsDataExtension))
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, 0, ds.ErrNotFound
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			_ = f.Close()
+			return nil, 0, err
+		}
+		return f, info.Size(), nil
+	}
+
+	data, err := s.datastore.Get(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), int64(len(data)), nil
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+func TestStorage_OpenReader(t *testing.T) {
+	ctx := context.Background()
+	s, _ := SetupStorage(t)
+
+	// A block key as the blockstore writes it, stored by FlatFS
+	key := ds.NewKey("/blocks/CIQA4XCGRCRTCCHV7XSGAZPZJOAOHLPOI6IQR3H6YQ")
+	value := []byte("streamed from its file")
+	if err := s.Datastore().Put(ctx, key, value); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	rc, size, err := s.OpenReader(ctx, key)
+	if err != nil {
+		t.Fatalf("OpenReader failed: %v", err)
+	}
+	defer rc.Close()
+	if _, ok := rc.(*os.File); !ok {
+		t.Errorf("OpenReader returned %T for a FlatFS value, want the file itself", rc)
+	}
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if string(data) != string(value) || size != int64(len(value)) {
+		t.Errorf("read %q (size %d), want %q (size %d)", data, size, value, len(value))
+	}
+
+	if _, _, err := s.OpenReader(ctx, ds.NewKey("/blocks/CIQMISSING")); !errors.Is(err, ds.ErrNotFound) {
+		t.Errorf("OpenReader of a missing value = %v, want ds.ErrNotFound", err)
+	}
+
+	// Values outside FlatFS are read through the datastore
+	meta := ds.NewKey("/meta/value")
+	if err := s.Datastore().Put(ctx, meta, value); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	rc, size, err = s.OpenReader(ctx, meta)
+	if err != nil {
+		t.Fatalf("OpenReader failed: %v", err)
+	}
+	defer rc.Close()
+	if data, _ := io.ReadAll(rc); string(data) != string(value) || size != int64(len(value)) {
+		t.Errorf("read %q (size %d), want %q", data, size, value)
+	}
+}
+
+func TestMemoryStorage_OpenReader(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStorage()
+	defer s.Close()
+
+	key := ds.NewKey("/blocks/CIQMEMORY")
+	if err := s.Datastore().Put(ctx, key, []byte("in memory")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	rc, size, err := s.OpenReader(ctx, key)
+	if err != nil {
+		t.Fatalf("OpenReader failed: %v", err)
+	}
+	defer rc.Close()
+	if data, _ := io.ReadAll(rc); string(data) != "in memory" || size != 9 {
+		t.Errorf("read %q (size %d), want %q", data, size, "in memory")
+	}
+}
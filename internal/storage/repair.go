@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	levelds "github.com/ipfs/go-ds-leveldb"
+	"github.com/syndtr/goleveldb/leveldb"
+	lerrors "github.com/syndtr/goleveldb/leveldb/errors"
+	ldbopts "github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// CorruptionError 表示某个挂载点的 LevelDB 已损坏，无法打开。
+//
+// 通常由异常关机导致，例如 MANIFEST 文件被截断。可以调用 RepairDatastore
+// 修复，或者在打开时设置 Options.AutoRepair。
+type CorruptionError struct {
+	// Mount 是损坏的 datastore 所在的挂载点，例如 "/"
+	Mount string
+	// Path 是损坏的 LevelDB 目录
+	Path string
+	// Err 是 LevelDB 返回的底层错误
+	Err error
+}
+
+// Error 实现 error 接口。
+func (e *CorruptionError) Error() string {
+	return fmt.Sprintf("datastore at mount %q is corrupted (%s): %v; run storage.RepairDatastore to recover it",
+		e.Mount, e.Path, e.Err)
+}
+
+// Unwrap 返回底层错误，支持 errors.Is 和 errors.As。
+func (e *CorruptionError) Unwrap() error {
+	return e.Err
+}
+
+// RepairReport 描述一次 LevelDB 修复的结果。
+//
+// 修复按表文件重建数据库：损坏的数据块被跳过，日志中尚未写入表文件的
+// 修改会被回放。损坏的数据库无法枚举，因此丢失的键数无法得知，
+// 只能从丢弃的表文件数判断损失的范围。
+type RepairReport struct {
+	Mount           string // 修复的挂载点
+	Path            string // 修复的 LevelDB 目录
+	TablesFound     int    // 修复前目录中的表文件数
+	TablesRecovered int    // 修复后数据库引用的表文件数，包含由日志重建的表
+	KeysRecovered   int    // 修复后可以读取的键数
+}
+
+// TablesDropped 返回修复前存在、修复后不再被引用的表文件数的估计值。
+func (r RepairReport) TablesDropped() int {
+	return max(r.TablesFound-r.TablesRecovered, 0)
+}
+
+// RepairDatastore 修复 path 处存储中挂载点 mount 的 LevelDB。
+//
+// 修复前获取存储的锁，存储被其他实例打开时返回 *InUseError，不做任何修改。
+// 只有 LevelDB 类型的挂载点（默认配置中的 "/"）可以修复。
+// 修复可能丢失损坏部分中的键，但保留其余的元数据，例如清单和固定记录。
+//
+// 参数：
+//
+//	path - 存储目录路径
+//	mount - 要修复的挂载点，例如 "/"
+//
+// 返回：
+//
+//	RepairReport - 修复结果
+//	error - 如果无法修复，返回错误
+func RepairDatastore(path string, mount string) (RepairReport, error) {
+	s, err := newStorage(path)
+	if err != nil {
+		return RepairReport{}, err
+	}
+
+	lockPath := filepath.Join(s.path, LockFile)
+	lock, err := acquireLock(s.path, lockPath)
+	if err != nil {
+		return RepairReport{}, err
+	}
+	defer func() {
+		_ = lock.Close()
+		_ = os.Remove(lockPath)
+	}()
+
+	dir, err := s.levelDBPath(mount)
+	if err != nil {
+		return RepairReport{}, err
+	}
+	return repairLevelDB(mount, dir)
+}
+
+// levelDBPath 返回磁盘配置中挂载点 mount 的 LevelDB 目录。
+func (s *Storage) levelDBPath(mount string) (string, error) {
+	data, err := s.readSpec()
+	if err != nil {
+		return "", &StorageError{
+			Operation: "read config",
+			Path:      s.path,
+			Err:       err,
+		}
+	}
+	spec, err := parseSpec(data)
+	if err != nil {
+		return "", &ConfigError{Field: "datastore_spec", Value: data, Err: err}
+	}
+
+	for _, m := range specMounts(spec) {
+		if mountpointOf(m) != mount {
+			continue
+		}
+		leaf := leafSpec(m)
+		dir, ok := leaf["path"].(string)
+		if leaf["type"] != "levelds" || !ok {
+			return "", &ConfigError{
+				Field: "mount",
+				Value: mount,
+				Err:   fmt.Errorf("mountpoint is not a leveldb datastore"),
+			}
+		}
+		return resolvePath(s.path, dir), nil
+	}
+	return "", &ConfigError{
+		Field: "mount",
+		Value: mount,
+		Err:   fmt.Errorf("no such mountpoint in datastore spec"),
+	}
+}
+
+// repairLevelDB 用 LevelDB 的恢复功能重建 dir 处的数据库。
+func repairLevelDB(mount, dir string) (RepairReport, error) {
+	report := RepairReport{Mount: mount, Path: dir}
+
+	tables, err := countTables(dir)
+	if err != nil {
+		return report, &StorageError{Operation: "repair datastore", Path: dir, Err: err}
+	}
+	report.TablesFound = tables
+
+	db, err := leveldb.RecoverFile(dir, nil)
+	if err != nil {
+		return report, &StorageError{Operation: "repair datastore", Path: dir, Err: err}
+	}
+
+	if sstables, err := db.GetProperty("leveldb.sstables"); err == nil {
+		for _, line := range strings.Split(sstables, "\n") {
+			if line != "" && !strings.HasPrefix(line, "---") {
+				report.TablesRecovered++
+			}
+		}
+	}
+
+	it := db.NewIterator(nil, nil)
+	for it.Next() {
+		report.KeysRecovered++
+	}
+	it.Release()
+	err = it.Error()
+
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return report, &StorageError{Operation: "repair datastore", Path: dir, Err: err}
+	}
+	return report, nil
+}
+
+// countTables 返回 dir 中的 LevelDB 表文件数。
+func countTables(dir string) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, entry := range entries {
+		if ext := filepath.Ext(entry.Name()); ext == ".ldb" || ext == ".sst" {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// checkLevelDB 以只读方式打开 dir 处已有的 LevelDB，检查它是否损坏。
+//
+// levelds.NewDatastore 遇到损坏时会静默地恢复，丢失的数据不会被报告；
+// 因此先在这里检查，损坏时返回 *CorruptionError，由调用者决定是否修复。
+// 其他打开错误留给 levelds.NewDatastore 报告。
+func checkLevelDB(dir string, opts *levelds.Options) error {
+	if !FileExists(filepath.Join(dir, "CURRENT")) {
+		return nil
+	}
+
+	o := ldbopts.Options(*opts)
+	o.ReadOnly = true
+	db, err := leveldb.OpenFile(dir, &o)
+	if err != nil {
+		if lerrors.IsCorrupted(err) {
+			return &CorruptionError{Path: dir, Err: err}
+		}
+		return nil
+	}
+	return db.Close()
+}
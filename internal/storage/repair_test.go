@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+const repairTestKeys = 200
+
+// populateAndCorrupt stores keys in a new storage at dir, closes it and
+// truncates the MANIFEST of its leveldb, as an unclean shutdown might.
+func populateAndCorrupt(t *testing.T, dir string) {
+	t.Helper()
+	ctx := context.Background()
+
+	s, err := NewStorage(dir)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	for i := 0; i < repairTestKeys; i++ {
+		key := ds.NewKey(fmt.Sprintf("/meta/%03d", i))
+		if err := s.Datastore().Put(ctx, key, []byte(fmt.Sprintf("value %d", i))); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	corruptManifest(t, dir)
+}
+
+// corruptManifest truncates every MANIFEST file of the storage's leveldb.
+func corruptManifest(t *testing.T, dir string) {
+	t.Helper()
+	manifests, err := filepath.Glob(filepath.Join(dir, "datastore", "MANIFEST-*"))
+	if err != nil || len(manifests) == 0 {
+		t.Fatalf("no leveldb MANIFEST found: %v", err)
+	}
+	for _, m := range manifests {
+		if err := os.Truncate(m, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readableKeys returns how many of the populated keys s can read, failing
+// the test if a key reads back a wrong value.
+func readableKeys(t *testing.T, s *Storage) int {
+	t.Helper()
+	n := 0
+	for i := 0; i < repairTestKeys; i++ {
+		value, err := s.Datastore().Get(context.Background(), ds.NewKey(fmt.Sprintf("/meta/%03d", i)))
+		if errors.Is(err, ds.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if want := fmt.Sprintf("value %d", i); string(value) != want {
+			t.Errorf("key %d = %q, want %q", i, value, want)
+		}
+		n++
+	}
+	return n
+}
+
+func TestStorage_CorruptionDetected(t *testing.T) {
+	dir := t.TempDir()
+	populateAndCorrupt(t, dir)
+
+	_, err := NewStorage(dir)
+	var corrupt *CorruptionError
+	if !errors.As(err, &corrupt) {
+		t.Fatalf("NewStorage returned %v, want a *CorruptionError", err)
+	}
+	if corrupt.Mount != "/" {
+		t.Errorf("corrupted mount = %q, want \"/\"", corrupt.Mount)
+	}
+	if corrupt.Path != filepath.Join(dir, "datastore") {
+		t.Errorf("corrupted path = %q", corrupt.Path)
+	}
+
+	// Detection leaves the store as it was, so a failed open can be retried
+	if _, err := NewStorage(dir); !errors.As(err, &corrupt) {
+		t.Errorf("second NewStorage returned %v, want a *CorruptionError", err)
+	}
+}
+
+func TestRepairDatastore(t *testing.T) {
+	dir := t.TempDir()
+	populateAndCorrupt(t, dir)
+
+	report, err := RepairDatastore(dir, "/")
+	if err != nil {
+		t.Fatalf("RepairDatastore failed: %v", err)
+	}
+	if report.Mount != "/" || report.Path != filepath.Join(dir, "datastore") {
+		t.Errorf("report names mount %q at %q", report.Mount, report.Path)
+	}
+	if report.KeysRecovered == 0 {
+		t.Error("repair recovered no keys")
+	}
+
+	s, err := NewStorage(dir)
+	if err != nil {
+		t.Fatalf("NewStorage after repair failed: %v", err)
+	}
+	defer s.Close()
+
+	// Some keys may be lost, but every surviving key must read back intact
+	readable := readableKeys(t, s)
+	if readable == 0 {
+		t.Error("no key is readable after repair")
+	}
+	if readable > report.KeysRecovered {
+		t.Errorf("%d keys readable, but the report recovered %d", readable, report.KeysRecovered)
+	}
+	if s.RepairReport() != nil {
+		t.Errorf("RepairReport() = %+v without AutoRepair", s.RepairReport())
+	}
+}
+
+func TestRepairDatastore_Errors(t *testing.T) {
+	s, dir := SetupStorage(t)
+
+	if _, err := RepairDatastore(dir, "/"); !errors.Is(err, ErrInUse) {
+		t.Errorf("RepairDatastore on an open storage returned %v, want ErrInUse", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mount := range []string{"/blocks", "/nope"} {
+		var cfgErr *ConfigError
+		if _, err := RepairDatastore(dir, mount); !errors.As(err, &cfgErr) {
+			t.Errorf("RepairDatastore(%q) returned %v, want a *ConfigError", mount, err)
+		}
+	}
+}
+
+func TestNewStorageWithOptions_AutoRepair(t *testing.T) {
+	dir := t.TempDir()
+	populateAndCorrupt(t, dir)
+
+	s, err := NewStorageWithOptions(context.Background(), dir, Options{AutoRepair: true})
+	if err != nil {
+		t.Fatalf("NewStorageWithOptions failed: %v", err)
+	}
+	defer s.Close()
+
+	report := s.RepairReport()
+	if report == nil {
+		t.Fatal("RepairReport() = nil after repairing a corrupted store")
+	}
+	if report.Mount != "/" || report.KeysRecovered == 0 {
+		t.Errorf("report = %+v", report)
+	}
+	if readableKeys(t, s) == 0 {
+		t.Error("no key is readable after repair")
+	}
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SentinelFile 是仓库目录中用于检测目录被删除或重新挂载的哨兵文件名。
+const SentinelFile = ".storage.sentinel"
+
+// ErrRepositoryMoved 表示仓库目录已被删除、替换或重新挂载，
+// 继续写入会落到错误的文件系统上。
+var ErrRepositoryMoved = errors.New("repository directory was removed or remounted")
+
+// SentinelFilePath 返回哨兵文件的完整路径。
+func SentinelFilePath(repoPath string) string {
+	return filepath.Join(repoPath, SentinelFile)
+}
+
+// writeSentinel 向仓库目录写入带随机令牌的哨兵文件并返回令牌。
+//
+// 每次打开存储都会写入新的令牌，因此目录在打开后被替换时令牌一定不同。
+func writeSentinel(path string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	if err := os.WriteFile(SentinelFilePath(path), []byte(token), 0o600); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// CheckSentinel 检查仓库目录是否仍是打开存储时的目录。
+//
+// 重新读取打开时写入的哨兵文件，文件不存在或令牌不同（目录被删除、
+// 替换或重新挂载）时返回包装 ErrRepositoryMoved 的错误。
+// 此方法是线程安全的。
+//
+// 返回：
+//
+//	error - 如果目录已变化或无法读取哨兵文件，返回错误
+func (s *Storage) CheckSentinel() error {
+	return s.RedactError(s.checkSentinel())
+}
+
+// checkSentinel 是 CheckSentinel 的实现。
+func (s *Storage) checkSentinel() error {
+	if s.memory {
+		return nil
+	}
+	b, err := os.ReadFile(SentinelFilePath(s.path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = fmt.Errorf("%w: sentinel file is missing", ErrRepositoryMoved)
+		}
+		return &StorageError{
+			Operation: "check sentinel",
+			Path:      s.path,
+			Err:       err,
+		}
+	}
+
+	if strings.TrimSpace(string(b)) != s.sentinel {
+		return &StorageError{
+			Operation: "check sentinel",
+			Path:      s.path,
+			Err:       fmt.Errorf("%w: sentinel token changed", ErrRepositoryMoved),
+		}
+	}
+	return nil
+}
+
+// WatchSentinel 每隔 interval 检查一次哨兵文件，供需要主动通知的服务使用。
+//
+// 返回的通道在检查第一次失败时收到该错误，随后关闭；ctx 取消时直接关闭。
+//
+// 参数：
+//
+//	ctx - 用于停止检查的上下文
+//	interval - 检查间隔，必须大于 0
+//
+// 返回：
+//
+//	<-chan error - 在目录变化时收到错误的通道
+func (s *Storage) WatchSentinel(ctx context.Context, interval time.Duration) <-chan error {
+	ch := make(chan error, 1)
+
+	go func() {
+		defer close(ch)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := s.CheckSentinel(); err != nil {
+				ch <- err
+				return
+			}
+		}
+	}()
+
+	return ch
+}
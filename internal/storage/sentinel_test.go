@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStorage_CheckSentinel(t *testing.T) {
+	s, dir := SetupStorage(t)
+
+	if err := s.CheckSentinel(); err != nil {
+		t.Fatalf("CheckSentinel on a fresh storage failed: %v", err)
+	}
+
+	// A directory replaced by another one carries a different token
+	if err := os.WriteFile(SentinelFilePath(dir), []byte("other"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CheckSentinel(); !errors.Is(err, ErrRepositoryMoved) {
+		t.Errorf("CheckSentinel with a changed token = %v, want ErrRepositoryMoved", err)
+	}
+
+	// An empty mountpoint has no sentinel at all
+	if err := os.Remove(SentinelFilePath(dir)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CheckSentinel(); !errors.Is(err, ErrRepositoryMoved) {
+		t.Errorf("CheckSentinel with a missing sentinel = %v, want ErrRepositoryMoved", err)
+	}
+}
+
+func TestStorage_CheckSentinel_Reopen(t *testing.T) {
+	dir := t.TempDir()
+
+	first, err := NewStorage(dir)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	token, err := os.ReadFile(SentinelFilePath(dir))
+	if err != nil {
+		t.Fatalf("sentinel file was not written: %v", err)
+	}
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := NewStorage(dir)
+	if err != nil {
+		t.Fatalf("reopening failed: %v", err)
+	}
+	defer second.Close()
+
+	if err := second.CheckSentinel(); err != nil {
+		t.Errorf("CheckSentinel after reopening failed: %v", err)
+	}
+	if err := first.CheckSentinel(); !errors.Is(err, ErrRepositoryMoved) {
+		t.Errorf("stale instance CheckSentinel = %v, want ErrRepositoryMoved", err)
+	}
+	if again, _ := os.ReadFile(SentinelFilePath(dir)); string(again) == string(token) {
+		t.Error("reopening did not write a new token")
+	}
+}
+
+func TestStorage_WatchSentinel(t *testing.T) {
+	s, dir := SetupStorage(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := s.WatchSentinel(ctx, 10*time.Millisecond)
+
+	if err := os.Remove(SentinelFilePath(dir)); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-ch:
+		if !errors.Is(err, ErrRepositoryMoved) {
+			t.Errorf("WatchSentinel sent %v, want ErrRepositoryMoved", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WatchSentinel did not report the missing sentinel")
+	}
+
+	if _, ok := <-ch; ok {
+		t.Error("channel still open after reporting an error")
+	}
+}
+
+func TestStorage_WatchSentinel_Cancel(t *testing.T) {
+	s, _ := SetupStorage(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := s.WatchSentinel(ctx, 10*time.Millisecond)
+	cancel()
+
+	select {
+	case err, ok := <-ch:
+		if ok {
+			t.Errorf("WatchSentinel sent %v after cancel, want a closed channel", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WatchSentinel did not stop after cancel")
+	}
+}
@@ -2,18 +2,18 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 
 	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/mount"
 	measure "github.com/ipfs/go-ds-measure"
 	"github.com/mitchellh/go-homedir"
-	"github.com/rogpeppe/go-internal/lockedfile"
 )
 
 const LockFile = ".storage.lock"
@@ -37,11 +37,21 @@ const LockFile = ".storage.lock"
 //	ds := store.Datastore()
 //	// 使用 datastore...
 type Storage struct {
-	mu        sync.Mutex
-	closed    atomic.Bool
-	path      string
-	lockFile  *lockedfile.File
-	datastore Datastore
+	mu         sync.Mutex
+	closed     atomic.Bool
+	path       string
+	lockFile   lockHandle
+	closing    *closeOp // 正在进行或已完成的关闭，未关闭时为 nil
+	datastore  Datastore
+	opts       Options
+	redactRoot string        // 错误信息中需要隐藏的根路径，为空表示不脱敏
+	sentinel   string        // 打开时写入哨兵文件的令牌
+	repair     *RepairReport // 打开时自动修复的结果，没有修复时为 nil
+	memory     bool          // 由 NewMemoryStorage 创建，没有目录和锁文件
+	mounts     []mount.Mount // 各挂载点的 datastore，配置不是 mount 时为 nil
+	files      []fileMount   // 值保存为单独文件的 FlatFS 挂载点，供 OpenReader 使用
+
+	externalPaths []string // 位于仓库目录之外的挂载点存储路径
 }
 
 // Datastore 返回底层的数据存储实例。
@@ -53,7 +63,26 @@ func (s *Storage) Datastore() Datastore {
 	return s.datastore
 }
 
-// GetStorageUsage 返回存储使用的磁盘空间。
+// RepairReport 返回打开时自动修复 LevelDB 的结果。
+//
+// 只有设置 Options.AutoRepair 且打开时发现损坏才有结果，否则返回 nil。
+func (s *Storage) RepairReport() *RepairReport {
+	return s.repair
+}
+
+// Paths 返回存储占用的所有目录：仓库目录，以及位于仓库目录之外的挂载点存储路径。
+//
+// 返回：
+//
+//	[]string - 目录列表，第一个是仓库目录；内存存储返回 nil
+func (s *Storage) Paths() []string {
+	if s.memory {
+		return nil
+	}
+	return append([]string{s.path}, s.externalPaths...)
+}
+
+// GetStorageUsage 返回存储使用的磁盘空间。内存存储返回所有数据块的字节数之和。
 //
 // 参数：
 //
@@ -64,7 +93,56 @@ func (s *Storage) Datastore() Datastore {
 //	uint64 - 使用的字节数
 //	error - 如果获取失败，返回错误
 func (s *Storage) GetStorageUsage(ctx context.Context) (uint64, error) {
-	return ds.DiskUsage(ctx, s.Datastore())
+	if s.memory {
+		return s.memoryUsage(ctx)
+	}
+	usage, err := ds.DiskUsage(ctx, s.Datastore())
+	return usage, s.RedactError(err)
+}
+
+// MountUsage 返回每个挂载点使用的磁盘空间，键为挂载点（如 "/blocks" 和 "/"），
+// 各值之和与 GetStorageUsage 相同。配置不是 mount 时只有挂载点 "/"；
+// 内存存储只有 "/blocks"，值为所有数据块的字节数之和。
+//
+// 参数：
+//
+//	ctx - 用于取消操作的上下文
+//
+// 返回：
+//
+//	map[string]uint64 - 每个挂载点使用的字节数
+//	error - 如果获取失败，返回错误
+func (s *Storage) MountUsage(ctx context.Context) (map[string]uint64, error) {
+	if s.memory {
+		usage, err := s.memoryUsage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]uint64{blocksPrefix: usage}, nil
+	}
+	if s.mounts == nil {
+		usage, err := s.GetStorageUsage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]uint64{"/": usage}, nil
+	}
+
+	usage := make(map[string]uint64, len(s.mounts))
+	for _, m := range s.mounts {
+		u, err := ds.DiskUsage(ctx, m.Datastore)
+		if err != nil {
+			return nil, s.RedactError(err)
+		}
+		usage[m.Prefix.String()] = u
+	}
+	return usage, nil
+}
+
+// closeOp 是一次在后台执行的关闭操作。
+type closeOp struct {
+	done chan struct{} // 关闭完成后被关闭
+	err  error         // 关闭结果，done 关闭后可读
 }
 
 // Close 关闭存储并释放资源。
@@ -87,6 +165,10 @@ func (s *Storage) Close() error {
 // CloseWithContext 是幂等的，多次调用不会返回错误。
 // 关闭后，Storage 对象不再可用。
 //
+// datastore 的关闭（写入 flatfs 的用量检查点、leveldb 的刷新和关闭）
+// 不可中断。ctx 先结束时立即返回 ctx.Err()，关闭在后台继续，
+// 完成后才释放锁；之后的 Close 或 Destroy 会等待它完成。
+//
 // 参数：
 //
 //	ctx - 用于取消操作或设置超时的上下文
@@ -95,48 +177,85 @@ func (s *Storage) Close() error {
 //
 //	error - 如果关闭过程中出现错误或上下文取消，返回错误
 func (s *Storage) CloseWithContext(ctx context.Context) error {
+	return s.RedactError(s.close(ctx))
+}
+
+// close 是 CloseWithContext 的实现。
+func (s *Storage) close(ctx context.Context) error {
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
 	default:
 	}
 
-	// 快速检查是否已关闭（双重检查锁定模式）
-	if s.closed.Load() {
-		return nil
-	}
-
-	// 获取 datastore 并标记为关闭
 	s.mu.Lock()
-	var ds Datastore
-	if !s.closed.Load() {
-		ds = s.datastore
-		s.closed.Store(true)
-	}
+	op := s.startClose()
 	s.mu.Unlock()
 
-	if ds == nil {
-		return nil
+	return op.wait(ctx)
+}
+
+// startClose 在后台开始关闭 datastore 并释放锁，返回关闭操作。
+// 已经开始关闭时返回同一操作；已被 Destroy 关闭时返回已完成的操作。
+// 调用者必须持有 s.mu。
+func (s *Storage) startClose() *closeOp {
+	if s.closing != nil {
+		return s.closing
 	}
 
-	// 在锁外执行关闭操作，避免持有锁过久
-	var errs []error
-	if err := ds.Close(); err != nil {
-		errs = append(errs, fmt.Errorf("datastore close error: %v", err))
+	op := &closeOp{done: make(chan struct{})}
+	s.closing = op
+	if s.closed.Load() {
+		close(op.done)
+		return op
 	}
+	s.closed.Store(true)
 
-	appendErrors(&errs, s.closeLockFile())
+	d := s.datastore
+	go func() {
+		defer close(op.done)
 
-	if len(errs) > 0 {
-		return fmt.Errorf("errors during close: %v", errs)
+		var errs []error
+		if err := d.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("datastore close error: %v", err))
+		}
+		appendErrors(&errs, s.closeLockFile())
+
+		if len(errs) > 0 {
+			op.err = fmt.Errorf("errors during close: %v", errs)
+		}
+	}()
+	return op
+}
+
+// finishClose 关闭尚未关闭的存储，并等待关闭完成或 ctx 结束。
+// 之前的 Close 的错误已经返回给它的调用者，这里不再返回。
+// 调用者必须持有 s.mu。
+func (s *Storage) finishClose(ctx context.Context) error {
+	previous := s.closing != nil || s.closed.Load()
+	err := s.startClose().wait(ctx)
+	if previous && ctx.Err() == nil {
+		return nil
 	}
+	return err
+}
 
-	return nil
+// wait 等待关闭完成或 ctx 结束。
+func (op *closeOp) wait(ctx context.Context) error {
+	select {
+	case <-op.done:
+		return op.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // Destroy 销毁存储并删除所有数据。
 //
-// 如果存储已经关闭，只删除数据目录。
+// 先关闭 datastore，再删除数据，最后释放并删除锁文件。
+// 如果存储已经关闭，先不阻塞地获取锁，确认没有其他实例（同一进程或其他进程）
+// 打开了同一存储；锁被持有时返回 *InUseError（包装 ErrInUse），不删除任何内容。
+// 存储目录已不存在时什么也不做，因此中途失败后可以重新执行。
 // 此操作不可逆，请谨慎使用。
 //
 // 此方法使用 context.Background()。如果需要超时或取消控制，
@@ -151,7 +270,9 @@ func (s *Storage) Destroy() error {
 
 // DestroyWithContext 销毁存储并删除所有数据，支持上下文控制。
 //
-// 如果存储已经关闭，只删除数据目录。
+// 锁的检查和删除顺序与 Destroy 相同。删除过程中每删除一个文件或目录前
+// 检查 ctx，ctx 结束时释放锁并返回 ctx.Err()，已删除的内容不会恢复，
+// 可以重新调用 Destroy 继续删除。datastore 的关闭不可中断。
 // 此操作不可逆，请谨慎使用。
 //
 // 参数：
@@ -162,31 +283,182 @@ func (s *Storage) Destroy() error {
 //
 //	error - 如果销毁失败或上下文取消，返回错误
 func (s *Storage) DestroyWithContext(ctx context.Context) error {
+	return s.RedactError(s.destroy(ctx))
+}
+
+// ForceDestroy 销毁存储并删除所有数据，不检查其他实例是否持有锁。
+//
+// 仅用于确定锁的持有者已不存在的情况，例如锁被挂起的进程持有。
+// 其他实例仍在使用存储时，其数据文件会在使用中被删除。
+//
+// 此方法使用 context.Background()。如果需要超时或取消控制，
+// 请使用 ForceDestroyWithContext。
+//
+// 返回：
+//
+//	error - 如果销毁失败，返回错误
+func (s *Storage) ForceDestroy() error {
+	return s.ForceDestroyWithContext(context.Background())
+}
+
+// ForceDestroyWithContext 与 ForceDestroy 相同，支持上下文控制。
+//
+// 删除过程中每删除一个文件或目录前检查 ctx，ctx 结束时返回 ctx.Err()。
+//
+// 参数：
+//
+//	ctx - 用于取消操作或设置超时的上下文
+//
+// 返回：
+//
+//	error - 如果销毁失败或上下文取消，返回错误
+func (s *Storage) ForceDestroyWithContext(ctx context.Context) error {
+	if s.memory {
+		return s.close(ctx)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.finishClose(ctx); err != nil {
+		return s.RedactError(err)
+	}
+	if err := s.removeExternal(ctx); err != nil {
+		return s.RedactError(err)
+	}
+	return s.RedactError(removeAll(ctx, s.path))
+}
+
+// destroy 是 DestroyWithContext 的实现。
+func (s *Storage) destroy(ctx context.Context) error {
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
 	default:
 	}
 
+	if s.memory {
+		return s.close(ctx)
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	// 等待之前被放弃等待的关闭完成，它完成后才释放锁
+	if s.closing != nil {
+		if err := s.finishClose(ctx); err != nil {
+			return err
+		}
+	}
+
 	if s.closed.Load() {
-		return os.RemoveAll(s.path)
+		if _, err := os.Lstat(s.path); os.IsNotExist(err) {
+			return s.removeExternal(ctx)
+		}
+
+		// 已关闭的实例不再持有锁，其他实例可能已重新打开存储
+		lock, err := acquireLock(s.path, filepath.Join(s.path, LockFile))
+		if err != nil {
+			return err
+		}
+		return s.removeLocked(ctx, func() error {
+			err := lock.Close()
+			if rmErr := os.Remove(lock.Name()); rmErr != nil && !os.IsNotExist(rmErr) && err == nil {
+				err = rmErr
+			}
+			return err
+		})
 	}
 
 	if err := s.datastore.Close(); err != nil {
 		return err
 	}
-
 	s.closed.Store(true)
 
-	err := s.closeLockFile()
+	return s.removeLocked(ctx, func() error {
+		if err := s.closeLockFile(); err != nil {
+			return fmt.Errorf("failed to close lock file: %v", err)
+		}
+		return nil
+	})
+}
+
+// removeLocked 在持有锁时删除存储内容，然后调用 unlock 释放并删除锁文件，
+// 最后删除存储目录。删除失败时同样释放锁。
+func (s *Storage) removeLocked(ctx context.Context, unlock func() error) error {
+	if err := s.removeContents(ctx); err != nil {
+		_ = unlock()
+		return err
+	}
+	if err := unlock(); err != nil {
+		return err
+	}
+	return removeAll(ctx, s.path)
+}
+
+// removeContents 删除挂载到其他位置的存储目录和存储目录中除锁文件外的所有内容。
+func (s *Storage) removeContents(ctx context.Context) error {
+	if err := s.removeExternal(ctx); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(s.path)
 	if err != nil {
-		return fmt.Errorf("failed to close lock file: %v", err)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Name() == LockFile {
+			continue
+		}
+		if err := removeAll(ctx, filepath.Join(s.path, entry.Name())); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	return os.RemoveAll(s.path)
+// removeExternal 删除挂载到其他位置的存储目录。
+func (s *Storage) removeExternal(ctx context.Context) error {
+	for _, path := range s.externalPaths {
+		if err := removeAll(ctx, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeAll 与 os.RemoveAll 相同，但在删除每个文件或目录前检查 ctx。
+func removeAll(ctx context.Context, path string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for _, entry := range entries {
+			if err := removeAll(ctx, filepath.Join(path, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
 }
 
 // NewStorage 创建或打开一个存储实例。
@@ -224,17 +496,7 @@ func NewStorage(path string) (*Storage, error) {
 //	*Storage - 存储实例
 //	error - 如果创建或打开失败或上下文取消，返回错误
 func NewStorageWithContext(ctx context.Context, path string) (*Storage, error) {
-	select {
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	default:
-	}
-
-	if err := initSpec(path, DefaultDiskSpec()); err != nil {
-		return nil, err
-	}
-
-	return openWithContext(ctx, path)
+	return NewStorageWithOptions(ctx, path, Options{})
 }
 
 // initSpec 初始化存储配置文件。
@@ -268,6 +530,11 @@ func open(path string) (*Storage, error) {
 //
 // 创建存储结构、获取锁文件、验证可写性并打开 datastore。
 func openWithContext(ctx context.Context, path string) (*Storage, error) {
+	return openWithOptions(ctx, path, Options{})
+}
+
+// openWithOptions 使用指定配置打开现有存储实例。
+func openWithOptions(ctx context.Context, path string, opts Options) (*Storage, error) {
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
@@ -278,13 +545,14 @@ func openWithContext(ctx context.Context, path string) (*Storage, error) {
 	if err != nil {
 		return nil, err
 	}
+	s.opts = opts
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	lockPath := filepath.Join(s.path, LockFile)
 
-	lockFile, err := createLockFile(lockPath)
+	lockFile, err := createLockFile(ctx, lockPath, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -300,6 +568,14 @@ func openWithContext(ctx context.Context, path string) (*Storage, error) {
 		return nil, err
 	}
 
+	if s.sentinel, err = writeSentinel(s.path); err != nil {
+		return nil, &StorageError{
+			Operation: "write sentinel",
+			Path:      s.path,
+			Err:       err,
+		}
+	}
+
 	if err = s.openDatastore(); err != nil {
 		return nil, err
 	}
@@ -329,21 +605,39 @@ func newStorage(path string) (*Storage, error) {
 
 // openDatastore 打开底层数据存储。
 //
-// 读取现有配置，验证与默认配置匹配，然后创建 datastore。
+// 读取现有配置，验证其逻辑布局与默认配置匹配，然后按配置中记录的
+// 存储路径（或 Options.MountPaths 覆盖后的路径）创建 datastore。
 func (s *Storage) openDatastore() error {
-	if err := s.validateConfig(); err != nil {
+	spec, err := s.resolveSpec()
+	if err != nil {
 		return err
 	}
 
-	dsc, err := AnyDatastoreConfig(DefaultDiskSpec())
+	dsc, err := AnyDatastoreConfig(spec)
 	if err != nil {
 		return &ConfigError{
 			Field: "type",
 			Err:   err,
 		}
 	}
+	files, err := fileMounts(s.path, spec)
+	if err != nil {
+		return &ConfigError{
+			Field: "shardFunc",
+			Err:   err,
+		}
+	}
 
 	d, err := dsc.Create(s.path)
+	var corrupt *CorruptionError
+	if errors.As(err, &corrupt) && s.opts.AutoRepair {
+		report, repairErr := repairLevelDB(corrupt.Mount, corrupt.Path)
+		if repairErr != nil {
+			return repairErr
+		}
+		s.repair = &report
+		d, err = dsc.Create(s.path)
+	}
 	if err != nil {
 		return &StorageError{
 			Operation: "create datastore",
@@ -352,39 +646,72 @@ func (s *Storage) openDatastore() error {
 		}
 	}
 
+	if m, ok := d.(*mountedDatastore); ok {
+		s.mounts = m.mounts
+	}
+	s.files = files
 	s.datastore = measure.New("ipfs.storage.datastore", d)
+	s.externalPaths = externalMountPaths(s.path, spec)
 	return nil
 }
 
-// validateConfig 验证现有配置与默认配置匹配。
-func (s *Storage) validateConfig() error {
+// resolveSpec 验证现有配置并返回用于创建 datastore 的完整配置。
+//
+// 配置只比较逻辑布局，存储路径可以不同。各挂载点使用磁盘配置中记录的路径，
+// Options.MountPaths 中的路径优先；覆盖后的路径会写回磁盘配置，
+// 之后不带覆盖打开时仍使用这些路径。
+func (s *Storage) resolveSpec() (DiskSpec, error) {
 	defaultConfig, err := AnyDatastoreConfig(DefaultDiskSpec())
 	if err != nil {
-		return &ConfigError{
+		return nil, &ConfigError{
 			Field: "default",
 			Err:   err,
 		}
 	}
+	expectedSpec := defaultConfig.DiskSpec()
 
-	expectedSpec := defaultConfig.DiskSpec().String()
 	actualSpec, err := s.readSpec()
 	if err != nil {
-		return &StorageError{
+		return nil, &StorageError{
 			Operation: "read config",
 			Path:      s.path,
 			Err:       err,
 		}
 	}
 
-	if actualSpec != expectedSpec {
-		return &ConfigError{
+	actual, err := parseSpec(actualSpec)
+	if err != nil || logicalSpec(actual) != logicalSpec(expectedSpec) {
+		return nil, &ConfigError{
 			Field: "datastore_spec",
 			Value: actualSpec,
 			Err:   fmt.Errorf("does not match expected config: %s", expectedSpec),
 		}
 	}
 
-	return nil
+	paths := make(map[string]string)
+	for _, m := range specMounts(actual) {
+		if path, ok := leafSpec(m)["path"].(string); ok {
+			paths[mountpointOf(m)] = path
+		}
+	}
+	for mountpoint, path := range s.opts.MountPaths {
+		paths[mountpoint] = path
+	}
+
+	if len(s.opts.MountPaths) > 0 {
+		persisted := OverrideMountPaths(expectedSpec, paths)
+		if persisted.String() != actualSpec {
+			if err := writeSpec(s.path, persisted); err != nil {
+				return nil, &StorageError{
+					Operation: "write config",
+					Path:      s.path,
+					Err:       err,
+				}
+			}
+		}
+	}
+
+	return OverrideMountPaths(DefaultDiskSpec(), paths), nil
 }
 
 // readSpec 从磁盘读取存储配置。
@@ -401,10 +728,10 @@ func (s *Storage) readSpec() (string, error) {
 
 // createLockFile 创建并初始化锁文件。
 //
-// 锁文件用于防止多个进程同时访问同一存储。
-// 将当前进程 PID 写入锁文件。
-func createLockFile(lockPath string) (*lockedfile.File, error) {
-	lockfile, err := lockedfile.Create(lockPath)
+// 锁文件用于防止多个进程同时访问同一存储，锁被其他实例持有时等待其释放，
+// 直到 ctx 结束。启用 LockMetadata 时将当前进程 PID（以及可选的主机名）写入锁文件。
+func createLockFile(ctx context.Context, lockPath string, opts Options) (lockHandle, error) {
+	lockfile, err := waitLock(ctx, lockPath, opts)
 	if err != nil {
 		if os.IsExist(err) {
 			return nil, &LockError{
@@ -419,13 +746,16 @@ func createLockFile(lockPath string) (*lockedfile.File, error) {
 		}
 	}
 
-	pidBytes := []byte(strconv.Itoa(os.Getpid()))
-	if _, err = lockfile.Write(pidBytes); err != nil {
+	if !opts.LockMetadata {
+		return lockfile, nil
+	}
+
+	if _, err = lockfile.Write([]byte(lockMetadata(opts))); err != nil {
 		_ = lockfile.Close()
 		_ = os.Remove(lockPath)
 		return nil, &LockError{
 			Path: lockPath,
-			Err:  fmt.Errorf("failed to write PID: %w", err),
+			Err:  fmt.Errorf("failed to write lock metadata: %w", err),
 		}
 	}
 
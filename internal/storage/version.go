@@ -0,0 +1,274 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// VersionFile 是仓库目录中记录磁盘格式版本的文件名。
+	VersionFile = "repo.version"
+
+	// CurrentVersion 是本库支持的磁盘格式版本。
+	CurrentVersion = 1
+)
+
+var (
+	// ErrMigrationRequired 表示仓库的磁盘格式较旧，需要先运行 Upgrade。
+	ErrMigrationRequired = errors.New("repository migration required")
+
+	// ErrVersionTooNew 表示仓库由更新版本的库创建，当前库无法打开。
+	ErrVersionTooNew = errors.New("repository version is newer than supported")
+)
+
+// migration 是从某个版本升级到下一个版本的步骤。
+type migration struct {
+	// name 是通过 progress 回调报告的步骤描述
+	name string
+	// run 执行升级，成功后由 Upgrade 写入新版本号
+	run func(ctx context.Context, path string) error
+}
+
+// migrations 按起始版本注册升级步骤，migrations[v] 将仓库从 v 升级到 v+1。
+var migrations = map[int]migration{
+	0: {name: "synthesize datastore_spec for legacy layout", run: migrateLegacyLayout},
+}
+
+// legacyDiskSpec 返回早期版本写入的存储配置（没有 measure 包装）。
+func legacyDiskSpec() DiskSpec {
+	return map[string]interface{}{
+		"type": "mount",
+		"mounts": []interface{}{
+			map[string]interface{}{
+				"mountpoint": "/blocks",
+				"type":       "flatfs",
+				"path":       "blocks",
+				"shardFunc":  "/repo/flatfs/shard/v1/next-to-last/2",
+			},
+			map[string]interface{}{
+				"mountpoint": "/",
+				"type":       "levelds",
+				"path":       "datastore",
+			},
+		},
+	}
+}
+
+// VersionFilePath 返回版本文件的完整路径。
+func VersionFilePath(repoPath string) string {
+	return filepath.Join(repoPath, VersionFile)
+}
+
+// NeedsMigration 检查仓库是否需要升级。
+//
+// 参数：
+//
+//	path - 仓库路径
+//
+// 返回：
+//
+//	from - 仓库当前的磁盘格式版本
+//	to - 本库支持的版本（CurrentVersion）
+//	needed - 如果仓库存在且版本低于 CurrentVersion，返回 true
+//
+// 不存在的仓库、无法识别的目录以及版本高于 CurrentVersion 的仓库都返回 false；
+// 后者在打开时会返回 ErrVersionTooNew。
+func NeedsMigration(path string) (from, to int, needed bool) {
+	version, exists, err := detectVersion(path)
+	if err != nil || !exists {
+		return 0, CurrentVersion, false
+	}
+	return version, CurrentVersion, version < CurrentVersion
+}
+
+// Upgrade 将仓库升级到 CurrentVersion。
+//
+// 依次执行注册的升级步骤，每完成一步就更新版本文件，中断后可以重新运行。
+// 升级期间持有仓库锁文件，仓库不能同时被打开。
+//
+// 参数：
+//
+//	ctx - 用于取消操作的上下文
+//	path - 仓库路径
+//	progress - 每个步骤开始前调用，参数为步骤描述；可以为 nil
+//
+// 返回：
+//
+//	error - 如果升级失败，返回错误；版本过新时返回包装 ErrVersionTooNew 的 *VersionError
+func Upgrade(ctx context.Context, path string, progress func(step string)) error {
+	version, exists, err := detectVersion(path)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return &InvalidPathError{Path: path, Reason: "no repository found"}
+	}
+	if version > CurrentVersion {
+		return newVersionError(path, version)
+	}
+	if version == CurrentVersion {
+		return ensureVersionFile(path)
+	}
+
+	lockPath := filepath.Join(path, LockFile)
+	lockFile, err := createLockFile(ctx, lockPath, Options{})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = lockFile.Close()
+		_ = os.Remove(lockPath)
+	}()
+
+	for v := version; v < CurrentVersion; v++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		step, ok := migrations[v]
+		if !ok {
+			return fmt.Errorf("no migration registered from version %d", v)
+		}
+		if progress != nil {
+			progress(step.name)
+		}
+		if err := step.run(ctx, path); err != nil {
+			return &StorageError{
+				Operation: fmt.Sprintf("migrate from version %d", v),
+				Path:      path,
+				Err:       err,
+			}
+		}
+		if err := writeVersion(path, v+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateLegacyLayout 为早期版本的仓库写入当前的 datastore_spec。
+//
+// 早期仓库使用相同的目录（blocks 和 datastore），但没有 datastore_spec
+// 或使用没有 measure 包装的配置。升级前验证目录存在且分片方式一致。
+func migrateLegacyLayout(_ context.Context, path string) error {
+	for _, dir := range []string{"blocks", "datastore"} {
+		info, err := os.Stat(filepath.Join(path, dir))
+		if err != nil {
+			return fmt.Errorf("expected directory %q: %w", dir, err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("expected %q to be a directory", dir)
+		}
+	}
+
+	sharding, err := os.ReadFile(filepath.Join(path, "blocks", "SHARDING"))
+	if err == nil {
+		expected := "/repo/flatfs/shard/v1/next-to-last/2"
+		if got := strings.TrimSpace(string(sharding)); got != expected {
+			return fmt.Errorf("unsupported flatfs sharding %q, expected %q", got, expected)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	dsc, err := AnyDatastoreConfig(DefaultDiskSpec())
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(DatastoreSpecPath(path), dsc.DiskSpec().Bytes(), 0o600)
+}
+
+// detectVersion 返回仓库的磁盘格式版本。
+//
+// 没有版本文件时：使用早期配置或没有配置但已有数据目录的仓库为版本 0；
+// 使用当前配置的仓库为版本 1。exists 为 false 表示目录中还没有仓库。
+func detectVersion(path string) (version int, exists bool, err error) {
+	data, err := os.ReadFile(VersionFilePath(path))
+	if err == nil {
+		version, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil || version < 0 {
+			return 0, true, &ConfigError{Field: VersionFile, Value: string(data), Err: fmt.Errorf("invalid version")}
+		}
+		return version, true, nil
+	}
+	if !os.IsNotExist(err) {
+		return 0, false, err
+	}
+
+	specPath := DatastoreSpecPath(path)
+	if FileExists(specPath) {
+		spec, err := os.ReadFile(specPath)
+		if err != nil {
+			return 0, true, err
+		}
+		if strings.TrimSpace(string(spec)) == legacyDiskSpec().String() {
+			return 0, true, nil
+		}
+		return 1, true, nil
+	}
+
+	if isDir(filepath.Join(path, "blocks")) && isDir(filepath.Join(path, "datastore")) {
+		return 0, true, nil
+	}
+	return 0, false, nil
+}
+
+// checkVersion 在打开仓库前检查版本，拒绝需要升级或版本过新的仓库。
+func checkVersion(path string) error {
+	version, exists, err := detectVersion(path)
+	if err != nil {
+		return err
+	}
+	if exists && version != CurrentVersion {
+		return newVersionError(path, version)
+	}
+	return nil
+}
+
+// ensureVersionFile 在版本文件不存在时写入当前版本。
+func ensureVersionFile(path string) error {
+	if FileExists(VersionFilePath(path)) {
+		return nil
+	}
+	return writeVersion(path, CurrentVersion)
+}
+
+// writeVersion 写入版本文件。
+func writeVersion(path string, version int) error {
+	if err := os.WriteFile(VersionFilePath(path), []byte(strconv.Itoa(version)), 0o600); err != nil {
+		return &StorageError{
+			Operation: "write version",
+			Path:      path,
+			Err:       err,
+		}
+	}
+	return nil
+}
+
+// newVersionError 创建版本不匹配错误。
+func newVersionError(path string, version int) error {
+	err := ErrMigrationRequired
+	if version > CurrentVersion {
+		err = ErrVersionTooNew
+	}
+	return &VersionError{
+		Path:      path,
+		Version:   version,
+		Supported: CurrentVersion,
+		Err:       err,
+	}
+}
+
+// isDir 检查路径是否为目录。
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+// createLegacyRepo 创建一个包含数据的仓库，然后删除版本文件，
+// 并删除 datastore_spec（spec 为空）或写入指定的早期配置。
+func createLegacyRepo(t *testing.T, spec string) (string, ds.Key) {
+	t.Helper()
+
+	tmpDir := SetupTempDir(t, "storage-legacy-*")
+	t.Cleanup(func() { os.RemoveAll(tmpDir) })
+
+	s, err := NewStorage(tmpDir)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	key := ds.NewKey("/legacy/key")
+	if err := s.Datastore().Put(context.Background(), key, []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if err := os.Remove(VersionFilePath(tmpDir)); err != nil {
+		t.Fatalf("failed to remove version file: %v", err)
+	}
+	if spec == "" {
+		if err := os.Remove(DatastoreSpecPath(tmpDir)); err != nil {
+			t.Fatalf("failed to remove spec: %v", err)
+		}
+	} else {
+		CreateTestFile(t, DatastoreSpecPath(tmpDir), []byte(spec))
+	}
+
+	return tmpDir, key
+}
+
+func TestNewStorage_WritesVersionFile(t *testing.T) {
+	s, tmpDir := SetupStorage(t)
+	defer CleanupTestData(t, tmpDir, s)
+
+	data, err := os.ReadFile(VersionFilePath(tmpDir))
+	if err != nil {
+		t.Fatalf("failed to read version file: %v", err)
+	}
+	if string(data) != "1" {
+		t.Errorf("version file = %q, want %q", data, "1")
+	}
+
+	if _, _, needed := NeedsMigration(tmpDir); needed {
+		t.Error("new repository should not need migration")
+	}
+}
+
+func TestNewStorage_StampsCurrentRepoWithoutVersion(t *testing.T) {
+	s, tmpDir := SetupStorage(t)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// 版本标记出现之前创建的仓库使用当前配置，视为当前版本
+	if err := os.Remove(VersionFilePath(tmpDir)); err != nil {
+		t.Fatalf("failed to remove version file: %v", err)
+	}
+	if from, _, needed := NeedsMigration(tmpDir); needed || from != CurrentVersion {
+		t.Errorf("NeedsMigration = %d, %v; want %d, false", from, needed, CurrentVersion)
+	}
+
+	s, err := NewStorage(tmpDir)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	defer s.Close()
+
+	if !FileExists(VersionFilePath(tmpDir)) {
+		t.Error("expected version file to be written on open")
+	}
+}
+
+func TestUpgrade_LegacyLayouts(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{name: "no datastore_spec", spec: ""},
+		{name: "legacy datastore_spec", spec: legacyDiskSpec().String()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, key := createLegacyRepo(t, tt.spec)
+
+			from, to, needed := NeedsMigration(path)
+			if !needed || from != 0 || to != CurrentVersion {
+				t.Fatalf("NeedsMigration = %d, %d, %v; want 0, %d, true", from, to, needed, CurrentVersion)
+			}
+
+			// 升级前拒绝打开，并给出升级提示
+			_, err := NewStorage(path)
+			if !errors.Is(err, ErrMigrationRequired) {
+				t.Fatalf("expected ErrMigrationRequired, got %v", err)
+			}
+			var versionErr *VersionError
+			if !errors.As(err, &versionErr) || versionErr.Version != 0 || versionErr.Supported != CurrentVersion {
+				t.Errorf("unexpected version error: %v", err)
+			}
+			if !strings.Contains(err.Error(), "Upgrade") {
+				t.Errorf("error should mention Upgrade: %v", err)
+			}
+
+			var steps []string
+			if err := Upgrade(context.Background(), path, func(step string) {
+				steps = append(steps, step)
+			}); err != nil {
+				t.Fatalf("Upgrade failed: %v", err)
+			}
+			if len(steps) != 1 {
+				t.Errorf("expected 1 step, got %v", steps)
+			}
+
+			if _, _, needed := NeedsMigration(path); needed {
+				t.Error("repository should not need migration after Upgrade")
+			}
+
+			s, err := NewStorage(path)
+			if err != nil {
+				t.Fatalf("NewStorage after Upgrade failed: %v", err)
+			}
+			defer s.Close()
+
+			value, err := s.Datastore().Get(context.Background(), key)
+			if err != nil || string(value) != "value" {
+				t.Errorf("legacy data not preserved: %q, %v", value, err)
+			}
+		})
+	}
+}
+
+func TestUpgrade_VersionTooNew(t *testing.T) {
+	s, tmpDir := SetupStorage(t)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	CreateTestFile(t, VersionFilePath(tmpDir), []byte("99"))
+
+	if _, _, needed := NeedsMigration(tmpDir); needed {
+		t.Error("newer repository should not report migration")
+	}
+	if _, err := NewStorage(tmpDir); !errors.Is(err, ErrVersionTooNew) {
+		t.Errorf("expected ErrVersionTooNew from NewStorage, got %v", err)
+	}
+	if err := Upgrade(context.Background(), tmpDir, nil); !errors.Is(err, ErrVersionTooNew) {
+		t.Errorf("expected ErrVersionTooNew from Upgrade, got %v", err)
+	}
+}
+
+func TestUpgrade_RejectsUnexpectedSharding(t *testing.T) {
+	path, _ := createLegacyRepo(t, "")
+	CreateTestFile(t, filepath.Join(path, "blocks", "SHARDING"), []byte("/repo/flatfs/shard/v1/prefix/2"))
+
+	err := Upgrade(context.Background(), path, nil)
+	if err == nil {
+		t.Fatal("expected error for unexpected sharding")
+	}
+	if FileExists(DatastoreSpecPath(path)) {
+		t.Error("failed upgrade should not write datastore_spec")
+	}
+	if from, _, needed := NeedsMigration(path); !needed || from != 0 {
+		t.Errorf("failed upgrade should leave version 0, got %d, %v", from, needed)
+	}
+}
+
+func TestUpgrade_NoRepository(t *testing.T) {
+	tmpDir := SetupTempDir(t, "storage-empty-*")
+	defer os.RemoveAll(tmpDir)
+
+	if _, _, needed := NeedsMigration(tmpDir); needed {
+		t.Error("empty directory should not need migration")
+	}
+	if err := Upgrade(context.Background(), tmpDir, nil); err == nil {
+		t.Error("expected error for empty directory")
+	}
+}
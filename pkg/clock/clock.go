@@ -0,0 +1,76 @@
+// Package clock abstracts the passage of time for the importer, the
+// extractor and the validator scheduler.
+//
+// Code that reads the wall clock or waits for a duration does so through a
+// Clock, so tests can replace the real clock with the manually advanced one
+// of the clocktest package and run deterministically without sleeping.
+package clock
+
+import "time"
+
+// Clock is a source of time.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// Since returns the time elapsed since t.
+	Since(t time.Time) time.Duration
+
+	// NewTimer returns a Timer that fires once after d.
+	NewTimer(d time.Duration) Timer
+
+	// After waits for d and then sends the current time on the returned
+	// channel, like time.After.
+	After(d time.Duration) <-chan time.Time
+}
+
+// Timer is a single event, like time.Timer.
+type Timer interface {
+	// C returns the channel on which the time is sent when the timer fires.
+	C() <-chan time.Time
+
+	// Stop prevents the timer from firing. It reports whether the timer was
+	// stopped before it fired.
+	Stop() bool
+
+	// Reset changes the timer to fire after d. It reports whether the timer
+	// was active before.
+	Reset(d time.Duration) bool
+}
+
+// Real is the Clock backed by the time package.
+type Real struct{}
+
+func (Real) Now() time.Time                         { return time.Now() }
+func (Real) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (Real) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func (Real) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+// realTimer adapts a time.Timer to Timer.
+type realTimer struct {
+	t *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time        { return t.t.C }
+func (t realTimer) Stop() bool                 { return t.t.Stop() }
+func (t realTimer) Reset(d time.Duration) bool { return t.t.Reset(d) }
+
+// OrReal returns c, or the real clock if c is nil.
+func OrReal(c Clock) Clock {
+	if c == nil {
+		return Real{}
+	}
+	return c
+}
+
+// Sleep pauses the calling goroutine for d as measured by c.
+func Sleep(c Clock, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	t := c.NewTimer(d)
+	<-t.C()
+}
@@ -0,0 +1,148 @@
+// Package clocktest provides a manually advanced clock for tests.
+package clocktest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tragoedia0722/repository/pkg/clock"
+)
+
+// Fake is a clock.Clock whose time only moves when Advance is called.
+// Timers fire, in deadline order, when the clock is advanced past their
+// deadline. It is safe for concurrent use.
+type Fake struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*fakeTimer // Pending timers
+}
+
+var _ clock.Clock = (*Fake)(nil)
+
+// NewFake returns a Fake clock set to now.
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+// Now returns the current time of the clock.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Since returns the clock time elapsed since t.
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+// After returns the channel of a new timer firing after d.
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+// NewTimer returns a timer firing once the clock has been advanced by d. A
+// timer for a duration that is not positive fires immediately.
+func (f *Fake) NewTimer(d time.Duration) clock.Timer {
+	t := &fakeTimer{clock: f, ch: make(chan time.Time, 1)}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.schedule(t, d)
+	return t
+}
+
+// Advance moves the clock forward by d and fires every timer whose deadline
+// has been reached.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+	sort.SliceStable(f.timers, func(i, j int) bool {
+		return f.timers[i].deadline.Before(f.timers[j].deadline)
+	})
+	remaining := f.timers[:0]
+	for _, t := range f.timers {
+		if t.deadline.After(f.now) {
+			remaining = append(remaining, t)
+			continue
+		}
+		t.fire(f.now)
+	}
+	f.timers = remaining
+}
+
+// Waiters returns the number of pending timers.
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.timers)
+}
+
+// BlockUntil waits until at least n timers are pending, for example until
+// the code under test has started waiting on the clock.
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.timers) < n {
+		f.cond.Wait()
+	}
+}
+
+// schedule makes t fire after d. Callers hold f.mu.
+func (f *Fake) schedule(t *fakeTimer, d time.Duration) {
+	if d <= 0 {
+		t.fire(f.now)
+		return
+	}
+	t.deadline = f.now.Add(d)
+	f.timers = append(f.timers, t)
+	f.cond.Broadcast()
+}
+
+// unschedule removes t from the pending timers and reports whether it was
+// pending. Callers hold f.mu.
+func (f *Fake) unschedule(t *fakeTimer) bool {
+	for i, pending := range f.timers {
+		if pending == t {
+			f.timers = append(f.timers[:i], f.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// fakeTimer is a timer of a Fake clock.
+type fakeTimer struct {
+	clock    *Fake
+	ch       chan time.Time
+	deadline time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.ch }
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.unschedule(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	active := t.clock.unschedule(t)
+	t.clock.schedule(t, d)
+	return active
+}
+
+// fire sends now on the channel unless a previous value is still unread.
+func (t *fakeTimer) fire(now time.Time) {
+	select {
+	case t.ch <- now:
+	default:
+	}
+}
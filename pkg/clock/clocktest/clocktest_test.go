@@ -0,0 +1,85 @@
+package clocktest
+
+import (
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// fired reports whether a value is ready on ch.
+func fired(ch <-chan time.Time) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestFake_Advance(t *testing.T) {
+	f := NewFake(epoch)
+	short := f.NewTimer(time.Second)
+	long := f.After(3 * time.Second)
+
+	f.Advance(999 * time.Millisecond)
+	if fired(short.C()) || fired(long) {
+		t.Fatal("timers fired before their deadline")
+	}
+	f.Advance(time.Millisecond)
+	if !fired(short.C()) || fired(long) {
+		t.Fatal("expected only the short timer to fire")
+	}
+	if got := f.Since(epoch); got != time.Second {
+		t.Errorf("Since = %v, want 1s", got)
+	}
+	if f.Waiters() != 1 {
+		t.Errorf("Waiters = %d, want 1", f.Waiters())
+	}
+
+	f.Advance(time.Hour)
+	if !fired(long) || f.Waiters() != 0 {
+		t.Error("expected the long timer to fire")
+	}
+}
+
+func TestFake_StopReset(t *testing.T) {
+	f := NewFake(epoch)
+	timer := f.NewTimer(time.Second)
+	if !timer.Stop() || timer.Stop() {
+		t.Error("Stop should report true once")
+	}
+	f.Advance(time.Second)
+	if fired(timer.C()) {
+		t.Error("stopped timer fired")
+	}
+
+	if timer.Reset(2 * time.Second) {
+		t.Error("Reset of a stopped timer reported it active")
+	}
+	f.Advance(time.Second)
+	if fired(timer.C()) {
+		t.Error("reset timer fired early")
+	}
+	f.Advance(time.Second)
+	if !fired(timer.C()) {
+		t.Error("reset timer did not fire")
+	}
+
+	if !fired(f.After(0)) {
+		t.Error("timer for zero duration did not fire immediately")
+	}
+}
+
+func TestFake_BlockUntil(t *testing.T) {
+	f := NewFake(epoch)
+	done := make(chan struct{})
+	go func() {
+		<-f.After(time.Minute)
+		close(done)
+	}()
+
+	f.BlockUntil(1)
+	f.Advance(time.Minute)
+	<-done
+}
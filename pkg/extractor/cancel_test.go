@@ -0,0 +1,198 @@
+package extractor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeHookDestination calls onWrite with the bytes written so far to each
+// file.
+type writeHookDestination struct {
+	Destination
+	onWrite func(relPath string, total int)
+}
+
+func (d *writeHookDestination) CreateFile(relPath string) (io.WriteCloser, error) {
+	w, err := d.Destination.CreateFile(relPath)
+	if err != nil {
+		return nil, err
+	}
+	return &hookedWriter{WriteCloser: w, rel: relPath, onWrite: d.onWrite}, nil
+}
+
+type hookedWriter struct {
+	io.WriteCloser
+	rel     string
+	total   int
+	onWrite func(relPath string, total int)
+}
+
+func (w *hookedWriter) Write(p []byte) (int, error) {
+	n, err := w.WriteCloser.Write(p)
+	w.total += n
+	w.onWrite(w.rel, w.total)
+	return n, err
+}
+
+// readState reads the state file at path.
+func readState(t *testing.T, path string) extractState {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("state file not written: %v", err)
+	}
+	var state extractState
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatalf("invalid state file: %v", err)
+	}
+	return state
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestExtractor_CancelBeforeFinalize(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	root := importFixture(t, bs, map[string][]byte{
+		"a.txt": []byte("complete"),
+		"b.txt": []byte("never started"),
+	}, nil).RootCid
+	out := t.TempDir()
+	stateFile := filepath.Join(t.TempDir(), "extract.state")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var hookErr error
+	ext := NewExtractor(bs, root, out).
+		WithStateFile(stateFile).
+		WithFinalizeHook(func(hookCtx context.Context, partPath, rel string, size int64) error {
+			cancel()
+			hookErr = hookCtx.Err()
+			return nil
+		})
+	if err := ext.Extract(ctx, false); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Extract returned %v, want context.Canceled", err)
+	}
+
+	if hookErr != nil {
+		t.Errorf("finalize hook saw a cancelled context: %v", hookErr)
+	}
+	if got, err := os.ReadFile(filepath.Join(out, "a.txt")); err != nil || string(got) != "complete" {
+		t.Errorf("a.txt was not finalized: %q, %v", got, err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "a.txt"+partFileSuffix)); !os.IsNotExist(err) {
+		t.Errorf("a.txt part file remains: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "b.txt")); !os.IsNotExist(err) {
+		t.Errorf("b.txt was extracted after cancellation: %v", err)
+	}
+
+	state := readState(t, stateFile)
+	if !containsString(state.Completed, "a.txt") {
+		t.Errorf("a.txt is not recorded as completed: %v", state.Completed)
+	}
+	if len(state.InFlight) != 0 {
+		t.Errorf("files left in flight: %v", state.InFlight)
+	}
+}
+
+func TestExtractor_CancelDuringLastWrite(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	data := []byte(strings.Repeat("last chunk;", 100))
+	root := importFixture(t, bs, map[string][]byte{"a.txt": data}, nil).RootCid
+	out := t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Cancel as the last byte is written; the check before the final read
+	// must not discard the complete file
+	ext := NewExtractor(bs, root, out).WithYield(time.Nanosecond)
+	ext.WithDestination(&writeHookDestination{
+		Destination: ext.destination(),
+		onWrite: func(rel string, total int) {
+			if total == len(data) {
+				cancel()
+			}
+		},
+	})
+	if err := ext.Extract(ctx, false); err != nil && !errors.Is(err, context.Canceled) {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	if got, err := os.ReadFile(filepath.Join(out, "a.txt")); err != nil || string(got) != string(data) {
+		t.Errorf("a.txt was not finalized: %d bytes, %v", len(got), err)
+	}
+}
+
+func TestExtractor_CancelMidWrite(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	large := []byte(strings.Repeat("0123456789abcdef", 6<<20/16))
+	root := importFixture(t, bs, map[string][]byte{
+		"a.txt": []byte("small"),
+		"b.bin": large,
+	}, nil).RootCid
+	out := t.TempDir()
+	stateFile := filepath.Join(t.TempDir(), "extract.state")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ext := NewExtractor(bs, root, out).WithStateFile(stateFile).WithYield(time.Nanosecond)
+	ext.WithDestination(&writeHookDestination{
+		Destination: ext.destination(),
+		onWrite: func(rel string, total int) {
+			if rel == "b.bin" {
+				cancel()
+			}
+		},
+	})
+	if err := ext.Extract(ctx, false); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Extract returned %v, want context.Canceled", err)
+	}
+
+	for _, name := range []string{"b.bin", "b.bin" + partFileSuffix} {
+		if _, err := os.Stat(filepath.Join(out, name)); !os.IsNotExist(err) {
+			t.Errorf("%s remains after cancellation: %v", name, err)
+		}
+	}
+	state := readState(t, stateFile)
+	if !containsString(state.Completed, "a.txt") || containsString(state.Completed, "b.bin") {
+		t.Errorf("completed = %v, want a.txt only", state.Completed)
+	}
+	if state.InFlight["b.bin"] != FileWriting {
+		t.Errorf("b.bin recorded as %q, want %q", state.InFlight["b.bin"], FileWriting)
+	}
+
+	// The resumed extraction reports the interrupted file and writes it
+	ext = NewExtractor(bs, root, out).WithStateFile(stateFile)
+	if err := ext.Extract(context.Background(), false); err != nil {
+		t.Fatalf("resumed Extract failed: %v", err)
+	}
+	if got := ext.Interrupted(); got["b.bin"] != FileWriting || len(got) != 1 {
+		t.Errorf("Interrupted() = %v, want b.bin writing", got)
+	}
+	if info, err := os.Stat(filepath.Join(out, "b.bin")); err != nil || info.Size() != int64(len(large)) {
+		t.Errorf("b.bin was not extracted on resume: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package extractor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// ChecksumMismatchError reports a file whose extracted data does not match the
+// checksum recorded at import time, or with WithVerify the CID of one of its
+// blocks. It wraps ErrChecksumMismatch.
+type ChecksumMismatchError struct {
+	Path     string // Path relative to the extraction root
+	Expected string // Recorded hex SHA-256, or the CID of the block that differs
+	Actual   string // Hex SHA-256 of the data written, or the CID it hashes to
+	Hash     string // "sha256" for WithChecksumVerify, "cid" for WithVerify
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("%s %q: expected %s %s, got %s", ErrChecksumMismatch, e.Path, e.Hash, e.Expected, e.Actual)
+}
+
+func (e *ChecksumMismatchError) Unwrap() error {
+	return ErrChecksumMismatch
+}
+
+// WithChecksumVerify verifies extracted files against the SHA-256 checksums
+// recorded by the importer, as returned by importer.Result.Checksums, keyed
+// by slash-separated path relative to the root ("" for a single file).
+//
+// The data is hashed as it is written to the part file, below any text
+// transform, so no extra read pass is needed. A file that does not match is
+// removed before it reaches its final path and fails the extraction with a
+// *ChecksumMismatchError; with WithSkipRejected it is reported by Rejected
+// instead. Files without a recorded checksum, files changed by the text
+// transform and files truncated by the selector are not verified, and
+// neither are existing files skipped because of their size.
+// Returns the extractor instance for method chaining.
+func (ext *Extractor) WithChecksumVerify(checksums map[string]string) *Extractor {
+	ext.checksums = checksums
+	return ext
+}
+
+// checksumWriter tees w into a SHA-256 hash when relativePath has a recorded
+// checksum. It returns w and a nil hash otherwise.
+func (ext *Extractor) checksumWriter(w io.Writer, relativePath string) (io.Writer, hash.Hash) {
+	if ext.checksums[ext.rootPath(relativePath)] == "" {
+		return w, nil
+	}
+	digest := sha256.New()
+	return io.MultiWriter(w, digest), digest
+}
+
+// verifyChecksum compares the hash of the data written for relativePath with
+// its recorded checksum.
+func (ext *Extractor) verifyChecksum(relativePath string, digest hash.Hash) error {
+	if digest == nil {
+		return nil
+	}
+	expected := ext.checksums[ext.rootPath(relativePath)]
+	if actual := hex.EncodeToString(digest.Sum(nil)); actual != expected {
+		return &ChecksumMismatchError{Path: relativePath, Expected: expected, Actual: actual, Hash: "sha256"}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/tragoedia0722/repository/pkg/importer"
+)
+
+// corruptingBlockstore flips the last byte of the blocks in corrupt.
+type corruptingBlockstore struct {
+	blockstore.Blockstore
+
+	corrupt map[cid.Cid]bool
+}
+
+func (b *corruptingBlockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	blk, err := b.Blockstore.Get(ctx, c)
+	if err != nil || !b.corrupt[c] {
+		return blk, err
+	}
+	data := append([]byte(nil), blk.RawData()...)
+	data[len(data)-1] ^= 0xff
+	return blocks.NewBlockWithCid(data, c)
+}
+
+// withChecksums configures an importFixture import to record checksums.
+func withChecksums(imp *importer.Importer) {
+	imp.WithChecksums(true)
+}
+
+// corruptTree imports a tree with a large file and returns it together with a
+// blockstore that corrupts one of that file's blocks.
+func corruptTree(t *testing.T) (*importer.Result, blockstore.Blockstore, map[string][]byte) {
+	t.Helper()
+
+	bs, cleanup := createTestBlockstore(t)
+	t.Cleanup(cleanup)
+
+	large := make([]byte, 600*1024)
+	rand.New(rand.NewSource(1)).Read(large)
+	tree := map[string][]byte{
+		"good.txt":    []byte("intact"),
+		"bad.bin":     large,
+		"dir/ok.json": []byte(`{}`),
+	}
+	result := importFixture(t, bs, tree, withChecksums)
+
+	leaves := descendants(t, bs, result.RootCid, "bad.bin")
+	if len(leaves) < 2 {
+		t.Fatalf("bad.bin has %d leaf blocks, want several", len(leaves))
+	}
+	return result, &corruptingBlockstore{Blockstore: bs, corrupt: map[cid.Cid]bool{leaves[1]: true}}, tree
+}
+
+func TestExtractor_WithChecksumVerify(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	tree := map[string][]byte{
+		"a.txt":     []byte("alpha"),
+		"dir/b.txt": []byte("beta"),
+	}
+	result := importFixture(t, bs, tree, withChecksums)
+
+	out := filepath.Join(t.TempDir(), "out")
+	ext := NewExtractor(bs, result.RootCid, out).WithChecksumVerify(result.Checksums())
+	if err := ext.Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if got := readTree(t, out); !reflect.DeepEqual(got, tree) {
+		t.Errorf("extracted %v, want %v", got, tree)
+	}
+}
+
+func TestExtractor_WithChecksumVerify_CorruptBlock(t *testing.T) {
+	result, corrupt, _ := corruptTree(t)
+	sums := result.Checksums()
+
+	out := filepath.Join(t.TempDir(), "out")
+	err := NewExtractor(corrupt, result.RootCid, out).WithChecksumVerify(sums).Extract(context.Background(), false)
+
+	var mismatch *ChecksumMismatchError
+	if !errors.As(err, &mismatch) || !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Extract() error = %v, want a *ChecksumMismatchError", err)
+	}
+	if mismatch.Path != "bad.bin" || mismatch.Expected != sums["bad.bin"] || mismatch.Actual == mismatch.Expected {
+		t.Errorf("mismatch = %+v", mismatch)
+	}
+	for _, name := range []string{"bad.bin", "bad.bin" + partFileSuffix} {
+		if _, err := os.Lstat(filepath.Join(out, name)); !os.IsNotExist(err) {
+			t.Errorf("%s exists after a checksum mismatch: %v", name, err)
+		}
+	}
+
+	// Without verification the corruption goes unnoticed
+	out = filepath.Join(t.TempDir(), "out")
+	if err := NewExtractor(corrupt, result.RootCid, out).Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract without verification failed: %v", err)
+	}
+}
+
+func TestExtractor_WithChecksumVerify_SkipRejected(t *testing.T) {
+	result, corrupt, tree := corruptTree(t)
+
+	out := filepath.Join(t.TempDir(), "out")
+	ext := NewExtractor(corrupt, result.RootCid, out).
+		WithChecksumVerify(result.Checksums()).
+		WithSkipRejected(true)
+	if err := ext.Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	delete(tree, "bad.bin")
+	if got := readTree(t, out); !reflect.DeepEqual(got, tree) {
+		t.Errorf("extracted %v, want %v", got, tree)
+	}
+
+	rejected := ext.Rejected()
+	if len(rejected) != 1 || rejected[0].Path != "bad.bin" || !errors.Is(rejected[0].Err, ErrChecksumMismatch) {
+		t.Errorf("Rejected() = %+v, want bad.bin with ErrChecksumMismatch", rejected)
+	}
+}
+
+func TestExtractor_WithChecksumVerify_SingleFile(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	src := filepath.Join(t.TempDir(), "single.txt")
+	if err := os.WriteFile(src, []byte("single file"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := importer.NewImporter(bs, src).WithChecksums(true).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	sums := result.Checksums()
+	if _, ok := sums[""]; !ok || len(sums) != 1 {
+		t.Fatalf("Checksums() = %v, want the root file under \"\"", sums)
+	}
+
+	out := filepath.Join(t.TempDir(), "single.txt")
+	if err := NewExtractor(bs, result.RootCid, out).WithChecksumVerify(sums).Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	// A recorded checksum that does not match keeps the file from appearing
+	tampered := filepath.Join(t.TempDir(), "tampered.txt")
+	err = NewExtractor(bs, result.RootCid, tampered).
+		WithChecksumVerify(map[string]string{"": sums[""][1:] + "0"}).
+		Extract(context.Background(), false)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Extract() error = %v, want ErrChecksumMismatch", err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(tampered))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("files left after a checksum mismatch: %v", entries)
+	}
+}
@@ -1,5 +1,11 @@
 package extractor
 
+import (
+	"time"
+
+	"github.com/tragoedia0722/repository/internal/artifact"
+)
+
 const (
 	// defaultWriteBufferSize is the default buffer size for writing files (4MB)
 	defaultWriteBufferSize = 4 * 1024 * 1024
@@ -15,10 +21,37 @@ const (
 	defaultCleanedDirName = "cleaned_dir"
 
 	// partFileSuffix is the suffix used for temporary files during atomic writes
-	partFileSuffix = ".part"
+	partFileSuffix = artifact.PartFileSuffix
+
+	// partialFileSuffix is appended to files truncated by a FileLimiter
+	partialFileSuffix = ".partial"
 
 	// progressUpdateThreshold is the minimum number of bytes that must be
 	// read before triggering a progress callback update (256KB).
 	// This reduces callback frequency from ~250K to ~4K calls per GB.
 	progressUpdateThreshold = 256 * 1024
+
+	// defaultSharedCacheSize is the default amount of block data ExtractMany
+	// keeps in memory for reuse across targets (64MB).
+	defaultSharedCacheSize = 64 * 1024 * 1024
+
+	// sharedUsageEntries is the number of blocks ExtractMany tracks to count
+	// the blocks shared by several targets, about 10MB of memory.
+	sharedUsageEntries = 1 << 16
+
+	// stateFlushEntries is the number of completed entries after which the
+	// state file is rewritten.
+	stateFlushEntries = 1000
+
+	// stateFlushInterval is the maximum time between state file rewrites
+	// while entries are being completed.
+	stateFlushInterval = 2 * time.Second
+
+	// finalizeTimeout bounds finalizing a fully written file (checksum,
+	// finalize hook and rename), which cancellation does not interrupt.
+	finalizeTimeout = 30 * time.Second
+
+	// defaultTimingThreshold is the minimum size of files listed
+	// individually in the timing summary (1MB).
+	defaultTimingThreshold = 1024 * 1024
 )
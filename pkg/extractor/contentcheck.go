@@ -0,0 +1,130 @@
+package extractor
+
+import (
+	"context"
+	"io"
+
+	"github.com/ipfs/boxo/files"
+)
+
+// ContentCheck selects how an existing regular file with the same size as
+// the file being extracted is compared before the extraction skips it.
+type ContentCheck int
+
+const (
+	// SizeOnly skips an existing file whose size matches. It reads nothing
+	// and is the default, but leaves a file in place whose content changed
+	// without changing its length.
+	SizeOnly ContentCheck = iota
+
+	// QuickHash compares the first and last 64KB of the existing file with
+	// the same ranges of the DAG, seeking the DAG reader instead of reading
+	// the whole file. A change in the middle of a file larger than 128KB
+	// goes unnoticed.
+	QuickHash
+
+	// FullHash compares the whole existing file with the DAG.
+	FullHash
+)
+
+// quickCheckSpan is the number of bytes QuickHash compares at each end of a
+// file.
+const quickCheckSpan = 64 * 1024
+
+// ReadableDestination is implemented by destinations whose files can be
+// read back. WithContentCheck compares existing files through it; on other
+// destinations a same-size file is always replaced unless the check is
+// SizeOnly.
+type ReadableDestination interface {
+	// Open opens the regular file at relPath for reading.
+	Open(relPath string) (io.ReadSeekCloser, error)
+}
+
+// WithContentCheck sets how an existing file whose size matches the file
+// being extracted is checked before it is skipped, both when overwriting
+// and when revalidating files a state file recorded as completed. A file
+// found to differ is replaced and counted in ExtractSummary.Replaced.
+// An existing file that cannot be read counts as a difference. The default
+// is SizeOnly.
+// Returns the extractor instance for method chaining.
+func (ext *Extractor) WithContentCheck(mode ContentCheck) *Extractor {
+	ext.contentChk = mode
+	return ext
+}
+
+// sameAsExisting reports whether the existing file at relativePath, which
+// has the same size as nd, holds the content of nd. A node that has to be
+// read is rewound afterwards. A file found to differ is remembered, so
+// writeEntry replaces it without comparing it again. With WithVerifyExisting
+// the file is hashed against the node instead of compared with its data.
+func (ext *Extractor) sameAsExisting(ctx context.Context, nd files.Node, relativePath string, size int64) (bool, error) {
+	if ext.stale == relativePath {
+		ext.stale = ""
+		return false, nil
+	}
+	if f, ok := nd.(*dagFile); ok && ext.verify && ext.reverify {
+		same, err := ext.verifyExistingFile(ctx, f, destPath(relativePath), relativePath)
+		if err != nil {
+			return false, err
+		}
+		if !same {
+			ext.stale = relativePath
+		}
+		return same, nil
+	}
+	if ext.contentChk == SizeOnly {
+		return true, nil
+	}
+	node, ok := nd.(files.File)
+	if !ok {
+		return true, nil
+	}
+
+	same, err := ext.compareExisting(node, destPath(relativePath), size)
+	if _, seekErr := node.Seek(0, io.SeekStart); seekErr != nil && err == nil {
+		err = seekErr
+	}
+	if err != nil {
+		return false, err
+	}
+	if !same {
+		ext.stale = relativePath
+	}
+	return same, nil
+}
+
+// compareExisting compares node with the existing file at rel according to
+// the content check.
+func (ext *Extractor) compareExisting(node files.File, rel string, size int64) (bool, error) {
+	readable, ok := ext.destination().(ReadableDestination)
+	if !ok {
+		return false, nil
+	}
+	f, err := readable.Open(rel)
+	if err != nil {
+		return false, nil
+	}
+	defer f.Close()
+
+	if ext.contentChk == FullHash || size <= 2*quickCheckSpan {
+		return sameReader(node, f)
+	}
+
+	same, err := sameRange(node, f, 0, quickCheckSpan)
+	if err != nil || !same {
+		return same, err
+	}
+	return sameRange(node, f, size-quickCheckSpan, quickCheckSpan)
+}
+
+// sameRange reports whether n bytes at offset off are the same in node and
+// f. Errors seeking or reading f count as a difference.
+func sameRange(node files.File, f io.ReadSeeker, off, n int64) (bool, error) {
+	if _, err := node.Seek(off, io.SeekStart); err != nil {
+		return false, err
+	}
+	if _, err := f.Seek(off, io.SeekStart); err != nil {
+		return false, nil
+	}
+	return sameReader(io.LimitReader(node, n), io.LimitReader(f, n))
+}
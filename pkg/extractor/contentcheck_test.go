@@ -0,0 +1,186 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tamper overwrites a few bytes of the file at path at offset off without
+// changing its size.
+func tamper(t *testing.T, path string, off int64) {
+	t.Helper()
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteAt([]byte("tampered"), off); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractor_WithContentCheck(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	big := readAheadData(512 << 10)
+	small := []byte("same size, different content")
+	root := importFixture(t, bs, map[string][]byte{"big.bin": big, "small.txt": small}, nil).RootCid
+
+	tests := []struct {
+		name     string
+		mode     ContentCheck
+		off      int64 // Offset of the change in big.bin
+		replaced bool  // Whether big.bin is replaced
+	}{
+		{"size only", SizeOnly, 0, false},
+		{"quick hash head", QuickHash, 10, true},
+		{"quick hash tail", QuickHash, int64(len(big)) - 20, true},
+		{"quick hash middle", QuickHash, int64(len(big)) / 2, false},
+		{"full hash middle", FullHash, int64(len(big)) / 2, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := t.TempDir()
+			if err := NewExtractor(bs, root, out).Extract(context.Background(), false); err != nil {
+				t.Fatalf("Extract failed: %v", err)
+			}
+			tamper(t, filepath.Join(out, "big.bin"), tt.off)
+			tamper(t, filepath.Join(out, "small.txt"), 0)
+
+			ext := NewExtractor(bs, root, out).WithContentCheck(tt.mode)
+			if err := ext.Extract(context.Background(), true); err != nil {
+				t.Fatalf("Extract failed: %v", err)
+			}
+
+			got, err := os.ReadFile(filepath.Join(out, "big.bin"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Equal(got, big) != tt.replaced {
+				t.Errorf("big.bin restored = %v, want %v", !tt.replaced, tt.replaced)
+			}
+			got, err = os.ReadFile(filepath.Join(out, "small.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if restored := bytes.Equal(got, small); restored != (tt.mode != SizeOnly) {
+				t.Errorf("small.txt restored = %v with mode %d", restored, tt.mode)
+			}
+
+			want := 0
+			if tt.mode != SizeOnly {
+				want++
+			}
+			if tt.replaced {
+				want++
+			}
+			if s := ext.Summary(); s.Replaced != want || s.Skipped != 2-want {
+				t.Errorf("Summary() = %+v, want %d replaced", s, want)
+			}
+		})
+	}
+}
+
+func TestExtractor_WithContentCheck_Unchanged(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	root := importFixture(t, bs, map[string][]byte{"big.bin": readAheadData(512 << 10)}, nil).RootCid
+	out := t.TempDir()
+	if err := NewExtractor(bs, root, out).Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	for _, mode := range []ContentCheck{QuickHash, FullHash} {
+		ext := NewExtractor(bs, root, out).WithContentCheck(mode)
+		if err := ext.Extract(context.Background(), true); err != nil {
+			t.Fatalf("Extract failed: %v", err)
+		}
+		if s := ext.Summary(); s.Skipped != 1 || s.Replaced != 0 || s.Written != 0 {
+			t.Errorf("mode %d: Summary() = %+v, want the file skipped", mode, s)
+		}
+	}
+}
+
+func TestExtractor_WithContentCheck_Revalidate(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	data := []byte("recorded as completed")
+	root := importFixture(t, bs, map[string][]byte{"a.txt": data}, nil).RootCid
+	out := t.TempDir()
+	if err := NewExtractor(bs, root, out).Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	tamper(t, filepath.Join(out, "a.txt"), 0)
+
+	state, err := json.Marshal(extractState{RootCid: root, Completed: []string{"a.txt"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stateFile := filepath.Join(t.TempDir(), "extract.state")
+	if err := os.WriteFile(stateFile, state, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ext := NewExtractor(bs, root, out).
+		WithStateFile(stateFile).
+		WithRevalidate(true).
+		WithContentCheck(FullHash)
+	if err := ext.Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(out, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("a.txt = %q, want %q", got, data)
+	}
+	if s := ext.Summary(); s.Replaced != 1 {
+		t.Errorf("Summary() = %+v, want 1 replaced", s)
+	}
+}
+
+func TestExtractor_WithContentCheck_MemoryDestination(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	data := []byte("in memory")
+	root := importFixture(t, bs, map[string][]byte{"a.txt": data}, nil).RootCid
+	dst := NewMemoryDestination()
+	if err := NewExtractor(bs, root, "unused").WithDestination(dst).Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	w, err := dst.CreateFile("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("IN MEMORY")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.Finalize("a.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	ext := NewExtractor(bs, root, "unused").WithDestination(dst).WithContentCheck(QuickHash)
+	if err := ext.Extract(context.Background(), true); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if got := dst.Files()["a.txt"]; !bytes.Equal(got, data) {
+		t.Errorf("a.txt = %q, want %q", got, data)
+	}
+	if s := ext.Summary(); s.Replaced != 1 {
+		t.Errorf("Summary() = %+v, want 1 replaced", s)
+	}
+}
@@ -0,0 +1,164 @@
+package extractor
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Destination is where extracted entries are written.
+//
+// Paths are relative to the extraction root, use forward slashes and are
+// empty for the root entry itself. The extractor calls Stat before writing
+// an entry to decide whether it conflicts with or can skip an existing one,
+// and Remove to replace an existing entry when overwriting.
+//
+// A file is written through the writer returned by CreateFile. Closing the
+// writer must make its data durable but not yet visible at relPath; Finalize
+// then publishes it. If writing fails, the extractor calls Remove, which must
+// also discard unfinished data created by CreateFile.
+//
+// Destinations that can create symbolic links implement SymlinkDestination.
+type Destination interface {
+	// CreateFile starts writing the file at relPath, creating parent
+	// directories as needed.
+	CreateFile(relPath string) (io.WriteCloser, error)
+
+	// Finalize publishes a file whose writer was closed successfully.
+	Finalize(relPath string) error
+
+	// Mkdir creates the directory at relPath and any missing parents.
+	Mkdir(relPath string) error
+
+	// Stat describes the entry at relPath without following a final symlink.
+	// A missing entry is reported with an error matching fs.ErrNotExist.
+	Stat(relPath string) (fs.FileInfo, error)
+
+	// Remove deletes the entry at relPath, including the contents of a
+	// directory and unfinished data of a file. Removing a missing entry
+	// is not an error.
+	Remove(relPath string) error
+}
+
+// SymlinkDestination is implemented by destinations that support symbolic
+// links. Extracting a symlink to any other destination fails with
+// ErrSymlinkUnsupported.
+type SymlinkDestination interface {
+	// Symlink creates a symbolic link at relPath pointing to target.
+	Symlink(target, relPath string) error
+}
+
+// WithDestination writes extracted entries to dst instead of the filesystem
+// at the extractor's path. Returns the extractor instance for method chaining.
+func (ext *Extractor) WithDestination(dst Destination) *Extractor {
+	ext.dest = dst
+	return ext
+}
+
+// destination returns the configured destination, defaulting to the
+// filesystem at the extractor's path.
+func (ext *Extractor) destination() Destination {
+	if ext.dest == nil {
+		ext.dest = &fsDestination{root: ext.path, base: ext.basePath}
+	}
+	return ext.dest
+}
+
+// destPath converts a relative path built by the extractor to the form used
+// by destinations.
+func destPath(relativePath string) string {
+	return filepath.ToSlash(relativePath)
+}
+
+// fsDestination writes to the local filesystem below root. Files are written
+// to a part file next to their final path and renamed into place by Finalize.
+type fsDestination struct {
+	root string // Directory receiving the extracted entries
+	base string // Directory no entry may escape through a symlink
+
+	lstat  func(string) (fs.FileInfo, error) // Inspects existing entries; nil means os.Lstat
+	writer func(*os.File) io.Writer          // Wraps part files before data is written; nil writes to them directly
+}
+
+// full returns the filesystem path of relPath.
+func (d *fsDestination) full(relPath string) string {
+	return filepath.Join(d.root, filepath.FromSlash(relPath))
+}
+
+// partPath returns the part file written for relPath.
+func (d *fsDestination) partPath(relPath string) string {
+	return d.full(relPath) + partFileSuffix
+}
+
+func (d *fsDestination) CreateFile(relPath string) (io.WriteCloser, error) {
+	f, _, err := createPartFile(d.full(relPath))
+	if err != nil {
+		return nil, err
+	}
+	var w io.Writer = f
+	if d.writer != nil {
+		w = d.writer(f)
+	}
+	return &partFile{f: f, w: w}, nil
+}
+
+func (d *fsDestination) Finalize(relPath string) error {
+	return os.Rename(d.partPath(relPath), d.full(relPath))
+}
+
+func (d *fsDestination) Mkdir(relPath string) error {
+	return os.MkdirAll(d.full(relPath), dirPermissions)
+}
+
+// Stat also rejects paths that would be reached through a symlink below the
+// base directory, since every entry is inspected before it is written.
+func (d *fsDestination) Stat(relPath string) (fs.FileInfo, error) {
+	path := d.full(relPath)
+	if err := ensureNoSymlinkInPath(d.base, path); err != nil {
+		return nil, err
+	}
+	if d.lstat != nil {
+		return d.lstat(path)
+	}
+	return os.Lstat(path)
+}
+
+// Open opens the file at relPath. Like Stat, it rejects paths reached
+// through a symlink below the base directory.
+func (d *fsDestination) Open(relPath string) (io.ReadSeekCloser, error) {
+	path := d.full(relPath)
+	if err := ensureNoSymlinkInPath(d.base, path); err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+func (d *fsDestination) Remove(relPath string) error {
+	if err := os.Remove(d.partPath(relPath)); err != nil && !os.IsNotExist(err) {
+		return wrapRemoveFailed(d.partPath(relPath), err)
+	}
+	return removePath(d.full(relPath))
+}
+
+func (d *fsDestination) Symlink(target, relPath string) error {
+	return os.Symlink(target, d.full(relPath))
+}
+
+// partFile is a part file being written. Closing it syncs the data to disk.
+type partFile struct {
+	f *os.File
+	w io.Writer
+}
+
+func (p *partFile) Write(b []byte) (int, error) {
+	return p.w.Write(b)
+}
+
+func (p *partFile) Close() error {
+	if err := p.f.Sync(); err != nil {
+		_ = p.f.Close()
+		return err
+	}
+	return p.f.Close()
+}
@@ -30,6 +30,30 @@ var (
 
 	// ErrUnsupportedFileType is returned when a file type is not supported
 	ErrUnsupportedFileType = errors.New("unsupported file type")
+
+	// ErrStateFileIgnored is reported to the warning handler when a state file cannot be used
+	ErrStateFileIgnored = errors.New("extraction state file ignored")
+
+	// ErrTargetsFailed is returned by ExtractMany when one or more targets failed
+	ErrTargetsFailed = errors.New("one or more extraction targets failed")
+
+	// ErrFileRejected is returned when a finalize hook rejects a file
+	ErrFileRejected = errors.New("file rejected by finalize hook")
+
+	// ErrSymlinkUnsupported is returned when a symlink is extracted to a destination without symlink support
+	ErrSymlinkUnsupported = errors.New("destination does not support symlinks")
+
+	// ErrChecksumMismatch is returned when an extracted file does not match its recorded checksum
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+
+	// ErrPartialExtraction is returned, as a *PartialExtractionError, when entries failed under ContinueOnError
+	ErrPartialExtraction = errors.New("extraction completed partially")
+
+	// ErrIsDirectory is returned when ExtractToWriter is given a directory instead of a file
+	ErrIsDirectory = errors.New("root is a directory")
+
+	// ErrPathNotFound is returned, as a *PathError, when ExtractPath finds no entry at the inner path
+	ErrPathNotFound = errors.New("path not found below the root")
 )
 
 // PathError represents an error related to path operations
@@ -75,6 +99,15 @@ func wrapUnsupportedFileType(path string, fileType interface{}) error {
 	}
 }
 
+// wrapSymlinkUnsupported wraps an error for a symlink the destination cannot create
+func wrapSymlinkUnsupported(path string) error {
+	return &PathError{
+		Path: path,
+		Op:   "symlink",
+		Err:  ErrSymlinkUnsupported,
+	}
+}
+
 // wrapInvalidDirectoryEntry wraps an error with invalid directory entry information
 func wrapInvalidDirectoryEntry(entryName string) error {
 	return fmt.Errorf("%w: %s", ErrInvalidDirectoryEntry, entryName)
@@ -107,3 +140,21 @@ func wrapMkdirFailed(path string, err error) error {
 		Err:  err,
 	}
 }
+
+// wrapFileRejected wraps an error returned by a finalize hook
+func wrapFileRejected(relativePath string, err error) error {
+	return &PathError{
+		Path: relativePath,
+		Op:   "finalize",
+		Err:  fmt.Errorf("%w: %w", ErrFileRejected, err),
+	}
+}
+
+// wrapReadFailed wraps an error when a directory entry cannot be loaded
+func wrapReadFailed(path string, err error) error {
+	return &PathError{
+		Path: path,
+		Op:   "read",
+		Err:  err,
+	}
+}
@@ -0,0 +1,117 @@
+package extractor
+
+// eventBufferSize is the capacity of the channel returned by Events.
+const eventBufferSize = 64
+
+// ExtractEvent is an event sent on the channel returned by Events. It is one
+// of FileStarted, Progress, FileCompleted, PhaseChanged, Warning, Done or
+// Failed.
+type ExtractEvent interface {
+	extractEvent()
+}
+
+// Phase is a stage of an extraction, reported by PhaseChanged.
+type Phase string
+
+const (
+	PhaseResolving  Phase = "resolving"  // Loading the root node, attributes and state file
+	PhaseExtracting Phase = "extracting" // Writing entries
+	PhaseDone       Phase = "done"       // Extract returned; only reported by WithProgressEvents
+)
+
+// FileStarted is sent before a file is written.
+type FileStarted struct {
+	Path string // Path relative to the extraction root
+	Size int64  // Size of the file's data
+}
+
+// Progress reports the bytes extracted so far, like the WithProgress
+// callback. Progress events may be dropped, see Events.
+type Progress struct {
+	Completed int64  // Bytes extracted so far
+	Total     int64  // Total bytes to extract; 0 when unknown
+	File      string // Path of the file being written
+}
+
+// FileCompleted is sent after a file has been moved to its final path.
+type FileCompleted struct {
+	Path string // Path relative to the extraction root
+	Size int64  // Number of bytes written
+}
+
+// PhaseChanged is sent when the extraction enters a new phase.
+type PhaseChanged struct {
+	Phase Phase
+}
+
+// Warning reports a non-fatal problem, as passed to WithWarningHandler.
+type Warning struct {
+	Err error
+}
+
+// Done is the last event of a successful extraction.
+type Done struct{}
+
+// Failed is the last event of a failed extraction.
+type Failed struct {
+	Err error
+}
+
+func (FileStarted) extractEvent()   {}
+func (Progress) extractEvent()      {}
+func (FileCompleted) extractEvent() {}
+func (PhaseChanged) extractEvent()  {}
+func (Warning) extractEvent()       {}
+func (Done) extractEvent()          {}
+func (Failed) extractEvent()        {}
+
+// Events returns a channel receiving the events of the next Extract, as an
+// alternative or in addition to WithProgress and WithWarningHandler. The
+// channel is buffered and closed when Extract returns, after a final Done or
+// Failed event; call Events again before each Extract.
+//
+// Progress events are sent without blocking and dropped while the buffer is
+// full, so a slow consumer sees them sparsely. All other events are never
+// dropped: sending them blocks until the consumer makes room, so the channel
+// must be drained until it is closed.
+func (ext *Extractor) Events() <-chan ExtractEvent {
+	ext.events = &eventStream{ch: make(chan ExtractEvent, eventBufferSize)}
+	return ext.events.ch
+}
+
+// eventStream sends the events of one extraction. A nil stream discards them.
+type eventStream struct {
+	ch chan ExtractEvent
+}
+
+// send delivers a lifecycle event, blocking while the buffer is full.
+func (s *eventStream) send(ev ExtractEvent) {
+	if s == nil {
+		return
+	}
+	s.ch <- ev
+}
+
+// progress delivers a Progress event unless the buffer is full.
+func (s *eventStream) progress(completed, total int64, file string) {
+	if s == nil {
+		return
+	}
+	select {
+	case s.ch <- Progress{Completed: completed, Total: total, File: file}:
+	default:
+	}
+}
+
+// finish sends Done or Failed and closes the channel.
+func (s *eventStream) finish(err error) {
+	if s == nil {
+		return
+	}
+	if err != nil {
+		s.send(Failed{Err: err})
+	} else {
+		s.send(Done{})
+	}
+	close(s.ch)
+}
@@ -0,0 +1,219 @@
+package extractor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// collectEvents drains events, sleeping after each one to simulate a slow
+// consumer, and returns them once the channel is closed.
+func collectEvents(events <-chan ExtractEvent, delay time.Duration) <-chan []ExtractEvent {
+	out := make(chan []ExtractEvent, 1)
+	go func() {
+		var all []ExtractEvent
+		for ev := range events {
+			all = append(all, ev)
+			time.Sleep(delay)
+		}
+		out <- all
+	}()
+	return out
+}
+
+func TestExtractor_Events(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	tree := map[string][]byte{
+		"a.bin":     make([]byte, 2*1024*1024),
+		"b.txt":     []byte("small"),
+		"dir/c.bin": make([]byte, 1024*1024),
+	}
+	root := importFixture(t, bs, tree, nil).RootCid
+
+	callbacks := 0
+	out := filepath.Join(t.TempDir(), "out")
+	ext := NewExtractor(bs, root, out).WithProgress(func(completed, total int64, file string) {
+		callbacks++
+	})
+	done := collectEvents(ext.Events(), time.Millisecond)
+
+	if err := ext.Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	var events []ExtractEvent
+	select {
+	case events = <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("event channel was not closed")
+	}
+
+	// Lifecycle events arrive completely and in order: every file is
+	// completed before the next one starts
+	var lifecycle []ExtractEvent
+	var progress int
+	var last, total int64
+	for _, ev := range events {
+		p, ok := ev.(Progress)
+		if !ok {
+			lifecycle = append(lifecycle, ev)
+			continue
+		}
+		if p.Completed < last {
+			t.Errorf("progress went backwards: %d -> %d", last, p.Completed)
+		}
+		last, total = p.Completed, p.Total
+		progress++
+	}
+
+	if len(lifecycle) != 2+2*len(tree)+1 {
+		t.Fatalf("lifecycle events = %+v", lifecycle)
+	}
+	wantPhases := []ExtractEvent{PhaseChanged{Phase: PhaseResolving}, PhaseChanged{Phase: PhaseExtracting}}
+	if !reflect.DeepEqual(lifecycle[:2], wantPhases) {
+		t.Errorf("first events = %+v, want %+v", lifecycle[:2], wantPhases)
+	}
+	var files []string
+	for i := 2; i < len(lifecycle)-1; i += 2 {
+		started, ok1 := lifecycle[i].(FileStarted)
+		completed, ok2 := lifecycle[i+1].(FileCompleted)
+		if !ok1 || !ok2 || started.Path != completed.Path || started.Size != completed.Size {
+			t.Fatalf("events %d-%d = %+v, %+v; want FileStarted and FileCompleted of one file", i, i+1, lifecycle[i], lifecycle[i+1])
+		}
+		rel := filepath.ToSlash(started.Path)
+		if int64(len(tree[rel])) != completed.Size {
+			t.Errorf("FileCompleted %q size = %d, want %d", rel, completed.Size, len(tree[rel]))
+		}
+		files = append(files, rel)
+	}
+	sort.Strings(files)
+	if want := []string{"a.bin", "b.txt", "dir/c.bin"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("files = %v, want %v", files, want)
+	}
+	if _, ok := lifecycle[len(lifecycle)-1].(Done); !ok {
+		t.Errorf("last event = %+v, want Done", lifecycle[len(lifecycle)-1])
+	}
+
+	// Progress events may be sparse, and run alongside the callback
+	if callbacks == 0 {
+		t.Error("the WithProgress callback was not called alongside the event channel")
+	}
+	if progress > callbacks {
+		t.Errorf("%d progress events for %d callbacks", progress, callbacks)
+	}
+	if progress > 0 && total != 3*1024*1024+5 {
+		t.Errorf("progress total = %d", total)
+	}
+	t.Logf("%d progress events, %d callbacks", progress, callbacks)
+}
+
+func TestExtractor_Events_FailedAndWarning(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	root := importFixture(t, bs, map[string][]byte{"a.txt": []byte("a")}, nil).RootCid
+	out := filepath.Join(t.TempDir(), "out")
+
+	// A state file for another root is ignored with a warning
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	data, _ := json.Marshal(extractState{RootCid: "bafyother"})
+	if err := os.WriteFile(stateFile, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var handled []error
+	ext := NewExtractor(bs, root, out).
+		WithStateFile(stateFile).
+		WithWarningHandler(func(err error) { handled = append(handled, err) })
+	done := collectEvents(ext.Events(), 0)
+	if err := ext.Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	var warnings []error
+	for _, ev := range <-done {
+		if w, ok := ev.(Warning); ok {
+			warnings = append(warnings, w.Err)
+		}
+	}
+	if len(warnings) != 1 || !errors.Is(warnings[0], ErrStateFileIgnored) {
+		t.Errorf("warning events = %v, want one wrapping ErrStateFileIgnored", warnings)
+	}
+	if !reflect.DeepEqual(handled, warnings) {
+		t.Errorf("warning handler got %v, events %v", handled, warnings)
+	}
+
+	// Extracting again without overwrite fails; the channel ends with Failed
+	ext = NewExtractor(bs, root, out)
+	done = collectEvents(ext.Events(), 0)
+	err := ext.Extract(context.Background(), false)
+	if !errors.Is(err, ErrPathExistsOverwrite) {
+		t.Fatalf("Extract() error = %v, want ErrPathExistsOverwrite", err)
+	}
+	events := <-done
+	failed, ok := events[len(events)-1].(Failed)
+	if !ok || failed.Err != err {
+		t.Errorf("last event = %+v, want Failed with %v", events[len(events)-1], err)
+	}
+}
+
+func TestExtractor_WithProgressEvents(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	tree := map[string][]byte{
+		"a.bin":     make([]byte, 2*1024*1024),
+		"b.txt":     []byte("small"),
+		"dir/c.bin": make([]byte, 1024*1024),
+	}
+	root := importFixture(t, bs, tree, nil).RootCid
+
+	var events []ProgressEvent
+	ext := NewExtractor(bs, root, filepath.Join(t.TempDir(), "out")).
+		WithConcurrency(2).
+		WithProgressEvents(func(ev ProgressEvent) {
+			events = append(events, ev)
+		})
+	if err := ext.Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if len(events) == 0 {
+		t.Fatal("no progress events")
+	}
+
+	var phases []Phase
+	files := 0
+	for _, ev := range events {
+		if ev.CurrentFile == "" {
+			phases = append(phases, ev.Phase)
+		}
+		if ev.FilesCompleted > files {
+			// A file is reported completed with all of its bytes
+			if ev.CurrentFileBytes != ev.CurrentFileTotal {
+				t.Errorf("%s completed with %d of %d bytes", ev.CurrentFile, ev.CurrentFileBytes, ev.CurrentFileTotal)
+			}
+			files = ev.FilesCompleted
+		}
+	}
+	wantPhases := []Phase{PhaseResolving, PhaseExtracting, PhaseDone}
+	if !reflect.DeepEqual(phases, wantPhases) {
+		t.Errorf("phases = %v, want %v", phases, wantPhases)
+	}
+
+	var total int64
+	for _, data := range tree {
+		total += int64(len(data))
+	}
+	last := events[len(events)-1]
+	if last.Phase != PhaseDone || last.CompletedBytes != total || last.FilesCompleted != len(tree) {
+		t.Errorf("last event = %+v, want PhaseDone with %d bytes in %d files", last, total, len(tree))
+	}
+}
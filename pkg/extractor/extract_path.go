@@ -0,0 +1,141 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ipfs/boxo/files"
+	unixfile "github.com/ipfs/boxo/ipld/unixfs/file"
+	uio "github.com/ipfs/boxo/ipld/unixfs/io"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// ExtractPath extracts only the entry at innerPath below the root, such as
+// "subdir/report.pdf", writing the file or the subtree to the extractor's
+// path as Extract writes the root. innerPath may use forward or backward
+// slashes; its components are cleaned like the entry names Extract writes
+// and matched against the cleaned names of the directory entries, and ".."
+// is rejected with ErrPathTraversalAttempt. An empty innerPath extracts the
+// whole root.
+//
+// Only the directories along innerPath and the selected entry are read.
+// The progress total is the size of the selected entry, and paths given to
+// the selector, the finalize hook and the events are relative to it, while
+// recorded checksums and extended attributes are still looked up by their
+// path below the root. A state file is keyed by the root CID together with
+// innerPath.
+//
+// If no entry exists at innerPath, ExtractPath returns a *PathError wrapping
+// ErrPathNotFound.
+func (ext *Extractor) ExtractPath(ctx context.Context, innerPath string, overwrite bool) error {
+	inner, err := cleanInnerPath(innerPath)
+	if err != nil {
+		return err
+	}
+	ext.inner = inner
+	defer func() { ext.inner = "" }()
+	return ext.Extract(ctx, overwrite)
+}
+
+// cleanInnerPath cleans the components of innerPath and returns them as a
+// slash path, or "" for the root.
+func cleanInnerPath(innerPath string) (string, error) {
+	p := strings.ReplaceAll(innerPath, "\\", "/")
+	if strings.Trim(p, "/.") == "" && !strings.Contains(p, "..") {
+		return "", nil
+	}
+	cleaned, err := normalizeBackslashPath(p)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(cleaned), nil
+}
+
+// rootPath returns the slash path below the root of the entry at
+// relativePath below the extracted entry.
+func (ext *Extractor) rootPath(relativePath string) string {
+	if ext.inner == "" {
+		return destPath(relativePath)
+	}
+	return path.Join(ext.inner, destPath(relativePath))
+}
+
+// stateKey identifies the extracted entry in the state file.
+func (ext *Extractor) stateKey() string {
+	if ext.inner == "" {
+		return ext.cid
+	}
+	return ext.cid + "/" + ext.inner
+}
+
+// errLinkFound stops the enumeration of directory links at a match.
+var errLinkFound = errors.New("link found")
+
+// openPath resolves rootCid through ds, walks the directories down to the
+// entry at the slash path inner and returns it as a UnixFS node together
+// with its total size.
+func openPath(ctx context.Context, ds ipld.DAGService, rootCid string, inner string) (files.Node, int64, error) {
+	c, err := cid.Parse(rootCid)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	node, err := ds.Get(ctx, c)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if inner != "" {
+		for _, name := range strings.Split(inner, "/") {
+			if node, err = findEntry(ctx, ds, node, name); err != nil {
+				return nil, 0, &PathError{Path: inner, Op: "resolve", Err: err}
+			}
+		}
+		c = node.Cid()
+	}
+
+	fileNode, err := unixfile.NewUnixfsFile(ctx, ds, node)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	size, err := fileNode.Size()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return wrapNode(fileNode, c, ds), size, nil
+}
+
+// findEntry returns the entry of the directory nd whose cleaned name is
+// name. It returns ErrPathNotFound when nd is not a directory or has no
+// such entry.
+func findEntry(ctx context.Context, ds ipld.DAGService, nd ipld.Node, name string) (ipld.Node, error) {
+	dir, err := uio.NewDirectoryFromNode(ds, nd)
+	if errors.Is(err, uio.ErrNotADir) {
+		return nil, ErrPathNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var found *ipld.Link
+	err = dir.ForEachLink(ctx, func(l *ipld.Link) error {
+		if clean, err := normalizeEntryName(l.Name); err == nil && filepath.ToSlash(clean) == name {
+			found = l
+			return errLinkFound
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errLinkFound) {
+		return nil, err
+	}
+	if found == nil {
+		return nil, ErrPathNotFound
+	}
+	return found.GetNode(ctx, ds)
+}
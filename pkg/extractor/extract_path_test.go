@@ -0,0 +1,125 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// extractPathTree is an imported folder of which single entries are
+// extracted.
+func extractPathTree() map[string][]byte {
+	return map[string][]byte{
+		"a.txt":                 []byte("top level"),
+		"sub/dir/report.pdf":    readAheadData(300 << 10),
+		"sub/dir/notes.txt":     []byte("notes"),
+		"sub/other/unused.bin":  readAheadData(200 << 10),
+		"sibling/unrelated.txt": []byte("not extracted"),
+	}
+}
+
+func TestExtractor_ExtractPath_File(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	tree := extractPathTree()
+	root := importFixture(t, bs, tree, nil).RootCid
+	out := filepath.Join(t.TempDir(), "report.pdf")
+
+	var completed, total int64
+	ext := NewExtractor(bs, root, out).WithProgress(func(c, tot int64, _ string) {
+		completed, total = c, tot
+	})
+	// Backslashes and redundant separators are accepted
+	if err := ext.ExtractPath(context.Background(), `sub\dir//report.pdf`, false); err != nil {
+		t.Fatalf("ExtractPath failed: %v", err)
+	}
+
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, tree["sub/dir/report.pdf"]) {
+		t.Error("report.pdf content mismatch")
+	}
+	if want := int64(len(tree["sub/dir/report.pdf"])); total != want || completed != want {
+		t.Errorf("progress %d of %d, want %d of %d", completed, total, want, want)
+	}
+}
+
+func TestExtractor_ExtractPath_Subtree(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	tree := extractPathTree()
+	root := importFixture(t, bs, tree, nil).RootCid
+	out := t.TempDir()
+
+	sum := sha256.Sum256(tree["sub/dir/notes.txt"])
+	var total int64
+	ext := NewExtractor(bs, root, out).
+		WithChecksumVerify(map[string]string{"sub/dir/notes.txt": hex.EncodeToString(sum[:])}).
+		WithProgress(func(_, tot int64, _ string) { total = tot })
+	if err := ext.ExtractPath(context.Background(), "sub", true); err != nil {
+		t.Fatalf("ExtractPath failed: %v", err)
+	}
+
+	for _, rel := range []string{"dir/report.pdf", "dir/notes.txt", "other/unused.bin"} {
+		got, err := os.ReadFile(filepath.Join(out, filepath.FromSlash(rel)))
+		if err != nil {
+			t.Fatalf("%s: %v", rel, err)
+		}
+		if !bytes.Equal(got, tree["sub/"+rel]) {
+			t.Errorf("%s content mismatch", rel)
+		}
+	}
+	for _, rel := range []string{"a.txt", "sibling", "sub"} {
+		if _, err := os.Lstat(filepath.Join(out, rel)); !os.IsNotExist(err) {
+			t.Errorf("%s was extracted: %v", rel, err)
+		}
+	}
+	want := int64(len(tree["sub/dir/report.pdf"]) + len(tree["sub/dir/notes.txt"]) + len(tree["sub/other/unused.bin"]))
+	if total != want {
+		t.Errorf("progress total = %d, want %d", total, want)
+	}
+}
+
+func TestExtractor_ExtractPath_Errors(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	root := importFixture(t, bs, extractPathTree(), nil).RootCid
+
+	tests := []struct {
+		name  string
+		inner string
+		want  error
+	}{
+		{"missing entry", "sub/dir/missing.pdf", ErrPathNotFound},
+		{"missing directory", "nope/report.pdf", ErrPathNotFound},
+		{"below a file", "a.txt/child", ErrPathNotFound},
+		{"traversal", "sub/../../etc/passwd", ErrPathTraversalAttempt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := t.TempDir()
+			err := NewExtractor(bs, root, out).ExtractPath(context.Background(), tt.inner, false)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("ExtractPath error = %v, want %v", err, tt.want)
+			}
+			var pathErr *PathError
+			if tt.want == ErrPathNotFound && (!errors.As(err, &pathErr) || pathErr.Path == "") {
+				t.Errorf("error %v is not a *PathError with the path", err)
+			}
+			entries, _ := os.ReadDir(out)
+			if len(entries) != 0 {
+				t.Errorf("entries written after a failed resolve: %v", entries)
+			}
+		})
+	}
+}
@@ -1,6 +1,11 @@
 // Package extractor provides functionality for extracting files from IPFS DAG nodes
 // to the local file system. It supports atomic writes, progress tracking, and handles
-// various file types including regular files, directories, and symlinks.
+// various file types including regular files, directories, and symlinks. Entries
+// can also be written to any other Destination, such as MemoryDestination, and
+// a Selector restricts the extraction to part of the tree. ExtractPath writes
+// a single file or subtree found by its path below the root.
+// ExtractTarStream writes a tree as a tar archive instead, and
+// ExtractToWriter streams the content of a single file.
 //
 // The extractor ensures safe extraction by:
 //   - Preventing path traversal attacks
@@ -20,18 +25,22 @@ package extractor
 import (
 	"context"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ipfs/boxo/blockservice"
 	"github.com/ipfs/boxo/blockstore"
 	"github.com/ipfs/boxo/files"
 	"github.com/ipfs/boxo/ipld/merkledag"
-	unixfile "github.com/ipfs/boxo/ipld/unixfs/file"
-	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/tragoedia0722/repository/pkg/clock"
+	"github.com/tragoedia0722/repository/pkg/tracing"
+	"github.com/tragoedia0722/repository/pkg/xattr"
 )
 
 // Error variables are defined in errors.go
@@ -47,7 +56,53 @@ type Extractor struct {
 	basePath   string                // Base path for security validation
 	trackerMu  sync.RWMutex          // Protects tracker access
 	tracker    *progressTracker      // Progress tracking and interruption state
+	progressMu sync.Mutex            // Serializes progress updates of files written concurrently
 	bufferPool sync.Pool             // Buffer pool for efficient file writes
+	stateFile  string                // Optional path of the resumable state file
+	revalidate bool                  // Re-check entries recorded in the state file
+	warn       func(error)           // Optional handler for non-fatal problems
+	state      *stateTracker         // Loaded state for the current extraction
+	yieldEvery time.Duration         // Scheduling point interval for write loops; 0 disables
+	yieldSleep time.Duration         // Optional sleep at each scheduling point
+	onYield    yieldFunc             // Called at scheduling points; nil means yieldNow
+	clock      clock.Clock           // Time source for yielding, timings, retry backoff and state flushes
+	finalize   FinalizeHook          // Optional check before a file is renamed into place
+	skipReject bool                  // Continue extraction when the finalize hook rejects a file
+	rejected   []RejectedFile        // Files rejected during the last extraction
+	text       *TextTransform        // Optional newline and BOM transform for text files
+	rewritten  []TransformedFile     // Files changed by the text transform during the last extraction
+	timed      bool                  // Record per-file read and write timings
+	timingMin  int64                 // Minimum size of files listed in the timings; 0 means default
+	timings    *timingCollector      // Timings of the last extraction, nil if disabled
+	dest       Destination           // Where entries are written; nil means the filesystem at path
+	withXattr  bool                  // Re-apply recorded extended attributes
+	metadata   bool                  // Restore recorded modes and modification times
+	xattrMeta  xattr.Metadata        // Attributes recorded by the import, loaded by Extract
+	xattrFail  []XattrFailure        // Attributes that could not be re-applied during the last extraction
+	selector   Selector              // Optional choice of the entries to extract
+	truncated  []TruncatedFile       // Files truncated by the selector during the last extraction
+	checksums  map[string]string     // Expected SHA-256 by slash path; nil disables verification
+	linkDest   string                // Optional reference directory to hard-link unchanged files from
+	linkVerify bool                  // Compare content before linking from linkDest
+	linkStats  LinkDestStats         // Linked and written files of the last extraction
+	retry      *RetryPolicy          // Retry policy for filesystem operations; nil means DefaultRetryPolicy
+	retryStat  RetryStats            // Retries made during the last extraction
+	events     *eventStream          // Events of the next or running extraction, nil if Events was not called
+	dagOrder   bool                  // Process directory entries in DAG order instead of sorted by name
+	policy     ErrorPolicy           // What happens when an entry fails; FailFast by default
+	summary    ExtractSummary        // Outcome of the entries of the last extraction
+	inFlight   map[string]FileState  // Files the loaded state file recorded as in flight
+	readAhead  int                   // Blocks prefetched ahead of the writer; 0 disables read-ahead
+	prefetch   *readAheadBlockstore  // Prefetch buffer of the last extraction, nil if disabled
+	tracer     tracing.Tracer        // Optional tracer; nil disables tracing
+	contentChk ContentCheck          // How same-size existing files are compared before skipping
+	stale      string                // Completed entry the content check found different, replaced next
+	inner      string                // Slash path of the entry ExtractPath extracts; empty for the root
+	verify     bool                  // Hash written files against their UnixFS nodes
+	reverify   bool                  // Also verify same-size existing files instead of trusting their size
+	workers    int                   // Files written at once; 0 or 1 writes them one at a time
+	onEvent    eventCallback         // Callback of WithProgressEvents
+	resume     bool                  // Continue part files left by an interrupted extraction
 }
 
 // NewExtractor creates a new Extractor instance with the given blockstore, CID,
@@ -65,9 +120,21 @@ func NewExtractor(blockStore blockstore.Blockstore, cid string, path string) *Ex
 				return make([]byte, defaultWriteBufferSize)
 			},
 		},
+		clock: clock.Real{},
 	}
 }
 
+// WithClock sets the time source used for the scheduling points of
+// WithYield, the timings of WithTimings, the waits between retries of
+// WithFSRetry and the periodic rewrites of the state file. A nil clock
+// restores the real clock, which is the default; tests pass a
+// clocktest.Fake to control time.
+// Returns the extractor instance for method chaining.
+func (ext *Extractor) WithClock(c clock.Clock) *Extractor {
+	ext.clock = clock.OrReal(c)
+	return ext
+}
+
 // WithProgress sets a callback function that will be called periodically during
 // extraction to report progress. The callback receives the number of bytes completed,
 // total bytes, and the current file being extracted.
@@ -84,55 +151,143 @@ func (ext *Extractor) WithProgress(progressFn progressCallback) *Extractor {
 	return ext
 }
 
+// WithProgressEvents sets a callback receiving detailed progress: the phase
+// of the extraction, the bytes and files extracted so far and the progress
+// of the file being written. It is called when a phase starts, when a file
+// starts and is moved to its final path, as data is written, and one last
+// time with PhaseDone when Extract returns, whether or not it succeeded.
+// Calls never overlap. FilesTotal is only known when the root is a single
+// file.
+//
+// WithProgress is a simpler form reporting only the byte counts; both may be
+// set.
+// Returns the extractor instance for method chaining.
+func (ext *Extractor) WithProgressEvents(fn func(ProgressEvent)) *Extractor {
+	ext.onEvent = fn
+	return ext
+}
+
 // Extract starts the extraction process from the IPFS DAG node specified by the CID.
 // If overwrite is true, existing files will be replaced. Otherwise, extraction will
 // fail if any file already exists.
 //
 // The extraction is performed atomically using temporary .part files, and supports
-// context cancellation for graceful interruption.
+// context cancellation for graceful interruption. Cancellation discards a file
+// whose data is still being written, removing its part file, but never one
+// whose last byte was written: such a file is finalized (synced, checked by
+// the finalize hook and renamed into place) without regard to ctx, within
+// finalizeTimeout, and recorded as completed in the state file.
 func (ext *Extractor) Extract(ctx context.Context, overwrite bool) error {
-	bs := blockservice.New(ext.blockStore, nil)
+	var span tracing.Span
+	if ext.tracer != nil {
+		ctx, span = ext.tracer.StartSpan(ctx, tracing.SpanExtract)
+	}
+	err := ext.extract(ctx, overwrite)
+	ext.reportPhase(PhaseDone)
+	if span != nil {
+		span.SetAttributes(tracing.String(tracing.AttrCID, ext.cid), tracing.Int(tracing.AttrRetries, ext.retryStat.Retries))
+		if ext.inner != "" {
+			span.SetAttributes(tracing.String(tracing.AttrPath, ext.inner))
+		}
+		tracing.End(span, err)
+	}
+	ext.events.finish(err)
+	ext.events = nil
+	return err
+}
+
+// extract performs the extraction.
+func (ext *Extractor) extract(ctx context.Context, overwrite bool) error {
+	ext.trackerMu.Lock()
+	if ext.tracker == nil {
+		ext.tracker = newProgressTracker(0, nil)
+	}
+	ext.tracker.resetFiles(ext.onEvent)
+	ext.trackerMu.Unlock()
+
+	ext.phase(PhaseResolving)
+	var store blockstore.Blockstore = ext.blockStore
+	ext.prefetch = nil
+	if ext.readAhead > 0 {
+		ext.prefetch = newReadAheadBlockstore(ext.blockStore, ext.readAhead)
+		store = ext.prefetch
+	}
+	bs := blockservice.New(store, nil)
 	ds := merkledag.NewDAGService(bs)
 
-	c, err := cid.Parse(ext.cid)
+	fileNode, size, err := openPath(ctx, ds, ext.cid, ext.inner)
 	if err != nil {
 		return err
 	}
 
-	node, err := ds.Get(ctx, c)
-	if err != nil {
-		return err
+	ext.rejected = nil
+	ext.rewritten = nil
+	ext.timings = ext.newTimingCollector()
+	ext.xattrMeta = nil
+	ext.xattrFail = nil
+	ext.truncated = nil
+	ext.linkStats = LinkDestStats{}
+	ext.retryStat = RetryStats{}
+	ext.stale = ""
+	ext.summary = ExtractSummary{}
+	ext.inFlight = nil
+	if ext.withXattr {
+		if ext.xattrMeta, err = loadXattrs(ctx, ds, ext.cid); err != nil {
+			return err
+		}
 	}
 
-	fileNode, err := unixfile.NewUnixfsFile(ctx, ds, node)
-	if err != nil {
-		return err
+	// The size of a selected subset is unknown until it has been traversed
+	if ext.selector != nil && ext.isDir(fileNode) {
+		size = 0
 	}
 
-	size, err := fileNode.Size()
+	ext.trackerMu.Lock()
+	ext.tracker.setTotal(size)
+	if !ext.isDir(fileNode) {
+		ext.tracker.filesTotal = 1
+	}
+	ext.trackerMu.Unlock()
+
+	if ext.stateFile == "" {
+		ext.phase(PhaseExtracting)
+		return ext.extractNode(ctx, fileNode, overwrite)
+	}
+
+	ext.state, err = loadStateTracker(ext.stateFile, ext.stateKey(), ext.clock, ext.warning)
 	if err != nil {
 		return err
 	}
+	ext.inFlight = ext.state.previous
+	defer func() { ext.state = nil }()
 
-	// Initialize progress tracker if not already initialized
-	ext.trackerMu.Lock()
-	if ext.tracker == nil {
-		ext.tracker = newProgressTracker(size, nil)
-	} else {
-		ext.tracker.setTotal(size)
+	ext.phase(PhaseExtracting)
+	if err := ext.extractNode(ctx, fileNode, overwrite); err != nil {
+		if flushErr := ext.state.flush(); flushErr != nil {
+			ext.warning(flushErr)
+		}
+		return err
 	}
-	ext.trackerMu.Unlock()
 
+	return ext.state.remove()
+}
+
+// openRoot resolves rootCid through ds and returns it as a UnixFS node
+// together with its total size.
+func openRoot(ctx context.Context, ds ipld.DAGService, rootCid string) (files.Node, int64, error) {
+	return openPath(ctx, ds, rootCid, "")
+}
+
+// extractNode writes an already resolved root node to the extractor's path.
+func (ext *Extractor) extractNode(ctx context.Context, fileNode files.Node, overwrite bool) error {
 	if !ext.isSubPath(ext.path, ext.basePath) {
 		return ErrPathTraversal
 	}
 
-	err = ext.writeTo(ctx, fileNode, ext.path, overwrite, "")
-	if err != nil {
+	if err := ext.writeTo(ctx, fileNode, ext.path, overwrite, ""); err != nil {
 		return err
 	}
-
-	return nil
+	return ext.partialError()
 }
 
 func (ext *Extractor) updateProgress(size int64, filename string) {
@@ -140,7 +295,48 @@ func (ext *Extractor) updateProgress(size int64, filename string) {
 	defer ext.trackerMu.RUnlock()
 
 	if ext.tracker != nil {
-		ext.tracker.update(size, filename)
+		ext.progressMu.Lock()
+		defer ext.progressMu.Unlock()
+		completed := ext.tracker.updateFile(size, filename)
+		ext.events.progress(completed, ext.tracker.getTotal(), filename)
+	}
+}
+
+// phase announces the next phase of the running extraction.
+func (ext *Extractor) phase(p Phase) {
+	ext.events.send(PhaseChanged{Phase: p})
+	ext.reportPhase(p)
+}
+
+// reportPhase sends the progress event of the phase p.
+func (ext *Extractor) reportPhase(p Phase) {
+	ext.withTracker(func(pt *progressTracker) { pt.setPhase(p) })
+}
+
+// fileStarted reports that the file at relativePath, of size bytes, is
+// being written.
+func (ext *Extractor) fileStarted(relativePath string, size int64) {
+	ext.events.send(FileStarted{Path: relativePath, Size: size})
+	ext.withTracker(func(pt *progressTracker) { pt.startFile(relativePath, size) })
+}
+
+// fileCompleted reports that size bytes were written to the file at
+// relativePath, which is now in its final place.
+func (ext *Extractor) fileCompleted(relativePath string, size int64) {
+	ext.events.send(FileCompleted{Path: relativePath, Size: size})
+	ext.withTracker(func(pt *progressTracker) { pt.finishFile(relativePath) })
+}
+
+// withTracker calls fn with the progress tracker, if any, holding the
+// locks that guard its progress events.
+func (ext *Extractor) withTracker(fn func(*progressTracker)) {
+	ext.trackerMu.RLock()
+	defer ext.trackerMu.RUnlock()
+
+	if ext.tracker != nil {
+		ext.progressMu.Lock()
+		defer ext.progressMu.Unlock()
+		fn(ext.tracker)
 	}
 }
 
@@ -161,9 +357,21 @@ func (*Extractor) isSubPath(path, base string) bool {
 }
 
 func (ext *Extractor) writeTo(ctx context.Context, nd files.Node, path string, allowOverwrite bool, relativePath string) error {
+	allowOverwrite, skipped, err := ext.beginEntry(ctx, nd, path, allowOverwrite, relativePath)
+	if skipped || err != nil {
+		return err
+	}
+	return ext.endEntry(ctx, nd, path, relativePath, ext.writeEntry(ctx, nd, path, allowOverwrite, relativePath))
+}
+
+// beginEntry checks whether the entry at relativePath is to be written and
+// whether it may replace an existing entry. It reports skip for entries a
+// previous run completed and for entries whose check failed, returning the
+// failure unless the error policy continues.
+func (ext *Extractor) beginEntry(ctx context.Context, nd files.Node, path string, allowOverwrite bool, relativePath string) (overwrite, skip bool, err error) {
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
+		return false, false, ctx.Err()
 	default:
 	}
 
@@ -172,62 +380,85 @@ func (ext *Extractor) writeTo(ctx context.Context, nd files.Node, path string, a
 	ext.trackerMu.RUnlock()
 
 	if interrupted {
-		return ErrInterrupted
-	}
-
-	if err := ensureNoSymlinkInPath(ext.basePath, path); err != nil {
-		return err
+		return false, false, ErrInterrupted
 	}
 
-	// Check if path exists and get its info
-	pathInfo, err := getPathInfo(path)
+	// Entries completed by a previous run are skipped before touching the filesystem
+	skipped, err := ext.skipCompleted(ctx, nd, path, relativePath)
 	if err != nil {
-		return err
+		return false, true, ext.entryFailed(ctx, relativePath, err)
 	}
-
-	if pathInfo.exists {
-		if !allowOverwrite {
-			return ErrPathExistsOverwrite
+	if skipped {
+		if !ext.isDir(nd) {
+			ext.summary.Skipped++
 		}
+		return false, true, nil
+	}
 
-		isNodeDir := ext.isDir(nd)
-		nodeSize, err := nd.Size()
-		if err != nil {
-			return fmt.Errorf("failed to get node size: %w", err)
-		}
+	// Entries not yet recorded may have been written after the last state flush
+	if ext.state != nil && ext.state.resumed {
+		allowOverwrite = true
+	}
+	return allowOverwrite, false, nil
+}
 
-		// Check if we should skip this existing file (only for regular files with same size)
-		if shouldSkipExistingFile(pathInfo.FileInfo, nodeSize, isNodeDir) {
-			// Update progress and skip extraction
-			ext.updateProgress(nodeSize, relativePath)
+// endEntry completes the entry at relativePath after it was written with
+// the error err: a written entry gets its attributes and is recorded in the
+// state file, a failed one is handled as the error policy says.
+func (ext *Extractor) endEntry(ctx context.Context, nd files.Node, path, relativePath string, err error) error {
+	if err != nil {
+		// Rejected files are left out of the state so a later run retries them
+		if ext.skipRejected(relativePath, err) {
+			ext.summary.Skipped++
 			return nil
 		}
+		// Failed entries are left out as well
+		return ext.entryFailed(ctx, relativePath, err)
+	}
+	ext.applyXattrs(path, relativePath)
+	if err := ext.applyMetadata(nd, path, relativePath); err != nil {
+		return ext.entryFailed(ctx, relativePath, err)
+	}
 
-		// For existing directories that match node directories, merge contents (do nothing)
-		// For everything else, remove the existing path
-		if !(pathInfo.IsDir() && isNodeDir) {
-			if err := removePath(path); err != nil {
-				return err
-			}
-		}
+	return ext.state.markCompleted(relativePath)
+}
+
+// writeEntry writes a single node, replacing or merging with an existing path as allowed.
+func (ext *Extractor) writeEntry(ctx context.Context, nd files.Node, path string, allowOverwrite bool, relativePath string) error {
+	done, err := ext.clearEntry(ctx, nd, allowOverwrite, relativePath)
+	if done || err != nil {
+		return err
 	}
 
+	dst := ext.destination()
+	rel := destPath(relativePath)
 	switch node := nd.(type) {
 	case *files.Symlink:
 		target := node.Target
 		if !ext.isValidSymlinkTarget(target) {
 			return wrapInvalidSymlinkTarget(target)
 		}
-		return os.Symlink(target, path)
+		links, ok := dst.(SymlinkDestination)
+		if !ok {
+			return wrapSymlinkUnsupported(path)
+		}
+		if err := links.Symlink(target, rel); err != nil {
+			return err
+		}
+		ext.summary.Written++
+		return nil
 
 	case files.File:
 		return ext.writeFileWithBuffer(ctx, node, path, relativePath)
 
 	case files.Directory:
-		if err := os.MkdirAll(path, dirPermissions); err != nil {
+		if err := ext.retryFS(ctx, "mkdir", relativePath, func(int) error { return dst.Mkdir(rel) }); err != nil {
+			return err
+		}
+		entries, err := ext.entries(ctx, node)
+		if err != nil {
 			return err
 		}
-		entries := node.Entries()
 		return ext.processDirectory(ctx, entries, path, allowOverwrite, relativePath)
 
 	default:
@@ -235,7 +466,64 @@ func (ext *Extractor) writeTo(ctx context.Context, nd files.Node, path string, a
 	}
 }
 
+// clearEntry makes room for the node nd at relativePath: an existing entry
+// is removed, merged with if both are directories, or kept if it is a file
+// the content check finds unchanged, in which case done is set.
+func (ext *Extractor) clearEntry(ctx context.Context, nd files.Node, allowOverwrite bool, relativePath string) (done bool, err error) {
+	dst := ext.destination()
+	rel := destPath(relativePath)
+
+	// Check if path exists and get its info
+	pathInfo, err := statEntry(dst, rel)
+	if err != nil {
+		return false, err
+	}
+	if !pathInfo.exists {
+		return false, nil
+	}
+	if !allowOverwrite {
+		return false, ErrPathExistsOverwrite
+	}
+
+	isNodeDir := ext.isDir(nd)
+	nodeSize, err := nd.Size()
+	if err != nil {
+		return false, fmt.Errorf("failed to get node size: %w", err)
+	}
+
+	// Check if we should skip this existing file (only for regular files
+	// with same size whose content passes the content check)
+	if shouldSkipExistingFile(pathInfo.FileInfo, nodeSize, isNodeDir) {
+		same, err := ext.sameAsExisting(ctx, nd, relativePath, nodeSize)
+		if err != nil {
+			return false, err
+		}
+		if same {
+			// Update progress and skip extraction
+			ext.updateProgress(nodeSize, relativePath)
+			ext.summary.Skipped++
+			return true, nil
+		}
+		ext.summary.Replaced++
+	}
+
+	// For existing directories that match node directories, merge contents (do nothing)
+	// For everything else, remove the existing path
+	if !(pathInfo.IsDir() && isNodeDir) {
+		if err := dst.Remove(rel); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
 func (*Extractor) createPartFile(finalPath string) (*os.File, string, error) {
+	return createPartFile(finalPath)
+}
+
+// createPartFile creates the part file for finalPath, replacing a stale one
+// left by an earlier run.
+func createPartFile(finalPath string) (*os.File, string, error) {
 	dir := filepath.Dir(finalPath)
 	if err := os.MkdirAll(dir, dirPermissions); err != nil {
 		return nil, "", err
@@ -263,68 +551,276 @@ func (*Extractor) createPartFile(finalPath string) (*os.File, string, error) {
 }
 
 func (ext *Extractor) writeFileWithBuffer(ctx context.Context, node files.File, path string, relativePath string) error {
-	tmpF, tmpPath, err := ext.createPartFile(path)
+	w, err := ext.startFileWrite(ctx, node, relativePath)
+	if w == nil {
+		return err
+	}
+	stopReadAhead := ext.startReadAhead(w.ctx, node)
+	err = ext.writeFileData(w)
+	stopReadAhead()
+	return ext.finishFileWrite(w, err)
+}
+
+// fileWrite is a file being written to its part file.
+type fileWrite struct {
+	ctx      context.Context // Context of the file, carrying its span
+	node     files.File
+	rel      string // Path relative to the extraction root
+	span     *fileSpan
+	part     *partWrite // Data written by the last attempt
+	retries  RetryStats // Retries of the write, merged into the extraction's by finishFileWrite
+	baseline int        // Retries of the extraction when the data started
+}
+
+// startFileWrite starts writing node to relativePath. It returns nil if
+// the file was linked from the reference directory, or could be neither
+// linked nor written, and the error in the latter case.
+func (ext *Extractor) startFileWrite(ctx context.Context, node files.File, relativePath string) (*fileWrite, error) {
+	ctx, span := ext.startFileSpan(ctx, relativePath)
+
+	if ext.linkDest != "" {
+		linked, err := ext.linkFromDest(ctx, node, relativePath)
+		if err != nil {
+			span.end(&err)
+			return nil, err
+		}
+		if linked {
+			span.done(0, true)
+			span.end(&err)
+			ext.summary.Written++
+			return nil, nil
+		}
+	}
+
+	ext.state.setFileState(relativePath, FileWriting)
+	return &fileWrite{ctx: ctx, node: node, rel: relativePath, span: span, baseline: ext.retryStat.Retries}, nil
+}
+
+// writeFileData writes the data of the file to its part file, starting
+// over as the retry policy allows. It touches no state of the extraction
+// but the progress, so files may be written concurrently.
+func (ext *Extractor) writeFileData(w *fileWrite) error {
+	started := false
+	return ext.retryInto(w.ctx, &w.retries, "write", w.rel, func(attempt int) error {
+		if attempt > 1 {
+			// Start over instead of resuming a part file in an unknown state
+			if w.part.reported > 0 {
+				ext.updateProgress(-w.part.reported, w.rel)
+			}
+			if _, err := w.node.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+		}
+		var err error
+		w.part, err = ext.writePart(w.ctx, w.node, w.rel, &started, attempt == 1)
+		return err
+	})
+}
+
+// finishFileWrite completes the file after its data was written with the
+// error err: the part file is checked and renamed into place, and the file
+// is recorded in the results of the extraction.
+func (ext *Extractor) finishFileWrite(w *fileWrite, err error) error {
+	w.span.skipRetries(ext.retryStat.Retries - w.baseline)
+	ext.retryStat.add(w.retries)
+	defer w.span.end(&err)
 	if err != nil {
 		return err
 	}
 
+	dest := ext.destination()
+	rel := destPath(w.rel)
+	relativePath := w.rel
+	part := w.part
+	written, tw := part.written, part.tw
+
+	// Every byte is written: finalizing is a critical section that cancellation
+	// does not interrupt, bounded by finalizeTimeout instead
+	ext.state.setFileState(relativePath, FileFinalizing)
+	fctx, cancel := context.WithTimeout(context.WithoutCancel(w.ctx), finalizeTimeout)
+	defer cancel()
+
+	// A transformed file no longer matches the checksum of its source
+	if tw == nil || !tw.changed {
+		if err = ext.verifyChecksum(relativePath, part.digest); err != nil {
+			_ = dest.Remove(rel)
+			return err
+		}
+	}
+
+	if err = ext.runFinalizeHook(fctx, dest, rel, relativePath, written); err != nil {
+		_ = dest.Remove(rel)
+		return err
+	}
+
+	if err = ext.retryFS(fctx, "rename", relativePath, func(int) error { return dest.Finalize(rel) }); err != nil {
+		_ = dest.Remove(rel)
+		return err
+	}
+	ext.fileCompleted(relativePath, written)
+	w.span.done(written, false)
+	ext.summary.Written++
+	if ext.linkDest != "" {
+		ext.linkStats.Written++
+	}
+
+	if part.timer != nil {
+		ext.timings.add(relativePath, part.timer)
+	}
+
+	if tw != nil && tw.changed {
+		ext.rewritten = append(ext.rewritten, TransformedFile{
+			Path:        relativePath,
+			SourceSize:  part.source,
+			WrittenSize: written,
+		})
+	}
+
+	return nil
+}
+
+// partWrite is the outcome of writing a file's data to its part file.
+type partWrite struct {
+	written  int64       // Bytes written to the destination
+	source   int64       // Bytes read from the node
+	reported int64       // Bytes reported as progress, also after a failure
+	digest   hash.Hash   // Hash of the written data; nil without a checksum to verify
+	tw       *textWriter // Text transform applied to the data, nil if none
+	timer    *fileTimer  // Read and write timings, nil if disabled
+}
+
+// writePart writes the data of node to a new part file, reading it from the
+// node's current position, and closes it. If resume is set, the part file
+// an interrupted extraction left may be continued instead, see WithResume.
+// FileStarted is sent once the part file exists unless started is already
+// set. On failure the part file is removed unless it is kept for resuming,
+// and the returned partWrite still tells the progress reported.
+func (ext *Extractor) writePart(ctx context.Context, node files.File, relativePath string, started *bool, resume bool) (*partWrite, error) {
+	dest := ext.destination()
+	rel := destPath(relativePath)
+	part := &partWrite{}
+
+	var tmpF io.WriteCloser
+	var kept int64
+	var err error
+	if resume {
+		if tmpF, kept, err = ext.resumePart(ctx, node, relativePath); err != nil {
+			return part, err
+		}
+	}
+	if tmpF == nil {
+		if tmpF, err = dest.CreateFile(rel); err != nil {
+			return part, err
+		}
+	}
+	if !*started {
+		*started = true
+		size, _ := node.Size()
+		ext.fileStarted(relativePath, size)
+	}
+
 	var retErr error
 	defer func() {
 		if tmpF != nil {
 			_ = tmpF.Close()
 		}
-		if retErr != nil {
-			_ = os.Remove(tmpPath)
+		if retErr != nil && !ext.keepsPart(retErr) {
+			_ = dest.Remove(rel)
 		}
 	}()
 
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
+		return part, ctx.Err()
 	default:
 	}
 
+	// The data kept from an interrupted extraction counts as extracted
+	if kept > 0 {
+		part.reported += kept
+		ext.updateProgress(kept, relativePath)
+	}
+
+	size, err := node.Size()
+	if err != nil {
+		size = -1
+	} else {
+		size -= kept
+	}
 	pr := &extractReader{
 		r: node,
 		onProgress: func(n int64) {
+			part.reported += n
 			ext.updateProgress(n, relativePath)
 		},
+		ctx:   ctx,
+		yield: newYielder(ext.clock, ext.yieldEvery, ext.yieldSleep, ext.onYield),
+		size:  size,
 	}
 
 	buf := ext.bufferPool.Get().([]byte)
 	defer ext.bufferPool.Put(buf)
 
-	_, copyErr := io.CopyBuffer(tmpF, pr, buf)
+	// The checksum covers the bytes reaching the destination, below any transform
+	dst, digest := ext.checksumWriter(tmpF, relativePath)
+	var tw *textWriter
+	if ext.text != nil {
+		tw = newTextWriter(dst, ext.text, relativePath)
+		dst = tw
+	}
+	if ext.timings != nil {
+		pr.timer = newFileTimer(ext.clock)
+		dst = &timedWriter{w: dst, timer: pr.timer}
+	}
+
+	// Verification covers the data read from the DAG, above any transform
+	src, err := ext.verifyReader(ctx, pr, node, relativePath)
+	if err != nil {
+		retErr = err
+		return part, retErr
+	}
+
+	written, copyErr := io.CopyBuffer(dst, src, buf)
 	if copyErr != nil {
 		retErr = copyErr
-		return retErr
+		return part, retErr
+	}
+
+	sourceSize := written
+	if tw != nil {
+		if err = tw.Close(); err != nil {
+			retErr = err
+			return part, retErr
+		}
+		written = tw.written
 	}
 
 	// Flush any remaining progress
 	if pr.bytesSinceUpdate > 0 {
+		part.reported += pr.bytesSinceUpdate
 		ext.updateProgress(pr.bytesSinceUpdate, relativePath)
 	}
 
-	if err = tmpF.Sync(); err != nil {
-		retErr = err
-		return retErr
-	}
-
-	if err = tmpF.Close(); err != nil {
-		retErr = err
-		return retErr
-	}
+	// Closing syncs the data, which is charged as write time
+	err = tmpF.Close()
 	tmpF = nil
-
-	if err = os.Rename(tmpPath, path); err != nil {
+	if err != nil {
 		retErr = err
-		return retErr
+		return part, retErr
+	}
+	if pr.timer != nil {
+		pr.timer.afterWrite()
 	}
 
-	return nil
+	part.written, part.source = kept+written, kept+sourceSize
+	part.digest, part.tw, part.timer = digest, tw, pr.timer
+	return part, nil
 }
 
 func (ext *Extractor) processDirectory(ctx context.Context, entries files.DirIterator, path string, allowOverwrite bool, relativePath string) error {
+	queue := ext.newFileQueue(ctx)
+	defer queue.stop()
+
 	for entries.Next() {
 		select {
 		case <-ctx.Done():
@@ -334,13 +830,22 @@ func (ext *Extractor) processDirectory(ctx context.Context, entries files.DirIte
 
 		entryName := entries.Name()
 		if entryName == "" || entryName == "." || entryName == ".." {
-			return wrapInvalidDirectoryEntry(entryName)
+			if err := ext.entryFailed(ctx, rawEntryPath(relativePath, entryName), wrapInvalidDirectoryEntry(entryName)); err != nil {
+				return err
+			}
+			continue
+		}
+		if isXattrMetadata(relativePath, entryName) {
+			continue
 		}
 
 		// Normalize the entry name (handles both simple names and backslash paths)
 		cleanedName, err := normalizeEntryName(entryName)
 		if err != nil {
-			return err
+			if err := ext.entryFailed(ctx, rawEntryPath(relativePath, entryName), err); err != nil {
+				return err
+			}
+			continue
 		}
 
 		// Build child paths
@@ -348,22 +853,71 @@ func (ext *Extractor) processDirectory(ctx context.Context, entries files.DirIte
 		childPath = filepath.Join(path, cleanedName)
 		childRelPath = filepath.Join(relativePath, cleanedName)
 
+		// Pruned entries are skipped before anything below them is loaded
+		node := entries.Node()
+		entryNode, truncated, selected, err := ext.selectEntry(node, childRelPath)
+		if u, ok := node.(*unreadableNode); ok && (selected || err != nil) {
+			err = wrapReadFailed(childRelPath, u.err)
+		}
+		if err != nil {
+			if err := ext.entryFailed(ctx, childRelPath, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if !selected {
+			continue
+		}
+		if truncated != nil {
+			childPath += partialFileSuffix
+			childRelPath += partialFileSuffix
+		}
+
+		// Files with a plain name are written concurrently; anything else
+		// waits for the pending files
+		nested := strings.Contains(cleanedName, string(filepath.Separator))
+		file, queued := queue.accepts(entryNode)
+		queued = queued && !nested
+		if !queued {
+			if err := queue.drain(); err != nil {
+				return err
+			}
+		}
+
 		// If the cleaned name contains path separators (nested path from backslash handling),
 		// create parent directories to ensure they exist before writing the file
-		if strings.Contains(cleanedName, string(filepath.Separator)) {
+		if nested {
 			parentDir := filepath.Dir(childPath)
-			if err := os.MkdirAll(parentDir, dirPermissions); err != nil {
-				return wrapMkdirFailed(parentDir, err)
+			parentRel := filepath.Dir(childRelPath)
+			if err := ext.retryFS(ctx, "mkdir", parentRel, func(int) error {
+				return ext.destination().Mkdir(destPath(parentRel))
+			}); err != nil {
+				if err := ext.entryFailed(ctx, childRelPath, wrapMkdirFailed(parentDir, err)); err != nil {
+					return err
+				}
+				continue
 			}
 		}
 
-		entryNode := entries.Node()
+		if queued {
+			if err := queue.add(entryNode, file, childPath, allowOverwrite, childRelPath, truncated); err != nil {
+				return err
+			}
+			continue
+		}
 
+		failed := len(ext.summary.Failed)
 		if err := ext.writeTo(ctx, entryNode, childPath, allowOverwrite, childRelPath); err != nil {
 			return err
 		}
+		if truncated != nil && len(ext.summary.Failed) == failed {
+			ext.truncated = append(ext.truncated, *truncated)
+		}
 	}
 
+	if err := queue.drain(); err != nil {
+		return err
+	}
 	return entries.Err()
 }
 
@@ -389,10 +943,27 @@ type extractReader struct {
 	r                io.Reader
 	onProgress       func(int64)
 	bytesSinceUpdate int64
+	ctx              context.Context // Checked at scheduling points
+	yield            *yielder        // Optional; nil disables scheduling points
+	timer            *fileTimer      // Optional; nil disables timing
+	size             int64           // Bytes expected from r, negative if unknown
+	read             int64           // Bytes read from r so far
 }
 
 func (pr *extractReader) Read(p []byte) (n int, err error) {
+	// Once every expected byte is read only EOF remains, and cancellation
+	// must not discard the complete data
+	if pr.size < 0 || pr.read < pr.size {
+		if err := pr.yield.maybeYield(pr.ctx); err != nil {
+			return 0, err
+		}
+	}
+
 	n, err = pr.r.Read(p)
+	pr.read += int64(n)
+	if pr.timer != nil {
+		pr.timer.afterRead(n)
+	}
 	if n > 0 && pr.onProgress != nil {
 		pr.bytesSinceUpdate += int64(n)
 		if pr.bytesSinceUpdate >= progressUpdateThreshold {
@@ -403,3 +974,12 @@ func (pr *extractReader) Read(p []byte) (n int, err error) {
 
 	return
 }
+
+// rawEntryPath returns the path of an entry whose name could not be
+// normalized, for reporting its failure.
+func rawEntryPath(relativePath, entryName string) string {
+	if relativePath == "" {
+		return entryName
+	}
+	return relativePath + string(filepath.Separator) + entryName
+}
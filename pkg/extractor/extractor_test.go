@@ -1,6 +1,7 @@
 package extractor
 
 import (
+	"bytes"
 	"context"
 	"os"
 	"path/filepath"
@@ -12,26 +13,41 @@ import (
 	"github.com/tragoedia0722/repository/pkg/repository"
 )
 
-// createTestBlockstore creates a test blockstore using repository
+// createTestBlockstore creates a test blockstore backed by an in-memory repository
 func createTestBlockstore(t *testing.T) (blockstore.Blockstore, func()) {
-	tmpDir, err := os.MkdirTemp("", "extractor-test-*")
-	if err != nil {
-		t.Fatalf("failed to create temp dir: %v", err)
+	repo := repository.NewMemoryRepository()
+	cleanup := func() {
+		repo.Close()
 	}
+	return repo.BlockStore(), cleanup
+}
 
-	// Use repository package which properly handles blockstore
-	repo, err := repository.NewRepository(tmpDir)
-	if err != nil {
-		os.RemoveAll(tmpDir)
-		t.Fatalf("failed to create repository: %v", err)
+// importFixture writes tree, keyed by slash path, below a temporary
+// directory and imports it into bs. configure, if not nil, sets up the
+// importer first.
+func importFixture(t *testing.T, bs blockstore.Blockstore, tree map[string][]byte, configure func(*importer.Importer)) *importer.Result {
+	t.Helper()
+
+	dir := t.TempDir()
+	for rel, data := range tree {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
 	}
 
-	cleanup := func() {
-		repo.Close()
-		os.RemoveAll(tmpDir)
+	imp := importer.NewImporter(bs, dir)
+	if configure != nil {
+		configure(imp)
 	}
-
-	return repo.BlockStore(), cleanup
+	result, err := imp.Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	return result
 }
 
 // importTestFiles creates and imports test files, returning the root CID
@@ -928,3 +944,38 @@ func TestExtractor_createPartFile(t *testing.T) {
 		}
 	})
 }
+
+func TestExtractor_ChunkSizes(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	src := t.TempDir()
+	data := readAheadData(1<<20 + 123)
+	if err := os.WriteFile(filepath.Join(src, "data.bin"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var roots []string
+	for _, size := range []int64{importer.MinChunkSize, importer.MaxChunkSize} {
+		result, err := importer.NewImporter(bs, src).WithChunkSize(size).Import(context.Background())
+		if err != nil {
+			t.Fatalf("size %d: Import failed: %v", size, err)
+		}
+		roots = append(roots, result.RootCid)
+
+		out := t.TempDir()
+		if err := NewExtractor(bs, result.RootCid, out).Extract(context.Background(), false); err != nil {
+			t.Fatalf("size %d: Extract failed: %v", size, err)
+		}
+		got, err := os.ReadFile(filepath.Join(out, "data.bin"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("size %d: extracted content differs", size)
+		}
+	}
+	if roots[0] == roots[1] {
+		t.Errorf("both chunk sizes produced root %s", roots[0])
+	}
+}
@@ -1,6 +1,8 @@
 package extractor
 
 import (
+	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
 )
@@ -25,6 +27,18 @@ func getPathInfo(path string) (info fileInfo, err error) {
 	return fileInfo{}, err
 }
 
+// statEntry is getPathInfo for relPath in a destination.
+func statEntry(dst Destination, relPath string) (fileInfo, error) {
+	fi, err := dst.Stat(relPath)
+	if err == nil {
+		return fileInfo{FileInfo: fi, exists: true}, nil
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return fileInfo{exists: false}, nil
+	}
+	return fileInfo{}, err
+}
+
 // shouldSkipExistingFile checks if an existing file should be skipped during extraction.
 // Returns true if the file exists, is a regular file, has the same size as the node.
 // For directories, returns false to allow merging of directory contents.
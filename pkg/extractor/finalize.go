@@ -0,0 +1,75 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+)
+
+// FinalizeHook inspects a fully written and synced part file before it is
+// renamed to its final path. finalRelPath is the file's path relative to the
+// extraction root and size is the number of bytes written. Returning an error
+// rejects the file: the part file is removed and nothing appears at the final
+// path. partPath is empty when extracting to a Destination other than the
+// filesystem.
+type FinalizeHook func(ctx context.Context, partPath string, finalRelPath string, size int64) error
+
+// RejectedFile is a file rejected by the finalize hook or by checksum
+// verification.
+type RejectedFile struct {
+	Path string // Path relative to the extraction root
+	Err  error  // Error returned by the hook wrapped in ErrFileRejected, or a *ChecksumMismatchError
+}
+
+// WithFinalizeHook sets a hook called for every extracted file after its data
+// is written and before it becomes visible at its final path, for example to
+// run a content scanner. The hook receives the extraction context and is not
+// called for files that are skipped, such as existing files of the same size
+// or entries completed by a previous run.
+//
+// By default a rejected file fails the extraction with an error wrapping
+// ErrFileRejected; see WithSkipRejected.
+// Returns the extractor instance for method chaining.
+func (ext *Extractor) WithFinalizeHook(hook FinalizeHook) *Extractor {
+	ext.finalize = hook
+	return ext
+}
+
+// WithSkipRejected makes extraction continue when the finalize hook rejects a
+// file or a file fails checksum verification. Rejected files are reported by
+// Rejected and retried by a resumed extraction. Returns the extractor instance for method chaining.
+func (ext *Extractor) WithSkipRejected(skip bool) *Extractor {
+	ext.skipReject = skip
+	return ext
+}
+
+// Rejected returns the files rejected by the finalize hook or by checksum
+// verification during the last extraction when WithSkipRejected is set.
+func (ext *Extractor) Rejected() []RejectedFile {
+	return ext.rejected
+}
+
+// runFinalizeHook calls the finalize hook, if any, for a file written to dst
+// at rel but not yet finalized.
+func (ext *Extractor) runFinalizeHook(ctx context.Context, dst Destination, rel, relativePath string, size int64) error {
+	if ext.finalize == nil {
+		return nil
+	}
+	var partPath string
+	if fsDst, ok := dst.(*fsDestination); ok {
+		partPath = fsDst.partPath(rel)
+	}
+	if err := ext.finalize(ctx, partPath, relativePath, size); err != nil {
+		return wrapFileRejected(relativePath, err)
+	}
+	return nil
+}
+
+// skipRejected reports whether err is a rejection that should not stop the
+// extraction, recording the file if so.
+func (ext *Extractor) skipRejected(relativePath string, err error) bool {
+	if !ext.skipReject || !(errors.Is(err, ErrFileRejected) || errors.Is(err, ErrChecksumMismatch)) {
+		return false
+	}
+	ext.rejected = append(ext.rejected, RejectedFile{Path: relativePath, Err: err})
+	return true
+}
@@ -0,0 +1,163 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/tragoedia0722/repository/pkg/importer"
+)
+
+var infectedMarker = []byte("X5O!P%@AP")
+
+// importScanFixture imports clean and infected files and returns the root CID.
+func importScanFixture(t *testing.T, bs blockstore.Blockstore) string {
+	t.Helper()
+
+	src := t.TempDir()
+	fixture := map[string][]byte{
+		"clean.txt":         []byte("nothing to see here"),
+		"infected.txt":      append([]byte("prefix "), infectedMarker...),
+		"sub/clean2.txt":    []byte("also clean"),
+		"sub/infected2.bin": append(infectedMarker, []byte(" suffix")...),
+	}
+	for rel, content := range fixture {
+		path := filepath.Join(src, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", rel, err)
+		}
+	}
+
+	result, err := importer.NewImporter(bs, src).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	return result.RootCid
+}
+
+// scanHook rejects part files containing infectedMarker and records the files it saw.
+func scanHook(t *testing.T, seen *[]string) FinalizeHook {
+	return func(ctx context.Context, partPath, finalRelPath string, size int64) error {
+		*seen = append(*seen, finalRelPath)
+
+		data, err := os.ReadFile(partPath)
+		if err != nil {
+			t.Errorf("hook could not read part file: %v", err)
+			return err
+		}
+		if int64(len(data)) != size {
+			t.Errorf("%s: hook got size %d, part file has %d bytes", finalRelPath, size, len(data))
+		}
+		if bytes.Contains(data, infectedMarker) {
+			return errors.New("malware signature found")
+		}
+		return nil
+	}
+}
+
+func TestExtractor_FinalizeHook_SkipRejected(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	root := importScanFixture(t, bs)
+	out := t.TempDir()
+
+	var seen []string
+	ext := NewExtractor(bs, root, out).WithFinalizeHook(scanHook(t, &seen)).WithSkipRejected(true)
+	if err := ext.Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	if len(seen) != 4 {
+		t.Errorf("expected hook to see 4 files, saw %v", seen)
+	}
+
+	rejected := map[string]bool{}
+	for _, r := range ext.Rejected() {
+		if !errors.Is(r.Err, ErrFileRejected) {
+			t.Errorf("%s: expected ErrFileRejected, got %v", r.Path, r.Err)
+		}
+		rejected[r.Path] = true
+	}
+	infected := []string{"infected.txt", filepath.Join("sub", "infected2.bin")}
+	if len(rejected) != len(infected) {
+		t.Errorf("expected %d rejected files, got %v", len(infected), ext.Rejected())
+	}
+
+	for _, rel := range infected {
+		if !rejected[rel] {
+			t.Errorf("expected %s to be rejected", rel)
+		}
+		if _, err := os.Lstat(filepath.Join(out, rel)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be absent, got %v", rel, err)
+		}
+		if _, err := os.Lstat(filepath.Join(out, rel) + partFileSuffix); !os.IsNotExist(err) {
+			t.Errorf("expected part file of %s to be removed, got %v", rel, err)
+		}
+	}
+
+	for rel, want := range map[string]string{
+		"clean.txt":                        "nothing to see here",
+		filepath.Join("sub", "clean2.txt"): "also clean",
+	} {
+		got, err := os.ReadFile(filepath.Join(out, rel))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", rel, err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: expected %q, got %q", rel, want, got)
+		}
+	}
+
+	// Existing files of the same size are skipped without calling the hook
+	seen = nil
+	ext = NewExtractor(bs, root, out).WithFinalizeHook(scanHook(t, &seen)).WithSkipRejected(true)
+	if err := ext.Extract(context.Background(), true); err != nil {
+		t.Fatalf("second Extract failed: %v", err)
+	}
+	for _, rel := range seen {
+		if !rejected[rel] {
+			t.Errorf("hook called for skipped file %s", rel)
+		}
+	}
+}
+
+func TestExtractor_FinalizeHook_FailsByDefault(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	root := importScanFixture(t, bs)
+
+	var seen []string
+	err := NewExtractor(bs, root, t.TempDir()).WithFinalizeHook(scanHook(t, &seen)).Extract(context.Background(), false)
+	if !errors.Is(err, ErrFileRejected) {
+		t.Fatalf("expected ErrFileRejected, got %v", err)
+	}
+}
+
+func TestExtractor_FinalizeHook_Context(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	root := importScanFixture(t, bs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hook := func(hookCtx context.Context, partPath, finalRelPath string, size int64) error {
+		cancel()
+		return hookCtx.Err()
+	}
+
+	err := NewExtractor(bs, root, t.TempDir()).WithFinalizeHook(hook).Extract(ctx, false)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected hook context to be cancelled with the extraction, got %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ipfs/boxo/files"
+)
+
+// linkCompareBufferSize is the chunk size used to compare a file with its
+// reference copy.
+const linkCompareBufferSize = 64 * 1024
+
+// LinkDestStats summarizes the use of the reference directory set by
+// WithLinkDest during an extraction.
+type LinkDestStats struct {
+	Linked     int   // Files hard-linked from the reference directory
+	Written    int   // Files written because no identical reference file could be linked
+	BytesSaved int64 // Total size of the linked files
+}
+
+// WithLinkDest makes the extractor hard-link files from refDir instead of
+// writing them, like rsync's --link-dest. A file is linked when refDir holds
+// a regular file at the same relative path with the same size; see
+// WithLinkDestVerify to also compare the content. When linking fails, for
+// example because refDir is on another filesystem, the file is written
+// normally.
+//
+// Linked files share their inode, permissions and extended attributes with
+// the reference file, so changing one changes the other. Symlinks and
+// directories are never linked, and nothing is linked when extracting to a
+// Destination other than the filesystem or with a text transform. Linked
+// files are not checked against WithChecksumVerify unless
+// WithLinkDestVerify is set.
+// Returns the extractor instance for method chaining.
+func (ext *Extractor) WithLinkDest(refDir string) *Extractor {
+	ext.linkDest = refDir
+	return ext
+}
+
+// WithLinkDestVerify makes WithLinkDest link only files whose content is
+// identical to the reference file. The reference file is hashed when
+// WithChecksumVerify supplies a checksum for the file; otherwise the file's
+// data is read and compared with it, which saves the write but not the read.
+// Returns the extractor instance for method chaining.
+func (ext *Extractor) WithLinkDestVerify(verify bool) *Extractor {
+	ext.linkVerify = verify
+	return ext
+}
+
+// LinkDestStats returns how many files the last extraction linked from the
+// reference directory and how many it wrote.
+func (ext *Extractor) LinkDestStats() LinkDestStats {
+	return ext.linkStats
+}
+
+// linkFromDest hard-links the reference copy of node into place. It reports
+// false, leaving node unread, when the file has to be written instead.
+func (ext *Extractor) linkFromDest(ctx context.Context, node files.File, relativePath string) (bool, error) {
+	dest, ok := ext.destination().(*fsDestination)
+	if !ok || ext.text != nil {
+		return false, nil
+	}
+
+	size, err := node.Size()
+	if err != nil {
+		return false, err
+	}
+	ref := filepath.Join(ext.linkDest, relativePath)
+	info, err := os.Lstat(ref)
+	if err != nil || !info.Mode().IsRegular() || info.Size() != size {
+		return false, nil
+	}
+
+	if ext.linkVerify {
+		same, err := ext.sameAsReference(node, ref, relativePath)
+		if err != nil || !same {
+			return false, err
+		}
+	}
+
+	rel := destPath(relativePath)
+	part := dest.partPath(rel)
+	if err := os.MkdirAll(filepath.Dir(part), dirPermissions); err != nil {
+		return false, nil
+	}
+	if err := os.Remove(part); err != nil && !os.IsNotExist(err) {
+		return false, nil
+	}
+	if err := os.Link(ref, part); err != nil {
+		return false, nil
+	}
+
+	ext.fileStarted(relativePath, size)
+	if err := ext.runFinalizeHook(ctx, dest, rel, relativePath, size); err != nil {
+		_ = os.Remove(part)
+		return true, err
+	}
+	if err := ext.retryFS(ctx, "rename", relativePath, func(int) error { return dest.Finalize(rel) }); err != nil {
+		_ = os.Remove(part)
+		return true, err
+	}
+
+	ext.updateProgress(size, relativePath)
+	ext.fileCompleted(relativePath, size)
+	ext.linkStats.Linked++
+	ext.linkStats.BytesSaved += size
+	return true, nil
+}
+
+// sameAsReference reports whether node has the same content as the file at
+// ref. When node has to be read for the comparison, it is rewound afterwards.
+func (ext *Extractor) sameAsReference(node files.File, ref, relativePath string) (bool, error) {
+	if expected := ext.checksums[ext.rootPath(relativePath)]; expected != "" {
+		f, err := os.Open(ref)
+		if err != nil {
+			return false, nil
+		}
+		defer f.Close()
+
+		digest := sha256.New()
+		if _, err := io.Copy(digest, f); err != nil {
+			return false, nil
+		}
+		return hex.EncodeToString(digest.Sum(nil)) == expected, nil
+	}
+
+	same, err := sameContent(node, ref)
+	if _, seekErr := node.Seek(0, io.SeekStart); seekErr != nil && err == nil {
+		err = seekErr
+	}
+	return same, err
+}
+
+// sameContent reports whether r yields exactly the content of the file at
+// path. Errors reading the file count as a difference; errors reading r are
+// returned.
+func sameContent(r io.Reader, path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, nil
+	}
+	defer f.Close()
+	return sameReader(r, f)
+}
+
+// sameReader reports whether r and f yield exactly the same bytes. Errors
+// reading f count as a difference; errors reading r are returned.
+func sameReader(r, f io.Reader) (bool, error) {
+	a := make([]byte, linkCompareBufferSize)
+	b := make([]byte, linkCompareBufferSize)
+	for {
+		n, errA := io.ReadFull(r, a)
+		if errA != nil && errA != io.EOF && errA != io.ErrUnexpectedEOF {
+			return false, errA
+		}
+		m, errB := io.ReadFull(f, b[:n])
+		if errB != nil && errB != io.EOF && errB != io.ErrUnexpectedEOF {
+			return false, nil
+		}
+		if m != n || !bytes.Equal(a[:n], b[:m]) {
+			return false, nil
+		}
+		if errA != nil {
+			// r is exhausted, so f must be too
+			k, _ := f.Read(b[:1])
+			return k == 0, nil
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package extractor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/boxo/blockstore"
+)
+
+// extractSnapshot extracts the import of tree to a new directory.
+func extractSnapshot(t *testing.T, bs blockstore.Blockstore, tree map[string][]byte, configure func(*Extractor)) (string, *Extractor) {
+	t.Helper()
+
+	result := importFixture(t, bs, tree, withChecksums)
+	out := filepath.Join(t.TempDir(), "out")
+	ext := NewExtractor(bs, result.RootCid, out)
+	if configure != nil {
+		configure(ext)
+	}
+	if err := ext.Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	return out, ext
+}
+
+// sameInode reports whether rel is the same file below both directories.
+func sameInode(t *testing.T, a, b, rel string) bool {
+	t.Helper()
+
+	infoA, err := os.Stat(filepath.Join(a, filepath.FromSlash(rel)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	infoB, err := os.Stat(filepath.Join(b, filepath.FromSlash(rel)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return os.SameFile(infoA, infoB)
+}
+
+func TestExtractor_WithLinkDest(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	snapshotA := map[string][]byte{
+		"same.txt":     []byte("unchanged"),
+		"dir/keep.txt": []byte("also unchanged"),
+		"changed.txt":  []byte("version one"),
+	}
+	snapshotB := map[string][]byte{
+		"same.txt":     []byte("unchanged"),
+		"dir/keep.txt": []byte("also unchanged"),
+		"changed.txt":  []byte("version two, longer"),
+	}
+
+	dirA, _ := extractSnapshot(t, bs, snapshotA, nil)
+	dirB, ext := extractSnapshot(t, bs, snapshotB, func(ext *Extractor) {
+		ext.WithLinkDest(dirA)
+	})
+
+	for _, rel := range []string{"same.txt", "dir/keep.txt"} {
+		if !sameInode(t, dirA, dirB, rel) {
+			t.Errorf("%s was not linked", rel)
+		}
+	}
+	if sameInode(t, dirA, dirB, "changed.txt") {
+		t.Error("changed.txt was linked")
+	}
+	for rel, want := range snapshotB {
+		got, err := os.ReadFile(filepath.Join(dirB, filepath.FromSlash(rel)))
+		if err != nil || string(got) != string(want) {
+			t.Errorf("%s = %q, %v; want %q", rel, got, err, want)
+		}
+	}
+
+	stats := ext.LinkDestStats()
+	want := LinkDestStats{Linked: 2, Written: 1, BytesSaved: int64(len("unchanged") + len("also unchanged"))}
+	if stats != want {
+		t.Errorf("LinkDestStats() = %+v, want %+v", stats, want)
+	}
+}
+
+func TestExtractor_WithLinkDestVerify(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	// changed.txt keeps its size, so only a content check tells the versions apart
+	snapshotA := map[string][]byte{
+		"same.txt":    []byte("unchanged"),
+		"changed.txt": []byte("version one"),
+	}
+	snapshotB := map[string][]byte{
+		"same.txt":    []byte("unchanged"),
+		"changed.txt": []byte("version two"),
+	}
+	dirA, _ := extractSnapshot(t, bs, snapshotA, nil)
+
+	tests := []struct {
+		name      string
+		checksums bool
+	}{
+		{name: "Streaming"},
+		{name: "Checksums", checksums: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := importFixture(t, bs, snapshotB, withChecksums)
+			dirB := filepath.Join(t.TempDir(), "out")
+			ext := NewExtractor(bs, result.RootCid, dirB).WithLinkDest(dirA).WithLinkDestVerify(true)
+			if tt.checksums {
+				ext.WithChecksumVerify(result.Checksums())
+			}
+			if err := ext.Extract(context.Background(), false); err != nil {
+				t.Fatalf("Extract failed: %v", err)
+			}
+
+			if !sameInode(t, dirA, dirB, "same.txt") {
+				t.Error("same.txt was not linked")
+			}
+			if sameInode(t, dirA, dirB, "changed.txt") {
+				t.Error("changed.txt was linked despite different content")
+			}
+			got, err := os.ReadFile(filepath.Join(dirB, "changed.txt"))
+			if err != nil || string(got) != "version two" {
+				t.Errorf("changed.txt = %q, %v", got, err)
+			}
+			if stats := ext.LinkDestStats(); stats.Linked != 1 || stats.Written != 1 {
+				t.Errorf("LinkDestStats() = %+v, want 1 linked and 1 written", stats)
+			}
+		})
+	}
+}
+
+func TestExtractor_WithLinkDest_MissingReference(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	tree := map[string][]byte{"a.txt": []byte("alpha")}
+	out, ext := extractSnapshot(t, bs, tree, func(ext *Extractor) {
+		ext.WithLinkDest(filepath.Join(t.TempDir(), "missing"))
+	})
+
+	got, err := os.ReadFile(filepath.Join(out, "a.txt"))
+	if err != nil || string(got) != "alpha" {
+		t.Errorf("a.txt = %q, %v", got, err)
+	}
+	if stats := ext.LinkDestStats(); stats != (LinkDestStats{Written: 1}) {
+		t.Errorf("LinkDestStats() = %+v, want 1 written", stats)
+	}
+}
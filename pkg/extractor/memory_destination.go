@@ -0,0 +1,232 @@
+package extractor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryDestination is a Destination keeping extracted entries in memory,
+// mainly for tests and as a reference for other implementations.
+// It is safe for concurrent use.
+type MemoryDestination struct {
+	mu      sync.Mutex
+	entries map[string]*memEntry // Published entries by relative path
+	pending map[string][]byte    // Closed but not yet finalized files
+}
+
+// memEntry is a published entry of a MemoryDestination.
+type memEntry struct {
+	mode   fs.FileMode
+	data   []byte
+	target string // Symlink target
+}
+
+// NewMemoryDestination returns an empty in-memory destination.
+func NewMemoryDestination() *MemoryDestination {
+	return &MemoryDestination{
+		entries: make(map[string]*memEntry),
+		pending: make(map[string][]byte),
+	}
+}
+
+// Files returns a copy of the content of every regular file by relative path.
+func (m *MemoryDestination) Files() map[string][]byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	files := make(map[string][]byte)
+	for rel, e := range m.entries {
+		if e.mode.IsRegular() {
+			files[rel] = bytes.Clone(e.data)
+		}
+	}
+	return files
+}
+
+// Paths returns the relative paths of all entries, sorted.
+func (m *MemoryDestination) Paths() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	paths := make([]string, 0, len(m.entries))
+	for rel := range m.entries {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// mkdirAll creates rel and its parents. Callers hold m.mu.
+func (m *MemoryDestination) mkdirAll(rel string) error {
+	if rel == "" || rel == "." {
+		if e, ok := m.entries[""]; ok && !e.mode.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: rel, Err: fs.ErrExist}
+		}
+		m.entries[""] = &memEntry{mode: fs.ModeDir | dirPermissions}
+		return nil
+	}
+	if err := m.mkdirAll(parentOf(rel)); err != nil {
+		return err
+	}
+	if e, ok := m.entries[rel]; ok {
+		if !e.mode.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: rel, Err: fs.ErrExist}
+		}
+		return nil
+	}
+	m.entries[rel] = &memEntry{mode: fs.ModeDir | dirPermissions}
+	return nil
+}
+
+// parentOf returns the parent of rel, empty for top-level entries.
+func parentOf(rel string) string {
+	dir := path.Dir(rel)
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+func (m *MemoryDestination) CreateFile(relPath string) (io.WriteCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if relPath != "" {
+		if err := m.mkdirAll(parentOf(relPath)); err != nil {
+			return nil, err
+		}
+	}
+	delete(m.pending, relPath)
+	return &memWriter{dst: m, rel: relPath}, nil
+}
+
+func (m *MemoryDestination) Finalize(relPath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, ok := m.pending[relPath]
+	if !ok {
+		return &fs.PathError{Op: "finalize", Path: relPath, Err: fs.ErrNotExist}
+	}
+	delete(m.pending, relPath)
+	m.entries[relPath] = &memEntry{mode: filePermissions, data: data}
+	return nil
+}
+
+func (m *MemoryDestination) Mkdir(relPath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mkdirAll(relPath)
+}
+
+func (m *MemoryDestination) Stat(relPath string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[relPath]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: relPath, Err: fs.ErrNotExist}
+	}
+	return &memInfo{name: path.Base(relPath), size: int64(len(e.data)), mode: e.mode}, nil
+}
+
+// Open returns a reader over a copy of the published file at relPath.
+func (m *MemoryDestination) Open(relPath string) (io.ReadSeekCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[relPath]
+	if !ok || !e.mode.IsRegular() {
+		return nil, &fs.PathError{Op: "open", Path: relPath, Err: fs.ErrNotExist}
+	}
+	return nopSeekCloser{bytes.NewReader(bytes.Clone(e.data))}, nil
+}
+
+// nopSeekCloser adds a no-op Close to a ReadSeeker.
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error { return nil }
+
+func (m *MemoryDestination) Remove(relPath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.pending, relPath)
+	delete(m.entries, relPath)
+
+	prefix := relPath + "/"
+	for rel := range m.entries {
+		if relPath == "" || strings.HasPrefix(rel, prefix) {
+			delete(m.entries, rel)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryDestination) Symlink(target, relPath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.entries[relPath]; ok {
+		return &fs.PathError{Op: "symlink", Path: relPath, Err: fs.ErrExist}
+	}
+	if err := m.mkdirAll(parentOf(relPath)); err != nil {
+		return err
+	}
+	m.entries[relPath] = &memEntry{mode: fs.ModeSymlink | 0o777, target: target}
+	return nil
+}
+
+// Readlink returns the target of the symlink at relPath.
+func (m *MemoryDestination) Readlink(relPath string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[relPath]
+	if !ok || e.mode&fs.ModeSymlink == 0 {
+		return "", fmt.Errorf("%s: not a symlink", relPath)
+	}
+	return e.target, nil
+}
+
+// memWriter buffers a file until it is closed.
+type memWriter struct {
+	dst *MemoryDestination
+	rel string
+	buf bytes.Buffer
+}
+
+func (w *memWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *memWriter) Close() error {
+	w.dst.mu.Lock()
+	defer w.dst.mu.Unlock()
+
+	w.dst.pending[w.rel] = w.buf.Bytes()
+	return nil
+}
+
+// memInfo describes an entry of a MemoryDestination.
+type memInfo struct {
+	name string
+	size int64
+	mode fs.FileMode
+}
+
+func (i *memInfo) Name() string       { return i.name }
+func (i *memInfo) Size() int64        { return i.size }
+func (i *memInfo) Mode() fs.FileMode  { return i.mode }
+func (i *memInfo) ModTime() time.Time { return time.Time{} }
+func (i *memInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *memInfo) Sys() any           { return nil }
@@ -0,0 +1,148 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readTree returns the content of every regular file below dir by
+// slash-separated relative path.
+func readTree(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+
+	tree := make(map[string][]byte)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		tree[filepath.ToSlash(rel)] = data
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", dir, err)
+	}
+	return tree
+}
+
+func TestExtractor_WithDestination_MatchesFilesystem(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	const dirs, files = 3, 5
+	root := importManyFiles(t, bs, dirs, files)
+	ctx := context.Background()
+
+	var fsCompleted, memCompleted int64
+	out := t.TempDir()
+	fsExt := NewExtractor(bs, root, out).WithProgress(func(c, _ int64, _ string) {
+		fsCompleted = c
+	})
+	if err := fsExt.Extract(ctx, false); err != nil {
+		t.Fatalf("filesystem Extract failed: %v", err)
+	}
+	want := readTree(t, out)
+
+	mem := NewMemoryDestination()
+	ext := NewExtractor(bs, root, "unused").WithDestination(mem).WithProgress(func(c, _ int64, _ string) {
+		memCompleted = c
+	})
+	if err := ext.Extract(ctx, false); err != nil {
+		t.Fatalf("memory Extract failed: %v", err)
+	}
+	got := mem.Files()
+
+	if len(got) != len(want) || len(got) != dirs*files {
+		t.Fatalf("memory destination holds %d files, filesystem %d", len(got), len(want))
+	}
+	for rel, data := range want {
+		if !bytes.Equal(got[rel], data) {
+			t.Errorf("%s: content differs from the filesystem extraction", rel)
+		}
+	}
+	if memCompleted != fsCompleted {
+		t.Errorf("progress ended at %d bytes, filesystem extraction at %d", memCompleted, fsCompleted)
+	}
+	if _, err := os.Stat("unused"); !os.IsNotExist(err) {
+		t.Errorf("extraction to a destination touched the extractor path: %v", err)
+	}
+}
+
+func TestExtractor_WithDestination_Conflicts(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	root := importManyFiles(t, bs, 1, 3)
+	ctx := context.Background()
+	mem := NewMemoryDestination()
+
+	if err := NewExtractor(bs, root, "unused").WithDestination(mem).Extract(ctx, false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	before := mem.Paths()
+
+	// Existing entries are found through Stat on the destination
+	err := NewExtractor(bs, root, "unused").WithDestination(mem).Extract(ctx, false)
+	if !errors.Is(err, ErrPathExistsOverwrite) {
+		t.Fatalf("second Extract error = %v, want ErrPathExistsOverwrite", err)
+	}
+	if err := NewExtractor(bs, root, "unused").WithDestination(mem).Extract(ctx, true); err != nil {
+		t.Fatalf("Extract with overwrite failed: %v", err)
+	}
+	if after := mem.Paths(); len(after) != len(before) {
+		t.Errorf("overwrite changed the entries: %v, want %v", after, before)
+	}
+}
+
+// failingDestination is a MemoryDestination whose file writes always fail.
+type failingDestination struct {
+	*MemoryDestination
+}
+
+func (d failingDestination) CreateFile(relPath string) (io.WriteCloser, error) {
+	w, err := d.MemoryDestination.CreateFile(relPath)
+	if err != nil {
+		return nil, err
+	}
+	return failingWriter{w}, nil
+}
+
+type failingWriter struct {
+	io.WriteCloser
+}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestExtractor_WithDestination_FailedWriteIsDiscarded(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	root := importManyFiles(t, bs, 1, 1)
+	mem := NewMemoryDestination()
+
+	err := NewExtractor(bs, root, "unused").WithDestination(failingDestination{mem}).Extract(context.Background(), false)
+	if err == nil {
+		t.Fatal("Extract succeeded despite failing writes")
+	}
+	if files := mem.Files(); len(files) != 0 {
+		t.Errorf("failed write left files behind: %v", files)
+	}
+	if len(mem.pending) != 0 {
+		t.Errorf("failed write left unfinished data behind")
+	}
+}
@@ -0,0 +1,47 @@
+package extractor
+
+import (
+	"os"
+
+	"github.com/ipfs/boxo/files"
+)
+
+// WithPreserveMetadata enables restoring the permission bits and
+// modification times recorded by an import with the importer's
+// WithPreserveMetadata. A file's are set once it has been renamed into
+// place and a directory's once all of its entries have been written, so a
+// read-only directory does not block writing its own entries and writing
+// them does not change its time. Entries without recorded metadata keep
+// the defaults, and symlinks are left alone. Metadata is only restored
+// when extracting to the filesystem; failing to set it fails the entry.
+// Disabled by default. Returns the extractor instance for method chaining.
+func (ext *Extractor) WithPreserveMetadata(enabled bool) *Extractor {
+	ext.metadata = enabled
+	return ext
+}
+
+// applyMetadata sets the mode and modification time recorded in nd on the
+// entry at relativePath, written to path.
+func (ext *Extractor) applyMetadata(nd files.Node, path, relativePath string) error {
+	if !ext.metadata {
+		return nil
+	}
+	if _, ok := nd.(*files.Symlink); ok {
+		return nil
+	}
+	if _, ok := ext.destination().(*fsDestination); !ok {
+		return nil
+	}
+
+	if perm := nd.Mode().Perm(); perm != 0 {
+		if err := os.Chmod(path, perm); err != nil {
+			return &PathError{Path: relativePath, Op: "chmod", Err: err}
+		}
+	}
+	if mtime := nd.ModTime(); !mtime.IsZero() {
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			return &PathError{Path: relativePath, Op: "chtimes", Err: err}
+		}
+	}
+	return nil
+}
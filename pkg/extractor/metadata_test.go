@@ -0,0 +1,121 @@
+package extractor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/tragoedia0722/repository/pkg/importer"
+)
+
+func TestExtractor_WithPreserveMetadata_RoundTrip(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not restored on Windows")
+	}
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	src := t.TempDir()
+	for _, rel := range []string{"a.txt", "sub/b.txt", "locked/c.txt"} {
+		path := filepath.Join(src, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(rel), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	modes := map[string]os.FileMode{
+		"a.txt":        0o600,
+		"sub/b.txt":    0o640,
+		"sub":          0o750,
+		"locked/c.txt": 0o444,
+		"locked":       0o555,
+	}
+	base := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	mtimes := make(map[string]time.Time)
+	// Directories last, so setting the times of their entries does not change theirs
+	for i, rel := range []string{"a.txt", "sub/b.txt", "locked/c.txt", "sub", "locked"} {
+		path := filepath.Join(src, filepath.FromSlash(rel))
+		mtimes[rel] = base.Add(time.Duration(i) * time.Hour)
+		if err := os.Chmod(path, modes[rel]); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtimes[rel], mtimes[rel]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { _ = os.Chmod(filepath.Join(src, "locked"), 0o755) })
+
+	res, err := importer.NewImporter(bs, src).WithPreserveMetadata(true).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	out := filepath.Join(t.TempDir(), "out")
+	t.Cleanup(func() { _ = os.Chmod(filepath.Join(out, "locked"), 0o755) })
+	if err := NewExtractor(bs, res.RootCid, out).WithPreserveMetadata(true).Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	for rel, mode := range modes {
+		info, err := os.Stat(filepath.Join(out, filepath.FromSlash(rel)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != mode {
+			t.Errorf("%s: mode = %v, want %v", rel, info.Mode().Perm(), mode)
+		}
+		if !info.ModTime().Equal(mtimes[rel]) {
+			t.Errorf("%s: mtime = %v, want %v", rel, info.ModTime(), mtimes[rel])
+		}
+	}
+}
+
+func TestExtractor_WithPreserveMetadata_Default(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	src := t.TempDir()
+	path := filepath.Join(src, "a.txt")
+	if err := os.WriteFile(path, []byte("alpha"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	plain, err := importer.NewImporter(bs, src).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	res, err := importer.NewImporter(bs, src).WithPreserveMetadata(true).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if res.RootCid == plain.RootCid {
+		t.Errorf("recording metadata left the root unchanged: %s", res.RootCid)
+	}
+
+	// Without the extractor option the recorded metadata is ignored
+	out := filepath.Join(t.TempDir(), "out")
+	if err := NewExtractor(bs, res.RootCid, out).Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(out, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ModTime().Equal(old) {
+		t.Errorf("mtime restored without WithPreserveMetadata")
+	}
+	data, err := os.ReadFile(filepath.Join(out, "a.txt"))
+	if err != nil || string(data) != "alpha" {
+		t.Errorf("a.txt = %q, %v, want alpha", data, err)
+	}
+}
@@ -0,0 +1,345 @@
+package extractor
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/files"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+)
+
+// ExtractTarget is a single root CID to extract into Path.
+type ExtractTarget struct {
+	Cid  string
+	Path string
+}
+
+// TargetStatus reports the outcome of a single ExtractMany target.
+type TargetStatus struct {
+	Cid     string
+	Path    string
+	Size    int64 // Total bytes of file content under the root (0 if it could not be resolved)
+	Skipped bool  // True if the target was not attempted because of FailFast
+	Err     error // Nil on success
+}
+
+// MultiSummary reports the outcome of ExtractMany.
+type MultiSummary struct {
+	Targets        []TargetStatus // Per-target status, in the order of the input targets
+	Succeeded      int            // Number of targets extracted successfully
+	Failed         int            // Number of targets that failed
+	TotalBytes     int64          // Sum of Size over all resolved targets
+	CompletedBytes int64          // Bytes reported through progress across all targets
+	BlocksRead     int            // Blocks loaded from the underlying blockstore
+	SharedBlocks   int            // Distinct blocks used by more than one target, see ExtractMany
+	DedupHits      int            // Block reads served from the shared cache
+	DedupBytes     int64          // Bytes served from the shared cache
+}
+
+// ManyOption configures ExtractMany.
+type ManyOption func(*manyOptions)
+
+type manyOptions struct {
+	overwrite bool
+	failFast  bool
+	progress  progressCallback
+	cacheSize int64
+}
+
+// WithOverwrite allows ExtractMany to replace existing files, as Extract does
+// when overwrite is true.
+func WithOverwrite(overwrite bool) ManyOption {
+	return func(o *manyOptions) {
+		o.overwrite = overwrite
+	}
+}
+
+// WithFailFast stops ExtractMany at the first failed target. Remaining targets
+// are reported as skipped.
+func WithFailFast() ManyOption {
+	return func(o *manyOptions) {
+		o.failFast = true
+	}
+}
+
+// WithCombinedProgress sets a callback receiving progress across all targets.
+// The total is the sum of all resolved roots' sizes.
+func WithCombinedProgress(progressFn progressCallback) ManyOption {
+	return func(o *manyOptions) {
+		o.progress = progressFn
+	}
+}
+
+// WithSharedCacheSize sets the maximum number of bytes of block data kept in
+// memory for reuse across targets. Zero or negative disables the cache.
+func WithSharedCacheSize(bytes int64) ManyOption {
+	return func(o *manyOptions) {
+		o.cacheSize = bytes
+	}
+}
+
+// ExtractMany extracts several roots from bs in one call.
+//
+// All targets share a single DAG service and an in-memory block cache, so
+// blocks common to several roots are read from bs once while they stay in
+// the cache, which evicts the least recently used blocks beyond
+// WithSharedCacheSize. SharedBlocks in the summary is counted over the most
+// recently used blocks only, so with very large DAGs it may miss a shared
+// block that was not read for a long time, or count one twice.
+//
+// Targets are extracted in order; a failing target does not stop the others
+// unless WithFailFast is given. The returned summary is always non-nil. The
+// error is non-nil when the context is cancelled, when FailFast stops the
+// run, or when any target failed.
+func ExtractMany(ctx context.Context, bs blockstore.Blockstore, targets []ExtractTarget, opts ...ManyOption) (*MultiSummary, error) {
+	options := manyOptions{cacheSize: defaultSharedCacheSize}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	shared := newSharedBlockstore(bs, options.cacheSize)
+	ds := merkledag.NewDAGService(blockservice.New(shared, nil))
+
+	summary := &MultiSummary{Targets: make([]TargetStatus, len(targets))}
+	roots := make([]files.Node, len(targets))
+
+	// Resolve every root first so the combined total is known before any data is written.
+	for i, target := range targets {
+		summary.Targets[i] = TargetStatus{Cid: target.Cid, Path: target.Path}
+
+		shared.setTarget(i)
+		node, _, err := openRoot(ctx, ds, target.Cid)
+		if err != nil {
+			summary.Targets[i].Err = err
+			continue
+		}
+		size, err := contentSize(ctx, node, "")
+		if err != nil {
+			summary.Targets[i].Err = err
+			continue
+		}
+		roots[i] = node
+		summary.Targets[i].Size = size
+		summary.TotalBytes += size
+	}
+
+	tracker := newProgressTracker(summary.TotalBytes, options.progress)
+
+	var runErr error
+	for i, target := range targets {
+		status := &summary.Targets[i]
+
+		if runErr != nil {
+			status.Skipped = true
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			runErr = err
+			status.Skipped = true
+			continue
+		}
+
+		if status.Err == nil {
+			shared.setTarget(i)
+			ext := NewExtractor(shared, target.Cid, target.Path)
+			ext.tracker = tracker
+			status.Err = ext.extractNode(ctx, roots[i], options.overwrite)
+		}
+
+		if status.Err != nil && options.failFast {
+			runErr = fmt.Errorf("extract %s to %q: %w", target.Cid, target.Path, status.Err)
+		}
+	}
+
+	for _, status := range summary.Targets {
+		switch {
+		case status.Skipped:
+		case status.Err != nil:
+			summary.Failed++
+		default:
+			summary.Succeeded++
+		}
+	}
+	summary.CompletedBytes = tracker.getCompleted()
+	shared.fillSummary(summary)
+
+	if runErr != nil {
+		return summary, runErr
+	}
+	if summary.Failed > 0 {
+		return summary, fmt.Errorf("%w: %d of %d", ErrTargetsFailed, summary.Failed, len(targets))
+	}
+	return summary, nil
+}
+
+// contentSize returns the number of file content bytes under nd, the entry at
+// relativePath, which is exactly what extraction reports through progress.
+// Directory sizes from UnixFS include encoding overhead, so they cannot be
+// used as a progress total.
+func contentSize(ctx context.Context, nd files.Node, relativePath string) (int64, error) {
+	switch node := nd.(type) {
+	case *files.Symlink:
+		return 0, nil
+	case files.File:
+		return node.Size()
+	case files.Directory:
+		var total int64
+		entries := node.Entries()
+		for entries.Next() {
+			if err := ctx.Err(); err != nil {
+				return 0, err
+			}
+			if isXattrMetadata(relativePath, entries.Name()) {
+				continue
+			}
+			size, err := contentSize(ctx, entries.Node(), filepath.Join(relativePath, entries.Name()))
+			if err != nil {
+				return 0, err
+			}
+			total += size
+		}
+		return total, entries.Err()
+	default:
+		return 0, nil
+	}
+}
+
+// sharedBlockstore wraps a blockstore with a bounded block cache and records
+// which targets used each block. Both the cache and the usage records evict
+// the least recently used entry, so their memory does not grow with the size
+// of the DAGs.
+type sharedBlockstore struct {
+	blockstore.Blockstore
+
+	mu         sync.Mutex
+	target     int
+	maxBytes   int64
+	cacheBytes int64
+	cache      map[cid.Cid]*list.Element // Values are blocks.Block
+	lru        *list.List                // Cached blocks, front is the most recently used
+	maxUsage   int
+	usage      map[cid.Cid]*list.Element // Values are *blockUsage
+	usageLRU   *list.List                // Usage records, front is the most recently used
+	shared     int                       // Blocks whose usage reached a second target
+	blocksRead int
+	dedupHits  int
+	dedupBytes int64
+}
+
+// blockUsage tracks the targets that read a block.
+type blockUsage struct {
+	cid        cid.Cid
+	lastTarget int
+	targets    int
+}
+
+func newSharedBlockstore(bs blockstore.Blockstore, maxBytes int64) *sharedBlockstore {
+	return &sharedBlockstore{
+		Blockstore: bs,
+		maxBytes:   maxBytes,
+		cache:      make(map[cid.Cid]*list.Element),
+		lru:        list.New(),
+		maxUsage:   sharedUsageEntries,
+		usage:      make(map[cid.Cid]*list.Element),
+		usageLRU:   list.New(),
+	}
+}
+
+// setTarget sets the index of the target on whose behalf blocks are read.
+func (s *sharedBlockstore) setTarget(target int) {
+	s.mu.Lock()
+	s.target = target
+	s.mu.Unlock()
+}
+
+func (s *sharedBlockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	s.mu.Lock()
+	s.recordUse(c)
+	if elem, ok := s.cache[c]; ok {
+		s.lru.MoveToFront(elem)
+		blk := elem.Value.(blocks.Block)
+		s.dedupHits++
+		s.dedupBytes += int64(len(blk.RawData()))
+		s.mu.Unlock()
+		return blk, nil
+	}
+	s.mu.Unlock()
+
+	blk, err := s.Blockstore.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.blocksRead++
+	s.addToCache(blk)
+	s.mu.Unlock()
+
+	return blk, nil
+}
+
+// recordUse notes that the current target used block c, dropping the least
+// recently used record beyond maxUsage. Callers must hold s.mu.
+func (s *sharedBlockstore) recordUse(c cid.Cid) {
+	if elem, ok := s.usage[c]; ok {
+		s.usageLRU.MoveToFront(elem)
+		u := elem.Value.(*blockUsage)
+		if u.lastTarget != s.target {
+			u.lastTarget = s.target
+			u.targets++
+			if u.targets == 2 {
+				s.shared++
+			}
+		}
+		return
+	}
+
+	s.usage[c] = s.usageLRU.PushFront(&blockUsage{cid: c, lastTarget: s.target, targets: 1})
+	for s.usageLRU.Len() > s.maxUsage {
+		elem := s.usageLRU.Back()
+		s.usageLRU.Remove(elem)
+		delete(s.usage, elem.Value.(*blockUsage).cid)
+	}
+}
+
+// addToCache stores blk, evicting the least recently used entries to stay
+// within maxBytes. Callers must hold s.mu.
+func (s *sharedBlockstore) addToCache(blk blocks.Block) {
+	size := int64(len(blk.RawData()))
+	if s.maxBytes <= 0 || size > s.maxBytes {
+		return
+	}
+	if elem, ok := s.cache[blk.Cid()]; ok {
+		s.lru.MoveToFront(elem)
+		return
+	}
+
+	for s.cacheBytes+size > s.maxBytes && s.lru.Len() > 0 {
+		elem := s.lru.Back()
+		old := elem.Value.(blocks.Block)
+		s.lru.Remove(elem)
+		delete(s.cache, old.Cid())
+		s.cacheBytes -= int64(len(old.RawData()))
+	}
+
+	s.cache[blk.Cid()] = s.lru.PushFront(blk)
+	s.cacheBytes += size
+}
+
+// fillSummary copies the block statistics into summary.
+func (s *sharedBlockstore) fillSummary(summary *MultiSummary) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summary.BlocksRead = s.blocksRead
+	summary.SharedBlocks = s.shared
+	summary.DedupHits = s.dedupHits
+	summary.DedupBytes = s.dedupBytes
+}
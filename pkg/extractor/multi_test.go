@@ -0,0 +1,244 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+)
+
+// importSnapshot imports a directory containing shared plus one unique file.
+func importSnapshot(t *testing.T, bs blockstore.Blockstore, shared []byte, unique string) string {
+	t.Helper()
+
+	return importFixture(t, bs, map[string][]byte{
+		"shared.bin": shared,
+		"unique.txt": []byte(unique),
+	}, nil).RootCid
+}
+
+func TestExtractMany_SharedContent(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	shared := make([]byte, 1024*1024)
+	rand.New(rand.NewSource(1)).Read(shared)
+
+	rootA := importSnapshot(t, bs, shared, "snapshot a")
+	rootB := importSnapshot(t, bs, shared, "snapshot b, a bit longer")
+
+	out := t.TempDir()
+	targets := []ExtractTarget{
+		{Cid: rootA, Path: filepath.Join(out, "a")},
+		{Cid: rootB, Path: filepath.Join(out, "b")},
+	}
+
+	var lastCompleted, lastTotal int64
+	reachedTotal := 0
+	summary, err := ExtractMany(context.Background(), bs, targets,
+		WithCombinedProgress(func(completed, total int64, currentFile string) {
+			if completed < lastCompleted {
+				t.Errorf("progress went backwards: %d -> %d", lastCompleted, completed)
+			}
+			lastCompleted, lastTotal = completed, total
+			if completed == total {
+				reachedTotal++
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("ExtractMany failed: %v", err)
+	}
+
+	wantTotal := int64(2*len(shared) + len("snapshot a") + len("snapshot b, a bit longer"))
+	if summary.TotalBytes != wantTotal {
+		t.Errorf("TotalBytes = %d, want %d", summary.TotalBytes, wantTotal)
+	}
+	if lastTotal != wantTotal || lastCompleted != wantTotal {
+		t.Errorf("final progress = %d/%d, want %d/%d", lastCompleted, lastTotal, wantTotal, wantTotal)
+	}
+	if reachedTotal != 1 {
+		t.Errorf("progress reached total %d times, want 1", reachedTotal)
+	}
+	if summary.CompletedBytes != wantTotal {
+		t.Errorf("CompletedBytes = %d, want %d", summary.CompletedBytes, wantTotal)
+	}
+
+	if summary.Succeeded != 2 || summary.Failed != 0 {
+		t.Errorf("Succeeded = %d, Failed = %d", summary.Succeeded, summary.Failed)
+	}
+	for _, target := range targets {
+		got, err := os.ReadFile(filepath.Join(target.Path, "shared.bin"))
+		if err != nil {
+			t.Fatalf("failed to read extracted file: %v", err)
+		}
+		if !bytes.Equal(got, shared) {
+			t.Errorf("%s: shared content mismatch", target.Path)
+		}
+	}
+	if got, _ := os.ReadFile(filepath.Join(out, "b", "unique.txt")); string(got) != "snapshot b, a bit longer" {
+		t.Errorf("unique content = %q", got)
+	}
+
+	if summary.SharedBlocks == 0 {
+		t.Error("expected shared blocks between overlapping roots")
+	}
+	if summary.DedupHits == 0 || summary.DedupBytes < int64(len(shared)) {
+		t.Errorf("DedupHits = %d, DedupBytes = %d", summary.DedupHits, summary.DedupBytes)
+	}
+}
+
+func TestExtractMany_ErrorIsolation(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	root := importTestFiles(t, bs)
+	out := t.TempDir()
+
+	targets := []ExtractTarget{
+		{Cid: "invalid-cid", Path: filepath.Join(out, "bad")},
+		{Cid: root, Path: filepath.Join(out, "good")},
+	}
+
+	t.Run("continues after failure", func(t *testing.T) {
+		summary, err := ExtractMany(context.Background(), bs, targets)
+		if !errors.Is(err, ErrTargetsFailed) {
+			t.Fatalf("expected ErrTargetsFailed, got %v", err)
+		}
+		if summary.Failed != 1 || summary.Succeeded != 1 {
+			t.Errorf("Succeeded = %d, Failed = %d", summary.Succeeded, summary.Failed)
+		}
+		if summary.Targets[0].Err == nil {
+			t.Error("expected error for invalid target")
+		}
+		if _, err := os.Stat(filepath.Join(out, "good", "test1.txt")); err != nil {
+			t.Errorf("good target not extracted: %v", err)
+		}
+	})
+
+	t.Run("fail fast", func(t *testing.T) {
+		summary, err := ExtractMany(context.Background(), bs, targets, WithFailFast(), WithOverwrite(true))
+		if err == nil || errors.Is(err, ErrTargetsFailed) {
+			t.Fatalf("expected first target's error, got %v", err)
+		}
+		if !summary.Targets[1].Skipped {
+			t.Error("expected second target to be skipped")
+		}
+		if summary.Failed != 1 || summary.Succeeded != 0 {
+			t.Errorf("Succeeded = %d, Failed = %d", summary.Succeeded, summary.Failed)
+		}
+	})
+}
+
+func TestExtractMany_NoCache(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	root := importTestFiles(t, bs)
+	out := t.TempDir()
+
+	summary, err := ExtractMany(context.Background(), bs, []ExtractTarget{
+		{Cid: root, Path: filepath.Join(out, "a")},
+		{Cid: root, Path: filepath.Join(out, "b")},
+	}, WithSharedCacheSize(0))
+	if err != nil {
+		t.Fatalf("ExtractMany failed: %v", err)
+	}
+	if summary.DedupHits != 0 {
+		t.Errorf("DedupHits = %d with cache disabled", summary.DedupHits)
+	}
+	if summary.SharedBlocks == 0 {
+		t.Error("expected blocks shared by identical roots")
+	}
+}
+
+func TestSharedBlockstore_Eviction(t *testing.T) {
+	shared := newSharedBlockstore(nil, 10)
+
+	first := blocks.NewBlock([]byte("123456"))
+	second := blocks.NewBlock([]byte("abcdef"))
+	large := blocks.NewBlock([]byte("this block exceeds the limit"))
+
+	shared.addToCache(first)
+	shared.addToCache(second)
+	shared.addToCache(large)
+
+	if _, ok := shared.cache[first.Cid()]; ok {
+		t.Error("oldest block should have been evicted")
+	}
+	if _, ok := shared.cache[second.Cid()]; !ok {
+		t.Error("newest block should be cached")
+	}
+	if _, ok := shared.cache[large.Cid()]; ok {
+		t.Error("block larger than the limit should not be cached")
+	}
+	if shared.cacheBytes != int64(len(second.RawData())) {
+		t.Errorf("cacheBytes = %d, want %d", shared.cacheBytes, len(second.RawData()))
+	}
+}
+
+func TestSharedBlockstore_LRU(t *testing.T) {
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	shared := newSharedBlockstore(bs, 12)
+
+	a := blocks.NewBlock([]byte("aaaa"))
+	b := blocks.NewBlock([]byte("bbbb"))
+	c := blocks.NewBlock([]byte("cccc"))
+	d := blocks.NewBlock([]byte("dddd"))
+	shared.addToCache(a)
+	shared.addToCache(b)
+	shared.addToCache(c)
+
+	// A hit makes a the most recently used block, so b is evicted instead
+	if _, err := shared.Get(context.Background(), a.Cid()); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	shared.addToCache(d)
+
+	if _, ok := shared.cache[b.Cid()]; ok {
+		t.Error("least recently used block should have been evicted")
+	}
+	for _, blk := range []blocks.Block{a, c, d} {
+		if _, ok := shared.cache[blk.Cid()]; !ok {
+			t.Errorf("block %q should be cached", blk.RawData())
+		}
+	}
+}
+
+func TestSharedBlockstore_UsageBounded(t *testing.T) {
+	shared := newSharedBlockstore(nil, 0)
+	shared.maxUsage = 4
+
+	var cids []cid.Cid
+	for i := 0; i < 10; i++ {
+		cids = append(cids, blocks.NewBlock([]byte(fmt.Sprintf("block %d", i))).Cid())
+	}
+	shared.setTarget(0)
+	for _, c := range cids {
+		shared.recordUse(c)
+	}
+	if len(shared.usage) != 4 || shared.usageLRU.Len() != 4 {
+		t.Fatalf("%d usage records, list of %d, want 4", len(shared.usage), shared.usageLRU.Len())
+	}
+
+	// The most recently used blocks are still tracked across targets
+	shared.setTarget(1)
+	for _, c := range cids[6:] {
+		shared.recordUse(c)
+	}
+	var summary MultiSummary
+	shared.fillSummary(&summary)
+	if summary.SharedBlocks != 4 {
+		t.Errorf("SharedBlocks = %d, want 4", summary.SharedBlocks)
+	}
+}
@@ -0,0 +1,145 @@
+package extractor
+
+import (
+	"context"
+	"sort"
+
+	"github.com/ipfs/boxo/files"
+	unixfile "github.com/ipfs/boxo/ipld/unixfs/file"
+	uio "github.com/ipfs/boxo/ipld/unixfs/io"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// WithDeterministicOrder makes extraction process the entries of every
+// directory sorted by their cleaned names, which is the default. Files are
+// then created, and progress callbacks, events and the lists reported after
+// extraction (such as Rejected, TruncatedFiles and Timings) follow, in the same
+// order for basic and sharded directories and across library versions.
+//
+// Only the names and CIDs of a directory's entries are held while it is
+// sorted; the entries themselves are loaded one at a time as they are
+// extracted. Disabling the option processes entries in the order the DAG
+// lists them, which saves the sort but may change between directory layouts.
+// Returns the extractor instance for method chaining.
+func (ext *Extractor) WithDeterministicOrder(enabled bool) *Extractor {
+	ext.dagOrder = !enabled
+	return ext
+}
+
+// dagDir is a UnixFS directory together with the CID and DAG service it was
+// loaded from, so that its links can be listed without loading its entries.
+type dagDir struct {
+	files.Directory
+	cid cid.Cid
+	dag ipld.DAGService
+}
+
+// wrapNode returns nd as a dagDir if it is a directory, as a dagFile if it
+// is a file, and nd otherwise.
+func wrapNode(nd files.Node, c cid.Cid, dag ipld.DAGService) files.Node {
+	switch n := nd.(type) {
+	case files.Directory:
+		return &dagDir{Directory: n, cid: c, dag: dag}
+	case files.File:
+		return &dagFile{File: n, cid: c}
+	}
+	return nd
+}
+
+// entries returns an iterator over the entries of dir in extraction order.
+func (ext *Extractor) entries(ctx context.Context, dir files.Directory) (files.DirIterator, error) {
+	d, ok := dir.(*dagDir)
+	if !ok {
+		return dir.Entries(), nil
+	}
+	if ext.dagOrder {
+		// Read-ahead needs the CIDs of the files, which only the listed
+		// links carry
+		if ext.prefetch == nil {
+			return dir.Entries(), nil
+		}
+		return d.listEntries(ctx, false)
+	}
+	return d.listEntries(ctx, true)
+}
+
+// dirLink is an entry of a directory, not yet loaded.
+type dirLink struct {
+	name string // Name as stored in the DAG
+	key  string // Cleaned name the entries are sorted by
+	cid  cid.Cid
+}
+
+// listEntries lists the links of d, including those of every shard of a
+// sharded directory, and returns an iterator over them, sorted by cleaned
+// name if sorted is set and in DAG order otherwise.
+func (d *dagDir) listEntries(ctx context.Context, sorted bool) (files.DirIterator, error) {
+	nd, err := d.dag.Get(ctx, d.cid)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := uio.NewDirectoryFromNode(d.dag, nd)
+	if err != nil {
+		return nil, err
+	}
+
+	var links []dirLink
+	err = dir.ForEachLink(ctx, func(l *ipld.Link) error {
+		// Names that cannot be cleaned sort by themselves and fail when reached
+		key, err := normalizeEntryName(l.Name)
+		if err != nil {
+			key = l.Name
+		}
+		links = append(links, dirLink{name: l.Name, key: key, cid: l.Cid})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if sorted {
+		sort.Slice(links, func(i, j int) bool {
+			if links[i].key != links[j].key {
+				return links[i].key < links[j].key
+			}
+			return links[i].name < links[j].name
+		})
+	}
+	return &linkIterator{ctx: ctx, dag: d.dag, links: links, pos: -1}, nil
+}
+
+// linkIterator loads the entries of a link list one at a time.
+type linkIterator struct {
+	ctx   context.Context
+	dag   ipld.DAGService
+	links []dirLink
+	pos   int
+	node  files.Node
+}
+
+func (it *linkIterator) Name() string     { return it.links[it.pos].name }
+func (it *linkIterator) Node() files.Node { return it.node }
+func (it *linkIterator) Err() error       { return nil }
+
+func (it *linkIterator) Next() bool {
+	if it.pos+1 >= len(it.links) {
+		return false
+	}
+	it.pos++
+	link := it.links[it.pos]
+
+	// An entry that cannot be loaded fails on its own, not the directory
+	nd, err := it.dag.Get(it.ctx, link.cid)
+	if err != nil {
+		it.node = &unreadableNode{err: err}
+		return true
+	}
+	node, err := unixfile.NewUnixfsFile(it.ctx, it.dag, nd)
+	if err != nil {
+		it.node = &unreadableNode{err: err}
+		return true
+	}
+	it.node = wrapNode(node, link.cid, it.dag)
+	return true
+}
@@ -0,0 +1,110 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs"
+	uio "github.com/ipfs/boxo/ipld/unixfs/io"
+	"github.com/ipfs/go-cid"
+)
+
+// recordingDestination records the order in which files are finalized.
+type recordingDestination struct {
+	Destination
+	finalized []string
+}
+
+func (d *recordingDestination) Finalize(relPath string) error {
+	d.finalized = append(d.finalized, relPath)
+	return d.Destination.Finalize(relPath)
+}
+
+// importSharded imports tree with a sharding threshold low enough that every
+// directory becomes a HAMT, and checks that the root is one.
+func importSharded(t *testing.T, bs blockstore.Blockstore, tree map[string][]byte) string {
+	t.Helper()
+
+	threshold := uio.HAMTShardingSize
+	uio.HAMTShardingSize = 256
+	defer func() { uio.HAMTShardingSize = threshold }()
+	root := importFixture(t, bs, tree, nil).RootCid
+
+	c, err := cid.Parse(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blk, err := bs.Get(context.Background(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pn, err := merkledag.DecodeProtobuf(blk.RawData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsn, err := unixfs.FSNodeFromBytes(pn.Data())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fsn.Type() != unixfs.THAMTShard {
+		t.Fatalf("root is a %v directory, want a sharded one", fsn.Type())
+	}
+	return root
+}
+
+func TestExtractor_WithDeterministicOrder(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	tree := make(map[string][]byte)
+	for i := 0; i < 200; i++ {
+		tree[fmt.Sprintf("file-%03d.txt", i)] = []byte(fmt.Sprintf("root %d", i))
+	}
+	for i := 0; i < 50; i++ {
+		tree[fmt.Sprintf("sub/file-%03d.txt", i)] = []byte(fmt.Sprintf("sub %d", i))
+	}
+	root := importSharded(t, bs, tree)
+
+	want := make([]string, 0, len(tree))
+	for rel := range tree {
+		want = append(want, rel)
+	}
+	sort.Strings(want)
+
+	extract := func(ordered bool) *recordingDestination {
+		t.Helper()
+		mem := NewMemoryDestination()
+		dst := &recordingDestination{Destination: mem}
+		ext := NewExtractor(bs, root, "unused").WithDestination(dst).WithDeterministicOrder(ordered)
+		if err := ext.Extract(context.Background(), false); err != nil {
+			t.Fatalf("Extract failed: %v", err)
+		}
+
+		got := mem.Files()
+		if len(got) != len(tree) {
+			t.Fatalf("extracted %d files, want %d", len(got), len(tree))
+		}
+		for rel, data := range tree {
+			if !bytes.Equal(got[rel], data) {
+				t.Errorf("%s: content differs", rel)
+			}
+		}
+		return dst
+	}
+
+	first, second := extract(true), extract(true)
+	for i := range want {
+		if first.finalized[i] != want[i] || second.finalized[i] != want[i] {
+			t.Fatalf("file %d created as %s then %s, want %s", i, first.finalized[i], second.finalized[i], want[i])
+		}
+	}
+
+	// DAG order, which follows the name hashes in a sharded directory,
+	// extracts the same files
+	extract(false)
+}
@@ -0,0 +1,176 @@
+package extractor
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/ipfs/boxo/files"
+)
+
+// WithConcurrency writes up to n files of a directory at once, which speeds
+// up trees of many small files. Zero or a negative value uses
+// runtime.NumCPU(); 1 writes files one at a time, which is the default.
+//
+// Each file is still written to its own part file and renamed into place,
+// and files are finalized, checked by the finalize hook and recorded in the
+// state file in directory order, so the FileCompleted events come in the
+// same order as with a sequential extraction. A directory is only created
+// once the files before it are finished, so parents always exist before
+// their children. FileStarted events of up to n files may precede the
+// FileCompleted event of the first, and progress callbacks name whichever
+// file was written last but never run concurrently. When a file fails and
+// the error policy stops the extraction, the files started after it are
+// cancelled, their part files removed, and the error of the first failed
+// file in directory order is returned. Files written concurrently are not read ahead by WithReadAhead.
+//
+// A Destination set with WithDestination must be safe for concurrent use
+// when n is greater than 1; the filesystem and MemoryDestination are.
+// Returns the extractor instance for method chaining.
+func (ext *Extractor) WithConcurrency(n int) *Extractor {
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	ext.workers = n
+	return ext
+}
+
+// fileQueue writes the files of one directory on up to n goroutines and
+// finishes them in directory order on the extraction's goroutine. The
+// state, the summary and the results of the extraction are only touched
+// when a file is started or finished.
+type fileQueue struct {
+	ext     *Extractor
+	ctx     context.Context // Cancelled when the directory stops
+	cancel  context.CancelFunc
+	n       int
+	pending []*fileJob // Files being written or waiting to be finished, in order
+}
+
+// fileJob is a file of the queue.
+type fileJob struct {
+	w         *fileWrite
+	nd        files.Node
+	path      string
+	truncated *TruncatedFile // Set if the selector truncated the file
+	failed    int            // Failed entries before the file
+	done      chan struct{}  // Closed once the data is written
+	err       error          // Error writing the data
+}
+
+// newFileQueue returns the queue for a directory, or nil if files are
+// written one at a time.
+func (ext *Extractor) newFileQueue(ctx context.Context) *fileQueue {
+	if ext.workers <= 1 {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &fileQueue{ext: ext, ctx: ctx, cancel: cancel, n: ext.workers}
+}
+
+// accepts returns node as a file if the queue writes it.
+func (q *fileQueue) accepts(node files.Node) (files.File, bool) {
+	if q == nil {
+		return nil, false
+	}
+	if _, ok := node.(*files.Symlink); ok {
+		return nil, false
+	}
+	file, ok := node.(files.File)
+	return file, ok
+}
+
+// add starts writing the file node to path, first finishing the oldest
+// file if n files are pending. Files that need not be written, or fail
+// before their data is written, are finished right away.
+func (q *fileQueue) add(nd files.Node, file files.File, path string, allowOverwrite bool, relativePath string, truncated *TruncatedFile) error {
+	ext := q.ext
+	if len(q.pending) == q.n {
+		if err := q.finishNext(); err != nil {
+			return err
+		}
+	}
+
+	job := &fileJob{nd: nd, path: path, truncated: truncated, failed: len(ext.summary.Failed)}
+	allowOverwrite, skip, err := ext.beginEntry(q.ctx, nd, path, allowOverwrite, relativePath)
+	if skip || err != nil {
+		if err != nil {
+			return err
+		}
+		return q.end(job, nil)
+	}
+
+	done, err := ext.clearEntry(q.ctx, nd, allowOverwrite, relativePath)
+	if !done && err == nil {
+		job.w, err = ext.startFileWrite(q.ctx, file, relativePath)
+	}
+	if job.w == nil {
+		return q.end(job, ext.endEntry(q.ctx, nd, path, relativePath, err))
+	}
+
+	job.done = make(chan struct{})
+	q.pending = append(q.pending, job)
+	go func() {
+		defer close(job.done)
+		job.err = ext.writeFileData(job.w)
+	}()
+	return nil
+}
+
+// finishNext waits for the oldest pending file and finishes it.
+func (q *fileQueue) finishNext() error {
+	job := q.pending[0]
+	q.pending = q.pending[1:]
+	<-job.done
+	return q.finish(job)
+}
+
+// finish finalizes the file of job after its data was written.
+func (q *fileQueue) finish(job *fileJob) error {
+	err := q.ext.finishFileWrite(job.w, job.err)
+	return q.end(job, q.ext.endEntry(q.ctx, job.nd, job.path, job.w.rel, err))
+}
+
+// end records the file of job as truncated if the selector truncated it
+// and it did not fail. err is the outcome of the entry.
+func (q *fileQueue) end(job *fileJob, err error) error {
+	if err != nil {
+		return err
+	}
+	if job.truncated != nil && len(q.ext.summary.Failed) == job.failed {
+		q.ext.truncated = append(q.ext.truncated, *job.truncated)
+	}
+	return nil
+}
+
+// drain finishes all pending files in order.
+func (q *fileQueue) drain() error {
+	if q == nil {
+		return nil
+	}
+	for len(q.pending) > 0 {
+		if err := q.finishNext(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stop cancels the files still pending after the directory stopped and
+// waits for their goroutines. A cancelled file has removed its part file;
+// a file whose data was already written is finalized, as cancellation
+// never discards complete data.
+func (q *fileQueue) stop() {
+	if q == nil {
+		return
+	}
+	q.cancel()
+	for _, job := range q.pending {
+		<-job.done
+		if job.err == nil {
+			_ = q.finish(job)
+		} else {
+			_ = q.ext.finishFileWrite(job.w, job.err)
+		}
+	}
+	q.pending = nil
+}
@@ -0,0 +1,107 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// manyFilesTree returns a tree of many small files in a few directories and
+// one larger file.
+func manyFilesTree() map[string][]byte {
+	tree := make(map[string][]byte)
+	for i := 0; i < 200; i++ {
+		tree[fmt.Sprintf("d%d/f%03d.txt", i%3, i)] = []byte(fmt.Sprintf("content of file %d", i))
+	}
+	tree["top.txt"] = []byte("top")
+	tree["d0/sub/deep.txt"] = []byte("deep")
+	tree["d1/large.bin"] = readAheadData(2*1024*1024 + 5)
+	return tree
+}
+
+func TestExtractor_WithConcurrency_MatchesSequential(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+	tree := manyFilesTree()
+	root := importFixture(t, bs, tree, nil).RootCid
+
+	extractWith := func(workers int) (map[string][]byte, ExtractSummary) {
+		out := filepath.Join(t.TempDir(), "out")
+		var last, total int64
+		ext := NewExtractor(bs, root, out).
+			WithConcurrency(workers).
+			WithProgress(func(completed, all int64, _ string) {
+				if completed < last {
+					t.Errorf("workers=%d: progress went back from %d to %d", workers, last, completed)
+				}
+				last, total = completed, all
+			})
+		if err := ext.Extract(context.Background(), false); err != nil {
+			t.Fatalf("workers=%d: Extract failed: %v", workers, err)
+		}
+		if last != total {
+			t.Errorf("workers=%d: last progress %d, want %d", workers, last, total)
+		}
+		return readTree(t, out), ext.Summary()
+	}
+
+	sequential, seqSummary := extractWith(1)
+	parallel, parSummary := extractWith(8)
+	if !reflect.DeepEqual(parallel, tree) {
+		t.Errorf("parallel extraction wrote %d files, want the %d imported", len(parallel), len(tree))
+	}
+	if !reflect.DeepEqual(parallel, sequential) {
+		t.Error("parallel extraction differs from the sequential one")
+	}
+	if !reflect.DeepEqual(parSummary, seqSummary) {
+		t.Errorf("Summary() = %+v, want %+v", parSummary, seqSummary)
+	}
+}
+
+func TestExtractor_WithConcurrency_FailFast(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+	root := importFixture(t, bs, manyFilesTree(), nil).RootCid
+
+	out := filepath.Join(t.TempDir(), "out")
+	fail := map[string]bool{"d1/f100.txt": true}
+	ext := NewExtractor(bs, root, out).
+		WithConcurrency(8).
+		WithDestination(&pathFailingDestination{Destination: &fsDestination{root: out, base: out}, fail: fail})
+
+	if err := ext.Extract(context.Background(), false); !errors.Is(err, errInjected) {
+		t.Fatalf("Extract returned %v, want the injected failure", err)
+	}
+
+	// Files before the failed one are written, the ones after it stopped
+	// without leaving part files behind
+	got := readTree(t, out)
+	for rel := range got {
+		if strings.HasSuffix(rel, partFileSuffix) {
+			t.Errorf("part file %s was left behind", rel)
+		}
+	}
+	if _, ok := got["d1/f097.txt"]; !ok {
+		t.Error("d1/f097.txt, listed before the failed file, was not written")
+	}
+	if _, ok := got["d2/f002.txt"]; ok {
+		t.Error("d2/f002.txt was written after the extraction failed")
+	}
+	if len(got) >= len(manyFilesTree()) {
+		t.Errorf("%d files written despite the failure", len(got))
+	}
+	err := filepath.WalkDir(out, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() && filepath.Base(path) == "d2" {
+			t.Error("directory d2 was created after the extraction failed")
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,216 @@
+package extractor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/files"
+	"github.com/ipfs/boxo/ipld/merkledag"
+)
+
+// PlanAction is what Extract would do with an entry of an ExtractPlan.
+type PlanAction int
+
+const (
+	// ActionCreate writes an entry where nothing exists yet.
+	ActionCreate PlanAction = iota
+
+	// ActionOverwrite replaces an existing file with different content.
+	ActionOverwrite
+
+	// ActionSkipSameSize keeps an existing file of the same size whose
+	// content passes the content check, see WithContentCheck.
+	ActionSkipSameSize
+
+	// ActionConflict removes an existing entry of another type, such as a
+	// directory where the DAG has a file, together with everything below it,
+	// and writes the entry in its place.
+	ActionConflict
+)
+
+// String returns the name of the action.
+func (a PlanAction) String() string {
+	switch a {
+	case ActionCreate:
+		return "create"
+	case ActionOverwrite:
+		return "overwrite"
+	case ActionSkipSameSize:
+		return "skip"
+	case ActionConflict:
+		return "conflict"
+	default:
+		return fmt.Sprintf("PlanAction(%d)", int(a))
+	}
+}
+
+// PlanEntry is an entry of an ExtractPlan.
+type PlanEntry struct {
+	Path   string     // Slash path relative to the extraction root; empty for the root itself
+	Size   int64      // Size of the file in the DAG; 0 for directories and symlinks
+	IsDir  bool       // The entry is a directory
+	Action PlanAction // What Extract would do with the entry
+}
+
+// ExtractPlan lists what Extract(ctx, true) would write. Existing
+// directories that the DAG merges into are not listed; the entries below
+// them are.
+type ExtractPlan struct {
+	Entries    []PlanEntry // Entries in the order Extract writes them
+	WriteBytes int64       // Bytes of the files that would be written, not counting skipped ones
+}
+
+// Plan walks the DAG like Extract(ctx, true) and compares every entry with
+// the destination, without writing or removing anything. It applies the
+// same name cleaning, selector, content check and ExtractPath as Extract,
+// so the plan matches what a following Extract does as long as the
+// destination does not change in between.
+//
+// A state file is not consulted, so entries a previous run completed are
+// planned like any other. Plan fails on the first entry Extract would fail
+// on, such as an invalid name or symlink target, whatever the ErrorPolicy.
+func (ext *Extractor) Plan(ctx context.Context) (*ExtractPlan, error) {
+	if !ext.isSubPath(ext.path, ext.basePath) {
+		return nil, ErrPathTraversal
+	}
+
+	ds := merkledag.NewDAGService(blockservice.New(ext.blockStore, nil))
+	root, _, err := openPath(ctx, ds, ext.cid, ext.inner)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+
+	// The content check remembers files found to differ for the next write
+	stale := ext.stale
+	defer func() { ext.stale = stale }()
+
+	plan := &ExtractPlan{}
+	if err := ext.planEntry(ctx, plan, root, "", false); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// planEntry adds the entry nd at relativePath, and the entries below it, to
+// plan. fresh is set below a directory that does not exist yet.
+func (ext *Extractor) planEntry(ctx context.Context, plan *ExtractPlan, nd files.Node, relativePath string, fresh bool) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	dst := ext.destination()
+	rel := destPath(relativePath)
+	var existing fileInfo
+	if !fresh {
+		var err error
+		if existing, err = statEntry(dst, rel); err != nil {
+			return err
+		}
+	}
+
+	isDir := ext.isDir(nd)
+	var size int64
+	if !isDir {
+		var err error
+		if size, err = nd.Size(); err != nil {
+			return fmt.Errorf("failed to get node size: %w", err)
+		}
+	}
+
+	action := ActionCreate
+	if existing.exists {
+		switch {
+		case existing.IsDir() && isDir:
+			return ext.planDir(ctx, plan, nd.(files.Directory), relativePath, false)
+		case shouldSkipExistingFile(existing.FileInfo, size, isDir):
+			same, err := ext.sameAsExisting(ctx, nd, relativePath, size)
+			ext.stale = ""
+			if err != nil {
+				return err
+			}
+			action = ActionOverwrite
+			if same {
+				action = ActionSkipSameSize
+			}
+		case existing.Mode().IsRegular() && !isDir:
+			action = ActionOverwrite
+			if _, ok := nd.(*files.Symlink); ok {
+				action = ActionConflict
+			}
+		default:
+			action = ActionConflict
+		}
+	}
+
+	entry := PlanEntry{Path: rel, IsDir: isDir, Action: action}
+	switch node := nd.(type) {
+	case *files.Symlink:
+		if !ext.isValidSymlinkTarget(node.Target) {
+			return wrapInvalidSymlinkTarget(node.Target)
+		}
+		if _, ok := dst.(SymlinkDestination); !ok {
+			return wrapSymlinkUnsupported(filepath.Join(ext.path, relativePath))
+		}
+	case files.File:
+		entry.Size = size
+		if action != ActionSkipSameSize {
+			plan.WriteBytes += size
+		}
+	case files.Directory:
+		plan.Entries = append(plan.Entries, entry)
+		return ext.planDir(ctx, plan, node, relativePath, true)
+	default:
+		return wrapUnsupportedFileType(filepath.Join(ext.path, relativePath), node)
+	}
+	plan.Entries = append(plan.Entries, entry)
+	return nil
+}
+
+// planDir adds the entries of dir at relativePath to plan, named and
+// selected as processDirectory does. fresh is set if dir does not exist yet.
+func (ext *Extractor) planDir(ctx context.Context, plan *ExtractPlan, dir files.Directory, relativePath string, fresh bool) error {
+	entries, err := ext.entries(ctx, dir)
+	if err != nil {
+		return err
+	}
+
+	for entries.Next() {
+		entryName := entries.Name()
+		if entryName == "" || entryName == "." || entryName == ".." {
+			return wrapInvalidDirectoryEntry(entryName)
+		}
+		if isXattrMetadata(relativePath, entryName) {
+			continue
+		}
+		cleanedName, err := normalizeEntryName(entryName)
+		if err != nil {
+			return err
+		}
+		childRelPath := filepath.Join(relativePath, cleanedName)
+
+		node := entries.Node()
+		entryNode, truncated, selected, err := ext.selectEntry(node, childRelPath)
+		if u, ok := node.(*unreadableNode); ok && (selected || err != nil) {
+			err = wrapReadFailed(childRelPath, u.err)
+		}
+		if err != nil {
+			return err
+		}
+		if !selected {
+			continue
+		}
+		if truncated != nil {
+			childRelPath += partialFileSuffix
+		}
+
+		if err := ext.planEntry(ctx, plan, entryNode, childRelPath, fresh); err != nil {
+			return err
+		}
+	}
+	return entries.Err()
+}
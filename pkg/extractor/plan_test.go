@@ -0,0 +1,111 @@
+package extractor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExtractor_Plan(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	tree := map[string][]byte{
+		"same.txt":     []byte("unchanged"),
+		"edited.txt":   []byte("edited"),
+		"resized.txt":  []byte("resized"),
+		"missing.txt":  []byte("missing"),
+		"dir/file.txt": []byte("replaced by a file"),
+		"sub/new.txt":  []byte("new"),
+	}
+	result := importFixture(t, bs, tree, nil)
+
+	out := filepath.Join(t.TempDir(), "out")
+	if err := NewExtractor(bs, result.RootCid, out).Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	write := func(rel, data string) {
+		if err := os.WriteFile(filepath.Join(out, filepath.FromSlash(rel)), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("edited.txt", "EDITED")
+	write("resized.txt", "resized, longer")
+	if err := os.Remove(filepath.Join(out, "missing.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(out, "dir")); err != nil {
+		t.Fatal(err)
+	}
+	write("dir", "a file where the DAG has a directory")
+	if err := os.RemoveAll(filepath.Join(out, "sub")); err != nil {
+		t.Fatal(err)
+	}
+
+	ext := NewExtractor(bs, result.RootCid, out).WithContentCheck(FullHash)
+	plan, err := ext.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+
+	want := []PlanEntry{
+		{Path: "dir", IsDir: true, Action: ActionConflict},
+		{Path: "dir/file.txt", Size: 18, Action: ActionCreate},
+		{Path: "edited.txt", Size: 6, Action: ActionOverwrite},
+		{Path: "missing.txt", Size: 7, Action: ActionCreate},
+		{Path: "resized.txt", Size: 7, Action: ActionOverwrite},
+		{Path: "same.txt", Size: 9, Action: ActionSkipSameSize},
+		{Path: "sub", IsDir: true, Action: ActionCreate},
+		{Path: "sub/new.txt", Size: 3, Action: ActionCreate},
+	}
+	if !reflect.DeepEqual(plan.Entries, want) {
+		t.Errorf("Entries = %+v, want %+v", plan.Entries, want)
+	}
+	if plan.WriteBytes != 18+6+7+7+3 {
+		t.Errorf("WriteBytes = %d, want %d", plan.WriteBytes, 18+6+7+7+3)
+	}
+
+	// Planning writes nothing
+	if data, err := os.ReadFile(filepath.Join(out, "edited.txt")); err != nil || string(data) != "EDITED" {
+		t.Errorf("edited.txt = %q, %v after Plan", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "sub")); !os.IsNotExist(err) {
+		t.Errorf("sub exists after Plan: %v", err)
+	}
+
+	// The extraction does what was planned
+	if err := ext.Extract(context.Background(), true); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if s := ext.Summary(); s.Skipped != 1 || s.Written != 5 || s.Replaced != 1 {
+		t.Errorf("Summary() = %+v, want 1 skipped and 5 written, 1 of them replaced", s)
+	}
+	if got := readTree(t, out); !reflect.DeepEqual(got, tree) {
+		t.Errorf("extracted %v, want %v", got, tree)
+	}
+}
+
+func TestExtractor_Plan_Empty(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	result := importFixture(t, bs, map[string][]byte{"a.txt": []byte("alpha")}, nil)
+	out := filepath.Join(t.TempDir(), "out")
+	plan, err := NewExtractor(bs, result.RootCid, out).Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+
+	want := []PlanEntry{
+		{Path: "", IsDir: true, Action: ActionCreate},
+		{Path: "a.txt", Size: 5, Action: ActionCreate},
+	}
+	if !reflect.DeepEqual(plan.Entries, want) || plan.WriteBytes != 5 {
+		t.Errorf("plan = %+v, want %+v and 5 bytes", plan, want)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("%s exists after Plan: %v", out, err)
+	}
+}
@@ -0,0 +1,162 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ipfs/boxo/files"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// ErrorPolicy decides what Extract does when an entry below the root cannot
+// be extracted.
+type ErrorPolicy int
+
+const (
+	// FailFast stops the extraction at the first failed entry and returns
+	// its error. It is the default.
+	FailFast ErrorPolicy = iota
+
+	// ContinueOnError records a failed entry in the summary and goes on with
+	// the next one. Extract then returns a *PartialExtractionError if any
+	// entry failed.
+	ContinueOnError
+)
+
+// FailureCategory classifies why an entry could not be extracted.
+type FailureCategory string
+
+const (
+	FailurePath        FailureCategory = "path"        // Invalid or unsafe entry name or symlink target
+	FailureExists      FailureCategory = "exists"      // The path exists and overwriting is not allowed
+	FailureRead        FailureCategory = "read"        // The entry could not be read, such as a missing block
+	FailureRejected    FailureCategory = "rejected"    // Rejected by the finalize hook or checksum verification
+	FailureUnsupported FailureCategory = "unsupported" // File type the destination cannot hold
+	FailureWrite       FailureCategory = "write"       // Writing to the destination failed
+)
+
+// FailedEntry is an entry that could not be extracted.
+type FailedEntry struct {
+	Path     string          // Path relative to the extraction root
+	Category FailureCategory // Why the entry failed
+	Err      error           // The failure, as a *PathError for the entry
+}
+
+// ExtractSummary is the outcome of the entries of an extraction.
+type ExtractSummary struct {
+	Written  int           // Files and symlinks written, including files linked from a reference directory
+	Skipped  int           // Files skipped as intended: already present, completed by a previous run or rejected with WithSkipRejected
+	Replaced int           // Existing files of the same size overwritten because WithContentCheck found different content
+	Failed   []FailedEntry // Entries that failed under ContinueOnError, in extraction order
+}
+
+// PartialExtractionError is returned by Extract under ContinueOnError when
+// one or more entries failed. The other entries were extracted.
+type PartialExtractionError struct {
+	Summary ExtractSummary
+}
+
+func (e *PartialExtractionError) Error() string {
+	first := e.Summary.Failed[0]
+	if len(e.Summary.Failed) == 1 {
+		return fmt.Sprintf("%v: %v", ErrPartialExtraction, first.Err)
+	}
+	return fmt.Sprintf("%v: %d entries failed, first: %v", ErrPartialExtraction, len(e.Summary.Failed), first.Err)
+}
+
+// Unwrap exposes ErrPartialExtraction and the first failure, so that
+// errors.Is matches either.
+func (e *PartialExtractionError) Unwrap() []error {
+	return []error{ErrPartialExtraction, e.Summary.Failed[0].Err}
+}
+
+// WithErrorPolicy sets what happens when an entry below the root cannot be
+// extracted. With FailFast, the default, Extract returns the first failure
+// and extracts nothing after it. With ContinueOnError every failure is
+// recorded and the remaining entries are still extracted; Extract returns
+// nil only if no entry failed, and a *PartialExtractionError carrying the
+// summary otherwise. Cancellation and interruption stop the extraction
+// under either policy, and a root that cannot be extracted at all is
+// returned as is.
+// Returns the extractor instance for method chaining.
+func (ext *Extractor) WithErrorPolicy(policy ErrorPolicy) *Extractor {
+	ext.policy = policy
+	return ext
+}
+
+// Summary returns the outcome of the entries of the last extraction.
+func (ext *Extractor) Summary() ExtractSummary {
+	return ext.summary
+}
+
+// entryFailed handles the failure of the entry at relativePath: it returns
+// err when the extraction must stop, and records the entry and returns nil
+// when it continues.
+func (ext *Extractor) entryFailed(ctx context.Context, relativePath string, err error) error {
+	if ext.policy != ContinueOnError || relativePath == "" || ctx.Err() != nil || errors.Is(err, ErrInterrupted) {
+		return err
+	}
+
+	var pathErr *PathError
+	if !errors.As(err, &pathErr) {
+		err = &PathError{Path: relativePath, Op: "extract", Err: err}
+	}
+	ext.summary.Failed = append(ext.summary.Failed, FailedEntry{
+		Path:     relativePath,
+		Category: failureCategory(err),
+		Err:      err,
+	})
+	return nil
+}
+
+// partialError returns the error reporting the failed entries of the
+// extraction, or nil if none failed.
+func (ext *Extractor) partialError() error {
+	if len(ext.summary.Failed) == 0 {
+		return nil
+	}
+	return &PartialExtractionError{Summary: ext.summary}
+}
+
+// failureCategory classifies the failure of an entry.
+func failureCategory(err error) FailureCategory {
+	switch {
+	case errors.Is(err, ErrFileRejected), errors.Is(err, ErrChecksumMismatch):
+		return FailureRejected
+	case errors.Is(err, ErrPathExistsOverwrite):
+		return FailureExists
+	case errors.Is(err, ErrPathTraversal), errors.Is(err, ErrPathTraversalAttempt),
+		errors.Is(err, ErrInvalidPathComponent), errors.Is(err, ErrInvalidDirectoryEntry),
+		errors.Is(err, ErrInvalidSymlinkTarget):
+		return FailurePath
+	case errors.Is(err, ErrUnsupportedFileType), errors.Is(err, ErrSymlinkUnsupported):
+		return FailureUnsupported
+	case ipld.IsNotFound(err), isReadError(err):
+		return FailureRead
+	default:
+		return FailureWrite
+	}
+}
+
+// isReadError reports whether err is the failure to load an entry.
+func isReadError(err error) bool {
+	var pathErr *PathError
+	return errors.As(err, &pathErr) && pathErr.Op == "read"
+}
+
+// unreadableNode stands for a directory entry that could not be loaded, so
+// that the failure is reported for that entry instead of ending the
+// directory.
+type unreadableNode struct {
+	err error
+}
+
+var _ files.Node = (*unreadableNode)(nil)
+
+func (n *unreadableNode) Close() error         { return nil }
+func (n *unreadableNode) Size() (int64, error) { return 0, n.err }
+func (n *unreadableNode) Mode() os.FileMode    { return 0 }
+func (n *unreadableNode) ModTime() time.Time   { return time.Time{} }
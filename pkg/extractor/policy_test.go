@@ -0,0 +1,125 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+var errInjected = errors.New("injected write failure")
+
+// pathFailingDestination fails to create the files listed in fail.
+type pathFailingDestination struct {
+	Destination
+	fail map[string]bool // Slash paths
+}
+
+func (d *pathFailingDestination) CreateFile(relPath string) (io.WriteCloser, error) {
+	if d.fail[filepath.ToSlash(relPath)] {
+		return nil, errInjected
+	}
+	return d.Destination.CreateFile(relPath)
+}
+
+func TestExtractor_WithErrorPolicy(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	root := importFixture(t, bs, map[string][]byte{
+		"a.txt":     []byte("a"),
+		"b.txt":     []byte("b"),
+		"c.txt":     []byte("c"),
+		"sub/d.txt": []byte("d"),
+		"sub/e.txt": []byte("e"),
+	}, nil).RootCid
+	fail := map[string]bool{"b.txt": true, "sub/d.txt": true}
+
+	t.Run("ContinueOnError", func(t *testing.T) {
+		mem := NewMemoryDestination()
+		ext := NewExtractor(bs, root, "unused").
+			WithDestination(&pathFailingDestination{Destination: mem, fail: fail}).
+			WithErrorPolicy(ContinueOnError)
+
+		err := ext.Extract(context.Background(), false)
+		var partial *PartialExtractionError
+		if !errors.As(err, &partial) {
+			t.Fatalf("Extract returned %v, want a *PartialExtractionError", err)
+		}
+		if !errors.Is(err, ErrPartialExtraction) || !errors.Is(err, errInjected) {
+			t.Errorf("error %v does not match ErrPartialExtraction and the first failure", err)
+		}
+
+		summary := partial.Summary
+		wantFailed := []string{"b.txt", "sub/d.txt"}
+		if len(summary.Failed) != len(wantFailed) {
+			t.Fatalf("failed entries %+v, want %v", summary.Failed, wantFailed)
+		}
+		for i, f := range summary.Failed {
+			if filepath.ToSlash(f.Path) != wantFailed[i] || f.Category != FailureWrite {
+				t.Errorf("failed entry %d is %s (%s), want %s (%s)", i, f.Path, f.Category, wantFailed[i], FailureWrite)
+			}
+			var pathErr *PathError
+			if !errors.As(f.Err, &pathErr) || !errors.Is(f.Err, errInjected) {
+				t.Errorf("failed entry %s has error %v", f.Path, f.Err)
+			}
+		}
+		if summary.Written != 3 || summary.Skipped != 0 {
+			t.Errorf("written %d, skipped %d; want 3, 0", summary.Written, summary.Skipped)
+		}
+		if got := ext.Summary(); len(got.Failed) != 2 || got.Written != 3 {
+			t.Errorf("Summary() = %+v, want the summary of the error", got)
+		}
+
+		files := mem.Files()
+		for _, rel := range []string{"a.txt", "c.txt", "sub/e.txt"} {
+			if _, ok := files[rel]; !ok {
+				t.Errorf("%s was not extracted", rel)
+			}
+		}
+		for rel := range fail {
+			if _, ok := files[rel]; ok {
+				t.Errorf("failed file %s was extracted", rel)
+			}
+		}
+	})
+
+	t.Run("FailFast", func(t *testing.T) {
+		mem := NewMemoryDestination()
+		ext := NewExtractor(bs, root, "unused").
+			WithDestination(&pathFailingDestination{Destination: mem, fail: fail})
+
+		err := ext.Extract(context.Background(), false)
+		if !errors.Is(err, errInjected) {
+			t.Fatalf("Extract returned %v, want the injected failure", err)
+		}
+		if errors.Is(err, ErrPartialExtraction) {
+			t.Errorf("FailFast returned a partial extraction: %v", err)
+		}
+
+		// Entries are processed in sorted order, so only a.txt precedes the failure
+		files := mem.Files()
+		if len(files) != 1 {
+			t.Errorf("extracted %d files, want only a.txt", len(files))
+		}
+		if _, ok := files["a.txt"]; !ok {
+			t.Error("a.txt was not extracted")
+		}
+		if got := ext.Summary(); len(got.Failed) != 0 || got.Written != 1 {
+			t.Errorf("Summary() = %+v, want 1 written and no failures", got)
+		}
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		ext := NewExtractor(bs, root, "unused").
+			WithDestination(NewMemoryDestination()).
+			WithErrorPolicy(ContinueOnError)
+		if err := ext.Extract(context.Background(), false); err != nil {
+			t.Fatalf("Extract failed: %v", err)
+		}
+		if got := ext.Summary(); got.Written != 5 || len(got.Failed) != 0 {
+			t.Errorf("Summary() = %+v, want 5 written", got)
+		}
+	})
+}
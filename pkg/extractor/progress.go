@@ -8,12 +8,41 @@ import (
 // Parameters: completed bytes, total bytes, current file being extracted
 type progressCallback func(completed, total int64, currentFile string)
 
-// progressTracker tracks extraction progress and handles concurrency-safe state
+// eventCallback receives detailed extraction progress.
+type eventCallback func(ProgressEvent)
+
+// ProgressEvent is the progress reported to a WithProgressEvents callback.
+type ProgressEvent struct {
+	Phase            Phase  // Phase of the extraction; PhaseDone in the last event
+	TotalBytes       int64  // Total bytes to extract; 0 when unknown
+	CompletedBytes   int64  // Bytes extracted so far
+	CurrentFile      string // Path of the file the event is about; empty when the phase changes
+	CurrentFileBytes int64  // Bytes of the current file written so far
+	CurrentFileTotal int64  // Size of the current file's data
+	FilesCompleted   int    // Files moved to their final path so far
+	FilesTotal       int    // Files to extract; 0 if unknown
+}
+
+// fileProgress is the progress of a file being written.
+type fileProgress struct {
+	path    string
+	written int64
+	total   int64
+}
+
+// progressTracker tracks extraction progress and handles concurrency-safe state.
+// files holds the files being written by relative path; it and the methods
+// sending progress events are guarded by the extractor's progressMu.
 type progressTracker struct {
 	totalBytes     int64            // Total bytes to extract
 	completedBytes atomic.Int64     // Bytes extracted so far
 	isInterrupted  atomic.Int32     // 1 if extraction is interrupted, 0 otherwise
 	callback       progressCallback // Optional callback for progress updates
+	onEvent        eventCallback    // Optional receiver of progress events
+	phase          Phase            // Phase reported in progress events
+	files          map[string]*fileProgress
+	filesDone      int // Files completed
+	filesTotal     int // Files to extract; 0 if unknown
 }
 
 // newProgressTracker creates a new progress tracker
@@ -30,18 +59,99 @@ func (pt *progressTracker) setTotal(totalBytes int64) {
 }
 
 // update adds the specified number of bytes to the completed count and triggers
-// the progress callback if one is registered.
-func (pt *progressTracker) update(bytes int64, filename string) {
+// the progress callback if one is registered. It returns the new completed count.
+func (pt *progressTracker) update(bytes int64, filename string) int64 {
 	completed := pt.completedBytes.Add(bytes)
 	if pt.callback != nil {
 		total := atomic.LoadInt64(&pt.totalBytes)
 		pt.callback(completed, total, filename)
 	}
+	return completed
+}
+
+// updateFile is update for bytes written to the file at relativePath,
+// also sending a progress event.
+func (pt *progressTracker) updateFile(bytes int64, relativePath string) int64 {
+	completed := pt.update(bytes, relativePath)
+	if pt.onEvent != nil {
+		file := pt.files[relativePath]
+		if file == nil {
+			// Bytes of a file that was not started, such as an existing one kept
+			file = &fileProgress{path: relativePath, written: bytes, total: bytes}
+		} else {
+			file.written += bytes
+		}
+		pt.emit(file)
+	}
+	return completed
+}
+
+// resetFiles sets the receiver of progress events for a new extraction and
+// clears the counts of files of the previous one.
+func (pt *progressTracker) resetFiles(onEvent eventCallback) {
+	pt.onEvent = onEvent
+	pt.phase = ""
+	pt.files = nil
+	pt.filesDone = 0
+	pt.filesTotal = 0
+}
+
+// startFile reports that the file at relativePath is being written.
+func (pt *progressTracker) startFile(relativePath string, size int64) {
+	if pt.onEvent == nil {
+		return
+	}
+	if pt.files == nil {
+		pt.files = make(map[string]*fileProgress)
+	}
+	file := &fileProgress{path: relativePath, total: size}
+	pt.files[relativePath] = file
+	pt.emit(file)
+}
+
+// finishFile reports that the file at relativePath is in its final place.
+func (pt *progressTracker) finishFile(relativePath string) {
+	if pt.onEvent == nil {
+		return
+	}
+	file := pt.files[relativePath]
+	delete(pt.files, relativePath)
+	pt.filesDone++
+	if file != nil {
+		pt.emit(file)
+	}
+}
+
+// setPhase reports the phase the extraction entered.
+func (pt *progressTracker) setPhase(p Phase) {
+	pt.phase = p
+	pt.emit(nil)
+}
+
+// emit sends a progress event about file, or about the extraction if file
+// is nil.
+func (pt *progressTracker) emit(file *fileProgress) {
+	if pt.onEvent == nil {
+		return
+	}
+	ev := ProgressEvent{
+		Phase:          pt.phase,
+		TotalBytes:     pt.getTotal(),
+		CompletedBytes: pt.getCompleted(),
+		FilesCompleted: pt.filesDone,
+		FilesTotal:     pt.filesTotal,
+	}
+	if file != nil {
+		ev.CurrentFile = file.path
+		ev.CurrentFileBytes = file.written
+		ev.CurrentFileTotal = file.total
+	}
+	pt.onEvent(ev)
 }
 
 // getTotal returns the total bytes to extract
 func (pt *progressTracker) getTotal() int64 {
-	return pt.totalBytes
+	return atomic.LoadInt64(&pt.totalBytes)
 }
 
 // getCompleted returns the number of bytes extracted so far
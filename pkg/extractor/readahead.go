@@ -0,0 +1,195 @@
+package extractor
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/files"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// ReadAheadStats describes the blocks prefetched during the last extraction.
+type ReadAheadStats struct {
+	Prefetched int   // Blocks read ahead of the writer
+	Hits       int   // Prefetched blocks the writer consumed
+	PeakBlocks int   // Most blocks buffered at the same time
+	PeakBytes  int64 // Most bytes buffered at the same time
+}
+
+// WithReadAhead prefetches up to blocks upcoming blocks of the file being
+// written on a background goroutine, so reading from the blockstore
+// overlaps with writing to the destination. The blocks are read through
+// the extractor's blockstore in the order the file needs them, so a
+// blockstore that verifies blocks or fetches missing ones from elsewhere
+// does so ahead of the writer. At most blocks blocks, and so at most blocks
+// times the largest block size of the DAG, are buffered at a time; the
+// buffer is released when each file is written. Zero or a negative value
+// disables read-ahead, which is the default.
+// Returns the extractor instance for method chaining.
+func (ext *Extractor) WithReadAhead(blocks int) *Extractor {
+	ext.readAhead = max(blocks, 0)
+	return ext
+}
+
+// ReadAheadStats returns what read-ahead prefetched during the last
+// extraction. It is zero without WithReadAhead.
+func (ext *Extractor) ReadAheadStats() ReadAheadStats {
+	if ext.prefetch == nil {
+		return ReadAheadStats{}
+	}
+	return ext.prefetch.stats()
+}
+
+// dagFile is a UnixFS file together with its CID, so that its blocks can
+// be prefetched.
+type dagFile struct {
+	files.File
+	cid cid.Cid
+}
+
+// startReadAhead starts prefetching the blocks of node and returns a
+// function stopping it and releasing the buffer. It does nothing without
+// read-ahead or for a node whose CID is unknown.
+func (ext *Extractor) startReadAhead(ctx context.Context, node files.File) func() {
+	f, ok := node.(*dagFile)
+	if ext.prefetch == nil || !ok {
+		return func() {}
+	}
+	return ext.prefetch.start(ctx, f.cid)
+}
+
+// readAheadBlockstore serves blocks from a prefetch buffer filled by a
+// background walk of the file being written, and reads any other block
+// from the underlying blockstore. A buffered block is handed out once and
+// then dropped.
+type readAheadBlockstore struct {
+	blockstore.Blockstore
+	dag ipld.DAGService // Reads for the walk, bypassing the buffer
+
+	mu       sync.Mutex
+	buffered map[cid.Cid]blocks.Block
+	taken    map[cid.Cid]struct{} // Blocks the writer read before they were prefetched
+	bytes    int64
+	slots    chan struct{} // One token per buffered block
+	st       ReadAheadStats
+}
+
+// newReadAheadBlockstore returns a blockstore buffering up to limit blocks
+// of bs ahead of the writer.
+func newReadAheadBlockstore(bs blockstore.Blockstore, limit int) *readAheadBlockstore {
+	return &readAheadBlockstore{
+		Blockstore: bs,
+		dag:        merkledag.NewDAGService(blockservice.New(bs, nil)),
+		buffered:   make(map[cid.Cid]blocks.Block),
+		taken:      make(map[cid.Cid]struct{}),
+		slots:      make(chan struct{}, limit),
+	}
+}
+
+// Get returns a prefetched block, or reads it from the underlying
+// blockstore if it has not been prefetched.
+func (b *readAheadBlockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	b.mu.Lock()
+	if blk, ok := b.buffered[c]; ok {
+		delete(b.buffered, c)
+		b.bytes -= int64(len(blk.RawData()))
+		b.st.Hits++
+		b.mu.Unlock()
+		<-b.slots
+		return blk, nil
+	}
+	b.taken[c] = struct{}{}
+	b.mu.Unlock()
+	return b.Blockstore.Get(ctx, c)
+}
+
+// start walks the DAG of the file root on a background goroutine and
+// returns a function stopping the walk and emptying the buffer.
+func (b *readAheadBlockstore) start(ctx context.Context, root cid.Cid) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// The writer already holds the root; a failed read is left for the
+		// writer to report
+		nd, err := b.dag.Get(ctx, root)
+		if err == nil {
+			_ = b.walk(ctx, nd.Links())
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+		b.reset()
+	}
+}
+
+// walk buffers the blocks below links depth-first, in the order the
+// writer reads them.
+func (b *readAheadBlockstore) walk(ctx context.Context, links []*ipld.Link) error {
+	for _, l := range links {
+		select {
+		case b.slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		nd, err := b.dag.Get(ctx, l.Cid)
+		if err != nil {
+			<-b.slots
+			return err
+		}
+		b.put(nd)
+		if err := b.walk(ctx, nd.Links()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// put buffers blk for the writer, holding the slot the walk acquired,
+// unless the writer has read it already.
+func (b *readAheadBlockstore) put(blk blocks.Block) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.st.Prefetched++
+	if _, ok := b.taken[blk.Cid()]; ok {
+		<-b.slots
+		return
+	}
+	if _, ok := b.buffered[blk.Cid()]; ok {
+		// A block repeated within the file is buffered once
+		<-b.slots
+		return
+	}
+	b.buffered[blk.Cid()] = blk
+	b.bytes += int64(len(blk.RawData()))
+	b.st.PeakBlocks = max(b.st.PeakBlocks, len(b.buffered))
+	b.st.PeakBytes = max(b.st.PeakBytes, b.bytes)
+}
+
+// reset drops every buffered block. The walk must have stopped.
+func (b *readAheadBlockstore) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// Every buffered block holds one slot
+	for range len(b.buffered) {
+		<-b.slots
+	}
+	clear(b.buffered)
+	clear(b.taken)
+	b.bytes = 0
+}
+
+// stats returns the prefetch statistics.
+func (b *readAheadBlockstore) stats() ReadAheadStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.st
+}
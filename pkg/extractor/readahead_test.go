@@ -0,0 +1,183 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/tragoedia0722/repository/pkg/importer"
+)
+
+// latencyBlockstore delays every Get, like a slow disk or a remote store.
+type latencyBlockstore struct {
+	blockstore.Blockstore
+	delay time.Duration
+}
+
+func (b *latencyBlockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	select {
+	case <-time.After(b.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return b.Blockstore.Get(ctx, c)
+}
+
+// slowWrites makes every write to the extractor's destination take delay.
+func slowWrites(ext *Extractor, delay time.Duration) *Extractor {
+	return ext.WithDestination(&writeHookDestination{
+		Destination: ext.destination(),
+		onWrite:     func(string, int) { time.Sleep(delay) },
+	})
+}
+
+// readAheadData returns size bytes of data that does not deduplicate.
+func readAheadData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i*31 + i>>16)
+	}
+	return data
+}
+
+func TestExtractor_WithReadAhead(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	data := readAheadData(8 << 20)
+	root := importFixture(t, bs, map[string][]byte{
+		"a.bin": data,
+		"b.bin": data[:3<<20],
+	}, nil).RootCid
+	out := t.TempDir()
+
+	const ahead = 4
+	slow := &latencyBlockstore{Blockstore: bs, delay: time.Millisecond}
+	ext := slowWrites(NewExtractor(slow, root, out).WithReadAhead(ahead), 5*time.Millisecond)
+	if err := ext.Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	for name, want := range map[string][]byte{"a.bin": data, "b.bin": data[:3<<20]} {
+		got, err := os.ReadFile(filepath.Join(out, name))
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("%s differs after extraction with read-ahead: %v", name, err)
+		}
+	}
+
+	// The buffer never holds more than the limit, in blocks or in bytes
+	stats := ext.ReadAheadStats()
+	if stats.Hits == 0 || stats.Prefetched < stats.Hits {
+		t.Errorf("stats = %+v, want prefetched blocks to be consumed", stats)
+	}
+	const maxLeaf = 256<<10 + 64 // Default chunk plus the UnixFS envelope
+	if stats.PeakBlocks > ahead || stats.PeakBytes > ahead*maxLeaf {
+		t.Errorf("buffer peaked at %d blocks and %d bytes, limit is %d blocks", stats.PeakBlocks, stats.PeakBytes, ahead)
+	}
+
+	// Nothing stays buffered once a file is written
+	if n := len(ext.prefetch.buffered); n != 0 {
+		t.Errorf("%d blocks still buffered after extraction", n)
+	}
+	if n := len(ext.prefetch.slots); n != 0 {
+		t.Errorf("%d slots still held after extraction", n)
+	}
+}
+
+func TestExtractor_WithReadAhead_Disabled(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	root := importFixture(t, bs, map[string][]byte{"a.bin": readAheadData(1 << 20)}, nil).RootCid
+	ext := NewExtractor(bs, root, t.TempDir()).WithReadAhead(-1)
+	if err := ext.Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if stats := ext.ReadAheadStats(); stats != (ReadAheadStats{}) {
+		t.Errorf("stats = %+v without read-ahead", stats)
+	}
+}
+
+func TestExtractor_WithReadAhead_DAGOrder(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	data := readAheadData(2 << 20)
+	root := importFixture(t, bs, map[string][]byte{"dir/a.bin": data, "b.bin": data}, nil).RootCid
+	out := t.TempDir()
+
+	ext := NewExtractor(bs, root, out).WithDeterministicOrder(false).WithReadAhead(8)
+	if err := ext.Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if got, err := os.ReadFile(filepath.Join(out, "dir", "a.bin")); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("dir/a.bin differs: %v", err)
+	}
+	if ext.ReadAheadStats().Prefetched == 0 {
+		t.Error("nothing was prefetched in DAG order")
+	}
+}
+
+func TestExtractor_WithReadAhead_Cancel(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	root := importFixture(t, bs, map[string][]byte{"a.bin": readAheadData(8 << 20)}, nil).RootCid
+	out := t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	slow := &latencyBlockstore{Blockstore: bs, delay: time.Millisecond}
+	ext := NewExtractor(slow, root, out).WithReadAhead(4).WithYield(time.Nanosecond)
+	ext.WithDestination(&writeHookDestination{
+		Destination: ext.destination(),
+		onWrite:     func(string, int) { cancel() },
+	})
+	if err := ext.Extract(ctx, false); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Extract returned %v, want context.Canceled", err)
+	}
+
+	// The prefetcher stopped with the writer and released its buffer
+	if n := len(ext.prefetch.buffered); n != 0 {
+		t.Errorf("%d blocks still buffered after cancellation", n)
+	}
+}
+
+// BenchmarkExtractor_ReadAhead extracts a file from a blockstore whose
+// reads take a millisecond to a destination whose writes take 10ms, so
+// read-ahead can hide the reads behind the writes.
+func BenchmarkExtractor_ReadAhead(b *testing.B) {
+	mem := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	src := filepath.Join(b.TempDir(), "large.bin")
+	if err := os.WriteFile(src, readAheadData(16<<20), 0o644); err != nil {
+		b.Fatal(err)
+	}
+	result, err := importer.NewImporter(mem, src).Import(context.Background())
+	if err != nil {
+		b.Fatalf("Import failed: %v", err)
+	}
+	bs := &latencyBlockstore{Blockstore: mem, delay: time.Millisecond}
+
+	for _, ahead := range []int{0, 4, 16} {
+		b.Run(fmt.Sprintf("blocks=%d", ahead), func(b *testing.B) {
+			b.SetBytes(16 << 20)
+			for i := 0; i < b.N; i++ {
+				ext := slowWrites(NewExtractor(bs, result.RootCid, b.TempDir()).WithReadAhead(ahead), 10*time.Millisecond)
+				if err := ext.Extract(context.Background(), false); err != nil {
+					b.Fatalf("Extract failed: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/files"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// WithResume continues the part files an interrupted extraction left behind
+// instead of writing those files again from their beginning. The data of a
+// part file is kept up to the last whole block of the file's DAG that it
+// holds, the rest is cut off and the file is written on from there; a part
+// file holding no whole block, or more data than the file, is written anew.
+// Cancelling an extraction with resuming enabled keeps the part file of a
+// file being written, closed and synced, for the next run. The bytes kept
+// count as extracted in the progress.
+//
+// Resuming trusts the data of the part file. It only applies to files
+// written to the filesystem without a text transform, checksum
+// verification or WithVerify, which need all of the data; such files and
+// those of other destinations are always written from their beginning.
+// Combine it with WithStateFile to also skip the files completed before.
+// Returns the extractor instance for method chaining.
+func (ext *Extractor) WithResume(enabled bool) *Extractor {
+	ext.resume = enabled
+	return ext
+}
+
+// resumePart opens the part file an earlier extraction left for node for
+// appending, cut to the last whole block it holds, and seeks node past the
+// data kept. It returns nil if the file has to be written from its
+// beginning.
+func (ext *Extractor) resumePart(ctx context.Context, node files.File, relativePath string) (io.WriteCloser, int64, error) {
+	dest, ok := ext.destination().(*fsDestination)
+	f, isDag := node.(*dagFile)
+	if !ext.resume || !ok || !isDag || ext.text != nil || ext.verify || ext.checksums[ext.rootPath(relativePath)] != "" {
+		return nil, 0, nil
+	}
+
+	partPath := dest.partPath(destPath(relativePath))
+	info, err := os.Lstat(partPath)
+	if err != nil || !info.Mode().IsRegular() || info.Size() == 0 {
+		return nil, 0, nil
+	}
+	dag := merkledag.NewDAGService(blockservice.New(ext.blockStore, nil))
+	keep, err := blockBoundary(ctx, dag, f.cid, info.Size())
+	if err != nil || keep == 0 {
+		return nil, 0, nil
+	}
+
+	part, err := openPartFile(partPath, keep)
+	if err != nil {
+		return nil, 0, nil
+	}
+	if _, err := node.Seek(keep, io.SeekStart); err != nil {
+		_ = part.Close()
+		return nil, 0, err
+	}
+	var w io.Writer = part
+	if dest.writer != nil {
+		w = dest.writer(part)
+	}
+	return &partFile{f: part, w: w}, keep, nil
+}
+
+// openPartFile opens the part file at partPath for appending after
+// truncating it to size bytes.
+func openPartFile(partPath string, size int64) (*os.File, error) {
+	f, err := os.OpenFile(partPath, os.O_WRONLY|os.O_APPEND, filePermissions)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(size); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// blockBoundary returns the largest offset of the file c, no larger than
+// limit, at which a block of its data ends. Only the nodes above the leaves
+// are read, as their block sizes give the layout.
+func blockBoundary(ctx context.Context, dag ipld.DAGService, c cid.Cid, limit int64) (int64, error) {
+	nd, err := dag.Get(ctx, c)
+	if err != nil {
+		return 0, err
+	}
+	pn, ok := nd.(*merkledag.ProtoNode)
+	if !ok {
+		// A raw leaf is a single block
+		if size := int64(len(nd.RawData())); size <= limit {
+			return size, nil
+		}
+		return 0, nil
+	}
+	fsn, err := unixfs.FSNodeFromBytes(pn.Data())
+	if err != nil {
+		return 0, err
+	}
+	if fsn.NumChildren() != len(pn.Links()) {
+		return 0, fmt.Errorf("block %s: %d block sizes for %d links", c, fsn.NumChildren(), len(pn.Links()))
+	}
+
+	// Data held by the node itself comes before the data of its children
+	offset := int64(len(fsn.Data()))
+	if offset > limit {
+		return 0, nil
+	}
+	for i, l := range pn.Links() {
+		size := int64(fsn.BlockSize(i))
+		if offset+size <= limit {
+			offset += size
+			continue
+		}
+		inner, err := blockBoundary(ctx, dag, l.Cid, limit-offset)
+		if err != nil {
+			return 0, err
+		}
+		return offset + inner, nil
+	}
+	return offset, nil
+}
+
+// keepsPart reports whether the part file of a file whose writing failed
+// with err is kept for resuming.
+func (ext *Extractor) keepsPart(err error) bool {
+	return ext.resume && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrInterrupted))
+}
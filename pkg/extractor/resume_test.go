@@ -0,0 +1,94 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tragoedia0722/repository/pkg/importer"
+)
+
+func TestExtractor_WithResume(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	const blockSize = 256 * 1024
+	data := readAheadData(4*blockSize + 5)
+	root := importFixture(t, bs, map[string][]byte{"big.bin": data}, func(imp *importer.Importer) {
+		imp.WithChunkSize(blockSize)
+	}).RootCid
+
+	// The part file of an interrupted extraction holds two whole blocks and
+	// part of the third, followed by data that never made it to disk intact
+	out := filepath.Join(t.TempDir(), "out")
+	if err := os.MkdirAll(out, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	partial := append(append([]byte{}, data[:2*blockSize+1000]...), bytes.Repeat([]byte{0xff}, 100)...)
+	partPath := filepath.Join(out, "big.bin"+partFileSuffix)
+	if err := os.WriteFile(partPath, partial, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var first, last, total int64 = -1, 0, 0
+	ext := NewExtractor(bs, root, out).
+		WithResume(true).
+		WithProgress(func(completed, all int64, _ string) {
+			if first < 0 {
+				first = completed
+			}
+			if completed > all {
+				t.Errorf("progress %d exceeds the total %d", completed, all)
+			}
+			last, total = completed, all
+		})
+	if err := ext.Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(out, "big.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("resumed file differs from the imported one (%d bytes, want %d)", len(got), len(data))
+	}
+	if _, err := os.Lstat(partPath); !os.IsNotExist(err) {
+		t.Errorf("part file left behind: %v", err)
+	}
+	if first != 2*blockSize {
+		t.Errorf("first progress %d, want the %d bytes kept", first, 2*blockSize)
+	}
+	if last != total {
+		t.Errorf("last progress %d, want %d", last, total)
+	}
+}
+
+func TestExtractor_WithResume_Disabled(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	data := readAheadData(300 * 1024)
+	root := importFixture(t, bs, map[string][]byte{"big.bin": data}, nil).RootCid
+
+	// Without resuming a stale part file is written over
+	out := filepath.Join(t.TempDir(), "out")
+	if err := os.MkdirAll(out, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(out, "big.bin"+partFileSuffix), bytes.Repeat([]byte{0xff}, len(data)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewExtractor(bs, root, out).Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(out, "big.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("file differs from the imported one")
+	}
+}
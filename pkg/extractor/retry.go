@@ -0,0 +1,190 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"syscall"
+	"time"
+
+	"github.com/tragoedia0722/repository/pkg/clock"
+)
+
+// Default retry policy for filesystem operations.
+const (
+	defaultRetryAttempts   = 3
+	defaultRetryBackoff    = 10 * time.Millisecond
+	defaultRetryMaxBackoff = 200 * time.Millisecond
+)
+
+// RetryPolicy controls how the extractor retries filesystem operations that
+// fail with a transient error, as seen on network filesystems.
+type RetryPolicy struct {
+	Attempts   int              // Total attempts per operation; less than 1 means 1, so nothing is retried
+	Backoff    time.Duration    // Wait before the first retry, doubled for each further retry
+	MaxBackoff time.Duration    // Upper bound of the wait; 0 means no bound
+	RetryOn    func(error) bool // Reports whether an error is transient; nil means IsTransientError
+}
+
+// DefaultRetryPolicy returns the policy used when WithFSRetry was not called:
+// three attempts with a backoff of 10ms doubling up to 200ms, retrying the
+// errors reported by IsTransientError.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Attempts:   defaultRetryAttempts,
+		Backoff:    defaultRetryBackoff,
+		MaxBackoff: defaultRetryMaxBackoff,
+		RetryOn:    IsTransientError,
+	}
+}
+
+// RetryStats counts the retries made during an extraction.
+type RetryStats struct {
+	Retries   int // Attempts repeated after a transient error
+	Recovered int // Operations that succeeded after at least one retry
+	Failed    int // Operations that still failed after being retried
+}
+
+// add counts the retries of other in s.
+func (s *RetryStats) add(other RetryStats) {
+	s.Retries += other.Retries
+	s.Recovered += other.Recovered
+	s.Failed += other.Failed
+}
+
+// RetryError is returned when a filesystem operation still fails after it
+// was retried. It wraps the error of the last attempt.
+type RetryError struct {
+	Op       string // Operation: "write" (creating and writing a file), "mkdir" or "rename"
+	Path     string // Path relative to the extraction root
+	Attempts int    // Number of attempts made
+	Err      error  // Error of the last attempt
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%s %q failed after %d attempts: %v", e.Op, e.Path, e.Attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// WithFSRetry sets how the extractor retries filesystem operations (creating,
+// writing and renaming files and creating directories) that fail with a
+// transient error. A file whose writing fails partway is removed and written
+// again from its beginning, so progress may step back while it is retried.
+//
+// Whatever RetryOn reports, errors that cannot go away on their own, such as
+// ENOSPC, EPERM, EACCES, existing or missing paths, rejected paths and
+// cancellation, are never retried. Use RetryPolicy{Attempts: 1} to disable
+// retries.
+// Returns the extractor instance for method chaining.
+func (ext *Extractor) WithFSRetry(policy RetryPolicy) *Extractor {
+	ext.retry = &policy
+	return ext
+}
+
+// RetryStats returns the retries made during the last extraction.
+func (ext *Extractor) RetryStats() RetryStats {
+	return ext.retryStat
+}
+
+// IsTransientError reports whether err is a filesystem error that may
+// succeed when the operation is repeated: EINTR, EAGAIN, ESTALE, EIO and,
+// on Windows, sharing and lock violations.
+func IsTransientError(err error) bool {
+	for _, errno := range []syscall.Errno{syscall.EINTR, syscall.EAGAIN, syscall.ESTALE, syscall.EIO} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return isPlatformTransient(err)
+}
+
+// isPermanentError reports whether err can never be fixed by retrying.
+func isPermanentError(err error) bool {
+	for _, target := range []error{
+		syscall.ENOSPC, syscall.EPERM, syscall.EACCES,
+		fs.ErrExist, fs.ErrNotExist, fs.ErrPermission,
+		ErrPathExistsOverwrite, ErrPathTraversal, ErrPathTraversalAttempt,
+		ErrInvalidPathComponent, ErrInvalidDirectoryEntry, ErrInvalidSymlinkTarget,
+		ErrInterrupted, context.Canceled, context.DeadlineExceeded,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryPolicy returns the configured policy or the default one.
+func (ext *Extractor) retryPolicy() RetryPolicy {
+	if ext.retry == nil {
+		return DefaultRetryPolicy()
+	}
+	policy := *ext.retry
+	if policy.RetryOn == nil {
+		policy.RetryOn = IsTransientError
+	}
+	return policy
+}
+
+// retryFS runs fn, the filesystem operation op on relativePath, until it
+// succeeds, fails with an error that is not retried or runs out of attempts.
+// fn receives the number of the attempt, starting at 1, and must undo the
+// effects of a failed attempt itself. Errors after retries are wrapped in a
+// *RetryError.
+func (ext *Extractor) retryFS(ctx context.Context, op, relativePath string, fn func(attempt int) error) error {
+	return ext.retryInto(ctx, &ext.retryStat, op, relativePath, fn)
+}
+
+// retryInto is retryFS counting the retries in stats, for files written
+// on their own goroutine.
+func (ext *Extractor) retryInto(ctx context.Context, stats *RetryStats, op, relativePath string, fn func(attempt int) error) error {
+	policy := ext.retryPolicy()
+	backoff := policy.Backoff
+
+	for attempt := 1; ; attempt++ {
+		err := fn(attempt)
+		if err == nil {
+			if attempt > 1 {
+				stats.Recovered++
+			}
+			return nil
+		}
+
+		if attempt >= policy.Attempts || isPermanentError(err) || !policy.RetryOn(err) {
+			if attempt == 1 {
+				return err
+			}
+			stats.Failed++
+			return &RetryError{Op: op, Path: relativePath, Attempts: attempt, Err: err}
+		}
+
+		stats.Retries++
+		if err := sleepContext(ctx, ext.clock, backoff); err != nil {
+			return err
+		}
+		backoff *= 2
+		if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
+
+// sleepContext waits for d as measured by clk or until ctx is done.
+func sleepContext(ctx context.Context, clk clock.Clock, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := clk.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
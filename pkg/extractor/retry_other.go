@@ -0,0 +1,9 @@
+//go:build !windows
+
+package extractor
+
+// isPlatformTransient reports whether err is a transient error specific to
+// the platform. There are none outside Windows.
+func isPlatformTransient(err error) bool {
+	return false
+}
@@ -0,0 +1,309 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/tragoedia0722/repository/pkg/clock/clocktest"
+)
+
+// flakyDestination wraps a destination and fails each operation on each path
+// the first time with err. Writing a file fails once, after its first write.
+type flakyDestination struct {
+	Destination
+	err    error
+	failed map[string]bool
+	calls  map[string]int
+}
+
+func newFlakyDestination(dst Destination, err error) *flakyDestination {
+	return &flakyDestination{Destination: dst, err: err, failed: map[string]bool{}, calls: map[string]int{}}
+}
+
+// fail reports whether the operation op on relPath fails this time.
+func (d *flakyDestination) fail(op, relPath string) bool {
+	key := op + " " + relPath
+	d.calls[key]++
+	if d.failed[key] {
+		return false
+	}
+	d.failed[key] = true
+	return true
+}
+
+func (d *flakyDestination) CreateFile(relPath string) (io.WriteCloser, error) {
+	if d.fail("create", relPath) {
+		return nil, &fs.PathError{Op: "create", Path: relPath, Err: d.err}
+	}
+	w, err := d.Destination.CreateFile(relPath)
+	if err != nil {
+		return nil, err
+	}
+	return &flakyWriter{WriteCloser: w, dst: d, rel: relPath}, nil
+}
+
+func (d *flakyDestination) Finalize(relPath string) error {
+	if d.fail("rename", relPath) {
+		return &fs.PathError{Op: "rename", Path: relPath, Err: d.err}
+	}
+	return d.Destination.Finalize(relPath)
+}
+
+func (d *flakyDestination) Mkdir(relPath string) error {
+	if d.fail("mkdir", relPath) {
+		return &fs.PathError{Op: "mkdir", Path: relPath, Err: d.err}
+	}
+	return d.Destination.Mkdir(relPath)
+}
+
+// flakyWriter fails the second write to a file the first time the file is
+// written.
+type flakyWriter struct {
+	io.WriteCloser
+	dst     *flakyDestination
+	rel     string
+	written int
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	if w.written > 0 && w.dst.fail("write", w.rel) {
+		return 0, &fs.PathError{Op: "write", Path: w.rel, Err: w.dst.err}
+	}
+	n, err := w.WriteCloser.Write(p)
+	w.written += n
+	return n, err
+}
+
+// retryFixture is a tree with nested directories and a file spanning
+// several blocks.
+func retryFixture() map[string][]byte {
+	return map[string][]byte{
+		"a.txt":           []byte("alpha"),
+		"dir/b.txt":       []byte("bravo"),
+		"dir/sub/big.bin": bytes.Repeat([]byte("0123456789abcdef"), 320*1024), // More than one write buffer
+	}
+}
+
+func TestExtractor_WithFSRetry(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	fixture := retryFixture()
+	root := importFixture(t, bs, fixture, nil).RootCid
+
+	var total, completed int64
+	mem := NewMemoryDestination()
+	flaky := newFlakyDestination(mem, syscall.EIO)
+	ext := NewExtractor(bs, root, "unused").
+		WithDestination(flaky).
+		WithFSRetry(RetryPolicy{Attempts: 3}).
+		WithProgress(func(c, tot int64, _ string) { completed, total = c, tot })
+	if err := ext.Extract(ctx, false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	got := mem.Files()
+	if len(got) != len(fixture) {
+		t.Fatalf("extracted %d files, want %d", len(got), len(fixture))
+	}
+	for rel, data := range fixture {
+		if !bytes.Equal(got[rel], data) {
+			t.Errorf("%s: content differs after retries", rel)
+		}
+	}
+	if completed != total {
+		t.Errorf("progress ended at %d of %d bytes", completed, total)
+	}
+
+	stats := ext.RetryStats()
+	if stats.Retries == 0 || stats.Recovered != stats.Retries || stats.Failed != 0 {
+		t.Errorf("RetryStats() = %+v, want every retry recovered", stats)
+	}
+	if n := flaky.calls["write dir/sub/big.bin"]; n < 2 {
+		t.Errorf("big.bin written %d times, want a rewrite after the failed write", n)
+	}
+	if n := flaky.calls["rename dir/b.txt"]; n != 2 {
+		t.Errorf("dir/b.txt renamed %d times, want 2", n)
+	}
+}
+
+func TestExtractor_WithFSRetry_Filesystem(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	fixture := retryFixture()
+	root := importFixture(t, bs, fixture, nil).RootCid
+	out := t.TempDir()
+
+	ext := NewExtractor(bs, root, out)
+	flaky := newFlakyDestination(ext.destination(), syscall.ESTALE)
+	ext.WithDestination(flaky).WithFSRetry(RetryPolicy{Attempts: 2})
+	if err := ext.Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	got := readTree(t, out)
+	if len(got) != len(fixture) {
+		t.Fatalf("extracted %v, want %d files without leftover part files", got, len(fixture))
+	}
+	for rel, data := range fixture {
+		if !bytes.Equal(got[rel], data) {
+			t.Errorf("%s: content differs after retries", rel)
+		}
+	}
+	if stats := ext.RetryStats(); stats.Recovered == 0 || stats.Failed != 0 {
+		t.Errorf("RetryStats() = %+v", stats)
+	}
+}
+
+func TestExtractor_WithFSRetry_Exhausted(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	root := importFixture(t, bs, map[string][]byte{"a.txt": []byte("alpha")}, nil).RootCid
+	mem := NewMemoryDestination()
+	ext := NewExtractor(bs, root, "unused").WithDestination(alwaysFailRename{mem}).WithFSRetry(RetryPolicy{Attempts: 4})
+	err := ext.Extract(context.Background(), false)
+
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) || !errors.Is(err, syscall.EAGAIN) {
+		t.Fatalf("Extract error = %v, want *RetryError wrapping EAGAIN", err)
+	}
+	if retryErr.Attempts != 4 || retryErr.Op != "rename" {
+		t.Errorf("RetryError = %+v, want 4 rename attempts", retryErr)
+	}
+	if stats := ext.RetryStats(); stats.Retries != 3 || stats.Failed != 1 {
+		t.Errorf("RetryStats() = %+v, want 3 retries and 1 failure", stats)
+	}
+	if len(mem.Paths()) != 0 {
+		t.Errorf("destination holds %v after the failure", mem.Paths())
+	}
+}
+
+// alwaysFailRename fails every Finalize with EAGAIN.
+type alwaysFailRename struct {
+	Destination
+}
+
+func (d alwaysFailRename) Finalize(relPath string) error {
+	return &fs.PathError{Op: "rename", Path: relPath, Err: syscall.EAGAIN}
+}
+
+func TestExtractor_WithFSRetry_Backoff(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	root := importFixture(t, bs, map[string][]byte{"a.txt": []byte("alpha")}, nil).RootCid
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	ext := NewExtractor(bs, root, "unused").
+		WithClock(clk).
+		WithDestination(alwaysFailRename{NewMemoryDestination()}).
+		WithFSRetry(RetryPolicy{Attempts: 5, Backoff: 10 * time.Millisecond, MaxBackoff: 30 * time.Millisecond})
+
+	done := make(chan error, 1)
+	go func() { done <- ext.Extract(context.Background(), false) }()
+
+	// The wait doubles from Backoff and is capped at MaxBackoff
+	for i, wait := range []time.Duration{10, 20, 30, 30} {
+		wait *= time.Millisecond
+		clk.BlockUntil(1)
+		clk.Advance(wait - time.Nanosecond)
+		if clk.Waiters() != 1 {
+			t.Fatalf("retry %d: backoff ended before %v", i+1, wait)
+		}
+		clk.Advance(time.Nanosecond)
+	}
+
+	var retryErr *RetryError
+	if err := <-done; !errors.As(err, &retryErr) || retryErr.Attempts != 5 {
+		t.Fatalf("Extract error = %v, want *RetryError after 5 attempts", err)
+	}
+}
+
+func TestExtractor_WithFSRetry_Permanent(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	root := importFixture(t, bs, retryFixture(), nil).RootCid
+	for _, errno := range []error{syscall.ENOSPC, syscall.EPERM, fs.ErrExist} {
+		t.Run(errno.Error(), func(t *testing.T) {
+			flaky := newFlakyDestination(NewMemoryDestination(), errno)
+			ext := NewExtractor(bs, root, "unused").
+				WithDestination(flaky).
+				WithFSRetry(RetryPolicy{Attempts: 5, RetryOn: func(error) bool { return true }})
+			err := ext.Extract(context.Background(), false)
+
+			var retryErr *RetryError
+			if !errors.Is(err, errno) || errors.As(err, &retryErr) {
+				t.Fatalf("Extract error = %v, want %v without retries", err, errno)
+			}
+			if stats := ext.RetryStats(); stats.Retries != 0 {
+				t.Errorf("RetryStats() = %+v, want no retries", stats)
+			}
+		})
+	}
+}
+
+func TestExtractor_WithFSRetry_RetryOn(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	root := importFixture(t, bs, retryFixture(), nil).RootCid
+	errBusy := errors.New("share busy")
+
+	// Not transient by default
+	ext := NewExtractor(bs, root, "unused").WithDestination(newFlakyDestination(NewMemoryDestination(), errBusy))
+	if err := ext.Extract(context.Background(), false); !errors.Is(err, errBusy) {
+		t.Fatalf("Extract error = %v, want %v", err, errBusy)
+	}
+
+	ext = NewExtractor(bs, root, "unused").
+		WithDestination(newFlakyDestination(NewMemoryDestination(), errBusy)).
+		WithFSRetry(RetryPolicy{Attempts: 2, RetryOn: func(err error) bool { return errors.Is(err, errBusy) }})
+	if err := ext.Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract with RetryOn failed: %v", err)
+	}
+	if stats := ext.RetryStats(); stats.Recovered == 0 {
+		t.Errorf("RetryStats() = %+v, want recovered operations", stats)
+	}
+
+	// Disabled retries
+	ext = NewExtractor(bs, root, "unused").
+		WithDestination(newFlakyDestination(NewMemoryDestination(), syscall.EIO)).
+		WithFSRetry(RetryPolicy{Attempts: 1})
+	if err := ext.Extract(context.Background(), false); !errors.Is(err, syscall.EIO) {
+		t.Fatalf("Extract error = %v, want EIO", err)
+	}
+}
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{syscall.EINTR, true},
+		{syscall.EAGAIN, true},
+		{syscall.ESTALE, true},
+		{&os.PathError{Op: "write", Path: "f", Err: syscall.EIO}, true},
+		{fmt.Errorf("wrapped: %w", syscall.EIO), true},
+		{syscall.ENOSPC, false},
+		{syscall.EPERM, false},
+		{fs.ErrNotExist, false},
+		{errors.New("other"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsTransientError(tt.err); got != tt.want {
+			t.Errorf("IsTransientError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
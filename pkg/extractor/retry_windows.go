@@ -0,0 +1,16 @@
+//go:build windows
+
+package extractor
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// isPlatformTransient reports whether err is a sharing or lock violation,
+// reported while another process, such as a virus scanner or an indexer,
+// briefly holds the file open.
+func isPlatformTransient(err error) bool {
+	return errors.Is(err, windows.ERROR_SHARING_VIOLATION) || errors.Is(err, windows.ERROR_LOCK_VIOLATION)
+}
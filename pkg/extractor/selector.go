@@ -0,0 +1,220 @@
+package extractor
+
+import (
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ipfs/boxo/files"
+)
+
+// Selector chooses which entries below a root directory are extracted.
+//
+// Paths are relative to the extraction root and use forward slashes. The
+// selector is consulted during traversal: ShouldDescend is called for every
+// entry, and an entry it rejects is skipped without loading anything below
+// it, so the blocks of a pruned subtree are never read. ShouldExtract is then
+// called for every file and symlink that was not pruned. Directories the
+// selector descends into are created even if nothing below them is selected.
+//
+// A root that is a single file is always extracted as a whole.
+type Selector interface {
+	// ShouldDescend reports whether the entry at relPath is traversed.
+	ShouldDescend(relPath string, isDir bool) bool
+
+	// ShouldExtract reports whether the file or symlink at relPath, whose
+	// content is size bytes long, is written.
+	ShouldExtract(relPath string, size int64) bool
+}
+
+// FileLimiter is implemented by selectors that extract only the beginning
+// of large files.
+type FileLimiter interface {
+	// LimitBytes returns the maximum number of bytes written for the
+	// selected file at relPath, or a negative value for no limit.
+	LimitBytes(relPath string, size int64) int64
+}
+
+// TruncatedFile is a file of which only the beginning was extracted because
+// of a FileLimiter. It is written to Path with the .partial suffix instead
+// of to Path.
+type TruncatedFile struct {
+	Path        string // Path relative to the extraction root
+	Size        int64  // Size of the file in the DAG
+	WrittenSize int64  // Bytes written to the .partial file
+}
+
+// WithSelector extracts only the entries chosen by sel. Since the size of
+// the selected set is not known before the traversal, the progress callback
+// then reports a total of 0 unless the root is a single file.
+// Returns the extractor instance for method chaining.
+func (ext *Extractor) WithSelector(sel Selector) *Extractor {
+	ext.selector = sel
+	return ext
+}
+
+// TruncatedFiles returns the files truncated by the selector during the
+// last extraction.
+func (ext *Extractor) TruncatedFiles() []TruncatedFile {
+	return ext.truncated
+}
+
+// selectEntry applies the selector to the entry nd at relativePath below the
+// root. It reports whether the entry is extracted; a truncated file is
+// returned as a node limited to the selected bytes together with its record.
+func (ext *Extractor) selectEntry(nd files.Node, relativePath string) (files.Node, *TruncatedFile, bool, error) {
+	if ext.selector == nil {
+		return nd, nil, true, nil
+	}
+
+	rel := destPath(relativePath)
+	isDir := ext.isDir(nd)
+	if !ext.selector.ShouldDescend(rel, isDir) {
+		return nil, nil, false, nil
+	}
+	if isDir {
+		return nd, nil, true, nil
+	}
+
+	size, err := nd.Size()
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if !ext.selector.ShouldExtract(rel, size) {
+		return nil, nil, false, nil
+	}
+
+	file, ok := nd.(files.File)
+	limiter, limited := ext.selector.(FileLimiter)
+	if !ok || !limited {
+		return nd, nil, true, nil
+	}
+	limit := limiter.LimitBytes(rel, size)
+	if limit < 0 || size <= limit {
+		return nd, nil, true, nil
+	}
+
+	return &truncatedFile{File: file, r: io.LimitReader(file, limit), size: limit},
+		&TruncatedFile{Path: rel, Size: size, WrittenSize: limit}, true, nil
+}
+
+// truncatedFile exposes the first size bytes of a file.
+type truncatedFile struct {
+	files.File
+	r    io.Reader
+	size int64
+}
+
+func (f *truncatedFile) Read(p []byte) (int, error) {
+	return f.r.Read(p)
+}
+
+func (f *truncatedFile) Size() (int64, error) {
+	return f.size, nil
+}
+
+// globSelector selects the entries matching any of its patterns.
+type globSelector struct {
+	patterns [][]string // Patterns split into path segments
+}
+
+// GlobSelector returns a Selector extracting the entries whose path matches
+// one of patterns. Patterns are slash-separated paths relative to the
+// extraction root; each segment is matched with path.Match, and a "**"
+// segment matches any number of segments, including none. A directory that
+// matches selects everything below it, so "config" and "config/**" are
+// equivalent. Directories that cannot contain a match are pruned. Malformed
+// patterns match nothing.
+//
+// For example, "config/*.json" selects the JSON files directly in config and
+// "**/*.json" the JSON files at any depth.
+func GlobSelector(patterns []string) Selector {
+	sel := &globSelector{}
+	for _, p := range patterns {
+		p = strings.Trim(filepath.ToSlash(p), "/")
+		sel.patterns = append(sel.patterns, strings.Split(p, "/"))
+	}
+	return sel
+}
+
+func (g *globSelector) ShouldDescend(relPath string, isDir bool) bool {
+	if !isDir {
+		return true
+	}
+	segments := strings.Split(relPath, "/")
+	if g.covers(segments) {
+		return true
+	}
+	for _, p := range g.patterns {
+		if matchSegments(p, segments, true) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *globSelector) ShouldExtract(relPath string, _ int64) bool {
+	return g.covers(strings.Split(relPath, "/"))
+}
+
+// covers reports whether the path or one of its parent directories matches
+// a pattern.
+func (g *globSelector) covers(segments []string) bool {
+	for i := 1; i <= len(segments); i++ {
+		for _, p := range g.patterns {
+			if matchSegments(p, segments[:i], false) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchSegments matches name against pattern segment by segment. With
+// prefix set, it also reports true when name is a directory below which a
+// match is still possible.
+func matchSegments(pattern, name []string, prefix bool) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:], prefix) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return prefix
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// maxBytesSelector extracts every entry, truncating large files.
+type maxBytesSelector struct {
+	limit int64
+}
+
+// MaxBytesPerFileSelector returns a Selector extracting every entry but
+// writing at most n bytes of each file. Larger files are written with the
+// .partial suffix and reported by TruncatedFiles. The returned selector
+// implements FileLimiter.
+func MaxBytesPerFileSelector(n int64) Selector {
+	if n < 0 {
+		n = 0
+	}
+	return &maxBytesSelector{limit: n}
+}
+
+func (*maxBytesSelector) ShouldDescend(string, bool) bool { return true }
+
+func (*maxBytesSelector) ShouldExtract(string, int64) bool { return true }
+
+func (m *maxBytesSelector) LimitBytes(string, int64) int64 {
+	return m.limit
+}
@@ -0,0 +1,197 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+)
+
+// countingBlockstore records the CIDs read through Get.
+type countingBlockstore struct {
+	blockstore.Blockstore
+
+	mu    sync.Mutex
+	reads map[cid.Cid]int
+}
+
+func (b *countingBlockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	b.mu.Lock()
+	b.reads[c]++
+	b.mu.Unlock()
+	return b.Blockstore.Get(ctx, c)
+}
+
+// descendants returns the CIDs of every block below the entry name of the
+// root directory, not including the entry's own block.
+func descendants(t *testing.T, bs blockstore.Blockstore, root, name string) []cid.Cid {
+	t.Helper()
+
+	ctx := context.Background()
+	dag := merkledag.NewDAGService(blockservice.New(bs, nil))
+	rootNode, err := dag.Get(ctx, cid.MustParse(root))
+	if err != nil {
+		t.Fatalf("Get(root) failed: %v", err)
+	}
+	link, _, err := rootNode.ResolveLink([]string{name})
+	if err != nil {
+		t.Fatalf("no entry %q: %v", name, err)
+	}
+
+	var out []cid.Cid
+	var walk func(c cid.Cid)
+	walk = func(c cid.Cid) {
+		nd, err := dag.Get(ctx, c)
+		if err != nil {
+			t.Fatalf("Get(%s) failed: %v", c, err)
+		}
+		for _, l := range nd.Links() {
+			out = append(out, l.Cid)
+			walk(l.Cid)
+		}
+	}
+	walk(link.Cid)
+	return out
+}
+
+func TestGlobSelector(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		path     string
+		isDir    bool
+		descend  bool
+		extract  bool
+	}{
+		{[]string{"config/*.json"}, "config", true, true, false},
+		{[]string{"config/*.json"}, "config/a.json", false, true, true},
+		{[]string{"config/*.json"}, "config/a.txt", false, true, false},
+		{[]string{"config/*.json"}, "config/sub", true, false, false},
+		{[]string{"config/*.json"}, "src", true, false, false},
+		{[]string{"config/*.json"}, "a.json", false, true, false},
+		{[]string{"**/*.json"}, "a.json", false, true, true},
+		{[]string{"**/*.json"}, "a/b/c.json", false, true, true},
+		{[]string{"**/*.json"}, "a/b", true, true, false},
+		{[]string{"config"}, "config", true, true, false},
+		{[]string{"config"}, "config/sub/a.txt", false, true, true},
+		{[]string{"config/**"}, "config/sub", true, true, false},
+		{[]string{"config/**"}, "config/sub/a.txt", false, true, true},
+		{[]string{"config/**"}, "configs/a.txt", false, true, false},
+		{[]string{"a/*/c"}, "a/b", true, true, false},
+		{[]string{"a/*/c"}, "a/b/c", false, true, true},
+		{[]string{"a/*/c"}, "a/b/d", false, true, false},
+		{[]string{"*.txt", "docs/**"}, "docs", true, true, false},
+		{[]string{"*.txt", "docs/**"}, "notes.txt", false, true, true},
+		{[]string{"[bad"}, "x", true, false, false},
+		{nil, "x", false, true, false},
+	}
+
+	for _, tt := range tests {
+		sel := GlobSelector(tt.patterns)
+		if got := sel.ShouldDescend(tt.path, tt.isDir); got != tt.descend {
+			t.Errorf("%v: ShouldDescend(%q, %v) = %v, want %v", tt.patterns, tt.path, tt.isDir, got, tt.descend)
+		}
+		if tt.isDir {
+			continue
+		}
+		if got := sel.ShouldExtract(tt.path, 0); got != tt.extract {
+			t.Errorf("%v: ShouldExtract(%q) = %v, want %v", tt.patterns, tt.path, got, tt.extract)
+		}
+	}
+}
+
+func TestExtractor_WithSelector_PrunesSubtrees(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	large := make([]byte, 1024*1024)
+	rand.New(rand.NewSource(1)).Read(large)
+	root := importFixture(t, bs, map[string][]byte{
+		"config/app.json":      []byte(`{"app":true}`),
+		"config/notes.txt":     []byte("not selected"),
+		"config/deep/db.json":  []byte(`{"db":true}`),
+		"assets/large.bin":     large,
+		"assets/nested/a.json": []byte(`{}`),
+	}, nil).RootCid
+
+	pruned := descendants(t, bs, root, "assets")
+	if len(pruned) < 4 {
+		t.Fatalf("assets has only %d descendant blocks", len(pruned))
+	}
+
+	counting := &countingBlockstore{Blockstore: bs, reads: make(map[cid.Cid]int)}
+	out := filepath.Join(t.TempDir(), "out")
+	var lastCompleted, lastTotal int64
+	ext := NewExtractor(counting, root, out).
+		WithSelector(GlobSelector([]string{"config/*.json"})).
+		WithProgress(func(completed, total int64, _ string) {
+			if completed < lastCompleted {
+				t.Errorf("progress went backwards: %d -> %d", lastCompleted, completed)
+			}
+			lastCompleted, lastTotal = completed, total
+		})
+	if err := ext.Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	want := map[string][]byte{"config/app.json": []byte(`{"app":true}`)}
+	if got := readTree(t, out); !reflect.DeepEqual(got, want) {
+		t.Errorf("extracted %v, want %v", got, want)
+	}
+	if _, err := os.Lstat(filepath.Join(out, "assets")); !os.IsNotExist(err) {
+		t.Errorf("pruned directory was created: %v", err)
+	}
+	for _, c := range pruned {
+		if n := counting.reads[c]; n > 0 {
+			t.Errorf("block %s below a pruned directory was read %d times", c, n)
+		}
+	}
+
+	if lastTotal != 0 {
+		t.Errorf("progress total = %d, want 0 for a selection", lastTotal)
+	}
+	if lastCompleted != int64(len(want["config/app.json"])) {
+		t.Errorf("progress completed = %d, want %d", lastCompleted, len(want["config/app.json"]))
+	}
+}
+
+func TestExtractor_MaxBytesPerFileSelector(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	large := bytes.Repeat([]byte("0123456789"), 100)
+	root := importFixture(t, bs, map[string][]byte{
+		"large.bin":     large,
+		"dir/small.txt": []byte("small"),
+		"dir/exact.txt": large[:100],
+	}, nil).RootCid
+
+	out := filepath.Join(t.TempDir(), "out")
+	ext := NewExtractor(bs, root, out).WithSelector(MaxBytesPerFileSelector(100))
+	if err := ext.Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	want := map[string][]byte{
+		"large.bin.partial": large[:100],
+		"dir/small.txt":     []byte("small"),
+		"dir/exact.txt":     large[:100],
+	}
+	if got := readTree(t, out); !reflect.DeepEqual(got, want) {
+		t.Errorf("extracted %v, want %v", got, want)
+	}
+
+	wantTruncated := []TruncatedFile{{Path: "large.bin", Size: int64(len(large)), WrittenSize: 100}}
+	if got := ext.TruncatedFiles(); !reflect.DeepEqual(got, wantTruncated) {
+		t.Errorf("TruncatedFiles() = %+v, want %+v", got, wantTruncated)
+	}
+}
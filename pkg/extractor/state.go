@@ -0,0 +1,286 @@
+package extractor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/boxo/files"
+	"github.com/tragoedia0722/repository/pkg/clock"
+)
+
+// FileState is the progress of a file through an extraction.
+type FileState string
+
+const (
+	FilePending    FileState = "pending"    // Not started yet
+	FileWriting    FileState = "writing"    // Data is being written to the part file; cancellation discards it
+	FileFinalizing FileState = "finalizing" // Every byte is written; being synced, checked and renamed into place
+	FileDone       FileState = "done"       // At its final path and recorded as completed
+)
+
+// extractState is the on-disk form of a state file.
+type extractState struct {
+	RootCid   string               `json:"rootCid"`
+	Completed []string             `json:"completed"`
+	InFlight  map[string]FileState `json:"inFlight,omitempty"` // Files started but not completed, with the state they stopped in
+}
+
+// stateTracker records fully completed entries of an extraction and
+// periodically persists them to a state file.
+type stateTracker struct {
+	mu        sync.Mutex
+	path      string
+	rootCid   string
+	completed map[string]struct{}
+	inFlight  map[string]FileState // Files of this run that are writing or finalizing
+	previous  map[string]FileState // Files the loaded state recorded as in flight
+	resumed   bool                 // true if a state file for the same root was loaded
+	dirty     int                  // completions since the last flush
+	lastFlush time.Time
+	clock     clock.Clock
+}
+
+// loadStateTracker reads the state file at path. A missing file starts an
+// empty state; a file recorded for a different root is ignored and reported
+// through warn.
+func loadStateTracker(path, rootCid string, clk clock.Clock, warn func(error)) (*stateTracker, error) {
+	st := &stateTracker{
+		path:      path,
+		rootCid:   rootCid,
+		completed: make(map[string]struct{}),
+		inFlight:  make(map[string]FileState),
+		lastFlush: clk.Now(),
+		clock:     clk,
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var state extractState
+	if err := json.Unmarshal(data, &state); err != nil {
+		warn(fmt.Errorf("%w: %s: %v", ErrStateFileIgnored, path, err))
+		return st, nil
+	}
+	if state.RootCid != rootCid {
+		warn(fmt.Errorf("%w: %s was recorded for root %s, not %s", ErrStateFileIgnored, path, state.RootCid, rootCid))
+		return st, nil
+	}
+
+	for _, rel := range state.Completed {
+		st.completed[rel] = struct{}{}
+	}
+	st.previous = state.InFlight
+	st.resumed = true
+	return st, nil
+}
+
+// isCompleted reports whether rel was recorded as fully extracted.
+func (st *stateTracker) isCompleted(rel string) bool {
+	if st == nil {
+		return false
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	_, ok := st.completed[rel]
+	return ok
+}
+
+// markCompleted records rel as fully extracted and flushes the state file
+// when enough entries or time have accumulated.
+func (st *stateTracker) markCompleted(rel string) error {
+	if st == nil {
+		return nil
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.completed[rel] = struct{}{}
+	delete(st.inFlight, rel)
+	st.dirty++
+
+	if st.dirty >= stateFlushEntries || st.clock.Since(st.lastFlush) >= stateFlushInterval {
+		return st.flushLocked()
+	}
+	return nil
+}
+
+// setFileState records that the file rel entered state, which must be
+// FileWriting or FileFinalizing; markCompleted records FileDone. A file that
+// fails keeps the state it failed in, so the state file tells where it
+// stopped.
+func (st *stateTracker) setFileState(rel string, state FileState) {
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.inFlight[rel] = state
+}
+
+// flush writes pending completions and files in flight to the state file.
+func (st *stateTracker) flush() error {
+	if st == nil {
+		return nil
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.dirty == 0 && len(st.inFlight) == 0 {
+		return nil
+	}
+	return st.flushLocked()
+}
+
+// flushLocked atomically replaces the state file. Callers must hold st.mu.
+func (st *stateTracker) flushLocked() error {
+	state := extractState{
+		RootCid:   st.rootCid,
+		Completed: make([]string, 0, len(st.completed)),
+	}
+	for rel := range st.completed {
+		state.Completed = append(state.Completed, rel)
+	}
+	sort.Strings(state.Completed)
+	if len(st.inFlight) > 0 {
+		state.InFlight = st.inFlight
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode state file: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(st.path), dirPermissions); err != nil {
+		return wrapMkdirFailed(filepath.Dir(st.path), err)
+	}
+
+	tmp := st.path + partFileSuffix
+	if err := os.WriteFile(tmp, data, filePermissions); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp, st.path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	st.dirty = 0
+	st.lastFlush = st.clock.Now()
+	return nil
+}
+
+// remove deletes the state file after a successful extraction.
+func (st *stateTracker) remove() error {
+	if st == nil {
+		return nil
+	}
+	if err := os.Remove(st.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove state file: %w", err)
+	}
+	return nil
+}
+
+// WithStateFile persists the set of fully extracted entries to path so that an
+// interrupted extraction can be resumed.
+//
+// The state file is written atomically (temporary file and rename) at regular
+// intervals and when Extract returns with an error. On the next Extract of the
+// same root CID with the same state file, recorded entries are skipped without
+// touching the filesystem, unless WithRevalidate is set. Entries not yet
+// recorded may have been written after the last flush, so a resumed
+// extraction replaces them even when overwrite is false. A state file for a
+// different root CID is ignored and reported to the warning handler. The
+// state file is deleted once extraction succeeds.
+// Returns the extractor instance for method chaining.
+func (ext *Extractor) WithStateFile(path string) *Extractor {
+	ext.stateFile = path
+	return ext
+}
+
+// Interrupted returns the files that the state file loaded by the last
+// Extract recorded as started but not completed, with the state each one
+// stopped in: FileWriting for a file whose data was being written, and
+// FileFinalizing for one whose finalize failed. Cancellation never leaves a
+// file in FileFinalizing, see Extract. Such files are extracted again. It
+// returns nil without a state file or when nothing was interrupted.
+func (ext *Extractor) Interrupted() map[string]FileState {
+	return ext.inFlight
+}
+
+// WithRevalidate makes a resumed extraction check that entries recorded in the
+// state file still exist on disk with the expected size before skipping them.
+// Returns the extractor instance for method chaining.
+func (ext *Extractor) WithRevalidate(revalidate bool) *Extractor {
+	ext.revalidate = revalidate
+	return ext
+}
+
+// WithWarningHandler sets a callback receiving non-fatal problems, such as an
+// ignored state file. Returns the extractor instance for method chaining.
+func (ext *Extractor) WithWarningHandler(handler func(error)) *Extractor {
+	ext.warn = handler
+	return ext
+}
+
+// warning forwards err to the warning handler and the event channel, if any.
+func (ext *Extractor) warning(err error) {
+	if ext.warn != nil {
+		ext.warn(err)
+	}
+	ext.events.send(Warning{Err: err})
+}
+
+// skipCompleted reports whether the entry at relativePath was completed by a
+// previous run and can be skipped. Skipped entries are counted as progress.
+func (ext *Extractor) skipCompleted(ctx context.Context, nd files.Node, path, relativePath string) (bool, error) {
+	if !ext.state.isCompleted(relativePath) {
+		return false, nil
+	}
+
+	if ext.revalidate {
+		// Directories are descended so that each child is revalidated
+		if ext.isDir(nd) {
+			return false, nil
+		}
+
+		info, err := statEntry(ext.destination(), destPath(relativePath))
+		if err != nil {
+			return false, err
+		}
+		if !info.exists {
+			return false, nil
+		}
+		size, err := nd.Size()
+		if err != nil {
+			return false, err
+		}
+		if info.Mode().IsRegular() && info.Size() != size {
+			return false, nil
+		}
+		if info.Mode().IsRegular() {
+			same, err := ext.sameAsExisting(ctx, nd, relativePath, size)
+			if err != nil || !same {
+				return false, err
+			}
+		}
+	}
+
+	size, err := contentSize(ctx, nd, relativePath)
+	if err != nil {
+		return false, err
+	}
+	ext.updateProgress(size, relativePath)
+	return true, nil
+}
@@ -0,0 +1,258 @@
+package extractor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/tragoedia0722/repository/pkg/clock/clocktest"
+	"github.com/tragoedia0722/repository/pkg/importer"
+)
+
+// countLstat makes ext write to the filesystem through a destination that
+// counts the entries it inspects.
+func countLstat(ext *Extractor, count *int) *Extractor {
+	ext.dest = &fsDestination{
+		root: ext.path,
+		base: ext.basePath,
+		lstat: func(name string) (os.FileInfo, error) {
+			*count++
+			return os.Lstat(name)
+		},
+	}
+	return ext
+}
+
+// importManyFiles imports dirs×files small files and returns the root CID.
+func importManyFiles(t *testing.T, bs blockstore.Blockstore, dirs, files int) string {
+	t.Helper()
+
+	src := t.TempDir()
+	for d := 0; d < dirs; d++ {
+		dir := filepath.Join(src, fmt.Sprintf("dir%d", d))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		for f := 0; f < files; f++ {
+			content := strings.Repeat(fmt.Sprintf("%d-%d;", d, f), 100)
+			if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d.txt", f)), []byte(content), 0o644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+		}
+	}
+
+	result, err := importer.NewImporter(bs, src).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	return result.RootCid
+}
+
+// verifyManyFiles checks the tree written by importManyFiles.
+func verifyManyFiles(t *testing.T, out string, dirs, files int) {
+	t.Helper()
+
+	for d := 0; d < dirs; d++ {
+		for f := 0; f < files; f++ {
+			path := filepath.Join(out, fmt.Sprintf("dir%d", d), fmt.Sprintf("file%d.txt", f))
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read %s: %v", path, err)
+			}
+			if want := strings.Repeat(fmt.Sprintf("%d-%d;", d, f), 100); string(got) != want {
+				t.Errorf("%s: content mismatch", path)
+			}
+		}
+	}
+}
+
+// interruptExtraction extracts root into out and cancels after about half the files.
+func interruptExtraction(t *testing.T, bs blockstore.Blockstore, root, out, stateFile string) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ext := NewExtractor(bs, root, out).WithStateFile(stateFile)
+	ext.WithProgress(func(completed, total int64, currentFile string) {
+		if completed*2 >= total {
+			cancel()
+		}
+	})
+
+	if err := ext.Extract(ctx, false); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestExtractor_StateFile_Resume(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	const dirs, files = 3, 20
+	root := importManyFiles(t, bs, dirs, files)
+
+	// Baseline: number of filesystem inspections for a full extraction
+	var stats int
+	if err := countLstat(NewExtractor(bs, root, t.TempDir()), &stats).Extract(context.Background(), false); err != nil {
+		t.Fatalf("baseline Extract failed: %v", err)
+	}
+	fullStats := stats
+
+	out := filepath.Join(t.TempDir(), "out")
+	stateFile := filepath.Join(t.TempDir(), "extract.state")
+	interruptExtraction(t, bs, root, out, stateFile)
+
+	data, err := os.ReadFile(stateFile)
+	if err != nil {
+		t.Fatalf("state file not written: %v", err)
+	}
+	var state extractState
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatalf("invalid state file: %v", err)
+	}
+	if state.RootCid != root || len(state.Completed) == 0 {
+		t.Fatalf("unexpected state: root %s, %d completed", state.RootCid, len(state.Completed))
+	}
+
+	var lastCompleted, lastTotal int64
+	stats = 0
+	ext := countLstat(NewExtractor(bs, root, out), &stats).WithStateFile(stateFile)
+	ext.WithProgress(func(completed, total int64, currentFile string) {
+		lastCompleted, lastTotal = completed, total
+	})
+	if err := ext.Extract(context.Background(), false); err != nil {
+		t.Fatalf("resumed Extract failed: %v", err)
+	}
+
+	if stats >= fullStats*3/4 {
+		t.Errorf("resumed extraction inspected %d paths, full extraction %d", stats, fullStats)
+	}
+	var wantBytes int64
+	for d := 0; d < dirs; d++ {
+		for f := 0; f < files; f++ {
+			wantBytes += int64(len(fmt.Sprintf("%d-%d;", d, f)) * 100)
+		}
+	}
+	if lastCompleted != wantBytes {
+		t.Errorf("final progress %d/%d does not cover all files", lastCompleted, lastTotal)
+	}
+	verifyManyFiles(t, out, dirs, files)
+
+	if _, err := os.Stat(stateFile); !os.IsNotExist(err) {
+		t.Errorf("state file should be removed after success, got %v", err)
+	}
+}
+
+func TestExtractor_StateFile_DifferentRoot(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	root := importTestFiles(t, bs)
+	stateFile := filepath.Join(t.TempDir(), "extract.state")
+	data, _ := json.Marshal(extractState{RootCid: "bafyother", Completed: []string{"test1.txt"}})
+	if err := os.WriteFile(stateFile, data, 0o644); err != nil {
+		t.Fatalf("failed to write state file: %v", err)
+	}
+
+	var warnings []error
+	out := t.TempDir()
+	ext := NewExtractor(bs, root, out).
+		WithStateFile(stateFile).
+		WithWarningHandler(func(err error) { warnings = append(warnings, err) })
+
+	if err := ext.Extract(context.Background(), true); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	if len(warnings) != 1 || !errors.Is(warnings[0], ErrStateFileIgnored) {
+		t.Errorf("expected one ErrStateFileIgnored warning, got %v", warnings)
+	}
+	if _, err := os.Stat(filepath.Join(out, "test1.txt")); err != nil {
+		t.Errorf("entry from foreign state file was skipped: %v", err)
+	}
+}
+
+func TestExtractor_StateFile_Revalidate(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	const dirs, files = 2, 10
+	root := importManyFiles(t, bs, dirs, files)
+
+	out := filepath.Join(t.TempDir(), "out")
+	stateFile := filepath.Join(t.TempDir(), "extract.state")
+	interruptExtraction(t, bs, root, out, stateFile)
+
+	data, err := os.ReadFile(stateFile)
+	if err != nil {
+		t.Fatalf("state file not written: %v", err)
+	}
+	var state extractState
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatalf("invalid state file: %v", err)
+	}
+
+	// Remove a file recorded as completed
+	var removed string
+	for _, rel := range state.Completed {
+		if strings.HasSuffix(rel, ".txt") {
+			removed = filepath.Join(out, rel)
+			break
+		}
+	}
+	if removed == "" {
+		t.Fatal("no completed file recorded")
+	}
+	if err := os.Remove(removed); err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+
+	if err := NewExtractor(bs, root, out).WithStateFile(stateFile).WithRevalidate(true).Extract(context.Background(), false); err != nil {
+		t.Fatalf("revalidated Extract failed: %v", err)
+	}
+	verifyManyFiles(t, out, dirs, files)
+}
+
+func TestStateTracker_FlushInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	st, err := loadStateTracker(path, "bafyroot", clk, nil)
+	if err != nil {
+		t.Fatalf("loadStateTracker failed: %v", err)
+	}
+
+	if err := st.markCompleted("a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(stateFlushInterval - time.Nanosecond)
+	if err := st.markCompleted("b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("state file written before the flush interval: %v", err)
+	}
+
+	clk.Advance(time.Nanosecond)
+	if err := st.markCompleted("c.txt"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("state file not written after the flush interval: %v", err)
+	}
+	var state extractState
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Completed) != 3 {
+		t.Errorf("state file lists %v, want 3 entries", state.Completed)
+	}
+}
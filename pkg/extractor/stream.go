@@ -0,0 +1,76 @@
+package extractor
+
+import (
+	"context"
+	"io"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/files"
+	"github.com/ipfs/boxo/ipld/merkledag"
+)
+
+// ExtractToWriter streams the content of the file identified by the
+// extractor's CID to w without touching disk, for example to serve it over
+// HTTP, and returns the number of bytes written.
+//
+// A directory root fails with ErrIsDirectory and a symlink root with
+// ErrUnsupportedFileType, before anything is written. Cancelling ctx stops
+// the stream between two reads and returns ctx.Err() together with the bytes
+// written so far. Progress is reported to the WithProgress callback as
+// Extract reports it, with the file size as the total. Settings for writing
+// to a destination, such as WithDestination, WithStateFile or WithSelector,
+// do not apply to a stream and are ignored.
+func (ext *Extractor) ExtractToWriter(ctx context.Context, w io.Writer) (int64, error) {
+	ds := merkledag.NewDAGService(blockservice.New(ext.blockStore, nil))
+	root, size, err := openRoot(ctx, ds, ext.cid)
+	if err != nil {
+		return 0, err
+	}
+	defer root.Close()
+
+	file, ok := root.(files.File)
+	if !ok {
+		if ext.isDir(root) {
+			return 0, ErrIsDirectory
+		}
+		return 0, wrapUnsupportedFileType(ext.cid, root)
+	}
+
+	ext.trackerMu.Lock()
+	if ext.tracker == nil {
+		ext.tracker = newProgressTracker(size, nil)
+	} else {
+		ext.tracker.setTotal(size)
+	}
+	ext.trackerMu.Unlock()
+
+	pr := &extractReader{
+		r:          &ctxReader{ctx: ctx, r: file},
+		onProgress: func(n int64) { ext.updateProgress(n, ext.cid) },
+		ctx:        ctx,
+		yield:      newYielder(ext.clock, ext.yieldEvery, ext.yieldSleep, ext.onYield),
+		size:       size,
+	}
+
+	buf := ext.bufferPool.Get().([]byte)
+	defer ext.bufferPool.Put(buf)
+
+	written, err := io.CopyBuffer(w, pr, buf)
+	if pr.bytesSinceUpdate > 0 {
+		ext.updateProgress(pr.bytesSinceUpdate, ext.cid)
+	}
+	return written, err
+}
+
+// ctxReader fails every read once ctx is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *ctxReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
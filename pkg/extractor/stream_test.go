@@ -0,0 +1,106 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/tragoedia0722/repository/pkg/importer"
+)
+
+// importFileCid imports data as the only file of a directory and returns the
+// CID of the file.
+func importFileCid(t *testing.T, bs blockstore.Blockstore, data []byte) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "data.bin"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := importer.NewImporter(bs, dir).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	return result.Contents[0].Cid
+}
+
+func TestExtractor_ExtractToWriter(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	for _, size := range []int{0, 100, 1 << 20, 3<<20 + 123} {
+		data := readAheadData(size)
+		fileCid := importFileCid(t, bs, data)
+
+		var completed, total int64
+		ext := NewExtractor(bs, fileCid, "unused").WithProgress(func(c, tot int64, _ string) {
+			completed, total = c, tot
+		})
+		var buf bytes.Buffer
+		n, err := ext.ExtractToWriter(context.Background(), &buf)
+		if err != nil {
+			t.Fatalf("size %d: ExtractToWriter failed: %v", size, err)
+		}
+		if n != int64(size) || !bytes.Equal(buf.Bytes(), data) {
+			t.Errorf("size %d: wrote %d bytes, content equal = %v", size, n, bytes.Equal(buf.Bytes(), data))
+		}
+		if size > 0 && (completed != int64(size) || total != int64(size)) {
+			t.Errorf("size %d: progress %d of %d", size, completed, total)
+		}
+	}
+}
+
+func TestExtractor_ExtractToWriter_Directory(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	root := importFixture(t, bs, map[string][]byte{"a.txt": []byte("alpha")}, nil).RootCid
+	var buf bytes.Buffer
+	if _, err := NewExtractor(bs, root, "unused").ExtractToWriter(context.Background(), &buf); !errors.Is(err, ErrIsDirectory) {
+		t.Fatalf("ExtractToWriter error = %v, want ErrIsDirectory", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("%d bytes written for a directory", buf.Len())
+	}
+}
+
+// cancelWriter cancels a context once it has received limit bytes.
+type cancelWriter struct {
+	buf    bytes.Buffer
+	limit  int
+	cancel context.CancelFunc
+}
+
+func (w *cancelWriter) Write(p []byte) (int, error) {
+	n, err := w.buf.Write(p)
+	if w.buf.Len() >= w.limit {
+		w.cancel()
+	}
+	return n, err
+}
+
+func TestExtractor_ExtractToWriter_Cancel(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	data := readAheadData(4 << 20)
+	fileCid := importFileCid(t, bs, data)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &cancelWriter{limit: 1 << 20, cancel: cancel}
+	n, err := NewExtractor(bs, fileCid, "unused").ExtractToWriter(ctx, w)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ExtractToWriter error = %v, want context.Canceled", err)
+	}
+	if n != int64(w.buf.Len()) || n >= int64(len(data)) {
+		t.Errorf("returned %d bytes, writer got %d of %d", n, w.buf.Len(), len(data))
+	}
+	if !bytes.Equal(w.buf.Bytes(), data[:w.buf.Len()]) {
+		t.Error("streamed prefix does not match the file")
+	}
+}
@@ -0,0 +1,185 @@
+package extractor
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/files"
+	"github.com/ipfs/boxo/ipld/merkledag"
+)
+
+// TarOption configures the extractor used by ExtractTarStream.
+type TarOption func(*Extractor)
+
+// ExtractTarStream writes the DAG below rootCid to w as a tar archive
+// without touching disk, for example to pipe it into "tar -x".
+//
+// The entries of a root directory are written at the top level of the
+// archive, so extracting the archive into a directory gives the same tree as
+// Extract. A root file is written as a single entry named after rootCid.
+// Entry names are cleaned and checked as Extract does, and symlinks whose
+// target would leave the tree fail with ErrInvalidSymlinkTarget.
+//
+// The options configure the underlying extractor, for example
+// func(ext *Extractor) { ext.WithProgress(fn) }. Settings for writing to a
+// destination, such as WithDestination, WithStateFile, WithTextTransform or
+// WithSelector, do not apply to a stream and are ignored.
+func ExtractTarStream(ctx context.Context, bs blockstore.Blockstore, rootCid string, w io.Writer, opts ...TarOption) error {
+	ext := NewExtractor(bs, rootCid, "")
+	for _, opt := range opts {
+		opt(ext)
+	}
+
+	ds := merkledag.NewDAGService(blockservice.New(bs, nil))
+	root, size, err := openRoot(ctx, ds, rootCid)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	ext.trackerMu.Lock()
+	if ext.tracker == nil {
+		ext.tracker = newProgressTracker(size, nil)
+	} else {
+		ext.tracker.setTotal(size)
+	}
+	ext.trackerMu.Unlock()
+
+	tw := tar.NewWriter(w)
+	if _, isDir := root.(files.Directory); isDir {
+		err = ext.writeTarEntry(ctx, tw, root, "")
+	} else {
+		err = ext.writeTarEntry(ctx, tw, root, rootCid)
+	}
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// writeTarEntry writes nd to tw at the slash path name, which is empty for
+// the root directory, followed by the entries of a directory.
+func (ext *Extractor) writeTarEntry(ctx context.Context, tw *tar.Writer, nd files.Node, name string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	hdr := &tar.Header{
+		Name:    name,
+		ModTime: tarModTime(nd.ModTime()),
+	}
+	switch node := nd.(type) {
+	case *unreadableNode:
+		return wrapReadFailed(name, node.err)
+
+	case *files.Symlink:
+		if !ext.isValidSymlinkTarget(node.Target) {
+			return wrapInvalidSymlinkTarget(node.Target)
+		}
+		hdr.Typeflag = tar.TypeSymlink
+		hdr.Linkname = node.Target
+		hdr.Mode = 0o777
+		return tw.WriteHeader(hdr)
+
+	case files.File:
+		size, err := node.Size()
+		if err != nil {
+			return fmt.Errorf("failed to get node size: %w", err)
+		}
+		hdr.Typeflag = tar.TypeReg
+		hdr.Size = size
+		hdr.Mode = int64(tarPerm(nd, filePermissions))
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		return ext.writeTarData(ctx, tw, node, name)
+
+	case files.Directory:
+		if name != "" {
+			hdr.Typeflag = tar.TypeDir
+			hdr.Name = name + "/"
+			hdr.Mode = int64(tarPerm(nd, dirPermissions))
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+		}
+		entries, err := ext.entries(ctx, node)
+		if err != nil {
+			return err
+		}
+		return ext.writeTarDir(ctx, tw, entries, name)
+
+	default:
+		return wrapUnsupportedFileType(name, node)
+	}
+}
+
+// writeTarDir writes the entries of the directory at name, checking their
+// names as processDirectory does.
+func (ext *Extractor) writeTarDir(ctx context.Context, tw *tar.Writer, entries files.DirIterator, name string) error {
+	for entries.Next() {
+		entryName := entries.Name()
+		if entryName == "" || entryName == "." || entryName == ".." {
+			return wrapInvalidDirectoryEntry(entryName)
+		}
+		if isXattrMetadata(name, entryName) {
+			continue
+		}
+
+		cleanedName, err := normalizeEntryName(entryName)
+		if err != nil {
+			return err
+		}
+		if err := ext.writeTarEntry(ctx, tw, entries.Node(), path.Join(name, filepath.ToSlash(cleanedName))); err != nil {
+			return err
+		}
+	}
+	return entries.Err()
+}
+
+// writeTarData copies the data of node to tw, reporting progress.
+func (ext *Extractor) writeTarData(ctx context.Context, tw *tar.Writer, node files.File, name string) error {
+	pr := &extractReader{
+		r:          node,
+		onProgress: func(n int64) { ext.updateProgress(n, name) },
+		ctx:        ctx,
+		yield:      newYielder(ext.clock, ext.yieldEvery, ext.yieldSleep, ext.onYield),
+		size:       -1,
+	}
+
+	buf := ext.bufferPool.Get().([]byte)
+	defer ext.bufferPool.Put(buf)
+
+	if _, err := io.CopyBuffer(tw, pr, buf); err != nil {
+		return err
+	}
+	if pr.bytesSinceUpdate > 0 {
+		ext.updateProgress(pr.bytesSinceUpdate, name)
+	}
+	return nil
+}
+
+// tarPerm returns the permission bits recorded for nd, or def if none were.
+func tarPerm(nd files.Node, def uint32) uint32 {
+	if perm := uint32(nd.Mode().Perm()); perm != 0 {
+		return perm
+	}
+	return def
+}
+
+// tarModTime returns t, or the Unix epoch if no time was recorded.
+func tarModTime(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Unix(0, 0)
+	}
+	return t
+}
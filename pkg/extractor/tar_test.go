@@ -0,0 +1,113 @@
+package extractor
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tragoedia0722/repository/pkg/importer"
+)
+
+// readTar returns the type and content of every entry of an archive by name.
+func readTar(t *testing.T, data []byte) (map[string]byte, map[string][]byte) {
+	t.Helper()
+
+	types := make(map[string]byte)
+	contents := make(map[string][]byte)
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return types, contents
+		}
+		if err != nil {
+			t.Fatalf("failed to read archive: %v", err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		types[hdr.Name] = hdr.Typeflag
+		contents[hdr.Name] = content
+	}
+}
+
+func TestExtractTarStream(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	fixture := retryFixture()
+	root := importFixture(t, bs, fixture, nil).RootCid
+
+	var completed, total int64
+	var buf bytes.Buffer
+	err := ExtractTarStream(context.Background(), bs, root, &buf, func(ext *Extractor) {
+		ext.WithProgress(func(c, tot int64, _ string) { completed, total = c, tot })
+	})
+	if err != nil {
+		t.Fatalf("ExtractTarStream failed: %v", err)
+	}
+
+	types, contents := readTar(t, buf.Bytes())
+	if types["dir/"] != tar.TypeDir || types["dir/sub/"] != tar.TypeDir {
+		t.Errorf("directory entries missing: %v", types)
+	}
+	for rel, data := range fixture {
+		if types[rel] != tar.TypeReg || !bytes.Equal(contents[rel], data) {
+			t.Errorf("%s: entry missing or content differs", rel)
+		}
+	}
+	if len(types) != len(fixture)+2 {
+		t.Errorf("archive has %d entries, want %d", len(types), len(fixture)+2)
+	}
+	if completed != total || total == 0 {
+		t.Errorf("progress ended at %d of %d bytes", completed, total)
+	}
+}
+
+func TestExtractTarStream_SingleFile(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	path := filepath.Join(t.TempDir(), "single.txt")
+	if err := os.WriteFile(path, []byte("single"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := importer.NewImporter(bs, path).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := ExtractTarStream(context.Background(), bs, result.RootCid, &buf); err != nil {
+		t.Fatalf("ExtractTarStream failed: %v", err)
+	}
+	_, contents := readTar(t, buf.Bytes())
+	if len(contents) != 1 || string(contents[result.RootCid]) != "single" {
+		t.Errorf("archive = %v, want one entry named %s", contents, result.RootCid)
+	}
+}
+
+func TestExtractTarStream_InvalidSymlink(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	dir := t.TempDir()
+	if err := os.Symlink("../../etc/passwd", filepath.Join(dir, "escape")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	result, err := importer.NewImporter(bs, dir).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	err = ExtractTarStream(context.Background(), bs, result.RootCid, io.Discard)
+	if !errors.Is(err, ErrInvalidSymlinkTarget) {
+		t.Errorf("ExtractTarStream error = %v, want ErrInvalidSymlinkTarget", err)
+	}
+}
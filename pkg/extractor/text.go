@@ -0,0 +1,249 @@
+package extractor
+
+import (
+	"bytes"
+	"io"
+)
+
+// NewlineMode selects how line endings of text files are written.
+type NewlineMode int
+
+const (
+	// NewlinePreserve writes line endings as stored.
+	NewlinePreserve NewlineMode = iota
+	// NewlineLF converts CRLF pairs to LF. Lone CR bytes are kept.
+	NewlineLF
+	// NewlineCRLF converts lone LF bytes to CRLF.
+	NewlineCRLF
+)
+
+// BOMMode selects how a UTF-8 byte order mark of text files is written.
+type BOMMode int
+
+const (
+	// BOMPreserve writes the byte order mark as stored.
+	BOMPreserve BOMMode = iota
+	// BOMAdd adds a UTF-8 byte order mark to files that lack one.
+	BOMAdd
+	// BOMStrip removes a leading UTF-8 byte order mark.
+	BOMStrip
+)
+
+// TextTransform rewrites line endings and the byte order mark of text files
+// while they are extracted.
+type TextTransform struct {
+	Newlines NewlineMode
+	BOM      BOMMode
+
+	// Detect classifies a file as text from its path relative to the
+	// extraction root and up to the first 8KB of its content. When nil, a
+	// file is text if the sample contains no NUL byte.
+	Detect func(relPath string, sample []byte) bool
+}
+
+// TransformedFile is a file whose extracted content differs from the DAG
+// because of the text transform.
+type TransformedFile struct {
+	Path        string // Path relative to the extraction root
+	SourceSize  int64  // Size of the file in the DAG
+	WrittenSize int64  // Size of the file written to disk
+}
+
+// textSampleSize is the amount of content passed to TextTransform.Detect.
+const textSampleSize = 8 * 1024
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+// WithTextTransform rewrites line endings and the UTF-8 byte order mark of
+// files classified as text while they are written. The transform is streamed
+// and only holds the detection sample in memory.
+//
+// Transformed files no longer match the DAG byte for byte: they are listed by
+// Transformed, and because their size differs they are never skipped as
+// existing files of the same size. Progress is still counted in source bytes,
+// so totals match the DAG. Files starting with a UTF-16 byte order mark are
+// written unchanged, since a byte-wise transform would corrupt them.
+// Returns the extractor instance for method chaining.
+func (ext *Extractor) WithTextTransform(tt TextTransform) *Extractor {
+	ext.text = &tt
+	return ext
+}
+
+// Transformed returns the files whose content was changed by the text
+// transform during the last extraction.
+func (ext *Extractor) Transformed() []TransformedFile {
+	return ext.rewritten
+}
+
+// isText applies the transform's classification to a content sample.
+func (tt *TextTransform) isText(relPath string, sample []byte) bool {
+	if bytes.HasPrefix(sample, utf16LEBOM) || bytes.HasPrefix(sample, utf16BEBOM) {
+		return false
+	}
+	if tt.Detect != nil {
+		return tt.Detect(relPath, sample)
+	}
+	return bytes.IndexByte(sample, 0) < 0
+}
+
+// textWriter applies a TextTransform to a stream written through it. The
+// first textSampleSize bytes are held back until the file is classified;
+// after that, data is converted as it arrives, with at most a trailing CR
+// carried over to the next write.
+type textWriter struct {
+	w       io.Writer
+	tt      *TextTransform
+	relPath string
+
+	head    []byte // content held back for classification
+	decided bool
+	text    bool
+
+	pendingCR bool // NewlineLF: a CR ended the last write
+	lastCR    bool // NewlineCRLF: the last byte written was a CR
+	changed   bool // output differs from the input
+	written   int64
+	out       []byte // reused conversion buffer
+}
+
+func newTextWriter(w io.Writer, tt *TextTransform, relPath string) *textWriter {
+	return &textWriter{w: w, tt: tt, relPath: relPath}
+}
+
+// Write consumes p and reports it as fully written so that io.Copy counts
+// source bytes.
+func (tw *textWriter) Write(p []byte) (int, error) {
+	if tw.decided {
+		return len(p), tw.emit(p)
+	}
+
+	tw.head = append(tw.head, p...)
+	if len(tw.head) < textSampleSize {
+		return len(p), nil
+	}
+	return len(p), tw.decide()
+}
+
+// Close writes any data still held back. It does not close the underlying writer.
+func (tw *textWriter) Close() error {
+	if !tw.decided {
+		if err := tw.decide(); err != nil {
+			return err
+		}
+	}
+	if tw.pendingCR {
+		tw.pendingCR = false
+		return tw.write([]byte{'\r'})
+	}
+	return nil
+}
+
+// decide classifies the file from the held-back content and writes it.
+func (tw *textWriter) decide() error {
+	tw.decided = true
+	head := tw.head
+	tw.head = nil
+
+	sample := head
+	if len(sample) > textSampleSize {
+		sample = sample[:textSampleSize]
+	}
+	tw.text = tw.tt.isText(tw.relPath, sample)
+	if !tw.text {
+		return tw.write(head)
+	}
+
+	hasBOM := bytes.HasPrefix(head, utf8BOM)
+	switch {
+	case tw.tt.BOM == BOMStrip && hasBOM:
+		head = head[len(utf8BOM):]
+		tw.changed = true
+	case tw.tt.BOM == BOMAdd && !hasBOM:
+		if err := tw.write(utf8BOM); err != nil {
+			return err
+		}
+		tw.changed = true
+	}
+
+	return tw.emit(head)
+}
+
+// emit converts p according to the newline mode and writes it.
+func (tw *textWriter) emit(p []byte) error {
+	if !tw.text || len(p) == 0 {
+		return tw.write(p)
+	}
+
+	switch tw.tt.Newlines {
+	case NewlineLF:
+		return tw.write(tw.toLF(p))
+	case NewlineCRLF:
+		return tw.write(tw.toCRLF(p))
+	default:
+		return tw.write(p)
+	}
+}
+
+// toLF drops the CR of every CRLF pair, holding back a trailing CR until the
+// next byte is known.
+func (tw *textWriter) toLF(p []byte) []byte {
+	out := tw.out[:0]
+	if tw.pendingCR {
+		tw.pendingCR = false
+		if p[0] == '\n' {
+			tw.changed = true
+		} else {
+			out = append(out, '\r')
+		}
+	}
+
+	for i := 0; i < len(p); i++ {
+		if p[i] != '\r' {
+			out = append(out, p[i])
+			continue
+		}
+		if i == len(p)-1 {
+			tw.pendingCR = true
+			break
+		}
+		if p[i+1] == '\n' {
+			tw.changed = true
+			continue
+		}
+		out = append(out, '\r')
+	}
+
+	tw.out = out
+	return out
+}
+
+// toCRLF inserts a CR before every LF not already preceded by one.
+func (tw *textWriter) toCRLF(p []byte) []byte {
+	out := tw.out[:0]
+	prevCR := tw.lastCR
+	for _, b := range p {
+		if b == '\n' && !prevCR {
+			out = append(out, '\r')
+			tw.changed = true
+		}
+		out = append(out, b)
+		prevCR = b == '\r'
+	}
+	tw.lastCR = prevCR
+
+	tw.out = out
+	return out
+}
+
+func (tw *textWriter) write(p []byte) error {
+	if len(p) == 0 {
+		return nil
+	}
+	n, err := tw.w.Write(p)
+	tw.written += int64(n)
+	return err
+}
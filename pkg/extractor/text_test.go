@@ -0,0 +1,180 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// transformAll writes input through a textWriter in pieces of at most step
+// bytes and returns the output.
+func transformAll(t *testing.T, tt TextTransform, input []byte, step int) []byte {
+	t.Helper()
+
+	var out bytes.Buffer
+	tw := newTextWriter(&out, &tt, "file.txt")
+	for len(input) > 0 {
+		n := min(step, len(input))
+		if _, err := tw.Write(input[:n]); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		input = input[n:]
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if tw.written != int64(out.Len()) {
+		t.Errorf("written = %d, output has %d bytes", tw.written, out.Len())
+	}
+	return out.Bytes()
+}
+
+func TestTextWriter_Modes(t *testing.T) {
+	bom := string(utf8BOM)
+	tests := []struct {
+		name  string
+		tt    TextTransform
+		input string
+		want  string
+	}{
+		{"preserve", TextTransform{}, bom + "a\r\nb\nc\r", bom + "a\r\nb\nc\r"},
+		{"lf", TextTransform{Newlines: NewlineLF}, "a\r\nb\nc\rd\r\n", "a\nb\nc\rd\n"},
+		{"lf trailing cr", TextTransform{Newlines: NewlineLF}, "a\r\n\r", "a\n\r"},
+		{"lf double cr", TextTransform{Newlines: NewlineLF}, "a\r\r\nb", "a\r\nb"},
+		{"crlf", TextTransform{Newlines: NewlineCRLF}, "a\nb\r\nc\rd\n", "a\r\nb\r\nc\rd\r\n"},
+		{"bom add", TextTransform{BOM: BOMAdd}, "a\n", bom + "a\n"},
+		{"bom add existing", TextTransform{BOM: BOMAdd}, bom + "a\n", bom + "a\n"},
+		{"bom strip", TextTransform{BOM: BOMStrip}, bom + "a\n", "a\n"},
+		{"bom strip missing", TextTransform{BOM: BOMStrip}, "a\n", "a\n"},
+		{"crlf and bom", TextTransform{Newlines: NewlineCRLF, BOM: BOMAdd}, "a\nb\n", bom + "a\r\nb\r\n"},
+		{"lf and strip", TextTransform{Newlines: NewlineLF, BOM: BOMStrip}, bom + "a\r\nb", "a\nb"},
+		{"binary untouched", TextTransform{Newlines: NewlineCRLF, BOM: BOMAdd}, "a\n\x00b\n", "a\n\x00b\n"},
+		{"utf16 untouched", TextTransform{Newlines: NewlineCRLF, BOM: BOMStrip}, "\xff\xfea\x00\n\x00", "\xff\xfea\x00\n\x00"},
+		{"empty", TextTransform{BOM: BOMAdd}, "", bom},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Every write size exercises every split position of the input.
+			for step := 1; step <= len(tc.input)+1; step++ {
+				got := transformAll(t, tc.tt, []byte(tc.input), step)
+				if string(got) != tc.want {
+					t.Fatalf("step %d: got %q, want %q", step, got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestTextWriter_Detect(t *testing.T) {
+	var sampled []string
+	tt := TextTransform{
+		Newlines: NewlineCRLF,
+		Detect: func(relPath string, sample []byte) bool {
+			sampled = append(sampled, relPath)
+			if len(sample) != textSampleSize {
+				t.Errorf("sample has %d bytes, want %d", len(sample), textSampleSize)
+			}
+			return false
+		},
+	}
+
+	input := []byte(strings.Repeat("line\n", textSampleSize))
+	got := transformAll(t, tt, input, 4096)
+	if !bytes.Equal(got, input) {
+		t.Error("file classified as binary was changed")
+	}
+	if len(sampled) != 1 || sampled[0] != "file.txt" {
+		t.Errorf("Detect calls = %v", sampled)
+	}
+}
+
+func TestExtractor_TextTransform_RoundTrip(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	// The importer splits files into 1MB chunks; place a CRLF pair across
+	// the boundary of the first two chunks.
+	const chunk = 1024 * 1024
+	split := bytes.Repeat([]byte("x"), chunk-1)
+	split = append(split, "\r\nend\n"...)
+
+	crlf := []byte("one\r\ntwo\r\n")
+	lf := []byte("one\ntwo\n")
+	binary := []byte("bin\n\x00\r\n")
+
+	root := importFixture(t, bs, map[string][]byte{
+		"split.txt":  split,
+		"crlf.txt":   crlf,
+		"lf.txt":     lf,
+		"binary.dat": binary,
+	}, nil).RootCid
+
+	toLF := func(b []byte) []byte { return bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n")) }
+	toCRLF := func(b []byte) []byte { return bytes.ReplaceAll(toLF(b), []byte("\n"), []byte("\r\n")) }
+	withBOM := func(b []byte) []byte { return append(append([]byte{}, utf8BOM...), b...) }
+
+	tests := []struct {
+		name string
+		tt   TextTransform
+		want func([]byte) []byte
+	}{
+		{"preserve", TextTransform{}, func(b []byte) []byte { return b }},
+		{"lf", TextTransform{Newlines: NewlineLF}, toLF},
+		{"crlf", TextTransform{Newlines: NewlineCRLF}, toCRLF},
+		{"crlf with bom", TextTransform{Newlines: NewlineCRLF, BOM: BOMAdd}, func(b []byte) []byte { return withBOM(toCRLF(b)) }},
+		{"lf without bom", TextTransform{Newlines: NewlineLF, BOM: BOMStrip}, toLF},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out := t.TempDir()
+
+			var completed int64
+			ext := NewExtractor(bs, root, out).
+				WithTextTransform(tc.tt).
+				WithProgress(func(c, _ int64, _ string) { completed = c })
+			if err := ext.Extract(context.Background(), false); err != nil {
+				t.Fatalf("Extract failed: %v", err)
+			}
+
+			if sourceBytes := int64(len(split) + len(crlf) + len(lf) + len(binary)); completed != sourceBytes {
+				t.Errorf("progress reported %d bytes, want %d source bytes", completed, sourceBytes)
+			}
+
+			transformed := make(map[string]TransformedFile)
+			for _, f := range ext.Transformed() {
+				transformed[f.Path] = f
+			}
+
+			for name, source := range map[string][]byte{"split.txt": split, "crlf.txt": crlf, "lf.txt": lf} {
+				got, err := os.ReadFile(filepath.Join(out, name))
+				if err != nil {
+					t.Fatalf("failed to read %s: %v", name, err)
+				}
+				want := tc.want(source)
+				if !bytes.Equal(got, want) {
+					t.Errorf("%s: content mismatch (got %d bytes, want %d)", name, len(got), len(want))
+				}
+
+				f, listed := transformed[name]
+				if changed := !bytes.Equal(want, source); listed != changed {
+					t.Errorf("%s: listed as transformed = %v, want %v", name, listed, changed)
+				} else if listed && (f.SourceSize != int64(len(source)) || f.WrittenSize != int64(len(want))) {
+					t.Errorf("%s: sizes = %d/%d, want %d/%d", name, f.SourceSize, f.WrittenSize, len(source), len(want))
+				}
+			}
+
+			got, err := os.ReadFile(filepath.Join(out, "binary.dat"))
+			if err != nil {
+				t.Fatalf("failed to read binary.dat: %v", err)
+			}
+			if !bytes.Equal(got, binary) {
+				t.Errorf("binary file was changed: %q", got)
+			}
+		})
+	}
+}
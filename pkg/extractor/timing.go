@@ -0,0 +1,191 @@
+package extractor
+
+import (
+	"io"
+	"sort"
+	"time"
+
+	"github.com/tragoedia0722/repository/pkg/clock"
+)
+
+// FileTiming reports where the time extracting a single file went.
+type FileTiming struct {
+	Path      string        // Path relative to the extraction root
+	Bytes     int64         // Bytes read from the DAG
+	ReadTime  time.Duration // Time spent reading from the DAG
+	WriteTime time.Duration // Time spent writing to disk, including the final sync
+	MBps      float64       // Effective throughput over ReadTime+WriteTime in MB/s
+}
+
+// WriteBound reports whether writing to disk took longer than reading from
+// the DAG.
+func (ft FileTiming) WriteBound() bool {
+	return ft.WriteTime > ft.ReadTime
+}
+
+// TimingSummary aggregates the timings of an extraction.
+//
+// Totals cover every extracted file. Files lists only files of at least the
+// timing threshold, and the percentiles are computed over those files.
+type TimingSummary struct {
+	Files      []FileTiming  // Files at or above the threshold, in extraction order
+	TotalFiles int           // Number of files extracted
+	TotalBytes int64         // Bytes read from the DAG over all files
+	TotalRead  time.Duration // Time spent reading from the DAG over all files
+	TotalWrite time.Duration // Time spent writing to disk over all files
+	ReadP50    time.Duration
+	ReadP95    time.Duration
+	WriteP50   time.Duration
+	WriteP95   time.Duration
+	MBpsP50    float64
+	MBpsP95    float64
+}
+
+// fileTimer splits the time spent copying one file between reads and writes.
+// Each read and each write takes a single clock reading, and the time since
+// the previous reading is charged to it.
+type fileTimer struct {
+	clock clock.Clock
+	last  time.Time
+	read  time.Duration
+	write time.Duration
+	bytes int64
+}
+
+func newFileTimer(clk clock.Clock) *fileTimer {
+	return &fileTimer{clock: clk, last: clk.Now()}
+}
+
+// afterRead charges the time since the last reading to reads.
+func (ft *fileTimer) afterRead(n int) {
+	now := ft.clock.Now()
+	ft.read += now.Sub(ft.last)
+	ft.last = now
+	ft.bytes += int64(n)
+}
+
+// afterWrite charges the time since the last reading to writes.
+func (ft *fileTimer) afterWrite() {
+	now := ft.clock.Now()
+	ft.write += now.Sub(ft.last)
+	ft.last = now
+}
+
+// timedWriter records write time on a fileTimer.
+type timedWriter struct {
+	w     io.Writer
+	timer *fileTimer
+}
+
+func (tw *timedWriter) Write(p []byte) (int, error) {
+	n, err := tw.w.Write(p)
+	tw.timer.afterWrite()
+	return n, err
+}
+
+// timingCollector accumulates file timings during an extraction.
+type timingCollector struct {
+	threshold int64
+	summary   TimingSummary
+}
+
+// add records the timing of a completed file.
+func (c *timingCollector) add(path string, ft *fileTimer) {
+	if c == nil {
+		return
+	}
+
+	c.summary.TotalFiles++
+	c.summary.TotalBytes += ft.bytes
+	c.summary.TotalRead += ft.read
+	c.summary.TotalWrite += ft.write
+
+	if ft.bytes < c.threshold {
+		return
+	}
+	c.summary.Files = append(c.summary.Files, FileTiming{
+		Path:      path,
+		Bytes:     ft.bytes,
+		ReadTime:  ft.read,
+		WriteTime: ft.write,
+		MBps:      throughput(ft.bytes, ft.read+ft.write),
+	})
+}
+
+// result returns the summary with percentiles filled in.
+func (c *timingCollector) result() *TimingSummary {
+	if c == nil {
+		return nil
+	}
+
+	s := c.summary
+	reads := make([]time.Duration, len(s.Files))
+	writes := make([]time.Duration, len(s.Files))
+	rates := make([]float64, len(s.Files))
+	for i, f := range s.Files {
+		reads[i], writes[i], rates[i] = f.ReadTime, f.WriteTime, f.MBps
+	}
+	s.ReadP50, s.ReadP95 = percentile(reads, 50), percentile(reads, 95)
+	s.WriteP50, s.WriteP95 = percentile(writes, 50), percentile(writes, 95)
+	s.MBpsP50, s.MBpsP95 = percentile(rates, 50), percentile(rates, 95)
+	return &s
+}
+
+// throughput returns bytes per elapsed in MB/s.
+func throughput(bytes int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(bytes) / (1024 * 1024) / elapsed.Seconds()
+}
+
+// percentile returns the nearest-rank p-th percentile of values, sorting
+// them in place. It returns zero for an empty slice.
+func percentile[T time.Duration | float64](values []T, p int) T {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	rank := (p*len(values) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return values[rank-1]
+}
+
+// WithTimings records for every extracted file the time spent reading from
+// the DAG and writing to disk, available from Timings after Extract. Only
+// files of at least the timing threshold (1MB unless set by
+// WithTimingThreshold) are listed individually. When disabled, which is the
+// default, no clock readings are taken.
+// Returns the extractor instance for method chaining.
+func (ext *Extractor) WithTimings(enabled bool) *Extractor {
+	ext.timed = enabled
+	return ext
+}
+
+// WithTimingThreshold sets the minimum size of files listed individually by
+// Timings. Returns the extractor instance for method chaining.
+func (ext *Extractor) WithTimingThreshold(bytes int64) *Extractor {
+	ext.timingMin = bytes
+	return ext
+}
+
+// Timings returns the timings of the last extraction, or nil if WithTimings
+// is not enabled.
+func (ext *Extractor) Timings() *TimingSummary {
+	return ext.timings.result()
+}
+
+// newTimingCollector returns the collector for an extraction, or nil if
+// timings are disabled.
+func (ext *Extractor) newTimingCollector() *timingCollector {
+	if !ext.timed {
+		return nil
+	}
+	threshold := ext.timingMin
+	if threshold == 0 {
+		threshold = defaultTimingThreshold
+	}
+	return &timingCollector{threshold: threshold}
+}
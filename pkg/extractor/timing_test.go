@@ -0,0 +1,110 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/tragoedia0722/repository/pkg/clock/clocktest"
+)
+
+// slowWriter advances a fake clock by delay on every write.
+type slowWriter struct {
+	w     io.Writer
+	clock *clocktest.Fake
+	delay time.Duration
+}
+
+func (s *slowWriter) Write(p []byte) (int, error) {
+	s.clock.Advance(s.delay)
+	return s.w.Write(p)
+}
+
+func TestExtractor_Timings_WriteBound(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	large := bytes.Repeat([]byte("0123456789abcdef"), 2*1024*1024/16)
+	root := importFixture(t, bs, map[string][]byte{
+		"large.bin": large,
+		"small.txt": []byte("below the threshold"),
+	}, nil).RootCid
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	ext := NewExtractor(bs, root, t.TempDir()).WithClock(clk).WithTimings(true)
+	ext.dest = &fsDestination{
+		root: ext.path,
+		base: ext.basePath,
+		writer: func(f *os.File) io.Writer {
+			return &slowWriter{w: f, clock: clk, delay: 50 * time.Millisecond}
+		},
+	}
+	if err := ext.Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	timings := ext.Timings()
+	if timings == nil {
+		t.Fatal("Timings() = nil with timings enabled")
+	}
+	if timings.TotalFiles != 2 {
+		t.Errorf("TotalFiles = %d, want 2", timings.TotalFiles)
+	}
+	if want := int64(len(large) + len("below the threshold")); timings.TotalBytes != want {
+		t.Errorf("TotalBytes = %d, want %d", timings.TotalBytes, want)
+	}
+	if len(timings.Files) != 1 {
+		t.Fatalf("expected only large.bin to be listed, got %+v", timings.Files)
+	}
+
+	ft := timings.Files[0]
+	if ft.Path != "large.bin" || ft.Bytes != int64(len(large)) {
+		t.Errorf("listed file = %s with %d bytes", ft.Path, ft.Bytes)
+	}
+	if !ft.WriteBound() {
+		t.Errorf("expected large.bin to be write-bound: read %v, write %v", ft.ReadTime, ft.WriteTime)
+	}
+	if ft.WriteTime < 50*time.Millisecond || ft.WriteTime%(50*time.Millisecond) != 0 {
+		t.Errorf("WriteTime = %v, want a multiple of the injected delay", ft.WriteTime)
+	}
+	if ft.ReadTime != 0 {
+		t.Errorf("ReadTime = %v, want 0 as the clock only moves on writes", ft.ReadTime)
+	}
+	if ft.MBps <= 0 {
+		t.Errorf("MBps = %v, want positive", ft.MBps)
+	}
+	if timings.WriteP50 != ft.WriteTime || timings.WriteP95 != ft.WriteTime {
+		t.Errorf("write percentiles = %v/%v, want %v", timings.WriteP50, timings.WriteP95, ft.WriteTime)
+	}
+}
+
+func TestExtractor_Timings_Disabled(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	root := importFixture(t, bs, map[string][]byte{"a.txt": []byte("a")}, nil).RootCid
+
+	ext := NewExtractor(bs, root, t.TempDir())
+	if err := ext.Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if timings := ext.Timings(); timings != nil {
+		t.Errorf("Timings() = %+v with timings disabled, want nil", timings)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{5, 1, 4, 2, 3, 6, 7, 8, 9, 10}
+	if got := percentile(values, 50); got != 5 {
+		t.Errorf("p50 = %v, want 5", got)
+	}
+	if got := percentile(values, 95); got != 10 {
+		t.Errorf("p95 = %v, want 10", got)
+	}
+	if got := percentile([]float64{}, 50); got != 0 {
+		t.Errorf("p50 of empty = %v, want 0", got)
+	}
+}
@@ -0,0 +1,70 @@
+package extractor
+
+import (
+	"context"
+
+	"github.com/tragoedia0722/repository/pkg/tracing"
+)
+
+// WithTracer reports the extraction to t: one span for the extraction and a
+// child span for every file written, with the bytes written, the filesystem
+// retries it needed and whether it was linked from the WithLinkDest
+// directory. The extraction span is a child of the span in the context
+// passed to Extract. A nil tracer, the default, disables tracing.
+// Returns the extractor instance for method chaining.
+func (ext *Extractor) WithTracer(t tracing.Tracer) *Extractor {
+	ext.tracer = t
+	return ext
+}
+
+// fileSpan is the span of the file being written. All methods are no-ops
+// on a nil fileSpan, which writeFileWithBuffer uses when tracing is disabled.
+type fileSpan struct {
+	span    tracing.Span
+	ext     *Extractor
+	retries int   // Retries of the extraction when the file started
+	written int64 // Bytes written to the destination
+	linked  bool  // The file was linked from the reference directory
+}
+
+// startFileSpan starts the span of the file at relativePath, or returns ctx
+// and nil when tracing is disabled.
+func (ext *Extractor) startFileSpan(ctx context.Context, relativePath string) (context.Context, *fileSpan) {
+	if ext.tracer == nil {
+		return ctx, nil
+	}
+	ctx, span := ext.tracer.StartSpan(ctx, tracing.SpanExtractFile)
+	span.SetAttributes(tracing.String(tracing.AttrPath, relativePath))
+	return ctx, &fileSpan{span: span, ext: ext, retries: ext.retryStat.Retries}
+}
+
+// done records how the file was written.
+func (s *fileSpan) done(written int64, linked bool) {
+	if s == nil {
+		return
+	}
+	s.written = written
+	s.linked = linked
+}
+
+// skipRetries leaves out n retries of other files, made while the file
+// was written on its own goroutine.
+func (s *fileSpan) skipRetries(n int) {
+	if s == nil {
+		return
+	}
+	s.retries += n
+}
+
+// end ends the span with the error writeFileWithBuffer returns.
+func (s *fileSpan) end(err *error) {
+	if s == nil {
+		return
+	}
+	s.span.SetAttributes(
+		tracing.Int64(tracing.AttrBytes, s.written),
+		tracing.Int(tracing.AttrRetries, s.ext.retryStat.Retries-s.retries),
+		tracing.Bool(tracing.AttrCacheHit, s.linked),
+	)
+	tracing.End(s.span, *err)
+}
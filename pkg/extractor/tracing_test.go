@@ -0,0 +1,102 @@
+package extractor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tragoedia0722/repository/pkg/importer"
+	"github.com/tragoedia0722/repository/pkg/tracing"
+	"github.com/tragoedia0722/repository/pkg/tracing/tracingtest"
+)
+
+func TestExtractor_WithTracer_ImportExtractCycle(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	src := t.TempDir()
+	files := map[string][]byte{
+		"a.txt":     []byte("hello"),
+		"dir/b.bin": readAheadData(1 << 20),
+	}
+	for rel, data := range files {
+		path := filepath.Join(src, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := tracingtest.NewRecorder()
+	ctx, request := rec.StartSpan(context.Background(), "request")
+	result, err := importer.NewImporter(bs, src).WithTracer(rec).Import(ctx)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if err := NewExtractor(bs, result.RootCid, t.TempDir()).WithTracer(rec).Extract(ctx, false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	request.End()
+
+	if spans := rec.Unended(); len(spans) != 0 {
+		t.Errorf("%d spans not ended", len(spans))
+	}
+
+	imports := rec.Named(tracing.SpanImport)
+	if len(imports) != 1 || imports[0].Path() != "request/"+tracing.SpanImport {
+		t.Fatalf("import spans = %v", imports)
+	}
+	if got, _ := imports[0].Attr(tracing.AttrCID); got != result.RootCid {
+		t.Errorf("import span cid = %v, want %s", got, result.RootCid)
+	}
+
+	// Every file has a span below the import, and the blocks of the large
+	// file are written in batches below its span
+	fileSpans := make(map[any]*tracingtest.Span)
+	for _, s := range rec.Named(tracing.SpanImportFile) {
+		if s.Parent != imports[0] {
+			t.Errorf("file span %s is not a child of the import", s.Path())
+		}
+		path, _ := s.Attr(tracing.AttrPath)
+		fileSpans[path] = s
+	}
+	large := fileSpans["dir/b.bin"]
+	if len(fileSpans) != len(files) || large == nil {
+		t.Fatalf("file spans for %v, want %d files", fileSpans, len(files))
+	}
+	if got, _ := large.Attr(tracing.AttrBytes); got != int64(1<<20) {
+		t.Errorf("file span bytes = %v", got)
+	}
+	batched := 0
+	for _, s := range rec.Named(tracing.SpanImportPutMany) {
+		if s.Parent == large {
+			batched++
+		}
+	}
+	if batched == 0 {
+		t.Error("no PutMany span below the span of dir/b.bin")
+	}
+
+	extracts := rec.Named(tracing.SpanExtract)
+	if len(extracts) != 1 || extracts[0].Path() != "request/"+tracing.SpanExtract {
+		t.Fatalf("extract spans = %v", extracts)
+	}
+	written := 0
+	for _, s := range rec.Named(tracing.SpanExtractFile) {
+		if s.Parent != extracts[0] {
+			t.Errorf("file span %s is not a child of the extraction", s.Path())
+		}
+		if path, _ := s.Attr(tracing.AttrPath); path == "dir/b.bin" {
+			if got, _ := s.Attr(tracing.AttrBytes); got != int64(1<<20) {
+				t.Errorf("extracted file span bytes = %v", got)
+			}
+		}
+		written++
+	}
+	if written != len(files) {
+		t.Errorf("%d extracted file spans, want %d", written, len(files))
+	}
+}
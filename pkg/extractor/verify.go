@@ -0,0 +1,350 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/files"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// WithVerify verifies every extracted file against its UnixFS node, without
+// checksums recorded at import time.
+//
+// The data is hashed as it streams from the DAG to the part file: each raw
+// leaf is rehashed with the prefix of its CID, and every other block of the
+// file is checked against its own CID before the data it holds is compared.
+// A file that does not match is removed as soon as the mismatch is found and
+// fails the extraction with a *ChecksumMismatchError holding the expected and
+// the actual CID of the first block that differs; with WithSkipRejected it is
+// reported by Rejected instead. The data is verified before any text
+// transform, and files truncated by the selector are not verified.
+//
+// Existing files skipped because of their size are trusted unless
+// WithVerifyExisting is set as well.
+// Returns the extractor instance for method chaining.
+func (ext *Extractor) WithVerify(enabled bool) *Extractor {
+	ext.verify = enabled
+	return ext
+}
+
+// WithVerifyExisting makes WithVerify also check an existing file whose size
+// matches instead of trusting its size: the whole file is read back and
+// hashed against the UnixFS node, and a file that differs is replaced. It
+// takes precedence over WithContentCheck for such files, needs a
+// ReadableDestination and has no effect without WithVerify.
+// Returns the extractor instance for method chaining.
+func (ext *Extractor) WithVerifyExisting(enabled bool) *Extractor {
+	ext.reverify = enabled
+	return ext
+}
+
+// verifyReader tees r into a verifier for node when verification is enabled
+// and the CID of node is known. It returns r otherwise.
+func (ext *Extractor) verifyReader(ctx context.Context, r io.Reader, node files.File, relativePath string) (io.Reader, error) {
+	f, ok := node.(*dagFile)
+	if !ext.verify || !ok {
+		return r, nil
+	}
+	v, err := ext.newNodeVerifier(ctx, f, relativePath)
+	if err != nil {
+		return nil, err
+	}
+	return &verifyingReader{r: r, v: v}, nil
+}
+
+// verifyExistingFile reports whether the existing file at rel holds the
+// content of node, hashing it against the node's blocks. Errors reading the
+// file count as a difference.
+func (ext *Extractor) verifyExistingFile(ctx context.Context, node *dagFile, rel, relativePath string) (bool, error) {
+	readable, ok := ext.destination().(ReadableDestination)
+	if !ok {
+		return false, nil
+	}
+	f, err := readable.Open(rel)
+	if err != nil {
+		return false, nil
+	}
+	defer f.Close()
+
+	v, err := ext.newNodeVerifier(ctx, node, relativePath)
+	if err != nil {
+		return false, err
+	}
+	if _, err := io.Copy(v, f); err != nil {
+		if isMismatch(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := v.finish(); err != nil {
+		if isMismatch(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// isMismatch reports whether err is a *ChecksumMismatchError.
+func isMismatch(err error) bool {
+	var mismatch *ChecksumMismatchError
+	return errors.As(err, &mismatch)
+}
+
+// verifyingReader passes the data read from r to a verifier. A mismatch is
+// returned as a read error, which stops the copy.
+type verifyingReader struct {
+	r io.Reader
+	v *nodeVerifier
+}
+
+func (vr *verifyingReader) Read(p []byte) (int, error) {
+	n, err := vr.r.Read(p)
+	if n > 0 {
+		if _, werr := vr.v.Write(p[:n]); werr != nil {
+			return n, werr
+		}
+	}
+	if err == io.EOF {
+		if ferr := vr.v.finish(); ferr != nil {
+			return n, ferr
+		}
+	}
+	return n, err
+}
+
+// segment is a run of file data checked as a whole.
+type segment struct {
+	cid  cid.Cid // Block the data belongs to
+	size int64   // Length of the data
+	raw  bool    // Data is a raw leaf, verified by rehashing it
+
+	data []byte         // Data of a verified dag-pb block, compared byte by byte
+	fsn  *unixfs.FSNode // UnixFS node data was taken from, to report the actual CID
+}
+
+// frame lists the children of a node still to be visited.
+type frame struct {
+	links []*ipld.Link
+	sizes []uint64
+}
+
+// nodeVerifier checks a stream of file data against the blocks of a UnixFS
+// file. Blocks are loaded as the data reaches them, so only the path from
+// the root to the current leaf is held.
+type nodeVerifier struct {
+	ctx  context.Context
+	dag  ipld.DAGService
+	root cid.Cid // Root of the file
+	path string  // Path relative to the extraction root, for errors
+
+	stack []frame
+	cur   *segment
+	buf   []byte
+	err   error // First mismatch or read error; returned by every later call
+}
+
+// newNodeVerifier returns a verifier for the data of f. Blocks are read from
+// the extractor's blockstore, bypassing the read-ahead buffer.
+func (ext *Extractor) newNodeVerifier(ctx context.Context, f *dagFile, relativePath string) (*nodeVerifier, error) {
+	v := &nodeVerifier{
+		ctx:  ctx,
+		dag:  merkledag.NewDAGService(blockservice.New(ext.blockStore, nil)),
+		root: f.cid,
+		path: relativePath,
+	}
+	if f.cid.Type() == cid.Raw {
+		size, err := f.Size()
+		if err != nil {
+			return nil, err
+		}
+		v.cur = &segment{cid: f.cid, size: size, raw: true}
+		return v, nil
+	}
+	if err := v.visit(f.cid); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Write checks p against the expected data, returning a
+// *ChecksumMismatchError as soon as a block differs.
+func (v *nodeVerifier) Write(p []byte) (int, error) {
+	if v.err != nil {
+		return 0, v.err
+	}
+	written := len(p)
+	for len(p) > 0 {
+		if v.cur == nil {
+			if err := v.next(); err != nil {
+				v.err = err
+				return 0, err
+			}
+			if v.cur == nil {
+				// More data than the node holds
+				v.err = v.mismatch(v.root, cid.Undef)
+				return 0, v.err
+			}
+		}
+		n := min(int64(len(p)), v.cur.size-int64(len(v.buf)))
+		v.buf = append(v.buf, p[:n]...)
+		p = p[n:]
+		if int64(len(v.buf)) == v.cur.size {
+			if err := v.check(); err != nil {
+				v.err = err
+				return 0, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// finish reports whether the data ended where the node ends. Data ending
+// early is reported as a mismatch of the block it ends in, with no actual CID.
+func (v *nodeVerifier) finish() error {
+	if v.err != nil {
+		return v.err
+	}
+	for {
+		if v.cur == nil {
+			if err := v.next(); err != nil {
+				return err
+			}
+			if v.cur == nil {
+				return nil
+			}
+		}
+		// Only empty segments may remain
+		if int64(len(v.buf)) < v.cur.size {
+			return v.mismatch(v.cur.cid, cid.Undef)
+		}
+		if err := v.check(); err != nil {
+			return err
+		}
+	}
+}
+
+// next moves to the next segment, loading blocks as needed. cur is nil when
+// every segment was checked.
+func (v *nodeVerifier) next() error {
+	v.cur = nil
+	v.buf = v.buf[:0]
+	for v.cur == nil && len(v.stack) > 0 {
+		top := &v.stack[len(v.stack)-1]
+		if len(top.links) == 0 {
+			v.stack = v.stack[:len(v.stack)-1]
+			continue
+		}
+		link, size := top.links[0], top.sizes[0]
+		top.links, top.sizes = top.links[1:], top.sizes[1:]
+
+		if link.Cid.Type() == cid.Raw {
+			v.cur = &segment{cid: link.Cid, size: int64(size), raw: true}
+			continue
+		}
+		if err := v.visit(link.Cid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// visit loads the dag-pb block c, checks it against its CID and pushes its
+// children. Data held by the block itself becomes the current segment.
+func (v *nodeVerifier) visit(c cid.Cid) error {
+	nd, err := v.dag.Get(v.ctx, c)
+	if err != nil {
+		return err
+	}
+	if actual, err := c.Prefix().Sum(nd.RawData()); err != nil {
+		return err
+	} else if !actual.Equals(c) {
+		return v.mismatch(c, actual)
+	}
+
+	pn, ok := nd.(*merkledag.ProtoNode)
+	if !ok {
+		return fmt.Errorf("block %s: %w", c, merkledag.ErrNotProtobuf)
+	}
+	fsn, err := unixfs.FSNodeFromBytes(pn.Data())
+	if err != nil {
+		return fmt.Errorf("block %s: %w", c, err)
+	}
+	if fsn.NumChildren() != len(pn.Links()) {
+		return fmt.Errorf("block %s: %d block sizes for %d links", c, fsn.NumChildren(), len(pn.Links()))
+	}
+
+	if len(pn.Links()) > 0 {
+		sizes := make([]uint64, fsn.NumChildren())
+		for i := range sizes {
+			sizes[i] = fsn.BlockSize(i)
+		}
+		v.stack = append(v.stack, frame{links: pn.Links(), sizes: sizes})
+	}
+	if data := fsn.Data(); len(data) > 0 {
+		v.cur = &segment{cid: c, size: int64(len(data)), data: data, fsn: fsn}
+	}
+	return nil
+}
+
+// check compares the buffered data with the current segment.
+func (v *nodeVerifier) check() error {
+	seg := v.cur
+	if seg.raw {
+		actual, err := seg.cid.Prefix().Sum(v.buf)
+		if err != nil {
+			return err
+		}
+		if !actual.Equals(seg.cid) {
+			return v.mismatch(seg.cid, actual)
+		}
+	} else if !bytes.Equal(v.buf, seg.data) {
+		return v.mismatch(seg.cid, rebuiltCid(seg, v.buf))
+	}
+	v.cur = nil
+	v.buf = v.buf[:0]
+	return nil
+}
+
+// rebuiltCid returns the CID the block of seg would have with data in place
+// of its own, or cid.Undef if it cannot be rebuilt.
+func rebuiltCid(seg *segment, data []byte) cid.Cid {
+	seg.fsn.SetData(append([]byte(nil), data...))
+	b, err := seg.fsn.GetBytes()
+	if err != nil {
+		return cid.Undef
+	}
+	nd := merkledag.NodeWithData(b)
+	if err := nd.SetCidBuilder(seg.cid.Prefix()); err != nil {
+		return cid.Undef
+	}
+	return nd.Cid()
+}
+
+// mismatch returns the error for a block whose data hashes to actual instead
+// of expected. An undefined actual CID stands for data that ended early, or
+// ran past the end of the file with expected set to the file's root.
+func (v *nodeVerifier) mismatch(expected, actual cid.Cid) error {
+	return &ChecksumMismatchError{
+		Path:     v.path,
+		Expected: cidString(expected),
+		Actual:   cidString(actual),
+		Hash:     "cid",
+	}
+}
+
+// cidString returns c as a string, or "none" if it is undefined.
+func cidString(c cid.Cid) string {
+	if !c.Defined() {
+		return "none"
+	}
+	return c.String()
+}
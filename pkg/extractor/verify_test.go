@@ -0,0 +1,99 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExtractor_WithVerify(t *testing.T) {
+	result, corrupt, tree := corruptTree(t)
+	intact := corrupt.(*corruptingBlockstore).Blockstore
+
+	out := filepath.Join(t.TempDir(), "out")
+	if err := NewExtractor(intact, result.RootCid, out).WithVerify(true).Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if got := readTree(t, out); !reflect.DeepEqual(got, tree) {
+		t.Errorf("extracted %v, want %v", got, tree)
+	}
+}
+
+func TestExtractor_WithVerify_CorruptBlock(t *testing.T) {
+	result, corrupt, _ := corruptTree(t)
+	leaves := descendants(t, corrupt.(*corruptingBlockstore).Blockstore, result.RootCid, "bad.bin")
+
+	out := filepath.Join(t.TempDir(), "out")
+	err := NewExtractor(corrupt, result.RootCid, out).WithVerify(true).Extract(context.Background(), false)
+
+	var mismatch *ChecksumMismatchError
+	if !errors.As(err, &mismatch) || !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Extract() error = %v, want a *ChecksumMismatchError", err)
+	}
+	if mismatch.Path != "bad.bin" || mismatch.Hash != "cid" || mismatch.Expected != leaves[1].String() || mismatch.Actual == mismatch.Expected {
+		t.Errorf("mismatch = %+v, want block %s", mismatch, leaves[1])
+	}
+	for _, name := range []string{"bad.bin", "bad.bin" + partFileSuffix} {
+		if _, err := os.Lstat(filepath.Join(out, name)); !os.IsNotExist(err) {
+			t.Errorf("%s exists after a verification failure: %v", name, err)
+		}
+	}
+}
+
+func TestExtractor_WithVerify_SkipRejected(t *testing.T) {
+	result, corrupt, tree := corruptTree(t)
+
+	out := filepath.Join(t.TempDir(), "out")
+	ext := NewExtractor(corrupt, result.RootCid, out).WithVerify(true).WithSkipRejected(true)
+	if err := ext.Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	delete(tree, "bad.bin")
+	if got := readTree(t, out); !reflect.DeepEqual(got, tree) {
+		t.Errorf("extracted %v, want %v", got, tree)
+	}
+	if rejected := ext.Rejected(); len(rejected) != 1 || rejected[0].Path != "bad.bin" {
+		t.Errorf("Rejected() = %+v, want bad.bin", rejected)
+	}
+}
+
+func TestExtractor_WithVerifyExisting(t *testing.T) {
+	result, corrupt, tree := corruptTree(t)
+	bs := corrupt.(*corruptingBlockstore).Blockstore
+
+	out := filepath.Join(t.TempDir(), "out")
+	if err := NewExtractor(bs, result.RootCid, out).Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	// Change a byte in the middle without changing the size
+	damaged := append([]byte(nil), tree["bad.bin"]...)
+	damaged[len(damaged)/2] ^= 0xff
+	if err := os.WriteFile(filepath.Join(out, "bad.bin"), damaged, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The size shortcut trusts the damaged file
+	ext := NewExtractor(bs, result.RootCid, out).WithVerify(true)
+	if err := ext.Extract(context.Background(), true); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if s := ext.Summary(); s.Replaced != 0 || s.Skipped != 3 {
+		t.Errorf("Summary() = %+v, want every file skipped", s)
+	}
+
+	ext = NewExtractor(bs, result.RootCid, out).WithVerify(true).WithVerifyExisting(true)
+	if err := ext.Extract(context.Background(), true); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if s := ext.Summary(); s.Replaced != 1 || s.Skipped != 2 {
+		t.Errorf("Summary() = %+v, want bad.bin replaced", s)
+	}
+	if got := readTree(t, out); !reflect.DeepEqual(got, tree) {
+		t.Errorf("extracted %v, want %v", got, tree)
+	}
+}
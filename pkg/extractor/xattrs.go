@@ -0,0 +1,119 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/ipfs/boxo/files"
+	unixfile "github.com/ipfs/boxo/ipld/unixfs/file"
+	uio "github.com/ipfs/boxo/ipld/unixfs/io"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/tragoedia0722/repository/pkg/xattr"
+)
+
+// XattrFailure is an extended attribute that could not be re-applied, for
+// example one in the trusted.* namespace when not running as root.
+type XattrFailure struct {
+	Path string // Path relative to the extraction root
+	Name string // Attribute name
+	Err  error  // Error returned when setting the attribute
+}
+
+// WithXattrs enables re-applying the extended attributes recorded by an
+// import with the importer's WithXattrs. Attributes are set after each entry
+// has been written; failures are reported by XattrFailures instead of
+// failing the extraction. Attributes are only applied when extracting to the
+// filesystem. Returns the extractor instance for method chaining.
+func (ext *Extractor) WithXattrs(enabled bool) *Extractor {
+	ext.withXattr = enabled
+	return ext
+}
+
+// XattrFailures returns the attributes that could not be re-applied during
+// the last extraction.
+func (ext *Extractor) XattrFailures() []XattrFailure {
+	return ext.xattrFail
+}
+
+// isXattrMetadata reports whether the entry name in the directory at
+// relativePath is the metadata file, which is never extracted.
+func isXattrMetadata(relativePath, name string) bool {
+	return relativePath == "" && name == xattr.ReservedName
+}
+
+// loadXattrs reads the metadata file of the root directory. It returns nil
+// if the root is not a directory or was imported without attributes.
+func loadXattrs(ctx context.Context, ds ipld.DAGService, rootCid string) (xattr.Metadata, error) {
+	c, err := cid.Parse(rootCid)
+	if err != nil {
+		return nil, err
+	}
+	root, err := ds.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := uio.NewDirectoryFromNode(ds, root)
+	if errors.Is(err, uio.ErrNotADir) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	nd, err := dir.Find(ctx, xattr.ReservedName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := unixfile.NewUnixfsFile(ctx, ds, nd)
+	if err != nil {
+		return nil, err
+	}
+	defer node.Close()
+
+	file, ok := node.(files.File)
+	if !ok {
+		return nil, wrapUnsupportedFileType(xattr.ReservedName, node)
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return xattr.Decode(data)
+}
+
+// applyXattrs sets the recorded attributes of the entry at relativePath,
+// written to path, and records the attributes that could not be set.
+func (ext *Extractor) applyXattrs(path, relativePath string) {
+	attrs := ext.xattrMeta[ext.rootPath(relativePath)]
+	if len(attrs) == 0 {
+		return
+	}
+	if _, ok := ext.destination().(*fsDestination); !ok {
+		return
+	}
+
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := xattr.Set(path, name, attrs[name]); err != nil {
+			ext.xattrFail = append(ext.xattrFail, XattrFailure{
+				Path: relativePath,
+				Name: name,
+				Err:  err,
+			})
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tragoedia0722/repository/pkg/importer"
+	"github.com/tragoedia0722/repository/pkg/xattr"
+)
+
+func TestExtractor_WithXattrs_RoundTrip(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, rel := range []string{"a.txt", "sub/b.txt"} {
+		if err := os.WriteFile(filepath.Join(src, rel), []byte(rel), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	value := []byte("preserved")
+	if err := xattr.Set(filepath.Join(src, "sub", "b.txt"), "user.test", value); err != nil {
+		t.Skipf("user xattrs not supported here: %v", err)
+	}
+	if err := xattr.Set(filepath.Join(src, "sub"), "user.dir", value); err != nil {
+		t.Fatalf("failed to set directory xattr: %v", err)
+	}
+
+	res, err := importer.NewImporter(bs, src).WithXattrs(true).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	out := filepath.Join(t.TempDir(), "out")
+	ext := NewExtractor(bs, res.RootCid, out).WithXattrs(true)
+	if err := ext.Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	for _, f := range ext.XattrFailures() {
+		// Attributes added by the system, such as SELinux labels, may not be settable
+		if strings.HasPrefix(f.Name, "user.") {
+			t.Errorf("failed to re-apply %s on %s: %v", f.Name, f.Path, f.Err)
+		}
+	}
+
+	for path, name := range map[string]string{
+		filepath.Join(out, "sub", "b.txt"): "user.test",
+		filepath.Join(out, "sub"):          "user.dir",
+	} {
+		attrs, err := xattr.List(path)
+		if err != nil {
+			t.Fatalf("List(%s) failed: %v", path, err)
+		}
+		if !bytes.Equal(attrs[name], value) {
+			t.Errorf("%s: %s = %q, want %q", path, name, attrs[name], value)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(out, xattr.ReservedName)); !os.IsNotExist(err) {
+		t.Errorf("metadata file was extracted: %v", err)
+	}
+
+	// Without the option the metadata stays hidden and nothing is applied
+	plain := filepath.Join(t.TempDir(), "plain")
+	if err := NewExtractor(bs, res.RootCid, plain).Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract without xattrs failed: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(plain, xattr.ReservedName)); !os.IsNotExist(err) {
+		t.Errorf("metadata file was extracted without the option: %v", err)
+	}
+	if attrs, _ := xattr.List(filepath.Join(plain, "sub", "b.txt")); len(attrs["user.test"]) != 0 {
+		t.Errorf("attributes applied without the option: %v", attrs)
+	}
+}
+
+func TestExtractor_WithXattrs_ReportsFailures(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	src := t.TempDir()
+	file := filepath.Join(src, "a.txt")
+	if err := os.WriteFile(file, []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := xattr.Set(file, "user.test", []byte("v")); err != nil {
+		t.Skipf("user xattrs not supported here: %v", err)
+	}
+
+	res, err := importer.NewImporter(bs, src).WithXattrs(true).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	// Attributes of a namespace that cannot be set must not fail the extraction
+	ext := NewExtractor(bs, res.RootCid, filepath.Join(t.TempDir(), "out")).WithXattrs(true)
+	if err := ext.Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	ext.xattrFail = nil
+	ext.xattrMeta = xattr.Metadata{"a.txt": {"invalid.namespace": []byte("v")}}
+	ext.applyXattrs(filepath.Join(ext.path, "a.txt"), "a.txt")
+
+	failures := ext.XattrFailures()
+	if len(failures) != 1 || failures[0].Path != "a.txt" || failures[0].Name != "invalid.namespace" || failures[0].Err == nil {
+		t.Errorf("XattrFailures() = %+v, want one failure for a.txt", failures)
+	}
+}
@@ -0,0 +1,80 @@
+package extractor
+
+import (
+	"context"
+	"runtime"
+	"time"
+
+	"github.com/tragoedia0722/repository/pkg/clock"
+)
+
+// yieldFunc is called at every scheduling point with the clock and the sleep
+// configured by WithYieldSleep.
+type yieldFunc func(clk clock.Clock, sleep time.Duration)
+
+// yieldNow gives up the processor at a scheduling point. A positive sleep
+// pauses the goroutine on clk instead of only yielding.
+func yieldNow(clk clock.Clock, sleep time.Duration) {
+	if sleep > 0 {
+		clock.Sleep(clk, sleep)
+		return
+	}
+	runtime.Gosched()
+}
+
+// yielder inserts scheduling points and context checks into tight read loops
+// at least once per interval of clock time.
+type yielder struct {
+	clock clock.Clock
+	every time.Duration
+	sleep time.Duration
+	last  time.Time
+	yield yieldFunc
+}
+
+// newYielder returns a yielder reading time from clk and calling yield at
+// every scheduling point, or nil when every is not positive. A nil yield
+// means yieldNow.
+func newYielder(clk clock.Clock, every, sleep time.Duration, yield yieldFunc) *yielder {
+	if every <= 0 {
+		return nil
+	}
+	if yield == nil {
+		yield = yieldNow
+	}
+	return &yielder{clock: clk, every: every, sleep: sleep, last: clk.Now(), yield: yield}
+}
+
+// maybeYield yields and checks ctx if the interval has elapsed since the last
+// scheduling point. It is a no-op on a nil yielder.
+func (y *yielder) maybeYield(ctx context.Context) error {
+	if y == nil {
+		return nil
+	}
+
+	if y.clock.Since(y.last) < y.every {
+		return nil
+	}
+
+	y.yield(y.clock, y.sleep)
+	y.last = y.clock.Now()
+	return ctx.Err()
+}
+
+// WithYield makes extraction yield the processor (runtime.Gosched) and check
+// for cancellation at least every interval of wall time while writing file
+// data, so hosts running the extraction next to latency-sensitive goroutines
+// stay responsive. Zero disables yielding, which is the default.
+// Returns the extractor instance for method chaining.
+func (ext *Extractor) WithYield(every time.Duration) *Extractor {
+	ext.yieldEvery = every
+	return ext
+}
+
+// WithYieldSleep makes every scheduling point configured by WithYield sleep
+// for d instead of only calling runtime.Gosched.
+// Returns the extractor instance for method chaining.
+func (ext *Extractor) WithYieldSleep(d time.Duration) *Extractor {
+	ext.yieldSleep = d
+	return ext
+}
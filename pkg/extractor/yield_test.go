@@ -0,0 +1,114 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/tragoedia0722/repository/pkg/clock"
+	"github.com/tragoedia0722/repository/pkg/clock/clocktest"
+	"github.com/tragoedia0722/repository/pkg/importer"
+)
+
+// countYields makes ext count its scheduling points into count.
+// If onYield is non-nil it is called at every scheduling point.
+func countYields(ext *Extractor, count *int, onYield func()) *Extractor {
+	ext.onYield = func(clk clock.Clock, sleep time.Duration) {
+		*count++
+		if onYield != nil {
+			onYield()
+		}
+		yieldNow(clk, sleep)
+	}
+	return ext
+}
+
+// importLargeFile imports a single file of size bytes and returns the root CID.
+func importLargeFile(t *testing.T, bs blockstore.Blockstore, size int) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "large.bin")
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 31)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	result, err := importer.NewImporter(bs, path).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	return result.RootCid
+}
+
+func TestExtractor_WithYield_SchedulingPoints(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	root := importLargeFile(t, bs, 16<<20)
+
+	var count int
+	if err := countYields(NewExtractor(bs, root, t.TempDir()), &count, nil).Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("expected no scheduling points by default, got %d", count)
+	}
+
+	// Time only passes when progress is reported, so every scheduling point
+	// follows a progress update.
+	every := time.Millisecond
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	updates := 0
+	ext := countYields(NewExtractor(bs, root, t.TempDir()), &count, nil).WithClock(clk).WithYield(every).
+		WithProgress(func(_, _ int64, _ string) {
+			updates++
+			clk.Advance(every)
+		})
+	if err := ext.Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	if count == 0 || count > updates {
+		t.Errorf("got %d scheduling points for %d intervals", count, updates)
+	}
+}
+
+func TestExtractor_WithYield_Cancellation(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	root := importLargeFile(t, bs, 16<<20)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var cancelled time.Time
+	var count int
+	ext := countYields(NewExtractor(bs, root, t.TempDir()), &count, func() {
+		if cancelled.IsZero() {
+			cancelled = time.Now()
+			cancel()
+		}
+	})
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	ext = ext.WithClock(clk).WithYield(time.Millisecond).
+		WithProgress(func(_, _ int64, _ string) { clk.Advance(time.Millisecond) })
+	err := ext.Extract(ctx, false)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if cancelled.IsZero() {
+		t.Fatal("expected a scheduling point before the extraction finished")
+	}
+	if latency := time.Since(cancelled); latency > 100*time.Millisecond {
+		t.Errorf("extraction returned %v after cancellation", latency)
+	}
+}
@@ -9,7 +9,11 @@
 //   - 移除控制字符和 Unicode 控制码
 //   - 标准化空格字符（合并连续空格）
 //   - 处理 Windows 保留的设备名（CON, PRN, AUX, NUL, COM1-9, LPT1-9）
-//   - 截断过长的文件名（Windows 限制为 255 字符）
+//   - 截断过长的文件名（默认限制为 255 字节）
+//   - 可选：CleanFilenameFor 按目标的 Profile 限制长度，以字节、UTF-16 码元或码点计算
+//   - 可选：CleanFilenameWithOptions 指定字节长度上限和替换字符，并可合并连续的替换
+//   - 可选：CleanFilenameASCII 将文件名转写为纯 ASCII，供只接受 ASCII 的系统使用
+//   - 可选：ShortenForDisplay 在字素簇边界缩短文件名用于显示，ToDOS83 生成 DOS 8.3 短文件名
 //
 // 基本用法：
 //
@@ -37,9 +41,21 @@
 //   - 正确处理 Unicode 文件名
 //   - 符合 Windows 文件系统要求
 //
+// 数据兼容性：
+//
+// 扩展名的判断统一由 SplitExt 完成后，有两类文件名的清理结果与之前不同：
+//   - 主文件名为保留名、扩展名为复合扩展名的文件名现在也会被改名，
+//     如 "CON.tar.gz" 变为 "CON_file.tar.gz"（之前保持不变）
+//   - 超长文件名截断时保留完整的复合扩展名，如按 18 字节截断
+//     "verylongfilename.tar.gz" 得到 "verylongfil.tar.gz"（之前为 "verylongfilenam.gz"）
+//
+// 导入器用清理后的名称构建 DAG，因此包含这类文件名的目录重新导入后
+// RootCid 会改变。已导入的数据不受影响，仍可按原 CID 导出；但按 RootCid
+// 比较新旧导入结果（如去重或增量同步）时，这些目录会被视为不同的内容。
+//
 // Windows 文件名限制：
 //
-//   - 最大长度: 255 字符
+//   - 最大长度: 255 个 UTF-16 码元（WindowsProfile；CleanFilename 保守地限制为 255 字节）
 //   - 不能包含: <, >, :, ", /, \, |, ?, *, 等
 //   - 不能是保留设备名（不分大小写）: CON, PRN, AUX, NUL, COM1-9, LPT1-9
 //   - 不能以空格或点结尾
@@ -0,0 +1,143 @@
+package helper
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// graphemes 将 s 切分为用户感知的字符（扩展字素簇）
+//
+// 按 UAX #29 的主要规则切分，不依赖完整的 Unicode 属性表：
+//   - CR LF 作为一个整体，其他控制字符单独成簇
+//   - 组合符号、变体选择符、emoji 肤色修饰符、标签字符和 ZWJ 附着在前一个字符上
+//   - ZWJ 之后的 emoji 与前面的 emoji 组成一个整体（如 👨‍👩‍👧）
+//   - 区域指示符两两组成国旗
+//   - 韩文字母按音节组合
+//
+// 无效的 UTF-8 字节各自单独成簇。
+func graphemes(s string) []string {
+	var clusters []string
+
+	start := 0
+	var prev rune = -1
+	regional := 0 // 当前簇末尾连续的区域指示符个数
+
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if i > start && graphemeBreak(prev, r, regional) {
+			clusters = append(clusters, s[start:i])
+			start = i
+			regional = 0
+		}
+
+		if isRegionalIndicator(r) {
+			regional++
+		} else {
+			regional = 0
+		}
+		if r == utf8.RuneError && size == 1 {
+			r = -1 // 无效字节不与后面的字符组合
+		}
+		prev = r
+		i += size
+	}
+
+	if start < len(s) {
+		clusters = append(clusters, s[start:])
+	}
+	return clusters
+}
+
+// graphemeBreak 判断 prev 和 r 之间是否是字素簇边界
+func graphemeBreak(prev, r rune, regional int) bool {
+	switch {
+	case prev == '\r' && r == '\n':
+		return false
+	case prev < 0 || isControl(prev) || isControl(r):
+		return true
+	case hangulJoins(prev, r):
+		return false
+	case isExtend(r) || unicode.Is(unicode.Mc, r):
+		return false
+	case prev == runeZeroWidthJoiner && isPictographic(r):
+		return false
+	case isRegionalIndicator(r) && regional%2 == 1:
+		return false
+	}
+	return true
+}
+
+// isControl 判断 r 是否是字素簇规则中的控制字符
+func isControl(r rune) bool {
+	return r < 0x20 || (r >= 0x7F && r <= 0x9F) || r == runeLineSeparator || r == runeParagraphSeparator
+}
+
+// isExtend 判断 r 是否附着在前一个字符上
+func isExtend(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me) ||
+		r == runeZeroWidthJoiner || r == runeZeroWidthNonJoiner ||
+		(r >= 0x1F3FB && r <= 0x1F3FF) || // emoji 肤色修饰符
+		(r >= 0xE0020 && r <= 0xE007F) // 标签字符（如英格兰旗）
+}
+
+// isRegionalIndicator 判断 r 是否是区域指示符
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// isPictographic 判断 r 是否是可以由 ZWJ 连接的 emoji
+func isPictographic(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF && !isRegionalIndicator(r) && !(r >= 0x1F3FB && r <= 0x1F3FF):
+		return true
+	case r >= 0x2300 && r <= 0x23FF, r >= 0x2600 && r <= 0x27BF, r >= 0x2B00 && r <= 0x2BFF:
+		return true
+	case r == 0x00A9, r == 0x00AE, r == 0x203C, r == 0x2049, r == 0x2122, r == 0x2139,
+		r >= 0x2194 && r <= 0x21AA, r == 0x3030, r == 0x303D, r == 0x3297, r == 0x3299:
+		return true
+	}
+	return false
+}
+
+// 韩文字母的组合类别
+const (
+	hangulNone = iota
+	hangulL    // 初声
+	hangulV    // 中声
+	hangulT    // 终声
+	hangulLV   // 无终声的音节
+	hangulLVT  // 有终声的音节
+)
+
+// hangulClass 返回 r 的韩文组合类别
+func hangulClass(r rune) int {
+	switch {
+	case r >= 0x1100 && r <= 0x115F, r >= 0xA960 && r <= 0xA97C:
+		return hangulL
+	case r >= 0x1160 && r <= 0x11A7, r >= 0xD7B0 && r <= 0xD7C6:
+		return hangulV
+	case r >= 0x11A8 && r <= 0x11FF, r >= 0xD7CB && r <= 0xD7FB:
+		return hangulT
+	case r >= 0xAC00 && r <= 0xD7A3:
+		if (r-0xAC00)%28 == 0 {
+			return hangulLV
+		}
+		return hangulLVT
+	}
+	return hangulNone
+}
+
+// hangulJoins 判断 prev 和 r 是否按韩文音节规则组合在一起
+func hangulJoins(prev, r rune) bool {
+	p, c := hangulClass(prev), hangulClass(r)
+	switch {
+	case p == hangulNone || c == hangulNone:
+		return false
+	case p == hangulL:
+		return c != hangulT
+	case p == hangulLV || p == hangulV:
+		return c == hangulV || c == hangulT
+	default:
+		return c == hangulT
+	}
+}
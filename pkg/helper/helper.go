@@ -1,18 +1,16 @@
 package helper
 
-import (
-	"strings"
-	"unicode/utf8"
-)
+import "unicode/utf8"
 
 // CleanFilename 清理文件名，使其适合在 Windows 文件系统中使用
 //
 // 本函数执行以下操作：
-//  1. 移除无效字符 (<, >, :, ", /, \, |, ?, *, 等)
-//  2. 移除控制字符和 Unicode 控制码
-//  3. 标准化空格字符（合并连续空格）
-//  4. 处理 Windows 保留的设备名（CON, PRN, AUX, 等）
-//  5. 截断过长的文件名（255 字符）
+//  1. 把无效的 UTF-8 字节替换为 U+FFFD，结果始终是有效的 UTF-8
+//  2. 移除无效字符 (<, >, :, ", /, \, |, ?, *, 等)
+//  3. 移除控制字符和 Unicode 控制码
+//  4. 标准化空格字符（合并连续空格）
+//  5. 处理 Windows 保留的设备名（CON, PRN, AUX, 等）
+//  6. 截断过长的文件名（255 字节，其他限制见 CleanFilenameFor）
 //
 // 参数：
 //
@@ -29,36 +27,14 @@ import (
 //	CleanFilename("测试文件.txt")        // "测试文件.txt"
 //	CleanFilename("file   name.txt")    // "file name.txt"
 //	CleanFilename("")                    // "unnamed_file"
+//
+// 需要其他长度上限或替换字符时使用 CleanFilenameWithOptions。
 func CleanFilename(filename string) string {
-	if filename == "" {
-		return DefaultFilename
-	}
-
-	// 步骤 1: 清理字符（移除和替换）
-	cleaned := cleanChars(filename)
-
-	// 步骤 2: 标准化空格（合并连续空格，修剪首尾）
-	cleaned = normalizeSpaces(cleaned)
-
-	// 步骤 3: 修剪尾部空格和点（第二次修剪，确保干净）
-	cleaned = strings.TrimRight(cleaned, ". ")
-
-	// 步骤 4: 处理 Windows 保留名
-	cleaned = HandleReservedNames(cleaned)
-
-	// 步骤 5: 截断过长的文件名
-	cleaned = TruncateFilename(cleaned, MaxFilenameLength)
-
-	// 最终检查：如果结果为空，返回默认文件名
-	if cleaned == "" {
-		return DefaultFilename
-	}
-
-	return cleaned
+	return CleanFilenameWithOptions(filename, DefaultOptions())
 }
 
 // TruncateFilename 截断文件名到指定最大长度
-// 如果文件名有扩展名，会尝试保留扩展名，只截断主文件名部分
+// 如果文件名有扩展名（由 SplitExt 决定），会尝试保留扩展名，只截断主文件名部分
 //
 // 参数：
 //
@@ -78,6 +54,7 @@ func CleanFilename(filename string) string {
 // 示例：
 //
 //	TruncateFilename("verylongfilename.txt", 15)  // "verylongfil.txt"
+//	TruncateFilename("longarchive.tar.gz", 14)    // "longarc.tar.gz"
 //	TruncateFilename("文件名称.txt", 8)            // "文件.txt" (不破坏 UTF-8)
 //	TruncateFilename("normal.txt", 255)           // "normal.txt"
 func TruncateFilename(filename string, maxLength int) string {
@@ -86,19 +63,15 @@ func TruncateFilename(filename string, maxLength int) string {
 		return filename
 	}
 
-	// 查找最后一个点（扩展名分隔符）
-	dotIndex := strings.LastIndex(filename, ".")
+	// 分离主文件名和扩展名（包含点）
+	name, ext := SplitExt(filename)
 
-	// 没有点，点在开头，或点在末尾
-	if dotIndex <= 0 || dotIndex == len(filename)-1 {
+	// 没有扩展名
+	if ext == "" {
 		// 安全截断整个文件名
 		return safeTruncate(filename, maxLength)
 	}
 
-	// 分离主文件名和扩展名
-	ext := filename[dotIndex:]  // 包含点的扩展名
-	name := filename[:dotIndex] // 主文件名
-
 	// 计算主文件名可用长度
 	minNameLength := 1
 	maxNameLength := maxLength - len(ext)
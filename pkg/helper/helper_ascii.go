@@ -0,0 +1,190 @@
+package helper
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+// asciiHashLength 是转写后追加的哈希后缀的十六进制位数
+const asciiHashLength = 8
+
+// 带变音符号的拉丁字母，按基本字母分组（只列小写，大写通过 unicode.ToLower 查找）
+var latinFolds = map[string]string{
+	"a": "àáâãäåāăą",
+	"c": "çćĉċč",
+	"d": "ďđð",
+	"e": "èéêëēĕėęě",
+	"g": "ĝğġģ",
+	"h": "ĥħ",
+	"i": "ìíîïĩīĭįı",
+	"j": "ĵ",
+	"k": "ķĸ",
+	"l": "ĺļľŀł",
+	"n": "ñńņňŉŋ",
+	"o": "òóôõöøōŏő",
+	"r": "ŕŗř",
+	"s": "śŝşšſ",
+	"t": "ţťŧ",
+	"u": "ùúûüũūŭůűų",
+	"w": "ŵ",
+	"y": "ýÿŷ",
+	"z": "źżž",
+}
+
+// asciiTable 是小写字符到 ASCII 的转写表，在 init 中补充 latinFolds
+var asciiTable = map[rune]string{
+	// 拉丁连字和特殊字母
+	'æ': "ae", 'œ': "oe", 'ß': "ss", 'þ': "th", 'ĳ': "ij",
+
+	// 西里尔字母（俄语，兼顾乌克兰语和白俄罗斯语），按 BGN/PCGN 罗马化
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	'і': "i", 'ї': "yi", 'є': "ye", 'ґ': "g", 'ў': "u",
+
+	// 希腊字母，按 ELOT 743 简化
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i",
+	'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x",
+	'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y",
+	'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+	'ά': "a", 'έ': "e", 'ή': "i", 'ί': "i", 'ό': "o", 'ύ': "y", 'ώ': "o",
+	'ϊ': "i", 'ϋ': "y", 'ΐ': "i", 'ΰ': "y",
+
+	// 常见标点
+	'‐': "-", '‑': "-", '‒': "-", '–': "-", '—': "-", '―': "-",
+	'‘': "'", '’': "'", '‚': "'", '′': "'", '“': "'", '”': "'", '„': "'", '″': "'",
+	'…': "...",
+}
+
+func init() {
+	for base, letters := range latinFolds {
+		for _, r := range letters {
+			asciiTable[r] = base
+		}
+	}
+}
+
+// CleanFilenameASCII 将文件名转写为 ASCII 近似形式，再按 CleanFilename 的规则清理
+//
+// 用于只接受 ASCII 文件名的下游系统。转写规则：
+//   - 带变音符号的拉丁字母去掉变音符号（café → cafe），连字展开（æ → ae）
+//   - 西里尔字母按 BGN/PCGN、希腊字母按 ELOT 743 罗马化
+//   - 全角字母、数字和符号转换为对应的 ASCII 字符
+//   - Unicode 空格转换为空格，常见破折号和引号转换为 - 和 '
+//   - emoji、其他符号和标点直接丢弃
+//   - 其他文字（中日韩文字、阿拉伯文等）无法转写，丢弃后在主文件名末尾追加
+//     "_" 和原始文件名的 8 位十六进制 FNV-1a 哈希，使不同的原始文件名不会
+//     变成同一个结果；本包不内置拼音等大型转写表
+//
+// 主文件名转写后为空时（例如全是中文或 emoji），使用哈希作为主文件名，
+// 因此结果永远不会是 DefaultFilename，除非输入为空。
+// 结果确定，只依赖输入，不超过 MaxFilenameLength，截断时保留哈希后缀和扩展名。
+//
+// 参数：
+//
+//	filename - 要清理的文件名
+//
+// 返回：
+//
+//	只包含 ASCII 字符的文件名，如果输入为空返回 "unnamed_file"
+//
+// 示例：
+//
+//	CleanFilenameASCII("café.txt")         // "cafe.txt"
+//	CleanFilenameASCII("Привет мир.doc")   // "Privet mir.doc"
+//	CleanFilenameASCII("Ελληνικά.pdf")     // "Ellinika.pdf"
+//	CleanFilenameASCII("photo 😀.jpg")     // "photo.jpg"
+//	CleanFilenameASCII("报告 2024.pdf")     // "2024_xxxxxxxx.pdf"（x 为哈希）
+//	CleanFilenameASCII("报告.pdf")          // "xxxxxxxx.pdf"
+func CleanFilenameASCII(filename string) string {
+	if filename == "" {
+		return DefaultFilename
+	}
+
+	stem, ext := SplitExt(filename)
+	asciiStem, lossyStem := transliterate(stem)
+	asciiExt, lossyExt := transliterate(ext)
+
+	// 扩展名的字符全部被丢弃时不保留单独的点
+	ext = cleanChars(asciiExt)
+	if ext == "." {
+		ext = ""
+	}
+
+	hash := filenameHash(filename)
+	suffix := ""
+	stem = normalizeSpaces(cleanChars(asciiStem))
+	if strings.Trim(stem, ".") == "" {
+		stem = hash
+	} else if lossyStem || lossyExt {
+		suffix = "_" + hash
+	}
+
+	// 截断主文件名，为哈希后缀和扩展名留出空间
+	if budget := MaxFilenameLength - len(suffix) - len(ext); budget > 0 {
+		stem = safeTruncate(stem, budget)
+	}
+
+	cleaned := TruncateFilename(HandleReservedNames(stem+suffix+ext), MaxFilenameLength)
+	if cleaned == "" {
+		return DefaultFilename
+	}
+	return cleaned
+}
+
+// transliterate 将 s 转写为 ASCII，返回结果以及是否丢弃了无法转写的字母或数字
+func transliterate(s string) (string, bool) {
+	var builder strings.Builder
+	builder.Grow(len(s))
+
+	lossy := false
+	for _, r := range s {
+		if r < 0x80 {
+			builder.WriteRune(r)
+			continue
+		}
+
+		// 全角 ASCII 字符
+		if r >= 0xFF01 && r <= 0xFF5E {
+			builder.WriteRune(r - 0xFEE0)
+			continue
+		}
+
+		if unicode.IsSpace(r) {
+			builder.WriteByte(' ')
+			continue
+		}
+
+		lower := unicode.ToLower(r)
+		if lower < 0x80 {
+			builder.WriteRune(unicode.ToUpper(lower)) // 例如土耳其语的 İ
+			continue
+		}
+
+		if ascii, ok := asciiTable[lower]; ok {
+			if lower != r && ascii != "" {
+				ascii = strings.ToUpper(ascii[:1]) + ascii[1:]
+			}
+			builder.WriteString(ascii)
+			continue
+		}
+
+		// 组合变音符号、emoji、其他符号和标点直接丢弃
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			lossy = true
+		}
+	}
+
+	return builder.String(), lossy
+}
+
+// filenameHash 返回文件名的 FNV-1a 哈希（8 位十六进制）
+func filenameHash(filename string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(filename))
+	return fmt.Sprintf("%0*x", asciiHashLength, h.Sum32())
+}
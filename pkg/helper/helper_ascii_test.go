@@ -0,0 +1,141 @@
+package helper
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// isASCII reports whether s only contains ASCII characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCleanFilenameASCII(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "ascii unchanged", input: "report.txt", expected: "report.txt"},
+		{name: "empty", input: "", expected: DefaultFilename},
+		{name: "latin diacritics", input: "café.txt", expected: "cafe.txt"},
+		{name: "latin uppercase diacritics", input: "ÉCOLE Ñandú.pdf", expected: "ECOLE Nandu.pdf"},
+		{name: "latin ligatures", input: "Æsir straße œuvre.doc", expected: "Aesir strasse oeuvre.doc"},
+		{name: "polish", input: "Łódź żółć.txt", expected: "Lodz zolc.txt"},
+		{name: "combining marks", input: "café.txt", expected: "cafe.txt"},
+		{name: "turkish dotted capital", input: "İstanbul.txt", expected: "Istanbul.txt"},
+		{name: "russian", input: "Привет мир.doc", expected: "Privet mir.doc"},
+		{name: "russian digraphs", input: "Щука и ёж.txt", expected: "Shchuka i yozh.txt"},
+		{name: "ukrainian letters", input: "Їжак і єнот.txt", expected: "Yizhak i yenot.txt"},
+		{name: "greek", input: "Ελληνικά.pdf", expected: "Ellinika.pdf"},
+		{name: "greek final sigma", input: "λόγος.txt", expected: "logos.txt"},
+		{name: "fullwidth", input: "ＡＢＣ１２３.txt", expected: "ABC123.txt"},
+		{name: "fullwidth invalid char", input: "a＜b.txt", expected: "a_b.txt"},
+		{name: "unicode spaces", input: "a　b c.txt", expected: "a b c.txt"},
+		{name: "dashes and quotes", input: "draft – “final”.txt", expected: "draft - 'final'.txt"},
+		{name: "emoji dropped", input: "photo 😀.jpg", expected: "photo.jpg"},
+		{name: "emoji sequence dropped", input: "team 👨‍👩‍👧 trip.jpg", expected: "team trip.jpg"},
+		{name: "reserved name", input: "CON.txt", expected: "CON_file.txt"},
+		{name: "transliterated reserved name", input: "ПРН.txt", expected: "PRN_file.txt"},
+		{name: "invalid characters", input: "a<b>:ü.txt", expected: "a_b__u.txt"},
+		{name: "compound extension", input: "архив.tar.gz", expected: "arkhiv.tar.gz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := CleanFilenameASCII(tt.input)
+			if result != tt.expected {
+				t.Errorf("CleanFilenameASCII(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCleanFilenameASCII_HashSuffix(t *testing.T) {
+	hashOnly := regexp.MustCompile(`^[0-9a-f]{8}(\.[a-z]+)?$`)
+	withSuffix := regexp.MustCompile(`_[0-9a-f]{8}(\.[a-z]+)?$`)
+
+	tests := []struct {
+		name    string
+		input   string
+		prefix  string
+		pattern *regexp.Regexp
+	}{
+		{name: "chinese only", input: "报告.pdf", pattern: hashOnly},
+		{name: "japanese only", input: "ファイル.txt", pattern: hashOnly},
+		{name: "korean only", input: "문서", pattern: hashOnly},
+		{name: "emoji only", input: "😀.jpg", pattern: hashOnly},
+		{name: "only dots after transliteration", input: "。。。", pattern: hashOnly},
+		{name: "chinese with digits", input: "报告 2024.pdf", prefix: "2024_", pattern: withSuffix},
+		{name: "mixed latin and cjk", input: "Résumé 简历.doc", prefix: "Resume_", pattern: withSuffix},
+		{name: "arabic", input: "ملف data.txt", prefix: "data_", pattern: withSuffix},
+		{name: "untransliterable extension", input: "report.文档", prefix: "report_", pattern: withSuffix},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := CleanFilenameASCII(tt.input)
+			if !isASCII(result) {
+				t.Errorf("CleanFilenameASCII(%q) = %q, contains non-ASCII characters", tt.input, result)
+			}
+			if !tt.pattern.MatchString(result) || !strings.HasPrefix(result, tt.prefix) {
+				t.Errorf("CleanFilenameASCII(%q) = %q, want prefix %q matching %s", tt.input, result, tt.prefix, tt.pattern)
+			}
+			if again := CleanFilenameASCII(tt.input); again != result {
+				t.Errorf("CleanFilenameASCII(%q) is not deterministic: %q and %q", tt.input, result, again)
+			}
+		})
+	}
+}
+
+func TestCleanFilenameASCII_Uniqueness(t *testing.T) {
+	names := []string{
+		"报告.pdf", "报表.pdf", "总结.pdf", "项目 报告.pdf", "项目 报表.pdf",
+		"会议记录 2024.txt", "会议纪要 2024.txt", "資料.txt", "资料.txt",
+	}
+
+	seen := make(map[string]string)
+	for _, name := range names {
+		result := CleanFilenameASCII(name)
+		if other, ok := seen[result]; ok {
+			t.Errorf("%q and %q both became %q", other, name, result)
+		}
+		seen[result] = name
+	}
+}
+
+func TestCleanFilenameASCII_Length(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "long cyrillic", input: strings.Repeat("щ", 200) + ".txt"},
+		{name: "long latin with cjk", input: strings.Repeat("é", 300) + "文.txt"},
+		{name: "long cjk", input: strings.Repeat("文", 300) + ".txt"},
+		{name: "long extension", input: "文件." + strings.Repeat("a", 300)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := CleanFilenameASCII(tt.input)
+			if len(result) > MaxFilenameLength {
+				t.Errorf("result length %d exceeds %d", len(result), MaxFilenameLength)
+			}
+			if result == "" || result == DefaultFilename || !isASCII(result) {
+				t.Errorf("CleanFilenameASCII() = %q", result)
+			}
+		})
+	}
+
+	// Truncation keeps the hash suffix and the extension
+	result := CleanFilenameASCII(strings.Repeat("é", 300) + "文.txt")
+	if !regexp.MustCompile(`^e+_[0-9a-f]{8}\.txt$`).MatchString(result) {
+		t.Errorf("truncated result %q lost its hash suffix or extension", result)
+	}
+}
@@ -4,9 +4,15 @@ import (
 	"strings"
 )
 
-// cleanChars 清理文件名中的字符
-// 它移除无效字符，替换特殊字符，并使用 strings.Builder 优化性能
+// cleanChars 清理文件名中的字符，无效字符替换为下划线
 func cleanChars(filename string) string {
+	return cleanCharsWith(filename, '_', false)
+}
+
+// cleanCharsWith 清理文件名中的字符
+// 它移除无效字符，把无效字符替换为 replacement，并使用 strings.Builder 优化性能
+// collapse 为 true 时，连续的无效字符只替换为一个 replacement
+func cleanCharsWith(filename string, replacement rune, collapse bool) string {
 	// 预分配空间，避免多次扩容
 	// 使用 75% 的原始长度作为估算，因为很多字符会被移除或替换
 	var builder strings.Builder
@@ -19,6 +25,7 @@ func cleanChars(filename string) string {
 	// 状态机跟踪
 	lastWasSpace := false
 	pendingSpace := false
+	lastWasReplaced := false
 
 	for _, r := range filename {
 		action := classifyCharacter(r)
@@ -34,16 +41,21 @@ func cleanChars(filename string) string {
 				pendingSpace = true
 			}
 			lastWasSpace = true
+			lastWasReplaced = false
 			continue
 
 		case actionReplaceWithUnderscore:
-			// 替换为下划线
+			// 替换为 replacement，需要时合并连续的替换
+			if collapse && lastWasReplaced && !pendingSpace {
+				continue
+			}
 			if pendingSpace {
 				builder.WriteByte(' ')
 				pendingSpace = false
 			}
 			lastWasSpace = false
-			builder.WriteByte('_')
+			lastWasReplaced = true
+			builder.WriteRune(replacement)
 			continue
 
 		case actionKeep:
@@ -53,6 +65,7 @@ func cleanChars(filename string) string {
 				pendingSpace = false
 			}
 			lastWasSpace = false
+			lastWasReplaced = false
 			builder.WriteRune(r)
 		}
 	}
@@ -219,7 +219,7 @@ func TestReservedNamesEdgeCases(t *testing.T) {
 		{
 			name:     "CON with multiple extensions",
 			input:    "CON.tar.gz",
-			expected: "CON.tar.gz", // .tar.gz 被认为是一个扩展名，CON.tar 不是保留名
+			expected: "CON_file.tar.gz", // .tar.gz 是一个复合扩展名，主文件名 CON 是保留名；有意变更，之前为 "CON.tar.gz"，见 doc.go 的数据兼容性说明
 		},
 		{
 			name:     "PRN with very long extension",
@@ -848,6 +848,13 @@ func TestFilenameProperties(t *testing.T) {
 		"file\x00\x01\x02name.txt",
 		"   filename.txt   ",
 		"filename.txt...",
+		// 无效的 UTF-8
+		"caf\xe9.txt",
+		"\xff\xfe",
+		"a\x80\x81b.txt",
+		"\xc3",
+		strings.Repeat("\xe9", 300) + ".txt",
+		"\xed\xa0\x80.txt",
 	}
 
 	for _, input := range testInputs {
@@ -0,0 +1,135 @@
+package helper
+
+import (
+	"strings"
+)
+
+// 复合扩展名 - 作为一个整体处理（不区分大小写）
+var compoundExtensions = []string{
+	".tar.gz", ".tar.bz2", ".tar.xz", ".tar.zst", ".tar.lz", ".tar.lz4", ".tar.lzma", ".tar.z",
+}
+
+// SplitExt 将文件名分离为主文件名和扩展名
+//
+// 这是本包判断扩展名的统一规则，CleanFilename、TruncateFilename 和
+// HandleReservedNames 都使用它：
+//   - 扩展名从最后一个点开始，包含点本身
+//   - 常见复合扩展名（如 .tar.gz）作为一个整体
+//   - 开头的点属于主文件名，隐藏文件（如 ".gitignore"）没有扩展名
+//   - 以点结尾的文件名没有扩展名
+//   - 主文件名不能为空；若复合扩展名会占满整个文件名，退回到最后一个点
+//
+// 参数：
+//
+//	name - 文件名（不含目录）
+//
+// 返回：
+//
+//	stem - 主文件名
+//	ext - 扩展名（包含点），没有扩展名时为空
+//
+// 示例：
+//
+//	SplitExt("file.txt")        // ("file", ".txt")
+//	SplitExt("archive.tar.gz")  // ("archive", ".tar.gz")
+//	SplitExt(".gitignore")      // (".gitignore", "")
+//	SplitExt(".config.json")    // (".config", ".json")
+//	SplitExt(".tar.gz")         // (".tar", ".gz")
+//	SplitExt("file.")           // ("file.", "")
+func SplitExt(name string) (stem, ext string) {
+	// 开头的点属于主文件名
+	start := len(name) - len(strings.TrimLeft(name, "."))
+
+	dotIndex := strings.LastIndex(name, ".")
+
+	// 没有点，点都在开头，或点在末尾
+	if dotIndex < start || dotIndex >= len(name)-1 {
+		return name, ""
+	}
+
+	lower := strings.ToLower(name)
+	for _, compound := range compoundExtensions {
+		idx := len(name) - len(compound)
+		if idx > start && strings.HasSuffix(lower, compound) {
+			return name[:idx], name[idx:]
+		}
+	}
+
+	return name[:dotIndex], name[dotIndex:]
+}
+
+// ReplaceExt 替换文件名的扩展名
+//
+// 扩展名的范围由 SplitExt 决定，newExt 可以带或不带开头的点，
+// 为空时移除扩展名。结果会经过 CleanFilename 处理，
+// 因此同样满足字符、保留名和长度限制。
+//
+// 参数：
+//
+//	name - 原文件名
+//	newExt - 新扩展名
+//
+// 返回：
+//
+//	替换扩展名并清理后的文件名
+//
+// 示例：
+//
+//	ReplaceExt("report.txt", ".bak")      // "report.bak"
+//	ReplaceExt("archive.tar.gz", "zip")   // "archive.zip"
+//	ReplaceExt(".gitignore", ".bak")      // ".gitignore.bak"
+//	ReplaceExt("CON.txt", ".log")         // "CON_file.log"
+func ReplaceExt(name, newExt string) string {
+	stem, _ := SplitExt(name)
+	if stem == "" {
+		stem = DefaultFilename
+	}
+
+	newExt = strings.TrimLeft(newExt, ".")
+	if newExt == "" {
+		return CleanFilename(stem)
+	}
+
+	return CleanFilename(stem + "." + newExt)
+}
+
+// HasExt 检查文件名是否具有给定扩展名之一（不区分大小写）
+//
+// 扩展名可以带或不带开头的点。对于复合扩展名，完整扩展名和
+// 最后一段都可以匹配，例如 "a.tar.gz" 同时匹配 ".tar.gz" 和 ".gz"。
+//
+// 参数：
+//
+//	name - 文件名
+//	exts - 要匹配的扩展名
+//
+// 返回：
+//
+//	如果匹配任意一个扩展名返回 true
+//
+// 示例：
+//
+//	HasExt("photo.JPG", ".jpg", ".png")   // true
+//	HasExt("archive.tar.gz", "gz")        // true
+//	HasExt(".gitignore", ".gitignore")    // false
+func HasExt(name string, exts ...string) bool {
+	_, ext := SplitExt(name)
+	if ext == "" {
+		return false
+	}
+
+	ext = strings.ToLower(ext)
+	last := ext[strings.LastIndex(ext, "."):]
+
+	for _, e := range exts {
+		e = "." + strings.ToLower(strings.TrimLeft(e, "."))
+		if e == "." {
+			continue
+		}
+		if e == ext || e == last {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,131 @@
+package helper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitExt(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		expectedStem string
+		expectedExt  string
+	}{
+		{name: "simple extension", input: "file.txt", expectedStem: "file", expectedExt: ".txt"},
+		{name: "no extension", input: "README", expectedStem: "README", expectedExt: ""},
+		{name: "empty", input: "", expectedStem: "", expectedExt: ""},
+		{name: "multiple dots keeps last extension", input: "file.name.test.txt", expectedStem: "file.name.test", expectedExt: ".txt"},
+		{name: "compound extension", input: "archive.tar.gz", expectedStem: "archive", expectedExt: ".tar.gz"},
+		{name: "compound extension is case-insensitive", input: "Backup.TAR.BZ2", expectedStem: "Backup", expectedExt: ".TAR.BZ2"},
+		{name: "reserved name with compound extension", input: "CON.tar.gz", expectedStem: "CON", expectedExt: ".tar.gz"},
+		{name: "reserved name with extension", input: "CON.txt", expectedStem: "CON", expectedExt: ".txt"},
+		{name: "tar alone is not compound", input: "file.tar", expectedStem: "file", expectedExt: ".tar"},
+		{name: "hidden file has no extension", input: ".gitignore", expectedStem: ".gitignore", expectedExt: ""},
+		{name: "hidden file with extension", input: ".config.json", expectedStem: ".config", expectedExt: ".json"},
+		{name: "leading dots belong to stem", input: "..hidden.txt", expectedStem: "..hidden", expectedExt: ".txt"},
+		{name: "all-extension name falls back to last dot", input: ".tar.gz", expectedStem: ".tar", expectedExt: ".gz"},
+		{name: "trailing dot has no extension", input: "test.", expectedStem: "test.", expectedExt: ""},
+		{name: "only dots", input: "...", expectedStem: "...", expectedExt: ""},
+		{name: "long extension", input: "PRN." + strings.Repeat("a", 100), expectedStem: "PRN", expectedExt: "." + strings.Repeat("a", 100)},
+		{name: "unicode", input: "测试文件.txt", expectedStem: "测试文件", expectedExt: ".txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stem, ext := SplitExt(tt.input)
+			if stem != tt.expectedStem || ext != tt.expectedExt {
+				t.Errorf("SplitExt(%q) = (%q, %q), want (%q, %q)", tt.input, stem, ext, tt.expectedStem, tt.expectedExt)
+			}
+			if stem+ext != tt.input {
+				t.Errorf("SplitExt(%q) does not reassemble: %q + %q", tt.input, stem, ext)
+			}
+		})
+	}
+}
+
+func TestReplaceExt(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		newExt   string
+		expected string
+	}{
+		{name: "replace simple extension", input: "report.txt", newExt: ".bak", expected: "report.bak"},
+		{name: "extension without dot", input: "report.txt", newExt: "bak", expected: "report.bak"},
+		{name: "replace compound extension", input: "archive.tar.gz", newExt: "zip", expected: "archive.zip"},
+		{name: "add extension", input: "README", newExt: ".md", expected: "README.md"},
+		{name: "remove extension", input: "report.txt", newExt: "", expected: "report"},
+		{name: "hidden file gains extension", input: ".gitignore", newExt: ".bak", expected: ".gitignore.bak"},
+		{name: "reserved name is handled", input: "CON.txt", newExt: ".log", expected: "CON_file.log"},
+		{name: "removing extension exposes reserved name", input: "nul.txt", newExt: "", expected: "nul_file"},
+		{name: "invalid characters are cleaned", input: "file.txt", newExt: ".b<a>k", expected: "file.b_a_k"},
+		{name: "empty input", input: "", newExt: ".txt", expected: "unnamed_file.txt"},
+		{
+			name:     "result is truncated preserving extension",
+			input:    strings.Repeat("a", 250) + ".txt",
+			newExt:   ".backup",
+			expected: strings.Repeat("a", MaxFilenameLength-len(".backup")) + ".backup",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ReplaceExt(tt.input, tt.newExt)
+			if result != tt.expected {
+				t.Errorf("ReplaceExt(%q, %q) = %q, want %q", tt.input, tt.newExt, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHasExt(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		exts     []string
+		expected bool
+	}{
+		{name: "matches with dot", input: "photo.jpg", exts: []string{".jpg"}, expected: true},
+		{name: "matches without dot", input: "photo.jpg", exts: []string{"jpg"}, expected: true},
+		{name: "case-insensitive", input: "photo.JPG", exts: []string{".png", ".jpg"}, expected: true},
+		{name: "no match", input: "photo.jpg", exts: []string{".png"}, expected: false},
+		{name: "no extensions given", input: "photo.jpg", exts: nil, expected: false},
+		{name: "empty extension ignored", input: "photo", exts: []string{""}, expected: false},
+		{name: "compound full match", input: "a.tar.gz", exts: []string{".tar.gz"}, expected: true},
+		{name: "compound last component", input: "a.tar.gz", exts: []string{"gz"}, expected: true},
+		{name: "compound first component does not match", input: "a.tar.gz", exts: []string{".tar"}, expected: false},
+		{name: "hidden file has no extension", input: ".gitignore", exts: []string{".gitignore"}, expected: false},
+		{name: "trailing dot has no extension", input: "file.", exts: []string{"."}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := HasExt(tt.input, tt.exts...)
+			if result != tt.expected {
+				t.Errorf("HasExt(%q, %v) = %v, want %v", tt.input, tt.exts, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestExtensionConsistency 确保清理、截断和保留名处理对扩展名的判断与 SplitExt 一致
+func TestExtensionConsistency(t *testing.T) {
+	names := []string{"CON.txt", "CON.tar.gz", "aux.TAR.XZ", ".config.json", "file.name.txt", "PRN."}
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			_, ext := SplitExt(name)
+
+			if reserved := HandleReservedNames(name); !strings.HasSuffix(reserved, ext) {
+				t.Errorf("HandleReservedNames(%q) = %q lost extension %q", name, reserved, ext)
+			}
+
+			long := strings.Repeat("x", 300) + name
+			truncated := TruncateFilename(long, MaxFilenameLength)
+			if _, gotExt := SplitExt(truncated); gotExt != ext {
+				t.Errorf("TruncateFilename kept extension %q, want %q", gotExt, ext)
+			}
+		})
+	}
+}
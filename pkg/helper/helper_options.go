@@ -0,0 +1,89 @@
+package helper
+
+import (
+	"errors"
+	"unicode/utf8"
+)
+
+// Options 配置 CleanFilenameWithOptions 的长度上限和替换字符
+//
+// 零值字段使用默认值：MaxLength 为 MaxFilenameLength 字节，Replacement 为 '_'。
+type Options struct {
+	MaxLength            int  // UTF-8 字节数上限，0 表示 MaxFilenameLength
+	Replacement          rune // 替换无效字符的字符，0 表示 '_'
+	CollapseReplacements bool // 连续的无效字符只替换为一个 Replacement
+}
+
+// DefaultOptions 返回 CleanFilename 使用的选项
+//
+// 每次调用返回一个新的副本，修改它不影响 CleanFilename 和其他调用者。
+func DefaultOptions() Options {
+	return Options{MaxLength: MaxFilenameLength, Replacement: '_'}
+}
+
+// ErrInvalidOptions 表示 Options 的长度上限为负数，或替换字符本身不能出现在文件名中
+var ErrInvalidOptions = errors.New("helper: options must set a non-negative MaxLength and a Replacement valid in filenames")
+
+// Validate 检查选项是否有效
+//
+// Replacement 必须是 CleanFilename 会原样保留的字符，并且不能是空格或点
+// （它们在文件名末尾会被修剪）。'/'、'\\'、控制字符、零宽字符和无效的码点
+// 都会被拒绝。
+//
+// 返回：
+//
+//	如果选项无效，返回 ErrInvalidOptions
+func (o Options) Validate() error {
+	if o.MaxLength < 0 {
+		return ErrInvalidOptions
+	}
+	if o.Replacement != 0 && !validReplacement(o.Replacement) {
+		return ErrInvalidOptions
+	}
+	return nil
+}
+
+// validReplacement 判断 r 能否作为替换字符。
+func validReplacement(r rune) bool {
+	if !utf8.ValidRune(r) || r == utf8.RuneError || r == ' ' || r == '.' {
+		return false
+	}
+	return classifyCharacter(r) == actionKeep
+}
+
+// orDefault 返回填充默认值后的 o，无效的选项替换为 DefaultOptions()。
+func (o Options) orDefault() Options {
+	if o.Validate() != nil {
+		return DefaultOptions()
+	}
+	if o.MaxLength == 0 {
+		o.MaxLength = MaxFilenameLength
+	}
+	if o.Replacement == 0 {
+		o.Replacement = '_'
+	}
+	return o
+}
+
+// CleanFilenameWithOptions 按 CleanFilename 的规则清理文件名，长度上限和替换字符由 opts 指定
+//
+// 参数：
+//
+//	filename - 要清理的文件名
+//	opts - 清理选项，无效的选项（见 Validate）按 DefaultOptions() 处理
+//
+// 返回：
+//
+//	清理后的文件名，不超过 opts.MaxLength 字节，截断时尽量保留扩展名
+//
+// 示例：
+//
+//	opts := Options{MaxLength: 128, Replacement: '-'}
+//	CleanFilenameWithOptions("file<>name", opts) // "file--name"
+//
+//	opts.CollapseReplacements = true
+//	CleanFilenameWithOptions("file<>name", opts) // "file-name"
+func CleanFilenameWithOptions(filename string, opts Options) string {
+	opts = opts.orDefault()
+	return cleanFilename(filename, Profile{MaxBytes: opts.MaxLength}, opts.Replacement, opts.CollapseReplacements)
+}
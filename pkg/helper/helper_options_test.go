@@ -0,0 +1,106 @@
+package helper
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCleanFilenameWithOptions(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  Options
+		want  string
+	}{
+		{"default replacement", "file<>name", Options{}, "file__name"},
+		{"custom replacement", "file<>name", Options{Replacement: '-'}, "file--name"},
+		{"collapse", "file<>name", Options{Replacement: '-', CollapseReplacements: true}, "file-name"},
+		{"collapse keeps separated runs", "a<b>c", Options{Replacement: '-', CollapseReplacements: true}, "a-b-c"},
+		{"collapse across space", "a< >b", Options{Replacement: '-', CollapseReplacements: true}, "a- -b"},
+		{"multi-byte replacement", "a?b", Options{Replacement: '＿'}, "a＿b"},
+		{"reserved name", "CON.txt", Options{Replacement: '-'}, "CON_file.txt"},
+		{"max length keeps extension", strings.Repeat("a", 200) + ".txt", Options{MaxLength: 128}, strings.Repeat("a", 124) + ".txt"},
+		{"empty", "", Options{Replacement: '-'}, DefaultFilename},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CleanFilenameWithOptions(tt.input, tt.opts); got != tt.want {
+				t.Errorf("CleanFilenameWithOptions(%q, %+v) = %q, want %q", tt.input, tt.opts, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanFilenameWithOptions_MaxLength(t *testing.T) {
+	name := strings.Repeat("文", 100) + ".tar.gz"
+	got := CleanFilenameWithOptions(name, Options{MaxLength: 128})
+	if len(got) > 128 || !utf8.ValidString(got) || !strings.HasSuffix(got, ".tar.gz") {
+		t.Errorf("got %q (%d bytes), want at most 128 bytes ending in .tar.gz", got, len(got))
+	}
+}
+
+func TestCleanFilenameWithOptions_DefaultUnchanged(t *testing.T) {
+	inputs := []string{
+		strings.Repeat("文", 200) + ".txt",
+		"test<>:file.txt",
+		"CON.txt",
+		"a\x00b\u200bc",
+		"",
+	}
+	for _, in := range inputs {
+		if got, want := CleanFilenameWithOptions(in, Options{}), CleanFilenameFor(in, DefaultProfile); got != want {
+			t.Errorf("CleanFilenameWithOptions(%q, Options{}) = %q, CleanFilenameFor = %q", in, got, want)
+		}
+	}
+}
+
+func TestDefaultOptions_Copy(t *testing.T) {
+	opts := DefaultOptions()
+	opts.MaxLength = 4
+	opts.Replacement = '-'
+	if got := DefaultOptions(); got.MaxLength != MaxFilenameLength || got.Replacement != '_' {
+		t.Errorf("DefaultOptions() = %+v after modifying a copy", got)
+	}
+	if got := CleanFilename("file<name>.txt"); got != "file_name_.txt" {
+		t.Errorf("CleanFilename = %q after modifying a copy of the defaults", got)
+	}
+}
+
+func TestOptions_Validate(t *testing.T) {
+	valid := []Options{
+		{},
+		DefaultOptions(),
+		{MaxLength: 128, Replacement: '-'},
+		{Replacement: '＿'},
+	}
+	for _, opts := range valid {
+		if err := opts.Validate(); err != nil {
+			t.Errorf("%+v: unexpected error %v", opts, err)
+		}
+	}
+
+	invalid := []Options{
+		{MaxLength: -1},
+		{Replacement: '/'},
+		{Replacement: '\\'},
+		{Replacement: '*'},
+		{Replacement: '\n'},
+		{Replacement: ' '},
+		{Replacement: '.'},
+		{Replacement: '\u200b'},
+		{Replacement: utf8.RuneError},
+		{Replacement: 0xD800},
+	}
+	for _, opts := range invalid {
+		if err := opts.Validate(); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("%+v: error = %v, want ErrInvalidOptions", opts, err)
+		}
+		// Invalid options fall back to the defaults
+		if got := CleanFilenameWithOptions("a/b", opts); got != "a_b" {
+			t.Errorf("%+v: CleanFilenameWithOptions = %q, want a_b", opts, got)
+		}
+	}
+}
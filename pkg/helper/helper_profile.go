@@ -0,0 +1,208 @@
+package helper
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// Profile 描述目标文件系统对文件名长度的限制
+//
+// 不同目标计算长度的方式不同：NTFS 限制为 255 个 UTF-16 码元（255 个中文字符
+// 可以使用，尽管它们占 765 个 UTF-8 字节），ext4 限制为 255 字节，一些对象存储
+// 允许 1024 字节。MaxBytes、MaxUTF16Units、MaxRunes 必须恰好设置一个。
+type Profile struct {
+	Name          string // 配置名称，仅用于显示
+	MaxBytes      int    // UTF-8 字节数上限
+	MaxUTF16Units int    // UTF-16 码元数上限，BMP 之外的字符计为 2
+	MaxRunes      int    // Unicode 码点数上限
+}
+
+var (
+	// DefaultProfile 是 CleanFilename 使用的配置，限制为 MaxFilenameLength 字节
+	DefaultProfile = Profile{Name: "default", MaxBytes: MaxFilenameLength}
+
+	// WindowsProfile 对应 NTFS，限制为 255 个 UTF-16 码元
+	WindowsProfile = Profile{Name: "windows", MaxUTF16Units: 255}
+
+	// POSIXProfile 对应 ext4 等文件系统，限制为 255 字节
+	POSIXProfile = Profile{Name: "posix", MaxBytes: 255}
+
+	// ObjectStoreProfile 对应允许长键名的对象存储，限制为 1024 字节
+	ObjectStoreProfile = Profile{Name: "object-store", MaxBytes: 1024}
+)
+
+// ErrInvalidProfile 表示 Profile 没有恰好设置一个长度限制
+var ErrInvalidProfile = errors.New("helper: profile must set exactly one of MaxBytes, MaxUTF16Units and MaxRunes")
+
+// Validate 检查配置是否恰好设置了一个正的长度限制
+//
+// 返回：
+//
+//	如果配置无效，返回 ErrInvalidProfile
+func (p Profile) Validate() error {
+	set := 0
+	for _, limit := range []int{p.MaxBytes, p.MaxUTF16Units, p.MaxRunes} {
+		if limit < 0 {
+			return ErrInvalidProfile
+		}
+		if limit > 0 {
+			set++
+		}
+	}
+	if set != 1 {
+		return ErrInvalidProfile
+	}
+	return nil
+}
+
+// Length 按配置的度量计算文件名的长度
+//
+// 参数：
+//
+//	name - 文件名
+//
+// 返回：
+//
+//	字节数、UTF-16 码元数或码点数，取决于配置设置的限制
+func (p Profile) Length(name string) int {
+	p = p.orDefault()
+	switch {
+	case p.MaxUTF16Units > 0:
+		return utf16Len(name)
+	case p.MaxRunes > 0:
+		return utf8.RuneCountInString(name)
+	default:
+		return len(name)
+	}
+}
+
+// orDefault 返回 p，无效的配置替换为 DefaultProfile。
+func (p Profile) orDefault() Profile {
+	if p.Validate() != nil {
+		return DefaultProfile
+	}
+	return p
+}
+
+// limit 返回配置设置的长度上限。
+func (p Profile) limit() int {
+	return p.MaxBytes + p.MaxUTF16Units + p.MaxRunes
+}
+
+// CleanFilenameFor 按 CleanFilename 的规则清理文件名，并按配置的度量截断
+//
+// 参数：
+//
+//	filename - 要清理的文件名
+//	p - 目标文件系统的配置，无效的配置（见 Validate）按 DefaultProfile 处理
+//
+// 返回：
+//
+//	清理后的文件名，始终是有效的 UTF-8，截断时尽量保留扩展名
+//
+// 示例：
+//
+//	name := strings.Repeat("文", 200) + ".txt"
+//	CleanFilenameFor(name, WindowsProfile) // 不截断：204 个 UTF-16 码元
+//	CleanFilenameFor(name, POSIXProfile)   // 截断为 83 个汉字加 ".txt"，共 253 字节
+func CleanFilenameFor(filename string, p Profile) string {
+	return cleanFilename(filename, p, '_', false)
+}
+
+// cleanFilename 是 CleanFilenameFor 和 CleanFilenameWithOptions 共用的清理流程，
+// 无效字符替换为 replacement，collapse 为 true 时合并连续的替换。
+func cleanFilename(filename string, p Profile, replacement rune, collapse bool) string {
+	if filename == "" {
+		return DefaultFilename
+	}
+
+	// 步骤 1: 替换无效的 UTF-8 字节
+	if !utf8.ValidString(filename) {
+		filename = strings.ToValidUTF8(filename, string(utf8.RuneError))
+	}
+
+	// 步骤 2: 清理字符（移除和替换）
+	cleaned := cleanCharsWith(filename, replacement, collapse)
+
+	// 步骤 3: 标准化空格（合并连续空格，修剪首尾）
+	cleaned = normalizeSpaces(cleaned)
+
+	// 步骤 4: 修剪尾部空格和点（第二次修剪，确保干净）
+	cleaned = strings.TrimRight(cleaned, ". ")
+
+	// 步骤 5: 处理 Windows 保留名
+	cleaned = HandleReservedNames(cleaned)
+
+	// 步骤 6: 按配置的度量截断过长的文件名
+	cleaned = TruncateFilenameFor(cleaned, p)
+
+	// 最终检查：如果结果为空，返回默认文件名
+	if cleaned == "" {
+		return DefaultFilename
+	}
+	return cleaned
+}
+
+// TruncateFilenameFor 按配置的度量截断文件名，规则与 TruncateFilename 相同
+//
+// 参数：
+//
+//	filename - 要截断的文件名
+//	p - 目标文件系统的配置，无效的配置（见 Validate）按 DefaultProfile 处理
+//
+// 返回：
+//
+//	截断后的文件名，如果不超过配置的限制则原样返回
+//
+// 截断只发生在字符边界，结果始终是有效的 UTF-8。
+func TruncateFilenameFor(filename string, p Profile) string {
+	p = p.orDefault()
+	if p.MaxBytes > 0 {
+		return TruncateFilename(filename, p.MaxBytes)
+	}
+
+	maxLength := p.limit()
+	if p.Length(filename) <= maxLength {
+		return filename
+	}
+
+	name, ext := SplitExt(filename)
+	if ext == "" {
+		return truncateUnits(filename, maxLength, p)
+	}
+
+	maxNameLength := maxLength - p.Length(ext)
+	if maxNameLength < 1 {
+		return truncateUnits(filename, maxLength, p)
+	}
+	return truncateUnits(name, maxNameLength, p) + ext
+}
+
+// truncateUnits 返回 s 不超过 maxLen 个度量单位的最长前缀，只在字符边界截断。
+func truncateUnits(s string, maxLen int, p Profile) string {
+	used := 0
+	for i, r := range s {
+		n := 1
+		if p.MaxUTF16Units > 0 && r >= 0x10000 {
+			n = 2
+		}
+		if used+n > maxLen {
+			return s[:i]
+		}
+		used += n
+	}
+	return s
+}
+
+// utf16Len 返回 s 编码为 UTF-16 后的码元数。
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n++
+		if r >= 0x10000 {
+			n++
+		}
+	}
+	return n
+}
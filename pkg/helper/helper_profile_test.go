@@ -0,0 +1,142 @@
+package helper
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCleanFilenameFor_CJK(t *testing.T) {
+	name := strings.Repeat("文", 200) + ".txt"
+
+	windows := CleanFilenameFor(name, WindowsProfile)
+	if windows != name {
+		t.Errorf("windows profile truncated a %d-unit name to %d runes", utf16Len(name), utf8.RuneCountInString(windows))
+	}
+
+	posix := CleanFilenameFor(name, POSIXProfile)
+	if len(posix) > 255 {
+		t.Errorf("posix profile produced %d bytes", len(posix))
+	}
+	if posix == name {
+		t.Error("posix profile did not truncate a 604-byte name")
+	}
+
+	for profile, got := range map[string]string{"windows": windows, "posix": posix} {
+		if !utf8.ValidString(got) {
+			t.Errorf("%s: result is not valid UTF-8", profile)
+		}
+		if !strings.HasSuffix(got, ".txt") || !strings.HasPrefix(got, "文") {
+			t.Errorf("%s: extension or name lost: %q", profile, got)
+		}
+	}
+}
+
+func TestCleanFilenameFor_DefaultUnchanged(t *testing.T) {
+	inputs := []string{
+		strings.Repeat("文", 200) + ".txt",
+		strings.Repeat("a", 300) + ".tar.gz",
+		"test<>:file.txt",
+		"CON.txt",
+		"",
+	}
+	for _, in := range inputs {
+		if got, want := CleanFilenameFor(in, DefaultProfile), CleanFilename(in); got != want {
+			t.Errorf("CleanFilenameFor(%q, DefaultProfile) = %q, CleanFilename = %q", in, got, want)
+		}
+	}
+	if got := len(CleanFilename(strings.Repeat("文", 200))); got > MaxFilenameLength {
+		t.Errorf("CleanFilename produced %d bytes", got)
+	}
+}
+
+func TestTruncateFilenameFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		profile Profile
+		want    string
+	}{
+		{
+			name:    "utf16 counts astral runes twice",
+			input:   "😀😀😀.txt",
+			profile: Profile{MaxUTF16Units: 7},
+			want:    "😀.txt",
+		},
+		{
+			name:    "runes count astral runes once",
+			input:   "😀😀😀.txt",
+			profile: Profile{MaxRunes: 6},
+			want:    "😀😀.txt",
+		},
+		{
+			name:    "bytes match TruncateFilename",
+			input:   "文件名称.txt",
+			profile: Profile{MaxBytes: 8},
+			want:    "文.txt",
+		},
+		{
+			name:    "no extension",
+			input:   strings.Repeat("文", 10),
+			profile: Profile{MaxUTF16Units: 4},
+			want:    strings.Repeat("文", 4),
+		},
+		{
+			name:    "extension too long to keep",
+			input:   "a.longextension",
+			profile: Profile{MaxRunes: 5},
+			want:    "a.lon",
+		},
+		{
+			name:    "object store allows long names",
+			input:   strings.Repeat("a", 1000) + ".txt",
+			profile: ObjectStoreProfile,
+			want:    strings.Repeat("a", 1000) + ".txt",
+		},
+		{
+			name:    "invalid profile uses the default",
+			input:   strings.Repeat("a", 300),
+			profile: Profile{MaxBytes: 10, MaxRunes: 10},
+			want:    strings.Repeat("a", MaxFilenameLength),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TruncateFilenameFor(tt.input, tt.profile)
+			if got != tt.want {
+				t.Errorf("TruncateFilenameFor(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("result is not valid UTF-8: %q", got)
+			}
+		})
+	}
+}
+
+func TestProfile_Validate(t *testing.T) {
+	for _, p := range []Profile{DefaultProfile, WindowsProfile, POSIXProfile, ObjectStoreProfile, {MaxRunes: 1}} {
+		if err := p.Validate(); err != nil {
+			t.Errorf("%+v: %v", p, err)
+		}
+	}
+	for _, p := range []Profile{{}, {MaxBytes: 1, MaxRunes: 1}, {MaxBytes: -1, MaxRunes: 1}} {
+		if err := p.Validate(); !errors.Is(err, ErrInvalidProfile) {
+			t.Errorf("%+v: Validate returned %v, want ErrInvalidProfile", p, err)
+		}
+	}
+}
+
+func TestProfile_Length(t *testing.T) {
+	const name = "文😀a"
+	if got := WindowsProfile.Length(name); got != 4 {
+		t.Errorf("utf16 length = %d, want 4", got)
+	}
+	if got := POSIXProfile.Length(name); got != 8 {
+		t.Errorf("byte length = %d, want 8", got)
+	}
+	if got := (Profile{MaxRunes: 10}).Length(name); got != 3 {
+		t.Errorf("rune length = %d, want 3", got)
+	}
+}
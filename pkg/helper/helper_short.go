@@ -0,0 +1,214 @@
+package helper
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// displayEllipsis 是 ShortenForDisplay 插入的省略号
+const displayEllipsis = "…"
+
+// dosSymbols 是 8.3 文件名中允许的 ASCII 符号
+const dosSymbols = "!#$%&'()-@^_`{}~"
+
+// dosMaxSuffix 是 ToDOS83 尝试的最大 ~N 后缀
+const dosMaxSuffix = 999999
+
+// ShortenForDisplay 将文件名缩短到不超过 maxRunes 个字符，用于列表等显示场景
+//
+// 省略号 "…" 插入在中间，开头和结尾都保持可见，使只在结尾不同的文件名
+// （如 "report_v1.pdf" 和 "report_v2.pdf"）缩短后仍然可以区分：
+//   - 只在用户感知的字符（字素簇）边界截断，带修饰符或 ZWJ 的 emoji、
+//     组合变音符号和国旗不会被拆开
+//   - 扩展名（由 SplitExt 决定）放得下时完整保留，省略号插入在主文件名中间
+//   - 放不下扩展名时，省略号插入在整个文件名中间
+//   - 省略号计入 maxRunes；一个字素簇按其包含的 rune 数计算
+//
+// 结果确定，只依赖输入。
+//
+// 参数：
+//
+//	name - 文件名
+//	maxRunes - 结果的最大 rune 数
+//
+// 返回：
+//
+//	缩短后的文件名；不超过 maxRunes 时原样返回，maxRunes 不大于 0 时返回空字符串
+//
+// 示例：
+//
+//	ShortenForDisplay("quarterly_report_2024.pdf", 16)  // "quarte…_2024.pdf"
+//	ShortenForDisplay("photo.jpg", 20)                  // "photo.jpg"
+//	ShortenForDisplay("longname.extension", 8)          // "long…ion"
+func ShortenForDisplay(name string, maxRunes int) string {
+	if maxRunes <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(name) <= maxRunes {
+		return name
+	}
+
+	// 扩展名之外至少保留省略号和开头的一个字符
+	stem, ext := SplitExt(name)
+	if extRunes := utf8.RuneCountInString(ext); ext != "" && extRunes+2 <= maxRunes {
+		head, tail := cutMiddle(graphemes(stem), maxRunes-extRunes-1)
+		if head != "" {
+			return head + displayEllipsis + tail + ext
+		}
+	}
+
+	head, tail := cutMiddle(graphemes(name), maxRunes-1)
+	return head + displayEllipsis + tail
+}
+
+// cutMiddle 从 clusters 的开头和结尾各取若干字素簇，总 rune 数不超过 budget
+//
+// 开头优先获得一半（向上取整）的预算，剩余的预算分给结尾，
+// 结尾用不完的预算再分给开头。调用者保证 clusters 的总 rune 数超过 budget。
+func cutMiddle(clusters []string, budget int) (head, tail string) {
+	i, j := 0, len(clusters) // 开头取 clusters[:i]，结尾取 clusters[j:]
+	used := 0
+
+	take := func(limit int) {
+		for i < j {
+			n := utf8.RuneCountInString(clusters[i])
+			if used+n > limit {
+				return
+			}
+			used += n
+			i++
+		}
+	}
+
+	take((budget + 1) / 2)
+	for j > i {
+		n := utf8.RuneCountInString(clusters[j-1])
+		if used+n > budget {
+			break
+		}
+		used += n
+		j--
+	}
+	take(budget)
+
+	return strings.Join(clusters[:i], ""), strings.Join(clusters[j:], "")
+}
+
+// ToDOS83 生成 DOS 兼容的 8.3 短文件名
+//
+// 生成规则与 Windows 的短文件名相近：
+//   - 主文件名最多 8 个字符，扩展名（最后一个点之后）最多 3 个字符，全部大写
+//   - 只保留 ASCII 字母、数字和 !#$%&'()-@^_`{}~；带变音符号等可以转写的字母
+//     按 CleanFilenameASCII 的规则转写，其他字母和数字替换为 "_"，
+//     空格、开头的点和主文件名中的点被移除，其他字符替换为 "_"
+//   - 原文件名本身就是合法的 8.3 文件名（不区分大小写）时直接使用其大写形式
+//   - 否则，或者 exists 报告该名称已被占用时，截断主文件名并追加 "~1"、"~2"……
+//     直到 exists 返回 false；后缀变长时主文件名相应缩短，总长不超过 8 个字符
+//   - Windows 保留设备名（如 CON）总是带有 ~N 后缀
+//
+// exists 接收完整的候选名称（如 "REPORT~1.TXT"），为 nil 时视为没有名称被占用。
+// 对于相同的输入和 exists 的回答，结果是确定的。
+//
+// 参数：
+//
+//	name - 文件名（不含目录）
+//	exists - 报告候选名称是否已被占用
+//
+// 返回：
+//
+//	8.3 短文件名；所有 ~N 后缀都被占用时返回空字符串
+//
+// 示例：
+//
+//	ToDOS83("readme.txt", nil)               // "README.TXT"
+//	ToDOS83("Quarterly Report.docx", nil)    // "QUARTE~1.DOC"
+//	ToDOS83("café.txt", nil)                 // "CAFE~1.TXT"
+//	ToDOS83(".gitignore", nil)               // "GITIGN~1"
+func ToDOS83(name string, exists func(string) bool) string {
+	if exists == nil {
+		exists = func(string) bool { return false }
+	}
+
+	trimmed := strings.TrimLeft(name, ".")
+	base, ext := trimmed, ""
+	if i := strings.LastIndex(trimmed, "."); i >= 0 {
+		base, ext = trimmed[:i], trimmed[i+1:]
+	}
+
+	base, lossyBase := dosChars(base)
+	ext, lossyExt := dosChars(ext)
+	lossy := lossyBase || lossyExt || trimmed != name ||
+		base == "" || len(base) > 8 || len(ext) > 3 || isReservedName(base)
+
+	if base == "" {
+		base = "_"
+	}
+	if len(ext) > 3 {
+		ext = ext[:3]
+	}
+
+	if !lossy {
+		if candidate := dosName(base, ext); !exists(candidate) {
+			return candidate
+		}
+	}
+
+	for n := 1; n <= dosMaxSuffix; n++ {
+		suffix := "~" + strconv.Itoa(n)
+		stem := base
+		if len(stem) > 8-len(suffix) {
+			stem = stem[:8-len(suffix)]
+		}
+		if candidate := dosName(stem+suffix, ext); !exists(candidate) {
+			return candidate
+		}
+	}
+
+	return ""
+}
+
+// dosName 拼接 8.3 文件名的主文件名和扩展名
+func dosName(base, ext string) string {
+	if ext == "" {
+		return base
+	}
+	return base + "." + ext
+}
+
+// dosChars 将 s 转换为 8.3 文件名允许的大写字符，返回结果以及是否有字符被改变
+// （大小写的变化除外）
+func dosChars(s string) (string, bool) {
+	var builder strings.Builder
+	builder.Grow(len(s))
+
+	lossy := false
+	for _, r := range s {
+		if r >= 0x80 {
+			lossy = true
+			ascii, _ := transliterate(string(r))
+			if ascii == "" && (unicode.IsLetter(r) || unicode.IsNumber(r)) {
+				ascii = "_"
+			}
+			// 转写结果同样按 ASCII 规则处理
+			converted, _ := dosChars(ascii)
+			builder.WriteString(converted)
+			continue
+		}
+
+		switch {
+		case r >= 'a' && r <= 'z':
+			builder.WriteRune(unicode.ToUpper(r))
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', strings.ContainsRune(dosSymbols, r):
+			builder.WriteRune(r)
+		case r == ' ' || r == '.':
+			lossy = true
+		default:
+			lossy = true
+			builder.WriteByte('_')
+		}
+	}
+
+	return builder.String(), lossy
+}
@@ -0,0 +1,154 @@
+package helper
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestGraphemes(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{name: "ascii", input: "abc", want: []string{"a", "b", "c"}},
+		{name: "cjk", input: "文件名", want: []string{"文", "件", "名"}},
+		{name: "combining mark", input: "café!", want: []string{"c", "a", "f", "é", "!"}},
+		{name: "skin tone modifier", input: "a👍🏽b", want: []string{"a", "👍🏽", "b"}},
+		{name: "zwj family", input: "👨‍👩‍👧‍👦x", want: []string{"👨‍👩‍👧‍👦", "x"}},
+		{name: "zwj profession with modifier", input: "👩🏻‍💻", want: []string{"👩🏻‍💻"}},
+		{name: "variation selector", input: "❤️a", want: []string{"❤️", "a"}},
+		{name: "flags", input: "🇯🇵🇺🇸🇫", want: []string{"🇯🇵", "🇺🇸", "🇫"}},
+		{name: "tag sequence", input: "🏴󠁧󠁢󠁥󠁮󠁧󠁿!", want: []string{"🏴󠁧󠁢󠁥󠁮󠁧󠁿", "!"}},
+		{name: "hangul syllables", input: "한글", want: []string{"한", "글"}},
+		{name: "hangul jamo", input: "한그", want: []string{"한", "그"}},
+		{name: "crlf", input: "a\r\nb", want: []string{"a", "\r\n", "b"}},
+		{name: "invalid utf-8", input: "a\xff́", want: []string{"a", "\xff", "́"}},
+		{name: "empty", input: "", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := graphemes(tt.input)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+				t.Errorf("graphemes(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShortenForDisplay(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		maxRunes int
+		expected string
+	}{
+		{name: "fits", input: "photo.jpg", maxRunes: 20, expected: "photo.jpg"},
+		{name: "exact fit", input: "photo.jpg", maxRunes: 9, expected: "photo.jpg"},
+		{name: "keeps extension", input: "quarterly_report_2024.pdf", maxRunes: 16, expected: "quarte…_2024.pdf"},
+		{name: "distinguishes endings", input: "quarterly_report_2025.pdf", maxRunes: 16, expected: "quarte…_2025.pdf"},
+		{name: "compound extension", input: "backup_of_everything.tar.gz", maxRunes: 15, expected: "back…ing.tar.gz"},
+		{name: "extension too long", input: "longname.extension", maxRunes: 8, expected: "long…ion"},
+		{name: "no extension", input: "abcdefghijklmnop", maxRunes: 7, expected: "abc…nop"},
+		{name: "cjk", input: "这是一个非常长的中文文件名.txt", maxRunes: 10, expected: "这是一…件名.txt"},
+		{name: "zwj emoji not split", input: "👨‍👩‍👧‍👦👨‍👩‍👧‍👦👨‍👩‍👧‍👦.png", maxRunes: 14, expected: "👨‍👩‍👧‍👦…" + ".png"},
+		{name: "emoji with modifier not split", input: "a👍🏽b👍🏽c👍🏽d.txt", maxRunes: 10, expected: "a👍🏽b…d.txt"},
+		{name: "combining mark kept", input: "ééééé", maxRunes: 5, expected: "é…é"},
+		{name: "flag not split", input: "🇯🇵🇯🇵🇯🇵🇯🇵", maxRunes: 6, expected: "🇯🇵…🇯🇵"},
+		{name: "single rune", input: "abcdef", maxRunes: 1, expected: "…"},
+		{name: "two runes", input: "abcdef", maxRunes: 2, expected: "a…"},
+		{name: "zero", input: "abcdef", maxRunes: 0, expected: ""},
+		{name: "negative", input: "abcdef", maxRunes: -1, expected: ""},
+		{name: "empty", input: "", maxRunes: 5, expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ShortenForDisplay(tt.input, tt.maxRunes)
+			if got != tt.expected {
+				t.Errorf("ShortenForDisplay(%q, %d) = %q, want %q", tt.input, tt.maxRunes, got, tt.expected)
+			}
+			if tt.maxRunes > 0 && utf8.RuneCountInString(got) > tt.maxRunes {
+				t.Errorf("ShortenForDisplay(%q, %d) = %q has %d runes", tt.input, tt.maxRunes, got, utf8.RuneCountInString(got))
+			}
+			if again := ShortenForDisplay(tt.input, tt.maxRunes); again != got {
+				t.Errorf("ShortenForDisplay is not deterministic: %q then %q", got, again)
+			}
+		})
+	}
+}
+
+func TestToDOS83(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		taken    []string
+		expected string
+	}{
+		{name: "already 8.3", input: "README.TXT", expected: "README.TXT"},
+		{name: "lowercase 8.3", input: "readme.txt", expected: "README.TXT"},
+		{name: "no extension", input: "makefile", expected: "MAKEFILE"},
+		{name: "long name", input: "Quarterly Report.docx", expected: "QUARTE~1.DOC"},
+		{name: "long extension", input: "page.html", expected: "PAGE~1.HTM"},
+		{name: "spaces", input: "a b.txt", expected: "AB~1.TXT"},
+		{name: "multiple dots", input: "archive.tar.gz", expected: "ARCHIV~1.GZ"},
+		{name: "leading dot", input: ".gitignore", expected: "GITIGN~1"},
+		{name: "invalid chars", input: "a+b=c.txt", expected: "A_B_C~1.TXT"},
+		{name: "symbols allowed", input: "a-b_c(1).txt", expected: "A-B_C(1).TXT"},
+		{name: "diacritics", input: "café.txt", expected: "CAFE~1.TXT"},
+		{name: "cjk", input: "报告.pdf", expected: "__~1.PDF"},
+		{name: "emoji dropped", input: "😀.txt", expected: "_~1.TXT"},
+		{name: "reserved name", input: "con.txt", expected: "CON~1.TXT"},
+		{name: "empty", input: "", expected: "_~1"},
+		{name: "collision on plain name", input: "readme.txt", taken: []string{"README.TXT"}, expected: "README~1.TXT"},
+		{name: "collision chain", input: "Quarterly Report.docx", taken: []string{"QUARTE~1.DOC", "QUARTE~2.DOC", "QUARTE~3.DOC"}, expected: "QUARTE~4.DOC"},
+		{
+			name:  "suffix grows",
+			input: "documentation.txt",
+			taken: []string{
+				"DOCUME~1.TXT", "DOCUME~2.TXT", "DOCUME~3.TXT", "DOCUME~4.TXT", "DOCUME~5.TXT",
+				"DOCUME~6.TXT", "DOCUME~7.TXT", "DOCUME~8.TXT", "DOCUME~9.TXT",
+			},
+			expected: "DOCUM~10.TXT",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taken := make(map[string]bool)
+			for _, name := range tt.taken {
+				taken[name] = true
+			}
+			got := ToDOS83(tt.input, func(name string) bool { return taken[name] })
+			if got != tt.expected {
+				t.Errorf("ToDOS83(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestToDOS83_Directory(t *testing.T) {
+	// 依次为同一目录中的文件生成短文件名，已生成的名称不能再被使用
+	names := []string{"Program Files", "Program Data", "ProgramFiles", "readme.txt", "README.txt", "Report 1.doc", "Report 2.doc"}
+	expected := []string{"PROGRA~1", "PROGRA~2", "PROGRA~3", "README.TXT", "README~1.TXT", "REPORT~1.DOC", "REPORT~2.DOC"}
+
+	for run := 0; run < 2; run++ {
+		used := make(map[string]bool)
+		for i, name := range names {
+			got := ToDOS83(name, func(s string) bool { return used[s] })
+			if got != expected[i] {
+				t.Errorf("run %d: ToDOS83(%q) = %q, want %q", run, name, got, expected[i])
+			}
+			used[got] = true
+		}
+	}
+
+	if got := ToDOS83("long filename.txt", nil); got != "LONGFI~1.TXT" {
+		t.Errorf("ToDOS83 with nil exists = %q, want %q", got, "LONGFI~1.TXT")
+	}
+	if got := ToDOS83("long filename.txt", func(string) bool { return true }); got != "" {
+		t.Errorf("ToDOS83 with every name taken = %q, want empty", got)
+	}
+}
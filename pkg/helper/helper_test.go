@@ -301,10 +301,10 @@ func TestTruncateFilename(t *testing.T) {
 			expected:  "verylongfil.txt",
 		},
 		{
-			name:      "truncate with double extension",
+			name:      "truncate with compound extension preserves it",
 			filename:  "verylongfilename.tar.gz",
 			maxLength: 18,
-			expected:  "verylongfilenam.gz",
+			expected:  "verylongfil.tar.gz", // 有意变更，之前为 "verylongfilenam.gz"，见 doc.go 的数据兼容性说明
 		},
 		{
 			name:      "dot at start truncates fully",
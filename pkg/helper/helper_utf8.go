@@ -0,0 +1,53 @@
+package helper
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// RepairUTF8 把不是有效 UTF-8 的文件名转换为有效的 UTF-8
+//
+// Linux 文件名是任意字节序列，旧归档中的文件名常常是 Latin-1 编码。
+// 转换规则是确定的：
+//   - 有效的 UTF-8 原样返回
+//   - 不含 0x80-0x9F（C1 控制字符）的字节序列按 Latin-1 解码，每个字节对应一个字符
+//   - 其他情况下，每段无效字节替换为一个 U+FFFD
+//
+// 参数：
+//
+//	name - 文件名的原始字节
+//
+// 返回：
+//
+//	repaired - 有效的 UTF-8 文件名
+//	changed - 如果 name 不是有效的 UTF-8 而被转换，返回 true
+//
+// 示例：
+//
+//	RepairUTF8("caf\xe9.txt")  // "café.txt", true
+//	RepairUTF8("a\x81b")       // "a�b", true
+//	RepairUTF8("文件.txt")      // "文件.txt", false
+func RepairUTF8(name string) (repaired string, changed bool) {
+	if utf8.ValidString(name) {
+		return name, false
+	}
+	if isLatin1Text(name) {
+		var builder strings.Builder
+		builder.Grow(len(name) * 2)
+		for i := 0; i < len(name); i++ {
+			builder.WriteRune(rune(name[i]))
+		}
+		return builder.String(), true
+	}
+	return strings.ToValidUTF8(name, string(utf8.RuneError)), true
+}
+
+// isLatin1Text 判断 s 能否作为 Latin-1 文本解码，即不包含 C1 控制字符
+func isLatin1Text(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 && s[i] <= 0x9F {
+			return false
+		}
+	}
+	return true
+}
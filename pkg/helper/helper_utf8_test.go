@@ -0,0 +1,54 @@
+package helper
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+// TestRepairUTF8 测试无效 UTF-8 文件名的转换
+func TestRepairUTF8(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		changed bool
+	}{
+		{"有效 UTF-8", "文件.txt", "文件.txt", false},
+		{"ASCII", "file.txt", "file.txt", false},
+		{"Latin-1", "caf\xe9.txt", "café.txt", true},
+		{"Latin-1 全部高位字节", "\xc0\xff", "Àÿ", true},
+		{"C1 控制字符", "a\x81b", "a�b", true},
+		{"连续无效字节", "a\x80\x81\x82b", "a�b", true},
+		{"截断的 UTF-8 序列", "\xe6\x96", "�", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed := RepairUTF8(tt.input)
+			if got != tt.want || changed != tt.changed {
+				t.Errorf("RepairUTF8(%q) = %q, %v; want %q, %v", tt.input, got, changed, tt.want, tt.changed)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("RepairUTF8(%q) = %q is not valid UTF-8", tt.input, got)
+			}
+			// 转换是确定的，且对结果幂等
+			if again, changed := RepairUTF8(got); again != got || changed {
+				t.Errorf("RepairUTF8(%q) = %q, %v; want unchanged", got, again, changed)
+			}
+		})
+	}
+}
+
+// TestCleanFilename_InvalidUTF8 测试无效 UTF-8 输入始终得到有效的 UTF-8
+func TestCleanFilename_InvalidUTF8(t *testing.T) {
+	for b := 0x80; b <= 0xFF; b++ {
+		input := "name" + string([]byte{byte(b)}) + ".txt"
+		got := CleanFilename(input)
+		if !utf8.ValidString(got) {
+			t.Errorf("CleanFilename(%q) = %q is not valid UTF-8", input, got)
+		}
+		if got != "name�.txt" {
+			t.Errorf("CleanFilename(%q) = %q, want %q", input, got, "name�.txt")
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package importer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+func TestImporter_WithChecksums(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	tree := map[string][]byte{
+		"a.txt":         []byte("alpha"),
+		"dir/b.txt":     []byte("beta"),
+		"dir/empty":     {},
+		"dir/sub/c.bin": make([]byte, 600*1024),
+	}
+	dir := writeTree(t, tree)
+	ctx := context.Background()
+
+	var size int64
+	want := make(map[string]string)
+	for rel, data := range tree {
+		sum := sha256.Sum256(data)
+		want[rel] = hex.EncodeToString(sum[:])
+		size += int64(len(data))
+	}
+
+	var read int64
+	result, err := countSourceReads(NewImporter(bs, dir), &read).WithChecksums(true).Import(ctx)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if got := result.Checksums(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Checksums() = %v, want %v", got, want)
+	}
+	if read != size {
+		t.Errorf("read %d bytes, want each file read once (%d bytes)", read, size)
+	}
+
+	plain, err := NewImporter(bs, dir).Import(ctx)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if got := plain.Checksums(); len(got) != 0 {
+		t.Errorf("Checksums() without WithChecksums = %v", got)
+	}
+	for _, c := range plain.Contents {
+		if _, ok := want[c.Path]; !ok {
+			t.Errorf("Content path %q is not a path of the tree", c.Path)
+		}
+	}
+}
+
+func TestImporter_WithChecksums_LinkedFilesHaveNone(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	index := NewDatastoreContentIndex(ds.NewMapDatastore())
+	ctx := context.Background()
+	tree := map[string][]byte{"known.bin": make([]byte, 300*1024)}
+
+	if _, err := NewImporter(bs, writeTree(t, tree)).WithContentIndex(index).Import(ctx); err != nil {
+		t.Fatalf("first import failed: %v", err)
+	}
+
+	tree["new.txt"] = []byte("not seen before")
+	result, err := NewImporter(bs, writeTree(t, tree)).WithContentIndex(index).WithChecksums(true).Import(ctx)
+	if err != nil {
+		t.Fatalf("second import failed: %v", err)
+	}
+
+	sums := result.Checksums()
+	if _, ok := sums["known.bin"]; ok {
+		t.Error("a file linked through the content index has a checksum")
+	}
+	if _, ok := sums["new.txt"]; !ok {
+		t.Errorf("Checksums() = %v, want an entry for new.txt", sums)
+	}
+}
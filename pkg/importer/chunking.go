@@ -0,0 +1,153 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+	chunk "github.com/ipfs/boxo/chunker"
+)
+
+const (
+	// DefaultChunker is the chunker specification used for every file unless
+	// a profile set with WithChunkerProfile chooses another one
+	DefaultChunker = "size-1048576"
+
+	// CompressedChunker is the chunker DefaultProfile chooses for files that
+	// are already compressed. Their data does not repeat, so the largest
+	// block size exchanged between IPFS peers keeps the block count low.
+	CompressedChunker = "size-2097152"
+
+	// MinChunkSize and MaxChunkSize bound the size accepted by WithChunkSize
+	MinChunkSize = 16 << 10
+	MaxChunkSize = 4 << 20
+)
+
+// compressedExtensions are the extensions DefaultProfile treats as already
+// compressed data.
+var compressedExtensions = map[string]bool{
+	// Archives and compressed streams
+	".zip": true, ".gz": true, ".tgz": true, ".bz2": true, ".xz": true, ".zst": true,
+	".lz4": true, ".lzma": true, ".br": true, ".7z": true, ".rar": true,
+	// Zip-based documents and packages
+	".jar": true, ".apk": true, ".docx": true, ".xlsx": true, ".pptx": true, ".odt": true, ".epub": true,
+	// Video
+	".mp4": true, ".m4v": true, ".mkv": true, ".mov": true, ".webm": true, ".avi": true,
+	// Audio
+	".mp3": true, ".aac": true, ".m4a": true, ".ogg": true, ".opus": true, ".flac": true,
+	// Images
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true, ".heic": true, ".avif": true,
+}
+
+// ChunkerProfile chooses the chunker specification for the file at relPath,
+// a slash-separated path below the import root (the file name for a
+// single-file import), of the given size. An empty specification selects
+// DefaultChunker, or the size set with WithChunkSize.
+//
+// Specifications are those of the IPFS chunker package: "size-<bytes>",
+// "rabin-<min>-<avg>-<max>" or "buzhash".
+type ChunkerProfile func(relPath string, size int64) string
+
+// DefaultProfile is a ChunkerProfile that chunks files with a known
+// compressed extension (archives, media and zip-based documents) with
+// CompressedChunker and every other file with DefaultChunker.
+func DefaultProfile(relPath string, size int64) string {
+	if compressedExtensions[strings.ToLower(path.Ext(relPath))] {
+		return CompressedChunker
+	}
+	return ""
+}
+
+// WithChunkerProfile chooses the chunker of every file with profile, for
+// example DefaultProfile, instead of chunking all files with DefaultChunker.
+// The chunker used for each file is recorded in Content.Chunker. An invalid
+// specification fails the import with ErrInvalidChunker.
+//
+// Blocks are only shared between files chunked with the same specification:
+// identical data chunked differently produces different blocks, so switching
+// a file to another chunker stores it again instead of deduplicating it
+// against earlier imports. A content index set with WithContentIndex only
+// links a file to an earlier import with the same chunker.
+// Returns the importer for method chaining.
+func (imp *Importer) WithChunkerProfile(profile ChunkerProfile) *Importer {
+	imp.profile = profile
+	return imp
+}
+
+// WithChunkSize chunks files into blocks of n bytes instead of the 1MiB of
+// DefaultChunker: larger blocks keep the block count of large media files
+// low, smaller ones suit workloads of small files. n must be between
+// MinChunkSize (16KiB) and MaxChunkSize (4MiB), otherwise the import fails
+// with ErrInvalidChunkSize; zero restores the default. A profile set with
+// WithChunkerProfile still chooses the chunker of the files it names.
+//
+// As with profiles, the same data chunked with another size produces other
+// blocks and another RootCid.
+// Returns the importer for method chaining.
+func (imp *Importer) WithChunkSize(n int64) *Importer {
+	imp.chunkSize = n
+	return imp
+}
+
+// checkChunkSize validates the size set with WithChunkSize.
+func (imp *Importer) checkChunkSize() error {
+	if imp.chunkSize != 0 && (imp.chunkSize < MinChunkSize || imp.chunkSize > MaxChunkSize) {
+		return fmt.Errorf("%w: %d bytes, must be between %d and %d", ErrInvalidChunkSize, imp.chunkSize, MinChunkSize, MaxChunkSize)
+	}
+	return nil
+}
+
+// defaultChunker returns the specification of files no profile chooses a
+// chunker for.
+func (imp *Importer) defaultChunker() string {
+	if imp.chunkSize == 0 {
+		return DefaultChunker
+	}
+	return "size-" + strconv.FormatInt(imp.chunkSize, 10)
+}
+
+// chunkerFor returns the chunker specification for the file at relPath.
+func (imp *Importer) chunkerFor(relPath string, size int64) string {
+	if imp.profile == nil {
+		return imp.defaultChunker()
+	}
+	if spec := imp.profile(relPath, size); spec != "" {
+		return spec
+	}
+	return imp.defaultChunker()
+}
+
+// newSplitter returns the splitter described by spec. Fixed sizes are
+// handled here so that any positive size is accepted; other specifications
+// are parsed by the chunker package.
+func newSplitter(r io.Reader, spec string) (chunk.Splitter, error) {
+	if sizeStr, ok := strings.CutPrefix(spec, "size-"); ok {
+		size, err := strconv.ParseInt(sizeStr, 10, 64)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidChunker, spec)
+		}
+		return chunk.NewSizeSplitter(r, size), nil
+	}
+
+	splitter, err := chunk.FromString(r, spec)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q: %w", ErrInvalidChunker, spec, err)
+	}
+	return splitter, nil
+}
+
+// chunkerKey mixes a chunker specification other than DefaultChunker into a
+// quick hash, so the content index only matches files chunked the same way.
+// Keys of files chunked with the default stay those of earlier imports.
+func chunkerKey(hash []byte, spec string) []byte {
+	if hash == nil || spec == DefaultChunker {
+		return hash
+	}
+	h := xxhash.New()
+	_, _ = h.Write(hash)
+	_, _ = h.Write([]byte(spec))
+	return h.Sum(nil)
+}
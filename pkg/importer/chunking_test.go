@@ -0,0 +1,189 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	ds "github.com/ipfs/go-datastore"
+)
+
+// leafSizes returns the sizes of the blocks directly below the file name in
+// the directory root.
+func leafSizes(t *testing.T, bs blockstore.Blockstore, root, name string) []uint64 {
+	t.Helper()
+
+	dag := merkledag.NewDAGService(blockservice.New(bs, nil))
+	nd, err := dag.Get(context.Background(), resolve(t, bs, root, name))
+	if err != nil {
+		t.Fatalf("Get(%s) failed: %v", name, err)
+	}
+	var sizes []uint64
+	for _, link := range nd.Links() {
+		sizes = append(sizes, link.Size)
+	}
+	return sizes
+}
+
+func TestImporter_WithChunkerProfile(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	rng := rand.New(rand.NewSource(1))
+	data := make([]byte, 5*1024*1024)
+	rng.Read(data)
+	dir := writeTree(t, map[string][]byte{"archive.ZIP": data, "notes.txt": data})
+
+	result, err := NewImporter(bs, dir).WithChunkerProfile(DefaultProfile).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		chunker string
+		leaves  int
+		maxSize uint64
+	}{
+		{"archive.ZIP", CompressedChunker, 3, 2 * 1024 * 1024},
+		{"notes.txt", DefaultChunker, 5, 1024 * 1024},
+	}
+	for _, tt := range tests {
+		sizes := leafSizes(t, bs, result.RootCid, tt.name)
+		if len(sizes) != tt.leaves {
+			t.Errorf("%s: %d leaves, want %d", tt.name, len(sizes), tt.leaves)
+		}
+		for _, size := range sizes {
+			if size > tt.maxSize {
+				t.Errorf("%s: leaf of %d bytes, want at most %d", tt.name, size, tt.maxSize)
+			}
+		}
+
+		found := false
+		for _, c := range result.Contents {
+			if c.Name == tt.name {
+				found = true
+				if c.Chunker != tt.chunker {
+					t.Errorf("%s: Chunker = %q, want %q", tt.name, c.Chunker, tt.chunker)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%s missing from Contents", tt.name)
+		}
+	}
+}
+
+func TestImporter_WithChunkerProfile_Invalid(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	dir := writeTree(t, map[string][]byte{"a.txt": []byte("alpha")})
+	for _, spec := range []string{"size-0", "size-abc", "unknown"} {
+		profile := func(string, int64) string { return spec }
+		_, err := NewImporter(bs, dir).WithChunkerProfile(profile).Import(context.Background())
+		if !errors.Is(err, ErrInvalidChunker) {
+			t.Errorf("%q: Import error = %v, want ErrInvalidChunker", spec, err)
+		}
+	}
+}
+
+func TestImporter_WithChunkerProfile_ContentIndex(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	rng := rand.New(rand.NewSource(2))
+	data := make([]byte, 3*1024*1024)
+	rng.Read(data)
+	dir := writeTree(t, map[string][]byte{"video.mp4": data})
+
+	ctx := context.Background()
+	index := NewDatastoreContentIndex(ds.NewMapDatastore())
+	first, err := NewImporter(bs, dir).WithContentIndex(index).Import(ctx)
+	if err != nil {
+		t.Fatalf("default import failed: %v", err)
+	}
+
+	profiled, err := NewImporter(bs, dir).WithContentIndex(index).WithChunkerProfile(DefaultProfile).Import(ctx)
+	if err != nil {
+		t.Fatalf("profiled import failed: %v", err)
+	}
+	want, err := NewImporter(bs, dir).WithChunkerProfile(DefaultProfile).Import(ctx)
+	if err != nil {
+		t.Fatalf("profiled import without index failed: %v", err)
+	}
+
+	if profiled.RootCid == first.RootCid {
+		t.Errorf("RootCid = %s, file was linked to its default-chunked import", profiled.RootCid)
+	}
+	if profiled.RootCid != want.RootCid {
+		t.Errorf("RootCid = %s, want %s as without index", profiled.RootCid, want.RootCid)
+	}
+}
+
+func TestImporter_WithChunkSize(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	rng := rand.New(rand.NewSource(2))
+	data := make([]byte, 3*1024*1024)
+	rng.Read(data)
+	dir := writeTree(t, map[string][]byte{"media.bin": data})
+
+	roots := make(map[string]int64)
+	for _, tt := range []struct {
+		size   int64
+		leaves int
+	}{
+		{64 << 10, 48},
+		{256 << 10, 12},
+		{MaxChunkSize, 0}, // One raw block, no links
+	} {
+		result, err := NewImporter(bs, dir).WithChunkSize(tt.size).Import(context.Background())
+		if err != nil {
+			t.Fatalf("size %d: Import failed: %v", tt.size, err)
+		}
+		if prev, ok := roots[result.RootCid]; ok {
+			t.Errorf("sizes %d and %d produced the same root %s", prev, tt.size, result.RootCid)
+		}
+		roots[result.RootCid] = tt.size
+
+		sizes := leafSizes(t, bs, result.RootCid, "media.bin")
+		if len(sizes) != tt.leaves {
+			t.Errorf("size %d: %d leaves, want %d", tt.size, len(sizes), tt.leaves)
+		}
+		blocks := 0
+		for _, pkg := range result.Packages {
+			blocks += len(pkg.Blocks)
+		}
+		// The leaves, the file node if it has leaves, and the directory
+		want := tt.leaves + 1
+		if tt.leaves > 0 {
+			want++
+		}
+		if blocks != want {
+			t.Errorf("size %d: %d blocks in packages, want %d", tt.size, blocks, want)
+		}
+		if got, want := result.Contents[0].Chunker, fmt.Sprintf("size-%d", tt.size); got != want {
+			t.Errorf("size %d: Chunker = %q, want %q", tt.size, got, want)
+		}
+	}
+}
+
+func TestImporter_WithChunkSize_Invalid(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	dir := writeTree(t, map[string][]byte{"a.txt": []byte("alpha")})
+	for _, size := range []int64{-1, 1, MinChunkSize - 1, MaxChunkSize + 1} {
+		_, err := NewImporter(bs, dir).WithChunkSize(size).Import(context.Background())
+		if !errors.Is(err, ErrInvalidChunkSize) {
+			t.Errorf("size %d: Import error = %v, want ErrInvalidChunkSize", size, err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multicodec"
+)
+
+// DefaultHashFunc is the multihash function of an import without
+// WithHashFunc.
+const DefaultHashFunc = "sha2-256"
+
+// hashFuncs are the multihash functions accepted by WithHashFunc, by name.
+var hashFuncs = map[string]multicodec.Code{
+	"sha2-256":    multicodec.Sha2_256,
+	"sha2-512":    multicodec.Sha2_512,
+	"sha3-256":    multicodec.Sha3_256,
+	"sha3-512":    multicodec.Sha3_512,
+	"blake2b-256": multicodec.Blake2b256,
+	"blake2b-512": multicodec.Blake2b512,
+	"blake3":      multicodec.Blake3,
+}
+
+// WithCIDVersion sets the version of the CIDs of every block of the import,
+// 1 by default. Version 0 only supports sha2-256 and stores file data in
+// dag-pb leaves instead of raw leaves, so that every block has a CIDv0, as
+// Kubo does for --cid-version=0. Any other version fails the import with
+// ErrInvalidCIDVersion.
+//
+// The same data imported with another version or hash function produces
+// other blocks and another RootCid.
+// Returns the importer for method chaining.
+func (imp *Importer) WithCIDVersion(v int) *Importer {
+	imp.cidVersion = v
+	return imp
+}
+
+// WithHashFunc sets the multihash function of the CIDs of every block of the
+// import by its multihash name, DefaultHashFunc by default. The supported
+// functions are sha2-256, sha2-512, sha3-256, sha3-512, blake2b-256,
+// blake2b-512 and blake3; any other name fails the import with
+// ErrUnsupportedHashFunc. For example, WithHashFunc("blake2b-256") with
+// version 1 matches a Kubo node adding with --hash=blake2b-256.
+// Returns the importer for method chaining.
+func (imp *Importer) WithHashFunc(name string) *Importer {
+	imp.hashFunc = name
+	return imp
+}
+
+// hashFuncName returns the hash function set with WithHashFunc, lower-cased.
+func (imp *Importer) hashFuncName() string {
+	if imp.hashFunc == "" {
+		return DefaultHashFunc
+	}
+	return strings.ToLower(imp.hashFunc)
+}
+
+// checkCidBuilder validates the CID version and hash function and sets the
+// builder every node of the import is encoded with.
+func (imp *Importer) checkCidBuilder() error {
+	name := imp.hashFuncName()
+	code, ok := hashFuncs[name]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnsupportedHashFunc, imp.hashFunc)
+	}
+
+	switch imp.cidVersion {
+	case 0:
+		if code != multicodec.Sha2_256 {
+			return fmt.Errorf("%w: CIDv0 requires sha2-256, got %s", ErrInvalidCIDVersion, name)
+		}
+		imp.cidBuilder = cid.V0Builder{}
+	case 1:
+		imp.cidBuilder = cid.V1Builder{
+			Codec:    uint64(multicodec.DagPb),
+			MhType:   uint64(code),
+			MhLength: -1,
+		}
+	default:
+		return fmt.Errorf("%w: %d", ErrInvalidCIDVersion, imp.cidVersion)
+	}
+	return nil
+}
+
+// rawLeaves reports whether file data is stored in raw leaf blocks, which
+// need a CIDv1.
+func (imp *Importer) rawLeaves() bool {
+	return imp.cidVersion != 0
+}
+
+// indexSpec returns the chunker specification a content index key covers
+// for files chunked with chunker. CID settings other than the default are
+// appended, so files are only linked to copies encoded the same way.
+func (imp *Importer) indexSpec(chunker string) string {
+	if imp.cidVersion == 1 && imp.hashFuncName() == DefaultHashFunc {
+		return chunker
+	}
+	return chunker + " " + builderString(imp.cidBuilder)
+}
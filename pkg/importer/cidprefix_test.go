@@ -0,0 +1,104 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multicodec"
+	"github.com/tragoedia0722/repository/pkg/extractor"
+)
+
+func TestImporter_WithCIDVersion_HashFunc(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	large := make([]byte, 3*1024*1024)
+	rand.New(rand.NewSource(4)).Read(large)
+	tree := map[string][]byte{
+		"large.bin":     large,
+		"dir/small.txt": []byte("small"),
+	}
+	dir := writeTree(t, tree)
+	ctx := context.Background()
+
+	plain, err := NewImporter(bs, dir).Import(ctx)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	for _, tt := range []struct {
+		version int
+		hash    string
+		mhType  multicodec.Code
+	}{
+		{1, "blake2b-256", multicodec.Blake2b256},
+		{1, "SHA3-256", multicodec.Sha3_256},
+		{0, "sha2-256", multicodec.Sha2_256},
+	} {
+		result, err := NewImporter(bs, dir).WithCIDVersion(tt.version).WithHashFunc(tt.hash).Import(ctx)
+		if err != nil {
+			t.Fatalf("v%d %s: Import failed: %v", tt.version, tt.hash, err)
+		}
+		if result.RootCid == plain.RootCid {
+			t.Errorf("v%d %s: same root as the default settings", tt.version, tt.hash)
+		}
+
+		root, err := cid.Decode(result.RootCid)
+		if err != nil || root.String() != result.RootCid {
+			t.Fatalf("v%d %s: RootCid %s does not round-trip: %v", tt.version, tt.hash, result.RootCid, err)
+		}
+
+		// Every block, leaves included, uses the chosen version and hash
+		for _, pkg := range result.Packages {
+			for _, block := range pkg.Blocks {
+				c, err := cid.Decode(block)
+				if err != nil {
+					t.Fatalf("invalid block CID %s: %v", block, err)
+				}
+				if p := c.Prefix(); p.Version != uint64(tt.version) || p.MhType != uint64(tt.mhType) {
+					t.Errorf("v%d %s: block %s has version %d and hash %s", tt.version, tt.hash, block, p.Version, multicodec.Code(p.MhType))
+				}
+			}
+		}
+
+		out := t.TempDir()
+		if err := extractor.NewExtractor(bs, result.RootCid, out).Extract(ctx, false); err != nil {
+			t.Fatalf("v%d %s: Extract failed: %v", tt.version, tt.hash, err)
+		}
+		for rel, want := range tree {
+			got, err := os.ReadFile(filepath.Join(out, filepath.FromSlash(rel)))
+			if err != nil || !bytes.Equal(got, want) {
+				t.Errorf("v%d %s: %s differs after extraction: %v", tt.version, tt.hash, rel, err)
+			}
+		}
+	}
+}
+
+func TestImporter_WithCIDVersion_Invalid(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	dir := writeTree(t, map[string][]byte{"a.txt": []byte("alpha")})
+	for _, tt := range []struct {
+		version int
+		hash    string
+		want    error
+	}{
+		{2, DefaultHashFunc, ErrInvalidCIDVersion},
+		{-1, DefaultHashFunc, ErrInvalidCIDVersion},
+		{0, "blake2b-256", ErrInvalidCIDVersion},
+		{1, "md5", ErrUnsupportedHashFunc},
+		{1, "sha2", ErrUnsupportedHashFunc},
+	} {
+		_, err := NewImporter(bs, dir).WithCIDVersion(tt.version).WithHashFunc(tt.hash).Import(context.Background())
+		if !errors.Is(err, tt.want) {
+			t.Errorf("v%d %s: error = %v, want %v", tt.version, tt.hash, err, tt.want)
+		}
+	}
+}
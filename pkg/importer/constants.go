@@ -4,16 +4,18 @@ const (
 	// Cache management
 	liveCacheSize = uint64(256 << 10) // 256K nodes max in memory before flushing
 
-	// Chunking configuration
-	chunkSize = 1024 * 1024 // 1MB chunks for file splitting
-
 	// Batch processing
 	defaultBatchSize = 100 << 20 // 100MB batch size for buffered DAG operations
+	commitBatchBytes = 32 << 20  // 32MB of staged blocks per PutMany when committing
+
+	// Directory listing
+	defaultDirBatchSize = 1024 // Directory entries read from disk at a time
+
+	// Directory statistics
+	defaultDirStatsLimit = 100000 // Directories recorded before automatic stats are dropped
 
-	// Package configuration
-	blocksPerPackage     = 100 // Max blocks per package
-	initialBlockCapacity = 100 // Pre-allocated block slice capacity
-	cidStringBufferSize  = 64  // Estimated CID string length for hash builder
+	// Node cache
+	nodeCacheMaxFile = 256 << 10 // Largest file whose node is kept in a NodeCache
 
 	// Default names
 	defaultFileName = "unnamed_file"
@@ -0,0 +1,169 @@
+package importer
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/ipfs/boxo/files"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/tragoedia0722/repository/pkg/packaging"
+)
+
+// ContentIndex maps file contents to the root CIDs of files imported before,
+// so that a file seen by an earlier import can be linked without being read
+// and chunked again.
+//
+// Files are identified by their size and a quick hash of their first and
+// last 64KB (see quickHashSpan). Implementations must report a miss for keys
+// known to map to different files.
+type ContentIndex interface {
+	// Lookup returns the root CID of a file previously added with the same
+	// size and quick hash.
+	Lookup(size int64, quickHash []byte) (fileCid string, ok bool)
+
+	// Add records fileCid as the root of a file with the given size and
+	// quick hash.
+	Add(size int64, quickHash []byte, fileCid string) error
+}
+
+// contentIndexKeyPrefix is the datastore namespace of DatastoreContentIndex.
+const contentIndexKeyPrefix = "/importer/content-index"
+
+// DatastoreContentIndex is a ContentIndex persisted in a datastore, such as
+// the one of the repository the blocks are imported into.
+//
+// When two different files are added under the same size and quick hash,
+// the key is marked as a collision and every later lookup misses, so those
+// files are always imported in full.
+type DatastoreContentIndex struct {
+	store ds.Datastore
+}
+
+// NewDatastoreContentIndex returns a ContentIndex stored in store.
+func NewDatastoreContentIndex(store ds.Datastore) *DatastoreContentIndex {
+	return &DatastoreContentIndex{store: store}
+}
+
+// key returns the datastore key for a size and quick hash.
+func (idx *DatastoreContentIndex) key(size int64, quickHash []byte) ds.Key {
+	return ds.NewKey(contentIndexKeyPrefix).
+		ChildString(strconv.FormatInt(size, 10)).
+		ChildString(hex.EncodeToString(quickHash))
+}
+
+// Lookup implements ContentIndex. Collided keys are stored with an empty
+// value and always miss.
+func (idx *DatastoreContentIndex) Lookup(size int64, quickHash []byte) (string, bool) {
+	data, err := idx.store.Get(context.Background(), idx.key(size, quickHash))
+	if err != nil || len(data) == 0 {
+		return "", false
+	}
+	return string(data), true
+}
+
+// Add implements ContentIndex.
+func (idx *DatastoreContentIndex) Add(size int64, quickHash []byte, fileCid string) error {
+	ctx := context.Background()
+	key := idx.key(size, quickHash)
+
+	existing, err := idx.store.Get(ctx, key)
+	switch {
+	case errors.Is(err, ds.ErrNotFound):
+	case err != nil:
+		return fmt.Errorf("failed to read content index: %w", err)
+	case string(existing) == fileCid:
+		return nil
+	default:
+		// A different file, or a key already marked as a collision
+		fileCid = ""
+	}
+
+	if err := idx.store.Put(ctx, key, []byte(fileCid)); err != nil {
+		return fmt.Errorf("failed to update content index: %w", err)
+	}
+	return nil
+}
+
+// quickHashSpan is the number of bytes hashed at each end of a file.
+const quickHashSpan = 64 * 1024
+
+// quickHash returns the xxhash64 of the size, the first quickHashSpan bytes
+// and the last quickHashSpan bytes of file, then rewinds it. Files of up to
+// twice the span are hashed in full. It returns nil if file cannot seek.
+func quickHash(file files.File, size int64) ([]byte, error) {
+	if _, err := file.Seek(0, io.SeekCurrent); err != nil {
+		return nil, nil
+	}
+
+	h := xxhash.New()
+	var sizeBuf [8]byte
+	binary.LittleEndian.PutUint64(sizeBuf[:], uint64(size))
+	_, _ = h.Write(sizeBuf[:])
+
+	if size <= 2*quickHashSpan {
+		if _, err := io.CopyN(h, file, size); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := io.CopyN(h, file, quickHashSpan); err != nil {
+			return nil, err
+		}
+		if _, err := file.Seek(size-quickHashSpan, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err := io.CopyN(h, file, quickHashSpan); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// WithContentIndex makes the import look up every file in index before
+// reading it. On a hit whose blocks are all present in the blockstore, the
+// recorded file node is linked into the new tree without chunking the file;
+// files imported in full are added to index.
+//
+// The DAG is the same as without an index. Only the size and the first and
+// last 64KB of a file are compared, so a file that changed solely in the
+// middle while keeping its size is linked to its earlier content; files of
+// up to 128KB are always compared in full.
+// Returns the importer for method chaining.
+func (imp *Importer) WithContentIndex(index ContentIndex) *Importer {
+	imp.index = index
+	return imp
+}
+
+// lookupContent returns the node of a previously imported file matching
+// size and hash, provided all of its blocks are present.
+func (imp *Importer) lookupContent(ctx context.Context, size int64, hash []byte) (ipld.Node, []string, bool) {
+	fileCid, ok := imp.index.Lookup(size, hash)
+	if !ok {
+		return nil, nil, false
+	}
+	c, err := cid.Decode(fileCid)
+	if err != nil {
+		return nil, nil, false
+	}
+
+	blocks, err := packaging.CollectBlocks(ctx, imp.dagService, c)
+	if err != nil {
+		return nil, nil, false
+	}
+	nd, err := imp.dagService.Get(ctx, c)
+	if err != nil {
+		return nil, nil, false
+	}
+	return nd, blocks, true
+}
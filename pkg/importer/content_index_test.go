@@ -0,0 +1,196 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/boxo/files"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/tragoedia0722/repository/pkg/extractor"
+)
+
+// countingFile counts the bytes read from a source file.
+type countingFile struct {
+	files.File
+	read *int64
+}
+
+func (f *countingFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	*f.read += int64(n)
+	return n, err
+}
+
+// countSourceReads makes imp add the bytes it reads from source files to read.
+func countSourceReads(imp *Importer, read *int64) *Importer {
+	imp.wrapSource = func(file files.File) files.File {
+		return &countingFile{File: file, read: read}
+	}
+	return imp
+}
+
+// writeTree writes files below a new temporary directory and returns it.
+func writeTree(t *testing.T, tree map[string][]byte) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for rel, content := range tree {
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", rel, err)
+		}
+	}
+	return dir
+}
+
+func TestImporter_WithContentIndex_SkipsKnownFiles(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	rng := rand.New(rand.NewSource(1))
+	random := func(n int) []byte {
+		b := make([]byte, n)
+		rng.Read(b)
+		return b
+	}
+
+	big := random(3*1024*1024 + 17)
+	medium := random(700 * 1024)
+	small := random(1000)
+
+	treeA := map[string][]byte{"big.bin": big, "medium.bin": medium, "small.bin": small}
+	treeB := map[string][]byte{
+		"copies/big.bin":      big,
+		"copies/renamed.bin":  medium,
+		"small.bin":           small,
+		"other/small-dup.bin": small,
+		"new.txt":             []byte("only in tree B"),
+	}
+
+	index := NewDatastoreContentIndex(ds.NewMapDatastore())
+	ctx := context.Background()
+
+	if _, err := NewImporter(bs, writeTree(t, treeA)).WithContentIndex(index).Import(ctx); err != nil {
+		t.Fatalf("import of tree A failed: %v", err)
+	}
+
+	dirB := writeTree(t, treeB)
+	var dedupedRead, fullRead int64
+	deduped, err := countSourceReads(NewImporter(bs, dirB), &dedupedRead).WithContentIndex(index).Import(ctx)
+	if err != nil {
+		t.Fatalf("deduplicated import of tree B failed: %v", err)
+	}
+
+	full, err := countSourceReads(NewImporter(bs, dirB), &fullRead).Import(ctx)
+	if err != nil {
+		t.Fatalf("full import of tree B failed: %v", err)
+	}
+
+	t.Logf("bytes read: %d with index, %d without", dedupedRead, fullRead)
+	if dedupedRead*10 > fullRead {
+		t.Errorf("index saved too little: read %d bytes, %d without index", dedupedRead, fullRead)
+	}
+
+	if deduped.RootCid != full.RootCid {
+		t.Errorf("RootCid = %s, want %s as without index", deduped.RootCid, full.RootCid)
+	}
+	if deduped.Size != full.Size {
+		t.Errorf("Size = %d, want %d", deduped.Size, full.Size)
+	}
+	if len(deduped.Packages) != len(full.Packages) {
+		t.Errorf("got %d packages, want %d", len(deduped.Packages), len(full.Packages))
+	}
+
+	out := filepath.Join(t.TempDir(), "out")
+	if err := extractor.NewExtractor(bs, deduped.RootCid, out).Extract(ctx, false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	for rel, want := range treeB {
+		got, err := os.ReadFile(filepath.Join(out, rel))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", rel, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: extracted content differs", rel)
+		}
+	}
+}
+
+func TestImporter_WithContentIndex_MissingBlocks(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	content := bytes.Repeat([]byte("abc"), 100)
+	path := filepath.Join(writeTree(t, map[string][]byte{"f.txt": content}), "f.txt")
+
+	// Point the index at a CID whose blocks were never written.
+	index := NewDatastoreContentIndex(ds.NewMapDatastore())
+	hash, err := quickHash(files.NewBytesFile(content), int64(len(content)))
+	if err != nil {
+		t.Fatalf("quickHash failed: %v", err)
+	}
+	absent, err := NewImporter(bs, path).cidBuilder.Sum([]byte("never written"))
+	if err != nil {
+		t.Fatalf("Sum failed: %v", err)
+	}
+	if err := index.Add(int64(len(content)), hash, absent.String()); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	result, err := NewImporter(bs, path).WithContentIndex(index).Import(ctx)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	want, err := NewImporter(bs, path).Import(ctx)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.RootCid != want.RootCid {
+		t.Errorf("RootCid = %s, want %s", result.RootCid, want.RootCid)
+	}
+}
+
+func TestDatastoreContentIndex_Collision(t *testing.T) {
+	index := NewDatastoreContentIndex(ds.NewMapDatastore())
+	hash := []byte{1, 2, 3}
+
+	if err := index.Add(10, hash, "cid-a"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if got, ok := index.Lookup(10, hash); !ok || got != "cid-a" {
+		t.Errorf("Lookup = %q, %v; want cid-a", got, ok)
+	}
+	if _, ok := index.Lookup(11, hash); ok {
+		t.Error("Lookup with a different size should miss")
+	}
+
+	// The same file again keeps the entry
+	if err := index.Add(10, hash, "cid-a"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if _, ok := index.Lookup(10, hash); !ok {
+		t.Error("re-adding the same file should keep the entry")
+	}
+
+	// A different file under the same key makes the key unusable
+	if err := index.Add(10, hash, "cid-b"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if _, ok := index.Lookup(10, hash); ok {
+		t.Error("Lookup should miss after a collision")
+	}
+	if err := index.Add(10, hash, "cid-a"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if _, ok := index.Lookup(10, hash); ok {
+		t.Error("a collided key should stay unusable")
+	}
+}
@@ -2,92 +2,85 @@ package importer
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
-	"sort"
-	"strings"
+	"io"
 
-	chunk "github.com/ipfs/boxo/chunker"
-	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/blockstore"
 	"github.com/ipfs/boxo/ipld/unixfs/importer/balanced"
 	"github.com/ipfs/boxo/ipld/unixfs/importer/helpers"
-	"github.com/ipfs/go-cid"
 	ipld "github.com/ipfs/go-ipld-format"
-	"io"
+	"github.com/tragoedia0722/repository/pkg/packaging"
 )
 
 // calcPackage creates a package hash from a list of block CIDs
 func (imp *Importer) calcPackage(blocks []string) Package {
-	builder := strings.Builder{}
-	builder.Grow(len(blocks) * cidStringBufferSize)
-
-	for _, block := range blocks {
-		builder.WriteString(block)
-	}
-
-	hash := sha256.Sum256([]byte(builder.String()))
-
-	return Package{
-		Hash:   hex.EncodeToString(hash[:]),
-		Blocks: blocks,
-	}
+	return packaging.Calc(blocks)
 }
 
 // collectBlocks walks the DAG and collects all block CIDs
 func (imp *Importer) collectBlocks(ctx context.Context, root ipld.Node) ([]string, error) {
-	cidSet := cid.NewSet()
-	if err := merkledag.Walk(ctx, merkledag.GetLinksWithDAG(imp.dagService), root.Cid(), func(c cid.Cid) bool {
-		return cidSet.Visit(c)
-	}, merkledag.Concurrent()); err != nil {
-		return nil, err
-	}
-
-	links := make([]string, 0, cidSet.Len())
-	_ = cidSet.ForEach(func(c cid.Cid) error {
-		links = append(links, c.String())
-		return nil
-	})
-	sort.Strings(links)
-
-	return links, nil
+	return packaging.CollectBlocks(ctx, imp.dagService, root.Cid())
 }
 
-// createPackages creates packages from collected blocks
-func (imp *Importer) createPackages(blocks []string) []Package {
-	packages := make([]Package, 0)
-	currentBlocks := make([]string, 0, initialBlockCapacity)
-
-	for _, link := range blocks {
-		currentBlocks = append(currentBlocks, link)
-
-		if len(currentBlocks) >= blocksPerPackage {
-			packages = append(packages, imp.calcPackage(currentBlocks))
-			currentBlocks = make([]string, 0, initialBlockCapacity)
-		}
+// createPackages collects the blocks below root and packs them within the
+// limits set with WithPackageLimit, taking block sizes from the walk
+func (imp *Importer) createPackages(ctx context.Context, root ipld.Node) ([]Package, error) {
+	blocks, size, err := packaging.CollectSizedBlocks(ctx, imp.dagService, root.Cid(), imp.store().GetSize)
+	if err != nil {
+		return nil, err
 	}
+	return packaging.SplitSized(ctx, blocks, size, imp.pkgLimit)
+}
 
-	if len(currentBlocks) > 0 {
-		packages = append(packages, imp.calcPackage(currentBlocks))
+// store returns the blockstore the running import writes to: the staging
+// area of an atomic import, otherwise the target blockstore
+func (imp *Importer) store() blockstore.Blockstore {
+	if imp.stage != nil {
+		return imp.stage
 	}
+	return imp.blockStore
+}
 
-	return packages
+// WithPackageLimit caps the packages of Result.Packages at maxBlocks blocks
+// and maxBytes bytes of block data, closing a package as soon as the next
+// block would exceed either limit. A non-positive maxBlocks keeps the
+// default of 100 blocks and a non-positive maxBytes sets no byte limit. A
+// single block larger than maxBytes forms a package of its own.
+// Returns the importer for method chaining.
+func (imp *Importer) WithPackageLimit(maxBlocks int, maxBytes int64) *Importer {
+	imp.pkgLimit = packaging.Limits{MaxBlocks: maxBlocks, MaxBytes: maxBytes}
+	return imp
 }
 
-// buildDAGFromFile chunks a file reader and builds a DAG
+// buildDAGFromFile chunks a file reader with the default chunker of the
+// import and builds a DAG
 func (imp *Importer) buildDAGFromFile(ctx context.Context, reader io.Reader) (ipld.Node, error) {
+	return imp.buildDAGWithChunker(ctx, reader, imp.defaultChunker())
+}
+
+// buildDAGWithChunker chunks a file reader as described by spec and builds a DAG
+func (imp *Importer) buildDAGWithChunker(ctx context.Context, reader io.Reader, spec string) (ipld.Node, error) {
+	return imp.buildDAGInto(ctx, reader, spec, imp.bufferedDS)
+}
+
+// buildDAGInto chunks a file reader as described by spec and builds a DAG
+// in dag, which is committed before it returns
+func (imp *Importer) buildDAGInto(ctx context.Context, reader io.Reader, spec string, dag *ipld.BufferedDAG) (ipld.Node, error) {
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	default:
 	}
 
-	splitter := chunk.NewSizeSplitter(reader, chunkSize)
+	splitter, err := newSplitter(reader, spec)
+	if err != nil {
+		return nil, err
+	}
 
 	params := helpers.DagBuilderParams{
 		Maxlinks:   helpers.DefaultLinksPerBlock,
-		RawLeaves:  true,
+		RawLeaves:  imp.rawLeaves(),
 		CidBuilder: imp.cidBuilder,
-		Dagserv:    imp.bufferedDS,
+		Dagserv:    dag,
 		NoCopy:     false,
 	}
 
@@ -101,5 +94,5 @@ func (imp *Importer) buildDAGFromFile(ctx context.Context, reader io.Reader) (ip
 		return nil, err
 	}
 
-	return nd, imp.bufferedDS.Commit()
+	return nd, dag.Commit()
 }
@@ -0,0 +1,118 @@
+package importer
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// DedupStats reports how many of the blocks written by an import were
+// already present in the blockstore. Each distinct block is counted once.
+type DedupStats struct {
+	NewBlocks      int   // Blocks the blockstore did not have before the import
+	NewBytes       int64 // Total size of the new blocks
+	ExistingBlocks int   // Blocks already present, e.g. from an earlier import
+	ExistingBytes  int64 // Total size of the existing blocks, i.e. the bytes deduplicated rather than stored again
+}
+
+// dedupDAG wraps a DAG service and classifies every added node as new or
+// already present in the blockstore. Before a batch from the buffered DAG is
+// written, AddMany checks each block not yet classified with its own Has,
+// one after another; only the write itself is batched. Batches of files
+// chunked concurrently may arrive at the same time.
+type dedupDAG struct {
+	ipld.DAGService
+	store blockstore.Blockstore // Blockstore the import finally writes to
+	mu    sync.Mutex            // Guards seen and stats
+	seen  map[cid.Cid]struct{}
+	stats DedupStats
+}
+
+func newDedupDAG(ds ipld.DAGService, store blockstore.Blockstore) *dedupDAG {
+	return &dedupDAG{
+		DAGService: ds,
+		store:      store,
+		seen:       make(map[cid.Cid]struct{}),
+	}
+}
+
+func (d *dedupDAG) Add(ctx context.Context, nd ipld.Node) error {
+	return d.AddMany(ctx, []ipld.Node{nd})
+}
+
+func (d *dedupDAG) AddMany(ctx context.Context, nds []ipld.Node) error {
+	var fresh []ipld.Node
+	var had []bool
+	for _, nd := range nds {
+		if d.counted(nd.Cid()) {
+			continue
+		}
+		has, err := d.store.Has(ctx, nd.Cid())
+		if err != nil {
+			return err
+		}
+		fresh = append(fresh, nd)
+		had = append(had, has)
+	}
+
+	if err := d.DAGService.AddMany(ctx, nds); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, nd := range fresh {
+		if _, ok := d.seen[nd.Cid()]; ok {
+			// Repeated within this batch, or added by a concurrent batch
+			continue
+		}
+		d.seen[nd.Cid()] = struct{}{}
+		size := int64(len(nd.RawData()))
+		if had[i] {
+			d.stats.ExistingBlocks++
+			d.stats.ExistingBytes += size
+		} else {
+			d.stats.NewBlocks++
+			d.stats.NewBytes += size
+		}
+	}
+	return nil
+}
+
+// counted reports whether c was already classified.
+func (d *dedupDAG) counted(c cid.Cid) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, ok := d.seen[c]
+	return ok
+}
+
+// result returns the collected statistics, or nil if collection was disabled.
+func (d *dedupDAG) result() *DedupStats {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stats := d.stats
+	return &stats
+}
+
+// WithDedupStats turns Result.DedupStats on or off. The statistics are off
+// by default: collecting them costs a presence check of every block before
+// it is written. While they are off Result.DedupStats is nil.
+// Returns the importer for method chaining.
+func (imp *Importer) WithDedupStats(enabled bool) *Importer {
+	imp.dedupStats = enabled
+	return imp
+}
+
+// WithoutDedupStats disables Result.DedupStats, the same as WithDedupStats(false)
+// and the default.
+// Returns the importer for method chaining.
+func (imp *Importer) WithoutDedupStats() *Importer {
+	return imp.WithDedupStats(false)
+}
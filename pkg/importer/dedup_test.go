@@ -0,0 +1,87 @@
+package importer
+
+import (
+	"context"
+	"testing"
+)
+
+func TestImporter_DedupStats_SecondImport(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	dir := writeTree(t, stagingTree())
+	ctx := context.Background()
+
+	first, err := NewImporter(bs, dir).WithDedupStats(true).Import(ctx)
+	if err != nil {
+		t.Fatalf("first Import failed: %v", err)
+	}
+	second, err := NewImporter(bs, dir).WithDedupStats(true).Import(ctx)
+	if err != nil {
+		t.Fatalf("second Import failed: %v", err)
+	}
+
+	d1, d2 := first.DedupStats, second.DedupStats
+	if d1 == nil || d2 == nil {
+		t.Fatalf("DedupStats = %+v, %+v, want both set", d1, d2)
+	}
+	if d1.ExistingBlocks != 0 || d1.ExistingBytes != 0 {
+		t.Errorf("first import reports existing blocks: %+v", *d1)
+	}
+	if d1.NewBytes < first.Size {
+		t.Errorf("first import NewBytes = %d, want at least the %d content bytes", d1.NewBytes, first.Size)
+	}
+
+	total := d2.NewBytes + d2.ExistingBytes
+	if d2.ExistingBytes < first.Size || d2.NewBytes*100 > total {
+		t.Errorf("second import is not ~100%% existing: %+v", *d2)
+	}
+	if d2.ExistingBlocks+d2.NewBlocks != d1.NewBlocks {
+		t.Errorf("second import counted %d blocks, first %d", d2.ExistingBlocks+d2.NewBlocks, d1.NewBlocks)
+	}
+}
+
+func TestImporter_DedupStats_AtomicCommit(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	dir := writeTree(t, stagingTree())
+	ctx := context.Background()
+
+	// Staged blocks must not be mistaken for blocks already in the blockstore
+	res, err := NewImporter(bs, dir).WithDedupStats(true).WithAtomicCommit(64 * 1024).Import(ctx)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if res.DedupStats == nil || res.DedupStats.ExistingBlocks != 0 || res.DedupStats.NewBlocks == 0 {
+		t.Errorf("DedupStats = %+v, want only new blocks", res.DedupStats)
+	}
+}
+
+func TestImporter_WithoutDedupStats(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	dir := writeTree(t, stagingTree())
+	tests := []struct {
+		name    string
+		imp     *Importer
+		enabled bool
+	}{
+		{name: "default", imp: NewImporter(bs, dir)},
+		{name: "WithoutDedupStats", imp: NewImporter(bs, dir).WithDedupStats(true).WithoutDedupStats()},
+		{name: "WithDedupStats(false)", imp: NewImporter(bs, dir).WithDedupStats(false)},
+		{name: "WithDedupStats(true)", imp: NewImporter(bs, dir).WithoutDedupStats().WithDedupStats(true), enabled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tt.imp.Import(context.Background())
+			if err != nil {
+				t.Fatalf("Import failed: %v", err)
+			}
+			if got := res.DedupStats != nil; got != tt.enabled {
+				t.Errorf("DedupStats = %+v, want set = %v", res.DedupStats, tt.enabled)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	uio "github.com/ipfs/boxo/ipld/unixfs/io"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// DirContent is an imported directory with the CID of its node, so that
+// the subtree below it can be extracted on its own.
+type DirContent struct {
+	Path string // Cleaned slash-separated path below the root ("" for the root)
+	Cid  string // CID of the directory node
+}
+
+// dirContents resolves the node of every directory recorded by addDir,
+// starting from root once MFS has been flushed. Directories are recorded in
+// depth-first pre-order, so the parent of each one is on the stack of nodes
+// resolved so far.
+func (imp *Importer) dirContents(ctx context.Context, root ipld.Node) ([]DirContent, error) {
+	if len(imp.dirPaths) == 0 {
+		return nil, nil
+	}
+
+	type frame struct {
+		path string
+		node ipld.Node
+	}
+	var stack []frame
+	out := make([]DirContent, 0, len(imp.dirPaths))
+	for _, p := range imp.dirPaths {
+		nd := root
+		if p != "" {
+			parent, name := path.Split(p)
+			parent = strings.TrimSuffix(parent, "/")
+			for len(stack) > 0 && stack[len(stack)-1].path != parent {
+				stack = stack[:len(stack)-1]
+			}
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("directory %s recorded before its parent", p)
+			}
+			dir, err := uio.NewDirectoryFromNode(imp.dagService, stack[len(stack)-1].node)
+			if err != nil {
+				return nil, err
+			}
+			if nd, err = dir.Find(ctx, name); err != nil {
+				return nil, fmt.Errorf("resolve directory %s: %w", p, err)
+			}
+		} else {
+			stack = stack[:0]
+		}
+		stack = append(stack, frame{path: p, node: nd})
+		out = append(out, DirContent{Path: p, Cid: nd.Cid().String()})
+	}
+	return out, nil
+}
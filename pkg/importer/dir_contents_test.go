@@ -0,0 +1,81 @@
+package importer
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// splitPath returns the names along the slash path p below the root.
+func splitPath(p string) []string {
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+func TestImporter_ContentCids(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	dir := writeTree(t, map[string][]byte{
+		"a.txt":             []byte("alpha"),
+		"src/main.go":       []byte("package main"),
+		"src/lib/util.go":   []byte("package lib"),
+		"src/lib/empty.txt": nil,
+		"docs/big.bin":      make([]byte, 3<<20),
+	})
+	cache := NewNodeCache(64)
+
+	// The second import links every node from the cache
+	for _, pass := range []string{"built", "cached"} {
+		imp := NewImporter(bs, dir).WithNodeCache(cache)
+		result, err := imp.Import(context.Background())
+		if err != nil {
+			t.Fatalf("%s: Import failed: %v", pass, err)
+		}
+
+		for _, c := range result.Contents {
+			if want := resolve(t, bs, result.RootCid, splitPath(c.Path)...).String(); c.Cid != want {
+				t.Errorf("%s: %s Cid = %q, want %s", pass, c.Path, c.Cid, want)
+			}
+		}
+
+		var paths []string
+		for _, d := range result.DirContents {
+			paths = append(paths, d.Path)
+			if want := resolve(t, bs, result.RootCid, splitPath(d.Path)...).String(); d.Cid != want {
+				t.Errorf("%s: directory %q Cid = %q, want %s", pass, d.Path, d.Cid, want)
+			}
+		}
+		if want := []string{"", "docs", "src", "src/lib"}; !reflect.DeepEqual(paths, want) {
+			t.Errorf("%s: DirContents paths = %q, want %q", pass, paths, want)
+		}
+	}
+	if stats := cache.Stats(); stats.Hits == 0 {
+		t.Errorf("Stats() = %+v, want the second import to hit the cache", stats)
+	}
+}
+
+func TestImporter_ContentCids_SingleFile(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	dir := writeTree(t, map[string][]byte{"only.txt": []byte("single")})
+	result, err := NewImporter(bs, filepath.Join(dir, "only.txt")).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if len(result.Contents) != 1 {
+		t.Fatalf("Contents = %+v, want one file", result.Contents)
+	}
+	c := result.Contents[0]
+	if want := resolve(t, bs, result.RootCid, splitPath(c.Path)...).String(); c.Cid != want {
+		t.Errorf("Cid = %q, want %s", c.Cid, want)
+	}
+	if len(result.DirContents) != 1 || result.DirContents[0].Cid != result.RootCid {
+		t.Errorf("DirContents = %+v, want the root only", result.DirContents)
+	}
+}
@@ -0,0 +1,110 @@
+package importer
+
+// DirStat summarizes a single directory of an import.
+type DirStat struct {
+	Path       string // Cleaned path relative to the import root ("" for the root)
+	Files      int    // Number of regular files directly in this directory
+	Dirs       int    // Number of directories directly in this directory
+	Size       int64  // Total size of all files below this directory
+	TotalFiles int    // Total number of files below this directory
+}
+
+// dirStatsCollector accumulates DirStat entries during the import walk.
+// Entries are recorded in depth-first pre-order; cumulative values are
+// propagated to the parent when a directory is finished.
+type dirStatsCollector struct {
+	stats    []DirStat
+	stack    []int // indexes into stats of the directories being walked
+	limit    int   // maximum number of directories to record (0 = unlimited)
+	disabled bool  // set once the limit is exceeded
+}
+
+// newDirStatsCollector creates a collector that gives up after limit directories.
+func newDirStatsCollector(limit int) *dirStatsCollector {
+	return &dirStatsCollector{limit: limit}
+}
+
+// enterDir starts a new directory below the current one.
+func (c *dirStatsCollector) enterDir(path string) {
+	if c == nil || c.disabled {
+		return
+	}
+
+	if c.limit > 0 && len(c.stats) >= c.limit {
+		c.disabled = true
+		c.stats = nil
+		c.stack = nil
+		return
+	}
+
+	if parent := c.current(); parent != nil {
+		parent.Dirs++
+	}
+
+	c.stack = append(c.stack, len(c.stats))
+	c.stats = append(c.stats, DirStat{Path: path})
+}
+
+// leaveDir finishes the current directory and adds its totals to its parent.
+func (c *dirStatsCollector) leaveDir() {
+	if c == nil || c.disabled || len(c.stack) == 0 {
+		return
+	}
+
+	done := c.stats[c.stack[len(c.stack)-1]]
+	c.stack = c.stack[:len(c.stack)-1]
+
+	if parent := c.current(); parent != nil {
+		parent.Size += done.Size
+		parent.TotalFiles += done.TotalFiles
+	}
+}
+
+// addFile records a regular file in the current directory.
+func (c *dirStatsCollector) addFile(size int64) {
+	if c == nil || c.disabled {
+		return
+	}
+
+	if dir := c.current(); dir != nil {
+		dir.Files++
+		dir.TotalFiles++
+		dir.Size += size
+	}
+}
+
+// current returns the directory currently being walked, or nil.
+func (c *dirStatsCollector) current() *DirStat {
+	if len(c.stack) == 0 {
+		return nil
+	}
+	return &c.stats[c.stack[len(c.stack)-1]]
+}
+
+// result returns the collected statistics, or nil if collection was disabled.
+func (c *dirStatsCollector) result() []DirStat {
+	if c == nil || c.disabled {
+		return nil
+	}
+	return c.stats
+}
+
+// WithDirectoryStats enables or disables per-directory statistics in
+// Result.Directories. When not called, statistics are collected for imports
+// of up to 100,000 directories and omitted for larger ones.
+// Returns the importer for method chaining.
+func (imp *Importer) WithDirectoryStats(enabled bool) *Importer {
+	imp.dirStats = &enabled
+	return imp
+}
+
+// newDirStats creates the collector for an import according to WithDirectoryStats.
+func (imp *Importer) newDirStats() *dirStatsCollector {
+	if imp.dirStats == nil {
+		return newDirStatsCollector(defaultDirStatsLimit)
+	}
+	if *imp.dirStats {
+		return newDirStatsCollector(0)
+	}
+	return nil
+}
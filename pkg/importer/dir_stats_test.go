@@ -0,0 +1,145 @@
+package importer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// createNestedFixture creates:
+//
+//	a.txt (5)
+//	sub/b.txt (10)
+//	sub/deep/c.txt (20)
+//	sub/deep/d.txt (30)
+//	other/ (empty)
+func createNestedFixture(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	files := map[string]int{
+		"a.txt":          5,
+		"sub/b.txt":      10,
+		"sub/deep/c.txt": 20,
+		"sub/deep/d.txt": 30,
+	}
+	for rel, size := range files {
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(strings.Repeat("x", size)), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", rel, err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "other"), 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+
+	return dir
+}
+
+func TestImporter_DirectoryStats(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	result, err := NewImporter(bs, createNestedFixture(t)).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	expected := []DirStat{
+		{Path: "", Files: 1, Dirs: 2, Size: 65, TotalFiles: 4},
+		{Path: "other", Files: 0, Dirs: 0, Size: 0, TotalFiles: 0},
+		{Path: "sub", Files: 1, Dirs: 1, Size: 60, TotalFiles: 3},
+		{Path: filepath.Join("sub", "deep"), Files: 2, Dirs: 0, Size: 50, TotalFiles: 2},
+	}
+
+	if len(result.Directories) != len(expected) {
+		t.Fatalf("got %d directories, want %d: %+v", len(result.Directories), len(expected), result.Directories)
+	}
+	for i, want := range expected {
+		if got := result.Directories[i]; got != want {
+			t.Errorf("Directories[%d] = %+v, want %+v", i, got, want)
+		}
+	}
+
+	if result.Directories[0].Size != result.Size {
+		t.Errorf("root size %d != Result.Size %d", result.Directories[0].Size, result.Size)
+	}
+	if result.Directories[0].TotalFiles != len(result.Contents) {
+		t.Errorf("root file count %d != len(Contents) %d", result.Directories[0].TotalFiles, len(result.Contents))
+	}
+}
+
+func TestImporter_DirectoryStats_SingleFile(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, []byte("single file"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	result, err := NewImporter(bs, path).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	if len(result.Directories) != 1 {
+		t.Fatalf("got %d directories, want 1", len(result.Directories))
+	}
+	root := result.Directories[0]
+	if root.Files != 1 || root.Size != result.Size || root.TotalFiles != 1 {
+		t.Errorf("unexpected root stats: %+v", root)
+	}
+}
+
+func TestImporter_WithDirectoryStats(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	dir := createNestedFixture(t)
+
+	result, err := NewImporter(bs, dir).WithDirectoryStats(false).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.Directories != nil {
+		t.Errorf("expected no directory stats, got %+v", result.Directories)
+	}
+
+	result, err = NewImporter(bs, dir).WithDirectoryStats(true).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if len(result.Directories) != 4 {
+		t.Errorf("expected 4 directory stats, got %d", len(result.Directories))
+	}
+}
+
+func TestDirStatsCollector_Limit(t *testing.T) {
+	c := newDirStatsCollector(2)
+
+	c.enterDir("")
+	c.enterDir("a")
+	c.leaveDir()
+	c.enterDir("b")
+	c.addFile(10)
+	c.leaveDir()
+	c.leaveDir()
+
+	if c.result() != nil {
+		t.Errorf("expected nil result after exceeding limit, got %+v", c.result())
+	}
+
+	var nilCollector *dirStatsCollector
+	nilCollector.enterDir("")
+	nilCollector.addFile(1)
+	nilCollector.leaveDir()
+	if nilCollector.result() != nil {
+		t.Error("nil collector should return nil result")
+	}
+}
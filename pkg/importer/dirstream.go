@@ -0,0 +1,308 @@
+package importer
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ipfs/boxo/files"
+)
+
+// WithDirBatchSize sets how many directory entries are read from disk at a
+// time. Directories are read in batches rather than all at once, so the
+// first entries of a directory with millions of files are imported without
+// waiting for the whole listing. Zero or a negative value uses the default
+// of 1024.
+// Returns the importer for method chaining.
+func (imp *Importer) WithDirBatchSize(n int) *Importer {
+	imp.dirBatch = max(n, 0)
+	return imp
+}
+
+// WithUnsortedDirs imports directory entries in the order the filesystem
+// returns them instead of sorted by name. By default the names of a
+// directory, but nothing else about its entries, are held in memory while
+// they are sorted; unsorted, memory for the listing is bounded by the
+// batch size no matter how large the directory is.
+//
+// The order files are read in, progress is reported in and
+// Result.Contents is listed in then depends on the filesystem, and the
+// RootCid is not guaranteed to match a sorted import of the same tree, so
+// imports of one tree are only comparable when they use the same mode.
+// Use it only for directories too large to list by name.
+// Returns the importer for method chaining.
+func (imp *Importer) WithUnsortedDirs(enabled bool) *Importer {
+	imp.unsorted = enabled
+	return imp
+}
+
+// batchSize returns the number of directory entries read per batch.
+func (imp *Importer) batchSize() int {
+	if imp.dirBatch > 0 {
+		return imp.dirBatch
+	}
+	return defaultDirBatchSize
+}
+
+// newStreamDir returns the directory at path, found with info by Lstat.
+func (imp *Importer) newStreamDir(path string, info os.FileInfo) *streamDir {
+	d := &streamDir{
+		path:     path,
+		info:     info,
+		batch:    imp.batchSize(),
+		sorted:   !imp.unsorted,
+		filter:   imp.filter,
+		symlinks: imp.symlinks,
+		warn:     imp.warn,
+	}
+	if imp.symlinks == SymlinkFollow {
+		d.links = newLinkWalk(path)
+	}
+	return d
+}
+
+// streamDir is a filesystem directory whose entries are read in batches
+// as they are iterated. Entries left out by the filter are skipped.
+type streamDir struct {
+	path   string       // Filesystem path of the directory
+	rel    string       // Slash path below the import root, "" for the root
+	info   os.FileInfo  // Mode and modification time of the directory
+	batch  int          // Entries read per batch
+	sorted bool         // Iterate entries sorted by name
+	filter *entryFilter // Hidden and ignored entries to leave out
+	open   *os.File     // Directory handle of an unsorted iteration in progress
+
+	symlinks SymlinkPolicy // How symlinks among the entries are added
+	links    *linkWalk     // Position in a walk following symlinks; nil unless SymlinkFollow
+	warn     func(error)   // Reports symlinks that cannot be followed
+}
+
+// child returns the subdirectory name, found with info by Lstat.
+func (d *streamDir) child(name string, info os.FileInfo) *streamDir {
+	return &streamDir{
+		path:     filepath.Join(d.path, name),
+		rel:      path.Join(d.rel, name),
+		info:     info,
+		batch:    d.batch,
+		sorted:   d.sorted,
+		filter:   d.filter,
+		symlinks: d.symlinks,
+		links:    d.links.child(name, ""),
+		warn:     d.warn,
+	}
+}
+
+// linkedChild returns the directory real, found with info by Stat, that
+// the symlink name points to.
+func (d *streamDir) linkedChild(name, real string, info os.FileInfo) *streamDir {
+	c := d.child(name, info)
+	c.path = real
+	c.links = d.links.child(name, real)
+	return c
+}
+
+func (d *streamDir) Close() error {
+	if d.open == nil {
+		return nil
+	}
+	err := d.open.Close()
+	d.open = nil
+	return err
+}
+
+func (d *streamDir) Mode() os.FileMode  { return d.info.Mode() }
+func (d *streamDir) ModTime() time.Time { return d.info.ModTime() }
+
+// Size returns the total size of the regular files below the directory
+// that are not left out.
+func (d *streamDir) Size() (int64, error) {
+	var total int64
+	err := readNames(d.path, d.batch, d.filter, func(names []string) error {
+		for _, name := range names {
+			info, err := os.Lstat(filepath.Join(d.path, name))
+			if err != nil {
+				return err
+			}
+			if d.filter.ignored(path.Join(d.rel, name), info.IsDir()) {
+				continue
+			}
+			if info.Mode()&os.ModeSymlink != 0 && d.symlinks == SymlinkFollow {
+				real, target, err := d.links.resolve(filepath.Join(d.path, name))
+				if err != nil {
+					// Reported when the entry is imported
+					continue
+				}
+				if target.IsDir() {
+					n, err := d.linkedChild(name, real, target).Size()
+					if err != nil {
+						return err
+					}
+					total += n
+				} else if target.Mode().IsRegular() {
+					total += target.Size()
+				}
+				continue
+			}
+			switch {
+			case info.IsDir():
+				n, err := d.child(name, info).Size()
+				if err != nil {
+					return err
+				}
+				total += n
+			case info.Mode().IsRegular():
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total, err
+}
+
+func (d *streamDir) Entries() files.DirIterator {
+	return &streamIterator{dir: d}
+}
+
+// streamIterator iterates over the entries of a streamDir. A sorted
+// iteration lists all names first; an unsorted one reads the next batch
+// of names whenever the previous one is used up.
+type streamIterator struct {
+	dir     *streamDir
+	started bool
+	names   []string // Names not yet iterated
+	done    bool     // No more names to read from disk
+	name    string
+	node    files.Node
+	err     error
+}
+
+func (it *streamIterator) Name() string     { return it.name }
+func (it *streamIterator) Node() files.Node { return it.node }
+func (it *streamIterator) Err() error       { return it.err }
+
+func (it *streamIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if !it.started {
+		it.started = true
+		if err := it.start(); err != nil {
+			it.err = err
+			return false
+		}
+	}
+
+	for {
+		for len(it.names) == 0 {
+			if it.done {
+				return false
+			}
+			if err := it.readBatch(); err != nil {
+				it.err = err
+				return false
+			}
+		}
+
+		name := it.names[0]
+		it.names[0] = "" // Let the name be collected once the batch is used up
+		it.names = it.names[1:]
+
+		full := filepath.Join(it.dir.path, name)
+		info, err := os.Lstat(full)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		if it.dir.filter.ignored(path.Join(it.dir.rel, name), info.IsDir()) {
+			continue
+		}
+		var node files.Node
+		if info.Mode()&os.ModeSymlink != 0 && it.dir.symlinks != SymlinkPreserve {
+			if it.dir.symlinks == SymlinkSkip {
+				continue
+			}
+			if node, err = it.dir.followLink(name, full); err != nil {
+				it.err = err
+				return false
+			}
+			if node == nil {
+				continue
+			}
+		} else if info.IsDir() {
+			node = it.dir.child(name, info)
+		} else if node, err = files.NewSerialFile(full, false, info); err != nil {
+			it.err = err
+			return false
+		}
+		it.name, it.node = name, node
+		return true
+	}
+}
+
+// start lists all names of a sorted iteration, or opens the directory for
+// an unsorted one.
+func (it *streamIterator) start() error {
+	d := it.dir
+	if !d.sorted {
+		f, err := os.Open(d.path)
+		if err != nil {
+			return err
+		}
+		d.open = f
+		return nil
+	}
+
+	err := readNames(d.path, d.batch, d.filter, func(names []string) error {
+		it.names = append(it.names, names...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(it.names)
+	it.done = true
+	return nil
+}
+
+// readBatch reads the next batch of names of an unsorted iteration.
+func (it *streamIterator) readBatch() error {
+	names, err := it.dir.open.Readdirnames(it.dir.batch)
+	it.names = it.dir.filter.visibleNames(names)
+	if errors.Is(err, io.EOF) {
+		it.done = true
+		return it.dir.Close()
+	}
+	return err
+}
+
+// readNames calls fn with the names of the entries of the directory at
+// path that filter does not leave out by name, at most batch names at a
+// time.
+func readNames(path string, batch int, filter *entryFilter, fn func(names []string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	for {
+		names, err := f.Readdirnames(batch)
+		if len(names) > 0 {
+			if err := fn(filter.visibleNames(names)); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
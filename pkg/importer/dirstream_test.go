@@ -0,0 +1,170 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"testing"
+
+	"github.com/ipfs/boxo/files"
+)
+
+func TestImporter_WithDirBatchSize_SameRootCid(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	dir := createScanFixture(t)
+	for i := 0; i < 50; i++ {
+		if err := os.WriteFile(filepath.Join(dir, "sub", fmt.Sprintf("n%02d.txt", i)), []byte{byte(i)}, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The prescanned listing does not read directories at import time
+	entries, err := ScanEntries(dir)
+	if err != nil {
+		t.Fatalf("ScanEntries failed: %v", err)
+	}
+	listed, err := NewImporter(bs, dir).WithPrescannedEntries(entries).Import(ctx)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	for _, batch := range []int{0, 1, 3, 1000} {
+		result, err := NewImporter(bs, dir).WithDirBatchSize(batch).Import(ctx)
+		if err != nil {
+			t.Fatalf("Import with batch size %d failed: %v", batch, err)
+		}
+		if result.RootCid != listed.RootCid {
+			t.Errorf("batch size %d: RootCid = %s, want %s", batch, result.RootCid, listed.RootCid)
+		}
+		if len(result.Contents) != len(listed.Contents) {
+			t.Fatalf("batch size %d: %d contents, want %d", batch, len(result.Contents), len(listed.Contents))
+		}
+		for i := range result.Contents {
+			if result.Contents[i].Path != listed.Contents[i].Path {
+				t.Errorf("batch size %d: content %d = %s, want %s", batch, i, result.Contents[i].Path, listed.Contents[i].Path)
+			}
+		}
+	}
+}
+
+func TestImporter_WithUnsortedDirs(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	dir := createScanFixture(t)
+
+	sorted, err := NewImporter(bs, dir).Import(ctx)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	unsorted, err := NewImporter(bs, dir).WithUnsortedDirs(true).WithDirBatchSize(2).Import(ctx)
+	if err != nil {
+		t.Fatalf("unsorted Import failed: %v", err)
+	}
+
+	paths := func(r *Result) []string {
+		var out []string
+		for _, c := range r.Contents {
+			out = append(out, c.Path)
+		}
+		sort.Strings(out)
+		return out
+	}
+	want, got := paths(sorted), paths(unsorted)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("unsorted import has contents %v, want %v", got, want)
+	}
+	if unsorted.Size != sorted.Size {
+		t.Errorf("unsorted import size = %d, want %d", unsorted.Size, sorted.Size)
+	}
+}
+
+// retainedByIterator returns how many heap bytes an iterator over dir holds
+// after its first entry, and how many entries it yields in total.
+func retainedByIterator(t *testing.T, dir files.Directory) (uint64, int) {
+	t.Helper()
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	it := dir.Entries()
+	if !it.Next() {
+		t.Fatalf("empty listing: %v", it.Err())
+	}
+	_ = it.Node().Close()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	n := 1
+	for it.Next() {
+		_ = it.Node().Close()
+		n++
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("iteration failed: %v", err)
+	}
+	_ = dir.Close()
+
+	if after.HeapAlloc < before.HeapAlloc {
+		return 0, n
+	}
+	return after.HeapAlloc - before.HeapAlloc, n
+}
+
+func TestStreamDir_BoundedMemory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("creates 200k files")
+	}
+
+	const entries = 200000
+	dir := t.TempDir()
+	for i := 0; i < entries; i++ {
+		f, err := os.Create(filepath.Join(dir, fmt.Sprintf("f%06d", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = f.Close()
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serial, err := files.NewSerialFile(dir, false, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, n := retainedByIterator(t, serial.(files.Directory))
+	if n != entries {
+		t.Fatalf("full listing yielded %d entries, want %d", n, entries)
+	}
+
+	imp := NewImporter(nil, dir)
+	sorted, n := retainedByIterator(t, imp.newStreamDir(dir, info))
+	if n != entries {
+		t.Fatalf("sorted listing yielded %d entries, want %d", n, entries)
+	}
+
+	imp.WithUnsortedDirs(true)
+	unsorted, n := retainedByIterator(t, imp.newStreamDir(dir, info))
+	if n != entries {
+		t.Fatalf("unsorted listing yielded %d entries, want %d", n, entries)
+	}
+
+	t.Logf("retained after the first entry: full %d, sorted %d, unsorted %d bytes", full, sorted, unsorted)
+	if sorted >= full {
+		t.Errorf("sorted listing retains %d bytes, no less than the full listing's %d", sorted, full)
+	}
+	// A batch of names, independent of the directory size
+	if unsorted > 1<<20 {
+		t.Errorf("unsorted listing retains %d bytes, want at most 1MB", unsorted)
+	}
+}
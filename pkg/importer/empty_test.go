@@ -0,0 +1,108 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/go-cid"
+)
+
+func TestImporter_EmptyFilesAndDirs(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	tree := map[string][]byte{"data.txt": []byte("not empty")}
+	var wantFiles []string
+	for i := 0; i < 100; i++ {
+		rel := fmt.Sprintf("gen/%02d/f%03d.o", i%4, i)
+		tree[rel] = nil
+		wantFiles = append(wantFiles, rel)
+	}
+	dir := writeTree(t, tree)
+
+	var wantDirs []string
+	for i := 0; i < 10; i++ {
+		rel := fmt.Sprintf("empty/d%d", i)
+		if err := os.MkdirAll(filepath.Join(dir, rel), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		wantDirs = append(wantDirs, rel)
+	}
+
+	res, err := NewImporter(bs, dir).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	gotFiles := append([]string(nil), res.EmptyFiles...)
+	sort.Strings(gotFiles)
+	sort.Strings(wantFiles)
+	if !reflect.DeepEqual(gotFiles, wantFiles) {
+		t.Errorf("EmptyFiles = %v, want %v", res.EmptyFiles, wantFiles)
+	}
+	gotDirs := append([]string(nil), res.EmptyDirs...)
+	sort.Strings(gotDirs)
+	if !reflect.DeepEqual(gotDirs, wantDirs) {
+		t.Errorf("EmptyDirs = %v, want %v", res.EmptyDirs, wantDirs)
+	}
+
+	// Every block, including the one shared by all empty files, is listed once
+	seen := make(map[string]int)
+	for _, pkg := range res.Packages {
+		for _, b := range pkg.Blocks {
+			seen[b]++
+		}
+	}
+	for b, n := range seen {
+		if n != 1 {
+			t.Errorf("block %s listed %d times", b, n)
+		}
+	}
+
+	emptyFile := resolve(t, bs, res.RootCid, "gen", "00", "f000.o")
+	emptyDir := resolve(t, bs, res.RootCid, "empty", "d0")
+	if seen[emptyFile.String()] != 1 {
+		t.Errorf("empty file block %s listed %d times, want 1", emptyFile, seen[emptyFile.String()])
+	}
+	if seen[emptyDir.String()] != 1 {
+		t.Errorf("empty directory block %s listed %d times, want 1", emptyDir, seen[emptyDir.String()])
+	}
+	if other := resolve(t, bs, res.RootCid, "gen", "03", "f099.o"); other != emptyFile {
+		t.Errorf("empty files have different blocks: %s and %s", emptyFile, other)
+	}
+}
+
+// resolve returns the CID of the entry at the path given by names below root.
+func resolve(t *testing.T, bs blockstore.Blockstore, root string, names ...string) cid.Cid {
+	t.Helper()
+
+	c, err := cid.Decode(root)
+	if err != nil {
+		t.Fatalf("invalid root %q: %v", root, err)
+	}
+	dag := merkledag.NewDAGService(blockservice.New(bs, nil))
+	for _, name := range names {
+		nd, err := dag.Get(context.Background(), c)
+		if err != nil {
+			t.Fatalf("Get(%s) failed: %v", c, err)
+		}
+		dir, ok := nd.(*merkledag.ProtoNode)
+		if !ok {
+			t.Fatalf("%s is not a directory", c)
+		}
+		link, err := dir.GetNodeLink(name)
+		if err != nil {
+			t.Fatalf("no entry %q: %v", name, err)
+		}
+		c = link.Cid
+	}
+	return c
+}
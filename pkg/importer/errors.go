@@ -17,6 +17,53 @@ var (
 
 	// ErrMfsRootNil is returned when MFS root initialization fails
 	ErrMfsRootNil = errors.New("mfs root is nil")
+
+	// ErrReservedName is returned when the source root contains an entry
+	// named like the extended attribute metadata file
+	ErrReservedName = errors.New("name is reserved for extended attribute metadata")
+
+	// ErrScanIgnored is reported in Result.Warnings and as a Warning event
+	// when a report supplied by WithScan was produced for a different path
+	ErrScanIgnored = errors.New("scan report ignored")
+
+	// ErrStaleEntry is wrapped by StaleEntryError when a prescanned entry no
+	// longer matches the filesystem
+	ErrStaleEntry = errors.New("prescanned entry is stale")
+
+	// ErrInvalidListing is returned when a listing supplied by
+	// WithPrescannedEntries is not depth-first below the import root
+	ErrInvalidListing = errors.New("invalid prescanned listing")
+
+	// ErrInvalidChunker is returned when a profile set with
+	// WithChunkerProfile chooses an invalid chunker specification
+	ErrInvalidChunker = errors.New("invalid chunker specification")
+
+	// ErrInvalidChunkSize is returned when the size set with WithChunkSize
+	// is outside MinChunkSize to MaxChunkSize
+	ErrInvalidChunkSize = errors.New("invalid chunk size")
+
+	// ErrInvalidCIDVersion is returned when the version set with
+	// WithCIDVersion is not 0 or 1, or is 0 with a hash function other than
+	// sha2-256
+	ErrInvalidCIDVersion = errors.New("invalid CID version")
+
+	// ErrUnsupportedHashFunc is returned when the name set with WithHashFunc
+	// is not a supported multihash function
+	ErrUnsupportedHashFunc = errors.New("unsupported hash function")
+
+	// ErrInvalidIgnorePattern is returned for a malformed or unsupported
+	// pattern set with WithIgnore
+	ErrInvalidIgnorePattern = errors.New("invalid ignore pattern")
+
+	// ErrSymlinkCycle is wrapped in the *ImportError reported in
+	// Result.Warnings for a symlink left out by SymlinkFollow because it
+	// points to a directory being imported above it
+	ErrSymlinkCycle = errors.New("symlink points to an enclosing directory")
+
+	// ErrInvalidTarEntry is returned by ImportTarStream for an archive entry
+	// that cannot be imported: a hard link, a device or a path leaving the
+	// archive root
+	ErrInvalidTarEntry = errors.New("unsupported tar entry")
 )
 
 // ImportError represents an error during import with context
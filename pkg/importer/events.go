@@ -0,0 +1,126 @@
+package importer
+
+// eventBufferSize is the capacity of the channel returned by Events.
+const eventBufferSize = 64
+
+// ImportEvent is an event sent on the channel returned by Events. It is one
+// of FileStarted, Progress, FileCompleted, PhaseChanged, Warning, Done or
+// Failed.
+type ImportEvent interface {
+	importEvent()
+}
+
+// Phase is a stage of an import, reported by PhaseChanged.
+type Phase string
+
+const (
+	PhaseScanning   Phase = "scanning"   // Computing the total size
+	PhaseImporting  Phase = "importing"  // Reading and chunking files
+	PhasePackaging  Phase = "packaging"  // Flushing the DAG and building packages
+	PhaseCommitting Phase = "committing" // Writing staged blocks (atomic imports only)
+	PhaseDone       Phase = "done"       // Import returned; only reported by WithProgressEvents
+)
+
+// FileStarted is sent before a file is read.
+type FileStarted struct {
+	Path string // Cleaned slash-separated path below the root; empty for a single-file import
+	Size int64  // File size in bytes; -1 for a reader of unknown size
+}
+
+// Progress reports the bytes imported so far, like the WithProgress callback.
+// Progress events may be dropped, see Events.
+type Progress struct {
+	Completed int64  // Bytes imported so far
+	Total     int64  // Total bytes to import
+	File      string // Cleaned name of the file being read
+}
+
+// FileCompleted is sent after a file has been added to the DAG.
+type FileCompleted struct {
+	Content Content // The file's entry in Result.Contents
+}
+
+// PhaseChanged is sent when the import enters a new phase.
+type PhaseChanged struct {
+	Phase Phase
+}
+
+// Warning reports a non-fatal problem, such as an ignored scan report.
+type Warning struct {
+	Err error
+}
+
+// Done is the last event of a successful import.
+type Done struct {
+	Result *Result
+}
+
+// Failed is the last event of a failed import.
+type Failed struct {
+	Err error
+}
+
+func (FileStarted) importEvent()   {}
+func (Progress) importEvent()      {}
+func (FileCompleted) importEvent() {}
+func (PhaseChanged) importEvent()  {}
+func (Warning) importEvent()       {}
+func (Done) importEvent()          {}
+func (Failed) importEvent()        {}
+
+// Events returns a channel receiving the events of the next Import, as an
+// alternative or in addition to WithProgress. The channel is buffered and
+// closed when Import returns, after a final Done or Failed event; call Events
+// again before each Import.
+//
+// Progress events are sent without blocking and dropped while the buffer is
+// full, so a slow consumer sees them sparsely. All other events are never
+// dropped: sending them blocks until the consumer makes room, so the channel
+// must be drained until it is closed.
+func (imp *Importer) Events() <-chan ImportEvent {
+	imp.events = &eventStream{ch: make(chan ImportEvent, eventBufferSize)}
+	return imp.events.ch
+}
+
+// eventStream sends the events of one import. A nil stream discards them.
+type eventStream struct {
+	ch chan ImportEvent
+}
+
+// send delivers a lifecycle event, blocking while the buffer is full.
+func (s *eventStream) send(ev ImportEvent) {
+	if s == nil {
+		return
+	}
+	s.ch <- ev
+}
+
+// progress delivers a Progress event unless the buffer is full.
+func (s *eventStream) progress(completed, total int64, file string) {
+	if s == nil {
+		return
+	}
+	select {
+	case s.ch <- Progress{Completed: completed, Total: total, File: file}:
+	default:
+	}
+}
+
+// finish sends Done or Failed and closes the channel.
+func (s *eventStream) finish(result *Result, err error) {
+	if s == nil {
+		return
+	}
+	if err != nil {
+		s.send(Failed{Err: err})
+	} else {
+		s.send(Done{Result: result})
+	}
+	close(s.ch)
+}
+
+// phase announces the next phase of the running import.
+func (imp *Importer) phase(p Phase) {
+	imp.events.send(PhaseChanged{Phase: p})
+	imp.tracker.setPhase(p)
+}
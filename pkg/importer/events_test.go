@@ -0,0 +1,208 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// collectEvents drains events, sleeping after each one to simulate a slow
+// consumer, and returns them once the channel is closed.
+func collectEvents(events <-chan ImportEvent, delay time.Duration) <-chan []ImportEvent {
+	out := make(chan []ImportEvent, 1)
+	go func() {
+		var all []ImportEvent
+		for ev := range events {
+			all = append(all, ev)
+			time.Sleep(delay)
+		}
+		out <- all
+	}()
+	return out
+}
+
+// lifecycle returns events without the Progress events.
+func lifecycle(events []ImportEvent) []ImportEvent {
+	var out []ImportEvent
+	for _, ev := range events {
+		if _, ok := ev.(Progress); !ok {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+func TestImporter_Events(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	tree := map[string][]byte{
+		"a.bin":     make([]byte, 2*1024*1024),
+		"b.txt":     []byte("small"),
+		"dir/c.bin": make([]byte, 1024*1024),
+	}
+	dir := writeTree(t, tree)
+
+	callbacks := 0
+	imp := NewImporter(bs, dir).WithProgress(func(completed, total int64, file string) {
+		callbacks++
+	})
+	done := collectEvents(imp.Events(), time.Millisecond)
+
+	result, err := imp.Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	var events []ImportEvent
+	select {
+	case events = <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("event channel was not closed")
+	}
+
+	// Lifecycle events arrive completely and in order
+	var want []ImportEvent
+	want = append(want, PhaseChanged{Phase: PhaseScanning}, PhaseChanged{Phase: PhaseImporting})
+	for _, c := range result.Contents {
+		want = append(want, FileStarted{Path: c.Path, Size: c.Size}, FileCompleted{Content: c})
+	}
+	want = append(want, PhaseChanged{Phase: PhasePackaging}, Done{Result: result})
+	if got := lifecycle(events); !reflect.DeepEqual(got, want) {
+		t.Errorf("lifecycle events = %+v, want %+v", got, want)
+	}
+	if len(result.Contents) != len(tree) {
+		t.Errorf("imported %d files, want %d", len(result.Contents), len(tree))
+	}
+
+	// Progress events may be sparse but never go backwards
+	var progress int
+	var last int64
+	for _, ev := range events {
+		p, ok := ev.(Progress)
+		if !ok {
+			continue
+		}
+		if p.Completed < last || p.Total != result.Size {
+			t.Errorf("progress event %+v after %d of %d", p, last, result.Size)
+		}
+		last = p.Completed
+		progress++
+	}
+	if callbacks == 0 {
+		t.Error("the WithProgress callback was not called alongside the event channel")
+	}
+	if progress > callbacks {
+		t.Errorf("%d progress events for %d callbacks", progress, callbacks)
+	}
+	t.Logf("%d progress events, %d callbacks", progress, callbacks)
+}
+
+func TestImporter_Events_Failed(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	imp := NewImporter(bs, filepath.Join(t.TempDir(), "missing"))
+	done := collectEvents(imp.Events(), 0)
+
+	_, err := imp.Import(context.Background())
+	if err == nil {
+		t.Fatal("Import of a missing path succeeded")
+	}
+
+	events := <-done
+	if len(events) == 0 {
+		t.Fatal("no events")
+	}
+	failed, ok := events[len(events)-1].(Failed)
+	if !ok || !errors.Is(failed.Err, err) {
+		t.Errorf("last event = %+v, want Failed with %v", events[len(events)-1], err)
+	}
+}
+
+func TestImporter_Events_ScanIgnored(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	other, err := NewImporter(bs, writeTree(t, map[string][]byte{"x.txt": []byte("x")})).Scan(ctx)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	imp := NewImporter(bs, writeTree(t, map[string][]byte{"y.txt": []byte("y")})).WithScan(other)
+	done := collectEvents(imp.Events(), 0)
+	if _, err := imp.Import(ctx); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	var warnings []error
+	for _, ev := range <-done {
+		if w, ok := ev.(Warning); ok {
+			warnings = append(warnings, w.Err)
+		}
+	}
+	if len(warnings) != 1 || !errors.Is(warnings[0], ErrScanIgnored) {
+		t.Errorf("warnings = %v, want one wrapping ErrScanIgnored", warnings)
+	}
+}
+
+func TestImporter_WithProgressEvents(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	tree := map[string][]byte{
+		"a.bin":     make([]byte, 2*1024*1024),
+		"b.txt":     []byte("small"),
+		"dir/c.bin": make([]byte, 1024*1024),
+	}
+	var events []ProgressEvent
+	imp := NewImporter(bs, writeTree(t, tree)).WithProgressEvents(func(ev ProgressEvent) {
+		events = append(events, ev)
+	})
+	result, err := imp.Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if len(events) == 0 {
+		t.Fatal("no progress events")
+	}
+
+	var phases []Phase
+	var completed int64
+	files := 0
+	for _, ev := range events {
+		if ev.CurrentFile == "" {
+			phases = append(phases, ev.Phase)
+		}
+		if ev.CompletedBytes < completed {
+			t.Errorf("CompletedBytes went back from %d to %d", completed, ev.CompletedBytes)
+		}
+		completed = ev.CompletedBytes
+		if ev.CurrentFile != "" && ev.CurrentFileBytes > ev.CurrentFileTotal {
+			t.Errorf("%s: %d of %d bytes", ev.CurrentFile, ev.CurrentFileBytes, ev.CurrentFileTotal)
+		}
+		if ev.FilesCompleted > files {
+			// A file is reported completed with all of its bytes
+			if ev.CurrentFileBytes != ev.CurrentFileTotal {
+				t.Errorf("%s completed with %d of %d bytes", ev.CurrentFile, ev.CurrentFileBytes, ev.CurrentFileTotal)
+			}
+			files = ev.FilesCompleted
+		}
+	}
+	wantPhases := []Phase{PhaseScanning, PhaseImporting, PhasePackaging, PhaseDone}
+	if !reflect.DeepEqual(phases, wantPhases) {
+		t.Errorf("phases = %v, want %v", phases, wantPhases)
+	}
+
+	last := events[len(events)-1]
+	if last.Phase != PhaseDone || last.CompletedBytes != result.Size || last.TotalBytes != result.Size {
+		t.Errorf("last event = %+v, want PhaseDone with %d bytes", last, result.Size)
+	}
+	if last.FilesCompleted != len(tree) {
+		t.Errorf("FilesCompleted = %d, want %d", last.FilesCompleted, len(tree))
+	}
+}
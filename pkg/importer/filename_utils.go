@@ -1,20 +1,42 @@
 package importer
 
 import (
-	"path/filepath"
+	"encoding/hex"
+	"unicode/utf8"
 
 	"github.com/tragoedia0722/repository/pkg/helper"
 )
 
+// repairName turns a source name that is not valid UTF-8 into valid UTF-8
+// before it is cleaned: latin-1 names are transcoded and any other invalid
+// bytes are replaced with U+FFFD, see helper.RepairUTF8.
+func repairName(original string) string {
+	repaired, _ := helper.RepairUTF8(original)
+	return repaired
+}
+
+// noteRawName records the source name of the entry at the cleaned path if it
+// was not valid UTF-8, for Content.OriginalNameRaw.
+func (imp *Importer) noteRawName(path, original string) {
+	if utf8.ValidString(original) {
+		return
+	}
+	if imp.rawNames == nil {
+		imp.rawNames = make(map[string]string)
+	}
+	imp.rawNames[path] = hex.EncodeToString([]byte(original))
+}
+
 // cleanFilename cleans a filename and provides a fallback if empty.
 // It preserves the file extension if the cleaned filename is empty.
 func cleanFilename(original string) string {
+	original = repairName(original)
 	cleaned := helper.CleanFilename(original)
 	if cleaned != "" {
 		return cleaned
 	}
 
-	ext := filepath.Ext(original)
+	_, ext := helper.SplitExt(original)
 	if ext != "" {
 		return defaultFileName + ext
 	}
@@ -23,7 +45,7 @@ func cleanFilename(original string) string {
 
 // cleanDirname cleans a directory name and provides a fallback if empty.
 func cleanDirname(original string) string {
-	cleaned := helper.CleanFilename(original)
+	cleaned := helper.CleanFilename(repairName(original))
 	if cleaned != "" {
 		return cleaned
 	}
@@ -33,6 +55,7 @@ func cleanDirname(original string) string {
 // cleanEntryName cleans a directory entry name, handling both files and directories.
 // If isDir is true, it uses the directory fallback; otherwise uses the file fallback.
 func cleanEntryName(original string, isDir bool) string {
+	original = repairName(original)
 	cleaned := helper.CleanFilename(original)
 	if cleaned != "" {
 		return cleaned
@@ -42,7 +65,7 @@ func cleanEntryName(original string, isDir bool) string {
 		return defaultDirName
 	}
 
-	ext := filepath.Ext(original)
+	_, ext := helper.SplitExt(original)
 	if ext != "" {
 		return defaultFileName + ext
 	}
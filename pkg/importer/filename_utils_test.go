@@ -0,0 +1,91 @@
+package importer
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/tragoedia0722/repository/pkg/extractor"
+)
+
+func TestImporter_Import_NonUTF8Names(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("filenames are arbitrary bytes only on Linux")
+	}
+
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	src := t.TempDir()
+	sub := filepath.Join(src, "d\xe9j\xe0")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	raw := map[string]string{
+		"caf\xe9.txt":  "café.txt",
+		"a\x81b.txt":   "a�b.txt",
+		"valid é.txt":  "valid é.txt",
+		"d\xe9j\xe0/x": "x",
+	}
+	for name := range raw {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := NewImporter(bs, src).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	for _, c := range result.Contents {
+		if !utf8.ValidString(c.Name) || !utf8.ValidString(c.Path) {
+			t.Errorf("content %q at %q is not valid UTF-8", c.Name, c.Path)
+		}
+		var want string
+		for original, repaired := range raw {
+			if repaired == c.Name {
+				want = original
+			}
+		}
+		if want == "" {
+			t.Errorf("unexpected content %q", c.Name)
+			continue
+		}
+		wantRaw := ""
+		if !utf8.ValidString(filepath.Base(want)) {
+			wantRaw = hex.EncodeToString([]byte(filepath.Base(want)))
+		}
+		if c.OriginalNameRaw != wantRaw {
+			t.Errorf("%s: OriginalNameRaw = %q, want %q", c.Name, c.OriginalNameRaw, wantRaw)
+		}
+	}
+	if len(result.Contents) != len(raw) {
+		t.Errorf("imported %d files, want %d", len(result.Contents), len(raw))
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("Result does not marshal: %v", err)
+	}
+	if !utf8.Valid(data) {
+		t.Error("marshaled Result is not valid UTF-8")
+	}
+
+	out := filepath.Join(t.TempDir(), "out")
+	if err := extractor.NewExtractor(bs, result.RootCid, out).Extract(context.Background(), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(out, "déjà", "x"))
+	if err != nil {
+		t.Fatalf("repaired directory was not extracted: %v", err)
+	}
+	if string(got) != "d\xe9j\xe0/x" {
+		t.Errorf("extracted %q", got)
+	}
+}
@@ -0,0 +1,146 @@
+package importer
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// WithIgnore leaves out entries matching any of patterns, which follow a
+// subset of the gitignore syntax:
+//
+//   - a pattern without a slash, such as "*.tmp", matches the name of an
+//     entry at any depth;
+//   - a pattern containing a slash, such as "docs/*.md" or "/build",
+//     matches the whole path below the import root, and a leading "**/"
+//     makes it match at any depth instead;
+//   - a trailing slash, as in ".git/" or "node_modules/", matches
+//     directories only;
+//   - empty patterns and patterns starting with "#" are ignored.
+//
+// Wildcards are those of path.Match and never match a slash. Negated
+// patterns ("!") are not supported. An ignored directory is left out with
+// everything below it. Patterns are matched against the source paths,
+// before filename cleaning.
+//
+// Ignored entries are not counted in the progress total and do not appear
+// in Result.Contents; Scan reports them with SkipReasonIgnored. The root of
+// the import is never ignored. An invalid pattern fails the import with
+// ErrInvalidIgnorePattern.
+// Returns the importer for method chaining.
+func (imp *Importer) WithIgnore(patterns []string) *Importer {
+	imp.ignore = append([]string(nil), patterns...)
+	return imp
+}
+
+// WithSkipHidden sets whether hidden entries, whose names start with a dot,
+// are left out. They are by default; WithSkipHidden(false) imports them.
+// ScanEntries always leaves hidden entries out of a prescanned listing.
+// Returns the importer for method chaining.
+func (imp *Importer) WithSkipHidden(skip bool) *Importer {
+	imp.showHidden = !skip
+	return imp
+}
+
+// entryFilter decides which entries below the import root are left out.
+// A nil filter leaves out hidden entries only.
+type entryFilter struct {
+	showHidden bool         // Keep entries whose names start with a dot
+	rules      []ignoreRule // Patterns set with WithIgnore
+}
+
+// ignoreRule is a parsed WithIgnore pattern.
+type ignoreRule struct {
+	pattern  string // path.Match pattern without the leading and trailing slash
+	anchored bool   // Match the whole path instead of the name
+	dirOnly  bool   // Match directories only
+}
+
+// newEntryFilter parses the patterns of the importer.
+func (imp *Importer) newEntryFilter() (*entryFilter, error) {
+	f := &entryFilter{showHidden: imp.showHidden}
+	for _, orig := range imp.ignore {
+		p := strings.TrimSpace(orig)
+		if p == "" || strings.HasPrefix(p, "#") {
+			continue
+		}
+		if strings.HasPrefix(p, "!") {
+			return nil, fmt.Errorf("%w: %q: negation is not supported", ErrInvalidIgnorePattern, orig)
+		}
+
+		var rule ignoreRule
+		if trimmed, ok := strings.CutSuffix(p, "/"); ok {
+			rule.dirOnly = true
+			p = trimmed
+		}
+		if trimmed, ok := strings.CutPrefix(p, "**/"); ok {
+			p = trimmed
+		} else if strings.Contains(p, "/") {
+			rule.anchored = true
+			p = strings.TrimPrefix(p, "/")
+		}
+		if p == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidIgnorePattern, orig)
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("%w: %q: %w", ErrInvalidIgnorePattern, orig, err)
+		}
+		rule.pattern = p
+		f.rules = append(f.rules, rule)
+	}
+	return f, nil
+}
+
+// skipName reports whether an entry is left out by its name alone.
+func (f *entryFilter) skipName(name string) bool {
+	return (f == nil || !f.showHidden) && strings.HasPrefix(name, ".")
+}
+
+// visibleNames removes the names f leaves out by name alone from names in
+// place.
+func (f *entryFilter) visibleNames(names []string) []string {
+	visible := names[:0]
+	for _, name := range names {
+		if !f.skipName(name) {
+			visible = append(visible, name)
+		}
+	}
+	return visible
+}
+
+// ignored reports whether a WithIgnore pattern matches the entry at the
+// slash path rel below the import root.
+func (f *entryFilter) ignored(rel string, isDir bool) bool {
+	if f == nil {
+		return false
+	}
+	for _, r := range f.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		target := path.Base(rel)
+		if r.anchored {
+			target = rel
+		}
+		if ok, _ := path.Match(r.pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// skip reports whether the entry at rel is left out.
+func (f *entryFilter) skip(rel string, isDir bool) bool {
+	return f.skipName(path.Base(rel)) || f.ignored(rel, isDir)
+}
+
+// skipPath reports whether the entry at rel or one of the directories
+// above it is left out, for sources that list nested paths directly.
+func (f *entryFilter) skipPath(rel string, isDir bool) bool {
+	for i := 0; i < len(rel); i++ {
+		if rel[i] == '/' && f.skip(rel[:i], true) {
+			return true
+		}
+	}
+	return f.skip(rel, isDir)
+}
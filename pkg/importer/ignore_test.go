@@ -0,0 +1,170 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// ignoreTree is a working directory with the usual clutter.
+func ignoreTree() map[string][]byte {
+	return map[string][]byte{
+		".git/config":              []byte("[core]"),
+		".DS_Store":                []byte("finder"),
+		"a.tmp":                    []byte("scratch"),
+		"main.go":                  []byte("package main"),
+		"src/util.go":              []byte("package src"),
+		"src/util.go.tmp":          []byte("backup"),
+		"node_modules/x/index.js":  []byte("module.exports = 1"),
+		"docs/guide.md":            []byte("# guide"),
+		"docs/api/ref.md":          []byte("# ref"),
+		"out/build/app":            []byte("binary"),
+		"src/build":                []byte("a file named build"),
+		"vendor/node_modules.json": []byte("{}"),
+	}
+}
+
+var ignorePatterns = []string{"# editor and build output", "*.tmp", "node_modules/", "/docs/*.md", "build/", ""}
+
+// importedPaths imports dir and returns the sorted Content paths and the
+// last progress report.
+func importedPaths(t *testing.T, imp *Importer) ([]string, int64, int64) {
+	t.Helper()
+
+	var completed, total int64
+	result, err := imp.WithProgress(func(c, tot int64, _ string) {
+		completed, total = c, tot
+	}).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	var paths []string
+	for _, c := range result.Contents {
+		paths = append(paths, c.Path)
+	}
+	sort.Strings(paths)
+	return paths, completed, total
+}
+
+// sizeOf returns the total size of the given entries of tree.
+func sizeOf(tree map[string][]byte, paths []string) int64 {
+	var n int64
+	for _, p := range paths {
+		n += int64(len(tree[p]))
+	}
+	return n
+}
+
+func TestImporter_WithIgnore(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	tree := ignoreTree()
+	dir := writeTree(t, tree)
+
+	tests := []struct {
+		name string
+		imp  *Importer
+		want []string
+	}{
+		{
+			name: "patterns",
+			imp:  NewImporter(bs, dir).WithIgnore(ignorePatterns),
+			want: []string{"docs/api/ref.md", "main.go", "src/build", "src/util.go", "vendor/node_modules.json"},
+		},
+		{
+			name: "patterns with hidden entries",
+			imp:  NewImporter(bs, dir).WithIgnore(append(ignorePatterns, ".git/")).WithSkipHidden(false),
+			want: []string{".DS_Store", "docs/api/ref.md", "main.go", "src/build", "src/util.go", "vendor/node_modules.json"},
+		},
+		{
+			name: "hidden entries only",
+			imp:  NewImporter(bs, dir).WithSkipHidden(false).WithIgnore([]string{"**/node_modules/", "out/"}),
+			want: []string{
+				".DS_Store", ".git/config", "a.tmp", "docs/api/ref.md", "docs/guide.md", "main.go",
+				"src/build", "src/util.go", "src/util.go.tmp", "vendor/node_modules.json",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths, completed, total := importedPaths(t, tt.imp)
+			if !reflect.DeepEqual(paths, tt.want) {
+				t.Errorf("Contents = %v, want %v", paths, tt.want)
+			}
+			if want := sizeOf(tree, tt.want); total != want || completed != total {
+				t.Errorf("progress %d of %d, want %d of %d", completed, total, want, want)
+			}
+		})
+	}
+}
+
+func TestImporter_WithIgnore_Sources(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	dir := writeTree(t, ignoreTree())
+	ctx := context.Background()
+	want, err := NewImporter(bs, dir).WithIgnore(ignorePatterns).Import(ctx)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	entries, err := ScanEntries(dir)
+	if err != nil {
+		t.Fatalf("ScanEntries failed: %v", err)
+	}
+	listed, err := NewImporter(bs, dir).WithIgnore(ignorePatterns).WithPrescannedEntries(entries).Import(ctx)
+	if err != nil {
+		t.Fatalf("prescanned Import failed: %v", err)
+	}
+	if listed.RootCid != want.RootCid {
+		t.Errorf("prescanned root %s, want %s", listed.RootCid, want.RootCid)
+	}
+
+	streamed, err := ImportTarStream(ctx, bs, bytes.NewReader(tarDirectory(t, dir)), func(imp *Importer) {
+		imp.WithIgnore(ignorePatterns)
+	})
+	if err != nil {
+		t.Fatalf("ImportTarStream failed: %v", err)
+	}
+	if streamed.RootCid != want.RootCid {
+		t.Errorf("tar root %s, want %s", streamed.RootCid, want.RootCid)
+	}
+
+	report, err := NewImporter(bs, dir).WithIgnore(ignorePatterns).Scan(ctx)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if report.TotalBytes != want.Size {
+		t.Errorf("Scan TotalBytes = %d, want %d", report.TotalBytes, want.Size)
+	}
+	ignored := make(map[string]bool)
+	for _, s := range report.Skipped {
+		if s.Reason == SkipReasonIgnored {
+			ignored[filepath.ToSlash(s.Path)] = true
+		}
+	}
+	for _, p := range []string{"a.tmp", "src/util.go.tmp", "node_modules", "docs/guide.md", "out/build"} {
+		if !ignored[p] {
+			t.Errorf("Scan did not report %s as ignored: %+v", p, report.Skipped)
+		}
+	}
+}
+
+func TestImporter_WithIgnore_Invalid(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	dir := writeTree(t, map[string][]byte{"a.txt": []byte("alpha")})
+	for _, pattern := range []string{"!keep.txt", "[", "/"} {
+		_, err := NewImporter(bs, dir).WithIgnore([]string{pattern}).Import(context.Background())
+		if !errors.Is(err, ErrInvalidIgnorePattern) {
+			t.Errorf("%q: Import error = %v, want ErrInvalidIgnorePattern", pattern, err)
+		}
+	}
+}
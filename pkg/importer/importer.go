@@ -2,6 +2,7 @@
 // into IPFS using content-addressable storage. It supports:
 //
 //   - Single file and directory import
+//   - Tar stream import without writing the archive to disk
 //   - Progress tracking with callbacks
 //   - Context cancellation for graceful interruption
 //   - Automatic filename cleaning for Windows compatibility
@@ -32,11 +33,16 @@ package importer
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"sync/atomic"
+	"time"
 
 	"github.com/ipfs/boxo/blockservice"
 	"github.com/ipfs/boxo/blockstore"
@@ -45,32 +51,96 @@ import (
 	"github.com/ipfs/boxo/ipld/unixfs"
 	"github.com/ipfs/boxo/mfs"
 	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
 	ipld "github.com/ipfs/go-ipld-format"
 	"github.com/multiformats/go-multicodec"
+	"github.com/tragoedia0722/repository/pkg/clock"
+	"github.com/tragoedia0722/repository/pkg/packaging"
+	"github.com/tragoedia0722/repository/pkg/tracing"
+	"github.com/tragoedia0722/repository/pkg/xattr"
 )
 
 // Constants are defined in constants.go
 // Callback types are defined in progress.go
 
 // Result contains the output of an import operation.
+//
+// Packages list every block of the imported tree exactly once, including the
+// blocks shared by empty files and empty directories, so a receiver holding
+// all package blocks (as checked by HasAllBlocks) can reconstruct the full
+// tree. EmptyFiles and EmptyDirs let a receiver create empty entries without
+// transferring any block.
 type Result struct {
-	FileName string    // Cleaned name of the imported file/directory
-	Size     int64     // Total size in bytes
-	RootCid  string    // Content-addressed identifier of the root DAG node
-	Packages []Package // Block packages with their hashes
-	Contents []Content // List of all imported files with their sizes
+	FileName    string       // Cleaned name of the imported file/directory
+	Size        int64        // Total size in bytes
+	RootCid     string       // Content-addressed identifier of the root DAG node
+	Packages    []Package    // Block packages with their hashes
+	Contents    []Content    // List of all imported files with their sizes
+	DirContents []DirContent // Every imported directory with its CID, depth-first from the root
+	Directories []DirStat    // Per-directory statistics, depth-first from the root (nil if disabled)
+	DedupStats  *DedupStats  // New versus already present blocks (nil unless WithDedupStats(true))
+	EmptyFiles  []string     // Cleaned slash-separated paths of zero-byte files, in import order
+	EmptyDirs   []string     // Cleaned slash-separated paths of empty directories below the root, in import order
+	Provenance  *Provenance  // Where and how the import was made (nil if disabled)
+	Warnings    []error      // Non-fatal problems, such as a *StaleEntryError
 }
 
-// Package represents a collection of blocks with their computed hash.
-type Package struct {
-	Hash   string   // SHA-256 hash of concatenated block CIDs
-	Blocks []string // List of block CIDs in this package
+// Checksums returns the recorded SHA-256 checksums keyed by Content.Path, in
+// the form accepted by extractor.WithChecksumVerify. Files without a checksum
+// are left out; the map is empty unless the import ran with WithChecksums.
+func (r *Result) Checksums() map[string]string {
+	sums := make(map[string]string)
+	for _, c := range r.Contents {
+		if c.SHA256 != "" {
+			sums[c.Path] = c.SHA256
+		}
+	}
+	return sums
+}
+
+// ImportRoot returns the root CID. Together with ImportPackages and
+// ImportProvenance it lets a Result be passed to repository.CommitImport.
+func (r *Result) ImportRoot() string {
+	return r.RootCid
+}
+
+// ImportPackages returns the block packages of the import.
+func (r *Result) ImportPackages() []Package {
+	return r.Packages
+}
+
+// ImportProvenance returns the JSON encoding of the provenance, or nil if
+// the import ran without WithProvenance.
+func (r *Result) ImportProvenance() ([]byte, error) {
+	if r.Provenance == nil {
+		return nil, nil
+	}
+	return json.Marshal(r.Provenance)
 }
 
+// Package represents a collection of blocks with their computed hash.
+// It is shared with the packaging package so packages rebuilt from a
+// repository compare equal to those produced by an import.
+type Package = packaging.Package
+
 // Content represents a single file's metadata within an import.
 type Content struct {
-	Name string // Cleaned filename
-	Size int64  // File size in bytes
+	Name    string // Cleaned filename
+	Size    int64  // File size in bytes
+	Path    string // Cleaned slash-separated path below the root; empty for a single-file import
+	SHA256  string // Hex SHA-256 of the file data; empty unless checksums are enabled and the file was read
+	Chunker string // Chunker specification the file's blocks were made with, such as "size-1048576"
+	Cid     string // CID of the file's root node, which extracts the file on its own
+
+	// OriginalNameRaw is the hex encoding of the file's name as read from
+	// the source when it was not valid UTF-8 and Name was derived from a
+	// repaired copy; it is empty for names that were valid UTF-8.
+	OriginalNameRaw string
+
+	// Stripes are the root CIDs of the file's stripes in file order when it
+	// was imported with WithFileStriping; nil for a file that was not
+	// striped or was linked through the content index.
+	Stripes []string
 }
 
 type Importer struct {
@@ -80,9 +150,59 @@ type Importer struct {
 	bufferedDS *ipld.BufferedDAG
 	cidBuilder cid.Builder
 	root       *mfs.Root
-	liveNodes  atomic.Uint64    // Atomic counter for cache management
-	progress   progressCallback // Callback to be stored until tracker is created
-	tracker    *progressTracker // Created when total size is known
+	liveNodes  atomic.Uint64      // Atomic counter for cache management
+	progress   progressCallback   // Callback to be stored until tracker is created
+	onEvent    eventCallback      // Callback of WithProgressEvents
+	tracker    *progressTracker   // Created when total size is known
+	scan       *ScanReport        // Optional pre-computed scan supplied via WithScan
+	dirStats   *bool              // Directory statistics setting; nil means automatic
+	dirs       *dirStatsCollector // Per-import directory statistics, nil if disabled
+	yieldEvery time.Duration      // Scheduling point interval for read loops; 0 disables
+	yieldSleep time.Duration      // Optional sleep at each scheduling point
+	onYield    yieldFunc          // Called at scheduling points; nil means yieldNow
+	clock      clock.Clock        // Time source for provenance timestamps and yielding
+	partials   *partialCollector  // Files committed by the running import
+	partial    *PartialResult     // What the last failed import committed; nil after success
+	index      ContentIndex       // Optional index of previously imported files
+	wrapSource sourceWrapper      // Applied to every source file before it is read; nil leaves it as is
+	atomic     bool               // Stage blocks and commit them only after success
+	stageMem   int64              // Staged bytes kept in memory before spilling to disk
+	stageDir   string             // Parent of the on-disk staging area; empty uses the temp dir
+	stage      *stagingBlockstore // Staging area of the running atomic import
+	dedupStats bool               // Collect the dedup statistics
+	pkgLimit   packaging.Limits   // Package limits set with WithPackageLimit
+	dedup      *dedupDAG          // Dedup statistics of the running import, nil if disabled
+	withXattr  bool               // Preserve extended attributes
+	metadata   bool               // Record file and directory modes and modification times
+	xattrs     *xattrCollector    // Attributes of the running import, nil if disabled
+	emptyFiles []string           // Zero-byte files of the running import
+	emptyDirs  []string           // Empty directories of the running import
+	dirPaths   []string           // Directories of the running import, in depth-first pre-order
+	rawNames   map[string]string  // Source names that were not valid UTF-8, keyed by cleaned entry path
+	checksums  bool               // Record a SHA-256 digest of every file read
+	events     *eventStream       // Events of the next or running import, nil if Events was not called
+	provOpts   *ProvenanceOptions // Provenance recording settings; nil disables it
+	listing    []SourceEntry      // Optional prescanned listing supplied via WithPrescannedEntries
+	profile    ChunkerProfile     // Optional choice of chunker per file; nil uses the default chunker
+	chunkSize  int64              // Block size of the default chunker; 0 uses DefaultChunker
+	cidVersion int                // CID version set with WithCIDVersion
+	hashFunc   string             // Multihash name set with WithHashFunc; empty means DefaultHashFunc
+	ignore     []string           // Patterns of entries to leave out, see WithIgnore
+	showHidden bool               // Import entries whose names start with a dot
+	symlinks   SymlinkPolicy      // How symlinks below the imported directory are added
+	workers    int                // Files of a directory chunked at once; 0 or 1 imports them one at a time
+	filter     *entryFilter       // Entries left out by the running import
+	tar        *tarStream         // Tar archive read by ImportTarStream; nil for filesystem imports
+	reader     *readerSource      // Data read by ImportReader; nil for filesystem imports
+	warnings   []error            // Non-fatal problems of the running import
+	stripeSize int64              // Stripe size for large files; 0 disables striping
+	dirBatch   int                // Directory entries read per batch; 0 uses the default
+	unsorted   bool               // Import directory entries in on-disk order
+	tracer     tracing.Tracer     // Optional tracer; nil disables tracing
+	traced     *tracingDAG        // Batch tracing of the running import, nil if disabled
+	nodeCache  *NodeCache         // Optional cache of file and directory nodes shared between imports
+	cacheDirs  []*cacheDir        // Links of the directories being imported, innermost last
+	resume     ds.Datastore       // Where files are recorded for resuming, see WithResume; nil disables it
 	Contents   []Content
 }
 
@@ -97,6 +217,8 @@ func NewImporter(blockStore blockstore.Blockstore, path string) *Importer {
 			MhType:   uint64(multicodec.Sha2_256),
 			MhLength: -1,
 		},
+		cidVersion: 1,
+		clock:      clock.Real{},
 	}
 }
 
@@ -108,59 +230,235 @@ func (imp *Importer) WithProgress(progressFn progressCallback) *Importer {
 	return imp
 }
 
+// WithProgressEvents sets a callback receiving detailed progress: the phase
+// of the import, the bytes and files imported so far and the progress of
+// the file being read. It is called when a phase starts, when a file starts
+// and is added to the DAG, as data is read, and one last time with
+// PhaseDone when Import returns, whether or not it succeeded. Calls never
+// overlap, and CompletedBytes never goes backwards.
+//
+// WithProgress is a simpler form reporting only the byte counts; both may be
+// set.
+// Returns the importer for method chaining.
+func (imp *Importer) WithProgressEvents(fn func(ProgressEvent)) *Importer {
+	imp.onEvent = fn
+	return imp
+}
+
+// WithClock sets the time source used for the provenance timestamps and the
+// scheduling points of WithYield. A nil clock restores the real clock, which
+// is the default; tests pass a clocktest.Fake to control time.
+// Returns the importer for method chaining.
+func (imp *Importer) WithClock(c clock.Clock) *Importer {
+	imp.clock = clock.OrReal(c)
+	return imp
+}
+
+// WithChecksums records the SHA-256 of every file in Result.Contents, computed
+// while the file is chunked so it is read only once. Files linked through the
+// content index are not read and get no checksum. The extractor can verify
+// extracted files against the recorded checksums, see
+// Result.Checksums.
+// Returns the importer for method chaining.
+func (imp *Importer) WithChecksums(enabled bool) *Importer {
+	imp.checksums = enabled
+	return imp
+}
+
+// trackerCallback returns the callback of the progress tracker, forwarding
+// to both the WithProgress callback and the event channel.
+func (imp *Importer) trackerCallback() progressCallback {
+	if imp.events == nil {
+		return imp.progress
+	}
+	events, progress := imp.events, imp.progress
+	return func(completed, total int64, currentFile string) {
+		if progress != nil {
+			progress(completed, total, currentFile)
+		}
+		events.progress(completed, total, currentFile)
+	}
+}
+
 func (imp *Importer) updateProgress(size int64, filename string) {
+	imp.updateFileProgress(size, "", filename)
+}
+
+// updateFileProgress reports size bytes read from the file at path.
+func (imp *Importer) updateFileProgress(size int64, path, filename string) {
 	if imp.tracker != nil {
-		imp.tracker.update(size, filename)
+		imp.tracker.updateFile(size, path, filename)
 	}
 }
 
 // Import imports the file or directory into IPFS and returns the result.
 // It supports cancellation through the context.
 func (imp *Importer) Import(ctx context.Context) (*Result, error) {
+	var span tracing.Span
+	if imp.tracer != nil {
+		ctx, span = imp.tracer.StartSpan(ctx, tracing.SpanImport)
+	}
+	result, err := imp.run(ctx)
+	if span != nil {
+		endImportSpan(span, result, err)
+	}
+	imp.tracker.setPhase(PhaseDone)
+	imp.events.finish(result, err)
+	imp.events = nil
+	return result, err
+}
+
+// run performs the import.
+func (imp *Importer) run(ctx context.Context) (*Result, error) {
+	imp.partial = nil
+	imp.partials = nil
+	imp.stage = nil
+	imp.dedup = nil
+	imp.emptyFiles = nil
+	imp.emptyDirs = nil
+	imp.dirPaths = nil
+	imp.rawNames = nil
+	imp.warnings = nil
+	imp.cacheDirs = nil
+	imp.tracker = newProgressTracker(0, imp.trackerCallback())
+	imp.tracker.onEvent = imp.onEvent
+
+	if err := imp.checkCidBuilder(); err != nil {
+		return imp.fail(err)
+	}
+	if err := imp.checkChunkSize(); err != nil {
+		return imp.fail(err)
+	}
+	prov := imp.startProvenance()
+	filter, err := imp.newEntryFilter()
+	if err != nil {
+		return imp.fail(err)
+	}
+	imp.filter = filter
+
 	// Initialize services
 	if err := imp.initServices(ctx); err != nil {
-		return nil, err
+		return imp.fail(err)
+	}
+	defer func() {
+		_ = imp.stage.discard()
+	}()
+	imp.dirs = imp.newDirStats()
+	xattrs, err := imp.newXattrs()
+	if err != nil {
+		return imp.fail(err)
 	}
+	imp.xattrs = xattrs
 
 	// Prepare content
 	dir, err := imp.sliceDirectory(imp.path)
 	if err != nil {
-		return nil, err
+		return imp.fail(err)
 	}
 
 	// Get root node
 	it := dir.Entries()
 	if !it.Next() {
-		return nil, ErrNoContent
+		return imp.fail(ErrNoContent)
 	}
 
-	// Calculate total size and initialize tracker
-	size, err := it.Node().Size()
-	if err != nil {
-		return nil, err
+	// Calculate total size and initialize tracker. A prescanned listing or a
+	// supplied scan report replaces the size walk; files changing since the
+	// scan are tolerated and the result reports the bytes actually imported.
+	_, listed := it.Node().(*listedDir)
+	_, streamed := it.Node().(*tarDir)
+	streamed = streamed || (imp.reader != nil && imp.reader.size < 0)
+	scan := imp.scanFor()
+	if scan == nil && imp.scan != nil {
+		imp.warn(fmt.Errorf("%w: produced for %s", ErrScanIgnored, imp.scan.Root))
 	}
-	imp.tracker = newProgressTracker(size, imp.progress)
+	var size int64 // A streamed import starts at 0 and grows as file headers are read
+	if listed {
+		size = listingBytes(imp.listing, imp.filter)
+		imp.tracker.filesTotal = listingFiles(imp.listing, imp.filter)
+	} else if scan != nil {
+		size = scan.TotalBytes
+		imp.tracker.filesTotal = scan.FileCount
+	} else if !streamed {
+		imp.phase(PhaseScanning)
+		size, err = it.Node().Size()
+		if err != nil {
+			return imp.fail(err)
+		}
+	}
+	if _, ok := it.Node().(files.File); ok {
+		imp.tracker.filesTotal = 1
+	}
+	imp.tracker.setTotal(size)
 
 	// Add content to DAG
+	imp.phase(PhaseImporting)
 	node, err := imp.addContent(ctx, it.Node())
 	if err != nil {
-		return nil, err
+		return imp.fail(err)
 	}
 
 	// Commit all changes
+	imp.phase(PhasePackaging)
 	if err = imp.commitChanges(ctx); err != nil {
-		return nil, err
+		return imp.fail(err)
+	}
+
+	if listed || streamed || scan != nil || imp.reader != nil {
+		size = imp.tracker.getProcessed()
 	}
 
 	// Build result
-	return imp.buildResult(ctx, node, size)
+	result, err := imp.buildResult(ctx, node, size)
+	if err != nil {
+		return imp.fail(err)
+	}
+
+	// Write staged blocks to the blockstore (atomic imports only)
+	if imp.stage != nil {
+		imp.phase(PhaseCommitting)
+	}
+	if err := imp.stage.commit(ctx); err != nil {
+		return imp.fail(err)
+	}
+
+	if err := imp.clearResume(ctx); err != nil {
+		imp.warn(fmt.Errorf("failed to remove the resume manifest: %w", err))
+	}
+
+	if prov != nil {
+		prov.Finished = imp.clock.Now().UTC()
+		result.Provenance = prov
+	}
+	return result, nil
 }
 
 // initServices initializes DAG service and buffered DAG
 func (imp *Importer) initServices(ctx context.Context) error {
-	bs := blockservice.New(imp.blockStore, nil)
-	imp.dagService = merkledag.NewDAGService(bs)
-	imp.bufferedDS = ipld.NewBufferedDAG(ctx, imp.dagService, ipld.MaxSizeBatchOption(defaultBatchSize))
+	var store blockstore.Blockstore = imp.blockStore
+	if imp.atomic {
+		imp.stage = newStagingBlockstore(imp.blockStore, imp.stageMem, imp.stageDir)
+		store = imp.stage
+	}
+
+	bs := blockservice.New(store, nil)
+	var ds ipld.DAGService = merkledag.NewDAGService(bs)
+	if imp.dedupStats {
+		// Presence is checked against the real blockstore, not the staging area
+		imp.dedup = newDedupDAG(ds, imp.blockStore)
+		ds = imp.dedup
+	}
+	recorder := newRecordingDAG(ds)
+	imp.partials = &partialCollector{dag: recorder}
+	imp.dagService = recorder
+
+	var batches ipld.DAGService = recorder
+	imp.traced = nil
+	if imp.tracer != nil {
+		imp.traced = &tracingDAG{DAGService: recorder, tracer: imp.tracer}
+		batches = imp.traced
+	}
+	imp.bufferedDS = ipld.NewBufferedDAG(ctx, batches, ipld.MaxSizeBatchOption(defaultBatchSize))
 	return nil
 }
 
@@ -174,24 +472,39 @@ func (imp *Importer) commitChanges(ctx context.Context) error {
 
 // buildResult collects blocks, creates packages, and builds the final result
 func (imp *Importer) buildResult(ctx context.Context, node ipld.Node, size int64) (*Result, error) {
-	blocks, err := imp.collectBlocks(ctx, node)
+	packages, err := imp.createPackages(ctx, node)
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := imp.dirContents(ctx, node)
 	if err != nil {
 		return nil, err
 	}
-
-	packages := imp.createPackages(blocks)
 
 	return &Result{
-		FileName: cleanFilename(filepath.Base(imp.path)),
-		Size:     size,
-		RootCid:  node.Cid().String(),
-		Packages: packages,
-		Contents: imp.Contents,
+		FileName:    cleanFilename(filepath.Base(imp.path)),
+		Size:        size,
+		RootCid:     node.Cid().String(),
+		Packages:    packages,
+		Contents:    imp.Contents,
+		DirContents: dirs,
+		Directories: imp.dirs.result(),
+		DedupStats:  imp.dedup.result(),
+		EmptyFiles:  imp.emptyFiles,
+		EmptyDirs:   imp.emptyDirs,
+		Warnings:    imp.warnings,
 	}, nil
 }
 
 func (imp *Importer) sliceDirectory(filename string) (files.Directory, error) {
-	lstat, err := os.Lstat(filename)
+	if imp.tar != nil {
+		return imp.tar.slice(cleanDirname(filepath.Base(filename))), nil
+	}
+	if imp.reader != nil {
+		return imp.reader.slice(), nil
+	}
+
+	lstat, err := os.Lstat(sourcePath(filename))
 	if err != nil {
 		return nil, err
 	}
@@ -205,9 +518,11 @@ func (imp *Importer) sliceDirectory(filename string) (files.Directory, error) {
 
 // sliceDirectoryPath creates a directory entry for a directory path
 func (imp *Importer) sliceDirectoryPath(dirPath string, lstat os.FileInfo) (files.Directory, error) {
-	node, err := files.NewSerialFile(dirPath, false, lstat)
-	if err != nil {
-		return nil, err
+	var node files.Node
+	if imp.listing != nil {
+		node = imp.newListedDir(sourcePath(dirPath), lstat)
+	} else {
+		node = imp.newStreamDir(sourcePath(dirPath), lstat)
 	}
 
 	cleanDirName := cleanDirname(filepath.Base(dirPath))
@@ -221,13 +536,14 @@ func (imp *Importer) sliceDirectoryPath(dirPath string, lstat os.FileInfo) (file
 
 // sliceSingleFile creates a directory entry for a single file
 func (imp *Importer) sliceSingleFile(filePath string, lstat os.FileInfo) (files.Directory, error) {
-	open, err := os.Open(filePath)
+	open, err := os.Open(sourcePath(filePath))
 	if err != nil {
 		return nil, err
 	}
 
 	node := files.NewReaderStatFile(open, lstat)
 	cleanFileName := cleanFilename(filepath.Base(filePath))
+	imp.noteRawName(cleanFileName, filepath.Base(filePath))
 
 	entries := []files.DirEntry{
 		files.FileEntry(cleanFileName, node),
@@ -242,6 +558,8 @@ func (imp *Importer) sliceSingleFile(filePath string, lstat os.FileInfo) (files.
 type progressReader struct {
 	reader     io.Reader
 	onProgress func(int64)
+	ctx        context.Context // Checked at scheduling points
+	yield      *yielder        // Optional; nil disables scheduling points
 }
 
 // newProgressReader creates a new progress reader
@@ -254,6 +572,10 @@ func newProgressReader(reader io.Reader, onProgress func(int64)) *progressReader
 
 // Read implements io.Reader
 func (pr *progressReader) Read(p []byte) (n int, err error) {
+	if err := pr.yield.maybeYield(pr.ctx); err != nil {
+		return 0, err
+	}
+
 	n, err = pr.reader.Read(p)
 	if n > 0 && pr.onProgress != nil {
 		pr.onProgress(int64(n))
@@ -267,6 +589,9 @@ func (imp *Importer) addContent(ctx context.Context, node files.Node) (ipld.Node
 	if err := imp.addNode(ctx, "", node, true); err != nil {
 		return nil, err
 	}
+	if err := imp.addXattrMetadata(ctx); err != nil {
+		return nil, err
+	}
 
 	mr, err := imp.mfsRoot(ctx)
 	if err != nil {
@@ -389,6 +714,14 @@ func (imp *Importer) addDir(ctx context.Context, dirPath string, dir files.Direc
 		}
 	}
 
+	imp.dirPaths = append(imp.dirPaths, filepath.ToSlash(dirPath))
+	imp.dirs.enterDir(dirPath)
+	defer imp.dirs.leaveDir()
+	imp.enterCacheDir()
+	defer imp.leaveCacheDir()
+	queue := imp.newFileQueue(ctx)
+	defer queue.stop()
+
 	it := dir.Entries()
 	seenNames := make(map[string]string)
 	for it.Next() {
@@ -406,14 +739,43 @@ func (imp *Importer) addDir(ctx context.Context, dirPath string, dir files.Direc
 			return fmt.Errorf("duplicate cleaned entry name %q from %q and %q", cleanName, previous, originalName)
 		}
 		seenNames[cleanName] = originalName
+		if dirPath == "" && cleanName == xattr.ReservedName {
+			return &ImportError{Path: originalName, Op: "import", Err: ErrReservedName}
+		}
 
 		entryPath := filepath.Join(dirPath, cleanName)
-		if err := imp.addNode(ctx, entryPath, entryNode, false); err != nil {
+		imp.noteRawName(entryPath, originalName)
+		if err := imp.xattrs.enter(entryPath, originalName); err != nil {
+			return err
+		}
+		var err error
+		if file, ok := queue.accepts(entryNode); ok {
+			err = queue.add(ctx, entryPath, file)
+		} else if err = queue.drain(); err == nil {
+			err = imp.addNode(ctx, entryPath, entryNode, false)
+		} else {
+			_ = entryNode.Close()
+		}
+		imp.xattrs.leave()
+		if err != nil {
 			return err
 		}
 	}
 
-	return it.Err()
+	if err := it.Err(); err != nil {
+		return err
+	}
+	if err := queue.drain(); err != nil {
+		return err
+	}
+
+	if len(seenNames) == 0 && dirPath != "" {
+		imp.emptyDirs = append(imp.emptyDirs, filepath.ToSlash(dirPath))
+	}
+	if err := imp.setDirMetadata(ctx, dirPath, dir.Mode(), dir.ModTime()); err != nil {
+		return err
+	}
+	return imp.finishCacheDir(ctx, dirPath, len(seenNames))
 }
 
 func (imp *Importer) addSymlink(ctx context.Context, path string, l *files.Symlink) error {
@@ -431,37 +793,310 @@ func (imp *Importer) addSymlink(ctx context.Context, path string, l *files.Symli
 		return err
 	}
 
-	return imp.putNode(ctx, node, path)
+	if err = imp.putNode(ctx, node, path); err != nil {
+		return err
+	}
+	return imp.noteCacheLink(path, node)
 }
 
+// sourceWrapper wraps a source file before it is read.
+type sourceWrapper func(file files.File) files.File
+
 // addFile imports a file into the DAG
 func (imp *Importer) addFile(ctx context.Context, path string, file files.File) error {
+	job, err := imp.openFile(ctx, path, file)
+	if job == nil {
+		return err
+	}
+	job.mark = imp.partials.mark()
+	job.err = imp.buildFile(job, imp.bufferedDS, imp.dagService)
+	return imp.finishFile(job)
+}
+
+// fileJob is a file that is read and chunked. It is opened and finished in
+// import order, while the chunking in between may run on a goroutine of its
+// own, see WithConcurrency.
+type fileJob struct {
+	ctx         context.Context // Context of the file, carrying its span
+	span        *fileSpan
+	path        string
+	file        files.File
+	size        int64
+	mode        os.FileMode
+	mtime       time.Time
+	displayName string
+	profilePath string
+	chunker     string
+	content     int                // Index of the file in imp.Contents
+	nodeKey     *[sha256.Size]byte // Node cache key; nil if the node is not cached
+	hash        []byte             // Content index key; nil without an index
+
+	// Blocks the file wrote are those recorded by recorder, or, without one,
+	// those the import recorded since mark
+	recorder *recordingDAG
+	mark     int
+
+	// Set by buildFile
+	node     ipld.Node
+	stripes  []string
+	read     int64
+	checksum []byte
+	err      error
+	done     chan struct{} // Closed when the chunking goroutine returns
+}
+
+// openFile records the file at path and starts its span. A file whose node
+// is reused from the node cache or the content index is completed right away
+// and nil is returned; otherwise the returned job has to be built and
+// finished.
+func (imp *Importer) openFile(ctx context.Context, path string, file files.File) (job *fileJob, err error) {
+	if imp.wrapSource != nil {
+		file = imp.wrapSource(file)
+	}
+
 	size, err := file.Size()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	mode, mtime := file.Mode(), file.ModTime()
 
 	displayName := cleanFilename(filepath.Base(path))
+	profilePath := filepath.ToSlash(path)
+	if profilePath == "" {
+		profilePath = filepath.Base(imp.path)
+	}
+	chunker := imp.chunkerFor(profilePath, size)
 
 	// Record content metadata
 	imp.Contents = append(imp.Contents, Content{
-		Name: displayName,
-		Size: size,
+		Name:            displayName,
+		Size:            size,
+		Path:            filepath.ToSlash(path),
+		Chunker:         chunker,
+		OriginalNameRaw: imp.rawNames[path],
 	})
+	content := len(imp.Contents) - 1
+	ctx, span := imp.startFileSpan(ctx, profilePath, size)
+	defer func() {
+		if job == nil {
+			span.end(&err)
+		}
+	}()
+	imp.events.send(FileStarted{Path: filepath.ToSlash(path), Size: size})
+	imp.tracker.startFile(path, displayName, size)
+	if size >= 0 {
+		imp.noteFileSize(path, size)
+	}
+
+	// Link the node an interrupted run of this import built
+	if e, linked, blocks, ok := imp.resumedFile(ctx, profilePath, size, mtime, chunker); ok {
+		if imp.checksums {
+			imp.Contents[content].SHA256 = e.SHA256
+		}
+		imp.Contents[content].Stripes = e.Stripes
+		node, err := imp.fileWithMetadata(ctx, linked, mode, mtime)
+		if err != nil {
+			return nil, err
+		}
+		blocks = withRoot(blocks, linked, node)
+		if err := imp.putNode(ctx, node, path); err != nil {
+			return nil, err
+		}
+		imp.Contents[content].Cid = node.Cid().String()
+		if err := imp.noteCacheLink(path, node); err != nil {
+			return nil, err
+		}
+		imp.updateFileProgress(size, path, displayName)
+		imp.partials.addFile(filepath.ToSlash(path), node, size, blocks)
+		span.done(node, true)
+		imp.events.send(FileCompleted{Content: imp.Contents[content]})
+		imp.tracker.finishFile(path)
+		return nil, nil
+	}
+
+	// Reuse the node of a small file an earlier import built
+	cached, nodeKey, file, err := imp.cachedFile(ctx, file, size, chunker)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil {
+		if imp.checksums {
+			digest := sha256.New()
+			if _, err := io.Copy(digest, file); err != nil {
+				return nil, err
+			}
+			imp.Contents[content].SHA256 = hex.EncodeToString(digest.Sum(nil))
+		}
+		node, err := imp.fileWithMetadata(ctx, cached.node, mode, mtime)
+		if err != nil {
+			return nil, err
+		}
+		if err := imp.putNode(ctx, node, path); err != nil {
+			return nil, err
+		}
+		imp.Contents[content].Cid = node.Cid().String()
+		if err := imp.noteCacheLink(path, node); err != nil {
+			return nil, err
+		}
+		imp.updateFileProgress(size, path, displayName)
+		imp.partials.addFile(filepath.ToSlash(path), node, size, withRoot(cached.blockStrings(), cached.node, node))
+		span.done(node, true)
+		imp.events.send(FileCompleted{Content: imp.Contents[content]})
+		imp.tracker.finishFile(path)
+		return nil, nil
+	}
+
+	// Link a previously imported copy of the file instead of reading it
+	var hash []byte
+	if imp.index != nil {
+		if hash, err = quickHash(file, size); err != nil {
+			return nil, err
+		}
+		hash = chunkerKey(hash, imp.indexSpec(chunker))
+		if hash != nil {
+			if node, blocks, ok := imp.lookupContent(ctx, size, hash); ok {
+				linked := node
+				if node, err = imp.fileWithMetadata(ctx, linked, mode, mtime); err != nil {
+					return nil, err
+				}
+				blocks = withRoot(blocks, linked, node)
+				if err := imp.putNode(ctx, node, path); err != nil {
+					return nil, err
+				}
+				imp.Contents[content].Cid = node.Cid().String()
+				if err := imp.noteCacheLink(path, node); err != nil {
+					return nil, err
+				}
+				imp.updateFileProgress(size, path, displayName)
+				imp.partials.addFile(filepath.ToSlash(path), node, size, blocks)
+				span.done(node, true)
+				imp.events.send(FileCompleted{Content: imp.Contents[content]})
+				imp.tracker.finishFile(path)
+				return nil, nil
+			}
+		}
+	}
+
+	return &fileJob{
+		ctx:         ctx,
+		span:        span,
+		path:        path,
+		file:        file,
+		size:        size,
+		mode:        mode,
+		mtime:       mtime,
+		displayName: displayName,
+		profilePath: profilePath,
+		chunker:     chunker,
+		content:     content,
+		nodeKey:     nodeKey,
+		hash:        hash,
+	}, nil
+}
+
+// buildFile reads and chunks the file of job into dag, adding the stitch
+// node of a striped file to ds. It only touches the job, the progress
+// tracker and the DAG, so it may run next to the import's main loop.
+func (imp *Importer) buildFile(job *fileJob, dag *ipld.BufferedDAG, ds ipld.DAGService) error {
+	// Hash the data as it is chunked, so the file is read only once
+	var reader io.Reader = job.file
+	var checksum func() []byte
+	if imp.checksums {
+		digest := sha256.New()
+		reader = io.TeeReader(job.file, digest)
+		checksum = func() []byte { return digest.Sum(nil) }
+	}
 
 	// Create progress reader
-	pr := newProgressReader(file, func(n int64) {
-		imp.updateProgress(n, displayName)
+	pr := newProgressReader(reader, func(n int64) {
+		job.read += n
+		imp.updateFileProgress(n, job.path, job.displayName)
 	})
+	pr.ctx = job.ctx
+	pr.yield = newYielder(imp.clock, imp.yieldEvery, imp.yieldSleep, imp.onYield)
+
+	// Build DAG from file. The buffered DAG is committed before it returns,
+	// so every block added since mark has been written.
+	var err error
+	if imp.stripes(job.size) {
+		job.node, job.stripes, err = imp.buildStripedDAG(job.ctx, pr, job.chunker, dag, ds)
+	} else {
+		job.node, err = imp.buildDAGInto(job.ctx, pr, job.chunker, dag)
+	}
+	if err != nil {
+		return err
+	}
+	if checksum != nil {
+		job.checksum = checksum()
+	}
+	return nil
+}
+
+// finishFile puts the built file of job in MFS and completes its records,
+// or returns the error it was built with.
+func (imp *Importer) finishFile(job *fileJob) (err error) {
+	defer job.span.end(&err)
+	if errors.Is(job.err, ErrInvalidChunker) {
+		return &ImportError{Path: job.profilePath, Op: "chunk", Err: job.err}
+	}
+	if job.err != nil {
+		return job.err
+	}
 
-	// Build DAG from file
-	node, err := imp.buildDAGFromFile(ctx, pr)
+	ctx, path, content := job.ctx, job.path, job.content
+	imp.Contents[content].Stripes = job.stripes
+	if job.checksum != nil {
+		imp.Contents[content].SHA256 = hex.EncodeToString(job.checksum)
+	}
+	if job.size < 0 {
+		// The size of a reader of unknown size is known once it is read
+		imp.Contents[content].Size = job.read
+		imp.noteFileSize(path, job.read)
+	}
+
+	// Put node in MFS. The cache and the index keep the node without metadata.
+	node := job.node
+	fileNode, err := imp.fileWithMetadata(ctx, node, job.mode, job.mtime)
 	if err != nil {
 		return err
 	}
+	if err := imp.putNode(ctx, fileNode, path); err != nil {
+		return err
+	}
+	imp.Contents[content].Cid = fileNode.Cid().String()
+	var blocks []string
+	if job.recorder != nil {
+		blocks = withRoot(job.recorder.since(0), node, fileNode)
+	} else {
+		blocks = imp.partials.since(job.mark)
+	}
+	imp.partials.addFile(filepath.ToSlash(path), fileNode, job.size, blocks)
+	job.span.done(fileNode, false)
+	if err := imp.noteCacheLink(path, fileNode); err != nil {
+		return err
+	}
+	if err := imp.addCachedFile(ctx, job.nodeKey, node); err != nil {
+		return err
+	}
 
-	// Put node in MFS
-	return imp.putNode(ctx, node, path)
+	if job.hash != nil {
+		if err := imp.index.Add(job.size, job.hash, node.Cid().String()); err != nil {
+			return err
+		}
+	}
+	imp.recordResume(ctx, job)
+	imp.events.send(FileCompleted{Content: imp.Contents[content]})
+	imp.tracker.finishFile(path)
+	return nil
+}
+
+// noteFileSize records the size of the file at path in the directory
+// statistics and the empty files.
+func (imp *Importer) noteFileSize(path string, size int64) {
+	imp.dirs.addFile(size)
+	if size == 0 {
+		imp.emptyFiles = append(imp.emptyFiles, filepath.ToSlash(path))
+	}
 }
 
 func (imp *Importer) putNode(ctx context.Context, node ipld.Node, filePath string) error {
@@ -921,12 +921,12 @@ func TestImporter_createPackages_VerifyHashes(t *testing.T) {
 	imp := NewImporter(bs, "/test")
 
 	// Test with exactly 100 blocks (1 package)
-	blocks100 := make([]string, 100)
-	for i := range blocks100 {
-		blocks100[i] = "Qm" + strings.Repeat(string(rune('a'+i%26)), 44)
-	}
+	blocks100 := putTestBlocks(t, bs, 100)
 
-	packages100 := imp.createPackages(blocks100)
+	packages100, err := imp.createPackages(context.Background(), blocks100)
+	if err != nil {
+		t.Fatalf("createPackages failed: %v", err)
+	}
 	if len(packages100) != 1 {
 		t.Errorf("Expected 1 package for 100 blocks, got %d", len(packages100))
 	}
@@ -936,12 +936,12 @@ func TestImporter_createPackages_VerifyHashes(t *testing.T) {
 	}
 
 	// Test with 250 blocks (2 full + 1 partial)
-	blocks250 := make([]string, 250)
-	for i := range blocks250 {
-		blocks250[i] = "Qm" + strings.Repeat(string(rune('a'+i%26)), 44)
-	}
+	blocks250 := putTestBlocks(t, bs, 250)
 
-	packages250 := imp.createPackages(blocks250)
+	packages250, err := imp.createPackages(context.Background(), blocks250)
+	if err != nil {
+		t.Fatalf("createPackages failed: %v", err)
+	}
 	if len(packages250) != 3 {
 		t.Errorf("Expected 3 packages for 250 blocks, got %d", len(packages250))
 	}
@@ -3,6 +3,7 @@ package importer
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -10,6 +11,9 @@ import (
 	"syscall"
 	"testing"
 	"time"
+
+	"github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
 )
 
 // ============================================================
@@ -225,7 +229,7 @@ func TestImporter_HugeFileSize(t *testing.T) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	// Create a file larger than chunkSize (1MB)
+	// Create a file larger than the default 1MB chunk size
 	// Use 5MB for testing
 	testFile := filepath.Join(tmpDir, "large.bin")
 	size := int64(5 * 1024 * 1024) // 5MB
@@ -830,6 +834,21 @@ func TestImporter_calcPackage_SingleBlock(t *testing.T) {
 	}
 }
 
+// putTestBlocks stores n distinct raw blocks in bs and returns their CIDs.
+func putTestBlocks(t *testing.T, bs blockstore.Blockstore, n int) []string {
+	t.Helper()
+
+	cids := make([]string, n)
+	for i := range cids {
+		blk := blocks.NewBlock([]byte(fmt.Sprintf("test block %d", i)))
+		if err := bs.Put(context.Background(), blk); err != nil {
+			t.Fatalf("failed to put block: %v", err)
+		}
+		cids[i] = blk.Cid().String()
+	}
+	return cids
+}
+
 func TestImporter_createPackages_ExactlyMultiple(t *testing.T) {
 	bs, cleanup := createTestBlockstore(t)
 	defer cleanup()
@@ -837,12 +856,12 @@ func TestImporter_createPackages_ExactlyMultiple(t *testing.T) {
 	imp := NewImporter(bs, "/test")
 
 	// Create exactly 300 blocks (should be 3 packages)
-	blocks := make([]string, 300)
-	for i := range blocks {
-		blocks[i] = "Qm" + strings.Repeat(string(rune('a'+i%26)), 44)
-	}
+	blocks := putTestBlocks(t, bs, 300)
 
-	packages := imp.createPackages(blocks)
+	packages, err := imp.createPackages(context.Background(), blocks)
+	if err != nil {
+		t.Fatalf("createPackages failed: %v", err)
+	}
 
 	if len(packages) != 3 {
 		t.Errorf("Expected 3 packages, got %d", len(packages))
@@ -866,12 +885,12 @@ func TestImporter_createPackages_NotMultiple(t *testing.T) {
 	imp := NewImporter(bs, "/test")
 
 	// Create 250 blocks (should be 2 full packages + 1 partial)
-	blocks := make([]string, 250)
-	for i := range blocks {
-		blocks[i] = "Qm" + strings.Repeat(string(rune('a'+i%26)), 44)
-	}
+	blocks := putTestBlocks(t, bs, 250)
 
-	packages := imp.createPackages(blocks)
+	packages, err := imp.createPackages(context.Background(), blocks)
+	if err != nil {
+		t.Fatalf("createPackages failed: %v", err)
+	}
 
 	if len(packages) != 3 {
 		t.Errorf("Expected 3 packages, got %d", len(packages))
@@ -1071,8 +1090,8 @@ func TestNewProgressTracker_ZeroTotal(t *testing.T) {
 		t.Fatal("newProgressTracker returned nil")
 	}
 
-	if tracker.totalSize != 0 {
-		t.Errorf("Expected totalSize 0, got %d", tracker.totalSize)
+	if tracker.totalSize.Load() != 0 {
+		t.Errorf("Expected totalSize 0, got %d", tracker.totalSize.Load())
 	}
 
 	if tracker.getProcessed() != 0 {
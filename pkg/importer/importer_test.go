@@ -3,11 +3,14 @@ package importer
 import (
 	"context"
 	"encoding/json"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/go-cid"
+	"github.com/tragoedia0722/repository/pkg/packaging"
 	"github.com/tragoedia0722/repository/pkg/repository"
 )
 
@@ -325,6 +328,71 @@ func TestImporter_calcPackage(t *testing.T) {
 	}
 }
 
+func TestImporter_WithPackageLimit(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	// 1MB blocks of the large file mixed with 1KB blocks of the small one
+	rng := rand.New(rand.NewSource(1))
+	large := make([]byte, 5<<20)
+	rng.Read(large)
+	small := make([]byte, 64<<10)
+	rng.Read(small)
+	dir := writeTree(t, map[string][]byte{"large.bin": large, "small.txt": small})
+	profile := func(relPath string, size int64) string {
+		if relPath == "small.txt" {
+			return "size-1024"
+		}
+		return ""
+	}
+
+	const maxBlocks, maxBytes = 20, 3 << 20
+	ctx := context.Background()
+	result, err := NewImporter(bs, dir).WithChunkerProfile(profile).WithPackageLimit(maxBlocks, maxBytes).Import(ctx)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	var blocks []string
+	for i, pkg := range result.Packages {
+		if len(pkg.Blocks) > maxBlocks || pkg.Bytes > maxBytes {
+			t.Errorf("package %d has %d blocks of %d bytes, limits %d and %d", i, len(pkg.Blocks), pkg.Bytes, maxBlocks, maxBytes)
+		}
+		var bytes int64
+		for _, b := range pkg.Blocks {
+			c, err := cid.Decode(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			size, err := bs.GetSize(ctx, c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bytes += int64(size)
+		}
+		if pkg.Bytes != bytes {
+			t.Errorf("package %d: Bytes = %d, want %d", i, pkg.Bytes, bytes)
+		}
+		if pkg.Hash != packaging.Calc(pkg.Blocks).Hash {
+			t.Errorf("package %d: hash differs from packaging.Calc", i)
+		}
+		blocks = append(blocks, pkg.Blocks...)
+	}
+	if len(blocks) < 70 {
+		t.Fatalf("import produced %d blocks, want the 5 large and 64 small leaves", len(blocks))
+	}
+
+	// Hashes depend only on the blocks: without the limits all blocks fit
+	// in one default package with the hash of the combined list
+	def, err := NewImporter(bs, dir).WithChunkerProfile(profile).Import(ctx)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if def.RootCid != result.RootCid || len(def.Packages) != 1 || def.Packages[0].Hash != packaging.Calc(blocks).Hash {
+		t.Errorf("default packages = %d, want one package of all %d blocks", len(def.Packages), len(blocks))
+	}
+}
+
 func TestImporter_sliceDirectory(t *testing.T) {
 	bs, cleanup := createTestBlockstore(t)
 	defer cleanup()
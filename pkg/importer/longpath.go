@@ -0,0 +1,71 @@
+package importer
+
+import "strings"
+
+// Windows path prefixes used by extendedPath.
+const (
+	extendedPrefix    = `\\?\`
+	extendedUNCPrefix = `\\?\UNC\`
+	devicePrefix      = `\\.\`
+)
+
+// extendedPath converts an absolute Windows path to extended-length form
+// (\\?\C:\... or \\?\UNC\server\share\...). Extended-length paths bypass the
+// 260 character limit and the reserved device name handling of the Win32 API,
+// but are passed to the filesystem verbatim, so the path is normalized first:
+// forward slashes become backslashes and ".", ".." and empty components are
+// resolved. Relative paths and paths already in device or extended form are
+// returned unchanged.
+//
+// The conversion is purely lexical and does not depend on the platform it runs
+// on; sourcePath decides whether to apply it.
+func extendedPath(path string) string {
+	if strings.HasPrefix(path, extendedPrefix) || strings.HasPrefix(path, devicePrefix) {
+		return path
+	}
+
+	p := strings.ReplaceAll(path, "/", `\`)
+
+	switch {
+	case len(p) >= 3 && isDriveLetter(p[0]) && p[1] == ':' && p[2] == '\\':
+		return extendedPrefix + p[:2] + `\` + normalizeComponents(p[3:], 0)
+
+	case strings.HasPrefix(p, `\\`):
+		// UNC path: the server and share components are never removed by ".."
+		rest := normalizeComponents(p[2:], 2)
+		if strings.Count(rest, `\`) < 1 {
+			return path
+		}
+		return extendedUNCPrefix + rest
+
+	default:
+		return path
+	}
+}
+
+// normalizeComponents resolves ".", ".." and empty components of a
+// backslash-separated path. The first keep components are never removed.
+func normalizeComponents(p string, keep int) string {
+	parts := strings.Split(p, `\`)
+	out := make([]string, 0, len(parts))
+
+	for _, part := range parts {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if len(out) > keep {
+				out = out[:len(out)-1]
+			}
+			continue
+		}
+		out = append(out, part)
+	}
+
+	return strings.Join(out, `\`)
+}
+
+// isDriveLetter reports whether c is an ASCII letter.
+func isDriveLetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package importer
+
+// sourcePath returns the form of path used to access source files. Outside
+// Windows paths need no conversion.
+func sourcePath(path string) string {
+	return path
+}
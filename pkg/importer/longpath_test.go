@@ -0,0 +1,38 @@
+package importer
+
+import "testing"
+
+func TestExtendedPath(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"drive path", `C:\data\file.txt`, `\\?\C:\data\file.txt`},
+		{"lowercase drive", `d:\x`, `\\?\d:\x`},
+		{"drive root", `C:\`, `\\?\C:\`},
+		{"forward slashes", `C:/data/sub/file.txt`, `\\?\C:\data\sub\file.txt`},
+		{"dot components", `C:\data\.\sub\..\file.txt`, `\\?\C:\data\file.txt`},
+		{"dotdot above root", `C:\..\..\file.txt`, `\\?\C:\file.txt`},
+		{"repeated separators", `C:\data\\sub\`, `\\?\C:\data\sub`},
+		{"reserved name", `C:\share\CON.txt`, `\\?\C:\share\CON.txt`},
+		{"unc path", `\\server\share\dir\file.txt`, `\\?\UNC\server\share\dir\file.txt`},
+		{"unc dotdot keeps share", `\\server\share\..\..\file.txt`, `\\?\UNC\server\share\file.txt`},
+		{"unc without share", `\\server`, `\\server`},
+		{"already extended", `\\?\C:\data`, `\\?\C:\data`},
+		{"already extended unc", `\\?\UNC\server\share`, `\\?\UNC\server\share`},
+		{"device path", `\\.\PhysicalDrive0`, `\\.\PhysicalDrive0`},
+		{"relative path", `data\file.txt`, `data\file.txt`},
+		{"drive relative", `C:file.txt`, `C:file.txt`},
+		{"unix path", `/home/user/file.txt`, `/home/user/file.txt`},
+		{"empty", ``, ``},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extendedPath(tt.in); got != tt.want {
+				t.Errorf("extendedPath(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package importer
+
+import "path/filepath"
+
+// sourcePath returns the form of path used to access source files. On Windows
+// absolute paths are converted to extended-length form so that deep trees and
+// files with reserved device names (CON, NUL, ...) can be opened. Names
+// recorded in the DAG are derived from the original path and cleaned as usual.
+func sourcePath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return extendedPath(abs)
+}
@@ -0,0 +1,49 @@
+package importer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImporter_WindowsLongPathsAndReservedNames(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	root := t.TempDir()
+
+	// Build a tree whose deepest path exceeds 260 characters
+	deep := root
+	for len(deep) <= 300 {
+		deep = filepath.Join(deep, strings.Repeat("d", 40))
+	}
+	if err := os.MkdirAll(extendedPath(deep), 0o755); err != nil {
+		t.Fatalf("failed to create deep tree: %v", err)
+	}
+	if err := os.WriteFile(extendedPath(filepath.Join(deep, "deep.txt")), []byte("deep"), 0o644); err != nil {
+		t.Fatalf("failed to write deep file: %v", err)
+	}
+
+	// A reserved device name can only be created through the extended form
+	if err := os.WriteFile(extendedPath(filepath.Join(root, "CON.txt")), []byte("console"), 0o644); err != nil {
+		t.Fatalf("failed to write reserved-name file: %v", err)
+	}
+
+	result, err := NewImporter(bs, root).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	names := make(map[string]int64)
+	for _, c := range result.Contents {
+		names[c.Name] = c.Size
+	}
+	if size, ok := names["deep.txt"]; !ok || size != 4 {
+		t.Errorf("expected deep.txt of 4 bytes, got %v", result.Contents)
+	}
+	if size, ok := names["CON_file.txt"]; !ok || size != 7 {
+		t.Errorf("expected CON.txt to be recorded as CON_file.txt, got %v", result.Contents)
+	}
+}
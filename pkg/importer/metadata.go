@@ -0,0 +1,115 @@
+package importer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/mfs"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// WithPreserveMetadata records the permission bits and modification time of
+// every file and directory in its UnixFS node, as Kubo does with
+// --preserve-mode and --preserve-mtime, so that the extractor's
+// WithPreserveMetadata can restore them. Entries whose source reports no
+// mode or time, such as the data of ImportReader, are stored without them.
+//
+// It is off by default: the recorded times give otherwise identical files
+// and directories different CIDs, so the same tree imported with and without
+// this option, or from two copies with different times, has different roots.
+// Directories are not reused from a NodeCache while it is on.
+// Returns the importer for method chaining.
+func (imp *Importer) WithPreserveMetadata(enabled bool) *Importer {
+	imp.metadata = enabled
+	return imp
+}
+
+// fileWithMetadata returns nd, the root of a file's DAG, with mode and
+// mtime recorded in it. A file stored as a single raw leaf has no room for
+// them and is wrapped in a UnixFS file node linking the leaf. nd is
+// returned as is if metadata is not preserved or none is known.
+func (imp *Importer) fileWithMetadata(ctx context.Context, nd ipld.Node, mode os.FileMode, mtime time.Time) (ipld.Node, error) {
+	if !imp.metadata || (mode.Perm() == 0 && mtime.IsZero()) {
+		return nd, nil
+	}
+
+	var fsn *unixfs.FSNode
+	var node *merkledag.ProtoNode
+	switch n := nd.(type) {
+	case *merkledag.ProtoNode:
+		var err error
+		if fsn, err = unixfs.FSNodeFromBytes(n.Data()); err != nil {
+			return nil, err
+		}
+		node = n.Copy().(*merkledag.ProtoNode)
+	case *merkledag.RawNode:
+		fsn = unixfs.NewFSNode(unixfs.TFile)
+		fsn.AddBlockSize(uint64(len(n.RawData())))
+		node = new(merkledag.ProtoNode)
+		if err := node.AddNodeLink("", n); err != nil {
+			return nil, err
+		}
+	default:
+		return nd, nil
+	}
+
+	if mode.Perm() != 0 {
+		fsn.SetMode(mode)
+	}
+	if !mtime.IsZero() {
+		fsn.SetModTime(mtime)
+	}
+	data, err := fsn.GetBytes()
+	if err != nil {
+		return nil, err
+	}
+	node.SetData(data)
+	if err := node.SetCidBuilder(imp.cidBuilder); err != nil {
+		return nil, err
+	}
+	if err := imp.dagService.Add(ctx, node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// setDirMetadata records mode and mtime in the MFS directory at dirPath.
+// It is called once all entries of the directory have been added.
+func (imp *Importer) setDirMetadata(ctx context.Context, dirPath string, mode os.FileMode, mtime time.Time) error {
+	if !imp.metadata || (mode.Perm() == 0 && mtime.IsZero()) {
+		return nil
+	}
+
+	mr, err := imp.mfsRoot(ctx)
+	if err != nil {
+		return err
+	}
+	fsn, err := mfs.Lookup(mr, "/"+filepath.ToSlash(dirPath))
+	if err != nil {
+		return err
+	}
+	if mode.Perm() != 0 {
+		if err := fsn.SetMode(mode); err != nil {
+			return err
+		}
+	}
+	if !mtime.IsZero() {
+		if err := fsn.SetModTime(mtime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withRoot returns blocks, the blocks of the file rooted at linked, with the
+// root node added when fileWithMetadata replaced it.
+func withRoot(blocks []string, linked, node ipld.Node) []string {
+	if node == linked {
+		return blocks
+	}
+	return append(blocks, node.Cid().String())
+}
@@ -34,7 +34,15 @@ func (imp *Importer) flushMFSRoot(ctx context.Context) error {
 	if imp.root == nil {
 		return ErrMfsRootNil
 	}
-	return imp.root.FlushMemFree(ctx)
+	if err := imp.root.FlushMemFree(ctx); err != nil {
+		return err
+	}
+	if imp.partials != nil {
+		if nd, err := imp.root.GetDirectory().GetNode(); err == nil {
+			imp.partials.rootCid = nd.Cid().String()
+		}
+	}
+	return nil
 }
 
 // putNodeToMFS places a node at the given path in MFS
@@ -0,0 +1,349 @@
+package importer
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/ipfs/boxo/files"
+	"github.com/ipfs/boxo/mfs"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// NodeCache keeps the UnixFS nodes of small files and directories built by
+// earlier imports, so that an import meeting the same content again links
+// the cached node instead of encoding and hashing it a second time. A cache
+// may be shared by any number of importers, also concurrently, and holds at
+// most maxEntries nodes, evicting the least recently used one.
+//
+// Small files are keyed by the SHA-256 of their whole content, directories
+// by the name, CID and size of every link, both together with the chunker
+// and CID builder that determine the encoding. A hit is used only while all
+// its blocks are still in the blockstore of the import, so a cache never
+// changes the CIDs an import produces.
+type NodeCache struct {
+	mu      sync.Mutex
+	max     int
+	lru     *list.List // Front is the most recently used
+	entries map[[sha256.Size]byte]*list.Element
+	stats   NodeCacheStats
+}
+
+// NodeCacheStats counts the lookups of a NodeCache.
+type NodeCacheStats struct {
+	Hits      int64 // Nodes reused from the cache
+	Misses    int64 // Nodes built because they were not cached or their blocks were gone
+	Evictions int64 // Entries dropped to stay within maxEntries
+}
+
+// nodeCacheEntry is a cached node with the blocks that must be present for
+// it to be reused.
+type nodeCacheEntry struct {
+	key    [sha256.Size]byte
+	node   ipld.Node
+	blocks []cid.Cid
+}
+
+// NewNodeCache returns an empty cache holding at most maxEntries nodes.
+// A maxEntries below 1 is treated as 1.
+func NewNodeCache(maxEntries int) *NodeCache {
+	return &NodeCache{
+		max:     max(maxEntries, 1),
+		lru:     list.New(),
+		entries: make(map[[sha256.Size]byte]*list.Element),
+	}
+}
+
+// Len returns the number of cached nodes.
+func (c *NodeCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Stats returns the lookup counters of the cache.
+func (c *NodeCache) Stats() NodeCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// get returns the entry stored under key. Its node is a copy, so importers
+// sharing the cache never share a mutable node.
+func (c *NodeCache) get(key [sha256.Size]byte) (*nodeCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	e := *elem.Value.(*nodeCacheEntry)
+	e.node = e.node.Copy()
+	return &e, true
+}
+
+// add stores a copy of nd under key, evicting the least recently used
+// entries beyond the limit.
+func (c *NodeCache) add(key [sha256.Size]byte, nd ipld.Node, blocks []cid.Cid) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&nodeCacheEntry{key: key, node: nd.Copy(), blocks: blocks})
+	for c.lru.Len() > c.max {
+		elem := c.lru.Back()
+		c.lru.Remove(elem)
+		delete(c.entries, elem.Value.(*nodeCacheEntry).key)
+		c.stats.Evictions++
+	}
+}
+
+// count records the outcome of a lookup.
+func (c *NodeCache) count(hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if hit {
+		c.stats.Hits++
+	} else {
+		c.stats.Misses++
+	}
+}
+
+// WithNodeCache makes the import reuse the nodes of small files and
+// directories found in c and add the ones it builds, see NodeCache. Reused
+// nodes are not written again, so their blocks are not counted in
+// Result.DedupStats.
+// Returns the importer for method chaining.
+func (imp *Importer) WithNodeCache(c *NodeCache) *Importer {
+	imp.nodeCache = c
+	return imp
+}
+
+// cacheDir collects the links of a directory being imported, so that its
+// node can be looked up once every entry is added.
+type cacheDir struct {
+	links []cacheLink
+}
+
+// cacheLink is a directory entry as it is encoded in the directory node.
+type cacheLink struct {
+	name string
+	cid  cid.Cid
+	size uint64
+}
+
+// nodeCacheKey starts a cache key of the given kind, covering the CID
+// builder every node of the import is encoded with.
+func (imp *Importer) nodeCacheKey(kind string) hash.Hash {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s\x00%T%+v\x00", kind, imp.cidBuilder, imp.cidBuilder)
+	return h
+}
+
+// cacheKey finishes a key started by nodeCacheKey.
+func cacheKey(h hash.Hash) (key [sha256.Size]byte) {
+	h.Sum(key[:0])
+	return key
+}
+
+// hasBlocks reports whether every block is in the blockstore the import
+// writes to, including blocks staged by an atomic import.
+func (imp *Importer) hasBlocks(ctx context.Context, blocks []cid.Cid) bool {
+	store := imp.blockStore
+	if imp.stage != nil {
+		store = imp.stage
+	}
+	for _, c := range blocks {
+		if ok, err := store.Has(ctx, c); err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// cachedFile reads a small file into memory and looks it up in the node
+// cache. It returns the cached entry on a hit, and otherwise the key to add
+// the built node under, if any. The returned file holds the data read and
+// replaces the source for the rest of the import.
+func (imp *Importer) cachedFile(ctx context.Context, file files.File, size int64, chunker string) (*nodeCacheEntry, *[sha256.Size]byte, files.File, error) {
+	if imp.nodeCache == nil || size < 0 || size > nodeCacheMaxFile || imp.stripes(size) {
+		return nil, nil, file, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, size+1))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if int64(len(data)) != size {
+		// The file changed while it was read, or a reader delivered more
+		// than announced; build it without the cache from all of its data
+		return nil, nil, files.NewReaderFile(io.MultiReader(bytes.NewReader(data), file)), nil
+	}
+	file = files.NewBytesFile(data)
+
+	h := imp.nodeCacheKey("file")
+	_, _ = fmt.Fprintf(h, "%s\x00", chunker)
+	_, _ = h.Write(data)
+	key := cacheKey(h)
+
+	e, ok := imp.nodeCache.get(key)
+	hit := ok && imp.hasBlocks(ctx, e.blocks)
+	imp.nodeCache.count(hit)
+	if hit {
+		return e, nil, file, nil
+	}
+	return nil, &key, file, nil
+}
+
+// addCachedFile adds the node of a file built after a cache miss.
+func (imp *Importer) addCachedFile(ctx context.Context, key *[sha256.Size]byte, nd ipld.Node) error {
+	if key == nil {
+		return nil
+	}
+	blocks := []cid.Cid{nd.Cid()}
+	if len(nd.Links()) > 0 {
+		cids, err := imp.collectBlocks(ctx, nd)
+		if err != nil {
+			return err
+		}
+		blocks = blocks[:0]
+		for _, s := range cids {
+			c, err := cid.Decode(s)
+			if err != nil {
+				return err
+			}
+			blocks = append(blocks, c)
+		}
+	}
+	imp.nodeCache.add(*key, nd, blocks)
+	return nil
+}
+
+// blockStrings returns the string form of the blocks of e.
+func (e *nodeCacheEntry) blockStrings() []string {
+	out := make([]string, len(e.blocks))
+	for i, c := range e.blocks {
+		out[i] = c.String()
+	}
+	return out
+}
+
+// enterCacheDir starts collecting the links of a directory.
+func (imp *Importer) enterCacheDir() {
+	if imp.nodeCache != nil {
+		imp.cacheDirs = append(imp.cacheDirs, &cacheDir{})
+	}
+}
+
+// leaveCacheDir stops collecting the links of the innermost directory.
+func (imp *Importer) leaveCacheDir() {
+	if imp.nodeCache != nil {
+		imp.cacheDirs = imp.cacheDirs[:len(imp.cacheDirs)-1]
+	}
+}
+
+// noteCacheLink records nd as the entry at path of the innermost directory.
+func (imp *Importer) noteCacheLink(path string, nd ipld.Node) error {
+	return imp.noteCacheLinkIn(len(imp.cacheDirs)-1, path, nd)
+}
+
+// noteCacheLinkIn records nd as the entry at path of the directory at
+// depth in the stack of collected directories.
+func (imp *Importer) noteCacheLinkIn(depth int, path string, nd ipld.Node) error {
+	if depth < 0 || path == "" {
+		return nil
+	}
+	size, err := nd.Size()
+	if err != nil {
+		return err
+	}
+	dir := imp.cacheDirs[depth]
+	dir.links = append(dir.links, cacheLink{name: filepath.Base(path), cid: nd.Cid(), size: size})
+	return nil
+}
+
+// finishCacheDir looks up the directory at dirPath, whose entries count
+// entries, once all of them are added. A hit replaces the directory in MFS
+// with the cached node; after a miss, the node MFS builds is added to the
+// cache. Either way the node is recorded in the parent directory. The root
+// of the import and directories with an entry that has no node are not
+// cached.
+func (imp *Importer) finishCacheDir(ctx context.Context, dirPath string, entries int) error {
+	// The key does not cover the metadata of the directory itself
+	if imp.nodeCache == nil || dirPath == "" || imp.metadata {
+		return nil
+	}
+	dir := imp.cacheDirs[len(imp.cacheDirs)-1]
+	if len(dir.links) != entries {
+		return nil
+	}
+
+	sort.Slice(dir.links, func(i, j int) bool { return dir.links[i].name < dir.links[j].name })
+	h := imp.nodeCacheKey("dir")
+	var buf [binary.MaxVarintLen64]byte
+	for _, l := range dir.links {
+		_, _ = h.Write(binary.AppendUvarint(buf[:0], uint64(len(l.name))))
+		_, _ = io.WriteString(h, l.name)
+		_, _ = h.Write(binary.AppendUvarint(buf[:0], uint64(l.cid.ByteLen())))
+		_, _ = h.Write(l.cid.Bytes())
+		_, _ = h.Write(binary.AppendUvarint(buf[:0], l.size))
+	}
+	key := cacheKey(h)
+
+	mr, err := imp.mfsRoot(ctx)
+	if err != nil {
+		return err
+	}
+	var nd ipld.Node
+	e, ok := imp.nodeCache.get(key)
+	hit := ok && imp.hasBlocks(ctx, e.blocks)
+	imp.nodeCache.count(hit)
+	if hit {
+		nd = e.node
+		parentPath := filepath.Dir(dirPath)
+		if parentPath == "." {
+			parentPath = ""
+		}
+		parent, err := mfs.Lookup(mr, "/"+filepath.ToSlash(parentPath))
+		if err != nil {
+			return err
+		}
+		pdir, ok := parent.(*mfs.Directory)
+		if !ok {
+			return fmt.Errorf("%s is not a directory", parentPath)
+		}
+		name := filepath.Base(dirPath)
+		if err := pdir.Unlink(name); err != nil {
+			return err
+		}
+		if err := pdir.AddChild(name, nd); err != nil {
+			return err
+		}
+	} else {
+		fsn, err := mfs.Lookup(mr, "/"+filepath.ToSlash(dirPath))
+		if err != nil {
+			return err
+		}
+		if nd, err = fsn.GetNode(); err != nil {
+			return err
+		}
+		imp.nodeCache.add(key, nd, []cid.Cid{nd.Cid()})
+	}
+
+	// Record the directory in its parent, which is still being collected
+	return imp.noteCacheLinkIn(len(imp.cacheDirs)-2, dirPath, nd)
+}
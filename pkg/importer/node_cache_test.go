@@ -0,0 +1,230 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// siblingTree returns the i-th of a family of related trees: most files and
+// directories are shared, a few depend on i.
+func siblingTree(i int) map[string][]byte {
+	tree := map[string][]byte{
+		"README":                           []byte("dataset\n"),
+		"shared/a.txt":                     []byte("shared a"),
+		"shared/b.txt":                     []byte("shared b"),
+		"shared/deep/c.bin":                make([]byte, 4096),
+		"schema/v1/fields.csv":             []byte("id,name\n"),
+		fmt.Sprintf("data/part-%d.csv", i): []byte(fmt.Sprintf("row %d\n", i)),
+	}
+	if i%2 == 0 {
+		tree["even/marker"] = []byte("even")
+	}
+	return tree
+}
+
+// importRoot imports dir into bs, with the node cache if c is not nil.
+func importRoot(t *testing.T, bs blockstore.Blockstore, dir string, c *NodeCache) *Result {
+	t.Helper()
+
+	imp := NewImporter(bs, dir).WithChecksums(true)
+	if c != nil {
+		imp.WithNodeCache(c)
+	}
+	result, err := imp.Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	return result
+}
+
+func TestImporter_WithNodeCache_SiblingTrees(t *testing.T) {
+	plain, cleanupPlain := createTestBlockstore(t)
+	defer cleanupPlain()
+	cachedStore, cleanupCached := createTestBlockstore(t)
+	defer cleanupCached()
+
+	cache := NewNodeCache(1024)
+	for i := range 6 {
+		dir := writeTree(t, siblingTree(i))
+		want := importRoot(t, plain, dir, nil)
+		got := importRoot(t, cachedStore, dir, cache)
+		if got.RootCid != want.RootCid {
+			t.Fatalf("tree %d: root %s with the cache, %s without", i, got.RootCid, want.RootCid)
+		}
+		for j := range want.Contents {
+			if got.Contents[j].SHA256 != want.Contents[j].SHA256 {
+				t.Errorf("tree %d: %s checksum %q, want %q", i, want.Contents[j].Path, got.Contents[j].SHA256, want.Contents[j].SHA256)
+			}
+		}
+		checkBlocks(t, cachedStore, got.RootCid)
+	}
+
+	// Every file of the shared directories and the directories themselves
+	// are reused from the second tree on
+	if stats := cache.Stats(); stats.Hits < 5*6 {
+		t.Errorf("Stats() = %+v, want the shared nodes reused", stats)
+	}
+}
+
+func TestImporter_WithNodeCache_MissingBlocks(t *testing.T) {
+	first, cleanupFirst := createTestBlockstore(t)
+	defer cleanupFirst()
+	second, cleanupSecond := createTestBlockstore(t)
+	defer cleanupSecond()
+
+	cache := NewNodeCache(1024)
+	dir := writeTree(t, siblingTree(1))
+	want := importRoot(t, first, dir, cache)
+
+	// The cached nodes refer to blocks the second store does not have
+	got := importRoot(t, second, dir, cache)
+	if got.RootCid != want.RootCid {
+		t.Fatalf("root %s, want %s", got.RootCid, want.RootCid)
+	}
+	if stats := cache.Stats(); stats.Hits != 0 {
+		t.Errorf("Stats() = %+v, want no hits in another blockstore", stats)
+	}
+	checkBlocks(t, second, got.RootCid)
+}
+
+// TestImporter_WithNodeCache_Property imports random trees built from a
+// small alphabet of names and contents, so that equal and nearly equal
+// files and directories are frequent, and compares every root with an
+// import without the cache.
+func TestImporter_WithNodeCache_Property(t *testing.T) {
+	plain, cleanupPlain := createTestBlockstore(t)
+	defer cleanupPlain()
+	cachedStore, cleanupCached := createTestBlockstore(t)
+	defer cleanupCached()
+
+	rng := rand.New(rand.NewSource(1699))
+	cache := NewNodeCache(16)
+	sizes := []int{0, 1, 7, 1024, nodeCacheMaxFile, nodeCacheMaxFile + 1}
+	for iter := range 25 {
+		tree := make(map[string][]byte)
+		for range 1 + rng.Intn(12) {
+			path := fmt.Sprintf("d%d/d%d/f%d", rng.Intn(3), rng.Intn(2), rng.Intn(4))
+			if rng.Intn(3) == 0 {
+				path = fmt.Sprintf("d%d/f%d", rng.Intn(3), rng.Intn(4))
+			}
+			data := make([]byte, sizes[rng.Intn(len(sizes))])
+			if len(data) > 0 && rng.Intn(2) == 0 {
+				// Same size as its unchanged siblings, one byte apart
+				data[rng.Intn(len(data))] = byte(1 + rng.Intn(255))
+			}
+			tree[path] = data
+		}
+
+		dir := writeTree(t, tree)
+		want := importRoot(t, plain, dir, nil)
+		got := importRoot(t, cachedStore, dir, cache)
+		if got.RootCid != want.RootCid {
+			t.Fatalf("iteration %d: root %s with the cache, %s without", iter, got.RootCid, want.RootCid)
+		}
+	}
+	if stats := cache.Stats(); stats.Hits == 0 || stats.Evictions == 0 {
+		t.Errorf("Stats() = %+v, want hits and evictions", stats)
+	}
+}
+
+// checkBlocks fails if a block below root is missing from bs.
+func checkBlocks(t *testing.T, bs blockstore.Blockstore, root string) {
+	t.Helper()
+
+	c, err := cid.Decode(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dag := merkledag.NewDAGService(blockservice.New(bs, nil))
+	if err := merkledag.Walk(context.Background(), merkledag.GetLinksWithDAG(dag), c, cid.NewSet().Visit); err != nil {
+		t.Fatalf("incomplete DAG below %s: %v", root, err)
+	}
+}
+
+func TestNodeCache_Eviction(t *testing.T) {
+	cache := NewNodeCache(2)
+	nodes := make([]ipld.Node, 3)
+	keys := make([][32]byte, 3)
+	for i := range nodes {
+		nodes[i] = merkledag.NodeWithData([]byte{byte(i)})
+		keys[i][0] = byte(i)
+	}
+
+	cache.add(keys[0], nodes[0], nil)
+	cache.add(keys[1], nodes[1], nil)
+	if _, ok := cache.get(keys[0]); !ok {
+		t.Fatal("entry 0 missing")
+	}
+	cache.add(keys[2], nodes[2], nil)
+
+	if cache.Len() != 2 || cache.Stats().Evictions != 1 {
+		t.Fatalf("Len() = %d, Stats() = %+v", cache.Len(), cache.Stats())
+	}
+	if _, ok := cache.get(keys[1]); ok {
+		t.Error("least recently used entry 1 was kept")
+	}
+	e, ok := cache.get(keys[0])
+	if !ok || !e.node.Cid().Equals(nodes[0].Cid()) {
+		t.Error("recently used entry 0 was evicted")
+	}
+	if e.node == nodes[0] {
+		t.Error("get returned the cached node instead of a copy")
+	}
+}
+
+// BenchmarkImport_SiblingTrees imports 100 related trees, with and without
+// a node cache shared between the imports.
+func BenchmarkImport_SiblingTrees(b *testing.B) {
+	src := b.TempDir()
+	dirs := make([]string, 100)
+	for i := range dirs {
+		dirs[i] = filepath.Join(src, fmt.Sprintf("tree%d", i))
+		tree := siblingTree(i)
+		for j := range 200 {
+			tree[fmt.Sprintf("shared/many/f%d.txt", j)] = []byte(fmt.Sprintf("file %d", j))
+		}
+		for rel, data := range tree {
+			path := filepath.Join(dirs[i], filepath.FromSlash(rel))
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				b.Fatal(err)
+			}
+			if err := os.WriteFile(path, data, 0o644); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	for _, cached := range []bool{false, true} {
+		b.Run(fmt.Sprintf("cache=%v", cached), func(b *testing.B) {
+			bs, cleanup := createBenchmarkBlockstore(b)
+			defer cleanup()
+
+			var cache *NodeCache
+			if cached {
+				cache = NewNodeCache(4096)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for _, dir := range dirs {
+					imp := NewImporter(bs, dir)
+					if cache != nil {
+						imp.WithNodeCache(cache)
+					}
+					if _, err := imp.Import(context.Background()); err != nil {
+						b.Fatalf("Import failed: %v", err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package importer
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/ipfs/boxo/files"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// WithConcurrency reads and chunks up to n files of a directory at once,
+// which speeds up directories of many small files. Zero or a negative value
+// uses runtime.NumCPU(); 1 imports files one at a time, which is the
+// default.
+//
+// Files are still opened, put in the DAG and reported in import order, so
+// the RootCid, Result.Contents and the FileCompleted events are the same as
+// with a sequential import. FileStarted events of up to n files may precede
+// the FileCompleted event of the first, progress callbacks name whichever
+// file was read last and never run concurrently. A file whose chunking
+// fails or is cancelled stops the files started after it. Tar archives and
+// ImportReader are always read sequentially.
+// Returns the importer for method chaining.
+func (imp *Importer) WithConcurrency(n int) *Importer {
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	imp.workers = n
+	return imp
+}
+
+// fileQueue chunks the files of one directory on up to n goroutines and
+// finishes them in directory order on the import's goroutine. MFS, the
+// import records and the events are only touched when a file is opened or
+// finished.
+type fileQueue struct {
+	imp     *Importer
+	ctx     context.Context // Cancelled when the directory fails
+	cancel  context.CancelFunc
+	n       int
+	pending []*fileJob // Files being chunked or waiting to be finished, in order
+}
+
+// newFileQueue returns the queue for a directory, or nil if files are
+// imported one at a time.
+func (imp *Importer) newFileQueue(ctx context.Context) *fileQueue {
+	if imp.workers <= 1 || imp.tar != nil || imp.reader != nil {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &fileQueue{imp: imp, ctx: ctx, cancel: cancel, n: imp.workers}
+}
+
+// accepts returns node as a file if the queue imports it.
+func (q *fileQueue) accepts(node files.Node) (files.File, bool) {
+	if q == nil {
+		return nil, false
+	}
+	if _, ok := node.(*files.Symlink); ok {
+		return nil, false
+	}
+	file, ok := node.(files.File)
+	return file, ok
+}
+
+// add opens the file at path and starts chunking it, first finishing the
+// oldest file if n files are pending. The file is closed once it is read.
+func (q *fileQueue) add(ctx context.Context, path string, file files.File) error {
+	imp := q.imp
+	err := imp.checkInterruption(ctx)
+	if err == nil && len(q.pending) == q.n {
+		err = q.finishNext()
+	}
+	if err == nil {
+		err = imp.maybeFlushCache(ctx)
+	}
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	imp.liveNodes.Add(1)
+
+	job, err := imp.openFile(q.ctx, path, file)
+	if job == nil {
+		_ = file.Close()
+		return err
+	}
+
+	// The file records its own blocks, and batches are traced under its span
+	var ds ipld.DAGService = imp.dagService
+	if imp.traced != nil {
+		ds = &tracingDAG{DAGService: imp.dagService, tracer: imp.tracer, file: job.ctx}
+	}
+	job.recorder = newRecordingDAG(ds)
+	dag := ipld.NewBufferedDAG(job.ctx, job.recorder, ipld.MaxSizeBatchOption(defaultBatchSize))
+	job.file = &cancelableFile{File: job.file, ctx: job.ctx}
+	job.done = make(chan struct{})
+	q.pending = append(q.pending, job)
+
+	go func() {
+		defer close(job.done)
+		defer func() {
+			_ = file.Close()
+		}()
+		job.err = imp.buildFile(job, dag, job.recorder)
+	}()
+	return nil
+}
+
+// finishNext waits for the oldest pending file and finishes it.
+func (q *fileQueue) finishNext() error {
+	job := q.pending[0]
+	q.pending = q.pending[1:]
+	<-job.done
+	return q.imp.finishFile(job)
+}
+
+// drain finishes all pending files in order.
+func (q *fileQueue) drain() error {
+	if q == nil {
+		return nil
+	}
+	for len(q.pending) > 0 {
+		if err := q.finishNext(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stop cancels the files still pending after a failure and waits for
+// their goroutines.
+func (q *fileQueue) stop() {
+	if q == nil {
+		return
+	}
+	q.cancel()
+	for _, job := range q.pending {
+		<-job.done
+		err := job.err
+		if err == nil {
+			err = q.ctx.Err()
+		}
+		job.span.end(&err)
+	}
+	q.pending = nil
+}
+
+// cancelableFile fails reads once its context is done, so a file chunked
+// on its own goroutine stops promptly.
+type cancelableFile struct {
+	files.File
+	ctx context.Context
+}
+
+func (f *cancelableFile) Read(p []byte) (int, error) {
+	if err := f.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return f.File.Read(p)
+}
@@ -0,0 +1,150 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// manyFilesTree returns a tree of many small files in a few directories and
+// one file large enough to be striped.
+func manyFilesTree() map[string][]byte {
+	tree := make(map[string][]byte)
+	for i := 0; i < 300; i++ {
+		tree[fmt.Sprintf("d%d/f%03d.txt", i%3, i)] = []byte(fmt.Sprintf("content of file %d", i))
+	}
+	tree["top.txt"] = []byte("top")
+	tree["empty.txt"] = nil
+	large := make([]byte, 3*1024*1024+17)
+	for i := range large {
+		large[i] = byte(i % 251)
+	}
+	tree["d1/large.bin"] = large
+	return tree
+}
+
+func TestImporter_WithConcurrency_MatchesSequential(t *testing.T) {
+	dir := writeTree(t, manyFilesTree())
+
+	importWith := func(workers int) *Result {
+		bs, cleanup := createTestBlockstore(t)
+		defer cleanup()
+
+		var last int64
+		imp := NewImporter(bs, dir).
+			WithConcurrency(workers).
+			WithChecksums(true).
+			WithDedupStats(true).
+			WithFileStriping(1024 * 1024).
+			WithProgress(func(completed, total int64, file string) {
+				if completed < last {
+					t.Errorf("workers=%d: progress went back from %d to %d", workers, last, completed)
+				}
+				last = completed
+			})
+		result, err := imp.Import(context.Background())
+		if err != nil {
+			t.Fatalf("workers=%d: Import failed: %v", workers, err)
+		}
+		if last != result.Size {
+			t.Errorf("workers=%d: last progress %d, want %d", workers, last, result.Size)
+		}
+		return result
+	}
+
+	sequential := importWith(1)
+	parallel := importWith(8)
+	if parallel.RootCid != sequential.RootCid {
+		t.Errorf("RootCid = %s, want %s as imported sequentially", parallel.RootCid, sequential.RootCid)
+	}
+	if !reflect.DeepEqual(parallel.Contents, sequential.Contents) {
+		t.Error("Contents differ from the sequential import")
+	}
+	if !reflect.DeepEqual(parallel.Packages, sequential.Packages) {
+		t.Error("Packages differ from the sequential import")
+	}
+	if !reflect.DeepEqual(parallel.DedupStats, sequential.DedupStats) {
+		t.Errorf("DedupStats = %+v, want %+v", parallel.DedupStats, sequential.DedupStats)
+	}
+	if !reflect.DeepEqual(parallel.EmptyFiles, sequential.EmptyFiles) {
+		t.Errorf("EmptyFiles = %v, want %v", parallel.EmptyFiles, sequential.EmptyFiles)
+	}
+}
+
+func TestImporter_WithConcurrency_EventsInOrder(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	imp := NewImporter(bs, writeTree(t, manyFilesTree())).WithConcurrency(4)
+	done := collectEvents(imp.Events(), 0)
+	result, err := imp.Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	events := <-done
+
+	// Every file starts before it completes, and files complete in order
+	started := make(map[string]bool)
+	var completed []Content
+	for _, ev := range events {
+		switch ev := ev.(type) {
+		case FileStarted:
+			started[ev.Path] = true
+		case FileCompleted:
+			if !started[ev.Content.Path] {
+				t.Errorf("%s completed before it started", ev.Content.Path)
+			}
+			completed = append(completed, ev.Content)
+		}
+	}
+	if !reflect.DeepEqual(completed, result.Contents) {
+		t.Error("FileCompleted events are not in the order of Result.Contents")
+	}
+}
+
+func TestImporter_WithConcurrency_Cancel(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var progress atomic.Int64
+	imp := NewImporter(bs, writeTree(t, manyFilesTree())).WithConcurrency(4).WithProgress(func(_, _ int64, _ string) {
+		if progress.Add(1) == 20 {
+			cancel()
+		}
+	})
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := imp.Import(ctx)
+		errc <- err
+	}()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Import error = %v, want context.Canceled", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Import did not stop after cancellation")
+	}
+
+	// Files finished before the cancellation are reported in order
+	partial := imp.LastPartial()
+	if partial == nil {
+		t.Fatal("LastPartial() = nil after a cancelled import")
+	}
+	if len(partial.Files) >= len(manyFilesTree()) {
+		t.Errorf("%d files completed despite the cancellation", len(partial.Files))
+	}
+	for i, f := range partial.Files {
+		if f.Path != imp.Contents[i].Path {
+			t.Errorf("Files[%d] = %s, want %s", i, f.Path, imp.Contents[i].Path)
+		}
+	}
+}
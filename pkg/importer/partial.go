@@ -0,0 +1,203 @@
+package importer
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/tragoedia0722/repository/pkg/packaging"
+)
+
+// PartialResult describes what an interrupted or failed import committed to
+// the blockstore before it stopped. Every block it lists was written
+// successfully; nothing is included speculatively.
+type PartialResult struct {
+	Files    []PartialFile // Files fully added before the interruption, in import order
+	RootCid  string        // CID of the last flushed MFS root, empty if it was never flushed
+	Blocks   []string      // All blocks written by the import, sorted; includes blocks of unfinished files
+	Packages []Package     // Packages built from the blocks of Files within the WithPackageLimit limits
+}
+
+// PartialFile is a file that was fully added before an import stopped.
+type PartialFile struct {
+	Path   string   // Cleaned path relative to the import root
+	Cid    string   // Root CID of the file's DAG
+	Size   int64    // File size in bytes
+	Blocks []string // Blocks of the file's DAG, sorted
+}
+
+// LastPartial returns what the most recent Import committed before it
+// failed, so a caller can resume from it or clean up orphaned blocks. It
+// returns nil if no import has run or the last one succeeded.
+func (imp *Importer) LastPartial() *PartialResult {
+	return imp.partial
+}
+
+// recordingDAG wraps a DAG service and remembers the CID and size of every
+// node it adds successfully, in the order they were added. Files chunked
+// concurrently add to it at the same time.
+type recordingDAG struct {
+	ipld.DAGService
+	mu    sync.Mutex
+	added []cid.Cid
+	sizes map[cid.Cid]int
+}
+
+func newRecordingDAG(ds ipld.DAGService) *recordingDAG {
+	return &recordingDAG{DAGService: ds, sizes: make(map[cid.Cid]int)}
+}
+
+func (r *recordingDAG) Add(ctx context.Context, nd ipld.Node) error {
+	if err := r.DAGService.Add(ctx, nd); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.added = append(r.added, nd.Cid())
+	r.sizes[nd.Cid()] = len(nd.RawData())
+	return nil
+}
+
+func (r *recordingDAG) AddMany(ctx context.Context, nds []ipld.Node) error {
+	if err := r.DAGService.AddMany(ctx, nds); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, nd := range nds {
+		r.added = append(r.added, nd.Cid())
+		r.sizes[nd.Cid()] = len(nd.RawData())
+	}
+	return nil
+}
+
+// mark returns the current position in the log of added nodes.
+func (r *recordingDAG) mark() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.added)
+}
+
+// since returns the distinct blocks added after mark, sorted.
+func (r *recordingDAG) since(mark int) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return uniqueSorted(r.added[mark:])
+}
+
+// sizeFunc returns the size of the blocks added so far, looking up any other
+// block with stat.
+func (r *recordingDAG) sizeFunc(stat packaging.SizeFunc) packaging.SizeFunc {
+	return func(ctx context.Context, c cid.Cid) (int, error) {
+		r.mu.Lock()
+		n, ok := r.sizes[c]
+		r.mu.Unlock()
+		if ok {
+			return n, nil
+		}
+		return stat(ctx, c)
+	}
+}
+
+// uniqueSorted converts cids to distinct strings in sorted order.
+func uniqueSorted(cids []cid.Cid) []string {
+	seen := make(map[cid.Cid]struct{}, len(cids))
+	out := make([]string, 0, len(cids))
+	for _, c := range cids {
+		if _, ok := seen[c]; ok {
+			continue
+		}
+		seen[c] = struct{}{}
+		out = append(out, c.String())
+	}
+	sort.Strings(out)
+	return out
+}
+
+// partialCollector accumulates the files completed during an import.
+type partialCollector struct {
+	dag     *recordingDAG
+	files   []PartialFile
+	rootCid string
+}
+
+// mark returns the position to pass to since once a file is complete.
+func (p *partialCollector) mark() int {
+	if p == nil {
+		return 0
+	}
+	return p.dag.mark()
+}
+
+// since returns the distinct blocks written after mark, sorted.
+func (p *partialCollector) since(mark int) []string {
+	if p == nil {
+		return nil
+	}
+	return p.dag.since(mark)
+}
+
+// addFile records a completed file whose blocks are all in the blockstore.
+func (p *partialCollector) addFile(path string, nd ipld.Node, size int64, blocks []string) {
+	if p == nil {
+		return
+	}
+	p.files = append(p.files, PartialFile{
+		Path:   path,
+		Cid:    nd.Cid().String(),
+		Size:   size,
+		Blocks: blocks,
+	})
+}
+
+// result builds the PartialResult from what was recorded, packing the
+// blocks of Files within limits. Blocks that were not added by this import,
+// such as those reused from the node cache, are sized with stat.
+func (p *partialCollector) result(ctx context.Context, stat packaging.SizeFunc, limits packaging.Limits) *PartialResult {
+	res := &PartialResult{Files: p.files, RootCid: p.rootCid}
+	if p.dag == nil {
+		return res
+	}
+
+	res.Blocks = p.dag.since(0)
+
+	seen := make(map[string]struct{})
+	var fileBlocks []string
+	for _, f := range p.files {
+		for _, b := range f.Blocks {
+			if _, ok := seen[b]; ok {
+				continue
+			}
+			seen[b] = struct{}{}
+			fileBlocks = append(fileBlocks, b)
+		}
+	}
+	sort.Strings(fileBlocks)
+	if len(fileBlocks) > 0 {
+		pkgs, err := packaging.SplitSized(ctx, fileBlocks, p.dag.sizeFunc(stat), limits)
+		if err != nil {
+			// Without sizes the packages can still be listed, with Bytes unset
+			pkgs = packaging.Split(fileBlocks, limits.MaxBlocks)
+		}
+		res.Packages = pkgs
+	}
+	return res
+}
+
+// fail records the partial result of a failed import and returns err.
+func (imp *Importer) fail(err error) (*Result, error) {
+	if imp.stage != nil {
+		// Only blocks of a failed commit reached the blockstore
+		imp.partial = &PartialResult{Blocks: uniqueSorted(imp.stage.committed)}
+		return nil, err
+	}
+	if imp.partials != nil {
+		// The import's context may be done already; the sizes are needed regardless
+		imp.partial = imp.partials.result(context.Background(), imp.store().GetSize, imp.pkgLimit)
+	} else {
+		imp.partial = &PartialResult{}
+	}
+	return nil, err
+}
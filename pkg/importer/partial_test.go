@@ -0,0 +1,125 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+)
+
+func TestImporter_LastPartial_CancelAfterNthFile(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	dir := t.TempDir()
+	for i := 0; i < 5; i++ {
+		content := strings.Repeat(fmt.Sprintf("file %d ", i), 100*(i+1))
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("f%d.txt", i)), []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Cancel while the third file is being read; it still completes because
+	// cancellation is checked between entries.
+	imp := NewImporter(bs, dir).WithProgress(func(_, _ int64, file string) {
+		if file == "f2.txt" {
+			cancel()
+		}
+	})
+
+	if _, err := imp.Import(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Import error = %v, want context.Canceled", err)
+	}
+
+	partial := imp.LastPartial()
+	if partial == nil {
+		t.Fatal("LastPartial() = nil after a cancelled import")
+	}
+
+	want := []string{"f0.txt", "f1.txt", "f2.txt"}
+	if len(partial.Files) != len(want) {
+		t.Fatalf("partial files = %+v, want %v", partial.Files, want)
+	}
+
+	written := make(map[string]bool)
+	for _, b := range partial.Blocks {
+		written[b] = true
+	}
+
+	for i, f := range partial.Files {
+		if f.Path != want[i] {
+			t.Errorf("Files[%d].Path = %q, want %q", i, f.Path, want[i])
+		}
+		if len(f.Blocks) == 0 {
+			t.Errorf("Files[%d] lists no blocks", i)
+		}
+		for _, b := range f.Blocks {
+			if !written[b] {
+				t.Errorf("file block %s missing from Blocks", b)
+			}
+		}
+	}
+
+	var packaged int
+	for i, pkg := range partial.Packages {
+		packaged += len(pkg.Blocks)
+		var bytes int64
+		for _, b := range pkg.Blocks {
+			c, err := cid.Decode(b)
+			if err != nil {
+				t.Fatalf("invalid CID %q: %v", b, err)
+			}
+			size, err := bs.GetSize(context.Background(), c)
+			if err != nil {
+				t.Fatalf("GetSize(%s) failed: %v", b, err)
+			}
+			bytes += int64(size)
+		}
+		if pkg.Bytes != bytes {
+			t.Errorf("Packages[%d].Bytes = %d, want %d", i, pkg.Bytes, bytes)
+		}
+	}
+	if packaged == 0 {
+		t.Error("partial has no packages")
+	}
+
+	for _, b := range partial.Blocks {
+		c, err := cid.Decode(b)
+		if err != nil {
+			t.Fatalf("invalid CID %q: %v", b, err)
+		}
+		has, err := bs.Has(context.Background(), c)
+		if err != nil {
+			t.Fatalf("Has(%s) failed: %v", b, err)
+		}
+		if !has {
+			t.Errorf("listed block %s was not written", b)
+		}
+	}
+}
+
+func TestImporter_LastPartial_NilAfterSuccess(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, []byte("content"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	imp := NewImporter(bs, path)
+	if _, err := imp.Import(context.Background()); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if partial := imp.LastPartial(); partial != nil {
+		t.Errorf("LastPartial() = %+v after success, want nil", partial)
+	}
+}
@@ -0,0 +1,304 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ipfs/boxo/files"
+)
+
+// SourceEntry describes one entry below an import root, as listed by
+// ScanEntries and accepted by WithPrescannedEntries.
+type SourceEntry struct {
+	Path    string      // Slash-separated path relative to the root, with the names found on disk
+	Size    int64       // Size in bytes; only meaningful for regular files
+	Mode    os.FileMode // Mode bits including the file type
+	ModTime time.Time   // Modification time
+	IsDir   bool        // Whether the entry is a directory
+}
+
+// StaleEntryError is collected in Result.Warnings when a prescanned entry no
+// longer matches the filesystem at import time. The entry is imported as it
+// is found on disk, or left out if it no longer exists.
+type StaleEntryError struct {
+	Path   string      // Slash-separated path of the entry relative to the root
+	Listed SourceEntry // The entry as listed
+	Found  os.FileInfo // The entry as found on disk; nil if it no longer exists
+}
+
+func (e *StaleEntryError) Error() string {
+	if e.Found == nil {
+		return fmt.Sprintf("%v: %s no longer exists", ErrStaleEntry, e.Path)
+	}
+	return fmt.Sprintf("%v: %s listed as %s with %d bytes, found %s with %d bytes",
+		ErrStaleEntry, e.Path, e.Listed.Mode.Type(), e.Listed.Size, e.Found.Mode().Type(), e.Found.Size())
+}
+
+func (e *StaleEntryError) Unwrap() error {
+	return ErrStaleEntry
+}
+
+// ScanEntries lists the entries below root depth-first, in the order Import
+// visits them, leaving out hidden entries as Import does. The root itself is
+// not listed, so the listing of a single file is empty. The listing can be
+// shown to the user before it is passed to WithPrescannedEntries.
+func ScanEntries(root string) ([]SourceEntry, error) {
+	base := sourcePath(filepath.Clean(root))
+
+	var entries []SourceEntry
+	err := filepath.WalkDir(base, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == base {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(base, p)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, SourceEntry{
+			Path:    filepath.ToSlash(rel),
+			Size:    info.Size(),
+			Mode:    info.Mode(),
+			ModTime: info.ModTime(),
+			IsDir:   d.IsDir(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// WithPrescannedEntries supplies a listing of the importer's directory, as
+// produced by ScanEntries, so Import does not walk the tree again. The
+// listing is trusted for which entries exist and for the progress total:
+// files are opened directly below the root, and entries added since the
+// listing was made are not imported. A file whose size or type changed, or
+// that no longer exists, is imported as found (or left out) and reported as
+// a *StaleEntryError in Result.Warnings instead of failing the import. The
+// root CID is the same as for a walk of the same tree.
+//
+// The listing must be depth-first with every directory before its entries;
+// Import fails with ErrInvalidListing otherwise. It is ignored when the
+// importer's path is a single file.
+// Returns the importer for method chaining.
+func (imp *Importer) WithPrescannedEntries(entries []SourceEntry) *Importer {
+	imp.listing = entries
+	return imp
+}
+
+// warn records a non-fatal problem in the result and reports it as an event.
+func (imp *Importer) warn(err error) {
+	imp.warnings = append(imp.warnings, err)
+	imp.events.send(Warning{Err: err})
+}
+
+// listingBytes returns the total size of the regular files in entries that
+// filter does not leave out.
+func listingBytes(entries []SourceEntry, filter *entryFilter) int64 {
+	var total int64
+	for _, e := range entries {
+		if e.Mode.IsRegular() && !filter.skipPath(e.Path, false) {
+			total += e.Size
+		}
+	}
+	return total
+}
+
+// listingFiles returns the number of regular files in entries that the
+// filter keeps.
+func listingFiles(entries []SourceEntry, filter *entryFilter) int {
+	var n int
+	for _, e := range entries {
+		if e.Mode.IsRegular() && !filter.skipPath(e.Path, false) {
+			n++
+		}
+	}
+	return n
+}
+
+// listedDir is a directory whose entries come from a prescanned listing.
+type listedDir struct {
+	imp     *Importer
+	root    string        // Filesystem path of the import root
+	prefix  string        // Slash path of this directory below the root, "" for the root
+	info    os.FileInfo   // Mode and modification time of the directory
+	entries []SourceEntry // All entries below this directory, depth-first
+}
+
+// newListedDir returns the root directory of the importer's listing.
+func (imp *Importer) newListedDir(root string, info os.FileInfo) *listedDir {
+	return &listedDir{imp: imp, root: root, info: info, entries: imp.listing}
+}
+
+func (d *listedDir) Close() error         { return nil }
+func (d *listedDir) Size() (int64, error) { return listingBytes(d.entries, d.imp.filter), nil }
+func (d *listedDir) Mode() os.FileMode    { return d.info.Mode() }
+func (d *listedDir) ModTime() time.Time   { return d.info.ModTime() }
+func (d *listedDir) Entries() files.DirIterator {
+	return &listedIterator{dir: d}
+}
+
+// listedIterator iterates over the direct entries of a listedDir.
+type listedIterator struct {
+	dir  *listedDir
+	pos  int
+	name string
+	node files.Node
+	err  error
+}
+
+func (it *listedIterator) Name() string     { return it.name }
+func (it *listedIterator) Node() files.Node { return it.node }
+func (it *listedIterator) Err() error       { return it.err }
+
+func (it *listedIterator) Next() bool {
+	entries := it.dir.entries
+	for it.pos < len(entries) {
+		e := entries[it.pos]
+		it.pos++
+
+		name, ok := it.childName(e.Path)
+		if !ok {
+			it.err = &ImportError{Path: e.Path, Op: "prescan", Err: ErrInvalidListing}
+			return false
+		}
+
+		// The entries of a directory follow it
+		var children []SourceEntry
+		if e.IsDir {
+			end := it.pos
+			for end < len(entries) && strings.HasPrefix(entries[end].Path, e.Path+"/") {
+				end++
+			}
+			children = entries[it.pos:end]
+			it.pos = end
+		}
+
+		if it.dir.imp.filter.skip(e.Path, e.IsDir) {
+			continue
+		}
+		node, err := it.dir.imp.listedNode(it.dir.root, e, children)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		if node == nil {
+			continue
+		}
+		it.name, it.node = name, node
+		return true
+	}
+	return false
+}
+
+// childName returns the name of p if it is a direct entry of the directory.
+func (it *listedIterator) childName(p string) (string, bool) {
+	if p == "" || path.Clean(p) != p || path.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") {
+		return "", false
+	}
+	rest := p
+	if it.dir.prefix != "" {
+		if !strings.HasPrefix(p, it.dir.prefix+"/") {
+			return "", false
+		}
+		rest = p[len(it.dir.prefix)+1:]
+	}
+	if rest == "" || strings.Contains(rest, "/") {
+		return "", false
+	}
+	return rest, true
+}
+
+// listedNode opens the listed entry e below root. It returns a nil node for
+// an entry that no longer exists or a symlink left out by SymlinkSkip.
+func (imp *Importer) listedNode(root string, e SourceEntry, children []SourceEntry) (files.Node, error) {
+	full := filepath.Join(root, filepath.FromSlash(e.Path))
+
+	switch {
+	case e.IsDir:
+		return &listedDir{imp: imp, root: root, prefix: e.Path, info: entryInfo{e}, entries: children}, nil
+
+	case e.Mode&os.ModeSymlink != 0:
+		if imp.symlinks == SymlinkSkip {
+			return nil, nil
+		}
+		target, err := os.Readlink(full)
+		if err != nil {
+			return imp.staleNode(full, e)
+		}
+		return files.NewLinkFile(target, entryInfo{e}), nil
+
+	case e.Mode.IsRegular():
+		f, err := os.Open(full)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return imp.staleNode(full, e)
+			}
+			return nil, err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			_ = f.Close()
+			return imp.staleNode(full, e)
+		}
+		if info.Size() != e.Size {
+			imp.warn(&StaleEntryError{Path: e.Path, Listed: e, Found: info})
+		}
+		return files.NewReaderPathFile(full, f, info)
+
+	default:
+		return nil, &ImportError{Path: e.Path, Op: "prescan", Err: ErrInvalidNodeType}
+	}
+}
+
+// staleNode reports that e no longer matches the filesystem and returns the
+// entry as a normal walk finds it, or nil if it no longer exists.
+func (imp *Importer) staleNode(full string, e SourceEntry) (files.Node, error) {
+	info, err := os.Lstat(full)
+	if errors.Is(err, fs.ErrNotExist) {
+		imp.warn(&StaleEntryError{Path: e.Path, Listed: e})
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	imp.warn(&StaleEntryError{Path: e.Path, Listed: e, Found: info})
+	return files.NewSerialFile(full, false, info)
+}
+
+// entryInfo presents a SourceEntry as an os.FileInfo.
+type entryInfo struct {
+	e SourceEntry
+}
+
+func (i entryInfo) Name() string       { return path.Base(i.e.Path) }
+func (i entryInfo) Size() int64        { return i.e.Size }
+func (i entryInfo) Mode() os.FileMode  { return i.e.Mode }
+func (i entryInfo) ModTime() time.Time { return i.e.ModTime }
+func (i entryInfo) IsDir() bool        { return i.e.IsDir }
+func (i entryInfo) Sys() any           { return nil }
@@ -0,0 +1,151 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScanEntries(t *testing.T) {
+	dir := createScanFixture(t)
+
+	entries, err := ScanEntries(dir)
+	if err != nil {
+		t.Fatalf("ScanEntries failed: %v", err)
+	}
+
+	var paths []string
+	for _, e := range entries {
+		paths = append(paths, e.Path)
+	}
+	want := []string{"a.txt", "b.TXT", "link", "sub", "sub/bad<name>.txt", "sub/c.bin", "sub/deep", "sub/deep/d.txt"}
+	if len(paths) != len(want) {
+		t.Fatalf("paths = %v, want %v", paths, want)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Fatalf("paths = %v, want %v", paths, want)
+		}
+	}
+	if e := entries[5]; e.Size != 4096 || !e.Mode.IsRegular() || e.IsDir || e.ModTime.IsZero() {
+		t.Errorf("sub/c.bin = %+v", e)
+	}
+	if e := entries[3]; !e.IsDir {
+		t.Errorf("sub = %+v, want a directory", e)
+	}
+}
+
+func TestImporter_WithPrescannedEntries(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	dir := createScanFixture(t)
+	entries, err := ScanEntries(dir)
+	if err != nil {
+		t.Fatalf("ScanEntries failed: %v", err)
+	}
+
+	var lastTotal int64
+	result, err := NewImporter(bs, dir).WithPrescannedEntries(entries).WithProgress(func(completed, total int64, file string) {
+		lastTotal = total
+	}).Import(ctx)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	walked, err := NewImporter(bs, dir).Import(ctx)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	if result.RootCid != walked.RootCid {
+		t.Errorf("RootCid = %s, want %s from a walk", result.RootCid, walked.RootCid)
+	}
+	if lastTotal != listingBytes(entries, nil) {
+		t.Errorf("progress total = %d, want %d", lastTotal, listingBytes(entries, nil))
+	}
+	if result.Size != walked.Size {
+		t.Errorf("Size = %d, want %d", result.Size, walked.Size)
+	}
+	if len(result.Warnings) != 0 {
+		t.Errorf("Warnings = %v, want none", result.Warnings)
+	}
+}
+
+func TestImporter_WithPrescannedEntries_Stale(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	dir := createScanFixture(t)
+	entries, err := ScanEntries(dir)
+	if err != nil {
+		t.Fatalf("ScanEntries failed: %v", err)
+	}
+
+	// One file grows and one disappears after the listing was made
+	if err := os.WriteFile(filepath.Join(dir, "sub", "deep", "d.txt"), []byte("deeper than listed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "b.TXT")); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewImporter(bs, dir).WithPrescannedEntries(entries).Import(ctx)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	walked, err := NewImporter(bs, dir).Import(ctx)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.RootCid != walked.RootCid {
+		t.Errorf("RootCid = %s, want %s from a walk of the current tree", result.RootCid, walked.RootCid)
+	}
+	if result.Size != walked.Size {
+		t.Errorf("Size = %d, want %d", result.Size, walked.Size)
+	}
+
+	if len(result.Warnings) != 2 {
+		t.Fatalf("Warnings = %v, want two", result.Warnings)
+	}
+	stale := map[string]*StaleEntryError{}
+	for _, w := range result.Warnings {
+		var se *StaleEntryError
+		if !errors.As(w, &se) || !errors.Is(w, ErrStaleEntry) {
+			t.Fatalf("warning %v is not a *StaleEntryError", w)
+		}
+		stale[se.Path] = se
+	}
+	if se := stale["sub/deep/d.txt"]; se == nil || se.Found == nil || se.Found.Size() != int64(len("deeper than listed")) || se.Listed.Size != 4 {
+		t.Errorf("sub/deep/d.txt warning = %+v", se)
+	}
+	if se := stale["b.TXT"]; se == nil || se.Found != nil {
+		t.Errorf("b.TXT warning = %+v", se)
+	}
+}
+
+func TestImporter_WithPrescannedEntries_Invalid(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	dir := createScanFixture(t)
+	tests := map[string][]SourceEntry{
+		"escapes root":    {{Path: "../a.txt", Mode: 0o644}},
+		"missing parent":  {{Path: "sub/c.bin", Mode: 0o644}},
+		"not depth-first": {{Path: "sub", IsDir: true, Mode: os.ModeDir}, {Path: "a.txt", Mode: 0o644}, {Path: "sub/c.bin", Mode: 0o644}},
+		"unclean path":    {{Path: "./a.txt", Mode: 0o644}},
+		"absolute path":   {{Path: "/a.txt", Mode: 0o644}},
+		"empty path":      {{Path: "", Mode: 0o644}},
+	}
+	for name, entries := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewImporter(bs, dir).WithPrescannedEntries(entries).Import(context.Background())
+			if !errors.Is(err, ErrInvalidListing) {
+				t.Errorf("Import error = %v, want ErrInvalidListing", err)
+			}
+		})
+	}
+}
@@ -1,34 +1,158 @@
 package importer
 
 import (
+	"sync"
 	"sync/atomic"
 )
 
 // progressCallback reports import progress
 type progressCallback func(completed, total int64, currentFile string)
 
+// eventCallback receives detailed import progress
+type eventCallback func(ProgressEvent)
+
+// ProgressEvent is the progress reported to a WithProgressEvents callback.
+type ProgressEvent struct {
+	Phase            Phase  // Phase of the import; PhaseDone in the last event
+	TotalBytes       int64  // Total bytes to import; grows during streamed imports
+	CompletedBytes   int64  // Bytes imported so far
+	CurrentFile      string // Cleaned name of the file the event is about; empty when the phase changes
+	CurrentFileBytes int64  // Bytes of the current file read so far
+	CurrentFileTotal int64  // Size of the current file; -1 for a reader of unknown size
+	FilesCompleted   int    // Files added to the DAG so far
+	FilesTotal       int    // Files to import; 0 if unknown
+}
+
+// fileProgress is the progress of a file being read.
+type fileProgress struct {
+	name  string
+	read  int64
+	total int64
+}
+
 // progressTracker manages progress tracking and interruption state atomically
 type progressTracker struct {
 	processedSize atomic.Int64 // Total bytes processed
-	totalSize     int64        // Total bytes to process (set once, never modified)
+	totalSize     atomic.Int64 // Total bytes to process; only grows for streamed imports
 	isInterrupted atomic.Int32 // 1 = interrupted, 0 = running (atomic flag)
 	callback      progressCallback
+	onEvent       eventCallback            // Optional receiver of progress events
+	mu            sync.Mutex               // Serializes callbacks
+	reported      int64                    // Completed bytes of the last callback
+	phase         Phase                    // Phase reported in progress events
+	files         map[string]*fileProgress // Files being read, by path
+	filesDone     int                      // Files completed
+	filesTotal    int                      // Files to import; 0 if unknown
 }
 
 // newProgressTracker creates a new progress tracker with the given total size and callback
 func newProgressTracker(totalSize int64, callback progressCallback) *progressTracker {
-	return &progressTracker{
-		totalSize: totalSize,
-		callback:  callback,
-	}
+	pt := &progressTracker{callback: callback, files: make(map[string]*fileProgress)}
+	pt.totalSize.Store(totalSize)
+	return pt
 }
 
-// update adds bytes to processed count and triggers callback if set
+// update adds bytes to processed count and triggers callback if set.
+// Callbacks never run concurrently, and an update that lost the race
+// against a later one is not reported, so completed never goes backwards.
 func (pt *progressTracker) update(size int64, filename string) {
+	pt.updateFile(size, "", filename)
+}
+
+// updateFile is update for bytes of the file started at key.
+func (pt *progressTracker) updateFile(size int64, key, filename string) {
 	completed := pt.processedSize.Add(size)
+	if pt.callback == nil && pt.onEvent == nil {
+		return
+	}
+
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	file := pt.files[key]
+	if file != nil {
+		file.read += size
+	}
+	if completed < pt.reported {
+		return
+	}
+	pt.reported = completed
 	if pt.callback != nil {
-		pt.callback(completed, pt.totalSize, filename)
+		pt.callback(completed, pt.totalSize.Load(), filename)
+	}
+	if file == nil {
+		file = &fileProgress{name: filename}
+	}
+	pt.emit(file)
+}
+
+// startFile reports that the file at key, named filename, is being read.
+func (pt *progressTracker) startFile(key, filename string, size int64) {
+	if pt == nil || pt.onEvent == nil {
+		return
 	}
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	file := &fileProgress{name: filename, total: size}
+	pt.files[key] = file
+	pt.emit(file)
+}
+
+// finishFile reports that the file at key was added to the DAG.
+func (pt *progressTracker) finishFile(key string) {
+	if pt == nil || pt.onEvent == nil {
+		return
+	}
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	file := pt.files[key]
+	delete(pt.files, key)
+	pt.filesDone++
+	if file != nil {
+		pt.emit(file)
+	}
+}
+
+// setPhase reports the phase the import entered.
+func (pt *progressTracker) setPhase(p Phase) {
+	if pt == nil || pt.onEvent == nil {
+		return
+	}
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	pt.phase = p
+	pt.emit(nil)
+}
+
+// emit sends a progress event about file, or about the import if file is
+// nil. The caller holds mu.
+func (pt *progressTracker) emit(file *fileProgress) {
+	if pt.onEvent == nil {
+		return
+	}
+	ev := ProgressEvent{
+		Phase:          pt.phase,
+		TotalBytes:     pt.totalSize.Load(),
+		CompletedBytes: pt.processedSize.Load(),
+		FilesCompleted: pt.filesDone,
+		FilesTotal:     pt.filesTotal,
+	}
+	if file != nil {
+		ev.CurrentFile = file.name
+		ev.CurrentFileBytes = file.read
+		ev.CurrentFileTotal = file.total
+	}
+	pt.onEvent(ev)
+}
+
+// setTotal sets the total bytes to process once they are known
+func (pt *progressTracker) setTotal(size int64) {
+	pt.totalSize.Store(size)
+}
+
+// grow adds size to the total, for sources whose size is only known as they
+// are read
+func (pt *progressTracker) grow(size int64) {
+	pt.totalSize.Add(size)
 }
 
 // interrupt flags the import as interrupted using atomic operation
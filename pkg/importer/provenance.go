@@ -0,0 +1,118 @@
+package importer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multicodec"
+)
+
+// modulePath is the import path of this module, looked up in the build info.
+const modulePath = "github.com/tragoedia0722/repository"
+
+// Version is the version of this library recorded in Provenance. It is read
+// from the build info of the running binary and is "(devel)" when the module
+// is built from a working tree.
+var Version = moduleVersion()
+
+// moduleVersion returns the version of this module in the build info.
+func moduleVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+	if info.Main.Path == modulePath && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path != modulePath {
+			continue
+		}
+		if dep.Replace != nil && dep.Replace.Version != "" {
+			return dep.Replace.Version
+		}
+		return dep.Version
+	}
+	return "(devel)"
+}
+
+// ProvenanceOptions configures the provenance recorded by WithProvenance.
+type ProvenanceOptions struct {
+	// Hostname records the name of the importing host.
+	Hostname bool
+}
+
+// Provenance records where and how an import was made. It never influences
+// the imported DAG. Fields that cannot be determined on the current platform
+// are left empty.
+type Provenance struct {
+	SourcePath string    `json:"source_path"` // Absolute path of the imported file or directory
+	FSType     string    `json:"fs_type"`     // Filesystem type of the source, such as "ext4"
+	DeviceID   string    `json:"device_id"`   // Device number of the source filesystem
+	Hostname   string    `json:"hostname"`    // Importing host, if enabled in ProvenanceOptions
+	Version    string    `json:"version"`     // Version of this library, see Version
+	Chunker    string    `json:"chunker"`     // Default chunker specification; see Content.Chunker for each file
+	Layout     string    `json:"layout"`      // DAG layout
+	RawLeaves  bool      `json:"raw_leaves"`  // Whether file data is stored in raw leaf blocks
+	CidBuilder string    `json:"cid_builder"` // CID version, codec and hash function
+	Started    time.Time `json:"started"`     // Wall-clock start of the import, in UTC
+	Finished   time.Time `json:"finished"`    // Wall-clock end of the import, in UTC
+}
+
+// WithProvenance records a Provenance in Result.Provenance: the absolute
+// source path, the source filesystem type and device where the platform
+// exposes them, optionally the hostname, the library version, the chunker,
+// layout and CID settings, and the start and finish times. The root CID is
+// the same with and without provenance.
+// Returns the importer for method chaining.
+func (imp *Importer) WithProvenance(opts ProvenanceOptions) *Importer {
+	imp.provOpts = &opts
+	return imp
+}
+
+// startProvenance records the parts of the provenance known before the
+// import starts. It returns nil if provenance is disabled.
+func (imp *Importer) startProvenance() *Provenance {
+	if imp.provOpts == nil {
+		return nil
+	}
+
+	p := &Provenance{
+		Version:    Version,
+		Chunker:    imp.defaultChunker(),
+		Layout:     "balanced",
+		RawLeaves:  imp.rawLeaves(),
+		CidBuilder: builderString(imp.cidBuilder),
+		Started:    imp.clock.Now().UTC(),
+	}
+	if !imp.streamed() {
+		if abs, err := filepath.Abs(imp.path); err == nil {
+			p.SourcePath = abs
+		}
+		p.FSType, p.DeviceID = sourceFilesystem(sourcePath(imp.path))
+	}
+	if imp.provOpts.Hostname {
+		p.Hostname, _ = os.Hostname()
+	}
+	return p
+}
+
+// builderString describes a CID builder, such as "cidv1 dag-pb sha2-256".
+func builderString(b cid.Builder) string {
+	switch b := b.(type) {
+	case cid.V1Builder:
+		return fmt.Sprintf("cidv1 %s %s", multicodec.Code(b.Codec), multicodec.Code(b.MhType))
+	case cid.V0Builder:
+		return "cidv0 dag-pb sha2-256"
+	case cid.Prefix:
+		return fmt.Sprintf("cidv%d %s %s", b.Version, multicodec.Code(b.Codec), multicodec.Code(b.MhType))
+	case nil:
+		return ""
+	default:
+		return fmt.Sprintf("%T %s", b, multicodec.Code(b.GetCodec()))
+	}
+}
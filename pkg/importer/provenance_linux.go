@@ -0,0 +1,45 @@
+package importer
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// fsTypes names the statfs magic numbers of common Linux filesystems.
+var fsTypes = map[int64]string{
+	0x9123683e: "btrfs",
+	0xef53:     "ext4",
+	0x4244:     "hfs",
+	0x794c7630: "overlay",
+	0x6969:     "nfs",
+	0x5346544e: "ntfs",
+	0xff534d42: "cifs",
+	0x65735546: "fuse",
+	0x01021994: "tmpfs",
+	0x4d44:     "vfat",
+	0x58465342: "xfs",
+	0x2fc12fc1: "zfs",
+	0xf2f52010: "f2fs",
+	0x9fa0:     "proc",
+}
+
+// sourceFilesystem returns the filesystem type and device number of path.
+// Unknown filesystem types are reported by their magic number.
+func sourceFilesystem(path string) (fsType, device string) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err == nil {
+		fsType = fsTypes[int64(fs.Type)]
+		if fsType == "" {
+			fsType = fmt.Sprintf("0x%x", fs.Type)
+		}
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		if st, ok := info.Sys().(*syscall.Stat_t); ok {
+			device = strconv.FormatUint(uint64(st.Dev), 10)
+		}
+	}
+	return fsType, device
+}
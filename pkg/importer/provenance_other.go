@@ -0,0 +1,8 @@
+//go:build !linux
+
+package importer
+
+// sourceFilesystem returns empty values outside Linux.
+func sourceFilesystem(path string) (fsType, device string) {
+	return "", ""
+}
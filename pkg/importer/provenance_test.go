@@ -0,0 +1,108 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestImporter_WithProvenance(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	dir := writeTree(t, map[string][]byte{
+		"a.txt":     []byte("alpha"),
+		"dir/b.txt": []byte("beta"),
+	})
+	ctx := context.Background()
+
+	plain, err := NewImporter(bs, dir).Import(ctx)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if plain.Provenance != nil {
+		t.Errorf("Provenance = %+v without WithProvenance", plain.Provenance)
+	}
+
+	before := time.Now().UTC()
+	result, err := NewImporter(bs, dir).WithProvenance(ProvenanceOptions{}).Import(ctx)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	after := time.Now().UTC()
+
+	if result.RootCid != plain.RootCid {
+		t.Errorf("RootCid = %s with provenance, %s without", result.RootCid, plain.RootCid)
+	}
+
+	p := result.Provenance
+	if p == nil {
+		t.Fatal("Provenance is nil")
+	}
+	abs, _ := filepath.Abs(dir)
+	if p.SourcePath != abs {
+		t.Errorf("SourcePath = %q, want %q", p.SourcePath, abs)
+	}
+	if p.Hostname != "" {
+		t.Errorf("Hostname = %q without opting in", p.Hostname)
+	}
+	if p.Version == "" || p.Version != Version {
+		t.Errorf("Version = %q, want %q", p.Version, Version)
+	}
+	if p.Chunker != "size-1048576" || p.Layout != "balanced" || !p.RawLeaves {
+		t.Errorf("chunker settings = %q, %q, %v", p.Chunker, p.Layout, p.RawLeaves)
+	}
+	if p.CidBuilder != "cidv1 dag-pb sha2-256" {
+		t.Errorf("CidBuilder = %q", p.CidBuilder)
+	}
+	if p.Started.Before(before) || p.Finished.Before(p.Started) || p.Finished.After(after) {
+		t.Errorf("timestamps %v - %v outside %v - %v", p.Started, p.Finished, before, after)
+	}
+	if runtime.GOOS == "linux" && (p.FSType == "" || p.DeviceID == "") {
+		t.Errorf("filesystem = %q, device = %q; want both on linux", p.FSType, p.DeviceID)
+	}
+
+	// The JSON form is stable and round-trips
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	again, _ := json.Marshal(p)
+	if !bytes.Equal(data, again) {
+		t.Errorf("JSON is not stable:\n%s\n%s", data, again)
+	}
+	var decoded Provenance
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if redone, _ := json.Marshal(decoded); !bytes.Equal(redone, data) {
+		t.Errorf("JSON round trip changed the provenance:\n%s\n%s", data, redone)
+	}
+}
+
+func TestImporter_WithProvenance_Hostname(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	src := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(src, []byte("single file"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewImporter(bs, src).WithProvenance(ProvenanceOptions{Hostname: true}).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		t.Skipf("hostname unavailable: %v", err)
+	}
+	if result.Provenance.Hostname != host {
+		t.Errorf("Hostname = %q, want %q", result.Provenance.Hostname, host)
+	}
+}
@@ -0,0 +1,89 @@
+package importer
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/files"
+)
+
+// ImportReader imports the data read from r into bs as a single file named
+// name without writing it to disk, for example the body of an HTTP upload.
+// The name is cleaned like the name of a file on disk, and the result has
+// the same Packages and Contents as importing a file of that name with the
+// same data.
+//
+// size is the number of bytes r is expected to deliver and is used as the
+// progress total. Pass -1 if it is unknown: the total then grows with the
+// bytes read, so that it always equals the bytes imported so far. Either
+// way r is read until EOF, and Result.Size and the size in Result.Contents
+// are the bytes actually read. An error returned by r fails the import.
+//
+// The options configure the underlying importer, for example
+// func(imp *Importer) { imp.WithChecksums(true) }. Extended attributes, scan
+// reports, prescanned listings and the content index do not apply to a
+// reader and are ignored.
+func ImportReader(ctx context.Context, bs blockstore.Blockstore, name string, size int64, r io.Reader, opts ...StreamOption) (*Result, error) {
+	imp := NewImporter(bs, name)
+	for _, opt := range opts {
+		opt(imp)
+	}
+	imp.reader = &readerSource{imp: imp, name: filepath.Base(imp.path), size: size, r: r}
+	return imp.Import(ctx)
+}
+
+// readerSource is the data of an ImportReader import, read once.
+type readerSource struct {
+	imp  *Importer
+	name string    // Name as given, before cleaning
+	size int64     // Expected size; negative if unknown
+	r    io.Reader // Data of the file
+}
+
+// slice returns the reader as a single file, as sliceSingleFile does for a
+// file on disk.
+func (s *readerSource) slice() files.Directory {
+	cleanName := cleanFilename(s.name)
+	s.imp.noteRawName(cleanName, s.name)
+
+	entries := []files.DirEntry{
+		files.FileEntry(cleanName, &readerFile{Reader: s.r, source: s}),
+	}
+	return files.NewSliceDirectory([]files.DirEntry{
+		files.FileEntry("folder", files.NewSliceDirectory(entries)),
+	})
+}
+
+// readerFile is the file of an ImportReader import. When the size is
+// unknown, it grows the progress total by the bytes it reads.
+type readerFile struct {
+	io.Reader
+	source *readerSource
+}
+
+func (f *readerFile) Close() error         { return nil }
+func (f *readerFile) Size() (int64, error) { return f.source.size, nil }
+func (f *readerFile) Mode() os.FileMode    { return 0o644 }
+func (f *readerFile) ModTime() time.Time   { return time.Time{} }
+
+func (f *readerFile) Seek(int64, int) (int64, error) {
+	return 0, files.ErrNotSupported
+}
+
+func (f *readerFile) Read(p []byte) (int, error) {
+	n, err := f.Reader.Read(p)
+	if n > 0 && f.source.size < 0 && f.source.imp.tracker != nil {
+		f.source.imp.tracker.grow(int64(n))
+	}
+	return n, err
+}
+
+// streamed reports whether the import reads a stream instead of the
+// filesystem at the importer's path.
+func (imp *Importer) streamed() bool {
+	return imp.tar != nil || imp.reader != nil
+}
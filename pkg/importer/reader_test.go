@@ -0,0 +1,118 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// readerData returns size bytes that do not deduplicate into few blocks.
+func readerData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i*7 + i>>12)
+	}
+	return data
+}
+
+func TestImportReader(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	for _, size := range []int{0, 1000, 5<<20 + 17} {
+		data := readerData(size)
+		dir := writeTree(t, map[string][]byte{"upload.bin": data})
+		want, err := NewImporter(bs, filepath.Join(dir, "upload.bin")).Import(context.Background())
+		if err != nil {
+			t.Fatalf("Import failed: %v", err)
+		}
+
+		for _, declared := range []int64{int64(size), -1} {
+			var calls int
+			var completed, total int64
+			progress := func(imp *Importer) {
+				imp.WithProgress(func(c, tot int64, _ string) {
+					calls++
+					completed, total = c, tot
+					if declared < 0 && tot != c {
+						t.Errorf("size %d: unknown size reported %d of %d", size, c, tot)
+					}
+				})
+			}
+			got, err := ImportReader(context.Background(), bs, "upload.bin", declared, bytes.NewReader(data), progress)
+			if err != nil {
+				t.Fatalf("size %d, declared %d: ImportReader failed: %v", size, declared, err)
+			}
+
+			if got.RootCid != want.RootCid || got.Size != want.Size || got.FileName != want.FileName {
+				t.Errorf("size %d, declared %d: got %s %d %q, want %s %d %q", size, declared,
+					got.RootCid, got.Size, got.FileName, want.RootCid, want.Size, want.FileName)
+			}
+			if !reflect.DeepEqual(got.Contents, want.Contents) {
+				t.Errorf("size %d, declared %d: Contents = %+v, want %+v", size, declared, got.Contents, want.Contents)
+			}
+			if !reflect.DeepEqual(got.Packages, want.Packages) {
+				t.Errorf("size %d, declared %d: Packages differ from a file import", size, declared)
+			}
+			if !reflect.DeepEqual(got.EmptyFiles, want.EmptyFiles) {
+				t.Errorf("size %d, declared %d: EmptyFiles = %q, want %q", size, declared, got.EmptyFiles, want.EmptyFiles)
+			}
+			if size > 0 && (calls == 0 || completed != int64(size) || total != int64(size)) {
+				t.Errorf("size %d, declared %d: progress %d of %d after %d calls", size, declared, completed, total, calls)
+			}
+		}
+	}
+}
+
+func TestImportReader_CleansName(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	result, err := ImportReader(context.Background(), bs, "dir/re:port?.txt", 5, bytes.NewReader([]byte("hello")))
+	if err != nil {
+		t.Fatalf("ImportReader failed: %v", err)
+	}
+	want := cleanFilename("re:port?.txt")
+	if result.FileName != want || len(result.Contents) != 1 || result.Contents[0].Name != want {
+		t.Fatalf("FileName %q, Contents %+v, want the name %q", result.FileName, result.Contents, want)
+	}
+	if c := result.Contents[0]; c.Cid != resolve(t, bs, result.RootCid, c.Path).String() {
+		t.Errorf("Contents[0].Cid %s does not resolve below the root", c.Cid)
+	}
+}
+
+// failingReader returns the data of r and then err instead of EOF.
+type failingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err == io.EOF {
+		return n, f.err
+	}
+	return n, err
+}
+
+func TestImportReader_ReadError(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	errUpload := errors.New("connection reset")
+	data := readerData(3 << 20)
+	for _, declared := range []int64{int64(len(data)) * 2, -1} {
+		r := &failingReader{r: bytes.NewReader(data), err: errUpload}
+		result, err := ImportReader(context.Background(), bs, "upload.bin", declared, r)
+		if !errors.Is(err, errUpload) {
+			t.Errorf("declared %d: ImportReader error = %v, want %v", declared, err, errUpload)
+		}
+		if result != nil {
+			t.Errorf("declared %d: result %+v after a read error", declared, result)
+		}
+	}
+}
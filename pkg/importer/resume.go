@@ -0,0 +1,183 @@
+package importer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/tragoedia0722/repository/pkg/packaging"
+)
+
+// resumeKeyPrefix is the datastore namespace of the resume manifests.
+const resumeKeyPrefix = "/importer/resume"
+
+// WithResume lets an interrupted import of the same path be resumed. Every
+// file the import adds to the DAG is recorded in store, keyed by its path,
+// size and modification time, as soon as its blocks are written; pass the
+// datastore of the repository the blocks are imported into, so that the
+// record survives a crash. A later import of the same path links the
+// recorded node of a file that is unchanged instead of reading and chunking
+// it again, provided all of its blocks are still in the blockstore, and
+// reports its bytes as completed at once. The manifest is removed when an
+// import succeeds.
+//
+// The DAG and Result.Contents are the same as with a clean import. A file
+// is only trusted to be unchanged if its size and modification time are,
+// and a different chunker, CID format or stripe size makes the recorded
+// node miss. Tar archives, ImportReader and files without a modification
+// time are always read. A nil store, the default, disables resuming.
+// Returns the importer for method chaining.
+func (imp *Importer) WithResume(store ds.Datastore) *Importer {
+	imp.resume = store
+	return imp
+}
+
+// resumeEntry is a file recorded in the resume manifest.
+type resumeEntry struct {
+	Size    int64    `json:"size"`
+	ModTime int64    `json:"mtime"`   // Unix nanoseconds
+	Spec    string   `json:"spec"`    // Chunker, CID format and stripe size the node was built with
+	Cid     string   `json:"cid"`     // File node without metadata
+	SHA256  string   `json:"sha256"`  // Empty unless the import recorded checksums
+	Stripes []string `json:"stripes"` // Stripe roots of a striped file
+}
+
+// resumePrefix returns the namespace of the manifest of this import, or
+// false if it is not resumable.
+func (imp *Importer) resumePrefix() (ds.Key, bool) {
+	if imp.resume == nil || imp.tar != nil || imp.reader != nil {
+		return ds.Key{}, false
+	}
+	root, err := filepath.Abs(imp.path)
+	if err != nil {
+		return ds.Key{}, false
+	}
+	sum := sha256.Sum256([]byte(root))
+	return ds.NewKey(resumeKeyPrefix).ChildString(hex.EncodeToString(sum[:16])), true
+}
+
+// resumeKey returns the key of the file at profilePath, or false if the
+// import is not resumable.
+func (imp *Importer) resumeKey(profilePath string) (ds.Key, bool) {
+	prefix, ok := imp.resumePrefix()
+	if !ok {
+		return prefix, false
+	}
+	return prefix.ChildString(hex.EncodeToString([]byte(profilePath))), true
+}
+
+// resumeSpec describes how a file of size bytes is built with chunker.
+func (imp *Importer) resumeSpec(chunker string, size int64) string {
+	spec := imp.indexSpec(chunker)
+	if imp.stripes(size) {
+		spec += fmt.Sprintf(" stripes=%d", imp.stripeSize)
+	}
+	return spec
+}
+
+// resumedFile returns the node a previous run recorded for the file at
+// profilePath, with its blocks, if the file is unchanged and all blocks are
+// in the blockstore.
+func (imp *Importer) resumedFile(ctx context.Context, profilePath string, size int64, mtime time.Time, chunker string) (*resumeEntry, ipld.Node, []string, bool) {
+	key, ok := imp.resumeKey(profilePath)
+	if !ok || size < 0 || mtime.IsZero() {
+		return nil, nil, nil, false
+	}
+	data, err := imp.resume.Get(ctx, key)
+	if err != nil {
+		return nil, nil, nil, false
+	}
+	var e resumeEntry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, nil, nil, false
+	}
+	if e.Size != size || e.ModTime != mtime.UnixNano() || e.Spec != imp.resumeSpec(chunker, size) {
+		return nil, nil, nil, false
+	}
+	if imp.checksums && e.SHA256 == "" {
+		return nil, nil, nil, false
+	}
+
+	c, err := cid.Decode(e.Cid)
+	if err != nil {
+		return nil, nil, nil, false
+	}
+	if ok, err := imp.blockStore.Has(ctx, c); err != nil || !ok {
+		return nil, nil, nil, false
+	}
+	blocks, err := packaging.CollectBlocks(ctx, imp.dagService, c)
+	if err != nil {
+		return nil, nil, nil, false
+	}
+	nd, err := imp.dagService.Get(ctx, c)
+	if err != nil {
+		return nil, nil, nil, false
+	}
+	return &e, nd, blocks, true
+}
+
+// recordResume records the built file of job in the resume manifest.
+// Failing to record it only costs reading the file again on resume.
+func (imp *Importer) recordResume(ctx context.Context, job *fileJob) {
+	key, ok := imp.resumeKey(job.profilePath)
+	if !ok || job.size < 0 || job.mtime.IsZero() {
+		return
+	}
+	e := resumeEntry{
+		Size:    job.size,
+		ModTime: job.mtime.UnixNano(),
+		Spec:    imp.resumeSpec(job.chunker, job.size),
+		Cid:     job.node.Cid().String(),
+		Stripes: job.stripes,
+	}
+	if job.checksum != nil {
+		e.SHA256 = hex.EncodeToString(job.checksum)
+	}
+	data, err := json.Marshal(e)
+	if err == nil {
+		err = imp.resume.Put(ctx, key, data)
+	}
+	if err != nil {
+		imp.warn(fmt.Errorf("failed to record %s for resuming: %w", job.profilePath, err))
+	}
+}
+
+// clearResume removes the resume manifest of a successful import.
+func (imp *Importer) clearResume(ctx context.Context) error {
+	prefix, ok := imp.resumePrefix()
+	if !ok {
+		return nil
+	}
+	results, err := imp.resume.Query(ctx, query.Query{Prefix: prefix.String(), KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	var keys []ds.Key
+	for r := range results.Next() {
+		if r.Error != nil {
+			_ = results.Close()
+			return r.Error
+		}
+		keys = append(keys, ds.NewKey(r.Key))
+	}
+	if err := results.Close(); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, key := range keys {
+		if err := imp.resume.Delete(ctx, key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,98 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+// manifestKeys returns the number of keys in the resume manifests of store.
+func manifestKeys(t *testing.T, store ds.Datastore) int {
+	t.Helper()
+
+	results, err := store.Query(context.Background(), query.Query{Prefix: resumeKeyPrefix, KeysOnly: true})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	return len(entries)
+}
+
+func TestImporter_WithResume(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := func(n int) []byte {
+		b := make([]byte, n)
+		rng.Read(b)
+		return b
+	}
+	tree := map[string][]byte{
+		"a.bin":     random(1024*1024 + 3),
+		"b.bin":     random(900 * 1024),
+		"c.bin":     random(1024 * 1024),
+		"sub/d.txt": []byte("small"),
+	}
+	dir := writeTree(t, tree)
+
+	clean, cleanup := createTestBlockstore(t)
+	defer cleanup()
+	want, err := NewImporter(clean, dir).WithChecksums(true).Import(context.Background())
+	if err != nil {
+		t.Fatalf("clean Import failed: %v", err)
+	}
+
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+	store := ds.NewMapDatastore()
+
+	// Interrupt the import once two files are added
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err = NewImporter(bs, dir).WithChecksums(true).WithResume(store).WithProgressEvents(func(ev ProgressEvent) {
+		if ev.FilesCompleted == 2 {
+			cancel()
+		}
+	}).Import(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Import error = %v, want context.Canceled", err)
+	}
+	if n := manifestKeys(t, store); n != 2 {
+		t.Fatalf("manifest holds %d files, want 2", n)
+	}
+
+	// A recorded file that changed since is read again
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "b.bin"), later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	var read int64
+	imp := countSourceReads(NewImporter(bs, dir).WithChecksums(true).WithResume(store), &read)
+	got, err := imp.Import(context.Background())
+	if err != nil {
+		t.Fatalf("resumed Import failed: %v", err)
+	}
+	if got.RootCid != want.RootCid {
+		t.Errorf("RootCid = %s, want %s of a clean import", got.RootCid, want.RootCid)
+	}
+	if !reflect.DeepEqual(got.Contents, want.Contents) {
+		t.Error("Contents differ from a clean import")
+	}
+	wantRead := int64(len(tree["b.bin"]) + len(tree["c.bin"]) + len(tree["sub/d.txt"]))
+	if read != wantRead {
+		t.Errorf("resumed import read %d bytes, want %d of the files not recorded or changed", read, wantRead)
+	}
+	if n := manifestKeys(t, store); n != 0 {
+		t.Errorf("manifest holds %d files after a successful import, want none", n)
+	}
+}
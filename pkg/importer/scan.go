@@ -0,0 +1,208 @@
+package importer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tragoedia0722/repository/pkg/helper"
+)
+
+// Skip reasons reported in ScanReport.Skipped
+const (
+	SkipReasonHidden      = "hidden"
+	SkipReasonIgnored     = "ignored"
+	SkipReasonUnsupported = "unsupported file type"
+	SkipReasonSymlink     = "symlink"
+	SkipReasonBrokenLink  = "unresolvable symlink"
+)
+
+// ScanReport describes what Import would do for the importer's path,
+// computed without writing anything to the blockstore.
+type ScanReport struct {
+	Root           string           // Cleaned source path that was scanned
+	TotalBytes     int64            // Total size of regular files that would be imported
+	FileCount      int              // Number of regular files
+	DirCount       int              // Number of directories, including the root directory
+	SymlinkCount   int              // Number of symlinks
+	LargestFile    ScanEntry        // Largest regular file (zero value if there are no files)
+	Files          []ScanEntry      // Regular files that would be imported, in walk order
+	ExtensionBytes map[string]int64 // Bytes per lower-cased extension ("" for none)
+	Renamed        []RenamedEntry   // Entries whose names are changed by filename cleaning
+	Skipped        []SkippedEntry   // Entries that Import leaves out or cannot add
+}
+
+// ScanEntry identifies a single entry by its cleaned path relative to the import root.
+// For a single-file import the path is the cleaned file name.
+type ScanEntry struct {
+	Path string
+	Size int64
+}
+
+// RenamedEntry records a name that filename cleaning changes.
+type RenamedEntry struct {
+	Original string // Original path relative to the import root
+	Cleaned  string // Path as it will appear in the DAG
+}
+
+// SkippedEntry records an entry that Import leaves out (hidden files,
+// entries matching a WithIgnore pattern and symlinks under SymlinkSkip) or
+// cannot add (unsupported file types such as sockets and devices, and
+// symlinks SymlinkFollow cannot resolve). Entries below a skipped directory are
+// not listed.
+type SkippedEntry struct {
+	Path   string // Original path relative to the import root
+	Reason string
+}
+
+// Scan walks the importer's path and reports what Import would add, without
+// touching the blockstore. It applies the same filtering of hidden and
+// ignored entries and filename cleaning as Import.
+func (imp *Importer) Scan(ctx context.Context) (*ScanReport, error) {
+	filter, err := imp.newEntryFilter()
+	if err != nil {
+		return nil, err
+	}
+	lstat, err := os.Lstat(sourcePath(imp.path))
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ScanReport{
+		Root:           imp.path,
+		ExtensionBytes: make(map[string]int64),
+	}
+
+	if !lstat.IsDir() {
+		name := filepath.Base(imp.path)
+		cleaned := cleanFilename(name)
+		if cleaned != name {
+			report.Renamed = append(report.Renamed, RenamedEntry{Original: name, Cleaned: cleaned})
+		}
+		report.recordFile(cleaned, lstat.Size())
+		return report, nil
+	}
+
+	var links *linkWalk
+	if imp.symlinks == SymlinkFollow {
+		links = newLinkWalk(sourcePath(imp.path))
+	}
+	report.DirCount++
+	if err := imp.scanDir(ctx, report, filter, links, sourcePath(imp.path), "", ""); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// WithScan supplies a ScanReport obtained from Scan so Import can skip its own
+// size walk. The report is ignored if it was produced for a different path.
+// Returns the importer for method chaining.
+func (imp *Importer) WithScan(report *ScanReport) *Importer {
+	imp.scan = report
+	return imp
+}
+
+// ImportWithScan imports the importer's path like Import, reusing report from
+// an earlier Scan instead of walking the tree a second time to size it.
+// It is equivalent to WithScan(report).Import(ctx).
+func (imp *Importer) ImportWithScan(ctx context.Context, report *ScanReport) (*Result, error) {
+	return imp.WithScan(report).Import(ctx)
+}
+
+// scanDir scans the entries of dirPath that filter does not leave out.
+// origRel and cleanRel are the original and cleaned paths of the directory
+// relative to the import root, and links is its position in a walk
+// following symlinks, nil unless SymlinkFollow is set.
+func (imp *Importer) scanDir(ctx context.Context, report *ScanReport, filter *entryFilter, links *linkWalk, dirPath, origRel, cleanRel string) error {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		name := entry.Name()
+		entryOrig := filepath.Join(origRel, name)
+
+		if filter.skipName(name) {
+			report.Skipped = append(report.Skipped, SkippedEntry{Path: entryOrig, Reason: SkipReasonHidden})
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		mode := info.Mode()
+		if filter.ignored(filepath.ToSlash(entryOrig), mode.IsDir()) {
+			report.Skipped = append(report.Skipped, SkippedEntry{Path: entryOrig, Reason: SkipReasonIgnored})
+			continue
+		}
+		var real string // Resolved path of a followed symlink
+		if mode&os.ModeSymlink != 0 && imp.symlinks != SymlinkPreserve {
+			if imp.symlinks == SymlinkSkip {
+				report.Skipped = append(report.Skipped, SkippedEntry{Path: entryOrig, Reason: SkipReasonSymlink})
+				continue
+			}
+			if real, info, err = links.resolve(filepath.Join(dirPath, name)); err != nil {
+				report.Skipped = append(report.Skipped, SkippedEntry{Path: entryOrig, Reason: SkipReasonBrokenLink})
+				continue
+			}
+			mode = info.Mode()
+		}
+		cleanedName := cleanEntryName(name, mode.IsDir())
+		entryClean := filepath.Join(cleanRel, cleanedName)
+		if cleanedName != name {
+			report.Renamed = append(report.Renamed, RenamedEntry{Original: entryOrig, Cleaned: entryClean})
+		}
+
+		switch {
+		case mode.IsDir():
+			report.DirCount++
+			sub := filepath.Join(dirPath, name)
+			if real != "" {
+				sub = real
+			}
+			if err := imp.scanDir(ctx, report, filter, links.child(name, real), sub, entryOrig, entryClean); err != nil {
+				return err
+			}
+		case mode&os.ModeSymlink != 0:
+			report.SymlinkCount++
+		case mode.IsRegular():
+			report.recordFile(entryClean, info.Size())
+		default:
+			report.Skipped = append(report.Skipped, SkippedEntry{Path: entryOrig, Reason: SkipReasonUnsupported})
+		}
+	}
+
+	return nil
+}
+
+// recordFile accounts for a regular file in the report totals.
+func (r *ScanReport) recordFile(cleanPath string, size int64) {
+	r.FileCount++
+	r.TotalBytes += size
+	r.Files = append(r.Files, ScanEntry{Path: cleanPath, Size: size})
+	_, ext := helper.SplitExt(filepath.Base(cleanPath))
+	r.ExtensionBytes[strings.ToLower(ext)] += size
+
+	if r.FileCount == 1 || size > r.LargestFile.Size {
+		r.LargestFile = ScanEntry{Path: cleanPath, Size: size}
+	}
+}
+
+// scanFor returns the supplied scan report if it matches the importer's path.
+func (imp *Importer) scanFor() *ScanReport {
+	if imp.scan != nil && !imp.streamed() && imp.scan.Root == imp.path {
+		return imp.scan
+	}
+	return nil
+}
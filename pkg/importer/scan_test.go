@@ -0,0 +1,227 @@
+package importer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// createScanFixture creates a tree with nested directories, a renamed entry,
+// a hidden file and a symlink.
+func createScanFixture(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"a.txt":             "hello",
+		"b.TXT":             "hello world",
+		"sub/c.bin":         strings.Repeat("x", 4096),
+		"sub/deep/d.txt":    "deep",
+		"sub/bad<name>.txt": "renamed",
+		".hidden":           "ignored",
+	}
+	for rel, content := range files {
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", rel, err)
+		}
+	}
+	if err := os.Symlink("a.txt", filepath.Join(dir, "link")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	return dir
+}
+
+func TestImporter_Scan_MatchesImport(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	dir := createScanFixture(t)
+
+	report, err := NewImporter(bs, dir).Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	result, err := NewImporter(bs, dir).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	if report.TotalBytes != result.Size {
+		t.Errorf("TotalBytes = %d, Import Size = %d", report.TotalBytes, result.Size)
+	}
+	if report.FileCount != len(result.Contents) {
+		t.Errorf("FileCount = %d, Import Contents = %d", report.FileCount, len(result.Contents))
+	}
+	if report.DirCount != 3 {
+		t.Errorf("DirCount = %d, want 3", report.DirCount)
+	}
+	if report.SymlinkCount != 1 {
+		t.Errorf("SymlinkCount = %d, want 1", report.SymlinkCount)
+	}
+	if report.LargestFile.Path != filepath.Join("sub", "c.bin") || report.LargestFile.Size != 4096 {
+		t.Errorf("LargestFile = %+v", report.LargestFile)
+	}
+	if got := report.ExtensionBytes[".txt"]; got != int64(len("hello")+len("hello world")+len("deep")+len("renamed")) {
+		t.Errorf("ExtensionBytes[.txt] = %d", got)
+	}
+	if got := report.ExtensionBytes[".bin"]; got != 4096 {
+		t.Errorf("ExtensionBytes[.bin] = %d", got)
+	}
+
+	if len(report.Renamed) != 1 || report.Renamed[0].Cleaned != filepath.Join("sub", "bad_name_.txt") {
+		t.Errorf("Renamed = %+v", report.Renamed)
+	}
+	var listed int64
+	for _, f := range report.Files {
+		listed += f.Size
+	}
+	if len(report.Files) != report.FileCount || listed != report.TotalBytes {
+		t.Errorf("Files = %+v, want %d files of %d bytes", report.Files, report.FileCount, report.TotalBytes)
+	}
+	if report.Files[2] != (ScanEntry{Path: filepath.Join("sub", "bad_name_.txt"), Size: int64(len("renamed"))}) {
+		t.Errorf("Files[2] = %+v, want the cleaned name", report.Files[2])
+	}
+	if len(report.Skipped) != 1 || report.Skipped[0].Path != ".hidden" || report.Skipped[0].Reason != SkipReasonHidden {
+		t.Errorf("Skipped = %+v", report.Skipped)
+	}
+}
+
+func TestImporter_Scan_SingleFile(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	path := filepath.Join(t.TempDir(), "file:1.txt")
+	if err := os.WriteFile(path, []byte("single"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	report, err := NewImporter(bs, path).Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	if report.FileCount != 1 || report.TotalBytes != 6 || report.DirCount != 0 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if len(report.Renamed) != 1 || report.Renamed[0].Cleaned != "file_1.txt" {
+		t.Errorf("Renamed = %+v", report.Renamed)
+	}
+}
+
+func TestImporter_Scan_DoesNotTouchBlockstore(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	dir := createScanFixture(t)
+	if _, err := NewImporter(bs, dir).Scan(context.Background()); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	keys, err := bs.AllKeysChan(context.Background())
+	if err != nil {
+		t.Fatalf("AllKeysChan failed: %v", err)
+	}
+	for range keys {
+		t.Fatal("Scan wrote blocks to the blockstore")
+	}
+}
+
+func TestImporter_Scan_ContextCancelled(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := NewImporter(bs, createScanFixture(t)).Scan(ctx); err == nil {
+		t.Error("expected error for cancelled context")
+	}
+}
+
+func TestImporter_WithScan(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	dir := createScanFixture(t)
+	report, err := NewImporter(bs, dir).Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	t.Run("uses scan total for progress", func(t *testing.T) {
+		var lastTotal int64
+		imp := NewImporter(bs, dir).WithScan(report).WithProgress(func(completed, total int64, file string) {
+			lastTotal = total
+		})
+
+		result, err := imp.Import(context.Background())
+		if err != nil {
+			t.Fatalf("Import failed: %v", err)
+		}
+		if lastTotal != report.TotalBytes {
+			t.Errorf("progress total = %d, want %d", lastTotal, report.TotalBytes)
+		}
+		if result.Size != report.TotalBytes {
+			t.Errorf("Size = %d, want %d", result.Size, report.TotalBytes)
+		}
+	})
+
+	t.Run("ImportWithScan", func(t *testing.T) {
+		var lastTotal int64
+		imp := NewImporter(bs, dir).WithProgress(func(completed, total int64, file string) {
+			lastTotal = total
+		})
+
+		if _, err := imp.ImportWithScan(context.Background(), report); err != nil {
+			t.Fatalf("ImportWithScan failed: %v", err)
+		}
+		if lastTotal != report.TotalBytes {
+			t.Errorf("progress total = %d, want %d", lastTotal, report.TotalBytes)
+		}
+	})
+
+	t.Run("tolerates changes after scan", func(t *testing.T) {
+		if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello, changed"), 0o644); err != nil {
+			t.Fatalf("failed to modify file: %v", err)
+		}
+
+		result, err := NewImporter(bs, dir).WithScan(report).Import(context.Background())
+		if err != nil {
+			t.Fatalf("Import failed: %v", err)
+		}
+
+		fresh, err := NewImporter(bs, dir).Import(context.Background())
+		if err != nil {
+			t.Fatalf("Import failed: %v", err)
+		}
+		if result.RootCid != fresh.RootCid {
+			t.Errorf("RootCid with stale scan %s != fresh %s", result.RootCid, fresh.RootCid)
+		}
+		if result.Size != fresh.Size {
+			t.Errorf("Size with stale scan = %d, want %d", result.Size, fresh.Size)
+		}
+	})
+
+	t.Run("ignores scan for another path", func(t *testing.T) {
+		other := t.TempDir()
+		if err := os.WriteFile(filepath.Join(other, "x.txt"), []byte("other"), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+
+		result, err := NewImporter(bs, other).WithScan(report).Import(context.Background())
+		if err != nil {
+			t.Fatalf("Import failed: %v", err)
+		}
+		if result.Size != 5 {
+			t.Errorf("Size = %d, want 5", result.Size)
+		}
+	})
+}
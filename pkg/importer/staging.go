@@ -0,0 +1,294 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	levelds "github.com/ipfs/go-ds-leveldb"
+	"github.com/tragoedia0722/repository/internal/artifact"
+)
+
+// stagingBlockstore holds the blocks written by an atomic import until the
+// whole tree has been built. Blocks are kept in memory up to a cap and then
+// spilled to a temporary LevelDB datastore. Reads fall through to the real
+// blockstore, and blocks it already holds are not staged again.
+//
+// Only reads reach the embedded real blockstore until commit.
+type stagingBlockstore struct {
+	blockstore.Blockstore
+
+	mu        sync.Mutex
+	maxMem    int64                    // Bytes kept in memory before spilling
+	memBytes  int64                    // Bytes currently held in mem
+	mem       map[cid.Cid]blocks.Block // Blocks staged in memory
+	spilled   map[cid.Cid]struct{}     // Blocks staged on disk
+	order     []cid.Cid                // Staged CIDs in write order
+	parentDir string                   // Directory the spill directory is created in
+	spillDir  string                   // Spill directory; empty until the first spill
+	spillDS   *levelds.Datastore       // Datastore backing spill
+	spill     blockstore.Blockstore    // On-disk staging area
+	committed []cid.Cid                // Blocks written to the real blockstore so far
+}
+
+// newStagingBlockstore stages blocks for real, spilling below parentDir (the
+// system temporary directory if empty) once maxMem bytes are held in memory.
+func newStagingBlockstore(real blockstore.Blockstore, maxMem int64, parentDir string) *stagingBlockstore {
+	return &stagingBlockstore{
+		Blockstore: real,
+		maxMem:     maxMem,
+		mem:        make(map[cid.Cid]blocks.Block),
+		spilled:    make(map[cid.Cid]struct{}),
+		parentDir:  parentDir,
+	}
+}
+
+// isStaged reports whether c is held in the staging area. Callers hold s.mu.
+func (s *stagingBlockstore) isStaged(c cid.Cid) bool {
+	if _, ok := s.mem[c]; ok {
+		return true
+	}
+	_, ok := s.spilled[c]
+	return ok
+}
+
+// spillStore returns the on-disk staging area, creating it on first use.
+// Callers hold s.mu.
+func (s *stagingBlockstore) spillStore() (blockstore.Blockstore, error) {
+	if s.spill != nil {
+		return s.spill, nil
+	}
+
+	dir, err := os.MkdirTemp(s.parentDir, artifact.StagingDirPrefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	d, err := levelds.NewDatastore(dir, nil)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to open staging datastore: %w", err)
+	}
+
+	s.spillDir = dir
+	s.spillDS = d
+	s.spill = blockstore.NewBlockstore(d)
+	return s.spill, nil
+}
+
+// put stages b unless it is already staged or present in the real
+// blockstore. Callers hold s.mu.
+func (s *stagingBlockstore) put(ctx context.Context, b blocks.Block) error {
+	c := b.Cid()
+	if s.isStaged(c) {
+		return nil
+	}
+	has, err := s.Blockstore.Has(ctx, c)
+	if err != nil {
+		return err
+	}
+	if has {
+		return nil
+	}
+
+	size := int64(len(b.RawData()))
+	if s.memBytes+size <= s.maxMem {
+		s.mem[c] = b
+		s.memBytes += size
+	} else {
+		spill, err := s.spillStore()
+		if err != nil {
+			return err
+		}
+		if err := spill.Put(ctx, b); err != nil {
+			return err
+		}
+		s.spilled[c] = struct{}{}
+	}
+	s.order = append(s.order, c)
+	return nil
+}
+
+func (s *stagingBlockstore) Put(ctx context.Context, b blocks.Block) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.put(ctx, b)
+}
+
+func (s *stagingBlockstore) PutMany(ctx context.Context, blks []blocks.Block) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, b := range blks {
+		if err := s.put(ctx, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// staged returns a staged block, or nil if c is not staged.
+func (s *stagingBlockstore) staged(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if b, ok := s.mem[c]; ok {
+		return b, nil
+	}
+	if _, ok := s.spilled[c]; ok {
+		return s.spill.Get(ctx, c)
+	}
+	return nil, nil
+}
+
+func (s *stagingBlockstore) Has(ctx context.Context, c cid.Cid) (bool, error) {
+	s.mu.Lock()
+	staged := s.isStaged(c)
+	s.mu.Unlock()
+	if staged {
+		return true, nil
+	}
+	return s.Blockstore.Has(ctx, c)
+}
+
+func (s *stagingBlockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	b, err := s.staged(ctx, c)
+	if err != nil || b != nil {
+		return b, err
+	}
+	return s.Blockstore.Get(ctx, c)
+}
+
+func (s *stagingBlockstore) GetSize(ctx context.Context, c cid.Cid) (int, error) {
+	b, err := s.staged(ctx, c)
+	if err != nil {
+		return 0, err
+	}
+	if b != nil {
+		return len(b.RawData()), nil
+	}
+	return s.Blockstore.GetSize(ctx, c)
+}
+
+// DeleteBlock removes a staged block. Blocks in the real blockstore are
+// never deleted by an import.
+func (s *stagingBlockstore) DeleteBlock(ctx context.Context, c cid.Cid) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if b, ok := s.mem[c]; ok {
+		s.memBytes -= int64(len(b.RawData()))
+		delete(s.mem, c)
+		return nil
+	}
+	if _, ok := s.spilled[c]; ok {
+		delete(s.spilled, c)
+		return s.spill.DeleteBlock(ctx, c)
+	}
+	return nil
+}
+
+// commit copies every staged block into the real blockstore in PutMany
+// batches of about commitBatchBytes. Puts are idempotent, so an import whose
+// commit failed converges when it is run again.
+func (s *stagingBlockstore) commit(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+
+	var batch []blocks.Block
+	var batchBytes int
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.Blockstore.PutMany(ctx, batch); err != nil {
+			return fmt.Errorf("failed to commit staged blocks: %w", err)
+		}
+		for _, b := range batch {
+			s.committed = append(s.committed, b.Cid())
+		}
+		batch, batchBytes = batch[:0], 0
+		return nil
+	}
+
+	for _, c := range s.order {
+		b, err := s.staged(ctx, c)
+		if err != nil {
+			return err
+		}
+		if b == nil {
+			continue // Deleted after it was staged
+		}
+		batch = append(batch, b)
+		batchBytes += len(b.RawData())
+		if batchBytes >= commitBatchBytes {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+// discard drops every staged block and removes the spill directory.
+func (s *stagingBlockstore) discard() error {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.mem = make(map[cid.Cid]blocks.Block)
+	s.spilled = make(map[cid.Cid]struct{})
+	s.order = nil
+	s.memBytes = 0
+
+	var err error
+	if s.spillDS != nil {
+		err = s.spillDS.Close()
+		s.spillDS, s.spill = nil, nil
+	}
+	if s.spillDir != "" {
+		if rmErr := os.RemoveAll(s.spillDir); err == nil {
+			err = rmErr
+		}
+		s.spillDir = ""
+	}
+	return err
+}
+
+// WithAtomicCommit builds the DAG against a staging area and writes blocks
+// to the blockstore only after the whole tree has been built, so a failed or
+// cancelled import leaves no orphaned blocks behind.
+//
+// Up to maxMemoryBytes of blocks are staged in memory; the rest go to a
+// temporary on-disk datastore below the directory set by WithStagingDir.
+// Import returns only after every staged block has been committed with
+// PutMany batches. If the commit itself fails, running the same import
+// again completes it, and LastPartial lists the blocks of the batches
+// already written.
+// The staging area is removed when Import returns.
+// Returns the importer for method chaining.
+func (imp *Importer) WithAtomicCommit(maxMemoryBytes int64) *Importer {
+	imp.atomic = true
+	imp.stageMem = maxMemoryBytes
+	return imp
+}
+
+// WithStagingDir sets the directory in which an atomic import creates its
+// on-disk staging area, such as a directory inside the repository so staged
+// blocks stay on the same disk. The system temporary directory is used by
+// default. A staging area left behind by a crash is removed by
+// Repository.CleanupArtifacts when this directory is passed in its Dirs.
+// Returns the importer for method chaining.
+func (imp *Importer) WithStagingDir(dir string) *Importer {
+	imp.stageDir = dir
+	return imp
+}
@@ -0,0 +1,170 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/tragoedia0722/repository/pkg/packaging"
+)
+
+// stagingTree returns a tree large enough to spill past a small memory cap.
+func stagingTree() map[string][]byte {
+	rng := rand.New(rand.NewSource(7))
+	tree := make(map[string][]byte)
+	for i := 0; i < 6; i++ {
+		b := make([]byte, 300*1024+i)
+		rng.Read(b)
+		tree[fmt.Sprintf("dir%d/f%d.bin", i%2, i)] = b
+	}
+	return tree
+}
+
+// blockCount returns the number of blocks in bs.
+func blockCount(t *testing.T, bs blockstore.Blockstore) int {
+	t.Helper()
+
+	keys, err := bs.AllKeysChan(context.Background())
+	if err != nil {
+		t.Fatalf("AllKeysChan failed: %v", err)
+	}
+	n := 0
+	for range keys {
+		n++
+	}
+	return n
+}
+
+// checkComplete fails unless every block reachable from root is in bs.
+func checkComplete(t *testing.T, bs blockstore.Blockstore, root string) {
+	t.Helper()
+
+	c, err := cid.Decode(root)
+	if err != nil {
+		t.Fatalf("invalid root %q: %v", root, err)
+	}
+	dag := merkledag.NewDAGService(blockservice.New(bs, nil))
+	if _, err := packaging.CollectBlocks(context.Background(), dag, c); err != nil {
+		t.Errorf("blockstore is incomplete: %v", err)
+	}
+}
+
+// emptyDir fails unless dir has no entries.
+func emptyDir(t *testing.T, dir string) {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("staging area left behind: %v", entries)
+	}
+}
+
+func TestImporter_WithAtomicCommit_CancelLeavesStoreUntouched(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	before := blockCount(t, bs)
+	stageDir := t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	imp := NewImporter(bs, writeTree(t, stagingTree())).
+		WithAtomicCommit(64 * 1024).
+		WithStagingDir(stageDir).
+		WithProgress(func(completed, _ int64, _ string) {
+			if completed > 1024*1024 {
+				cancel()
+			}
+		})
+
+	if _, err := imp.Import(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Import error = %v, want context.Canceled", err)
+	}
+	if after := blockCount(t, bs); after != before {
+		t.Errorf("blockstore holds %d blocks after cancel, want %d", after, before)
+	}
+	if partial := imp.LastPartial(); partial == nil || len(partial.Blocks) != 0 {
+		t.Errorf("LastPartial() = %+v, want no committed blocks", partial)
+	}
+	emptyDir(t, stageDir)
+}
+
+func TestImporter_WithAtomicCommit_MatchesDirectImport(t *testing.T) {
+	dir := writeTree(t, stagingTree())
+
+	direct, cleanupDirect := createTestBlockstore(t)
+	defer cleanupDirect()
+	want, err := NewImporter(direct, dir).Import(context.Background())
+	if err != nil {
+		t.Fatalf("direct import failed: %v", err)
+	}
+
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+	stageDir := t.TempDir()
+
+	got, err := NewImporter(bs, dir).WithAtomicCommit(64 * 1024).WithStagingDir(stageDir).Import(context.Background())
+	if err != nil {
+		t.Fatalf("atomic import failed: %v", err)
+	}
+
+	if got.RootCid != want.RootCid {
+		t.Errorf("RootCid = %s, want %s", got.RootCid, want.RootCid)
+	}
+	if len(got.Packages) != len(want.Packages) {
+		t.Errorf("got %d packages, want %d", len(got.Packages), len(want.Packages))
+	}
+	checkComplete(t, bs, got.RootCid)
+	emptyDir(t, stageDir)
+}
+
+// crashingBlockstore writes half of every PutMany batch and then fails, as
+// a crash part way through a commit would.
+type crashingBlockstore struct {
+	blockstore.Blockstore
+}
+
+func (c *crashingBlockstore) PutMany(ctx context.Context, blks []blocks.Block) error {
+	if err := c.Blockstore.PutMany(ctx, blks[:len(blks)/2]); err != nil {
+		return err
+	}
+	return errors.New("simulated crash")
+}
+
+func TestImporter_WithAtomicCommit_FailedCommitConverges(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	dir := writeTree(t, stagingTree())
+	before := blockCount(t, bs)
+
+	// Write half of the first batch, then fail as a crash would
+	imp := NewImporter(&crashingBlockstore{Blockstore: bs}, dir).WithAtomicCommit(1 << 20)
+	if _, err := imp.Import(context.Background()); err == nil {
+		t.Fatal("Import succeeded despite the failing commit")
+	}
+	if blockCount(t, bs) == before {
+		t.Fatal("simulated crash wrote no blocks")
+	}
+	if partial := imp.LastPartial(); partial == nil {
+		t.Fatal("LastPartial() = nil after a failed commit")
+	}
+
+	result, err := NewImporter(bs, dir).WithAtomicCommit(1 << 20).Import(context.Background())
+	if err != nil {
+		t.Fatalf("re-run failed: %v", err)
+	}
+	checkComplete(t, bs, result.RootCid)
+}
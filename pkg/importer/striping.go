@@ -0,0 +1,85 @@
+package importer
+
+import (
+	"context"
+	"io"
+
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// WithFileStriping imports every file larger than stripeBytes as
+// independent stripes: each stripeBytes of the file (the last one may be
+// shorter) becomes a UnixFS file DAG of its own, and a small stitch node, a
+// UnixFS file node linking the stripe roots in order, stands for the whole
+// file. The file reads back unchanged through the stitch node, while each
+// stripe can be transferred and validated on its own. The stripe roots are
+// listed in Content.Stripes. Zero or a negative value disables striping,
+// which is the default.
+// Returns the importer for method chaining.
+func (imp *Importer) WithFileStriping(stripeBytes int64) *Importer {
+	imp.stripeSize = max(stripeBytes, 0)
+	return imp
+}
+
+// stripes reports whether a file of size bytes is imported as stripes.
+func (imp *Importer) stripes(size int64) bool {
+	return imp.stripeSize > 0 && size > imp.stripeSize
+}
+
+// buildStripedDAG builds a DAG in dag for each stripe of reader with spec,
+// adds the stitch node linking them to ds and returns it with the stripe
+// roots. Stripes are read until the reader is exhausted, so a file that
+// grew since it was sized is imported whole.
+func (imp *Importer) buildStripedDAG(ctx context.Context, reader io.Reader, spec string, dag *ipld.BufferedDAG, ds ipld.DAGService) (ipld.Node, []string, error) {
+	stitch := unixfs.NewFSNode(unixfs.TFile)
+	var stripes []ipld.Node
+	for {
+		cr := &countingReader{r: io.LimitReader(reader, imp.stripeSize)}
+		nd, err := imp.buildDAGInto(ctx, cr, spec, dag)
+		if err != nil {
+			return nil, nil, err
+		}
+		if cr.n == 0 && len(stripes) > 0 {
+			break
+		}
+		stitch.AddBlockSize(uint64(cr.n))
+		stripes = append(stripes, nd)
+		if cr.n < imp.stripeSize {
+			break
+		}
+	}
+
+	data, err := stitch.GetBytes()
+	if err != nil {
+		return nil, nil, err
+	}
+	node := merkledag.NodeWithData(data)
+	if err := node.SetCidBuilder(imp.cidBuilder); err != nil {
+		return nil, nil, err
+	}
+	roots := make([]string, len(stripes))
+	for i, nd := range stripes {
+		if err := node.AddNodeLink("", nd); err != nil {
+			return nil, nil, err
+		}
+		roots[i] = nd.Cid().String()
+	}
+	if err := ds.Add(ctx, node); err != nil {
+		return nil, nil, err
+	}
+	return node, roots, nil
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
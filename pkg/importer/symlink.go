@@ -0,0 +1,133 @@
+package importer
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/ipfs/boxo/files"
+)
+
+// SymlinkPolicy chooses how Import adds the symbolic links it finds.
+type SymlinkPolicy int
+
+const (
+	// SymlinkPreserve stores each symlink as a UnixFS symlink node holding
+	// its raw target. This is the default.
+	SymlinkPreserve SymlinkPolicy = iota
+
+	// SymlinkFollow imports the file or directory a symlink points to, under
+	// the name of the link. A link that cannot be resolved, or that points
+	// to a directory being imported above it, is left out and reported in
+	// Result.Warnings as an *ImportError with Op "symlink".
+	SymlinkFollow
+
+	// SymlinkSkip leaves symlinks out of the DAG.
+	SymlinkSkip
+)
+
+// String returns the name of the policy.
+func (p SymlinkPolicy) String() string {
+	switch p {
+	case SymlinkPreserve:
+		return "preserve"
+	case SymlinkFollow:
+		return "follow"
+	case SymlinkSkip:
+		return "skip"
+	default:
+		return fmt.Sprintf("SymlinkPolicy(%d)", int(p))
+	}
+}
+
+// WithSymlinkPolicy sets how the symlinks below the imported directory are
+// added, SymlinkPreserve by default. With SymlinkFollow the progress total
+// and ScanReport include the content reached through links, and Scan lists
+// the links it cannot follow with SkipReasonBrokenLink; with SymlinkSkip,
+// Scan lists the links with SkipReasonSymlink.
+//
+// The policy applies to directories read from the filesystem. Listings
+// supplied with WithPrescannedEntries honor SymlinkSkip but never follow
+// links, and tar archives keep their links as they are.
+// Returns the importer for method chaining.
+func (imp *Importer) WithSymlinkPolicy(policy SymlinkPolicy) *Importer {
+	imp.symlinks = policy
+	return imp
+}
+
+// linkWalk is the position of a directory in a walk that follows symlinks:
+// its resolved absolute path and the directory it was reached from.
+type linkWalk struct {
+	real   string
+	parent *linkWalk
+}
+
+// newLinkWalk starts a walk that follows symlinks at the directory dir.
+func newLinkWalk(dir string) *linkWalk {
+	real, err := filepath.EvalSymlinks(dir)
+	if err == nil {
+		real, err = filepath.Abs(real)
+	}
+	if err != nil {
+		real = filepath.Clean(dir)
+	}
+	return &linkWalk{real: real}
+}
+
+// child returns the position of the directory name below w, or of the
+// directory real if it was reached through a symlink.
+func (w *linkWalk) child(name, real string) *linkWalk {
+	if w == nil {
+		return nil
+	}
+	if real == "" {
+		real = filepath.Join(w.real, name)
+	}
+	return &linkWalk{real: real, parent: w}
+}
+
+// contains reports whether real is w or one of the directories above it.
+func (w *linkWalk) contains(real string) bool {
+	for ; w != nil; w = w.parent {
+		if w.real == real {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the resolved absolute path of the symlink at full and
+// the entry it points to. It fails with ErrSymlinkCycle for a directory
+// that is already being walked.
+func (w *linkWalk) resolve(full string) (string, os.FileInfo, error) {
+	real, err := filepath.EvalSymlinks(full)
+	if err != nil {
+		return "", nil, err
+	}
+	if real, err = filepath.Abs(real); err != nil {
+		return "", nil, err
+	}
+	info, err := os.Stat(real)
+	if err != nil {
+		return "", nil, err
+	}
+	if info.IsDir() && w.contains(real) {
+		return "", nil, fmt.Errorf("%w: %s", ErrSymlinkCycle, real)
+	}
+	return real, info, nil
+}
+
+// followLink returns the node the symlink name in d points to, or nil if
+// it cannot be followed, which is reported as a warning.
+func (d *streamDir) followLink(name, full string) (files.Node, error) {
+	real, info, err := d.links.resolve(full)
+	if err != nil {
+		d.warn(&ImportError{Path: path.Join(d.rel, name), Op: "symlink", Err: err})
+		return nil, nil
+	}
+	if info.IsDir() {
+		return d.linkedChild(name, real, info), nil
+	}
+	return files.NewSerialFile(real, false, info)
+}
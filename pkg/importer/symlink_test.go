@@ -0,0 +1,158 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/tragoedia0722/repository/pkg/extractor"
+)
+
+// createSymlinkFixture creates a tree with a link to a file, a link to a
+// directory, a link to the root and a dangling link.
+func createSymlinkFixture(t *testing.T) string {
+	t.Helper()
+	dir := writeTree(t, map[string][]byte{
+		"a.txt":     []byte("alpha"),
+		"sub/b.txt": []byte("bravo"),
+	})
+	links := map[string]string{
+		"file-link": "a.txt",
+		"dir-link":  "sub",
+		"loop":      ".",
+		"dangling":  "missing.txt",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, name)); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+	return dir
+}
+
+// importWithPolicy imports dir with policy, extracts it and returns the
+// slash path of every extracted entry, symlinks suffixed by " -> target".
+func importWithPolicy(t *testing.T, dir string, policy SymlinkPolicy) (*Result, []string) {
+	t.Helper()
+	bs, cleanup := createTestBlockstore(t)
+	t.Cleanup(cleanup)
+
+	result, err := NewImporter(bs, dir).WithSymlinkPolicy(policy).Import(context.Background())
+	if err != nil {
+		t.Fatalf("%v: Import failed: %v", policy, err)
+	}
+
+	out := filepath.Join(t.TempDir(), "out")
+	if err := extractor.NewExtractor(bs, result.RootCid, out).Extract(context.Background(), false); err != nil {
+		t.Fatalf("%v: Extract failed: %v", policy, err)
+	}
+	var entries []string
+	err = filepath.Walk(out, func(path string, info os.FileInfo, err error) error {
+		if err != nil || path == out {
+			return err
+		}
+		rel, _ := filepath.Rel(out, path)
+		rel = filepath.ToSlash(rel)
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			rel += " -> " + target
+		}
+		entries = append(entries, rel)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(entries)
+	return result, entries
+}
+
+func TestImporter_WithSymlinkPolicy_Preserve(t *testing.T) {
+	dir := createSymlinkFixture(t)
+	result, entries := importWithPolicy(t, dir, SymlinkPreserve)
+
+	want := []string{"a.txt", "dangling -> missing.txt", "dir-link -> sub", "file-link -> a.txt", "loop -> .", "sub", "sub/b.txt"}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("extracted %v, want %v", entries, want)
+	}
+	if len(result.Warnings) != 0 {
+		t.Errorf("Warnings = %v, want none", result.Warnings)
+	}
+}
+
+func TestImporter_WithSymlinkPolicy_Follow(t *testing.T) {
+	dir := createSymlinkFixture(t)
+	result, entries := importWithPolicy(t, dir, SymlinkFollow)
+
+	want := []string{"a.txt", "dir-link", "dir-link/b.txt", "file-link", "sub", "sub/b.txt"}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("extracted %v, want %v", entries, want)
+	}
+	if result.Size != 20 {
+		t.Errorf("Size = %d, want the 20 bytes reached through links included", result.Size)
+	}
+
+	// The dangling link and the link to the root are reported, not fatal
+	warned := make(map[string]error)
+	for _, w := range result.Warnings {
+		var ie *ImportError
+		if !errors.As(w, &ie) || ie.Op != "symlink" {
+			t.Errorf("unexpected warning %v", w)
+			continue
+		}
+		warned[ie.Path] = ie.Err
+	}
+	if len(warned) != 2 || !errors.Is(warned["loop"], ErrSymlinkCycle) || !errors.Is(warned["dangling"], os.ErrNotExist) {
+		t.Errorf("warnings = %v, want loop (cycle) and dangling (not found)", warned)
+	}
+}
+
+func TestImporter_WithSymlinkPolicy_Skip(t *testing.T) {
+	dir := createSymlinkFixture(t)
+	result, entries := importWithPolicy(t, dir, SymlinkSkip)
+
+	want := []string{"a.txt", "sub", "sub/b.txt"}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("extracted %v, want %v", entries, want)
+	}
+	if result.Size != 10 || len(result.Contents) != 2 {
+		t.Errorf("Size = %d with %d files, want 10 bytes in 2 files", result.Size, len(result.Contents))
+	}
+}
+
+func TestImporter_Scan_SymlinkPolicy(t *testing.T) {
+	dir := createSymlinkFixture(t)
+
+	follow, err := NewImporter(nil, dir).WithSymlinkPolicy(SymlinkFollow).Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if follow.TotalBytes != 20 || follow.FileCount != 4 || follow.DirCount != 3 || follow.SymlinkCount != 0 {
+		t.Errorf("follow: %d bytes, %d files, %d dirs, %d links, want 20, 4, 3, 0",
+			follow.TotalBytes, follow.FileCount, follow.DirCount, follow.SymlinkCount)
+	}
+	if len(follow.Skipped) != 2 {
+		t.Errorf("follow: Skipped = %+v, want loop and dangling", follow.Skipped)
+	}
+	for _, s := range follow.Skipped {
+		if s.Reason != SkipReasonBrokenLink {
+			t.Errorf("follow: %s skipped for %q", s.Path, s.Reason)
+		}
+	}
+
+	skip, err := NewImporter(nil, dir).WithSymlinkPolicy(SymlinkSkip).Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if skip.TotalBytes != 10 || skip.SymlinkCount != 0 || len(skip.Skipped) != 4 {
+		t.Errorf("skip: %d bytes, %d links, Skipped %+v, want 10 bytes and 4 skipped links",
+			skip.TotalBytes, skip.SymlinkCount, skip.Skipped)
+	}
+}
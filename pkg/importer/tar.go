@@ -0,0 +1,199 @@
+package importer
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/files"
+)
+
+// tarStreamName is the Result.FileName of an import read from a tar stream.
+const tarStreamName = "stream"
+
+// StreamOption configures the importer used by ImportTarStream and
+// ImportReader.
+type StreamOption func(*Importer)
+
+// ImportTarStream imports the tar archive read from r into bs without writing
+// it to disk, for example an archive piped through standard input.
+//
+// The entries at the top level of the archive become the entries of the
+// root directory, so an archive made from the contents of a directory, such
+// as "tar -C dir -c .", imports to the same RootCid as importing the
+// directory itself. Entry names are cleaned like those of a filesystem
+// import, and hidden and ignored entries are left out as set with
+// WithSkipHidden and WithIgnore. Directories missing from the
+// archive are created for the entries below them, and symlinks are imported
+// as symlinks. Hard links, devices and paths leaving the archive root fail
+// the import with ErrInvalidTarEntry.
+//
+// The archive is read once, so the entries of each directory must follow
+// each other, as they do in archives made from a directory tree. The
+// progress total grows with the sizes in the file headers as they are read.
+//
+// The options configure the underlying importer, for example
+// func(imp *Importer) { imp.WithChecksums(true) }. Extended attributes, scan
+// reports and prescanned listings do not apply to a stream and are ignored.
+func ImportTarStream(ctx context.Context, bs blockstore.Blockstore, r io.Reader, opts ...StreamOption) (*Result, error) {
+	imp := NewImporter(bs, tarStreamName)
+	for _, opt := range opts {
+		opt(imp)
+	}
+	imp.tar = &tarStream{imp: imp, tr: tar.NewReader(r)}
+	return imp.Import(ctx)
+}
+
+// tarStream is a tar archive read once while it is imported.
+type tarStream struct {
+	imp     *Importer
+	tr      *tar.Reader
+	pending *tar.Header // Next header, read but not yet imported, with its name cleaned
+	err     error       // Error that ended the stream
+}
+
+// slice returns the stream as the single entry of a directory, as
+// sliceDirectory does for a directory on disk.
+func (s *tarStream) slice(name string) files.Directory {
+	return files.NewSliceDirectory([]files.DirEntry{
+		files.FileEntry(name, &tarDir{stream: s}),
+	})
+}
+
+// peek returns the next header to import without consuming it, or nil at
+// the end of the archive.
+func (s *tarStream) peek() (*tar.Header, error) {
+	for s.pending == nil {
+		if s.err != nil {
+			return nil, s.err
+		}
+
+		hdr, err := s.tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		if err != nil {
+			s.err = err
+			return nil, err
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		name, ok := tarEntryPath(hdr.Name)
+		if !ok {
+			s.err = &ImportError{Path: hdr.Name, Op: "tar", Err: ErrInvalidTarEntry}
+			return nil, s.err
+		}
+		if name == "" || s.imp.filter.skipPath(name, hdr.Typeflag == tar.TypeDir) {
+			continue
+		}
+		hdr.Name = name
+		s.pending = hdr
+	}
+	return s.pending, nil
+}
+
+// tarEntryPath cleans the name of an archive entry to a slash path relative
+// to the archive root, "" for the root itself. It reports false for paths
+// leaving the root.
+func tarEntryPath(name string) (string, bool) {
+	p := path.Clean(strings.TrimSuffix(name, "/"))
+	if path.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") {
+		return "", false
+	}
+	if p == "." {
+		return "", true
+	}
+	return p, true
+}
+
+// tarDir is a directory whose entries are read from a tar stream.
+type tarDir struct {
+	stream *tarStream
+	prefix string      // Slash path of this directory with a trailing slash, "" for the root
+	info   os.FileInfo // Header of the directory; nil if the archive has none
+}
+
+func (d *tarDir) Close() error         { return nil }
+func (d *tarDir) Size() (int64, error) { return 0, nil }
+func (d *tarDir) Entries() files.DirIterator {
+	return &tarIterator{dir: d}
+}
+
+func (d *tarDir) Mode() os.FileMode {
+	if d.info == nil {
+		return os.ModeDir | 0o755
+	}
+	return d.info.Mode()
+}
+
+func (d *tarDir) ModTime() time.Time {
+	if d.info == nil {
+		return time.Time{}
+	}
+	return d.info.ModTime()
+}
+
+// tarIterator iterates over the direct entries of a tarDir. It stops at the
+// first header outside the directory, which is left for the parent.
+type tarIterator struct {
+	dir  *tarDir
+	name string
+	node files.Node
+	err  error
+}
+
+func (it *tarIterator) Name() string     { return it.name }
+func (it *tarIterator) Node() files.Node { return it.node }
+func (it *tarIterator) Err() error       { return it.err }
+
+func (it *tarIterator) Next() bool {
+	s := it.dir.stream
+	hdr, err := s.peek()
+	if err != nil {
+		it.err = err
+		return false
+	}
+	if hdr == nil {
+		return false
+	}
+
+	rest, ok := strings.CutPrefix(hdr.Name, it.dir.prefix)
+	if !ok || rest == "" {
+		return false
+	}
+
+	// An entry below a directory the archive has no header for
+	name, _, nested := strings.Cut(rest, "/")
+	if nested {
+		it.name, it.node = name, &tarDir{stream: s, prefix: it.dir.prefix + name + "/"}
+		return true
+	}
+
+	s.pending = nil
+	info := hdr.FileInfo()
+	switch {
+	case hdr.Typeflag == tar.TypeDir:
+		it.node = &tarDir{stream: s, prefix: hdr.Name + "/", info: info}
+	case hdr.Typeflag == tar.TypeSymlink:
+		it.node = files.NewLinkFile(hdr.Linkname, info)
+	case hdr.Typeflag == tar.TypeReg || hdr.Typeflag == tar.TypeGNUSparse:
+		if s.imp.tracker != nil {
+			s.imp.tracker.grow(hdr.Size)
+		}
+		it.node = files.NewReaderStatFile(s.tr, info)
+	default:
+		s.err = &ImportError{Path: hdr.Name, Op: "tar", Err: ErrInvalidTarEntry}
+		it.err = s.err
+		return false
+	}
+	it.name = name
+	return true
+}
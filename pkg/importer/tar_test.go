@@ -0,0 +1,236 @@
+package importer
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tragoedia0722/repository/pkg/extractor"
+)
+
+// tarDirectory archives the contents of dir with the standard library, with
+// names relative to dir as "tar -C dir -c ." writes them.
+func tarDirectory(t *testing.T, dir string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var link string
+		if d.Type()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if d.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			_, err = tw.Write(data)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to archive %s: %v", dir, err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// untar reads the regular files and symlinks of an archive by name.
+func untar(t *testing.T, data []byte) (map[string][]byte, map[string]string) {
+	t.Helper()
+
+	regular := make(map[string][]byte)
+	links := make(map[string]string)
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return regular, links
+		}
+		if err != nil {
+			t.Fatalf("failed to read archive: %v", err)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			content, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			regular[hdr.Name] = content
+		case tar.TypeSymlink:
+			links[hdr.Name] = hdr.Linkname
+		}
+	}
+}
+
+func TestImportTarStream_RoundTrip(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	rng := rand.New(rand.NewSource(1))
+	big := make([]byte, 3*1024*1024+5)
+	rng.Read(big)
+	tree := map[string][]byte{
+		"readme.txt":           []byte("hello"),
+		"data/big.bin":         big,
+		"data/nested/deep.txt": []byte("deep"),
+		"data/empty.txt":       nil,
+		".hidden/ignored.txt":  []byte("hidden"),
+	}
+	dir := writeTree(t, tree)
+	if err := os.Mkdir(filepath.Join(dir, "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("readme.txt", filepath.Join(dir, "link")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	ctx := context.Background()
+	want, err := NewImporter(bs, dir).Import(ctx)
+	if err != nil {
+		t.Fatalf("filesystem import failed: %v", err)
+	}
+
+	var completed, total int64
+	got, err := ImportTarStream(ctx, bs, bytes.NewReader(tarDirectory(t, dir)), func(imp *Importer) {
+		imp.WithProgress(func(c, tot int64, _ string) { completed, total = c, tot })
+	})
+	if err != nil {
+		t.Fatalf("ImportTarStream failed: %v", err)
+	}
+
+	if got.RootCid != want.RootCid {
+		t.Errorf("RootCid = %s, want %s as for the filesystem import", got.RootCid, want.RootCid)
+	}
+	if got.Size != want.Size {
+		t.Errorf("Size = %d, want %d", got.Size, want.Size)
+	}
+	if len(got.Contents) != len(want.Contents) {
+		t.Errorf("got %d contents, want %d", len(got.Contents), len(want.Contents))
+	}
+	if completed != got.Size || total != got.Size {
+		t.Errorf("progress ended at %d of %d, want %d", completed, total, got.Size)
+	}
+
+	var out bytes.Buffer
+	if err := extractor.ExtractTarStream(ctx, bs, got.RootCid, &out); err != nil {
+		t.Fatalf("ExtractTarStream failed: %v", err)
+	}
+	regular, links := untar(t, out.Bytes())
+	for rel, data := range tree {
+		if rel == ".hidden/ignored.txt" {
+			if _, ok := regular[rel]; ok {
+				t.Errorf("hidden file %s was imported", rel)
+			}
+			continue
+		}
+		if !bytes.Equal(regular[rel], data) {
+			t.Errorf("%s: extracted content differs", rel)
+		}
+	}
+	if links["link"] != "readme.txt" {
+		t.Errorf("link target = %q, want readme.txt", links["link"])
+	}
+}
+
+func TestImportTarStream_ImplicitDirectories(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	tree := map[string][]byte{"a/b/c.txt": []byte("c"), "a/d.txt": []byte("d"), "e.txt": []byte("e")}
+	want, err := NewImporter(bs, writeTree(t, tree)).Import(context.Background())
+	if err != nil {
+		t.Fatalf("filesystem import failed: %v", err)
+	}
+
+	// Only file headers, with "./" prefixes as tar writes them for "."
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range []string{"a/b/c.txt", "a/d.txt", "e.txt"} {
+		if err := tw.WriteHeader(&tar.Header{Name: "./" + name, Mode: 0o644, Size: int64(len(tree[name]))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(tree[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ImportTarStream(context.Background(), bs, &buf)
+	if err != nil {
+		t.Fatalf("ImportTarStream failed: %v", err)
+	}
+	if got.RootCid != want.RootCid {
+		t.Errorf("RootCid = %s, want %s", got.RootCid, want.RootCid)
+	}
+}
+
+func TestImportTarStream_InvalidEntries(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	tests := []struct {
+		name string
+		hdr  tar.Header
+	}{
+		{"traversal", tar.Header{Name: "../escape.txt", Typeflag: tar.TypeReg, Mode: 0o644}},
+		{"absolute", tar.Header{Name: "/etc/passwd", Typeflag: tar.TypeReg, Mode: 0o644}},
+		{"hard link", tar.Header{Name: "copy.txt", Typeflag: tar.TypeLink, Linkname: "a.txt"}},
+		{"device", tar.Header{Name: "null", Typeflag: tar.TypeChar, Mode: 0o666}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			if err := tw.WriteHeader(&tt.hdr); err != nil {
+				t.Fatal(err)
+			}
+			if err := tw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := ImportTarStream(context.Background(), bs, &buf)
+			var importErr *ImportError
+			if !errors.Is(err, ErrInvalidTarEntry) || !errors.As(err, &importErr) {
+				t.Errorf("ImportTarStream error = %v, want *ImportError wrapping ErrInvalidTarEntry", err)
+			}
+		})
+	}
+}
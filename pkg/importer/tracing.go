@@ -0,0 +1,104 @@
+package importer
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/tragoedia0722/repository/pkg/tracing"
+)
+
+// WithTracer reports the import to t: one span for the import, a child span
+// for every file and, below the file, a span for every batch of blocks
+// written. The import span is a child of the span in the context passed to
+// Import. A nil tracer, the default, disables tracing.
+// Returns the importer for method chaining.
+func (imp *Importer) WithTracer(t tracing.Tracer) *Importer {
+	imp.tracer = t
+	return imp
+}
+
+// endImportSpan records the outcome of an import and ends its span.
+func endImportSpan(span tracing.Span, result *Result, err error) {
+	if result != nil {
+		blocks := 0
+		for _, pkg := range result.Packages {
+			blocks += len(pkg.Blocks)
+		}
+		span.SetAttributes(
+			tracing.String(tracing.AttrCID, result.RootCid),
+			tracing.Int64(tracing.AttrBytes, result.Size),
+			tracing.Int(tracing.AttrBlocks, blocks),
+		)
+	}
+	tracing.End(span, err)
+}
+
+// tracingDAG reports every batch the buffered DAG writes as a span. The
+// buffered DAG flushes with the context of the whole import, so batches
+// written while a file is imported are parented to the file's span instead.
+type tracingDAG struct {
+	ipld.DAGService
+	tracer tracing.Tracer
+	file   context.Context // Context of the file being imported; nil between files
+}
+
+func (t *tracingDAG) AddMany(ctx context.Context, nds []ipld.Node) error {
+	if t.file != nil {
+		ctx = t.file
+	}
+	ctx, span := t.tracer.StartSpan(ctx, tracing.SpanImportPutMany)
+
+	err := t.DAGService.AddMany(ctx, nds)
+
+	size := 0
+	for _, nd := range nds {
+		size += len(nd.RawData())
+	}
+	span.SetAttributes(tracing.Int(tracing.AttrBlocks, len(nds)), tracing.Int(tracing.AttrBytes, size))
+	tracing.End(span, err)
+	return err
+}
+
+// fileSpan is the span of the file being imported. All methods are no-ops
+// on a nil fileSpan, which addFile uses when tracing is disabled.
+type fileSpan struct {
+	span   tracing.Span
+	dag    *tracingDAG
+	cid    cid.Cid // Root of the file's DAG once known
+	linked bool    // The file was linked through the content index
+}
+
+// startFileSpan starts the span of the file at path, or returns ctx and nil
+// when tracing is disabled.
+func (imp *Importer) startFileSpan(ctx context.Context, path string, size int64) (context.Context, *fileSpan) {
+	if imp.tracer == nil {
+		return ctx, nil
+	}
+	ctx, span := imp.tracer.StartSpan(ctx, tracing.SpanImportFile)
+	span.SetAttributes(tracing.String(tracing.AttrPath, path), tracing.Int64(tracing.AttrBytes, size))
+	imp.traced.file = ctx
+	return ctx, &fileSpan{span: span, dag: imp.traced}
+}
+
+// done records the root of the file's DAG and whether it was linked from
+// the content index rather than read.
+func (s *fileSpan) done(node ipld.Node, linked bool) {
+	if s == nil {
+		return
+	}
+	s.cid = node.Cid()
+	s.linked = linked
+}
+
+// end ends the span with the error addFile returns.
+func (s *fileSpan) end(err *error) {
+	if s == nil {
+		return
+	}
+	s.dag.file = nil
+	if s.cid.Defined() {
+		s.span.SetAttributes(tracing.String(tracing.AttrCID, s.cid.String()), tracing.Bool(tracing.AttrCacheHit, s.linked))
+	}
+	tracing.End(s.span, *err)
+}
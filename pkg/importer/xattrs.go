@@ -0,0 +1,117 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/tragoedia0722/repository/pkg/xattr"
+)
+
+// xattrCollector records the extended attributes of the source entries
+// during the import walk. The source path of each entry is derived from the
+// directories being walked, since cleaned names may differ from the
+// original ones.
+type xattrCollector struct {
+	meta     xattr.Metadata
+	stack    []string // Source paths of the entries being walked
+	rootFile string   // Source of a single imported file, empty for directories
+}
+
+// newXattrs creates the collector for an import if WithXattrs is set and
+// records the attributes of the imported directory itself.
+func (imp *Importer) newXattrs() (*xattrCollector, error) {
+	if !imp.withXattr || imp.streamed() {
+		return nil, nil
+	}
+
+	src := sourcePath(imp.path)
+	info, err := os.Lstat(src)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &xattrCollector{meta: make(xattr.Metadata)}
+	if !info.IsDir() {
+		c.rootFile = src
+		return c, nil
+	}
+
+	c.stack = []string{src}
+	return c, c.record("", src)
+}
+
+// enter records the entry at path, named originalName in its source
+// directory, and makes it the current entry until leave is called.
+func (c *xattrCollector) enter(path, originalName string) error {
+	if c == nil {
+		return nil
+	}
+
+	var src string
+	if len(c.stack) == 0 {
+		// The only entry of a single-file import
+		src = c.rootFile
+	} else {
+		src = filepath.Join(c.stack[len(c.stack)-1], originalName)
+	}
+	c.stack = append(c.stack, src)
+	return c.record(path, src)
+}
+
+// leave ends the entry started by the last call to enter.
+func (c *xattrCollector) leave() {
+	if c == nil {
+		return
+	}
+	c.stack = c.stack[:len(c.stack)-1]
+}
+
+// record stores the attributes of src under path. Filesystems without
+// xattr support are skipped.
+func (c *xattrCollector) record(path, src string) error {
+	attrs, err := xattr.List(src)
+	if errors.Is(err, xattr.ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return &ImportError{Path: path, Op: "read xattrs", Err: err}
+	}
+	if len(attrs) > 0 {
+		c.meta[filepath.ToSlash(path)] = attrs
+	}
+	return nil
+}
+
+// addXattrMetadata links the recorded attributes from the root directory
+// under xattr.ReservedName. Nothing is added if no entry had attributes, so
+// the root CID then matches an import without WithXattrs.
+func (imp *Importer) addXattrMetadata(ctx context.Context) error {
+	if imp.xattrs == nil || len(imp.xattrs.meta) == 0 {
+		return nil
+	}
+
+	data, err := xattr.Encode(imp.xattrs.meta)
+	if err != nil {
+		return err
+	}
+
+	node, err := imp.buildDAGFromFile(ctx, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	return imp.putNodeToMFS(ctx, node, xattr.ReservedName)
+}
+
+// WithXattrs enables preserving extended attributes, including POSIX ACLs
+// where the filesystem exposes them. The attributes of every file and
+// directory are stored in a metadata file linked from the root directory,
+// which the extractor re-applies when its WithXattrs is set. Sources on
+// filesystems or platforms without xattr support are imported without
+// attributes. Returns the importer for method chaining.
+func (imp *Importer) WithXattrs(enabled bool) *Importer {
+	imp.withXattr = enabled
+	return imp
+}
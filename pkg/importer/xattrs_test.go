@@ -0,0 +1,53 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/tragoedia0722/repository/pkg/xattr"
+)
+
+func TestImporter_WithXattrs_NoAttributes(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	dir := writeTree(t, map[string][]byte{"a.txt": []byte("a"), "sub/b.txt": []byte("b")})
+	ctx := context.Background()
+
+	for _, rel := range []string{"", "a.txt", "sub", "sub/b.txt"} {
+		if attrs, _ := xattr.List(filepath.Join(dir, rel)); len(attrs) > 0 {
+			t.Skipf("%q has attributes set by the system: %v", rel, attrs)
+		}
+	}
+
+	plain, err := NewImporter(bs, dir).Import(ctx)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	// Without attributes no metadata is added
+	withXattrs, err := NewImporter(bs, dir).WithXattrs(true).Import(ctx)
+	if err != nil {
+		t.Fatalf("Import with xattrs failed: %v", err)
+	}
+	if withXattrs.RootCid != plain.RootCid {
+		t.Errorf("root CID changed without attributes: %s, want %s", withXattrs.RootCid, plain.RootCid)
+	}
+}
+
+func TestImporter_ReservedName(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	dir := writeTree(t, map[string][]byte{xattr.ReservedName: []byte("{}")})
+	if _, err := NewImporter(bs, dir).Import(context.Background()); !errors.Is(err, ErrReservedName) {
+		t.Errorf("Import error = %v, want ErrReservedName", err)
+	}
+
+	// The name is only reserved at the root
+	nested := writeTree(t, map[string][]byte{"sub/" + xattr.ReservedName: []byte("{}")})
+	if _, err := NewImporter(bs, nested).Import(context.Background()); err != nil {
+		t.Errorf("Import of a nested reserved name failed: %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/tragoedia0722/repository/pkg/clock"
+	"github.com/tragoedia0722/repository/pkg/clock/clocktest"
+)
+
+// countYields makes imp count its scheduling points into count.
+// If onYield is non-nil it is called at every scheduling point.
+func countYields(imp *Importer, count *int, onYield func()) *Importer {
+	imp.onYield = func(clk clock.Clock, sleep time.Duration) {
+		*count++
+		if onYield != nil {
+			onYield()
+		}
+		yieldNow(clk, sleep)
+	}
+	return imp
+}
+
+// createLargeFile writes a file of size bytes into a temporary directory.
+func createLargeFile(t *testing.T, size int) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "large.bin")
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 31)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	return path
+}
+
+func TestImporter_WithYield_SchedulingPoints(t *testing.T) {
+	path := createLargeFile(t, 16<<20)
+
+	t.Run("disabled by default", func(t *testing.T) {
+		bs, cleanup := createTestBlockstore(t)
+		defer cleanup()
+		var count int
+		if _, err := countYields(NewImporter(bs, path), &count, nil).Import(context.Background()); err != nil {
+			t.Fatalf("Import failed: %v", err)
+		}
+		if count != 0 {
+			t.Errorf("expected no scheduling points, got %d", count)
+		}
+	})
+
+	t.Run("bounded by clock time", func(t *testing.T) {
+		bs, cleanup := createTestBlockstore(t)
+		defer cleanup()
+		var count int
+
+		// Time only passes when data is read, so there is exactly one
+		// scheduling point before the read following each progress update.
+		every := time.Millisecond
+		clk := clocktest.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		updates := 0
+		imp := countYields(NewImporter(bs, path), &count, nil).WithClock(clk).WithYield(every).
+			WithProgress(func(_, _ int64, _ string) {
+				updates++
+				clk.Advance(every)
+			})
+		if _, err := imp.Import(context.Background()); err != nil {
+			t.Fatalf("Import failed: %v", err)
+		}
+
+		if updates == 0 || count != updates {
+			t.Errorf("got %d scheduling points for %d intervals, want one per interval", count, updates)
+		}
+	})
+
+	t.Run("not before the interval", func(t *testing.T) {
+		bs, cleanup := createTestBlockstore(t)
+		defer cleanup()
+		var count int
+		clk := clocktest.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		if _, err := countYields(NewImporter(bs, path), &count, nil).WithClock(clk).WithYield(time.Millisecond).Import(context.Background()); err != nil {
+			t.Fatalf("Import failed: %v", err)
+		}
+		if count != 0 {
+			t.Errorf("expected no scheduling points while the clock stands still, got %d", count)
+		}
+	})
+}
+
+func TestImporter_WithYield_Cancellation(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	path := createLargeFile(t, 16<<20)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var cancelled time.Time
+	var count int
+	imp := countYields(NewImporter(bs, path), &count, func() {
+		if cancelled.IsZero() {
+			cancelled = time.Now()
+			cancel()
+		}
+	})
+
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	imp = imp.WithClock(clk).WithYield(time.Millisecond).
+		WithProgress(func(_, _ int64, _ string) { clk.Advance(time.Millisecond) })
+	_, err := imp.Import(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if cancelled.IsZero() {
+		t.Fatal("expected a scheduling point before the import finished")
+	}
+	if latency := time.Since(cancelled); latency > 100*time.Millisecond {
+		t.Errorf("import returned %v after cancellation", latency)
+	}
+}
+
+func TestImporter_WithYieldSleep(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	path := createLargeFile(t, 4<<20)
+
+	var sleeps []time.Duration
+	imp := NewImporter(bs, path)
+	imp.onYield = func(_ clock.Clock, sleep time.Duration) {
+		sleeps = append(sleeps, sleep)
+	}
+	imp = imp.WithYield(time.Nanosecond).WithYieldSleep(2 * time.Millisecond)
+	if _, err := imp.Import(context.Background()); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	if len(sleeps) == 0 {
+		t.Fatal("expected scheduling points")
+	}
+	for _, d := range sleeps {
+		if d != 2*time.Millisecond {
+			t.Fatalf("expected sleep of 2ms, got %v", d)
+		}
+	}
+}
+
+func TestImporter_WithYieldSleep_Clock(t *testing.T) {
+	bs, cleanup := createTestBlockstore(t)
+	defer cleanup()
+
+	path := createLargeFile(t, 1<<20)
+
+	// Every scheduling point sleeps on the fake clock until the test
+	// advances it, so the import cannot finish without the clock.
+	clk := clocktest.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	done := make(chan error, 1)
+	go func() {
+		imp := NewImporter(bs, path).WithClock(clk).WithYield(time.Nanosecond).WithYieldSleep(time.Second)
+		_, err := imp.Import(context.Background())
+		done <- err
+	}()
+
+	clk.BlockUntil(1)
+	start := clk.Now()
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("Import failed: %v", err)
+			}
+			if clk.Since(start) == 0 {
+				t.Error("expected the import to sleep on the clock")
+			}
+			return
+		default:
+		}
+		if clk.Waiters() > 0 {
+			clk.Advance(time.Second)
+		}
+		runtime.Gosched()
+	}
+}
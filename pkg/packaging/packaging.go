@@ -0,0 +1,198 @@
+// Package packaging groups the blocks of a DAG into hashed packages.
+//
+// A package is a run of consecutive block CIDs (in sorted order) together with
+// the SHA-256 hash of their concatenated string forms. The importer produces
+// packages for every import, and the repository can rebuild them from a root
+// CID; both use this package so their output is byte-identical.
+package packaging
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/tragoedia0722/repository/internal/dagwalk"
+)
+
+const (
+	// DefaultBlocksPerPackage is the maximum number of blocks in a package.
+	DefaultBlocksPerPackage = 100
+
+	// cidStringBufferSize is the estimated CID string length for the hash builder.
+	cidStringBufferSize = 64
+)
+
+// Package represents a collection of blocks with their computed hash.
+type Package struct {
+	Hash   string   // SHA-256 hash of concatenated block CIDs
+	Blocks []string // List of block CIDs in this package
+	Bytes  int64    // Total size of the blocks; 0 for packages built by Calc or Split
+}
+
+// Limits bounds the packages built by SplitSized.
+type Limits struct {
+	MaxBlocks int   // Maximum number of blocks; non-positive uses DefaultBlocksPerPackage
+	MaxBytes  int64 // Maximum total size of the blocks; non-positive means no limit
+}
+
+// SizeFunc returns the size of the block c, such as the GetSize method of a
+// blockstore.
+type SizeFunc func(ctx context.Context, c cid.Cid) (int, error)
+
+// MissingBlocksError is returned when blocks reachable from a root are not
+// available, so packages for the root would be incomplete.
+type MissingBlocksError struct {
+	Root    string   // Root CID of the walk
+	Missing []string // Sorted CIDs of the missing blocks
+}
+
+func (e *MissingBlocksError) Error() string {
+	return fmt.Sprintf("root %s: %d missing blocks (first: %s)", e.Root, len(e.Missing), e.Missing[0])
+}
+
+// Calc creates a package from a list of block CIDs.
+func Calc(blocks []string) Package {
+	builder := strings.Builder{}
+	builder.Grow(len(blocks) * cidStringBufferSize)
+
+	for _, block := range blocks {
+		builder.WriteString(block)
+	}
+
+	hash := sha256.Sum256([]byte(builder.String()))
+
+	return Package{
+		Hash:   hex.EncodeToString(hash[:]),
+		Blocks: blocks,
+	}
+}
+
+// Split slices blocks into packages of at most blocksPerPackage blocks.
+// A non-positive blocksPerPackage uses DefaultBlocksPerPackage.
+func Split(blocks []string, blocksPerPackage int) []Package {
+	if blocksPerPackage <= 0 {
+		blocksPerPackage = DefaultBlocksPerPackage
+	}
+
+	packages := make([]Package, 0)
+	currentBlocks := make([]string, 0, blocksPerPackage)
+
+	for _, link := range blocks {
+		currentBlocks = append(currentBlocks, link)
+
+		if len(currentBlocks) >= blocksPerPackage {
+			packages = append(packages, Calc(currentBlocks))
+			currentBlocks = make([]string, 0, blocksPerPackage)
+		}
+	}
+
+	if len(currentBlocks) > 0 {
+		packages = append(packages, Calc(currentBlocks))
+	}
+
+	return packages
+}
+
+// SplitSized slices blocks into packages like Split, but closes a package
+// as soon as the next block would take it over either limit, and sets the
+// Bytes of every package from size. A block larger than MaxBytes forms a
+// package of its own. The hash of a package depends only on its blocks, as
+// with Calc.
+func SplitSized(ctx context.Context, blocks []string, size SizeFunc, limits Limits) ([]Package, error) {
+	if limits.MaxBlocks <= 0 {
+		limits.MaxBlocks = DefaultBlocksPerPackage
+	}
+
+	packages := make([]Package, 0)
+	var current []string
+	var bytes int64
+	closePackage := func() {
+		pkg := Calc(current)
+		pkg.Bytes = bytes
+		packages = append(packages, pkg)
+		current, bytes = nil, 0
+	}
+
+	for _, block := range blocks {
+		c, err := cid.Decode(block)
+		if err != nil {
+			return nil, fmt.Errorf("invalid block CID %q: %w", block, err)
+		}
+		n, err := size(ctx, c)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get size of block %s: %w", block, err)
+		}
+
+		if len(current) > 0 && limits.MaxBytes > 0 && bytes+int64(n) > limits.MaxBytes {
+			closePackage()
+		}
+		current = append(current, block)
+		bytes += int64(n)
+		if len(current) >= limits.MaxBlocks {
+			closePackage()
+		}
+	}
+
+	if len(current) > 0 {
+		closePackage()
+	}
+
+	return packages, nil
+}
+
+// CollectBlocks walks the DAG below root and returns every reachable block CID,
+// including the root, sorted as strings. Each block is listed once however
+// often it is linked, such as the shared block of many empty files. If any
+// block is missing the walk continues past it and a *MissingBlocksError
+// listing all missing blocks is returned.
+func CollectBlocks(ctx context.Context, dag ipld.NodeGetter, root cid.Cid) ([]string, error) {
+	walked, err := collect(ctx, dag, root, dagwalk.Options{})
+	if err != nil {
+		return nil, err
+	}
+	return walked.Visited.Strings(), nil
+}
+
+// CollectSizedBlocks is CollectBlocks that also returns the size of every
+// block, for SplitSized. Sizes of the nodes the walk decodes come from their
+// data; raw leaves are not read but looked up with stat, which also reports
+// missing leaves.
+func CollectSizedBlocks(ctx context.Context, dag ipld.NodeGetter, root cid.Cid, stat SizeFunc) ([]string, SizeFunc, error) {
+	walked, err := collect(ctx, dag, root, dagwalk.Options{Stat: stat, RecordSizes: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	size := func(ctx context.Context, c cid.Cid) (int, error) {
+		if n, ok := walked.Sizes[c]; ok {
+			return int(n), nil
+		}
+		return stat(ctx, c)
+	}
+	return walked.Visited.Strings(), size, nil
+}
+
+// collect walks the DAG below root and turns missing or undecodable blocks
+// into errors.
+func collect(ctx context.Context, dag ipld.NodeGetter, root cid.Cid, opts dagwalk.Options) (*dagwalk.Result, error) {
+	walked, err := dagwalk.Walk(ctx, dag, root, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(walked.Invalid) > 0 {
+		return nil, walked.Invalid[0].Err
+	}
+
+	if len(walked.Missing) > 0 {
+		missing := make([]string, len(walked.Missing))
+		for i, c := range walked.Missing {
+			missing[i] = c.String()
+		}
+		return nil, &MissingBlocksError{Root: root.String(), Missing: missing}
+	}
+
+	return walked, nil
+}
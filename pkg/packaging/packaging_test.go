@@ -0,0 +1,152 @@
+package packaging
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+func TestCalc(t *testing.T) {
+	blocks := []string{"bafyA", "bafyB"}
+	sum := sha256.Sum256([]byte("bafyAbafyB"))
+
+	pkg := Calc(blocks)
+	if pkg.Hash != hex.EncodeToString(sum[:]) {
+		t.Errorf("Hash = %s", pkg.Hash)
+	}
+	if len(pkg.Blocks) != 2 {
+		t.Errorf("Blocks = %v", pkg.Blocks)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	blocks := make([]string, 250)
+	for i := range blocks {
+		blocks[i] = fmt.Sprintf("block%03d", i)
+	}
+
+	tests := []struct {
+		name     string
+		perPkg   int
+		expected []int
+	}{
+		{name: "default", perPkg: 0, expected: []int{100, 100, 50}},
+		{name: "custom", perPkg: 120, expected: []int{120, 120, 10}},
+		{name: "exact", perPkg: 50, expected: []int{50, 50, 50, 50, 50}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packages := Split(blocks, tt.perPkg)
+			if len(packages) != len(tt.expected) {
+				t.Fatalf("got %d packages, want %d", len(packages), len(tt.expected))
+			}
+			for i, pkg := range packages {
+				if len(pkg.Blocks) != tt.expected[i] {
+					t.Errorf("package %d has %d blocks, want %d", i, len(pkg.Blocks), tt.expected[i])
+				}
+			}
+		})
+	}
+
+	if packages := Split(nil, 0); len(packages) != 0 {
+		t.Errorf("expected no packages for no blocks, got %d", len(packages))
+	}
+}
+
+func TestSplitSized(t *testing.T) {
+	// Every third block is 1MB, the others 1KB
+	sizes := make(map[cid.Cid]int)
+	var blocks []string
+	var total int64
+	for i := 0; i < 300; i++ {
+		hash, err := mh.Sum([]byte(fmt.Sprintf("block%03d", i)), mh.SHA2_256, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := cid.NewCidV1(cid.Raw, hash)
+		size := 1 << 10
+		if i%3 == 0 {
+			size = 1 << 20
+		}
+		sizes[c] = size
+		total += int64(size)
+		blocks = append(blocks, c.String())
+	}
+	size := func(ctx context.Context, c cid.Cid) (int, error) {
+		return sizes[c], nil
+	}
+
+	tests := []struct {
+		name   string
+		limits Limits
+	}{
+		{name: "bytes", limits: Limits{MaxBytes: 8 << 20}},
+		{name: "both", limits: Limits{MaxBlocks: 20, MaxBytes: 4 << 20}},
+		{name: "blocks", limits: Limits{MaxBlocks: 50}},
+		{name: "smaller than a block", limits: Limits{MaxBytes: 512 << 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packages, err := SplitSized(context.Background(), blocks, size, tt.limits)
+			if err != nil {
+				t.Fatalf("SplitSized failed: %v", err)
+			}
+
+			maxBlocks := tt.limits.MaxBlocks
+			if maxBlocks <= 0 {
+				maxBlocks = DefaultBlocksPerPackage
+			}
+			var n int
+			var sum int64
+			for i, pkg := range packages {
+				var bytes int64
+				for _, b := range pkg.Blocks {
+					c, _ := cid.Decode(b)
+					bytes += int64(sizes[c])
+				}
+				if pkg.Bytes != bytes {
+					t.Errorf("package %d: Bytes = %d, want %d", i, pkg.Bytes, bytes)
+				}
+				if len(pkg.Blocks) > maxBlocks {
+					t.Errorf("package %d has %d blocks, limit %d", i, len(pkg.Blocks), maxBlocks)
+				}
+				if tt.limits.MaxBytes > 0 && pkg.Bytes > tt.limits.MaxBytes && len(pkg.Blocks) > 1 {
+					t.Errorf("package %d has %d bytes in %d blocks, limit %d", i, pkg.Bytes, len(pkg.Blocks), tt.limits.MaxBytes)
+				}
+				if pkg.Hash != Calc(pkg.Blocks).Hash {
+					t.Errorf("package %d: hash differs from Calc", i)
+				}
+				n += len(pkg.Blocks)
+				sum += pkg.Bytes
+			}
+			if n != len(blocks) || sum != total {
+				t.Errorf("packages hold %d blocks of %d bytes, want %d of %d", n, sum, len(blocks), total)
+			}
+		})
+	}
+
+	// Without a byte limit the packages are those of Split
+	packages, err := SplitSized(context.Background(), blocks, size, Limits{})
+	if err != nil {
+		t.Fatalf("SplitSized failed: %v", err)
+	}
+	for i, pkg := range Split(blocks, 0) {
+		if packages[i].Hash != pkg.Hash {
+			t.Errorf("package %d differs from Split", i)
+		}
+	}
+
+	failing := func(ctx context.Context, c cid.Cid) (int, error) {
+		return 0, errors.New("not found")
+	}
+	if _, err := SplitSized(context.Background(), blocks, failing, Limits{}); err == nil {
+		t.Error("SplitSized succeeded although a size lookup failed")
+	}
+}
@@ -0,0 +1,297 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"hash/maphash"
+	"math"
+	"math/bits"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	cid2 "github.com/ipfs/go-cid"
+)
+
+const (
+	// DefaultAccessTopK 是 AccessStatsOptions.TopK 为 0 时保留的热点块个数。
+	DefaultAccessTopK = 32
+
+	// MaxAccessTopK 是 AccessStatsOptions.TopK 的上限。
+	MaxAccessTopK = 1024
+
+	sketchDepth   = 4    // count-min sketch 的行数
+	sketchWidth   = 2048 // count-min sketch 每行的计数器个数，必须是 2 的幂
+	hllPrecision  = 12   // HyperLogLog 用哈希的前 12 位选择寄存器
+	hllRegisters  = 1 << hllPrecision
+	hllRankOffset = 64 - hllPrecision
+)
+
+// AccessStatsOptions 配置块访问统计。
+type AccessStatsOptions struct {
+	// SampleRate 是被采样的读取比例（0-1），0 表示不启用统计。
+	// 只有命中的读取（GetRawData 等 Get、GetSize 以及返回 true 的 Has）参与采样。
+	SampleRate float64
+
+	// TopK 是保留的热点块个数，0 表示 DefaultAccessTopK，最大为 MaxAccessTopK。
+	TopK int
+}
+
+// validate 检查配置是否有效。
+func (o AccessStatsOptions) validate() error {
+	if o.SampleRate < 0 || o.SampleRate > 1 || math.IsNaN(o.SampleRate) {
+		return fmt.Errorf("access stats sample rate must be between 0 and 1: %v", o.SampleRate)
+	}
+	if o.TopK < 0 || o.TopK > MaxAccessTopK {
+		return fmt.Errorf("access stats top-k must be between 0 and %d: %d", MaxAccessTopK, o.TopK)
+	}
+	return nil
+}
+
+// BlockAccess 是一个热点块及其被采样读取次数的估计值。
+type BlockAccess struct {
+	Cid   cid2.Cid
+	Count uint64 // 估计的采样读取次数，不小于实际次数
+}
+
+// AccessStatsSnapshot 是块访问统计的快照。
+//
+// 计数只包含被采样的读取；SampleRate 小于 1 时，实际读取次数约为
+// SampledReads / SampleRate。DistinctBlocks 和 Top 的计数是估计值，
+// 误差与仓库大小无关，只取决于固定大小的统计结构。
+type AccessStatsSnapshot struct {
+	Since          time.Time     // 统计开始的时间：打开仓库或上次调用 ResetAccessStats
+	SampleRate     float64       // 采样比例，未启用统计时为 0
+	SampledReads   uint64        // 被采样的读取次数
+	DistinctBlocks uint64        // 被采样读取的不同块个数的估计值
+	Top            []BlockAccess // 采样读取次数最多的块，按次数降序
+	CacheHits      uint64        // 副本仓库从本地缓存满足的读取次数，不经过采样
+	CacheMisses    uint64        // 副本仓库从主仓库读取的次数，不经过采样
+}
+
+// HitRatio 返回缓存命中率。没有缓存层或还没有读取时返回 0。
+func (s AccessStatsSnapshot) HitRatio() float64 {
+	total := s.CacheHits + s.CacheMisses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.CacheHits) / float64(total)
+}
+
+// AccessStats 返回块访问统计的快照。未启用 AccessStats 时返回零值。
+func (r *Repository) AccessStats() AccessStatsSnapshot {
+	if r.access == nil {
+		return AccessStatsSnapshot{}
+	}
+	return r.access.snapshot()
+}
+
+// ResetAccessStats 清空块访问统计，重新开始计数。未启用 AccessStats 时不做任何操作。
+func (r *Repository) ResetAccessStats() {
+	if r.access != nil {
+		r.access.reset()
+	}
+}
+
+// accessStats 以固定大小的内存统计块的读取：count-min sketch 估计每个块的
+// 读取次数，HyperLogLog 估计不同块的个数，另外保留估计次数最多的 topK 个块。
+type accessStats struct {
+	rate float64
+	topK int
+	seed maphash.Seed
+
+	hits   atomic.Uint64 // 缓存命中次数
+	misses atomic.Uint64 // 缓存未命中次数
+
+	mu      sync.Mutex
+	since   time.Time
+	sampled uint64
+	sketch  [sketchDepth][sketchWidth]uint32
+	hll     [hllRegisters]uint8
+	top     map[string]*BlockAccess // 热点块，键为 CID 的 KeyString
+}
+
+// newAccessStats 按 opts 创建统计，未启用时返回 nil。
+func newAccessStats(opts AccessStatsOptions) *accessStats {
+	if opts.SampleRate == 0 {
+		return nil
+	}
+	topK := opts.TopK
+	if topK == 0 {
+		topK = DefaultAccessTopK
+	}
+	a := &accessStats{rate: opts.SampleRate, topK: topK, seed: maphash.MakeSeed()}
+	a.reset()
+	return a
+}
+
+// reset 清空统计。
+func (a *accessStats) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.since = time.Now()
+	a.sampled = 0
+	a.sketch = [sketchDepth][sketchWidth]uint32{}
+	a.hll = [hllRegisters]uint8{}
+	a.top = make(map[string]*BlockAccess, a.topK+1)
+	a.hits.Store(0)
+	a.misses.Store(0)
+}
+
+// cacheHit 记录一次缓存命中，a 为 nil 时不做任何操作。
+func (a *accessStats) cacheHit() {
+	if a != nil {
+		a.hits.Add(1)
+	}
+}
+
+// cacheMiss 记录一次缓存未命中，a 为 nil 时不做任何操作。
+func (a *accessStats) cacheMiss() {
+	if a != nil {
+		a.misses.Add(1)
+	}
+}
+
+// sample 按采样比例记录一次对 c 的读取。
+func (a *accessStats) sample(c cid2.Cid) {
+	if a.rate < 1 && rand.Float64() >= a.rate {
+		return
+	}
+
+	h := maphash.Bytes(a.seed, c.Hash())
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.sampled++
+
+	// 行 i 的位置为 h1 + i*h2，count-min 的估计值是各行计数的最小值
+	h1, h2 := uint32(h), uint32(h>>32)|1
+	count := uint32(math.MaxUint32)
+	for i := range a.sketch {
+		cell := &a.sketch[i][(h1+uint32(i)*h2)&(sketchWidth-1)]
+		if *cell < math.MaxUint32 {
+			*cell++
+		}
+		count = min(count, *cell)
+	}
+
+	reg := h >> hllRankOffset
+	rank := uint8(bits.LeadingZeros64(h<<hllPrecision|1<<(hllPrecision-1)) + 1)
+	a.hll[reg] = max(a.hll[reg], rank)
+
+	a.updateTop(c, uint64(count))
+}
+
+// updateTop 用 c 的新估计值更新热点块。调用者必须持有 a.mu。
+func (a *accessStats) updateTop(c cid2.Cid, count uint64) {
+	key := c.KeyString()
+	if e, ok := a.top[key]; ok {
+		e.Count = count
+		return
+	}
+	if len(a.top) < a.topK {
+		a.top[key] = &BlockAccess{Cid: c, Count: count}
+		return
+	}
+
+	var minKey string
+	var minCount uint64 = math.MaxUint64
+	for k, e := range a.top {
+		if e.Count < minCount {
+			minKey, minCount = k, e.Count
+		}
+	}
+	if count > minCount {
+		delete(a.top, minKey)
+		a.top[key] = &BlockAccess{Cid: c, Count: count}
+	}
+}
+
+// distinct 返回 HyperLogLog 估计的不同块个数。调用者必须持有 a.mu。
+func (a *accessStats) distinct() uint64 {
+	const m = float64(hllRegisters)
+	sum, zeros := 0.0, 0
+	for _, rank := range a.hll {
+		sum += math.Ldexp(1, -int(rank))
+		if rank == 0 {
+			zeros++
+		}
+	}
+
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// 基数较小时使用线性计数
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(math.Round(estimate))
+}
+
+// snapshot 返回当前统计的副本。
+func (a *accessStats) snapshot() AccessStatsSnapshot {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	top := make([]BlockAccess, 0, len(a.top))
+	for _, e := range a.top {
+		top = append(top, *e)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Cid.KeyString() < top[j].Cid.KeyString()
+	})
+
+	return AccessStatsSnapshot{
+		Since:          a.since,
+		SampleRate:     a.rate,
+		SampledReads:   a.sampled,
+		DistinctBlocks: a.distinct(),
+		Top:            top,
+		CacheHits:      a.hits.Load(),
+		CacheMisses:    a.misses.Load(),
+	}
+}
+
+// accessBlockstore 为 bs 加上 r 的块访问统计，未启用统计时原样返回 bs。
+func (r *Repository) accessBlockstore(bs blockstore.Blockstore) blockstore.Blockstore {
+	if r.access == nil {
+		return bs
+	}
+	return &samplingBlockstore{Blockstore: bs, stats: r.access}
+}
+
+// samplingBlockstore 对命中的读取采样。
+type samplingBlockstore struct {
+	blockstore.Blockstore
+	stats *accessStats
+}
+
+func (b *samplingBlockstore) Get(ctx context.Context, c cid2.Cid) (blocks.Block, error) {
+	blk, err := b.Blockstore.Get(ctx, c)
+	if err == nil {
+		b.stats.sample(c)
+	}
+	return blk, err
+}
+
+func (b *samplingBlockstore) GetSize(ctx context.Context, c cid2.Cid) (int, error) {
+	size, err := b.Blockstore.GetSize(ctx, c)
+	if err == nil {
+		b.stats.sample(c)
+	}
+	return size, err
+}
+
+func (b *samplingBlockstore) Has(ctx context.Context, c cid2.Cid) (bool, error) {
+	has, err := b.Blockstore.Has(ctx, c)
+	if err == nil && has {
+		b.stats.sample(c)
+	}
+	return has, err
+}
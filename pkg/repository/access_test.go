@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	cid2 "github.com/ipfs/go-cid"
+)
+
+// readSkewed stores 200 blocks and reads the first five 100 times each and
+// the others twice. It returns the CIDs of the hot blocks and the number of
+// reads.
+func readSkewed(t *testing.T, repo *Repository) ([]cid2.Cid, int) {
+	t.Helper()
+	ctx := context.Background()
+
+	var cids []cid2.Cid
+	for i := 0; i < 200; i++ {
+		c, err := repo.PutBlock(ctx, []byte(fmt.Sprintf("block %d", i)))
+		if err != nil {
+			t.Fatalf("PutBlock failed: %v", err)
+		}
+		cids = append(cids, *c)
+	}
+
+	reads := 0
+	for i, c := range cids {
+		n := 2
+		if i < 5 {
+			n = 100
+		}
+		for j := 0; j < n; j++ {
+			if _, err := repo.GetRawDataCid(ctx, c); err != nil {
+				t.Fatalf("GetRawDataCid failed: %v", err)
+			}
+			reads++
+		}
+	}
+	return cids[:5], reads
+}
+
+func TestRepository_AccessStats(t *testing.T) {
+	repo, err := NewRepositoryWithOptions(t.TempDir(), RepoOptions{
+		AccessStats: AccessStatsOptions{SampleRate: 1, TopK: 10},
+	})
+	if err != nil {
+		t.Fatalf("NewRepositoryWithOptions failed: %v", err)
+	}
+	defer repo.Close()
+
+	hot, reads := readSkewed(t, repo)
+	stats := repo.AccessStats()
+
+	if stats.SampledReads < uint64(reads) {
+		t.Errorf("sampled %d reads, want at least %d", stats.SampledReads, reads)
+	}
+	if stats.DistinctBlocks < 180 || stats.DistinctBlocks > 220 {
+		t.Errorf("estimated %d distinct blocks, want about 200", stats.DistinctBlocks)
+	}
+	if len(stats.Top) != 10 {
+		t.Fatalf("top-k has %d blocks, want 10", len(stats.Top))
+	}
+
+	top := make(map[cid2.Cid]uint64)
+	for _, a := range stats.Top {
+		top[a.Cid] = a.Count
+	}
+	for _, c := range hot {
+		count, ok := top[c]
+		if !ok {
+			t.Errorf("hot block %s is not in the top-k", c)
+			continue
+		}
+		if count < 100 || count > 200 {
+			t.Errorf("hot block %s counted %d times, want about 100", c, count)
+		}
+	}
+	for i := 1; i < len(stats.Top); i++ {
+		if stats.Top[i].Count > stats.Top[i-1].Count {
+			t.Errorf("top-k is not sorted: %+v", stats.Top)
+		}
+	}
+
+	repo.ResetAccessStats()
+	if stats := repo.AccessStats(); stats.SampledReads != 0 || stats.DistinctBlocks != 0 || len(stats.Top) != 0 {
+		t.Errorf("stats after reset: %+v", stats)
+	}
+}
+
+func TestRepository_AccessStats_Sampled(t *testing.T) {
+	repo, err := NewRepositoryWithOptions(t.TempDir(), RepoOptions{
+		AccessStats: AccessStatsOptions{SampleRate: 0.5},
+	})
+	if err != nil {
+		t.Fatalf("NewRepositoryWithOptions failed: %v", err)
+	}
+	defer repo.Close()
+
+	hot, reads := readSkewed(t, repo)
+	stats := repo.AccessStats()
+
+	sampled := float64(stats.SampledReads)
+	if sampled < 0.35*float64(reads) || sampled > 0.65*float64(reads) {
+		t.Errorf("sampled %d of %d reads at rate 0.5", stats.SampledReads, reads)
+	}
+	top := make(map[cid2.Cid]bool)
+	for _, a := range stats.Top {
+		top[a.Cid] = true
+	}
+	for _, c := range hot {
+		if !top[c] {
+			t.Errorf("hot block %s is not in the top-k", c)
+		}
+	}
+}
+
+func TestRepository_AccessStats_Disabled(t *testing.T) {
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	readSkewed(t, repo)
+	if stats := repo.AccessStats(); stats.SampledReads != 0 || len(stats.Top) != 0 {
+		t.Errorf("disabled stats recorded reads: %+v", stats)
+	}
+	repo.ResetAccessStats()
+
+	for _, opts := range []AccessStatsOptions{{SampleRate: -0.1}, {SampleRate: 1.5}, {SampleRate: 1, TopK: MaxAccessTopK + 1}} {
+		if _, err := NewRepositoryWithOptions(t.TempDir(), RepoOptions{AccessStats: opts}); err == nil {
+			t.Errorf("options %+v were accepted", opts)
+		}
+	}
+}
+
+func TestReplicaRepository_AccessStats(t *testing.T) {
+	ctx := context.Background()
+	primary, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer primary.Close()
+
+	c, err := primary.PutBlock(ctx, []byte("cached"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replica, err := NewReplicaRepository(t.TempDir(), primary, ReplicaOptions{
+		AccessStats: AccessStatsOptions{SampleRate: 1},
+	})
+	if err != nil {
+		t.Fatalf("NewReplicaRepository failed: %v", err)
+	}
+	defer replica.Close()
+
+	for i := 0; i < 4; i++ {
+		if _, err := replica.GetRawDataCid(ctx, *c); err != nil {
+			t.Fatalf("GetRawDataCid failed: %v", err)
+		}
+	}
+
+	stats := replica.AccessStats()
+	if stats.CacheMisses != 1 || stats.CacheHits != 3 {
+		t.Errorf("cache hits %d, misses %d; want 3, 1", stats.CacheHits, stats.CacheMisses)
+	}
+	if ratio := stats.HitRatio(); ratio != 0.75 {
+		t.Errorf("HitRatio = %v, want 0.75", ratio)
+	}
+}
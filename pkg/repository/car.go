@@ -0,0 +1,451 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	blocks "github.com/ipfs/go-block-format"
+	cid2 "github.com/ipfs/go-cid"
+	"github.com/tragoedia0722/repository/internal/dagwalk"
+	"github.com/tragoedia0722/repository/pkg/packaging"
+)
+
+const (
+	// carVersion 是读写的 CAR 格式版本
+	carVersion = 1
+
+	// maxCARHeaderSize 是 CAR 头的最大字节数
+	maxCARHeaderSize = 1 << 20
+
+	// maxCARCidSize 是 CAR 中单个 CID 的最大字节数
+	maxCARCidSize = 4096
+
+	// carImportBatch 是导入 CAR 时每批写入的块数
+	carImportBatch = 256
+
+	// cidTag 是 DAG-CBOR 中 CID 的标签
+	cidTag = 42
+)
+
+// ErrInvalidCAR 表示数据不是有效的 CARv1 文件。
+var ErrInvalidCAR = errors.New("invalid CAR data")
+
+// ExportCAR 把根 CID 可达的所有块写成 CARv1 格式，与 ipfs dag export 相同。
+//
+// CAR 头只包含 rootCid 一个根。块的顺序是确定的：根块在最前，其余块按
+// CID 字符串排序，每个块只写一次，因此同一个 DAG 总是得到相同的字节。
+// 写入之前先遍历整个 DAG，有块缺失时返回列出缺失块的
+// *packaging.MissingBlocksError，不写入任何数据。
+//
+// 参数：
+//
+//	ctx - 用于取消操作的上下文
+//	rootCid - 根 CID 字符串
+//	w - CAR 数据的写入目标
+//
+// 返回：
+//
+//	error - 如果 CID 无效、块缺失、读取或写入失败，返回错误
+func (r *Repository) ExportCAR(ctx context.Context, rootCid string, w io.Writer) error {
+	if err := r.life.enter(); err != nil {
+		return err
+	}
+	defer r.life.leave()
+
+	root, err := r.parseCID(rootCid)
+	if err != nil {
+		return err
+	}
+
+	dag := merkledag.NewDAGService(blockservice.New(r.blockStore, nil))
+	walked, err := dagwalk.Walk(ctx, dag, root, dagwalk.Options{Stat: r.blockStore.GetSize})
+	if err != nil {
+		return err
+	}
+	if len(walked.Missing) > 0 {
+		missing := make([]string, len(walked.Missing))
+		for i, c := range walked.Missing {
+			missing[i] = c.String()
+		}
+		return &packaging.MissingBlocksError{Root: root.String(), Missing: missing}
+	}
+	if len(walked.Invalid) > 0 {
+		f := walked.Invalid[0]
+		return fmt.Errorf("failed to read block %s: %w", f.Cid, f.Err)
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := writeCARSection(bw, encodeCARHeader([]cid2.Cid{root})); err != nil {
+		return fmt.Errorf("failed to write CAR header: %w", err)
+	}
+	if err := r.writeCARBlock(ctx, bw, root); err != nil {
+		return err
+	}
+	for _, s := range walked.Visited.Strings() {
+		c, err := cid2.Decode(s)
+		if err != nil {
+			return fmt.Errorf("invalid CID %q: %w", s, err)
+		}
+		if c.Equals(root) {
+			continue
+		}
+		if err := r.writeCARBlock(ctx, bw, c); err != nil {
+			return err
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write CAR data: %w", err)
+	}
+	return nil
+}
+
+// writeCARBlock 把块 c 作为一个 CAR 段写入 w。
+func (r *Repository) writeCARBlock(ctx context.Context, w io.Writer, c cid2.Cid) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	blk, err := r.getBlock(ctx, c)
+	if err != nil {
+		return fmt.Errorf("failed to read block %s: %w", c, err)
+	}
+
+	section := make([]byte, 0, c.ByteLen()+len(blk.RawData()))
+	section = append(section, c.Bytes()...)
+	section = append(section, blk.RawData()...)
+	if err := writeCARSection(w, section); err != nil {
+		return fmt.Errorf("failed to write block %s: %w", c, err)
+	}
+	return nil
+}
+
+// ImportCAR 读取 CARv1 数据并把其中的块写入仓库，与 ipfs dag import 相同。
+//
+// 每个块写入之前都会校验数据与 CID 是否相符，不符时返回包装
+// ErrCorruptBlock 的错误。不校验块是否构成完整的 DAG。
+// 出错时之前的批次已经写入，仓库中可能留下部分块。
+//
+// 参数：
+//
+//	ctx - 用于取消操作的上下文
+//	rd - CAR 数据
+//
+// 返回：
+//
+//	[]cid2.Cid - CAR 头中列出的根 CID
+//	error - 如果数据格式无效、块校验失败或写入失败，返回错误
+func (r *Repository) ImportCAR(ctx context.Context, rd io.Reader) ([]cid2.Cid, error) {
+	if err := r.life.enter(); err != nil {
+		return nil, err
+	}
+	defer r.life.leave()
+
+	br := bufio.NewReader(rd)
+	header, err := readCARSection(br, maxCARHeaderSize)
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidCAR)
+	}
+	if err != nil {
+		return nil, err
+	}
+	roots, err := decodeCARHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := make([]blocks.Block, 0, carImportBatch)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := r.putCARBatch(ctx, batch); err != nil {
+			return fmt.Errorf("failed to put blocks: %w", err)
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	maxSection := uint64(r.limits.MaxBlockSize) + maxCARCidSize
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		section, err := readCARSection(br, maxSection)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		blk, err := r.decodeCARBlock(section)
+		if err != nil {
+			return nil, err
+		}
+		batch = append(batch, blk)
+		if len(batch) == carImportBatch {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return roots, nil
+}
+
+// decodeCARBlock 解析一个 CAR 段并校验其数据与 CID 是否相符。
+func (r *Repository) decodeCARBlock(section []byte) (blocks.Block, error) {
+	n, c, err := cid2.CidFromBytes(section)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid block CID: %v", ErrInvalidCAR, err)
+	}
+	data := section[n:]
+	if err := r.checkBlockSize(len(data)); err != nil {
+		return nil, fmt.Errorf("block %s: %w", c, err)
+	}
+
+	sum, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash block %s: %w", c, err)
+	}
+	if !sum.Equals(c) {
+		return nil, fmt.Errorf("block %s: %w", c, ErrCorruptBlock)
+	}
+
+	blk, err := blocks.NewBlockWithCid(data, c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create block: %w", err)
+	}
+	return blk, nil
+}
+
+// writeCARSection 写入一个以 varint 长度为前缀的 CAR 段。
+func writeCARSection(w io.Writer, data []byte) error {
+	if _, err := w.Write(binary.AppendUvarint(nil, uint64(len(data)))); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// readCARSection 读取一个以 varint 长度为前缀的 CAR 段，长度不能超过 limit。
+// 数据在段之间结束时返回 io.EOF。
+func readCARSection(br *bufio.Reader, limit uint64) ([]byte, error) {
+	size, err := binary.ReadUvarint(br)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid section length: %v", ErrInvalidCAR, err)
+	}
+	if size == 0 || size > limit {
+		return nil, fmt.Errorf("%w: section length %d out of range", ErrInvalidCAR, size)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(br, data); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("%w: truncated section", ErrInvalidCAR)
+		}
+		return nil, fmt.Errorf("failed to read CAR data: %w", err)
+	}
+	return data, nil
+}
+
+// CBOR 主类型
+const (
+	cborUint  = 0
+	cborBytes = 2
+	cborText  = 3
+	cborArray = 4
+	cborMap   = 5
+	cborTag   = 6
+)
+
+// encodeCARHeader 把根列表编码为 DAG-CBOR 的 CAR 头 {roots, version}，
+// 键按 DAG-CBOR 规定的长度优先顺序排列。
+func encodeCARHeader(roots []cid2.Cid) []byte {
+	buf := appendCBORHead(nil, cborMap, 2)
+	buf = appendCBORText(buf, "roots")
+	buf = appendCBORHead(buf, cborArray, uint64(len(roots)))
+	for _, c := range roots {
+		b := c.Bytes()
+		buf = appendCBORHead(buf, cborTag, cidTag)
+		// DAG-CBOR 的 CID 以一个 0x00 字节（identity multibase）开头
+		buf = appendCBORHead(buf, cborBytes, uint64(len(b)+1))
+		buf = append(buf, 0)
+		buf = append(buf, b...)
+	}
+	buf = appendCBORText(buf, "version")
+	return appendCBORHead(buf, cborUint, carVersion)
+}
+
+// appendCBORHead 追加一个 CBOR 数据项的头部。
+func appendCBORHead(buf []byte, major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return append(buf, major|byte(n))
+	case n <= 0xff:
+		return append(buf, major|24, byte(n))
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(buf, major|25), uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(buf, major|26), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, major|27), n)
+	}
+}
+
+// appendCBORText 追加一个 CBOR 文本字符串。
+func appendCBORText(buf []byte, s string) []byte {
+	return append(appendCBORHead(buf, cborText, uint64(len(s))), s...)
+}
+
+// decodeCARHeader 解析 CAR 头并返回其中的根，只接受版本 1。
+func decodeCARHeader(data []byte) ([]cid2.Cid, error) {
+	d := &cborDecoder{buf: data}
+	entries, err := d.expect(cborMap)
+	if err != nil {
+		return nil, err
+	}
+
+	var roots []cid2.Cid
+	version := uint64(0)
+	for range entries {
+		key, err := d.text()
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "roots":
+			if roots, err = d.cids(); err != nil {
+				return nil, err
+			}
+		case "version":
+			if version, err = d.expect(cborUint); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("%w: unknown header field %q", ErrInvalidCAR, key)
+		}
+	}
+	if len(d.buf) > 0 {
+		return nil, fmt.Errorf("%w: trailing bytes in header", ErrInvalidCAR)
+	}
+
+	if version != carVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidCAR, version)
+	}
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("%w: no roots", ErrInvalidCAR)
+	}
+	return roots, nil
+}
+
+// cborDecoder 读取 CAR 头用到的 CBOR 子集。
+type cborDecoder struct {
+	buf []byte
+}
+
+// errCBOR 是头部格式错误时返回的错误。
+var errCBOR = fmt.Errorf("%w: malformed header", ErrInvalidCAR)
+
+// head 读取一个数据项的主类型和参数。
+func (d *cborDecoder) head() (byte, uint64, error) {
+	if len(d.buf) == 0 {
+		return 0, 0, errCBOR
+	}
+	major, info := d.buf[0]>>5, d.buf[0]&0x1f
+	d.buf = d.buf[1:]
+	if info < 24 {
+		return major, uint64(info), nil
+	}
+	if info > 27 {
+		return 0, 0, errCBOR
+	}
+
+	size := 1 << (info - 24)
+	if len(d.buf) < size {
+		return 0, 0, errCBOR
+	}
+	var n uint64
+	for _, b := range d.buf[:size] {
+		n = n<<8 | uint64(b)
+	}
+	d.buf = d.buf[size:]
+	return major, n, nil
+}
+
+// expect 读取一个主类型为 major 的数据项头部并返回其参数。
+func (d *cborDecoder) expect(major byte) (uint64, error) {
+	m, n, err := d.head()
+	if err != nil {
+		return 0, err
+	}
+	if m != major {
+		return 0, errCBOR
+	}
+	return n, nil
+}
+
+// bytes 读取主类型为 major 的字节串或文本字符串的内容。
+func (d *cborDecoder) bytes(major byte) ([]byte, error) {
+	n, err := d.expect(major)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.buf)) {
+		return nil, errCBOR
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b, nil
+}
+
+// text 读取一个文本字符串。
+func (d *cborDecoder) text() (string, error) {
+	b, err := d.bytes(cborText)
+	return string(b), err
+}
+
+// cids 读取一个 CID 数组。
+func (d *cborDecoder) cids() ([]cid2.Cid, error) {
+	n, err := d.expect(cborArray)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.buf)) {
+		return nil, errCBOR
+	}
+
+	cids := make([]cid2.Cid, 0, n)
+	for range n {
+		tag, err := d.expect(cborTag)
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.bytes(cborBytes)
+		if err != nil {
+			return nil, err
+		}
+		if tag != cidTag || len(b) == 0 || b[0] != 0 {
+			return nil, errCBOR
+		}
+		c, err := cid2.Cast(b[1:])
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid root CID: %v", ErrInvalidCAR, err)
+		}
+		cids = append(cids, c)
+	}
+	return cids, nil
+}
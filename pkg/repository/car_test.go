@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	cid2 "github.com/ipfs/go-cid"
+	"github.com/tragoedia0722/repository/pkg/packaging"
+)
+
+func TestRepository_ExportImportCAR(t *testing.T) {
+	ctx := context.Background()
+	src, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer src.Close()
+	result := importPackagesFixture(t, src)
+
+	var car bytes.Buffer
+	if err := src.ExportCAR(ctx, result.RootCid, &car); err != nil {
+		t.Fatalf("ExportCAR failed: %v", err)
+	}
+
+	// The same DAG always exports to the same bytes
+	var again bytes.Buffer
+	if err := src.ExportCAR(ctx, result.RootCid, &again); err != nil {
+		t.Fatalf("ExportCAR failed: %v", err)
+	}
+	if !bytes.Equal(car.Bytes(), again.Bytes()) {
+		t.Error("two exports of the same root differ")
+	}
+
+	dst, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer dst.Close()
+
+	roots, err := dst.ImportCAR(ctx, &car)
+	if err != nil {
+		t.Fatalf("ImportCAR failed: %v", err)
+	}
+	if len(roots) != 1 || roots[0].String() != result.RootCid {
+		t.Errorf("roots = %v, want [%s]", roots, result.RootCid)
+	}
+
+	rebuilt, err := BuildPackages(ctx, dst.BlockStore(), result.RootCid, PackagingOptions{})
+	if err != nil {
+		t.Fatalf("BuildPackages on the imported copy failed: %v", err)
+	}
+	if !reflect.DeepEqual(rebuilt, result.Packages) {
+		t.Error("imported copy does not hold the exported DAG")
+	}
+}
+
+func TestRepository_ExportCAR_MissingBlock(t *testing.T) {
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+	result := importPackagesFixture(t, repo)
+
+	var victim string
+	for _, blk := range result.Packages[0].Blocks {
+		if strings.HasPrefix(blk, "bafk") {
+			victim = blk
+			break
+		}
+	}
+	if err := repo.DelBlock(context.Background(), victim); err != nil {
+		t.Fatalf("DelBlock failed: %v", err)
+	}
+
+	var car bytes.Buffer
+	err = repo.ExportCAR(context.Background(), result.RootCid, &car)
+	var missingErr *packaging.MissingBlocksError
+	if !errors.As(err, &missingErr) || !reflect.DeepEqual(missingErr.Missing, []string{victim}) {
+		t.Fatalf("ExportCAR() = %v, want the missing block %s", err, victim)
+	}
+	if !strings.Contains(err.Error(), victim) {
+		t.Errorf("error %q does not name %s", err, victim)
+	}
+	if car.Len() != 0 {
+		t.Errorf("ExportCAR wrote %d bytes before failing", car.Len())
+	}
+}
+
+func TestRepository_ImportCAR_Invalid(t *testing.T) {
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	good := []byte("good block")
+	goodCid, err := defaultBuilder.Sum(good)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// carWith returns a CAR rooted at goodCid holding the block data under c
+	carWith := func(c cid2.Cid, data []byte) []byte {
+		var buf bytes.Buffer
+		if err := writeCARSection(&buf, encodeCARHeader([]cid2.Cid{goodCid})); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeCARSection(&buf, append(c.Bytes(), data...)); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	if _, err := repo.ImportCAR(context.Background(), bytes.NewReader(carWith(goodCid, good))); err != nil {
+		t.Fatalf("ImportCAR of a valid CAR failed: %v", err)
+	}
+
+	// A block whose data does not match its CID is rejected, not stored
+	other, err := defaultBuilder.Sum([]byte("other block"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.ImportCAR(context.Background(), bytes.NewReader(carWith(other, good)))
+	if !errors.Is(err, ErrCorruptBlock) {
+		t.Errorf("ImportCAR() = %v, want ErrCorruptBlock", err)
+	}
+	if has, _ := repo.HasBlockCid(context.Background(), other); has {
+		t.Error("mismatched block was stored")
+	}
+
+	tests := map[string][]byte{
+		"empty":     nil,
+		"garbage":   []byte("not a car file"),
+		"carv2":     {0x0a, 0xa1, 0x67, 'v', 'e', 'r', 's', 'i', 'o', 'n', 0x02},
+		"truncated": carWith(goodCid, good)[:40],
+	}
+	for name, data := range tests {
+		if _, err := repo.ImportCAR(context.Background(), bytes.NewReader(data)); !errors.Is(err, ErrInvalidCAR) {
+			t.Errorf("%s: ImportCAR() = %v, want ErrInvalidCAR", name, err)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/tragoedia0722/repository/internal/artifact"
+	"github.com/tragoedia0722/repository/internal/storage"
+)
+
+// DefaultCleanupAge 是 CleanupOptions.MinAge 为 0 时使用的最小年龄。
+// 足够长，使正在进行的导入或导出的临时文件不会被当作残留。
+const DefaultCleanupAge = 24 * time.Hour
+
+// CleanupOptions 配置 CleanupArtifacts。
+type CleanupOptions struct {
+	// MinAge 是临时条目被视为残留的最小年龄，0 表示 DefaultCleanupAge。
+	// 目录的年龄按其中最新的修改时间计算。
+	MinAge time.Duration
+
+	// Remove 为 true 时删除找到的残留，否则只报告。
+	Remove bool
+
+	// Dirs 是仓库目录之外需要一并扫描的目录，例如导入器 WithStagingDir
+	// 或导出器的目标目录。
+	Dirs []string
+}
+
+// Artifact 描述一个残留的临时文件或目录。
+type Artifact struct {
+	Path    string    // 完整路径
+	Kind    string    // 匹配的规则类别，如 "part" 或 "staging"
+	Size    int64     // 字节数，目录为其中所有文件的总和
+	ModTime time.Time // 最新的修改时间
+	Removed bool      // 是否已被删除
+}
+
+// CleanupReport 是 CleanupArtifacts 的结果。
+type CleanupReport struct {
+	Artifacts    []Artifact // 找到的残留，按路径排序
+	Removed      int        // 删除的条目数
+	RemovedBytes int64      // 删除的字节数
+}
+
+// CleanupArtifacts 扫描仓库目录中本库组件在崩溃后留下的临时文件和目录。
+//
+// 只有与 internal/artifact 中登记的命名规则匹配、且年龄不小于 MinAge 的条目
+// 被报告，Remove 为 true 时被删除；其他文件不会被触及。扫描范围是仓库
+// （或每个分片）占用的所有目录以及 opts.Dirs。匹配的目录作为整体报告和删除，
+// 不再进入其中扫描。
+//
+// 单个条目删除失败不会中止扫描，所有错误合并后返回，报告仍包含已完成的部分。
+//
+// 参数：
+//
+//	ctx - 用于取消操作的上下文
+//	opts - 清理配置
+//
+// 返回：
+//
+//	CleanupReport - 找到和删除的残留
+//	error - 如果扫描或删除失败，返回错误
+func (r *Repository) CleanupArtifacts(ctx context.Context, opts CleanupOptions) (CleanupReport, error) {
+	if err := r.life.enter(); err != nil {
+		return CleanupReport{}, err
+	}
+	defer r.life.leave()
+
+	minAge := opts.MinAge
+	if minAge == 0 {
+		minAge = DefaultCleanupAge
+	}
+	cutoff := time.Now().Add(-minAge)
+
+	stores := r.shards
+	if stores == nil {
+		stores = []*storage.Storage{r.storage}
+	}
+	dirs := append([]string(nil), opts.Dirs...)
+	for _, s := range stores {
+		dirs = append(dirs, s.Paths()...)
+	}
+
+	var report CleanupReport
+	var errs []error
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		dir = filepath.Clean(dir)
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+
+		found, err := findArtifacts(ctx, dir, cutoff)
+		if err != nil {
+			if ctx.Err() != nil {
+				return report, ctx.Err()
+			}
+			errs = append(errs, r.storage.RedactError(err))
+		}
+		for _, a := range found {
+			if opts.Remove {
+				if err := os.RemoveAll(a.Path); err != nil {
+					errs = append(errs, r.storage.RedactError(err))
+				} else {
+					a.Removed = true
+					report.Removed++
+					report.RemovedBytes += a.Size
+				}
+			}
+			report.Artifacts = append(report.Artifacts, a)
+		}
+	}
+
+	sort.Slice(report.Artifacts, func(i, j int) bool {
+		return report.Artifacts[i].Path < report.Artifacts[j].Path
+	})
+	return report, errors.Join(errs...)
+}
+
+// findArtifacts 返回 root 下修改时间早于 cutoff 的残留。root 不存在时返回空。
+func findArtifacts(ctx context.Context, root string, cutoff time.Time) ([]Artifact, error) {
+	var found []Artifact
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if path == root || (!d.IsDir() && !d.Type().IsRegular()) {
+			return nil
+		}
+
+		pattern, ok := artifact.Match(d.Name(), d.IsDir())
+		if !ok {
+			return nil
+		}
+		a, err := statArtifact(path, pattern.Kind)
+		if err != nil {
+			return err
+		}
+		if a.ModTime.Before(cutoff) {
+			found = append(found, a)
+		}
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return found, err
+}
+
+// statArtifact 统计 path 的大小和最新修改时间，目录统计其中的所有条目。
+func statArtifact(path, kind string) (Artifact, error) {
+	a := Artifact{Path: path, Kind: kind}
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(a.ModTime) {
+			a.ModTime = info.ModTime()
+		}
+		if info.Mode().IsRegular() {
+			a.Size += info.Size()
+		}
+		return nil
+	})
+	return a, err
+}
+
+// cleanupOnOpen 在启用 CleanupOnOpen 时清理刚打开的仓库。只有 ctx 结束才使打开失败。
+func (r *Repository) cleanupOnOpen(ctx context.Context, opts RepoOptions) (*Repository, error) {
+	if !opts.CleanupOnOpen {
+		return r, nil
+	}
+	if _, err := r.CleanupArtifacts(ctx, CleanupOptions{Remove: true}); err != nil && ctx.Err() != nil {
+		_ = r.Close()
+		return nil, ctx.Err()
+	}
+	return r, nil
+}
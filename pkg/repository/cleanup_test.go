@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// plantArtifacts creates one stale artifact of each registered kind below
+// dir, plus a bystander file and a fresh .part file that must be kept. It
+// returns the paths of the stale artifacts and of the files to keep.
+func plantArtifacts(t *testing.T, dir string) (stale, keep []string) {
+	t.Helper()
+
+	old := time.Now().Add(-2 * DefaultCleanupAge)
+	write := func(path string, mtime time.Time) {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("residue"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	part := filepath.Join(dir, "out", "file.bin.part")
+	spec := filepath.Join(dir, "datastore_spec.tmp")
+	staging := filepath.Join(dir, "import-staging-123")
+	write(part, old)
+	write(spec, old)
+	write(filepath.Join(staging, "000001.log"), old)
+	if err := os.Chtimes(staging, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	bystander := filepath.Join(dir, "out", "notes.txt")
+	fresh := filepath.Join(dir, "fresh.part")
+	write(bystander, old)
+	write(fresh, time.Now())
+
+	return []string{part, spec, staging}, []string{bystander, fresh}
+}
+
+func reportedPaths(report CleanupReport) []string {
+	var paths []string
+	for _, a := range report.Artifacts {
+		paths = append(paths, a.Path)
+	}
+	return paths
+}
+
+func assertPaths(t *testing.T, got, want []string) {
+	t.Helper()
+
+	want = append([]string(nil), want...)
+	sort.Strings(want)
+	if len(got) != len(want) {
+		t.Fatalf("reported %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("reported %v, want %v", got, want)
+		}
+	}
+}
+
+func TestRepository_CleanupArtifacts(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	repo, err := NewRepository(dir)
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	stale, keep := plantArtifacts(t, dir)
+
+	report, err := repo.CleanupArtifacts(ctx, CleanupOptions{})
+	if err != nil {
+		t.Fatalf("CleanupArtifacts failed: %v", err)
+	}
+	assertPaths(t, reportedPaths(report), stale)
+	if report.Removed != 0 {
+		t.Errorf("report-only run removed %d artifacts", report.Removed)
+	}
+	for _, path := range stale {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("report-only run touched %s: %v", path, err)
+		}
+	}
+
+	report, err = repo.CleanupArtifacts(ctx, CleanupOptions{Remove: true})
+	if err != nil {
+		t.Fatalf("CleanupArtifacts failed: %v", err)
+	}
+	if report.Removed != len(stale) || report.RemovedBytes != int64(len(stale)*len("residue")) {
+		t.Errorf("removed %d artifacts of %d bytes, want %d of %d", report.Removed, report.RemovedBytes, len(stale), len(stale)*len("residue"))
+	}
+	for _, path := range stale {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s still exists", path)
+		}
+	}
+	for _, path := range keep {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s was removed: %v", path, err)
+		}
+	}
+
+	// The repository itself is left intact
+	if _, err := repo.PutBlock(ctx, []byte("after cleanup")); err != nil {
+		t.Errorf("PutBlock after cleanup failed: %v", err)
+	}
+}
+
+func TestRepository_CleanupArtifacts_Dirs(t *testing.T) {
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	extra := t.TempDir()
+	stale, _ := plantArtifacts(t, extra)
+
+	report, err := repo.CleanupArtifacts(context.Background(), CleanupOptions{
+		MinAge: time.Hour,
+		Dirs:   []string{extra, filepath.Join(extra, "missing")},
+	})
+	if err != nil {
+		t.Fatalf("CleanupArtifacts failed: %v", err)
+	}
+	assertPaths(t, reportedPaths(report), stale)
+}
+
+func TestRepository_CleanupOnOpen(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := NewRepository(dir)
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	if err := repo.Close(); err != nil {
+		t.Fatal(err)
+	}
+	stale, keep := plantArtifacts(t, dir)
+
+	repo, err = NewRepositoryWithOptions(dir, RepoOptions{CleanupOnOpen: true})
+	if err != nil {
+		t.Fatalf("NewRepositoryWithOptions failed: %v", err)
+	}
+	defer repo.Close()
+
+	for _, path := range stale {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s still exists", path)
+		}
+	}
+	for _, path := range keep {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s was removed: %v", path, err)
+		}
+	}
+}
@@ -82,9 +82,9 @@ func TestEdgeCases_PutBlock(t *testing.T) {
 
 	t.Run("maximum_size_block", func(t *testing.T) {
 		// Create a block exactly at the limit (128MB)
-		data := make([]byte, maxBlockSize)
+		data := make([]byte, DefaultMaxBlockSize)
 		data[0] = 0x42
-		data[maxBlockSize-1] = 0x24
+		data[DefaultMaxBlockSize-1] = 0x24
 
 		cid, err := repo.PutBlock(ctx, data)
 		if err != nil {
@@ -96,16 +96,16 @@ func TestEdgeCases_PutBlock(t *testing.T) {
 			t.Fatalf("GetRawData failed: %v", err)
 		}
 
-		if len(retrieved) != maxBlockSize {
-			t.Errorf("size mismatch: got %d, want %d", len(retrieved), maxBlockSize)
+		if len(retrieved) != DefaultMaxBlockSize {
+			t.Errorf("size mismatch: got %d, want %d", len(retrieved), DefaultMaxBlockSize)
 		}
-		if retrieved[0] != 0x42 || retrieved[maxBlockSize-1] != 0x24 {
+		if retrieved[0] != 0x42 || retrieved[DefaultMaxBlockSize-1] != 0x24 {
 			t.Error("first/last byte mismatch")
 		}
 	})
 
 	t.Run("exceeds_maximum_size", func(t *testing.T) {
-		data := make([]byte, maxBlockSize+1)
+		data := make([]byte, DefaultMaxBlockSize+1)
 
 		_, err := repo.PutBlock(ctx, data)
 		if err == nil {
@@ -233,7 +233,7 @@ func TestEdgeCases_PutManyBlocks(t *testing.T) {
 	t.Run("one_block_exceeds_limit", func(t *testing.T) {
 		data := [][]byte{
 			[]byte("valid block"),
-			make([]byte, maxBlockSize+1), // exceeds limit
+			make([]byte, DefaultMaxBlockSize+1), // exceeds limit
 			[]byte("another valid block"),
 		}
 
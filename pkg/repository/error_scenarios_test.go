@@ -9,6 +9,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	ipld "github.com/ipfs/go-ipld-format"
 )
 
 // TestErrorScenarios_NewRepository 测试错误情况
@@ -57,21 +59,22 @@ func TestErrorScenarios_PutBlock(t *testing.T) {
 	ctx := context.Background()
 
 	t.Run("block_too_large", func(t *testing.T) {
-		largeData := make([]byte, maxBlockSize+1)
+		largeData := make([]byte, DefaultMaxBlockSize+1)
 
 		_, err := repo.PutBlock(ctx, largeData)
 		if err == nil {
 			t.Fatal("expected error for block exceeding maximum size")
 		}
 
-		if !strings.Contains(err.Error(), "exceeds maximum") {
-			t.Errorf("expected 'exceeds maximum' error, got: %v", err)
+		var tooLarge *BlockTooLargeError
+		if !errors.As(err, &tooLarge) || tooLarge.Size != len(largeData) || tooLarge.Max != DefaultMaxBlockSize {
+			t.Errorf("expected *BlockTooLargeError, got: %v", err)
 		}
 	})
 
 	t.Run("exactly_at_limit", func(t *testing.T) {
 		// This should succeed
-		data := make([]byte, maxBlockSize)
+		data := make([]byte, DefaultMaxBlockSize)
 		_, err := repo.PutBlock(ctx, data)
 		if err != nil {
 			t.Errorf("PutBlock failed for block at size limit: %v", err)
@@ -130,8 +133,8 @@ func TestErrorScenarios_PutBlockWithCid(t *testing.T) {
 				if err == nil {
 					t.Errorf("expected error for CID %q", invalidCID)
 				}
-				if !strings.Contains(err.Error(), "invalid CID") {
-					t.Logf("Error for %q: %v", invalidCID, err)
+				if !errors.Is(err, ErrInvalidCID) {
+					t.Errorf("expected ErrInvalidCID for %q, got: %v", invalidCID, err)
 				}
 			})
 		}
@@ -144,7 +147,7 @@ func TestErrorScenarios_PutBlockWithCid(t *testing.T) {
 			t.Fatalf("PutBlock failed: %v", err)
 		}
 
-		largeData := make([]byte, maxBlockSize+1)
+		largeData := make([]byte, DefaultMaxBlockSize+1)
 		err = repo.PutBlockWithCid(ctx, cid.String(), largeData)
 		if err == nil {
 			t.Fatal("expected error for block exceeding maximum size")
@@ -168,7 +171,7 @@ func TestErrorScenarios_PutManyBlocks(t *testing.T) {
 	t.Run("one_invalid_block_size", func(t *testing.T) {
 		data := [][]byte{
 			[]byte("valid 1"),
-			make([]byte, maxBlockSize+1), // invalid
+			make([]byte, DefaultMaxBlockSize+1), // invalid
 			[]byte("valid 2"),
 		}
 
@@ -180,8 +183,8 @@ func TestErrorScenarios_PutManyBlocks(t *testing.T) {
 		if !strings.Contains(err.Error(), "index 1") {
 			t.Errorf("expected error for index 1, got: %v", err)
 		}
-		if !strings.Contains(err.Error(), "exceeds maximum") {
-			t.Errorf("expected 'exceeds maximum' error, got: %v", err)
+		if !errors.Is(err, ErrBlockTooLarge) {
+			t.Errorf("expected ErrBlockTooLarge, got: %v", err)
 		}
 	})
 
@@ -265,8 +268,8 @@ func TestErrorScenarios_HasBlock(t *testing.T) {
 			if err == nil {
 				t.Errorf("expected error for CID %q", cid)
 			}
-			if !strings.Contains(err.Error(), "invalid CID") {
-				t.Logf("Error for %q: %v", cid, err)
+			if !errors.Is(err, ErrInvalidCID) {
+				t.Errorf("expected ErrInvalidCID for %q, got: %v", cid, err)
 			}
 		}
 	})
@@ -639,3 +642,74 @@ func TestErrorScenarios_PermissionErrors(t *testing.T) {
 		}
 	})
 }
+
+// TestErrorScenarios_Closed 测试关闭后的操作
+func TestErrorScenarios_Closed(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	c, err := repo.PutBlock(ctx, []byte("stored before close"))
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+	if err := repo.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	ops := map[string]func() error{
+		"PutBlock": func() error {
+			_, err := repo.PutBlock(ctx, []byte("data"))
+			return err
+		},
+		"PutBlockWithCid": func() error {
+			return repo.PutBlockWithCid(ctx, c.String(), []byte("stored before close"))
+		},
+		"PutManyBlocks": func() error {
+			_, err := repo.PutManyBlocks(ctx, [][]byte{[]byte("data")})
+			return err
+		},
+		"HasBlock": func() error {
+			_, err := repo.HasBlock(ctx, c.String())
+			return err
+		},
+		"HasAllBlocks": func() error {
+			_, err := repo.HasAllBlocks(ctx, []string{c.String()})
+			return err
+		},
+		"GetRawData": func() error {
+			_, err := repo.GetRawData(ctx, c.String())
+			return err
+		},
+		"DelBlock": func() error {
+			return repo.DelBlock(ctx, c.String())
+		},
+	}
+	for name, op := range ops {
+		if err := op(); !errors.Is(err, ErrClosed) {
+			t.Errorf("%s after Close = %v, want ErrClosed", name, err)
+		}
+	}
+}
+
+// TestErrorScenarios_BlockNotFound 测试读取不存在的块
+func TestErrorScenarios_BlockNotFound(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	defer repo.Close()
+
+	c, err := repo.PutBlock(ctx, []byte("deleted"))
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+	if err := repo.DelBlock(ctx, c.String()); err != nil {
+		t.Fatalf("DelBlock failed: %v", err)
+	}
+
+	_, err = repo.GetRawData(ctx, c.String())
+	var notFound *BlockNotFoundError
+	if !errors.As(err, &notFound) || !notFound.Cid.Equals(*c) {
+		t.Fatalf("GetRawData = %v, want *BlockNotFoundError for %s", err, c)
+	}
+	if !errors.Is(err, ErrBlockNotFound) || !ipld.IsNotFound(err) {
+		t.Errorf("GetRawData = %v, want ErrBlockNotFound and ipld.IsNotFound", err)
+	}
+}
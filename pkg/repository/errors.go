@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	cid2 "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+var (
+	// ErrInvalidCID 表示传入的 CID 字符串无法解析。
+	ErrInvalidCID = errors.New("invalid CID")
+
+	// ErrBlockTooLarge 表示数据块超过 RepoLimits.MaxBlockSize。
+	// 具体的大小和限制通过 *BlockTooLargeError 获取。
+	ErrBlockTooLarge = errors.New("block too large")
+
+	// ErrBlockNotFound 表示数据块在重试后仍不存在。
+	// 具体的 CID 通过 *BlockNotFoundError 获取。
+	ErrBlockNotFound = errors.New("block not found")
+
+	// ErrClosed 表示仓库已经关闭或销毁。
+	ErrClosed = errors.New("repository is closed")
+)
+
+// InvalidCIDsError 列出一组 CID 中所有无法解析的项。它包装 ErrInvalidCID。
+type InvalidCIDsError struct {
+	CIDs []string // 无法解析的 CID 字符串，保持输入顺序并去重
+	Err  error    // 第一个无效项的解析错误
+}
+
+func (e *InvalidCIDsError) Error() string {
+	if len(e.CIDs) == 1 {
+		return fmt.Sprintf("%v %q: %v", ErrInvalidCID, e.CIDs[0], e.Err)
+	}
+	return fmt.Sprintf("%d invalid CIDs, first %q: %v", len(e.CIDs), e.CIDs[0], e.Err)
+}
+
+func (e *InvalidCIDsError) Unwrap() []error {
+	return []error{ErrInvalidCID, e.Err}
+}
+
+// BlockTooLargeError 描述一次因数据块过大而被拒绝的写入。
+type BlockTooLargeError struct {
+	Size int // 数据块的字节数
+	Max  int // 允许的最大字节数
+}
+
+func (e *BlockTooLargeError) Error() string {
+	return fmt.Sprintf("%v: size %d bytes exceeds maximum %d bytes", ErrBlockTooLarge, e.Size, e.Max)
+}
+
+func (e *BlockTooLargeError) Unwrap() error {
+	return ErrBlockTooLarge
+}
+
+// BlockNotFoundError 描述一个不存在的数据块。
+// 它同时满足 ipld.IsNotFound，与 blockstore 返回的错误一致。
+type BlockNotFoundError struct {
+	Cid cid2.Cid // 不存在的块的 CID
+}
+
+func (e *BlockNotFoundError) Error() string {
+	return fmt.Sprintf("block %s not found", e.Cid)
+}
+
+func (e *BlockNotFoundError) Unwrap() []error {
+	return []error{ErrBlockNotFound, ipld.ErrNotFound{Cid: e.Cid}}
+}
+
+// checkBlockSize 在 size 超过 MaxBlockSize 时返回 *BlockTooLargeError。
+func (r *Repository) checkBlockSize(size int) error {
+	if size > r.limits.MaxBlockSize {
+		return &BlockTooLargeError{Size: size, Max: r.limits.MaxBlockSize}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package repository
+
+// AcquireForeground 标记一个前台操作（导入、提取等）开始。
+//
+// 后台任务（例如 validator.Scheduler）会在存在前台操作时暂停，
+// 以免影响前台延迟。每次调用都必须有对应的 ReleaseForeground。
+func (r *Repository) AcquireForeground() {
+	r.foreground.Add(1)
+}
+
+// ReleaseForeground 标记一个前台操作结束。
+//
+// 多余的调用会被忽略，计数不会变为负数。
+func (r *Repository) ReleaseForeground() {
+	for {
+		n := r.foreground.Load()
+		if n <= 0 {
+			return
+		}
+		if r.foreground.CompareAndSwap(n, n-1) {
+			return
+		}
+	}
+}
+
+// ForegroundActive 返回当前是否有前台操作正在进行。
+func (r *Repository) ForegroundActive() bool {
+	return r.foreground.Load() > 0
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/datastore/dshelp"
+	cid2 "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+// ForEachKey 对仓库中的每个数据块调用一次 fn。
+//
+// 数据块按 multihash 存储，不记录编码，因此传给 fn 的是 raw 编码的 CIDv1，
+// 与 blockstore 的 AllKeysChan 相同；与其他 CID 比较时应比较 Hash()。
+// 遍历直接读取 datastore，读取失败时返回错误，而不是像 AllKeysChan 那样
+// 提前关闭通道；不是数据块的键被忽略。分片仓库依次遍历所有分片。
+// 遍历期间写入或删除的块可能出现也可能不出现。
+//
+// 参数：
+//
+//	ctx - 用于取消遍历的上下文，取消后在下一个键之前返回 ctx.Err()
+//	fn - 每个块调用一次，返回错误时停止遍历并原样返回该错误
+//
+// 返回：
+//
+//	error - 遍历失败、fn 返回错误或上下文取消时返回错误
+func (r *Repository) ForEachKey(ctx context.Context, fn func(c cid2.Cid) error) error {
+	if err := r.life.enter(); err != nil {
+		return err
+	}
+	defer r.life.leave()
+
+	var fnErr error
+	visit := func(c cid2.Cid) error {
+		fnErr = fn(c)
+		return fnErr
+	}
+	for _, s := range r.storages() {
+		if err := forEachKey(ctx, s.Datastore(), visit); err != nil {
+			if fnErr != nil {
+				return fnErr
+			}
+			return s.RedactError(err)
+		}
+	}
+	return nil
+}
+
+// forEachKey 对 d 中 blockstore 前缀下的每个块调用 fn。
+func forEachKey(ctx context.Context, d ds.Datastore, fn func(c cid2.Cid) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	results, err := d.Query(ctx, query.Query{Prefix: blockstore.BlockPrefix.String(), KeysOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to list blocks: %w", err)
+	}
+	defer results.Close()
+
+	for result := range results.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if result.Error != nil {
+			return fmt.Errorf("failed to list blocks: %w", result.Error)
+		}
+		hash, err := dshelp.DsKeyToMultihash(ds.NewKey(ds.RawKey(result.Key).BaseNamespace()))
+		if err != nil {
+			continue
+		}
+		if err := fn(cid2.NewCidV1(cid2.Raw, hash)); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
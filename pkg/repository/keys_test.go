@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	cid2 "github.com/ipfs/go-cid"
+)
+
+// putKeysFixture stores n distinct blocks in repo and returns their hashes.
+func putKeysFixture(t *testing.T, repo *Repository, n int) map[string]bool {
+	t.Helper()
+
+	data := make([][]byte, n)
+	for i := range data {
+		data[i] = []byte(fmt.Sprintf("block %d", i))
+	}
+	cids, err := repo.PutManyBlocks(context.Background(), data)
+	if err != nil {
+		t.Fatalf("PutManyBlocks failed: %v", err)
+	}
+	want := make(map[string]bool, n)
+	for _, c := range cids {
+		want[string(c.Hash())] = true
+	}
+	return want
+}
+
+// collectKeys returns the hashes ForEachKey enumerates in repo.
+func collectKeys(t *testing.T, repo *Repository) map[string]bool {
+	t.Helper()
+
+	got := make(map[string]bool)
+	err := repo.ForEachKey(context.Background(), func(c cid2.Cid) error {
+		if got[string(c.Hash())] {
+			t.Errorf("ForEachKey visited %s twice", c)
+		}
+		got[string(c.Hash())] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachKey failed: %v", err)
+	}
+	return got
+}
+
+func TestRepository_ForEachKey(t *testing.T) {
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	want := putKeysFixture(t, repo, 50)
+	got := collectKeys(t, repo)
+	if len(got) != len(want) {
+		t.Errorf("ForEachKey visited %d blocks, want %d", len(got), len(want))
+	}
+	for h := range want {
+		if !got[h] {
+			t.Errorf("ForEachKey missed a stored block")
+		}
+	}
+}
+
+func TestRepository_ForEachKey_Sharded(t *testing.T) {
+	repo, err := NewShardedRepository(shardPaths(t, 3), RepoOptions{})
+	if err != nil {
+		t.Fatalf("NewShardedRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	want := putKeysFixture(t, repo, 50)
+	got := collectKeys(t, repo)
+	if len(got) != len(want) {
+		t.Errorf("ForEachKey visited %d blocks across shards, want %d", len(got), len(want))
+	}
+}
+
+func TestRepository_ForEachKey_Stop(t *testing.T) {
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+	putKeysFixture(t, repo, 10)
+
+	// An error from fn stops the walk and is returned unchanged
+	stop := errors.New("stop")
+	visited := 0
+	err = repo.ForEachKey(context.Background(), func(cid2.Cid) error {
+		visited++
+		return stop
+	})
+	if err != stop || visited != 1 {
+		t.Errorf("ForEachKey() = %v after %d blocks, want stop after 1", err, visited)
+	}
+
+	// Cancelling the context stops the walk before the next key
+	ctx, cancel := context.WithCancel(context.Background())
+	visited = 0
+	err = repo.ForEachKey(ctx, func(cid2.Cid) error {
+		visited++
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || visited != 1 {
+		t.Errorf("ForEachKey() = %v after %d blocks, want context.Canceled after 1", err, visited)
+	}
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"sync"
+)
+
+// lifecycle 记录仓库是否已经关闭以及正在进行的操作数。
+//
+// 操作开始时调用 enter，结束时调用 leave。关闭先标记仓库已关闭，之后开始的
+// 操作得到 ErrClosed，再等待已经开始的操作结束。enter 从不等待关闭，因此
+// 操作内部（例如变更回调中）再次调用仓库的方法不会死锁，只会得到 ErrClosed。
+type lifecycle struct {
+	mu      sync.Mutex
+	closed  bool
+	active  int           // 正在进行的操作数
+	drained chan struct{} // 关闭后所有操作都已结束时关闭；未关闭时为 nil
+}
+
+// enter 登记一个操作。仓库已关闭时返回 ErrClosed，此时不需要调用 leave。
+func (l *lifecycle) enter() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+	l.active++
+	return nil
+}
+
+// leave 结束 enter 登记的操作。
+func (l *lifecycle) leave() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	if l.closed && l.active == 0 {
+		close(l.drained)
+	}
+}
+
+// close 标记仓库已关闭，返回在所有已开始的操作结束时关闭的 channel。
+// 可以多次调用，返回同一个 channel。
+func (l *lifecycle) close() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.closed {
+		l.closed = true
+		l.drained = make(chan struct{})
+		if l.active == 0 {
+			close(l.drained)
+		}
+	}
+	return l.drained
+}
+
+// drain 为 Destroy 标记仓库已关闭并等待已开始的操作结束，ctx 先结束时返回
+// ctx.Err()。调用时 ctx 已经结束则不做任何操作，仓库保持可用。
+func (r *Repository) drain(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	drained := r.life.close()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	cid2 "github.com/ipfs/go-cid"
+	"github.com/tragoedia0722/repository/pkg/importer"
+)
+
+func TestRepository_CloseWhileOperating(t *testing.T) {
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	ctx := context.Background()
+
+	// Every call either completes or returns ErrClosed, run with -race
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; ; j++ {
+				data := []byte(fmt.Sprintf("worker %d block %d", id, j))
+				c, err := repo.PutBlock(ctx, data)
+				if errors.Is(err, ErrClosed) {
+					return
+				}
+				if err != nil {
+					t.Errorf("PutBlock failed: %v", err)
+					return
+				}
+
+				got, err := repo.GetRawData(ctx, c.String())
+				if errors.Is(err, ErrClosed) {
+					return
+				}
+				if err != nil || !bytes.Equal(got, data) {
+					t.Errorf("GetRawData = %q, %v, want %q", got, err, data)
+					return
+				}
+			}
+		}(i)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if err := repo.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	wg.Wait()
+
+	if _, err := repo.PutBlock(ctx, []byte("late")); !errors.Is(err, ErrClosed) {
+		t.Errorf("PutBlock after Close = %v, want ErrClosed", err)
+	}
+	if _, err := repo.Usage(ctx); !errors.Is(err, ErrClosed) {
+		t.Errorf("Usage after Close = %v, want ErrClosed", err)
+	}
+	if err := repo.Close(); err != nil {
+		t.Errorf("second Close failed: %v", err)
+	}
+}
+
+func TestRepository_CloseWaitsForOperations(t *testing.T) {
+	data := []byte("read during close")
+	repo, gated, c := newGatedRepository(t, data)
+
+	type result struct {
+		data []byte
+		err  error
+	}
+	read := make(chan result, 1)
+	go func() {
+		got, err := repo.GetRawData(context.Background(), c)
+		read <- result{got, err}
+	}()
+	waitFor(t, "the read to start", func() bool { return gated.reads.Load() == 1 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := repo.CloseWithContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("CloseWithContext = %v, want DeadlineExceeded while a read is running", err)
+	}
+	if _, err := repo.PutBlock(context.Background(), []byte("late")); !errors.Is(err, ErrClosed) {
+		t.Errorf("PutBlock during Close = %v, want ErrClosed", err)
+	}
+
+	// The running read still completes against the open datastore
+	close(gated.release)
+	if r := <-read; r.err != nil || !bytes.Equal(r.data, data) {
+		t.Errorf("GetRawData = %q, %v, want %q", r.data, r.err, data)
+	}
+	if err := repo.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
+
+func TestRepository_MethodsAfterClose(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	c, err := repo.PutBlock(ctx, []byte("stored before close"))
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+	if err := repo.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	res := &importer.Result{RootCid: c.String()}
+	tests := []struct {
+		name string
+		op   func() error
+	}{
+		{"ExportCAR", func() error { return repo.ExportCAR(ctx, c.String(), io.Discard) }},
+		{"ImportCAR", func() error {
+			_, err := repo.ImportCAR(ctx, bytes.NewReader(nil))
+			return err
+		}},
+		{"ForEachKey", func() error { return repo.ForEachKey(ctx, func(cid2.Cid) error { return nil }) }},
+		{"CommitImport", func() error { return repo.CommitImport(ctx, res, CommitOptions{}) }},
+		{"AbortImport", func() error { return repo.AbortImport(ctx, res) }},
+		{"LoadImport", func() error {
+			_, err := repo.LoadImport(ctx, c.String())
+			return err
+		}},
+		{"IsPinned", func() error {
+			_, err := repo.IsPinned(ctx, c.String())
+			return err
+		}},
+		{"BlockRefs", func() error {
+			_, err := repo.BlockRefs(ctx, c.String())
+			return err
+		}},
+		{"Usage", func() error {
+			_, err := repo.Usage(ctx)
+			return err
+		}},
+		{"UsageDetail", func() error {
+			_, err := repo.UsageDetail(ctx)
+			return err
+		}},
+		{"ReconcileQuota", func() error { return repo.ReconcileQuota(ctx) }},
+		{"QuotaUsage", func() error {
+			_, err := repo.QuotaUsage()
+			return err
+		}},
+		{"CleanupArtifacts", func() error {
+			_, err := repo.CleanupArtifacts(ctx, CleanupOptions{})
+			return err
+		}},
+		{"CorruptBlocks", func() error {
+			_, err := repo.CorruptBlocks()
+			return err
+		}},
+		{"GetBlockReader", func() error {
+			_, _, err := repo.GetBlockReader(ctx, c.String())
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.op(); !errors.Is(err, ErrClosed) {
+				t.Errorf("%s after Close = %v, want ErrClosed", tt.name, err)
+			}
+		})
+	}
+}
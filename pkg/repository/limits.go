@@ -0,0 +1,57 @@
+package repository
+
+import "time"
+
+const (
+	// DefaultMaxBlockSize 是单个数据块的默认最大字节数。
+	DefaultMaxBlockSize = 128 * 1024 * 1024 // 128MB
+
+	// DefaultHasCheckConcurrency 是 HasAllBlocks 等批量存在性检查的默认并发数。
+	DefaultHasCheckConcurrency = 100
+
+	// DefaultGetRetryAttempts 是 GetRawData 在块不存在时的默认尝试次数。
+	DefaultGetRetryAttempts = 3
+
+	// DefaultGetRetryBaseDelay 是 GetRawData 重试的初始退避时间，每次重试翻倍。
+	DefaultGetRetryBaseDelay = 50 * time.Millisecond
+)
+
+// RepoLimits 描述仓库实际生效的运行限制。
+//
+// 调用者应使用 Limits() 返回的值预先切分数据或设置自己的工作池大小，
+// 而不是硬编码这些数值。
+type RepoLimits struct {
+	// MaxBlockSize 是单个数据块的最大字节数，超过时写入失败。
+	MaxBlockSize int
+
+	// HasCheckConcurrency 是批量存在性检查的最大并发数。
+	HasCheckConcurrency int
+
+	// GetRetryAttempts 是读取不存在的块时的最大尝试次数。
+	GetRetryAttempts int
+
+	// GetRetryBaseDelay 是读取重试的初始退避时间，每次重试翻倍。
+	GetRetryBaseDelay time.Duration
+
+	// QuotaBytes 是数据块的最大总字节数，0 表示不限制。
+	QuotaBytes int64
+}
+
+// defaultLimits 返回默认的运行限制。
+func defaultLimits() RepoLimits {
+	return RepoLimits{
+		MaxBlockSize:        DefaultMaxBlockSize,
+		HasCheckConcurrency: DefaultHasCheckConcurrency,
+		GetRetryAttempts:    DefaultGetRetryAttempts,
+		GetRetryBaseDelay:   DefaultGetRetryBaseDelay,
+	}
+}
+
+// Limits 返回仓库实际生效的运行限制。
+//
+// 返回：
+//
+//	RepoLimits - 当前实例使用的限制值
+func (r *Repository) Limits() RepoLimits {
+	return r.limits
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRepository_Limits_Defaults(t *testing.T) {
+	repo, err := NewRepositoryWithOptions(t.TempDir(), RepoOptions{QuotaBytes: 1 << 30})
+	if err != nil {
+		t.Fatalf("NewRepositoryWithOptions failed: %v", err)
+	}
+	defer repo.Close()
+
+	want := RepoLimits{
+		MaxBlockSize:        DefaultMaxBlockSize,
+		HasCheckConcurrency: DefaultHasCheckConcurrency,
+		GetRetryAttempts:    DefaultGetRetryAttempts,
+		GetRetryBaseDelay:   DefaultGetRetryBaseDelay,
+		QuotaBytes:          1 << 30,
+	}
+	if got := repo.Limits(); got != want {
+		t.Errorf("Limits() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRepository_Limits_MatchEnforcement(t *testing.T) {
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	// Shrink the effective limit so the test does not allocate 128MB blocks
+	repo.limits.MaxBlockSize = 4096
+
+	ctx := context.Background()
+	limit := repo.Limits().MaxBlockSize
+	if limit != 4096 {
+		t.Fatalf("Limits().MaxBlockSize = %d, want the effective 4096", limit)
+	}
+
+	atLimit := make([]byte, limit)
+	overLimit := make([]byte, limit+1)
+	wantMsg := fmt.Sprintf("exceeds maximum %d bytes", limit)
+
+	if _, err := repo.PutBlock(ctx, atLimit); err != nil {
+		t.Errorf("PutBlock at the limit failed: %v", err)
+	}
+	if _, err := repo.PutBlock(ctx, overLimit); err == nil || !strings.Contains(err.Error(), wantMsg) {
+		t.Errorf("PutBlock over the limit: err = %v, want %q", err, wantMsg)
+	}
+
+	c, err := repo.builder.Sum(overLimit)
+	if err != nil {
+		t.Fatalf("Sum failed: %v", err)
+	}
+	if err := repo.PutBlockWithCid(ctx, c.String(), overLimit); err == nil || !strings.Contains(err.Error(), wantMsg) {
+		t.Errorf("PutBlockWithCid over the limit: err = %v, want %q", err, wantMsg)
+	}
+
+	if _, err := repo.PutManyBlocks(ctx, [][]byte{atLimit, overLimit}); err == nil || !strings.Contains(err.Error(), wantMsg) {
+		t.Errorf("PutManyBlocks over the limit: err = %v, want %q", err, wantMsg)
+	}
+	if _, err := repo.PutManyBlocks(ctx, [][]byte{atLimit}); err != nil {
+		t.Errorf("PutManyBlocks at the limit failed: %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/tragoedia0722/repository/internal/storage"
+)
+
+// NewMemoryRepository 创建一个数据保存在内存中的仓库实例，用于测试和临时的处理流程。
+//
+// 内存仓库与磁盘仓库提供相同的 API，BlockStore() 返回同样满足
+// blockstore.Blockstore 的 blockstore，导入器、导出器和校验器可以直接使用。
+// 它不创建目录也不持有锁文件，因此不会与其他实例争用。
+// Usage 返回所有数据块的字节数之和；Destroy 和 ForceDestroy 与 Close 相同，
+// CheckMount 总是成功，CleanupArtifacts 只扫描 CleanupOptions.Dirs。
+// 数据只在实例的生命周期内存在。使用默认配置，需要配额等选项时应使用磁盘仓库。
+//
+// 返回：
+//
+//	*Repository - 仓库实例
+func NewMemoryRepository() *Repository {
+	s := storage.NewMemoryStorage()
+	r := &Repository{
+		storage:    s,
+		blockStore: blockstore.NewBlockstore(s.Datastore()),
+		builder:    RepoOptions{}.cidBuilder(),
+		limits:     defaultLimits(),
+		reads:      newReadGroup(),
+		access:     newAccessStats(AccessStatsOptions{}),
+	}
+	r.blockStore = r.watchBlockstore(r.accessBlockstore(r.blockStore))
+	return r
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/ipfs/boxo/blockstore"
+	ds "github.com/ipfs/go-datastore"
+)
+
+func TestNewMemoryRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	defer repo.Close()
+
+	var _ blockstore.Blockstore = repo.BlockStore()
+
+	data := [][]byte{[]byte("first block"), []byte("second"), bytes.Repeat([]byte("x"), 4096)}
+	var cids []string
+	var want uint64
+	for _, b := range data {
+		c, err := repo.PutBlock(ctx, b)
+		if err != nil {
+			t.Fatalf("PutBlock failed: %v", err)
+		}
+		cids = append(cids, c.String())
+		want += uint64(len(b))
+	}
+	// Storing a block again does not count it twice
+	if _, err := repo.PutBlock(ctx, data[0]); err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+
+	for i, c := range cids {
+		got, err := repo.GetRawData(ctx, c)
+		if err != nil {
+			t.Fatalf("GetRawData failed: %v", err)
+		}
+		if !bytes.Equal(got, data[i]) {
+			t.Errorf("GetRawData(%s) = %q, want %q", c, got, data[i])
+		}
+	}
+
+	ok, err := repo.HasAllBlocks(ctx, cids)
+	if err != nil || !ok {
+		t.Errorf("HasAllBlocks = %v, %v, want true", ok, err)
+	}
+
+	// Metadata in the datastore does not count as usage
+	if err := repo.DataStore().Put(ctx, ds.NewKey("/meta/test"), []byte("metadata")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	usage, err := repo.Usage(ctx)
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	if usage != want {
+		t.Errorf("Usage = %d, want %d", usage, want)
+	}
+
+	if err := repo.CheckMount(); err != nil {
+		t.Errorf("CheckMount failed: %v", err)
+	}
+}
+
+func TestNewMemoryRepository_Destroy(t *testing.T) {
+	repo := NewMemoryRepository()
+	if _, err := repo.PutBlock(context.Background(), []byte("data")); err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := repo.Destroy(); err != nil {
+			t.Fatalf("Destroy #%d failed: %v", i+1, err)
+		}
+	}
+	if err := repo.Close(); err != nil {
+		t.Errorf("Close after Destroy failed: %v", err)
+	}
+
+	// Instances do not share data
+	other := NewMemoryRepository()
+	defer other.Close()
+	usage, err := other.Usage(context.Background())
+	if err != nil || usage != 0 {
+		t.Errorf("Usage of a new instance = %d, %v, want 0", usage, err)
+	}
+}
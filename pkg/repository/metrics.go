@@ -0,0 +1,257 @@
+package repository
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid2 "github.com/ipfs/go-cid"
+)
+
+// BlockOp 是 MetricsSink.ObserveBatch 报告的批量操作的类型。
+type BlockOp int
+
+const (
+	BlockOpPut BlockOp = iota // 写入：PutManyBlocks、PutManyBlocksWithPrefix 和 ImportCAR
+	BlockOpGet                // 读取：GetManyRawData
+	BlockOpHas                // 存在检查：HasAllBlocks、MissingBlocks 及其 Cid 版本
+)
+
+// String 返回操作的名称，可直接用作度量指标的标签。
+func (op BlockOp) String() string {
+	switch op {
+	case BlockOpPut:
+		return "put"
+	case BlockOpGet:
+		return "get"
+	case BlockOpHas:
+		return "has"
+	default:
+		return "unknown"
+	}
+}
+
+// MetricsSink 接收仓库块操作的观测值，用于把操作次数、字节数、延迟和错误
+// 导出到监控系统。
+//
+// 每个方法在对应的操作结束时调用，dur 是操作的耗时，err 是操作返回的错误
+// （成功时为 nil）。方法可能被多个 goroutine 并发调用，实现必须是并发安全的，
+// 并且应尽快返回：它们在操作的调用路径上同步执行。
+type MetricsSink interface {
+	// ObservePut 报告一个块的写入，size 是块的字节数。
+	ObservePut(size int, dur time.Duration, err error)
+
+	// ObserveGet 报告一个块的读取，size 是读到的字节数，失败时为 0。
+	// 块不存在时 err 满足 ipld.IsNotFound。
+	ObserveGet(size int, dur time.Duration, err error)
+
+	// ObserveHas 报告一个块的存在检查，found 是检查结果。
+	ObserveHas(found bool, dur time.Duration, err error)
+
+	// ObserveDelete 报告一个块的删除。
+	ObserveDelete(dur time.Duration, err error)
+
+	// ObserveBatch 报告一次批量操作，count 是批中的块数，size 是写入或
+	// 读到的总字节数（存在检查为 0）。批中的每个块另外通过对应的
+	// Observe 方法单独报告。
+	ObserveBatch(op BlockOp, count, size int, dur time.Duration, err error)
+}
+
+// WithMetrics 设置接收块操作观测值的 MetricsSink，nil 表示不报告。
+//
+// 报告的操作包括 PutBlock、PutBlockWithCid、PutManyBlocks、HasBlock、
+// HasAllBlocks、MissingBlocks、GetRawData、GetManyRawData、GetBlockReader、
+// DelBlock 及其 Cid 和 Prefix 版本，以及 ImportCAR 和 ExportCAR 的块读写；
+// 导入器等直接使用 BlockStore() 的操作不报告。仓库关闭后以 ErrClosed
+// 拒绝的调用没有执行，也不报告。
+//
+// 批量操作既按块也按批报告：GetManyRawData 和 HasAllBlocks 的每个块报告
+// 各自的耗时，重复的 CID 只报告一次；PutManyBlocks 和 ImportCAR 的块在
+// 同一次写入中完成，每个块报告批的平均耗时和批的错误。
+//
+// 未设置时各操作只多一次 nil 判断，没有额外的分配（由
+// TestRepository_Metrics_NoAllocs 检查）。WithMetrics 应在使用仓库之前调用，
+// 不能与块操作并发。
+//
+// 参数：
+//
+//	m - 观测值的接收者，可以使用 NewMetricsCounters
+//
+// 返回：
+//
+//	*Repository - 仓库实例，便于链式调用
+func (r *Repository) WithMetrics(m MetricsSink) *Repository {
+	r.metrics = m
+	return r
+}
+
+// hasBlock 检查块 c 是否存在，设置了 MetricsSink 时报告这次检查。
+func (r *Repository) hasBlock(ctx context.Context, c cid2.Cid) (bool, error) {
+	if r.metrics == nil {
+		return r.blockStore.Has(ctx, c)
+	}
+	start := time.Now()
+	has, err := r.blockStore.Has(ctx, c)
+	r.metrics.ObserveHas(has, time.Since(start), err)
+	return has, err
+}
+
+// getBlock 读取块 c，设置了 MetricsSink 时报告这次读取。
+func (r *Repository) getBlock(ctx context.Context, c cid2.Cid) (blocks.Block, error) {
+	if r.metrics == nil {
+		return r.blockStore.Get(ctx, c)
+	}
+	start := time.Now()
+	blk, err := r.blockStore.Get(ctx, c)
+	size := 0
+	if blk != nil {
+		size = len(blk.RawData())
+	}
+	r.metrics.ObserveGet(size, time.Since(start), err)
+	return blk, err
+}
+
+// putCARBatch 写入 ImportCAR 的一批块，设置了 MetricsSink 时报告这次写入。
+func (r *Repository) putCARBatch(ctx context.Context, batch []blocks.Block) error {
+	if r.metrics == nil {
+		return r.blockStore.PutMany(ctx, batch)
+	}
+	start := time.Now()
+	err := r.blockStore.PutMany(ctx, batch)
+	r.observePutBatch(len(batch), func(i int) int { return len(batch[i].RawData()) }, time.Since(start), err)
+	return err
+}
+
+// observePutBatch 报告 n 个块的一次批量写入，size 返回第 i 个块的字节数。
+// 调用者必须已确认 r.metrics 不为 nil。
+func (r *Repository) observePutBatch(n int, size func(i int) int, dur time.Duration, err error) {
+	per := dur
+	if n > 0 {
+		per = dur / time.Duration(n)
+	}
+	total := 0
+	for i := 0; i < n; i++ {
+		r.metrics.ObservePut(size(i), per, err)
+		total += size(i)
+	}
+	r.metrics.ObserveBatch(BlockOpPut, n, total, dur, err)
+}
+
+// OpMetrics 是一类块操作的累计值。
+type OpMetrics struct {
+	Count    uint64        // 操作次数
+	Errors   uint64        // 返回错误的次数
+	Bytes    uint64        // 写入或读到的字节数
+	Duration time.Duration // 总耗时，除以 Count 得到平均延迟
+}
+
+// MetricsSnapshot 是 MetricsCounters 的快照。
+type MetricsSnapshot struct {
+	Put    OpMetrics // 单个块的写入，包括批量写入中的每个块
+	Get    OpMetrics // 单个块的读取，包括批量读取中的每个块
+	Has    OpMetrics // 单个块的存在检查，包括批量检查中的每个块
+	Found  uint64    // 存在检查中块存在的次数
+	Delete OpMetrics // 块的删除
+
+	PutBatch OpMetrics // 批量写入，Count 为批数
+	GetBatch OpMetrics // 批量读取，Count 为批数
+	HasBatch OpMetrics // 批量存在检查，Count 为批数
+}
+
+// MetricsCounters 是以原子计数器累计观测值的 MetricsSink，供调用者定期
+// 读取总数，例如导出到只支持计数器的监控系统。零值可以直接使用。
+type MetricsCounters struct {
+	put, get, has, del           opCounters
+	found                        atomic.Uint64
+	putBatch, getBatch, hasBatch opCounters
+}
+
+// NewMetricsCounters 创建一个计数从零开始的 MetricsCounters。
+//
+// 返回：
+//
+//	*MetricsCounters - 计数器实例
+func NewMetricsCounters() *MetricsCounters {
+	return &MetricsCounters{}
+}
+
+// ObservePut 实现 MetricsSink。
+func (m *MetricsCounters) ObservePut(size int, dur time.Duration, err error) {
+	m.put.add(size, dur, err)
+}
+
+// ObserveGet 实现 MetricsSink。
+func (m *MetricsCounters) ObserveGet(size int, dur time.Duration, err error) {
+	m.get.add(size, dur, err)
+}
+
+// ObserveHas 实现 MetricsSink。
+func (m *MetricsCounters) ObserveHas(found bool, dur time.Duration, err error) {
+	m.has.add(0, dur, err)
+	if found {
+		m.found.Add(1)
+	}
+}
+
+// ObserveDelete 实现 MetricsSink。
+func (m *MetricsCounters) ObserveDelete(dur time.Duration, err error) {
+	m.del.add(0, dur, err)
+}
+
+// ObserveBatch 实现 MetricsSink。
+func (m *MetricsCounters) ObserveBatch(op BlockOp, count, size int, dur time.Duration, err error) {
+	switch op {
+	case BlockOpPut:
+		m.putBatch.add(size, dur, err)
+	case BlockOpGet:
+		m.getBatch.add(size, dur, err)
+	case BlockOpHas:
+		m.hasBatch.add(size, dur, err)
+	}
+}
+
+// Snapshot 返回当前的累计值。各计数分别原子地读取，与并发的操作
+// 同时读取时不同计数之间可能相差正在进行的操作。
+func (m *MetricsCounters) Snapshot() MetricsSnapshot {
+	return MetricsSnapshot{
+		Put:      m.put.load(),
+		Get:      m.get.load(),
+		Has:      m.has.load(),
+		Found:    m.found.Load(),
+		Delete:   m.del.load(),
+		PutBatch: m.putBatch.load(),
+		GetBatch: m.getBatch.load(),
+		HasBatch: m.hasBatch.load(),
+	}
+}
+
+// opCounters 是一类操作的原子计数器。
+type opCounters struct {
+	count  atomic.Uint64
+	errors atomic.Uint64
+	bytes  atomic.Uint64
+	nanos  atomic.Int64
+}
+
+// add 累计一次操作。
+func (c *opCounters) add(size int, dur time.Duration, err error) {
+	c.count.Add(1)
+	if err != nil {
+		c.errors.Add(1)
+	}
+	if size > 0 {
+		c.bytes.Add(uint64(size))
+	}
+	c.nanos.Add(int64(dur))
+}
+
+// load 返回累计值。
+func (c *opCounters) load() OpMetrics {
+	return OpMetrics{
+		Count:    c.count.Load(),
+		Errors:   c.errors.Load(),
+		Bytes:    c.bytes.Load(),
+		Duration: time.Duration(c.nanos.Load()),
+	}
+}
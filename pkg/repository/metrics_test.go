@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestRepository_Metrics(t *testing.T) {
+	ctx := context.Background()
+	m := NewMetricsCounters()
+	repo := NewMemoryRepository().WithMetrics(m)
+	defer repo.Close()
+
+	a, err := repo.PutBlock(ctx, []byte("a"))
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+	many, err := repo.PutManyBlocks(ctx, [][]byte{[]byte("bb"), []byte("ccc")})
+	if err != nil {
+		t.Fatalf("PutManyBlocks failed: %v", err)
+	}
+	absent, err := repo.builder.Sum([]byte("absent"))
+	if err != nil {
+		t.Fatalf("Sum failed: %v", err)
+	}
+
+	if has, err := repo.HasBlock(ctx, a.String()); err != nil || !has {
+		t.Errorf("HasBlock = %v, %v, want true", has, err)
+	}
+	if has, err := repo.HasBlockCid(ctx, absent); err != nil || has {
+		t.Errorf("HasBlockCid = %v, %v, want false", has, err)
+	}
+	// 重复的 CID 只检查一次
+	if has, err := repo.HasAllBlocks(ctx, []string{a.String(), a.String(), absent.String()}); err != nil || has {
+		t.Errorf("HasAllBlocks = %v, %v, want false", has, err)
+	}
+
+	if _, err := repo.GetRawData(ctx, a.String()); err != nil {
+		t.Fatalf("GetRawData failed: %v", err)
+	}
+	// 缺失的块在按块的观测中是错误，批本身成功
+	if _, _, err := repo.GetManyRawData(ctx, []string{many[0].String(), many[0].String(), absent.String()}); err != nil {
+		t.Fatalf("GetManyRawData failed: %v", err)
+	}
+
+	if err := repo.DelBlock(ctx, a.String()); err != nil {
+		t.Fatalf("DelBlock failed: %v", err)
+	}
+
+	if err := repo.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	// 关闭后被拒绝的调用不计入
+	if _, err := repo.PutBlock(ctx, []byte("d")); !errors.Is(err, ErrClosed) {
+		t.Fatalf("PutBlock after Close = %v, want ErrClosed", err)
+	}
+	if _, err := repo.HasBlockCid(ctx, absent); !errors.Is(err, ErrClosed) {
+		t.Fatalf("HasBlockCid after Close = %v, want ErrClosed", err)
+	}
+
+	// 耗时与运行环境有关，不参与比较
+	got := m.Snapshot()
+	for _, op := range []*OpMetrics{&got.Put, &got.Get, &got.Has, &got.Delete, &got.PutBatch, &got.GetBatch, &got.HasBatch} {
+		op.Duration = 0
+	}
+	want := MetricsSnapshot{
+		Put:      OpMetrics{Count: 3, Bytes: 6},
+		Get:      OpMetrics{Count: 3, Errors: 1, Bytes: 3},
+		Has:      OpMetrics{Count: 4},
+		Found:    2,
+		Delete:   OpMetrics{Count: 1},
+		PutBatch: OpMetrics{Count: 1, Bytes: 5},
+		GetBatch: OpMetrics{Count: 1, Bytes: 2},
+		HasBatch: OpMetrics{Count: 1},
+	}
+	if got != want {
+		t.Errorf("Snapshot =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestRepository_Metrics_CAR(t *testing.T) {
+	ctx := context.Background()
+	src := NewMemoryRepository()
+	defer src.Close()
+	root, err := src.PutBlockWithPrefix(ctx, []byte("root"), sha512Prefix)
+	if err != nil {
+		t.Fatalf("PutBlockWithPrefix failed: %v", err)
+	}
+
+	m := NewMetricsCounters()
+	src.WithMetrics(m)
+	var buf bytes.Buffer
+	if err := src.ExportCAR(ctx, root.String(), &buf); err != nil {
+		t.Fatalf("ExportCAR failed: %v", err)
+	}
+	if got := m.Snapshot().Get; got.Count != 1 || got.Bytes != 4 {
+		t.Errorf("ExportCAR reads = %+v, want 1 block of 4 bytes", got)
+	}
+
+	dst := NewMemoryRepository().WithMetrics(m)
+	defer dst.Close()
+	if _, err := dst.ImportCAR(ctx, &buf); err != nil {
+		t.Fatalf("ImportCAR failed: %v", err)
+	}
+	s := m.Snapshot()
+	if s.Put.Count != 1 || s.Put.Bytes != 4 || s.PutBatch.Count != 1 {
+		t.Errorf("ImportCAR writes = %+v, batches = %+v, want 1 block of 4 bytes in 1 batch", s.Put, s.PutBatch)
+	}
+}
+
+func TestRepository_Metrics_NoAllocs(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	defer repo.Close()
+	c, err := repo.PutBlock(ctx, []byte("allocs"))
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+
+	// 未设置 MetricsSink 时与直接访问 blockstore 的分配次数相同
+	bs := repo.BlockStore()
+	base := testing.AllocsPerRun(100, func() { _, _ = bs.Has(ctx, *c) })
+	if got := testing.AllocsPerRun(100, func() { _, _ = repo.HasBlockCid(ctx, *c) }); got != base {
+		t.Errorf("HasBlockCid without metrics: %v allocs, blockstore Has: %v", got, base)
+	}
+	base = testing.AllocsPerRun(100, func() { _ = bs.DeleteBlock(ctx, *c) })
+	if got := testing.AllocsPerRun(100, func() { _ = repo.DelBlockCid(ctx, *c) }); got != base {
+		t.Errorf("DelBlockCid without metrics: %v allocs, blockstore DeleteBlock: %v", got, base)
+	}
+
+	// MetricsCounters 本身也不分配
+	repo.WithMetrics(NewMetricsCounters())
+	base = testing.AllocsPerRun(100, func() { _, _ = bs.Has(ctx, *c) })
+	if got := testing.AllocsPerRun(100, func() { _, _ = repo.HasBlockCid(ctx, *c) }); got != base {
+		t.Errorf("HasBlockCid with MetricsCounters: %v allocs, blockstore Has: %v", got, base)
+	}
+}
+
+// BenchmarkGetRawData_Metrics 比较未设置和设置 MetricsSink 时的读取开销，
+// 未设置时的分配次数应与没有度量时相同
+func BenchmarkGetRawData_Metrics(b *testing.B) {
+	for _, observed := range []bool{false, true} {
+		b.Run(fmt.Sprintf("metrics=%v", observed), func(b *testing.B) {
+			repo := NewMemoryRepository()
+			defer repo.Close()
+			if observed {
+				repo.WithMetrics(NewMetricsCounters())
+			}
+
+			ctx := context.Background()
+			c, err := repo.PutBlock(ctx, bytes.Repeat([]byte("m"), 4096))
+			if err != nil {
+				b.Fatalf("PutBlock failed: %v", err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.GetRawDataCid(ctx, *c); err != nil {
+					b.Fatalf("GetRawDataCid failed: %v", err)
+				}
+			}
+		})
+	}
+}
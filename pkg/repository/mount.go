@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	cid2 "github.com/ipfs/go-cid"
+	"github.com/tragoedia0722/repository/internal/storage"
+)
+
+// DefaultVerifyMountTTL 是启用 VerifyMount 时两次哨兵文件检查之间的默认间隔。
+const DefaultVerifyMountTTL = time.Second
+
+// ErrRepositoryMoved 表示仓库目录已被删除、替换或重新挂载。
+var ErrRepositoryMoved = storage.ErrRepositoryMoved
+
+// mountCheckBlockstore 在每次 blockstore 操作前检查仓库目录是否仍是打开时的目录。
+//
+// 检查成功的结果缓存 ttl 时间，避免每次操作都读取哨兵文件；
+// 检查失败不缓存，之后的操作会继续失败。
+type mountCheckBlockstore struct {
+	blockstore.Blockstore
+
+	storage *storage.Storage
+	ttl     time.Duration
+	checked atomic.Int64 // 上次检查成功的时间（UnixNano），0 表示尚未检查
+}
+
+// check 在缓存过期时检查哨兵文件。
+func (b *mountCheckBlockstore) check() error {
+	now := time.Now().UnixNano()
+	if last := b.checked.Load(); last != 0 && now-last < int64(b.ttl) {
+		return nil
+	}
+
+	if err := b.storage.CheckSentinel(); err != nil {
+		return err
+	}
+	b.checked.Store(now)
+	return nil
+}
+
+func (b *mountCheckBlockstore) DeleteBlock(ctx context.Context, c cid2.Cid) error {
+	if err := b.check(); err != nil {
+		return err
+	}
+	return b.Blockstore.DeleteBlock(ctx, c)
+}
+
+func (b *mountCheckBlockstore) Has(ctx context.Context, c cid2.Cid) (bool, error) {
+	if err := b.check(); err != nil {
+		return false, err
+	}
+	return b.Blockstore.Has(ctx, c)
+}
+
+func (b *mountCheckBlockstore) Get(ctx context.Context, c cid2.Cid) (blocks.Block, error) {
+	if err := b.check(); err != nil {
+		return nil, err
+	}
+	return b.Blockstore.Get(ctx, c)
+}
+
+func (b *mountCheckBlockstore) GetSize(ctx context.Context, c cid2.Cid) (int, error) {
+	if err := b.check(); err != nil {
+		return -1, err
+	}
+	return b.Blockstore.GetSize(ctx, c)
+}
+
+func (b *mountCheckBlockstore) Put(ctx context.Context, blk blocks.Block) error {
+	if err := b.check(); err != nil {
+		return err
+	}
+	return b.Blockstore.Put(ctx, blk)
+}
+
+func (b *mountCheckBlockstore) PutMany(ctx context.Context, blks []blocks.Block) error {
+	if err := b.check(); err != nil {
+		return err
+	}
+	return b.Blockstore.PutMany(ctx, blks)
+}
+
+func (b *mountCheckBlockstore) AllKeysChan(ctx context.Context) (<-chan cid2.Cid, error) {
+	if err := b.check(); err != nil {
+		return nil, err
+	}
+	return b.Blockstore.AllKeysChan(ctx)
+}
+
+// CheckMount 检查仓库目录是否仍是打开时的目录。
+//
+// 不受 VerifyMount 缓存影响，每次调用都重新读取哨兵文件。分片仓库检查所有分片。
+//
+// 返回：
+//
+//	error - 如果目录已被删除、替换或重新挂载，返回包装 ErrRepositoryMoved 的错误
+func (r *Repository) CheckMount() error {
+	for _, s := range r.storages() {
+		if err := s.CheckSentinel(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WatchMount 每隔 interval 检查一次仓库目录，在目录变化时通过返回的通道通知。
+//
+// 通道在第一次检查失败时收到包装 ErrRepositoryMoved 的错误后关闭；
+// ctx 取消时直接关闭。分片仓库检查所有分片，任意分片变化时通知。
+//
+// 参数：
+//
+//	ctx - 用于停止检查的上下文
+//	interval - 检查间隔，必须大于 0
+//
+// 返回：
+//
+//	<-chan error - 在目录变化时收到错误的通道
+func (r *Repository) WatchMount(ctx context.Context, interval time.Duration) <-chan error {
+	if r.shards == nil {
+		return r.storage.WatchSentinel(ctx, interval)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	out := make(chan error, 1)
+	var wg sync.WaitGroup
+	for _, s := range r.shards {
+		wg.Add(1)
+		go func(ch <-chan error) {
+			defer wg.Done()
+			if err, ok := <-ch; ok {
+				select {
+				case out <- err:
+				default:
+				}
+				cancel()
+			}
+		}(s.WatchSentinel(ctx, interval))
+	}
+	go func() {
+		wg.Wait()
+		cancel()
+		close(out)
+	}()
+	return out
+}
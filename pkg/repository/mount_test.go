@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tragoedia0722/repository/internal/storage"
+)
+
+func TestRepository_VerifyMount(t *testing.T) {
+	path := t.TempDir()
+	const ttl = 20 * time.Millisecond
+
+	repo, err := NewRepositoryWithOptions(path, RepoOptions{VerifyMount: true, VerifyMountTTL: ttl})
+	if err != nil {
+		t.Fatalf("NewRepositoryWithOptions failed: %v", err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+	c, err := repo.PutBlock(ctx, []byte("before"))
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+
+	// Simulate the mount going away
+	if err := os.Remove(filepath.Join(path, storage.SentinelFile)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(ttl)
+
+	if _, err := repo.PutBlock(ctx, []byte("after")); !errors.Is(err, ErrRepositoryMoved) {
+		t.Errorf("PutBlock after remount = %v, want ErrRepositoryMoved", err)
+	}
+	if _, err := repo.GetRawData(ctx, c.String()); !errors.Is(err, ErrRepositoryMoved) {
+		t.Errorf("GetRawData after remount = %v, want ErrRepositoryMoved", err)
+	}
+	if _, err := repo.HasBlock(ctx, c.String()); !errors.Is(err, ErrRepositoryMoved) {
+		t.Errorf("HasBlock after remount = %v, want ErrRepositoryMoved", err)
+	}
+	if err := repo.CheckMount(); !errors.Is(err, ErrRepositoryMoved) {
+		t.Errorf("CheckMount after remount = %v, want ErrRepositoryMoved", err)
+	}
+}
+
+func TestRepository_VerifyMount_ReplacedToken(t *testing.T) {
+	path := t.TempDir()
+	const ttl = 20 * time.Millisecond
+
+	repo, err := NewRepositoryWithOptions(path, RepoOptions{VerifyMount: true, VerifyMountTTL: ttl, RedactPaths: true})
+	if err != nil {
+		t.Fatalf("NewRepositoryWithOptions failed: %v", err)
+	}
+	defer repo.Close()
+
+	if err := os.WriteFile(filepath.Join(path, storage.SentinelFile), []byte("replaced"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(ttl)
+
+	_, err = repo.PutBlock(context.Background(), []byte("data"))
+	if !errors.Is(err, ErrRepositoryMoved) {
+		t.Fatalf("PutBlock after replacement = %v, want ErrRepositoryMoved", err)
+	}
+	abs, _ := filepath.Abs(path)
+	if msg := err.Error(); strings.Contains(msg, abs) {
+		t.Errorf("error leaks the repository path: %s", msg)
+	}
+}
+
+func TestRepository_VerifyMount_Disabled(t *testing.T) {
+	path := t.TempDir()
+
+	repo, err := NewRepository(path)
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	if err := os.Remove(filepath.Join(path, storage.SentinelFile)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.PutBlock(context.Background(), []byte("data")); err != nil {
+		t.Errorf("PutBlock without VerifyMount failed: %v", err)
+	}
+}
+
+func TestRepository_VerifyMount_NegativeTTL(t *testing.T) {
+	if _, err := NewRepositoryWithOptions(t.TempDir(), RepoOptions{VerifyMount: true, VerifyMountTTL: -time.Second}); err == nil {
+		t.Error("negative VerifyMountTTL was accepted")
+	}
+}
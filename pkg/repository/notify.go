@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	cid2 "github.com/ipfs/go-cid"
+)
+
+// blockHooks 保存数据块变更回调。
+type blockHooks struct {
+	mu      sync.RWMutex
+	next    int
+	put     map[int]func(cid2.Cid)
+	deleted map[int]func(cid2.Cid)
+	corrupt map[int]func(cid2.Cid)
+}
+
+// OnBlockPut 注册数据块写入成功后调用的回调。
+//
+// 所有经过 BlockStore() 的写入都会触发回调，包括 PutBlock、PutManyBlocks
+// 以及导入器。回调在写入的 goroutine 中同步调用，不应阻塞或再次写入仓库。
+//
+// 参数：
+//
+//	fn - 回调函数，参数为写入的数据块 CID
+//
+// 返回：
+//
+//	func() - 注销回调的函数，可以多次调用
+func (r *Repository) OnBlockPut(fn func(c cid2.Cid)) func() {
+	return r.hooks.add(&r.hooks.put, fn)
+}
+
+// OnBlockDeleted 注册数据块删除后调用的回调。
+//
+// 所有经过 BlockStore() 的删除都会触发回调，包括 DelBlock 和 DelBlockCid。
+// 删除不存在的数据块也可能触发回调。回调在删除的 goroutine 中同步调用，
+// 不应阻塞或再次写入仓库。
+//
+// 参数：
+//
+//	fn - 回调函数，参数为删除的数据块 CID
+//
+// 返回：
+//
+//	func() - 注销回调的函数，可以多次调用
+func (r *Repository) OnBlockDeleted(fn func(c cid2.Cid)) func() {
+	return r.hooks.add(&r.hooks.deleted, fn)
+}
+
+// add 把 fn 加入 set 并返回注销函数。
+func (h *blockHooks) add(set *map[int]func(cid2.Cid), fn func(cid2.Cid)) func() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if *set == nil {
+		*set = make(map[int]func(cid2.Cid))
+	}
+	id := h.next
+	h.next++
+	(*set)[id] = fn
+
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(*set, id)
+	}
+}
+
+// notify 对 cids 中的每个 CID 调用 set 中的回调。
+func (h *blockHooks) notify(set *map[int]func(cid2.Cid), cids ...cid2.Cid) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, fn := range *set {
+		for _, c := range cids {
+			fn(c)
+		}
+	}
+}
+
+// watchBlockstore 为 bs 加上 r 的变更通知。
+func (r *Repository) watchBlockstore(bs blockstore.Blockstore) blockstore.Blockstore {
+	return &notifyingBlockstore{Blockstore: bs, hooks: &r.hooks}
+}
+
+// notifyingBlockstore 在写入和删除成功后调用仓库的变更回调。
+type notifyingBlockstore struct {
+	blockstore.Blockstore
+	hooks *blockHooks
+}
+
+func (b *notifyingBlockstore) Put(ctx context.Context, blk blocks.Block) error {
+	if err := b.Blockstore.Put(ctx, blk); err != nil {
+		return err
+	}
+	b.hooks.notify(&b.hooks.put, blk.Cid())
+	return nil
+}
+
+func (b *notifyingBlockstore) PutMany(ctx context.Context, blks []blocks.Block) error {
+	if err := b.Blockstore.PutMany(ctx, blks); err != nil {
+		return err
+	}
+	cids := make([]cid2.Cid, len(blks))
+	for i, blk := range blks {
+		cids[i] = blk.Cid()
+	}
+	b.hooks.notify(&b.hooks.put, cids...)
+	return nil
+}
+
+func (b *notifyingBlockstore) DeleteBlock(ctx context.Context, c cid2.Cid) error {
+	if err := b.Blockstore.DeleteBlock(ctx, c); err != nil {
+		return err
+	}
+	b.hooks.notify(&b.hooks.deleted, c)
+	return nil
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	cid2 "github.com/ipfs/go-cid"
+)
+
+func TestRepository_BlockHooks(t *testing.T) {
+	ctx := context.Background()
+	repo, err := NewRepositoryWithOptions(t.TempDir(), RepoOptions{QuotaBytes: 1 << 20, RedactPaths: true})
+	if err != nil {
+		t.Fatalf("NewRepositoryWithOptions failed: %v", err)
+	}
+	defer repo.Close()
+
+	var put, deleted []cid2.Cid
+	removePut := repo.OnBlockPut(func(c cid2.Cid) { put = append(put, c) })
+	removeDeleted := repo.OnBlockDeleted(func(c cid2.Cid) { deleted = append(deleted, c) })
+
+	c, err := repo.PutBlock(ctx, []byte("hooked"))
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+	many, err := repo.PutManyBlocks(ctx, [][]byte{[]byte("one"), []byte("two")})
+	if err != nil {
+		t.Fatalf("PutManyBlocks failed: %v", err)
+	}
+	if err := repo.DelBlockCid(ctx, *c); err != nil {
+		t.Fatalf("DelBlockCid failed: %v", err)
+	}
+
+	want := []cid2.Cid{*c, *many[0], *many[1]}
+	if len(put) != len(want) {
+		t.Fatalf("put hooks = %v, want %v", put, want)
+	}
+	for i := range want {
+		if !put[i].Equals(want[i]) {
+			t.Errorf("put hook %d = %s, want %s", i, put[i], want[i])
+		}
+	}
+	if len(deleted) != 1 || !deleted[0].Equals(*c) {
+		t.Errorf("deleted hooks = %v, want [%s]", deleted, c)
+	}
+
+	// Removed hooks are no longer called; removing twice is harmless
+	removePut()
+	removePut()
+	removeDeleted()
+	if _, err := repo.PutBlock(ctx, []byte("unhooked")); err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+	if err := repo.DelBlockCid(ctx, *many[0]); err != nil {
+		t.Fatalf("DelBlockCid failed: %v", err)
+	}
+	if len(put) != 3 || len(deleted) != 1 {
+		t.Errorf("hooks called after removal: %d puts, %d deletes", len(put), len(deleted))
+	}
+}
+
+func TestRepository_BlockHooks_FailedWrite(t *testing.T) {
+	repo, err := NewRepositoryWithOptions(t.TempDir(), RepoOptions{QuotaBytes: 10})
+	if err != nil {
+		t.Fatalf("NewRepositoryWithOptions failed: %v", err)
+	}
+	defer repo.Close()
+
+	calls := 0
+	repo.OnBlockPut(func(cid2.Cid) { calls++ })
+	if _, err := repo.PutBlock(context.Background(), make([]byte, 64)); err == nil {
+		t.Fatal("PutBlock over quota succeeded")
+	}
+	if calls != 0 {
+		t.Errorf("put hook called %d times for a rejected write", calls)
+	}
+}
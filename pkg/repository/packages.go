@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	cid2 "github.com/ipfs/go-cid"
+	"github.com/tragoedia0722/repository/pkg/packaging"
+)
+
+// PackagingOptions 配置 BuildPackages 的打包方式。
+type PackagingOptions struct {
+	// BlocksPerPackage 是每个包的最大块数。0 表示使用导入时的默认值（100）。
+	BlocksPerPackage int
+
+	// BytesPerPackage 是每个包中块数据的最大字节数。0 表示不限制。
+	// 与导入时 WithPackageLimit 的参数相同时，结果与导入一致。
+	BytesPerPackage int64
+}
+
+// BuildPackages 根据 blockstore 中已有的块重新生成根 CID 的包列表。
+//
+// 遍历根 CID 可达的所有块，按与导入相同的方式排序、分组并计算包哈希，
+// 并用遍历得到的块大小填充 Bytes，因此在默认选项下结果与原始导入的
+// Result.Packages 完全一致（packaging.Package 与 importer.Package 是同一类型）。
+//
+// 如果遍历过程中有块缺失，不会返回不完整的包，而是返回
+// *packaging.MissingBlocksError，其中列出所有缺失的块。
+//
+// 参数：
+//
+//	ctx - 用于取消操作的上下文
+//	bs - 包含 DAG 的 blockstore
+//	rootCid - 根 CID 字符串
+//	opts - 打包选项
+//
+// 返回：
+//
+//	[]packaging.Package - 包列表
+//	error - 如果 CID 无效、块缺失或遍历失败，返回错误
+func BuildPackages(ctx context.Context, bs blockstore.Blockstore, rootCid string, opts PackagingOptions) ([]packaging.Package, error) {
+	root, err := cid2.Parse(rootCid)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CID %q: %w", rootCid, err)
+	}
+
+	dag := merkledag.NewDAGService(blockservice.New(bs, nil))
+
+	blocks, size, err := packaging.CollectSizedBlocks(ctx, dag, root, bs.GetSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return packaging.SplitSized(ctx, blocks, size, packaging.Limits{
+		MaxBlocks: opts.BlocksPerPackage,
+		MaxBytes:  opts.BytesPerPackage,
+	})
+}
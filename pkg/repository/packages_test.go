@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tragoedia0722/repository/pkg/importer"
+	"github.com/tragoedia0722/repository/pkg/packaging"
+)
+
+// importPackagesFixture imports enough small files to produce several packages.
+func importPackagesFixture(t *testing.T, repo *Repository) *importer.Result {
+	t.Helper()
+
+	src := t.TempDir()
+	for i := 0; i < 150; i++ {
+		dir := filepath.Join(src, fmt.Sprintf("d%d", i%5))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("f%03d.txt", i)), []byte(fmt.Sprintf("content %d", i)), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	result, err := importer.NewImporter(repo.BlockStore(), src).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	return result
+}
+
+func TestBuildPackages_MatchesImport(t *testing.T) {
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	result := importPackagesFixture(t, repo)
+	if len(result.Packages) < 2 {
+		t.Fatalf("fixture should produce several packages, got %d", len(result.Packages))
+	}
+
+	rebuilt, err := BuildPackages(context.Background(), repo.BlockStore(), result.RootCid, PackagingOptions{})
+	if err != nil {
+		t.Fatalf("BuildPackages failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(rebuilt, result.Packages) {
+		t.Errorf("rebuilt packages differ from import result:\nrebuilt: %+v\nimport:  %+v", rebuilt, result.Packages)
+	}
+}
+
+func TestBuildPackages_BlocksPerPackage(t *testing.T) {
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	result := importPackagesFixture(t, repo)
+
+	rebuilt, err := BuildPackages(context.Background(), repo.BlockStore(), result.RootCid, PackagingOptions{BlocksPerPackage: 10})
+	if err != nil {
+		t.Fatalf("BuildPackages failed: %v", err)
+	}
+
+	total := 0
+	for i, pkg := range rebuilt {
+		if len(pkg.Blocks) > 10 {
+			t.Errorf("package %d has %d blocks", i, len(pkg.Blocks))
+		}
+		if pkg.Hash != packaging.Calc(pkg.Blocks).Hash {
+			t.Errorf("package %d has wrong hash", i)
+		}
+		total += len(pkg.Blocks)
+	}
+
+	want := 0
+	for _, pkg := range result.Packages {
+		want += len(pkg.Blocks)
+	}
+	if total != want {
+		t.Errorf("rebuilt %d blocks, import had %d", total, want)
+	}
+}
+
+func TestBuildPackages_BytesPerPackage(t *testing.T) {
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	result := importPackagesFixture(t, repo)
+
+	const limit = 500
+	rebuilt, err := BuildPackages(context.Background(), repo.BlockStore(), result.RootCid, PackagingOptions{BytesPerPackage: limit})
+	if err != nil {
+		t.Fatalf("BuildPackages failed: %v", err)
+	}
+
+	var total int64
+	for i, pkg := range rebuilt {
+		if pkg.Bytes > limit && len(pkg.Blocks) > 1 {
+			t.Errorf("package %d has %d blocks of %d bytes", i, len(pkg.Blocks), pkg.Bytes)
+		}
+		total += pkg.Bytes
+	}
+
+	var want int64
+	for _, pkg := range result.Packages {
+		want += pkg.Bytes
+	}
+	if len(rebuilt) <= len(result.Packages) || total != want {
+		t.Errorf("rebuilt %d packages of %d bytes, import had %d of %d", len(rebuilt), total, len(result.Packages), want)
+	}
+}
+
+func TestBuildPackages_MissingBlocks(t *testing.T) {
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	result := importPackagesFixture(t, repo)
+
+	// Delete two raw leaf blocks so neither hides the other from the walk
+	var victims []string
+	for _, blk := range result.Packages[0].Blocks {
+		if strings.HasPrefix(blk, "bafk") && len(victims) < 2 {
+			victims = append(victims, blk)
+		}
+	}
+	for _, v := range victims {
+		if err := repo.DelBlock(context.Background(), v); err != nil {
+			t.Fatalf("DelBlock failed: %v", err)
+		}
+	}
+
+	_, err = BuildPackages(context.Background(), repo.BlockStore(), result.RootCid, PackagingOptions{})
+
+	var missingErr *packaging.MissingBlocksError
+	if !errors.As(err, &missingErr) {
+		t.Fatalf("expected MissingBlocksError, got %v", err)
+	}
+	if missingErr.Root != result.RootCid {
+		t.Errorf("Root = %s, want %s", missingErr.Root, result.RootCid)
+	}
+	for _, v := range victims {
+		found := false
+		for _, m := range missingErr.Missing {
+			if m == v {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("missing block %s not reported in %v", v, missingErr.Missing)
+		}
+	}
+}
+
+func TestBuildPackages_InvalidCID(t *testing.T) {
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	if _, err := BuildPackages(context.Background(), repo.BlockStore(), "not-a-cid", PackagingOptions{}); err == nil {
+		t.Error("expected error for invalid CID")
+	}
+}
@@ -381,9 +381,9 @@ func TestStress_MaxBlockSize(t *testing.T) {
 		start := time.Now()
 
 		for i := 0; i < numBlocks; i++ {
-			data := make([]byte, maxBlockSize)
+			data := make([]byte, DefaultMaxBlockSize)
 			data[0] = byte(i)
-			data[maxBlockSize-1] = byte(i >> 8)
+			data[DefaultMaxBlockSize-1] = byte(i >> 8)
 
 			cid, err := repo.PutBlock(ctx, data)
 			if err != nil {
@@ -396,13 +396,13 @@ func TestStress_MaxBlockSize(t *testing.T) {
 				t.Fatalf("GetRawData failed: %v", err)
 			}
 
-			if len(retrieved) != maxBlockSize {
-				t.Errorf("Size mismatch: got %d, want %d", len(retrieved), maxBlockSize)
+			if len(retrieved) != DefaultMaxBlockSize {
+				t.Errorf("Size mismatch: got %d, want %d", len(retrieved), DefaultMaxBlockSize)
 			}
 		}
 
 		duration := time.Since(start)
-		totalMb := (float64(numBlocks*maxBlockSize) / (1024 * 1024))
+		totalMb := (float64(numBlocks*DefaultMaxBlockSize) / (1024 * 1024))
 		mbPerSec := totalMb / duration.Seconds()
 
 		t.Logf("Stored %d max-size blocks (%.2f MB) in %v (%.2f MB/sec)",
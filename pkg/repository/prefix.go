@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	cid2 "github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multicodec"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// ErrUnsupportedCidPrefix 表示 CID 前缀的版本、编码或哈希函数不受支持。
+var ErrUnsupportedCidPrefix = errors.New("unsupported CID prefix")
+
+// defaultBuilder 是未设置 CidPrefix 时 PutBlock 使用的 CIDv1/dag-pb/sha2-256 构造器。
+var defaultBuilder = cid2.V1Builder{
+	Codec:    uint64(multicodec.DagPb),
+	MhType:   mh.SHA2_256,
+	MhLength: -1,
+}
+
+// cidBuilder 返回配置的 CID 构造器，未设置 CidPrefix 时返回 defaultBuilder。
+func (opts RepoOptions) cidBuilder() cid2.Builder {
+	if opts.CidPrefix == (cid2.Prefix{}) {
+		return defaultBuilder
+	}
+	return opts.CidPrefix
+}
+
+// validatePrefix 检查 p 能否用于计算数据块的 CID。
+//
+// 哈希函数必须已在 go-multihash 中注册，identity 哈希会把整个块写进 CID，
+// 同样被拒绝。CIDv0 只支持 dag-pb 编码和完整长度的 sha2-256。
+func validatePrefix(p cid2.Prefix) error {
+	hash := multicodec.Code(p.MhType)
+	if p.MhType == mh.IDENTITY {
+		return fmt.Errorf("%w: identity hash cannot address stored blocks", ErrUnsupportedCidPrefix)
+	}
+	if _, err := mh.GetHasher(p.MhType); err != nil {
+		return fmt.Errorf("%w: hash function %s (0x%x) is not supported", ErrUnsupportedCidPrefix, hash, p.MhType)
+	}
+	if p.MhLength == 0 || p.MhLength < -1 {
+		return fmt.Errorf("%w: invalid hash length %d", ErrUnsupportedCidPrefix, p.MhLength)
+	}
+
+	switch p.Version {
+	case 0:
+		if p.Codec != cid2.DagProtobuf || p.MhType != mh.SHA2_256 || (p.MhLength != -1 && p.MhLength != 32) {
+			return fmt.Errorf("%w: CIDv0 requires dag-pb and sha2-256, got %s and %s",
+				ErrUnsupportedCidPrefix, multicodec.Code(p.Codec), hash)
+		}
+	case 1:
+	default:
+		return fmt.Errorf("%w: CID version %d", ErrUnsupportedCidPrefix, p.Version)
+	}
+	return nil
+}
+
+// PutBlockWithPrefix 按指定的 CID 前缀存储单个数据块并返回其 CID。
+//
+// 用于镜像其他系统的数据块，使本地 CID 与之相同，例如 sha2-512：
+//
+//	prefix := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: mh.SHA2_512, MhLength: -1}
+//	c, err := repo.PutBlockWithPrefix(ctx, data, prefix)
+//
+// 参数：
+//
+//	ctx - 用于取消操作的上下文
+//	bytes - 要存储的数据
+//	prefix - CID 前缀，MhLength 为 -1 表示哈希函数的默认长度
+//
+// 返回：
+//
+//	cid2.Cid - 数据块的 CID
+//	error - 前缀不受支持时返回包装 ErrUnsupportedCidPrefix 的错误；存储失败时返回错误
+func (r *Repository) PutBlockWithPrefix(ctx context.Context, bytes []byte, prefix cid2.Prefix) (cid2.Cid, error) {
+	if err := validatePrefix(prefix); err != nil {
+		return cid2.Undef, err
+	}
+	c, err := r.putBlock(ctx, bytes, prefix)
+	if err != nil {
+		return cid2.Undef, err
+	}
+	return *c, nil
+}
+
+// PutManyBlocksWithPrefix 按指定的 CID 前缀批量存储数据块并返回对应的 CID 列表。
+//
+// 同一批中的所有块使用相同的版本、编码和哈希函数。前缀不受支持时不写入任何块。
+//
+// 参数：
+//
+//	ctx - 用于取消操作的上下文
+//	bytes - 数据块列表
+//	prefix - CID 前缀，见 PutBlockWithPrefix
+//
+// 返回：
+//
+//	[]cid2.Cid - CID 列表，顺序与 bytes 相同
+//	error - 前缀不受支持时返回包装 ErrUnsupportedCidPrefix 的错误；存储失败时返回错误
+func (r *Repository) PutManyBlocksWithPrefix(ctx context.Context, bytes [][]byte, prefix cid2.Prefix) ([]cid2.Cid, error) {
+	if err := validatePrefix(prefix); err != nil {
+		return nil, err
+	}
+	ptrs, err := r.putManyBlocks(ctx, bytes, prefix)
+	if err != nil || ptrs == nil {
+		return nil, err
+	}
+	cids := make([]cid2.Cid, len(ptrs))
+	for i, c := range ptrs {
+		cids[i] = *c
+	}
+	return cids, nil
+}
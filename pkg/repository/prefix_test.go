@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	cid2 "github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+var sha512Prefix = cid2.Prefix{Version: 1, Codec: cid2.Raw, MhType: mh.SHA2_512, MhLength: -1}
+
+func TestRepository_PutBlockWithPrefix(t *testing.T) {
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+	data := []byte("mirrored block")
+	want, err := sha512Prefix.Sum(data)
+	if err != nil {
+		t.Fatalf("Sum failed: %v", err)
+	}
+
+	c, err := repo.PutBlockWithPrefix(ctx, data, sha512Prefix)
+	if err != nil {
+		t.Fatalf("PutBlockWithPrefix failed: %v", err)
+	}
+	if !c.Equals(want) {
+		t.Errorf("PutBlockWithPrefix() = %s, want %s", c, want)
+	}
+	got, err := repo.GetRawDataCid(ctx, c)
+	if err != nil || string(got) != string(data) {
+		t.Errorf("GetRawDataCid() = %q, %v, want %q", got, err, data)
+	}
+
+	// The repository default is unchanged
+	def, err := repo.PutBlock(ctx, data)
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+	if p := def.Prefix(); p.MhType != mh.SHA2_256 || p.Version != 1 {
+		t.Errorf("PutBlock prefix = %+v, want CIDv1/sha2-256", p)
+	}
+}
+
+func TestRepository_PutManyBlocksWithPrefix(t *testing.T) {
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+	data := [][]byte{[]byte("one"), []byte("two"), []byte("three")}
+	cids, err := repo.PutManyBlocksWithPrefix(ctx, data, sha512Prefix)
+	if err != nil {
+		t.Fatalf("PutManyBlocksWithPrefix failed: %v", err)
+	}
+	if len(cids) != len(data) {
+		t.Fatalf("got %d CIDs, want %d", len(cids), len(data))
+	}
+	for i, c := range cids {
+		if p := c.Prefix(); p.Codec != cid2.Raw || p.MhType != mh.SHA2_512 {
+			t.Errorf("CID %d prefix = %+v, want sha2-512", i, c.Prefix())
+		}
+		if has, err := repo.HasBlockCid(ctx, c); err != nil || !has {
+			t.Errorf("HasBlockCid(%s) = %v, %v", c, has, err)
+		}
+	}
+}
+
+func TestRepository_CidPrefixOption(t *testing.T) {
+	repo, err := NewRepositoryWithOptions(t.TempDir(), RepoOptions{CidPrefix: sha512Prefix})
+	if err != nil {
+		t.Fatalf("NewRepositoryWithOptions failed: %v", err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+	c, err := repo.PutBlock(ctx, []byte("default prefix"))
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+	if c.Prefix().MhType != mh.SHA2_512 {
+		t.Errorf("PutBlock hash = %x, want sha2-512", c.Prefix().MhType)
+	}
+
+	cids, err := repo.PutManyBlocks(ctx, [][]byte{[]byte("a"), []byte("b")})
+	if err != nil {
+		t.Fatalf("PutManyBlocks failed: %v", err)
+	}
+	for _, c := range cids {
+		if c.Prefix().MhType != mh.SHA2_512 {
+			t.Errorf("PutManyBlocks hash = %x, want sha2-512", c.Prefix().MhType)
+		}
+	}
+}
+
+func TestRepository_CidPrefix_Unsupported(t *testing.T) {
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	invalid := []cid2.Prefix{
+		{Version: 1, Codec: cid2.Raw, MhType: 0x9999, MhLength: -1},
+		{Version: 1, Codec: cid2.Raw, MhType: mh.IDENTITY, MhLength: -1},
+		{Version: 1, Codec: cid2.Raw, MhType: mh.SHA2_256, MhLength: 0},
+		{Version: 0, Codec: cid2.DagProtobuf, MhType: mh.SHA2_512, MhLength: -1},
+		{Version: 0, Codec: cid2.Raw, MhType: mh.SHA2_256, MhLength: -1},
+		{Version: 2, Codec: cid2.Raw, MhType: mh.SHA2_256, MhLength: -1},
+	}
+	ctx := context.Background()
+	for _, p := range invalid {
+		if _, err := repo.PutBlockWithPrefix(ctx, []byte("x"), p); !errors.Is(err, ErrUnsupportedCidPrefix) {
+			t.Errorf("PutBlockWithPrefix(%+v) error = %v, want ErrUnsupportedCidPrefix", p, err)
+		}
+		if _, err := repo.PutManyBlocksWithPrefix(ctx, [][]byte{[]byte("x")}, p); !errors.Is(err, ErrUnsupportedCidPrefix) {
+			t.Errorf("PutManyBlocksWithPrefix(%+v) error = %v, want ErrUnsupportedCidPrefix", p, err)
+		}
+		if _, err := NewRepositoryWithOptions(t.TempDir(), RepoOptions{CidPrefix: p}); !errors.Is(err, ErrUnsupportedCidPrefix) {
+			t.Errorf("NewRepositoryWithOptions(%+v) error = %v, want ErrUnsupportedCidPrefix", p, err)
+		}
+	}
+}
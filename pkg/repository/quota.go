@@ -0,0 +1,288 @@
+package repository
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	cid2 "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// quotaUsageKey 是本地 datastore 中已用配额计数的键。
+var quotaUsageKey = ds.NewKey("/quota/usage")
+
+// ErrQuotaExceeded 表示写入会超过仓库的配额硬限制。
+// 具体的用量和限制通过 *QuotaExceededError 获取。
+var ErrQuotaExceeded = errors.New("repository quota exceeded")
+
+// QuotaExceededError 描述一次因超过配额而被拒绝的写入。
+type QuotaExceededError struct {
+	Usage     int64 // 写入前的已用字节数
+	Limit     int64 // 配额硬限制
+	Requested int64 // 本次写入新增的字节数
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%v: usage %d + %d bytes exceeds limit %d bytes", ErrQuotaExceeded, e.Usage, e.Requested, e.Limit)
+}
+
+func (e *QuotaExceededError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// WithQuotaWarning 设置用量达到软限制时的回调。
+//
+// 回调在用量首次达到软限制时调用一次；用量因删除降到软限制以下后，
+// 再次达到时会重新调用。未启用软限制时回调不会被调用。
+//
+// 参数：
+//
+//	fn - 回调函数，参数为当前用量和硬限制
+//
+// 返回：
+//
+//	*Repository - 仓库实例，便于链式调用
+func (r *Repository) WithQuotaWarning(fn func(usage, limit int64)) *Repository {
+	if r.quota != nil {
+		r.quota.mu.Lock()
+		r.quota.warn = fn
+		r.quota.mu.Unlock()
+	}
+	return r
+}
+
+// QuotaUsage 返回配额计数的已用字节数。未启用配额时返回 0。
+// 仓库关闭后返回 ErrClosed。
+func (r *Repository) QuotaUsage() (int64, error) {
+	if err := r.life.enter(); err != nil {
+		return 0, err
+	}
+	defer r.life.leave()
+	if r.quota == nil {
+		return 0, nil
+	}
+	r.quota.mu.Lock()
+	defer r.quota.mu.Unlock()
+	return r.quota.used, nil
+}
+
+// ReconcileQuota 重新统计仓库中所有数据块的大小并更新配额计数。
+//
+// 用于修正绕过仓库直接修改 datastore 导致的计数偏差。未启用配额时不做任何操作。
+func (r *Repository) ReconcileQuota(ctx context.Context) error {
+	if err := r.life.enter(); err != nil {
+		return err
+	}
+	defer r.life.leave()
+
+	if r.quota == nil {
+		return nil
+	}
+	r.quota.mu.Lock()
+	defer r.quota.mu.Unlock()
+	return r.quota.recount(ctx)
+}
+
+// quotaBlockstore 在 blockstore 之上实现配额检查和用量计数。
+type quotaBlockstore struct {
+	blockstore.Blockstore
+
+	meta  ds.Datastore
+	limit int64
+	soft  int64 // 0 表示不启用软限制
+
+	mu     sync.Mutex // 串行化写入，保证检查和计数一致
+	used   int64
+	warned bool
+	warn   func(usage, limit int64)
+}
+
+// load 从 datastore 读取已用字节数，不存在时统计现有数据块。
+func (q *quotaBlockstore) load(ctx context.Context) error {
+	data, err := q.meta.Get(ctx, quotaUsageKey)
+	if errors.Is(err, ds.ErrNotFound) {
+		return q.recount(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	if len(data) != 8 {
+		return q.recount(ctx)
+	}
+
+	q.used = int64(binary.BigEndian.Uint64(data))
+	q.warned = q.soft > 0 && q.used >= q.soft
+	return nil
+}
+
+// recount 统计所有数据块的大小并保存。调用者必须持有 q.mu。
+func (q *quotaBlockstore) recount(ctx context.Context) error {
+	keys, err := q.Blockstore.AllKeysChan(ctx)
+	if err != nil {
+		return err
+	}
+
+	var used int64
+	for c := range keys {
+		size, err := q.Blockstore.GetSize(ctx, c)
+		if ipld.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		used += int64(size)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	q.used = used
+	q.warned = q.soft > 0 && q.used >= q.soft
+	return q.persist(ctx)
+}
+
+// persist 保存已用字节数。调用者必须持有 q.mu。
+func (q *quotaBlockstore) persist(ctx context.Context) error {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(q.used))
+	return q.meta.Put(ctx, quotaUsageKey, buf[:])
+}
+
+// add 更新已用字节数并保存，返回需要触发的软限制回调。调用者必须持有 q.mu。
+func (q *quotaBlockstore) add(ctx context.Context, delta int64) (func(), error) {
+	if delta == 0 {
+		return nil, nil
+	}
+
+	q.used += delta
+	if err := q.persist(ctx); err != nil {
+		return nil, fmt.Errorf("failed to persist quota usage: %w", err)
+	}
+
+	if q.soft <= 0 {
+		return nil, nil
+	}
+	if q.used < q.soft {
+		q.warned = false
+		return nil, nil
+	}
+	if q.warned || q.warn == nil {
+		return nil, nil
+	}
+
+	q.warned = true
+	warn, usage, limit := q.warn, q.used, q.limit
+	return func() { warn(usage, limit) }, nil
+}
+
+// reserve 检查写入 size 字节后是否超过硬限制。调用者必须持有 q.mu。
+func (q *quotaBlockstore) reserve(size int64) error {
+	if q.used+size > q.limit {
+		return &QuotaExceededError{Usage: q.used, Limit: q.limit, Requested: size}
+	}
+	return nil
+}
+
+// Put 存储数据块，已存在的块不计入用量。
+func (q *quotaBlockstore) Put(ctx context.Context, blk blocks.Block) error {
+	notify, err := q.put(ctx, blk)
+	if notify != nil {
+		notify()
+	}
+	return err
+}
+
+func (q *quotaBlockstore) put(ctx context.Context, blk blocks.Block) (func(), error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	has, err := q.Blockstore.Has(ctx, blk.Cid())
+	if err != nil {
+		return nil, err
+	}
+	if has {
+		return nil, nil
+	}
+
+	size := int64(len(blk.RawData()))
+	if err := q.reserve(size); err != nil {
+		return nil, err
+	}
+	if err := q.Blockstore.Put(ctx, blk); err != nil {
+		return nil, err
+	}
+	return q.add(ctx, size)
+}
+
+// PutMany 批量存储数据块。超过配额时整批拒绝，不写入任何块。
+func (q *quotaBlockstore) PutMany(ctx context.Context, blks []blocks.Block) error {
+	notify, err := q.putMany(ctx, blks)
+	if notify != nil {
+		notify()
+	}
+	return err
+}
+
+func (q *quotaBlockstore) putMany(ctx context.Context, blks []blocks.Block) (func(), error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var size int64
+	seen := make(map[cid2.Cid]struct{}, len(blks))
+	for _, blk := range blks {
+		if _, ok := seen[blk.Cid()]; ok {
+			continue
+		}
+		seen[blk.Cid()] = struct{}{}
+
+		has, err := q.Blockstore.Has(ctx, blk.Cid())
+		if err != nil {
+			return nil, err
+		}
+		if !has {
+			size += int64(len(blk.RawData()))
+		}
+	}
+
+	if err := q.reserve(size); err != nil {
+		return nil, err
+	}
+	if err := q.Blockstore.PutMany(ctx, blks); err != nil {
+		return nil, err
+	}
+	return q.add(ctx, size)
+}
+
+// DeleteBlock 删除数据块并减少用量。
+func (q *quotaBlockstore) DeleteBlock(ctx context.Context, c cid2.Cid) error {
+	notify, err := q.deleteBlock(ctx, c)
+	if notify != nil {
+		notify()
+	}
+	return err
+}
+
+func (q *quotaBlockstore) deleteBlock(ctx context.Context, c cid2.Cid) (func(), error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	size, err := q.Blockstore.GetSize(ctx, c)
+	if ipld.IsNotFound(err) {
+		return nil, q.Blockstore.DeleteBlock(ctx, c)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := q.Blockstore.DeleteBlock(ctx, c); err != nil {
+		return nil, err
+	}
+	return q.add(ctx, -int64(size))
+}
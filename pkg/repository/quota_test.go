@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+// quotaBlock returns a 30-byte block payload distinguished by i.
+func quotaBlock(i int) []byte {
+	data := bytes.Repeat([]byte{'q'}, 30)
+	data[0] = byte(i)
+	return data
+}
+
+func TestRepository_Quota(t *testing.T) {
+	ctx := context.Background()
+	path := t.TempDir()
+	opts := RepoOptions{QuotaBytes: 100, QuotaSoftPct: 50}
+
+	repo, err := NewRepositoryWithOptions(path, opts)
+	if err != nil {
+		t.Fatalf("NewRepositoryWithOptions failed: %v", err)
+	}
+
+	var warnings []int64
+	repo.WithQuotaWarning(func(usage, limit int64) {
+		if limit != 100 {
+			t.Errorf("expected limit 100, got %d", limit)
+		}
+		warnings = append(warnings, usage)
+	})
+
+	// Three blocks fit (90 bytes), the fourth would cross the limit
+	var cids []string
+	for i := 0; i < 3; i++ {
+		c, err := repo.PutBlock(ctx, quotaBlock(i))
+		if err != nil {
+			t.Fatalf("PutBlock %d failed: %v", i, err)
+		}
+		cids = append(cids, c.String())
+	}
+
+	_, err = repo.PutBlock(ctx, quotaBlock(3))
+	var quotaErr *QuotaExceededError
+	if !errors.As(err, &quotaErr) {
+		t.Fatalf("expected QuotaExceededError, got %v", err)
+	}
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Error("expected error to match ErrQuotaExceeded")
+	}
+	if quotaErr.Usage != 90 || quotaErr.Limit != 100 || quotaErr.Requested != 30 {
+		t.Errorf("unexpected error details: %+v", quotaErr)
+	}
+
+	// Re-putting an existing block does not count against the quota
+	if _, err := repo.PutBlock(ctx, quotaBlock(0)); err != nil {
+		t.Errorf("re-putting an existing block failed: %v", err)
+	}
+
+	if len(warnings) != 1 || warnings[0] != 60 {
+		t.Errorf("expected one warning at 60 bytes, got %v", warnings)
+	}
+
+	// Deleting a block frees space for new writes
+	if err := repo.DelBlock(ctx, cids[0]); err != nil {
+		t.Fatalf("DelBlock failed: %v", err)
+	}
+	if got, err := repo.QuotaUsage(); err != nil || got != 60 {
+		t.Errorf("expected usage 60 after delete, got %d (%v)", got, err)
+	}
+	if _, err := repo.PutBlock(ctx, quotaBlock(3)); err != nil {
+		t.Fatalf("PutBlock after delete failed: %v", err)
+	}
+
+	if err := repo.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// The counter survives reopening
+	repo, err = NewRepositoryWithOptions(path, opts)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer repo.Close()
+
+	if got, err := repo.QuotaUsage(); err != nil || got != 90 {
+		t.Errorf("expected usage 90 after reopen, got %d (%v)", got, err)
+	}
+	if _, err := repo.PutBlock(ctx, quotaBlock(4)); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected ErrQuotaExceeded after reopen, got %v", err)
+	}
+}
+
+func TestRepository_Quota_PutMany(t *testing.T) {
+	ctx := context.Background()
+
+	repo, err := NewRepositoryWithOptions(t.TempDir(), RepoOptions{QuotaBytes: 100})
+	if err != nil {
+		t.Fatalf("NewRepositoryWithOptions failed: %v", err)
+	}
+	defer repo.Close()
+
+	batch := [][]byte{quotaBlock(0), quotaBlock(1), quotaBlock(2), quotaBlock(3)}
+	if _, err := repo.PutManyBlocks(ctx, batch); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected ErrQuotaExceeded, got %v", err)
+	}
+	if got, err := repo.QuotaUsage(); err != nil || got != 0 {
+		t.Errorf("expected rejected batch to store nothing, usage %d (%v)", got, err)
+	}
+
+	// Duplicates within a batch are counted once
+	if _, err := repo.PutManyBlocks(ctx, [][]byte{quotaBlock(0), quotaBlock(0), quotaBlock(1)}); err != nil {
+		t.Fatalf("PutManyBlocks failed: %v", err)
+	}
+	if got, err := repo.QuotaUsage(); err != nil || got != 60 {
+		t.Errorf("expected usage 60, got %d (%v)", got, err)
+	}
+}
+
+func TestRepository_Quota_InitialRecount(t *testing.T) {
+	ctx := context.Background()
+	path := t.TempDir()
+
+	repo, err := NewRepository(path)
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := repo.PutBlock(ctx, quotaBlock(i)); err != nil {
+			t.Fatalf("PutBlock failed: %v", err)
+		}
+	}
+	if err := repo.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	repo, err = NewRepositoryWithOptions(path, RepoOptions{QuotaBytes: 1000})
+	if err != nil {
+		t.Fatalf("NewRepositoryWithOptions failed: %v", err)
+	}
+	defer repo.Close()
+
+	if got, err := repo.QuotaUsage(); err != nil || got != 60 {
+		t.Errorf("expected existing blocks to be counted, got %d (%v)", got, err)
+	}
+}
+
+func TestNewRepositoryWithOptions_Invalid(t *testing.T) {
+	if _, err := NewRepositoryWithOptions(t.TempDir(), RepoOptions{QuotaBytes: -1}); err == nil {
+		t.Error("expected error for negative quota")
+	}
+	if _, err := NewRepositoryWithOptions(t.TempDir(), RepoOptions{QuotaBytes: 100, QuotaSoftPct: 150}); err == nil {
+		t.Error("expected error for soft limit above 100 percent")
+	}
+}
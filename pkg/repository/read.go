@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/datastore/dshelp"
+	cid2 "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	metrics "github.com/ipfs/go-metrics-interface"
+	"golang.org/x/sync/singleflight"
+)
+
+// readGroup 合并对同一个块的并发读取。
+//
+// 同一 CID 的并发调用共享一次 datastore 读取（包括重试），
+// 每个等待者得到自己的数据副本。
+type readGroup struct {
+	group     singleflight.Group
+	coalesced atomic.Uint64   // 合并到其他调用上的读取次数
+	counter   metrics.Counter // 同一计数的度量指标
+}
+
+func newReadGroup() *readGroup {
+	return &readGroup{
+		counter: metrics.New("ipfs.repository.get_coalesced_total",
+			"Number of block reads served by a concurrent read of the same block").Counter(),
+	}
+}
+
+// do 执行或加入 c 的读取，coalesced 表示结果来自其他调用者的读取。
+//
+// 读取在与调用者取消信号分离的上下文中执行，一个调用者取消不会让
+// 其他等待者失败；被取消的调用者立即返回 ctx.Err()。
+func (g *readGroup) do(ctx context.Context, c cid2.Cid, read func(ctx context.Context) ([]byte, error)) (data []byte, coalesced bool, err error) {
+	leader := false
+	ch := g.group.DoChan(c.KeyString(), func() (interface{}, error) {
+		leader = true
+		return read(context.WithoutCancel(ctx))
+	})
+
+	select {
+	case res := <-ch:
+		if !leader {
+			g.coalesced.Add(1)
+			g.counter.Inc()
+		}
+		if res.Err != nil {
+			return nil, !leader, res.Err
+		}
+		data := res.Val.([]byte)
+		if res.Shared && !leader {
+			data = bytes.Clone(data)
+		}
+		return data, !leader, nil
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
+
+// CoalescedReads 返回合并到同一块的并发读取上、未单独访问 datastore 的
+// GetRawData 调用次数。同一计数也通过度量指标
+// ipfs.repository.get_coalesced_total 报告。
+//
+// 返回：
+//
+//	uint64 - 自仓库打开以来合并的读取次数
+func (r *Repository) CoalescedReads() uint64 {
+	return r.reads.coalesced.Load()
+}
+
+// GetBlockReader 返回指定 CID 的块数据的读取器及其长度，供以 io.Reader
+// 处理块数据的调用者使用。
+//
+// 未启用 VerifyReads 时，本地存储中的块直接从 datastore 流式读取（FlatFS
+// 中的块打开其文件），不会整体读入内存；读取报告给 MetricsSink 和访问统计，
+// 但不经过追踪和并发读取合并。读取校验本身需要完整的块才能计算哈希，
+// 因此启用 VerifyReads 时，以及本地不存在的块（包括副本仓库需要从主仓库
+// 读取的块）经由 GetRawData 读取，读取器建立在内存中的块数据之上，CID 校验
+// 和块不存在时的重试与 GetRawData 相同。副本仓库总是经由 GetRawData 读取，
+// 以便更新缓存的访问顺序。
+//
+// 读取器的 Close 总是返回 nil，可以多次调用。
+//
+// 参数：
+//
+//	ctx - 用于取消操作的上下文
+//	cid - CID 字符串
+//
+// 返回：
+//
+//	io.ReadCloser - 块数据的读取器，使用后必须关闭
+//	int64 - 块的长度（字节）
+//	error - 如果获取失败，返回错误
+func (r *Repository) GetBlockReader(ctx context.Context, cid string) (io.ReadCloser, int64, error) {
+	c, err := r.parseCID(cid)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if r.verify == nil && r.replica == nil {
+		rc, size, err := r.openBlock(ctx, c)
+		if err == nil {
+			return &blockReader{ReadCloser: rc}, size, nil
+		}
+		if !errors.Is(err, ds.ErrNotFound) {
+			return nil, 0, err
+		}
+	}
+
+	data, err := r.GetRawDataCid(ctx, c)
+	if err != nil {
+		return nil, 0, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), int64(len(data)), nil
+}
+
+// openBlock 打开块 c 所在存储中的块数据，块不存在时返回 ds.ErrNotFound。
+// 设置了 MetricsSink 时报告这次读取。
+func (r *Repository) openBlock(ctx context.Context, c cid2.Cid) (rc io.ReadCloser, size int64, err error) {
+	if err := r.life.enter(); err != nil {
+		return nil, 0, err
+	}
+	defer r.life.leave()
+	if r.metrics != nil {
+		start := time.Now()
+		defer func() {
+			if !errors.Is(err, ds.ErrNotFound) {
+				r.metrics.ObserveGet(int(size), time.Since(start), err)
+			}
+		}()
+	}
+
+	s := r.storage
+	if r.shards != nil {
+		s = r.shards[shardIndex(c, len(r.shards))]
+	}
+	rc, size, err = s.OpenReader(ctx, blockstore.BlockPrefix.Child(dshelp.MultihashToDsKey(c.Hash())))
+	if err != nil {
+		return nil, 0, s.RedactError(err)
+	}
+	if r.access != nil {
+		r.access.sample(c)
+	}
+	return rc, size, nil
+}
+
+// blockReader 是 GetBlockReader 流式读取时返回的读取器，
+// 底层读取器只关闭一次，Close 总是返回 nil。
+type blockReader struct {
+	io.ReadCloser
+	closed bool
+}
+
+func (b *blockReader) Close() error {
+	if !b.closed {
+		b.closed = true
+		// 只读打开的文件关闭失败不影响已读取的数据
+		_ = b.ReadCloser.Close()
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	cid2 "github.com/ipfs/go-cid"
+)
+
+// gatedBlockstore counts reads and holds each one until release is closed.
+type gatedBlockstore struct {
+	blockstore.Blockstore
+
+	reads   atomic.Int64
+	release chan struct{}
+}
+
+func (b *gatedBlockstore) Get(ctx context.Context, c cid2.Cid) (blocks.Block, error) {
+	b.reads.Add(1)
+	<-b.release
+	return b.Blockstore.Get(ctx, c)
+}
+
+// newGatedRepository returns a repository holding data whose block reads
+// wait for the returned blockstore to be released.
+func newGatedRepository(t *testing.T, data []byte) (*Repository, *gatedBlockstore, string) {
+	t.Helper()
+
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	t.Cleanup(func() { _ = repo.Close() })
+
+	c, err := repo.PutBlock(context.Background(), data)
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+
+	gated := &gatedBlockstore{Blockstore: repo.blockStore, release: make(chan struct{})}
+	repo.blockStore = gated
+	return repo, gated, c.String()
+}
+
+// waitFor polls cond until it holds or the test times out.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRepository_GetRawData_CoalescesConcurrentReads(t *testing.T) {
+	data := []byte("hot directory node")
+	repo, gated, c := newGatedRepository(t, data)
+
+	const callers = 100
+	results := make([][]byte, callers)
+	errs := make([]error, callers)
+	var started, wg sync.WaitGroup
+	started.Add(callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			started.Done()
+			results[i], errs[i] = repo.GetRawData(context.Background(), c)
+		}(i)
+	}
+
+	// Give every caller time to join the flight blocked in the first read
+	started.Wait()
+	waitFor(t, "the first read", func() bool { return gated.reads.Load() > 0 })
+	time.Sleep(50 * time.Millisecond)
+	close(gated.release)
+	wg.Wait()
+
+	for i := range results {
+		if errs[i] != nil {
+			t.Fatalf("caller %d failed: %v", i, errs[i])
+		}
+		if !bytes.Equal(results[i], data) {
+			t.Fatalf("caller %d got %q", i, results[i])
+		}
+	}
+	if reads := gated.reads.Load(); reads > 2 {
+		t.Errorf("%d callers caused %d datastore reads, want about 1", callers, reads)
+	}
+	if got := repo.CoalescedReads(); got < callers-2 {
+		t.Errorf("CoalescedReads() = %d, want about %d", got, callers-1)
+	}
+
+	// Callers must not share the returned slice
+	results[0][0] ^= 0xff
+	for i := 1; i < callers; i++ {
+		if !bytes.Equal(results[i], data) {
+			t.Fatalf("modifying one result changed caller %d's data", i)
+		}
+	}
+}
+
+func TestRepository_GetRawData_CancelledWaiter(t *testing.T) {
+	data := []byte("shared block")
+	repo, gated, c := newGatedRepository(t, data)
+
+	// The cancelled caller starts the flight, so the shared read runs under its context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error, 1)
+	go func() {
+		_, err := repo.GetRawData(ctx, c)
+		cancelled <- err
+	}()
+	waitFor(t, "the first read", func() bool { return gated.reads.Load() > 0 })
+
+	const waiters = 10
+	errs := make(chan error, waiters)
+	for i := 0; i < waiters; i++ {
+		go func() {
+			got, err := repo.GetRawData(context.Background(), c)
+			if err == nil && !bytes.Equal(got, data) {
+				err = errors.New("unexpected data")
+			}
+			errs <- err
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-cancelled:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("cancelled caller returned %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled caller did not return while the read was pending")
+	}
+
+	close(gated.release)
+	for i := 0; i < waiters; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("waiter failed after another caller cancelled: %v", err)
+		}
+	}
+}
+
+func TestRepository_GetBlockReader(t *testing.T) {
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+	repo.limits.GetRetryBaseDelay = time.Millisecond
+
+	ctx := context.Background()
+	data := bytes.Repeat([]byte("large raw block "), 64<<10)
+	c, err := repo.PutBlock(ctx, data)
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+
+	rc, size, err := repo.GetBlockReader(ctx, c.String())
+	if err != nil {
+		t.Fatalf("GetBlockReader failed: %v", err)
+	}
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if size != int64(len(data)) || !bytes.Equal(got, data) {
+		t.Errorf("size %d and %d bytes read, want %d", size, len(got), len(data))
+	}
+	// 未启用 VerifyReads 时直接读取 FlatFS 中的文件，块不会整体读入内存
+	if br, ok := rc.(*blockReader); !ok {
+		t.Errorf("GetBlockReader returned %T, want a streaming reader", rc)
+	} else if _, ok := br.ReadCloser.(*os.File); !ok {
+		t.Errorf("GetBlockReader streams from %T, want the block file", br.ReadCloser)
+	}
+	// 重复关闭是安全的
+	if err := rc.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Errorf("second Close failed: %v", err)
+	}
+
+	if _, _, err := repo.GetBlockReader(ctx, "not-a-cid"); err == nil {
+		t.Error("GetBlockReader accepted an invalid CID")
+	}
+
+	missing, err := repo.builder.Sum([]byte("never stored"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := repo.GetBlockReader(ctx, missing.String()); err == nil {
+		t.Error("GetBlockReader returned a reader for a missing block")
+	}
+}
+
+func TestRepository_GetBlockReader_VerifyReads(t *testing.T) {
+	repo, err := NewRepositoryWithOptions(t.TempDir(), RepoOptions{VerifyReads: true})
+	if err != nil {
+		t.Fatalf("NewRepositoryWithOptions failed: %v", err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+	data := []byte("verified before it is returned")
+	c, err := repo.PutBlock(ctx, data)
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+
+	// 校验需要完整的块，读取器建立在已校验的数据之上
+	rc, size, err := repo.GetBlockReader(ctx, c.String())
+	if err != nil {
+		t.Fatalf("GetBlockReader failed: %v", err)
+	}
+	defer rc.Close()
+	if _, ok := rc.(*blockReader); ok {
+		t.Error("GetBlockReader streamed a block that VerifyReads must check first")
+	}
+	if got, _ := io.ReadAll(rc); size != int64(len(data)) || !bytes.Equal(got, data) {
+		t.Errorf("read %q (size %d), want %q", got, size, data)
+	}
+}
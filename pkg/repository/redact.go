@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	cid2 "github.com/ipfs/go-cid"
+	"github.com/tragoedia0722/repository/internal/storage"
+)
+
+// redactingBlockstore 隐藏 blockstore 错误信息中的仓库根路径。
+//
+// 底层 datastore（如 flatfs）返回的错误通常包含块文件的完整路径，
+// 包装后仍可通过 errors.As 获取原始错误。
+type redactingBlockstore struct {
+	blockstore.Blockstore
+
+	storage *storage.Storage
+}
+
+func (b *redactingBlockstore) DeleteBlock(ctx context.Context, c cid2.Cid) error {
+	return b.storage.RedactError(b.Blockstore.DeleteBlock(ctx, c))
+}
+
+func (b *redactingBlockstore) Has(ctx context.Context, c cid2.Cid) (bool, error) {
+	has, err := b.Blockstore.Has(ctx, c)
+	return has, b.storage.RedactError(err)
+}
+
+func (b *redactingBlockstore) Get(ctx context.Context, c cid2.Cid) (blocks.Block, error) {
+	blk, err := b.Blockstore.Get(ctx, c)
+	return blk, b.storage.RedactError(err)
+}
+
+func (b *redactingBlockstore) GetSize(ctx context.Context, c cid2.Cid) (int, error) {
+	size, err := b.Blockstore.GetSize(ctx, c)
+	return size, b.storage.RedactError(err)
+}
+
+func (b *redactingBlockstore) Put(ctx context.Context, blk blocks.Block) error {
+	return b.storage.RedactError(b.Blockstore.Put(ctx, blk))
+}
+
+func (b *redactingBlockstore) PutMany(ctx context.Context, blks []blocks.Block) error {
+	return b.storage.RedactError(b.Blockstore.PutMany(ctx, blks))
+}
+
+func (b *redactingBlockstore) AllKeysChan(ctx context.Context) (<-chan cid2.Cid, error) {
+	ch, err := b.Blockstore.AllKeysChan(ctx)
+	return ch, b.storage.RedactError(err)
+}
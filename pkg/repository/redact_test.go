@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tragoedia0722/repository/internal/storage"
+)
+
+func TestNewRepositoryWithOptions_RedactPaths(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// 仓库路径是普通文件，创建目录时会返回包含完整路径的错误
+	path := filepath.Join(tmpDir, "repo")
+	if err := os.WriteFile(path, []byte("x"), 0o600); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	_, err := NewRepositoryWithOptions(path, RepoOptions{RedactPaths: true})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if strings.Contains(err.Error(), tmpDir) {
+		t.Errorf("error message leaks the path: %v", err)
+	}
+	if !strings.Contains(err.Error(), storage.RedactedPathPlaceholder) {
+		t.Errorf("error message should contain %s: %v", storage.RedactedPathPlaceholder, err)
+	}
+
+	var pathErr *os.PathError
+	if !errors.As(err, &pathErr) || !strings.HasPrefix(pathErr.Path, tmpDir) {
+		t.Errorf("expected full path via errors.As, got %v", err)
+	}
+
+	_, err = NewRepository(path)
+	if err == nil || !strings.Contains(err.Error(), tmpDir) {
+		t.Errorf("expected full path without redaction, got %v", err)
+	}
+}
+
+func TestNewRepositoryWithOptions_RedactedBlockstore(t *testing.T) {
+	repo, err := NewRepositoryWithOptions(t.TempDir(), RepoOptions{RedactPaths: true})
+	if err != nil {
+		t.Fatalf("NewRepositoryWithOptions failed: %v", err)
+	}
+	defer repo.Close()
+
+	if _, ok := repo.BlockStore().(*redactingBlockstore); !ok {
+		t.Fatalf("expected redacting blockstore, got %T", repo.BlockStore())
+	}
+
+	// 正常读写不受影响，未找到的错误仍可识别
+	data := []byte("redacted repository")
+	c, err := repo.PutBlock(t.Context(), data)
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+	got, err := repo.GetRawData(t.Context(), c.String())
+	if err != nil || string(got) != string(data) {
+		t.Fatalf("GetRawData = %q, %v", got, err)
+	}
+	if err := repo.DelBlock(t.Context(), c.String()); err != nil {
+		t.Fatalf("DelBlock failed: %v", err)
+	}
+	if has, err := repo.HasBlock(t.Context(), c.String()); err != nil || has {
+		t.Errorf("HasBlock = %v, %v after delete", has, err)
+	}
+}
@@ -0,0 +1,524 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	cid2 "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/tragoedia0722/repository/internal/storage"
+)
+
+const (
+	// replicaLRUPrefix 是本地 datastore 中缓存块访问记录的命名空间。
+	replicaLRUPrefix = "/replica/lru"
+
+	// replicaFlushTouches 是缓存命中累积多少个未写回的访问记录后批量写回。
+	replicaFlushTouches = 1024
+)
+
+// WriteMode 指定副本仓库的写入方式。
+type WriteMode int
+
+const (
+	// WriteLocal 只写入本地仓库。
+	WriteLocal WriteMode = iota
+
+	// WriteBoth 同时写入本地仓库和主仓库。
+	WriteBoth
+)
+
+// ReplicaOptions 配置副本仓库。
+type ReplicaOptions struct {
+	// MaxCacheBytes 是从主仓库读取并缓存到本地的块的最大总字节数。
+	// 0 表示不限制。本地写入的块不计入此限制，也永远不会被淘汰；
+	// 被固定或被 CommitImport 提交的导入引用的缓存块同样视为本地持有。
+	MaxCacheBytes int64
+
+	// WriteMode 指定 PutBlock 等写操作的目标。
+	WriteMode WriteMode
+
+	// AccessStats 配置副本仓库的块访问统计，见 RepoOptions.AccessStats。
+	// 启用后 AccessStats 还报告本地缓存的命中和未命中次数。
+	AccessStats AccessStatsOptions
+}
+
+// NewReplicaRepository 创建一个以 primary 为主仓库的本地读副本。
+//
+// 读取时优先访问本地仓库；本地不存在时从主仓库读取，校验 CID 后缓存到本地。
+// 缓存的块按 LRU 顺序淘汰，访问记录保存在本地 datastore 中，重新打开后仍然有效：
+// 缓存命中只更新内存中的顺序，每累积 replicaFlushTouches 个变化以及关闭时
+// 在锁外批量写回，进程异常退出只丢失最近的访问顺序。
+// 本地写入的块视为本地持有，不会被淘汰。Usage 返回本地仓库的使用情况。
+//
+// 关闭副本仓库不会关闭主仓库。
+//
+// 参数：
+//
+//	localPath - 本地仓库路径
+//	primary - 主仓库
+//	opts - 副本配置
+//
+// 返回：
+//
+//	*Repository - 副本仓库实例
+//	error - 如果创建失败，返回错误
+func NewReplicaRepository(localPath string, primary *Repository, opts ReplicaOptions) (*Repository, error) {
+	return NewReplicaRepositoryWithContext(context.Background(), localPath, primary, opts)
+}
+
+// NewReplicaRepositoryWithContext 与 NewReplicaRepository 相同，支持上下文控制。
+//
+// 等待本地仓库的锁和恢复缓存索引响应 ctx，ctx 结束时返回包装 ctx.Err() 的错误。
+// 本地 datastore 的打开不可中断。
+//
+// 参数：
+//
+//	ctx - 用于取消操作或设置超时的上下文
+//	localPath - 本地仓库路径
+//	primary - 主仓库
+//	opts - 副本配置
+//
+// 返回：
+//
+//	*Repository - 副本仓库实例
+//	error - 如果创建失败或上下文取消，返回错误
+func NewReplicaRepositoryWithContext(ctx context.Context, localPath string, primary *Repository, opts ReplicaOptions) (*Repository, error) {
+	if primary == nil {
+		return nil, fmt.Errorf("primary repository cannot be nil")
+	}
+	if localPath == "" {
+		return nil, fmt.Errorf("repository path cannot be empty")
+	}
+	if err := opts.AccessStats.validate(); err != nil {
+		return nil, err
+	}
+
+	localPath = filepath.Clean(localPath)
+	if err := os.MkdirAll(localPath, defaultDirPerm); err != nil {
+		return nil, fmt.Errorf("failed to create repository directory: %w", err)
+	}
+
+	s, err := storage.NewStorageWithContext(ctx, localPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage: %w", err)
+	}
+
+	r := &Repository{
+		storage: s,
+		builder: defaultBuilder,
+		limits:  defaultLimits(),
+		reads:   newReadGroup(),
+		access:  newAccessStats(opts.AccessStats),
+	}
+
+	// 缓存的填充和淘汰同样通知变更回调
+	rb := &replicaBlockstore{
+		Blockstore: r.watchBlockstore(blockstore.NewBlockstore(s.Datastore())),
+		meta:       s.Datastore(),
+		primary:    primary,
+		opts:       opts,
+		access:     r.access,
+		lru:        list.New(),
+		entries:    make(map[cid2.Cid]*list.Element),
+		dirty:      make(map[cid2.Cid]*cacheEntry),
+	}
+	if err := rb.load(ctx); err != nil {
+		_ = s.Close()
+		return nil, fmt.Errorf("failed to load replica cache index: %w", err)
+	}
+
+	r.blockStore = r.accessBlockstore(rb)
+	r.flush = rb.flush
+	r.replica = rb
+	return r, nil
+}
+
+// cacheEntry 是一个从主仓库缓存到本地的块。
+type cacheEntry struct {
+	cid  cid2.Cid
+	size int64
+	seq  uint64 // 最近一次访问的序号，越大越新
+}
+
+// replicaBlockstore 在本地 blockstore 之上实现对主仓库的读穿透缓存。
+type replicaBlockstore struct {
+	blockstore.Blockstore // 本地 blockstore
+
+	meta    ds.Datastore
+	primary *Repository
+	opts    ReplicaOptions
+	access  *accessStats // 记录缓存命中，未启用统计时为 nil
+
+	mu         sync.Mutex
+	lru        *list.List // 前端为最近访问
+	entries    map[cid2.Cid]*list.Element
+	cacheBytes int64
+	seq        uint64
+	dirty      map[cid2.Cid]*cacheEntry // 访问顺序变化后尚未写回的缓存块
+
+	flushing sync.Mutex // 串行化 flush，避免较早的快照覆盖较新的记录
+}
+
+// load 从本地 datastore 恢复缓存块的 LRU 顺序。
+func (b *replicaBlockstore) load(ctx context.Context) error {
+	res, err := b.meta.Query(ctx, query.Query{Prefix: replicaLRUPrefix})
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+
+	var loaded []*cacheEntry
+	for r := range res.Next() {
+		if r.Error != nil {
+			return r.Error
+		}
+		c, err := cid2.Parse(ds.RawKey(r.Key).BaseNamespace())
+		if err != nil || len(r.Value) != 16 {
+			continue
+		}
+		loaded = append(loaded, &cacheEntry{
+			cid:  c,
+			seq:  binary.BigEndian.Uint64(r.Value[:8]),
+			size: int64(binary.BigEndian.Uint64(r.Value[8:])),
+		})
+	}
+
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].seq > loaded[j].seq })
+	for _, e := range loaded {
+		b.entries[e.cid] = b.lru.PushBack(e)
+		b.cacheBytes += e.size
+		if e.seq > b.seq {
+			b.seq = e.seq
+		}
+	}
+	return nil
+}
+
+// Has 先检查本地，本地不存在时检查主仓库。
+func (b *replicaBlockstore) Has(ctx context.Context, c cid2.Cid) (bool, error) {
+	has, err := b.Blockstore.Has(ctx, c)
+	if err != nil || has {
+		return has, err
+	}
+	return b.primary.blockStore.Has(ctx, c)
+}
+
+// Get 先读取本地，本地不存在时从主仓库读取并缓存。
+func (b *replicaBlockstore) Get(ctx context.Context, c cid2.Cid) (blocks.Block, error) {
+	blk, err := b.Blockstore.Get(ctx, c)
+	if err == nil {
+		b.touch(ctx, c)
+		b.access.cacheHit()
+		return blk, nil
+	}
+	if !ipld.IsNotFound(err) {
+		return nil, err
+	}
+
+	b.access.cacheMiss()
+	return b.fetch(ctx, c)
+}
+
+// GetSize 先读取本地，本地不存在时从主仓库读取并缓存。
+func (b *replicaBlockstore) GetSize(ctx context.Context, c cid2.Cid) (int, error) {
+	size, err := b.Blockstore.GetSize(ctx, c)
+	if err == nil {
+		b.access.cacheHit()
+	}
+	if err == nil || !ipld.IsNotFound(err) {
+		return size, err
+	}
+
+	b.access.cacheMiss()
+	blk, err := b.fetch(ctx, c)
+	if err != nil {
+		return -1, err
+	}
+	return len(blk.RawData()), nil
+}
+
+// Put 写入本地，WriteBoth 模式下同时写入主仓库。
+func (b *replicaBlockstore) Put(ctx context.Context, blk blocks.Block) error {
+	if b.opts.WriteMode == WriteBoth {
+		if err := b.primary.blockStore.Put(ctx, blk); err != nil {
+			return fmt.Errorf("failed to write to primary: %w", err)
+		}
+	}
+	if err := b.Blockstore.Put(ctx, blk); err != nil {
+		return err
+	}
+	return b.untrack(ctx, blk.Cid())
+}
+
+// PutMany 批量写入本地，WriteBoth 模式下同时写入主仓库。
+func (b *replicaBlockstore) PutMany(ctx context.Context, blks []blocks.Block) error {
+	if b.opts.WriteMode == WriteBoth {
+		if err := b.primary.blockStore.PutMany(ctx, blks); err != nil {
+			return fmt.Errorf("failed to write to primary: %w", err)
+		}
+	}
+	if err := b.Blockstore.PutMany(ctx, blks); err != nil {
+		return err
+	}
+	for _, blk := range blks {
+		if err := b.untrack(ctx, blk.Cid()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteBlock 只删除本地块，不影响主仓库。
+func (b *replicaBlockstore) DeleteBlock(ctx context.Context, c cid2.Cid) error {
+	if err := b.Blockstore.DeleteBlock(ctx, c); err != nil {
+		return err
+	}
+	return b.untrack(ctx, c)
+}
+
+// fetch 从主仓库读取块，校验 CID 后写入本地缓存。
+func (b *replicaBlockstore) fetch(ctx context.Context, c cid2.Cid) (blocks.Block, error) {
+	data, err := b.primary.GetRawDataCid(ctx, c)
+	if err != nil {
+		if has, hasErr := b.primary.blockStore.Has(ctx, c); hasErr == nil && !has {
+			return nil, ipld.ErrNotFound{Cid: c}
+		}
+		return nil, fmt.Errorf("failed to read from primary: %w", err)
+	}
+
+	sum, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify block %s: %w", c, err)
+	}
+	if !sum.Equals(c) {
+		return nil, fmt.Errorf("block %s from primary failed CID verification", c)
+	}
+
+	blk, err := blocks.NewBlockWithCid(data, c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create block: %w", err)
+	}
+
+	if err := b.Blockstore.Put(ctx, blk); err != nil {
+		return nil, fmt.Errorf("failed to cache block: %w", err)
+	}
+	if err := b.track(ctx, c, int64(len(data))); err != nil {
+		return nil, err
+	}
+
+	return blk, nil
+}
+
+// track 记录一个新缓存的块，并在超出容量时淘汰最久未访问的块。
+func (b *replicaBlockstore) track(ctx context.Context, c cid2.Cid, size int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elem, ok := b.entries[c]; ok {
+		b.promote(elem)
+		return nil
+	}
+
+	b.seq++
+	e := &cacheEntry{cid: c, size: size, seq: b.seq}
+	if err := b.persist(ctx, e); err != nil {
+		return err
+	}
+	b.entries[c] = b.lru.PushFront(e)
+	b.cacheBytes += size
+
+	return b.evict(ctx)
+}
+
+// touch 更新缓存块的访问顺序。本地写入的块不在 LRU 中，不受影响。
+// 未写回的记录达到 replicaFlushTouches 个时在释放 b.mu 之后写回。
+func (b *replicaBlockstore) touch(ctx context.Context, c cid2.Cid) {
+	b.mu.Lock()
+	if elem, ok := b.entries[c]; ok {
+		b.promote(elem)
+	}
+	full := len(b.dirty) >= replicaFlushTouches
+	b.mu.Unlock()
+
+	if full {
+		// 访问记录写入失败只影响淘汰顺序，不影响读取结果
+		_ = b.flush(ctx)
+	}
+}
+
+// promote 将缓存块移到 LRU 前端，访问记录留待 flush 写回。调用者必须持有 b.mu。
+func (b *replicaBlockstore) promote(elem *list.Element) {
+	e := elem.Value.(*cacheEntry)
+	b.seq++
+	e.seq = b.seq
+	b.lru.MoveToFront(elem)
+	b.dirty[e.cid] = e
+}
+
+// flush 将未写回的访问记录批量写入本地 datastore，写入时不持有 b.mu。
+// 写入期间被移除或重新缓存的块按其当前状态修正记录。
+func (b *replicaBlockstore) flush(ctx context.Context) error {
+	b.flushing.Lock()
+	defer b.flushing.Unlock()
+
+	b.mu.Lock()
+	if len(b.dirty) == 0 {
+		b.mu.Unlock()
+		return nil
+	}
+	pending := make([]cacheEntry, 0, len(b.dirty))
+	for _, e := range b.dirty {
+		pending = append(pending, *e)
+	}
+	clear(b.dirty)
+	b.mu.Unlock()
+
+	batch, err := b.batch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to record cache access: %w", err)
+	}
+	for i := range pending {
+		if err := b.persistTo(ctx, batch, &pending[i]); err != nil {
+			return err
+		}
+	}
+	if err := batch.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to record cache access: %w", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := range pending {
+		c := pending[i].cid
+		elem, ok := b.entries[c]
+		switch {
+		case !ok:
+			if err := b.meta.Delete(ctx, replicaLRUKey(c)); err != nil {
+				return fmt.Errorf("failed to record cache access: %w", err)
+			}
+		case elem.Value.(*cacheEntry).seq != pending[i].seq:
+			// 写入期间再次被访问的块已重新标记，重新缓存的块需要恢复 track 写入的记录
+			if _, ok := b.dirty[c]; !ok {
+				if err := b.persist(ctx, elem.Value.(*cacheEntry)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// batch 返回写入访问记录的批，datastore 不支持批量写入时逐条写入。
+func (b *replicaBlockstore) batch(ctx context.Context) (ds.Batch, error) {
+	if bd, ok := b.meta.(ds.Batching); ok {
+		return bd.Batch(ctx)
+	}
+	return unbatched{b.meta}, nil
+}
+
+// unbatched 以逐条写入实现 ds.Batch。
+type unbatched struct {
+	ds.Datastore
+}
+
+// Commit 实现 ds.Batch，写入已经完成。
+func (unbatched) Commit(context.Context) error {
+	return nil
+}
+
+// untrack 将块从 LRU 中移除，使其成为本地持有的块或反映其已被删除。
+func (b *replicaBlockstore) untrack(ctx context.Context, c cid2.Cid) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	elem, ok := b.entries[c]
+	if !ok {
+		return nil
+	}
+	e := elem.Value.(*cacheEntry)
+	b.lru.Remove(elem)
+	delete(b.entries, c)
+	delete(b.dirty, c)
+	b.cacheBytes -= e.size
+
+	return b.meta.Delete(ctx, replicaLRUKey(c))
+}
+
+// evict 淘汰最久未访问的缓存块，直到总大小不超过 MaxCacheBytes。
+// 被固定或被引用的块不删除，而是移出 LRU 成为本地持有的块。
+// 调用者必须持有 b.mu。
+func (b *replicaBlockstore) evict(ctx context.Context) error {
+	if b.opts.MaxCacheBytes <= 0 {
+		return nil
+	}
+
+	for b.cacheBytes > b.opts.MaxCacheBytes {
+		elem := b.lru.Back()
+		if elem == nil {
+			return nil
+		}
+		e := elem.Value.(*cacheEntry)
+
+		held, err := b.held(ctx, e.cid)
+		if err != nil {
+			return fmt.Errorf("failed to evict block %s: %w", e.cid, err)
+		}
+		if !held {
+			if err := b.Blockstore.DeleteBlock(ctx, e.cid); err != nil && !ipld.IsNotFound(err) {
+				return fmt.Errorf("failed to evict block %s: %w", e.cid, err)
+			}
+		}
+		if err := b.meta.Delete(ctx, replicaLRUKey(e.cid)); err != nil {
+			return fmt.Errorf("failed to evict block %s: %w", e.cid, err)
+		}
+
+		b.lru.Remove(elem)
+		delete(b.entries, e.cid)
+		delete(b.dirty, e.cid)
+		b.cacheBytes -= e.size
+	}
+	return nil
+}
+
+// held 报告块 c 是否是被固定的根或被已提交的导入引用。
+func (b *replicaBlockstore) held(ctx context.Context, c cid2.Cid) (bool, error) {
+	pinned, err := b.meta.Has(ctx, pinPrefix.ChildString(c.String()))
+	if err != nil || pinned {
+		return pinned, err
+	}
+	refs, err := countRefs(ctx, b.meta, c)
+	return refs > 0, err
+}
+
+// persist 将缓存块的访问记录写入本地 datastore。
+func (b *replicaBlockstore) persist(ctx context.Context, e *cacheEntry) error {
+	return b.persistTo(ctx, b.meta, e)
+}
+
+// persistTo 将缓存块的访问记录写入 w。
+func (b *replicaBlockstore) persistTo(ctx context.Context, w ds.Write, e *cacheEntry) error {
+	value := make([]byte, 16)
+	binary.BigEndian.PutUint64(value[:8], e.seq)
+	binary.BigEndian.PutUint64(value[8:], uint64(e.size))
+
+	if err := w.Put(ctx, replicaLRUKey(e.cid), value); err != nil {
+		return fmt.Errorf("failed to record cache access: %w", err)
+	}
+	return nil
+}
+
+// replicaLRUKey 返回缓存块访问记录的 datastore 键。
+func replicaLRUKey(c cid2.Cid) ds.Key {
+	return ds.NewKey(replicaLRUPrefix).ChildString(c.String())
+}
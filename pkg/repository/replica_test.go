@@ -0,0 +1,375 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	cid2 "github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// countingBlockstore counts Get calls on the wrapped blockstore.
+type countingBlockstore struct {
+	blockstore.Blockstore
+	gets atomic.Int64
+}
+
+func (c *countingBlockstore) Get(ctx context.Context, k cid2.Cid) (blocks.Block, error) {
+	c.gets.Add(1)
+	return c.Blockstore.Get(ctx, k)
+}
+
+// setupReplica creates a primary repository with a counting blockstore and a replica of it.
+func setupReplica(t *testing.T, opts ReplicaOptions) (*Repository, *countingBlockstore, *Repository) {
+	t.Helper()
+
+	primary, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	t.Cleanup(func() { _ = primary.Close() })
+
+	counter := &countingBlockstore{Blockstore: primary.blockStore}
+	primary.blockStore = counter
+
+	replica, err := NewReplicaRepository(t.TempDir(), primary, opts)
+	if err != nil {
+		t.Fatalf("NewReplicaRepository failed: %v", err)
+	}
+	t.Cleanup(func() { _ = replica.Close() })
+
+	return primary, counter, replica
+}
+
+// hasLocal reports whether the replica holds the block in its local store.
+func hasLocal(t *testing.T, replica *Repository, cid string) bool {
+	t.Helper()
+
+	c, err := cid2.Parse(cid)
+	if err != nil {
+		t.Fatalf("invalid CID: %v", err)
+	}
+	has, err := replica.blockStore.(*replicaBlockstore).Blockstore.Has(context.Background(), c)
+	if err != nil {
+		t.Fatalf("Has failed: %v", err)
+	}
+	return has
+}
+
+func TestReplicaRepository_ReadThrough(t *testing.T) {
+	ctx := context.Background()
+	primary, counter, replica := setupReplica(t, ReplicaOptions{})
+
+	data := []byte("hot dataset block")
+	c, err := primary.PutBlock(ctx, data)
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+
+	if hasLocal(t, replica, c.String()) {
+		t.Fatal("block should not be local before first read")
+	}
+
+	got, err := replica.GetRawData(ctx, c.String())
+	if err != nil {
+		t.Fatalf("GetRawData failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("data mismatch: got %q", got)
+	}
+	if !hasLocal(t, replica, c.String()) {
+		t.Error("block should be cached locally after read")
+	}
+
+	afterFirst := counter.gets.Load()
+	if afterFirst == 0 {
+		t.Fatal("expected primary access on miss")
+	}
+
+	if _, err := replica.GetRawData(ctx, c.String()); err != nil {
+		t.Fatalf("second GetRawData failed: %v", err)
+	}
+	if counter.gets.Load() != afterFirst {
+		t.Errorf("primary accessed on cache hit: %d -> %d", afterFirst, counter.gets.Load())
+	}
+
+	has, err := replica.HasBlock(ctx, c.String())
+	if err != nil || !has {
+		t.Errorf("HasBlock = %v, %v", has, err)
+	}
+}
+
+func TestReplicaRepository_Missing(t *testing.T) {
+	ctx := context.Background()
+	_, _, replica := setupReplica(t, ReplicaOptions{})
+
+	c, err := cid2.V1Builder{Codec: cid2.Raw, MhType: mh.SHA2_256}.Sum([]byte("absent"))
+	if err != nil {
+		t.Fatalf("failed to build CID: %v", err)
+	}
+
+	if _, err := replica.GetRawData(ctx, c.String()); err == nil {
+		t.Error("expected error for block missing in both repositories")
+	}
+	has, err := replica.HasBlock(ctx, c.String())
+	if err != nil || has {
+		t.Errorf("HasBlock = %v, %v", has, err)
+	}
+}
+
+func TestReplicaRepository_Eviction(t *testing.T) {
+	ctx := context.Background()
+	primary, _, replica := setupReplica(t, ReplicaOptions{MaxCacheBytes: 25})
+
+	local, err := replica.PutBlock(ctx, []byte("locally written block, larger than the cap"))
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+
+	var cids []string
+	for _, s := range []string{"block-one", "block-two", "block-six"} {
+		c, err := primary.PutBlock(ctx, []byte(s))
+		if err != nil {
+			t.Fatalf("PutBlock failed: %v", err)
+		}
+		cids = append(cids, c.String())
+	}
+
+	// Two 9-byte blocks fit; touch the first so the second becomes least recently used.
+	for _, c := range cids[:2] {
+		if _, err := replica.GetRawData(ctx, c); err != nil {
+			t.Fatalf("GetRawData failed: %v", err)
+		}
+	}
+	if _, err := replica.GetRawData(ctx, cids[0]); err != nil {
+		t.Fatalf("GetRawData failed: %v", err)
+	}
+	if _, err := replica.GetRawData(ctx, cids[2]); err != nil {
+		t.Fatalf("GetRawData failed: %v", err)
+	}
+
+	if !hasLocal(t, replica, cids[0]) || !hasLocal(t, replica, cids[2]) {
+		t.Error("recently used blocks should remain cached")
+	}
+	if hasLocal(t, replica, cids[1]) {
+		t.Error("least recently used block should have been evicted")
+	}
+	if !hasLocal(t, replica, local.String()) {
+		t.Error("locally written block must never be evicted")
+	}
+
+	rb := replica.blockStore.(*replicaBlockstore)
+	if rb.cacheBytes > 25 {
+		t.Errorf("cacheBytes = %d exceeds cap", rb.cacheBytes)
+	}
+}
+
+func TestReplicaRepository_EvictionKeepsCommitted(t *testing.T) {
+	ctx := context.Background()
+	primary, _, replica := setupReplica(t, ReplicaOptions{MaxCacheBytes: 16})
+
+	result := importFiles(t, primary, map[string]string{"a.txt": "committed data", "dir/b.txt": "more committed data"})
+	if err := replica.CommitImport(ctx, result, CommitOptions{}); err != nil {
+		t.Fatalf("CommitImport failed: %v", err)
+	}
+
+	// Reading the import caches far more than the cap; filler blocks then push it out of the LRU.
+	var imported []string
+	for _, pkg := range result.Packages {
+		imported = append(imported, pkg.Blocks...)
+	}
+	for _, c := range imported {
+		if _, err := replica.GetRawData(ctx, c); err != nil {
+			t.Fatalf("GetRawData failed: %v", err)
+		}
+	}
+	for _, s := range []string{"filler-block-one", "filler-block-two"} {
+		c, err := primary.PutBlock(ctx, []byte(s))
+		if err != nil {
+			t.Fatalf("PutBlock failed: %v", err)
+		}
+		if _, err := replica.GetRawData(ctx, c.String()); err != nil {
+			t.Fatalf("GetRawData failed: %v", err)
+		}
+	}
+
+	for _, c := range imported {
+		if !hasLocal(t, replica, c) {
+			t.Errorf("block %s of a committed import was evicted", c)
+		}
+	}
+	if rb := replica.blockStore.(*replicaBlockstore); rb.cacheBytes > 16 {
+		t.Errorf("cacheBytes = %d exceeds cap", rb.cacheBytes)
+	}
+}
+
+func TestReplicaRepository_LRUPersisted(t *testing.T) {
+	ctx := context.Background()
+	primary, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer primary.Close()
+
+	c, err := primary.PutBlock(ctx, []byte("persisted"))
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+
+	path := t.TempDir()
+	replica, err := NewReplicaRepository(path, primary, ReplicaOptions{})
+	if err != nil {
+		t.Fatalf("NewReplicaRepository failed: %v", err)
+	}
+	if _, err := replica.GetRawData(ctx, c.String()); err != nil {
+		t.Fatalf("GetRawData failed: %v", err)
+	}
+	if err := replica.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reopened, err := NewReplicaRepository(path, primary, ReplicaOptions{})
+	if err != nil {
+		t.Fatalf("NewReplicaRepository failed: %v", err)
+	}
+	defer reopened.Close()
+
+	rb := reopened.blockStore.(*replicaBlockstore)
+	if _, ok := rb.entries[*c]; !ok {
+		t.Error("cached block should be tracked after reopening")
+	}
+	if rb.cacheBytes != int64(len("persisted")) {
+		t.Errorf("cacheBytes = %d, want %d", rb.cacheBytes, len("persisted"))
+	}
+}
+
+func TestReplicaRepository_TouchFlushedOnClose(t *testing.T) {
+	ctx := context.Background()
+	primary, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer primary.Close()
+
+	var cids []cid2.Cid
+	for _, s := range []string{"older", "newer"} {
+		c, err := primary.PutBlock(ctx, []byte(s))
+		if err != nil {
+			t.Fatalf("PutBlock failed: %v", err)
+		}
+		cids = append(cids, *c)
+	}
+
+	path := t.TempDir()
+	replica, err := NewReplicaRepository(path, primary, ReplicaOptions{})
+	if err != nil {
+		t.Fatalf("NewReplicaRepository failed: %v", err)
+	}
+	for _, c := range cids {
+		if _, err := replica.GetRawDataCid(ctx, c); err != nil {
+			t.Fatalf("GetRawDataCid failed: %v", err)
+		}
+	}
+
+	// A cache hit reorders the list in memory without writing the record.
+	rb := replica.blockStore.(*replicaBlockstore)
+	before, err := rb.meta.Get(ctx, replicaLRUKey(cids[0]))
+	if err != nil {
+		t.Fatalf("Get record failed: %v", err)
+	}
+	if _, err := replica.GetRawDataCid(ctx, cids[0]); err != nil {
+		t.Fatalf("GetRawDataCid failed: %v", err)
+	}
+	if front := rb.lru.Front().Value.(*cacheEntry).cid; !front.Equals(cids[0]) {
+		t.Errorf("LRU front = %s, want touched block %s", front, cids[0])
+	}
+	after, err := rb.meta.Get(ctx, replicaLRUKey(cids[0]))
+	if err != nil {
+		t.Fatalf("Get record failed: %v", err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Error("cache hit should not write the access record before a flush")
+	}
+
+	if err := replica.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	reopened, err := NewReplicaRepository(path, primary, ReplicaOptions{})
+	if err != nil {
+		t.Fatalf("NewReplicaRepository failed: %v", err)
+	}
+	defer reopened.Close()
+
+	rb = reopened.blockStore.(*replicaBlockstore)
+	if front := rb.lru.Front().Value.(*cacheEntry).cid; !front.Equals(cids[0]) {
+		t.Errorf("LRU front after reopening = %s, want %s", front, cids[0])
+	}
+}
+
+func TestReplicaBlockstore_FlushSkipsRemoved(t *testing.T) {
+	ctx := context.Background()
+	primary, _, replica := setupReplica(t, ReplicaOptions{})
+	c, err := primary.PutBlock(ctx, []byte("removed"))
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+	if _, err := replica.GetRawDataCid(ctx, *c); err != nil {
+		t.Fatalf("GetRawDataCid failed: %v", err)
+	}
+	if _, err := replica.GetRawDataCid(ctx, *c); err != nil {
+		t.Fatalf("GetRawDataCid failed: %v", err)
+	}
+
+	// Deleting the block drops its pending record, so the flush must not write it back.
+	rb := replica.blockStore.(*replicaBlockstore)
+	if err := replica.DelBlockCid(ctx, *c); err != nil {
+		t.Fatalf("DelBlockCid failed: %v", err)
+	}
+	if err := rb.flush(ctx); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	if has, err := rb.meta.Has(ctx, replicaLRUKey(*c)); err != nil || has {
+		t.Errorf("record of deleted block = %v, %v, want none", has, err)
+	}
+}
+
+func TestReplicaRepository_WriteMode(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("local", func(t *testing.T) {
+		primary, _, replica := setupReplica(t, ReplicaOptions{WriteMode: WriteLocal})
+		c, err := replica.PutBlock(ctx, []byte("local only"))
+		if err != nil {
+			t.Fatalf("PutBlock failed: %v", err)
+		}
+		if has, _ := primary.HasBlock(ctx, c.String()); has {
+			t.Error("WriteLocal should not write to primary")
+		}
+	})
+
+	t.Run("both", func(t *testing.T) {
+		primary, _, replica := setupReplica(t, ReplicaOptions{WriteMode: WriteBoth})
+		cids, err := replica.PutManyBlocks(ctx, [][]byte{[]byte("a"), []byte("b")})
+		if err != nil {
+			t.Fatalf("PutManyBlocks failed: %v", err)
+		}
+		for _, c := range cids {
+			if has, _ := primary.HasBlock(ctx, c.String()); !has {
+				t.Errorf("WriteBoth should write %s to primary", c)
+			}
+			if !hasLocal(t, replica, c.String()) {
+				t.Errorf("WriteBoth should write %s locally", c)
+			}
+		}
+	})
+}
+
+func TestNewReplicaRepository_NilPrimary(t *testing.T) {
+	if _, err := NewReplicaRepository(t.TempDir(), nil, ReplicaOptions{}); err == nil {
+		t.Error("expected error for nil primary")
+	}
+}
@@ -2,35 +2,42 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ipfs/boxo/blockstore"
 	blocks "github.com/ipfs/go-block-format"
 	cid2 "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
 	ipld "github.com/ipfs/go-ipld-format"
-	"github.com/multiformats/go-multicodec"
-	mh "github.com/multiformats/go-multihash"
 	"github.com/tragoedia0722/repository/internal/storage"
+	"github.com/tragoedia0722/repository/pkg/tracing"
 	"golang.org/x/sync/errgroup"
 )
 
 const (
-	// 默认重试配置
-	defaultMaxRetries = 3
-	defaultBaseDelay  = 50 * time.Millisecond
-
 	// 默认目录权限
 	defaultDirPerm = 0o750 // rwxr-x---
+)
 
-	// 默认并发限制
-	defaultMaxConcurrency = 100
+// ErrRepositoryInUse 表示仓库被其他实例打开，Destroy 不能删除它，
+// 设置了 RepoOptions.LockWait 的打开也不能获得锁。
+var ErrRepositoryInUse = storage.ErrInUse
 
-	// 数据块大小限制
-	maxBlockSize = 128 * 1024 * 1024 // 128MB
-)
+// InUseError 表示仓库的锁被其他实例持有，包含锁文件记录的持有者信息
+// （需要持有者启用 LockMetadata）。它包装 ErrRepositoryInUse。
+type InUseError = storage.InUseError
+
+// LockError 表示锁文件相关的错误，不等待锁或等待超时时包装 *InUseError。
+type LockError = storage.LockError
+
+// NoLockWait 作为 RepoOptions.LockWait 时，仓库被其他实例打开立即返回错误。
+const NoLockWait = storage.NoLockWait
 
 // Repository 表示一个 IPFS 风格的内容寻址存储仓库。
 //
@@ -40,11 +47,107 @@ type Repository struct {
 	storage    *storage.Storage
 	blockStore blockstore.Blockstore
 	builder    cid2.Builder
+	foreground atomic.Int64         // 正在进行的前台操作数
+	quota      *quotaBlockstore     // 配额检查，未启用时为 nil
+	limits     RepoLimits           // 实际生效的运行限制
+	reads      *readGroup           // 合并同一块的并发读取
+	hooks      blockHooks           // 数据块变更回调
+	verify     *verifyingBlockstore // 读取校验，未启用 VerifyReads 时为 nil
+	sessions   sync.Mutex           // 串行化 CommitImport 和 AbortImport
+	access     *accessStats         // 块访问统计，未启用 AccessStats 时为 nil
+	tracer     tracing.Tracer       // 调用追踪，未配置 Tracer 时为 nil
+	metrics    MetricsSink          // 块操作的观测值，未调用 WithMetrics 时为 nil
+	life       lifecycle            // 关闭状态和正在进行的操作
+
+	// shards 是分片仓库的所有分片存储，storage 为其中第一个；未分片时为 nil
+	shards []*storage.Storage
+
+	// flush 在关闭存储之前写回内存中的状态，例如副本仓库的缓存访问顺序；没有时为 nil
+	flush func(ctx context.Context) error
+
+	// replica 是副本仓库的读穿透缓存，其他仓库为 nil
+	replica *replicaBlockstore
+}
+
+// RepoOptions 配置仓库。
+type RepoOptions struct {
+	// QuotaBytes 是仓库中数据块的最大总字节数，0 表示不限制。
+	// 超过限制的写入返回 *QuotaExceededError。
+	QuotaBytes int64
+
+	// QuotaSoftPct 是软限制，为 QuotaBytes 的百分比（0-100），0 表示不启用。
+	// 用量达到软限制时调用 WithQuotaWarning 设置的回调。
+	QuotaSoftPct float64
+
+	// RedactPaths 为 true 时，返回的错误信息中的仓库根路径会被替换为 "<repo>"。
+	// 包含完整路径的原始错误仍可通过 errors.As 获取，用于本地日志。
+	RedactPaths bool
+
+	// LockMetadata 为 true 时，在锁文件中写入当前进程的 PID 和获得锁的时间。
+	LockMetadata bool
+
+	// LockHostname 为 true 时，在锁文件中额外写入主机名。仅在 LockMetadata 为 true 时生效。
+	LockHostname bool
+
+	// LockWait 是仓库被其他实例打开时等待锁的最长时间，0（默认）表示一直等待到
+	// ctx 结束，NoLockWait 表示不等待。不再等待时返回包装 *InUseError 的
+	// *LockError，错误信息包含持有者的 PID 和获得锁的时间（需要持有者启用
+	// LockMetadata），例如 "locked by pid 12345 since 14:02:11"。
+	LockWait time.Duration
+
+	// StaleLockTakeover 为 true 时，锁被持有但锁文件记录的进程在本机已不存在
+	// （例如持有者退出后锁被它的子进程继承）时删除锁文件并接管锁。
+	// 只有持有者启用了 LockMetadata 的锁才能被接管；主机名为空的记录被当作本机，
+	// 共享存储上的多台主机应同时启用 LockHostname。
+	StaleLockTakeover bool
+
+	// VerifyMount 为 true 时，每次经过 BlockStore() 的操作前检查仓库目录是否
+	// 仍是打开时的目录（例如 NFS 挂载是否消失或被重新挂载），
+	// 变化后的操作返回包装 ErrRepositoryMoved 的错误。
+	VerifyMount bool
+
+	// VerifyMountTTL 是 VerifyMount 检查结果的缓存时间，0 表示 DefaultVerifyMountTTL。
+	// 目录变化后最迟在一个 TTL 之后的操作中被发现。
+	VerifyMountTTL time.Duration
+
+	// VerifyReads 为 true 时，经过 BlockStore() 的每次读取（包括 GetRawData、
+	// GetRawDataCid 以及导出器）都重新计算块的哈希并与 CID 比较。
+	// 不符的块不会返回给调用者：读取返回 *CorruptBlockError（包装 ErrCorruptBlock），
+	// 块的原始数据被移动到 datastore 的 /quarantine 命名空间下供事后分析，
+	// 之后 HasBlock 返回 false，调用方可以重新获取或修复该块。
+	// 哈希计算使每次读取变慢，见 BenchmarkGetRawData_VerifyReads，默认不启用。
+	VerifyReads bool
+
+	// CleanupOnOpen 为 true 时，打开仓库后调用 CleanupArtifacts 删除
+	// 超过 DefaultCleanupAge 的临时文件残留。清理失败不影响打开，
+	// 需要检查结果时应直接调用 CleanupArtifacts。
+	CleanupOnOpen bool
+
+	// AccessStats 配置块访问统计，SampleRate 为 0（默认）时不启用。
+	// 启用后按比例采样经过 BlockStore() 的命中读取，统计占用固定大小的内存，
+	// 与仓库中块的数量无关。结果通过 AccessStats 获取。
+	AccessStats AccessStatsOptions
+
+	// Tracer 为 PutBlock、GetRawData 和 HasAllBlocks 的每次调用创建 span，
+	// 记录 CID、字节数、重试次数以及读取是否合并到并发调用上。
+	// span 是 ctx 中已有 span 的子 span。nil（默认）时不追踪，也没有额外开销。
+	// 导入器和导出器通过各自的 WithTracer 配置。
+	Tracer tracing.Tracer
+
+	// CidPrefix 是 PutBlock 和 PutManyBlocks 计算 CID 使用的前缀，
+	// 零值表示 CIDv1、dag-pb 编码和 sha2-256。不受支持的前缀在打开时
+	// 返回包装 ErrUnsupportedCidPrefix 的错误。单次写入可以用
+	// PutBlockWithPrefix 和 PutManyBlocksWithPrefix 指定其他前缀。
+	CidPrefix cid2.Prefix
 }
 
 // NewRepository 创建或打开一个仓库实例。
 //
 // 如果仓库目录不存在，会自动创建。目录权限设置为 0o750（rwxr-x---）。
+// 需要配额等配置时使用 NewRepositoryWithOptions。
+//
+// 磁盘格式较旧的仓库返回包装 ErrMigrationRequired 的错误，需要先调用 Upgrade；
+// 版本高于本库支持的仓库返回包装 ErrVersionTooNew 的错误。
 //
 // 参数：
 //
@@ -55,6 +158,123 @@ type Repository struct {
 //	*Repository - 仓库实例
 //	error - 如果创建失败，返回错误
 func NewRepository(path string) (*Repository, error) {
+	return NewRepositoryWithOptions(path, RepoOptions{})
+}
+
+// NewRepositoryWithOptions 使用指定配置创建或打开一个仓库实例。
+//
+// 启用配额时，所有经过 BlockStore() 的写入（包括 PutBlock、PutBlockWithCid、
+// PutManyBlocks 以及导入器）都会检查配额。已用字节数在写入和删除时增量维护，
+// 并保存在 datastore 中；首次启用配额时会统计现有数据块进行初始化。
+// 未启用配额时的写入不会更新计数，之后重新启用配额时应调用 ReconcileQuota。
+//
+// 启用 RedactPaths 时，仓库及其 BlockStore() 返回的错误信息不包含仓库根路径。
+//
+// 启用 VerifyMount 时，仓库目录在打开后被删除、替换或重新挂载，
+// 之后的块操作返回包装 ErrRepositoryMoved 的错误，而不是写入错误的文件系统。
+//
+// 启用 VerifyReads 时，读取到的损坏块被隔离，读取返回包装 ErrCorruptBlock 的错误。
+//
+// 启用 CleanupOnOpen 时，打开后删除其他组件崩溃后留在仓库目录中的临时文件。
+//
+// 参数：
+//
+//	path - 仓库路径
+//	opts - 仓库配置
+//
+// 返回：
+//
+//	*Repository - 仓库实例
+//	error - 如果创建失败，返回错误
+func NewRepositoryWithOptions(path string, opts RepoOptions) (*Repository, error) {
+	return NewRepositoryWithContext(context.Background(), path, opts)
+}
+
+// NewRepositoryWithContext 与 NewRepositoryWithOptions 相同，支持上下文控制。
+//
+// 仓库被其他实例持有锁时等待其释放，ctx 结束时返回 ctx.Err()。
+// 首次启用配额时的块统计同样响应 ctx。datastore 的打开不可中断，
+// ctx 在此期间结束时打开完成后再返回。
+//
+// 参数：
+//
+//	ctx - 用于取消操作或设置超时的上下文
+//	path - 仓库路径
+//	opts - 仓库配置
+//
+// 返回：
+//
+//	*Repository - 仓库实例
+//	error - 如果创建失败或上下文取消，返回错误
+func NewRepositoryWithContext(ctx context.Context, path string, opts RepoOptions) (*Repository, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	s, err := openStorage(ctx, path, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Repository{
+		storage:    s,
+		blockStore: blockstore.NewBlockstore(s.Datastore()),
+		builder:    opts.cidBuilder(),
+		limits:     defaultLimits(),
+		reads:      newReadGroup(),
+		access:     newAccessStats(opts.AccessStats),
+		tracer:     opts.Tracer,
+	}
+	r.limits.QuotaBytes = opts.QuotaBytes
+
+	if opts.QuotaBytes > 0 {
+		q := &quotaBlockstore{
+			Blockstore: r.blockStore,
+			meta:       s.Datastore(),
+			limit:      opts.QuotaBytes,
+			soft:       int64(float64(opts.QuotaBytes) * opts.QuotaSoftPct / 100),
+		}
+		if err := q.load(ctx); err != nil {
+			_ = r.Close()
+			return nil, fmt.Errorf("failed to load quota usage: %w", s.RedactError(err))
+		}
+		r.blockStore = q
+		r.quota = q
+	}
+
+	if opts.VerifyReads {
+		r.blockStore = r.verifyBlockstore(r.blockStore, func(cid2.Cid) ds.Datastore { return s.Datastore() })
+	}
+
+	r.blockStore = guardBlockstore(r.watchBlockstore(r.accessBlockstore(r.blockStore)), s, opts)
+	return r.cleanupOnOpen(ctx, opts)
+}
+
+// validate 检查配置是否有效。
+func (opts RepoOptions) validate() error {
+	if opts.QuotaBytes < 0 {
+		return fmt.Errorf("quota cannot be negative: %d", opts.QuotaBytes)
+	}
+	if opts.QuotaSoftPct < 0 || opts.QuotaSoftPct > 100 {
+		return fmt.Errorf("quota soft limit must be between 0 and 100 percent: %v", opts.QuotaSoftPct)
+	}
+	if opts.LockWait < 0 && opts.LockWait != NoLockWait {
+		return fmt.Errorf("lock wait cannot be negative: %v", opts.LockWait)
+	}
+	if opts.VerifyMountTTL < 0 {
+		return fmt.Errorf("mount verification TTL cannot be negative: %v", opts.VerifyMountTTL)
+	}
+	if err := opts.AccessStats.validate(); err != nil {
+		return err
+	}
+	if opts.CidPrefix != (cid2.Prefix{}) {
+		return validatePrefix(opts.CidPrefix)
+	}
+	return nil
+}
+
+// openStorage 创建仓库目录（如果不存在）并打开其中的存储。
+func openStorage(ctx context.Context, path string, opts RepoOptions) (*storage.Storage, error) {
 	// 验证路径不为空
 	if path == "" {
 		return nil, fmt.Errorf("repository path cannot be empty")
@@ -65,60 +285,260 @@ func NewRepository(path string) (*Repository, error) {
 
 	// 使用安全的默认权限创建目录
 	if err := os.MkdirAll(path, defaultDirPerm); err != nil {
+		if opts.RedactPaths {
+			err = storage.Redact(err, path)
+		}
 		return nil, fmt.Errorf("failed to create repository directory: %w", err)
 	}
 
-	s, err := storage.NewStorage(path)
+	s, err := storage.NewStorageWithOptions(ctx, path, storage.Options{
+		RedactPaths:       opts.RedactPaths,
+		LockMetadata:      opts.LockMetadata,
+		LockHostname:      opts.LockHostname,
+		LockWait:          opts.LockWait,
+		StaleLockTakeover: opts.StaleLockTakeover,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create storage: %w", err)
 	}
+	return s, nil
+}
 
-	return &Repository{
-		storage:    s,
-		blockStore: blockstore.NewBlockstore(s.Datastore()),
-		builder: cid2.V1Builder{
-			Codec:    uint64(multicodec.DagPb),
-			MhType:   mh.SHA2_256,
-			MhLength: -1,
-		},
-	}, nil
+// guardBlockstore 按配置为 s 上的 bs 加上挂载检查和错误信息脱敏。
+func guardBlockstore(bs blockstore.Blockstore, s *storage.Storage, opts RepoOptions) blockstore.Blockstore {
+	if opts.VerifyMount {
+		ttl := opts.VerifyMountTTL
+		if ttl == 0 {
+			ttl = DefaultVerifyMountTTL
+		}
+		bs = &mountCheckBlockstore{Blockstore: bs, storage: s, ttl: ttl}
+	}
+
+	if opts.RedactPaths {
+		bs = &redactingBlockstore{Blockstore: bs, storage: s}
+	}
+	return bs
 }
 
-// BlockStore 返回底层 blockstore。
+// BlockStore 返回底层 blockstore。blockstore 在打开仓库时已经创建，
+// BlockStore 不会阻塞。
 // 注意：调用者不应该关闭或修改返回的 blockstore。
 func (r *Repository) BlockStore() blockstore.Blockstore {
 	return r.blockStore
 }
 
-// DataStore 返回底层数据存储。
+// DataStore 返回底层数据存储。分片仓库返回第一个分片的数据存储。
 func (r *Repository) DataStore() storage.Datastore {
 	return r.storage.Datastore()
 }
 
-// Usage 返回存储使用情况（字节数）。
+// Usage 返回存储使用情况（字节数）。分片仓库返回所有分片的用量之和。
+// 结果与 UsageDetail 的 TotalBytes 相同，分项统计使用 UsageDetail。
 func (r *Repository) Usage(ctx context.Context) (uint64, error) {
-	return r.storage.GetStorageUsage(ctx)
+	if err := r.life.enter(); err != nil {
+		return 0, err
+	}
+	defer r.life.leave()
+
+	var total uint64
+	for _, s := range r.storages() {
+		usage, err := s.GetStorageUsage(ctx)
+		if err != nil {
+			return 0, err
+		}
+		total += usage
+	}
+	return total, nil
+}
+
+// UsageDetail 是仓库存储使用情况的分项统计。
+type UsageDetail struct {
+	// BlocksBytes 是数据块存储（/blocks 挂载点，flatfs）使用的字节数
+	BlocksBytes uint64
+	// MetadataBytes 是元数据存储（/ 挂载点，leveldb）使用的字节数，包括 leveldb 自身的开销
+	MetadataBytes uint64
+	// BlockCount 是仓库中数据块的数量
+	BlockCount uint64
+	// TotalBytes 是所有存储使用的字节数，与 Usage 的结果相同
+	TotalBytes uint64
+}
+
+// UsageDetail 返回按组成部分统计的存储使用情况。分片仓库返回所有分片之和。
+//
+// 字节数来自每个挂载点的 datastore 的磁盘用量；BlockCount 需要遍历所有数据块，
+// 比 Usage 慢得多。内存仓库的 MetadataBytes 为 0，TotalBytes 为数据块的字节数之和。
+//
+// 参数：
+//
+//	ctx - 用于取消操作的上下文
+//
+// 返回：
+//
+//	UsageDetail - 分项统计
+//	error - 如果统计失败或上下文取消，返回错误
+func (r *Repository) UsageDetail(ctx context.Context) (UsageDetail, error) {
+	if err := r.life.enter(); err != nil {
+		return UsageDetail{}, err
+	}
+	defer r.life.leave()
+
+	var detail UsageDetail
+	for _, s := range r.storages() {
+		usage, err := s.MountUsage(ctx)
+		if err != nil {
+			return UsageDetail{}, err
+		}
+		for mount, bytes := range usage {
+			switch mount {
+			case blockstore.BlockPrefix.String():
+				detail.BlocksBytes += bytes
+			case "/":
+				detail.MetadataBytes += bytes
+			}
+			detail.TotalBytes += bytes
+		}
+	}
+
+	err := r.ForEachKey(ctx, func(cid2.Cid) error {
+		detail.BlockCount++
+		return nil
+	})
+	if err != nil {
+		return UsageDetail{}, err
+	}
+	return detail, nil
 }
 
 // Close 关闭仓库并释放资源。
 //
+// Close 之后开始的数据块操作、导入会话、CAR、用量和配额等访问存储的操作
+// 返回 ErrClosed。Close 先等待已经开始的操作结束，再关闭 datastore。
 // Close 是幂等的，多次调用不会返回错误。
+// 此方法使用 context.Background()。如果需要超时或取消控制，请使用 CloseWithContext。
 func (r *Repository) Close() error {
-	if r.storage == nil {
-		return nil
+	return r.CloseWithContext(context.Background())
+}
+
+// CloseWithContext 关闭仓库并释放资源，支持上下文控制。
+//
+// 等待进行中的操作和 datastore 的关闭都不可中断。ctx 先结束时立即返回
+// ctx.Err()，关闭在后台继续，完成后才释放锁；之后的 Close 或 Destroy 会等待它完成。
+//
+// 参数：
+//
+//	ctx - 用于取消操作或设置超时的上下文
+//
+// 返回：
+//
+//	error - 如果关闭失败或上下文取消，返回错误
+func (r *Repository) CloseWithContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-	return r.storage.Close()
+
+	drained := r.life.close()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		// 进行中的操作结束后在后台关闭
+		go func() {
+			<-drained
+			_ = r.closeStorages(context.Background())
+		}()
+		return ctx.Err()
+	}
+	return r.closeStorages(ctx)
+}
+
+// closeStorages 关闭仓库使用的所有存储。
+func (r *Repository) closeStorages(ctx context.Context) error {
+	var errs []error
+	if r.flush != nil {
+		errs = append(errs, r.flush(ctx))
+	}
+	for _, s := range r.storages() {
+		errs = append(errs, s.CloseWithContext(ctx))
+	}
+	return errors.Join(errs...)
 }
 
 // Destroy 销毁仓库并删除所有数据。
 //
+// 先关闭 datastore，再删除数据，最后释放并删除锁文件。已关闭的实例先确认
+// 没有其他实例（同一进程或其他进程）打开了同一仓库；仓库正在被使用时返回
+// *InUseError（包装 ErrRepositoryInUse），不删除任何内容，这种情况下可以
+// 等待其他实例关闭，或者在确定持有者已不存在时使用 ForceDestroy。
+//
 // 此操作不可逆，请谨慎使用。
-// Destroy 是幂等的，多次调用不会返回错误。
+// Destroy 是幂等的，多次调用不会返回错误；中途失败后可以重新调用。
+// 此方法使用 context.Background()。如果需要超时或取消控制，请使用 DestroyWithContext。
 func (r *Repository) Destroy() error {
+	return r.DestroyWithContext(context.Background())
+}
+
+// DestroyWithContext 与 Destroy 相同，支持上下文控制。
+//
+// 与 Close 一样先等待进行中的操作结束，ctx 在此之前结束时返回 ctx.Err()，
+// 不删除任何内容；调用时 ctx 已经结束则仓库保持可用，否则之后的操作返回 ErrClosed。删除过程中每删除一个文件或目录前检查 ctx，ctx 结束时释放锁
+// 并返回 ctx.Err()。已删除的数据不会恢复，可以重新调用 Destroy 继续删除。
+// datastore 的关闭不可中断。
+//
+// 参数：
+//
+//	ctx - 用于取消操作或设置超时的上下文
+//
+// 返回：
+//
+//	error - 如果销毁失败或上下文取消，返回错误
+func (r *Repository) DestroyWithContext(ctx context.Context) error {
+	if err := r.drain(ctx); err != nil {
+		return err
+	}
+	var errs []error
+	for _, s := range r.storages() {
+		errs = append(errs, s.DestroyWithContext(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// ForceDestroy 销毁仓库并删除所有数据，不检查其他实例是否打开了仓库。
+//
+// 仅用于确定锁的持有者已不存在的情况；仍在使用仓库的实例会在数据文件
+// 被删除后出错。此操作不可逆，请谨慎使用。
+// 此方法使用 context.Background()。如果需要超时或取消控制，请使用 ForceDestroyWithContext。
+func (r *Repository) ForceDestroy() error {
+	return r.ForceDestroyWithContext(context.Background())
+}
+
+// ForceDestroyWithContext 与 ForceDestroy 相同，支持上下文控制。
+//
+// 参数：
+//
+//	ctx - 用于取消操作或设置超时的上下文
+//
+// 返回：
+//
+//	error - 如果销毁失败或上下文取消，返回错误
+func (r *Repository) ForceDestroyWithContext(ctx context.Context) error {
+	if err := r.drain(ctx); err != nil {
+		return err
+	}
+	var errs []error
+	for _, s := range r.storages() {
+		errs = append(errs, s.ForceDestroyWithContext(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// storages 返回仓库使用的所有存储。
+func (r *Repository) storages() []*storage.Storage {
+	if r.shards != nil {
+		return r.shards
+	}
 	if r.storage == nil {
 		return nil
 	}
-	return r.storage.Destroy()
+	return []*storage.Storage{r.storage}
 }
 
 // PutBlock 存储单个数据块并返回其 CID。
@@ -131,14 +551,40 @@ func (r *Repository) Destroy() error {
 // 返回：
 //
 //	*cid2.Cid - 数据块的 CID
-//	error - 如果存储失败，返回错误
+//	error - 如果存储失败，返回错误；数据块过大时为 *BlockTooLargeError，仓库已关闭时为 ErrClosed
 func (r *Repository) PutBlock(ctx context.Context, bytes []byte) (*cid2.Cid, error) {
+	return r.putBlock(ctx, bytes, r.builder)
+}
+
+// putBlock 使用 builder 计算 CID 并存储单个数据块。
+func (r *Repository) putBlock(ctx context.Context, bytes []byte, builder cid2.Builder) (c *cid2.Cid, err error) {
+	if r.tracer != nil {
+		var span tracing.Span
+		ctx, span = r.tracer.StartSpan(ctx, tracing.SpanPutBlock)
+		defer func() {
+			span.SetAttributes(tracing.Int(tracing.AttrBytes, len(bytes)))
+			if c != nil {
+				span.SetAttributes(tracing.String(tracing.AttrCID, c.String()))
+			}
+			tracing.End(span, err)
+		}()
+	}
+
+	if err := r.life.enter(); err != nil {
+		return nil, err
+	}
+	defer r.life.leave()
+	if r.metrics != nil {
+		start := time.Now()
+		defer func() { r.metrics.ObservePut(len(bytes), time.Since(start), err) }()
+	}
+
 	// 验证数据大小
-	if len(bytes) > maxBlockSize {
-		return nil, fmt.Errorf("block size %d bytes exceeds maximum %d bytes", len(bytes), maxBlockSize)
+	if err := r.checkBlockSize(len(bytes)); err != nil {
+		return nil, err
 	}
 
-	sum, err := r.builder.Sum(bytes)
+	sum, err := builder.Sum(bytes)
 	if err != nil {
 		return nil, fmt.Errorf("failed to calculate CID: %w", err)
 	}
@@ -166,10 +612,19 @@ func (r *Repository) PutBlock(ctx context.Context, bytes []byte) (*cid2.Cid, err
 // 返回：
 //
 //	error - 如果存储失败，返回错误
-func (r *Repository) PutBlockWithCid(ctx context.Context, cid string, bytes []byte) error {
+func (r *Repository) PutBlockWithCid(ctx context.Context, cid string, bytes []byte) (err error) {
+	if err := r.life.enter(); err != nil {
+		return err
+	}
+	defer r.life.leave()
+	if r.metrics != nil {
+		start := time.Now()
+		defer func() { r.metrics.ObservePut(len(bytes), time.Since(start), err) }()
+	}
+
 	// 验证数据大小
-	if len(bytes) > maxBlockSize {
-		return fmt.Errorf("block size %d bytes exceeds maximum %d bytes", len(bytes), maxBlockSize)
+	if err := r.checkBlockSize(len(bytes)); err != nil {
+		return err
 	}
 
 	c, err := r.parseCID(cid)
@@ -201,6 +656,22 @@ func (r *Repository) PutBlockWithCid(ctx context.Context, cid string, bytes []by
 //	[]*cid2.Cid - CID 列表
 //	error - 如果存储失败，返回错误
 func (r *Repository) PutManyBlocks(ctx context.Context, bytes [][]byte) ([]*cid2.Cid, error) {
+	return r.putManyBlocks(ctx, bytes, r.builder)
+}
+
+// putManyBlocks 使用 builder 计算 CID 并批量存储数据块。
+func (r *Repository) putManyBlocks(ctx context.Context, bytes [][]byte, builder cid2.Builder) (_ []*cid2.Cid, err error) {
+	if err := r.life.enter(); err != nil {
+		return nil, err
+	}
+	defer r.life.leave()
+	if r.metrics != nil {
+		start := time.Now()
+		defer func() {
+			r.observePutBatch(len(bytes), func(i int) int { return len(bytes[i]) }, time.Since(start), err)
+		}()
+	}
+
 	if len(bytes) == 0 {
 		return nil, nil
 	}
@@ -218,12 +689,11 @@ func (r *Repository) PutManyBlocks(ctx context.Context, bytes [][]byte) ([]*cid2
 		}
 
 		// 验证数据大小
-		if len(b) > maxBlockSize {
-			return nil, fmt.Errorf("block at index %d: size %d bytes exceeds maximum %d bytes",
-				i, len(b), maxBlockSize)
+		if err := r.checkBlockSize(len(b)); err != nil {
+			return nil, fmt.Errorf("block at index %d: %w", i, err)
 		}
 
-		sum, err := r.builder.Sum(b)
+		sum, err := builder.Sum(b)
 		if err != nil {
 			return nil, fmt.Errorf("failed to calculate CID at index %d: %w", i, err)
 		}
@@ -260,13 +730,37 @@ func (r *Repository) HasBlock(ctx context.Context, cid string) (bool, error) {
 		return false, err
 	}
 
+	return r.HasBlockCid(ctx, c)
+}
+
+// HasBlockCid 检查指定 CID 的块是否存在，调用方已持有解析后的 CID 时使用。
+//
+// 参数：
+//
+//	ctx - 用于取消操作的上下文
+//	c - CID
+//
+// 返回：
+//
+//	bool - 如果块存在返回 true，否则返回 false
+//	error - 如果检查失败，返回错误
+func (r *Repository) HasBlockCid(ctx context.Context, c cid2.Cid) (has bool, err error) {
+	if err := r.life.enter(); err != nil {
+		return false, err
+	}
+	defer r.life.leave()
+	if r.metrics != nil {
+		start := time.Now()
+		defer func() { r.metrics.ObserveHas(has, time.Since(start), err) }()
+	}
+
 	return r.blockStore.Has(ctx, c)
 }
 
 // HasAllBlocks 检查所有指定的 CID 是否都存在。
 //
-// 使用并发检查以提高性能，最多同时运行 100 个 goroutine。
-// 每个 goroutine 都有 panic 恢复机制，防止单个失败导致整个程序崩溃。
+// 所有 CID 先被解析，有无效的 CID 时返回列出全部无效项的 *InvalidCIDsError；
+// 之后的检查与 HasAllBlockCids 相同。需要知道缺少哪些块时使用 MissingBlocks。
 //
 // 参数：
 //
@@ -278,32 +772,184 @@ func (r *Repository) HasBlock(ctx context.Context, cid string) (bool, error) {
 //	bool - 如果所有块都存在返回 true，否则返回 false
 //	error - 如果检查失败，返回错误
 func (r *Repository) HasAllBlocks(ctx context.Context, cids []string) (bool, error) {
+	parsed, err := r.parseCIDs(cids)
+	if err != nil {
+		return false, err
+	}
+
+	return r.HasAllBlockCids(ctx, parsed)
+}
+
+// HasAllBlockCids 检查所有指定的 CID 是否都存在，即 MissingBlockCids 的结果为空。
+//
+// 使用并发检查以提高性能，最多同时运行 100 个 goroutine。
+// 每个 goroutine 都有 panic 恢复机制，防止单个失败导致整个程序崩溃。
+//
+// 参数：
+//
+//	ctx - 用于取消操作的上下文
+//	cids - CID 列表
+//
+// 返回：
+//
+//	bool - 如果所有块都存在返回 true，否则返回 false
+//	error - 如果检查失败，返回错误
+func (r *Repository) HasAllBlockCids(ctx context.Context, cids []cid2.Cid) (found bool, err error) {
+	if r.tracer != nil {
+		var span tracing.Span
+		ctx, span = r.tracer.StartSpan(ctx, tracing.SpanHasAllBlocks)
+		defer func() {
+			span.SetAttributes(tracing.Int(tracing.AttrBlocks, len(cids)), tracing.Bool(tracing.AttrFound, found))
+			tracing.End(span, err)
+		}()
+	}
+
+	missing, err := r.missingBlocks(ctx, cids)
+	if err != nil {
+		return false, err
+	}
+	return len(missing) == 0, nil
+}
+
+// MissingBlocks 返回指定 CID 中不存在的块。
+//
+// 检查的并发数与 HasAllBlocks 相同。重复的 CID 只检查一次，缺失时只在第一次
+// 出现的位置列出。重复按解析后的 CID 判断，同一 CID 的不同字符串编码（例如
+// CIDv1 的 base32 和 base58 形式）也被视为重复，结果中使用第一次出现的字符串。所有 CID 先被解析，有无效的 CID 时返回列出全部无效项的
+// *InvalidCIDsError（包装 ErrInvalidCID），不做任何检查。
+//
+// 参数：
+//
+//	ctx - 用于取消操作的上下文
+//	cids - CID 字符串列表
+//
+// 返回：
+//
+//	[]string - 不存在的 CID，保持输入顺序并去重；全部存在时为空
+//	error - 如果 CID 无效或检查失败，返回错误
+func (r *Repository) MissingBlocks(ctx context.Context, cids []string) ([]string, error) {
+	parsed, err := r.parseCIDs(cids)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes, err := r.missingBlocks(ctx, parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, i := range indexes {
+		missing = append(missing, cids[i])
+	}
+	return missing, nil
+}
+
+// MissingBlockCids 返回指定 CID 中不存在的块，重复的 CID 只检查和列出一次。
+//
+// 参数：
+//
+//	ctx - 用于取消操作的上下文
+//	cids - CID 列表
+//
+// 返回：
+//
+//	[]cid2.Cid - 不存在的 CID，保持输入顺序并去重；全部存在时为空
+//	error - 如果检查失败，返回错误
+func (r *Repository) MissingBlockCids(ctx context.Context, cids []cid2.Cid) ([]cid2.Cid, error) {
+	indexes, err := r.missingBlocks(ctx, cids)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []cid2.Cid
+	for _, i := range indexes {
+		missing = append(missing, cids[i])
+	}
+	return missing, nil
+}
+
+// missingBlocks 检查 cids 中每个不同的 CID 是否存在，返回不存在的 CID
+// 第一次出现的位置，按输入顺序排列。
+func (r *Repository) missingBlocks(ctx context.Context, cids []cid2.Cid) ([]int, error) {
+	// 每个 CID 第一次出现的位置
+	first := make([]int, 0, len(cids))
+	seen := make(map[cid2.Cid]struct{}, len(cids))
+	for i, c := range cids {
+		if _, ok := seen[c]; ok {
+			continue
+		}
+		seen[c] = struct{}{}
+		first = append(first, i)
+	}
+
+	unique := cids
+	if len(first) < len(cids) {
+		unique = make([]cid2.Cid, len(first))
+		for j, i := range first {
+			unique[j] = cids[i]
+		}
+	}
+
+	results, err := r.checkBlocks(ctx, unique)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []int
+	for j, has := range results {
+		if !has {
+			missing = append(missing, first[j])
+		}
+	}
+	return missing, nil
+}
+
+// checkBlocks 并发检查每个 CID 是否存在。
+//
+// 最多同时运行 Limits().HasCheckConcurrency 个 goroutine，每个 goroutine 都有 panic 恢复机制。
+// 错误信息中包含 CID 字符串，便于日志排查。
+//
+// 参数：
+//
+//	ctx - 用于取消操作的上下文
+//	cids - CID 列表
+//
+// 返回：
+//
+//	[]bool - 与 cids 一一对应的存在状态
+//	error - 如果检查失败，返回错误
+func (r *Repository) checkBlocks(ctx context.Context, cids []cid2.Cid) (_ []bool, err error) {
+	if err := r.life.enter(); err != nil {
+		return nil, err
+	}
+	defer r.life.leave()
+	if r.metrics != nil {
+		start := time.Now()
+		defer func() { r.metrics.ObserveBatch(BlockOpHas, len(cids), 0, time.Since(start), err) }()
+	}
+
+	results := make([]bool, len(cids))
 	if len(cids) == 0 {
-		return true, nil
+		return results, nil
 	}
 
 	g, ctx := errgroup.WithContext(ctx)
-	g.SetLimit(defaultMaxConcurrency) // 限制并发数
-	results := make([]bool, len(cids))
+	g.SetLimit(r.limits.HasCheckConcurrency) // 限制并发数
 
-	for i, cidStr := range cids {
-		i, cidStr := i, cidStr // 避免闭包问题
+	for i, c := range cids {
+		i, c := i, c // 避免闭包问题
 		g.Go(func() (err error) {
 			// 添加 panic 恢复机制
 			defer func() {
 				if r := recover(); r != nil {
-					err = fmt.Errorf("panic checking block %s: %v", cidStr, r)
+					err = fmt.Errorf("panic checking block %s: %v", c, r)
 				}
 			}()
 
-			c, err := r.parseCID(cidStr)
-			if err != nil {
-				return err
-			}
-
-			has, err := r.blockStore.Has(ctx, c)
+			has, err := r.hasBlock(ctx, c)
 			if err != nil {
-				return fmt.Errorf("failed to check block %s: %w", cidStr, err)
+				return fmt.Errorf("failed to check block %s: %w", c, err)
 			}
 
 			results[i] = has
@@ -312,24 +958,109 @@ func (r *Repository) HasAllBlocks(ctx context.Context, cids []string) (bool, err
 	}
 
 	if err := g.Wait(); err != nil {
-		return false, err
+		return nil, err
 	}
 
-	// 检查是否全部存在
-	for _, has := range results {
-		if !has {
-			return false, nil
+	return results, nil
+}
+
+// GetManyRawData 并发获取多个 CID 的原始数据，例如一个包中的全部块。
+//
+// 并发数与 HasAllBlocks 相同，受 HasCheckConcurrency 限制。每个块只读取
+// 一次、不重试；不存在的块记入缺失列表，不会中止其余的读取。其他读取
+// 错误（例如 I/O 错误或启用 VerifyReads 时的 ErrCorruptBlock）不会被当作
+// 缺失，而是中止读取并返回，以免把故障的磁盘误认为缺少块。
+// 重复的 CID 只读取一次。
+//
+// 参数：
+//
+//	ctx - 用于取消操作的上下文
+//	cids - CID 字符串列表
+//
+// 返回：
+//
+//	map[string][]byte - 找到的块数据，以输入的 CID 字符串为键
+//	[]string - 缺失的 CID，保持输入顺序；全部找到时为空
+//	error - CID 无效、读取失败或上下文取消时返回错误
+func (r *Repository) GetManyRawData(ctx context.Context, cids []string) (_ map[string][]byte, _ []string, err error) {
+	parsed, err := r.parseCIDs(cids)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := r.life.enter(); err != nil {
+		return nil, nil, err
+	}
+	defer r.life.leave()
+
+	var data [][]byte // 与 cids 一一对应的块数据，只有每个 CID 第一次出现的位置被填充
+	var unique int    // 不同 CID 的个数
+	if r.metrics != nil {
+		start := time.Now()
+		defer func() {
+			size := 0
+			for _, d := range data {
+				size += len(d)
+			}
+			r.metrics.ObserveBatch(BlockOpGet, unique, size, time.Since(start), err)
+		}()
+	}
+
+	data = make([][]byte, len(cids))
+	ok := make([]bool, len(cids))
+	first := make(map[string]int, len(cids)) // 每个 CID 第一次出现的位置
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(r.limits.HasCheckConcurrency) // 限制并发数
+	for i, c := range parsed {
+		if _, seen := first[cids[i]]; seen {
+			continue
 		}
+		first[cids[i]] = i
+		unique++
+
+		g.Go(func() (err error) {
+			// 添加 panic 恢复机制
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("panic getting block %s: %v", c, r)
+				}
+			}()
+
+			blk, err := r.getBlock(gctx, c)
+			if ipld.IsNotFound(err) {
+				// 只有块不存在才算缺失
+				return nil
+			}
+			if err != nil {
+				if gctx.Err() != nil {
+					return gctx.Err()
+				}
+				return fmt.Errorf("failed to get block %s: %w", c, err)
+			}
+			data[i], ok[i] = blk.RawData(), true
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
 	}
 
-	return true, nil
+	found := make(map[string][]byte, len(first))
+	var missing []string
+	for _, s := range cids {
+		if j := first[s]; ok[j] {
+			found[s] = data[j]
+		} else {
+			missing = append(missing, s)
+		}
+	}
+	return found, missing, nil
 }
 
 // GetRawData 获取指定 CID 的原始数据，支持指数退避重试。
 //
-// 使用指数退避策略（50ms → 100ms → 200ms）以提高响应速度。
-// 最坏情况下延迟 350ms，而非原来的 1500ms。
-//
 // 参数：
 //
 //	ctx - 用于取消操作的上下文
@@ -345,34 +1076,113 @@ func (r *Repository) GetRawData(ctx context.Context, cid string) ([]byte, error)
 		return nil, err
 	}
 
-	var lastErr error
-	for retry := 0; retry < defaultMaxRetries; retry++ {
+	return r.GetRawDataCid(ctx, c)
+}
+
+// GetRawDataCid 获取指定 CID 的原始数据，支持指数退避重试。
+//
+// 使用指数退避策略（50ms → 100ms → 200ms）以提高响应速度。
+// 最坏情况下延迟 350ms，而非原来的 1500ms。
+//
+// 同一 CID 的并发调用共享一次读取和重试，各自得到独立的数据副本。
+// 某个调用者取消不影响其他调用者。
+//
+// 启用 VerifyReads 时，数据与 CID 不符的块不会重试，直接返回包装
+// ErrCorruptBlock 的错误，该块已被隔离。
+//
+// 重试后块仍不存在时返回包装 *BlockNotFoundError 的错误；仓库已关闭时返回 ErrClosed。
+//
+// 参数：
+//
+//	ctx - 用于取消操作的上下文
+//	c - CID
+//
+// 返回：
+//
+//	[]byte - 原始数据
+//	error - 如果获取失败，返回错误
+func (r *Repository) GetRawDataCid(ctx context.Context, c cid2.Cid) (data []byte, err error) {
+	if err := r.life.enter(); err != nil {
+		return nil, err
+	}
+	defer r.life.leave()
+	if r.metrics != nil {
+		start := time.Now()
+		defer func() { r.metrics.ObserveGet(len(data), time.Since(start), err) }()
+	}
+
+	if r.tracer == nil {
+		data, _, err := r.reads.do(ctx, c, func(ctx context.Context) ([]byte, error) {
+			return r.getWithRetry(ctx, c, nil)
+		})
+		return data, err
+	}
+
+	// 被取消的调用者不等待读取结束，重试次数需要原子地读取
+	ctx, span := r.tracer.StartSpan(ctx, tracing.SpanGetRawData)
+	var retries atomic.Int64
+	data, coalesced, err := r.reads.do(ctx, c, func(ctx context.Context) ([]byte, error) {
+		return r.getWithRetry(ctx, c, &retries)
+	})
+	span.SetAttributes(
+		tracing.String(tracing.AttrCID, c.String()),
+		tracing.Int(tracing.AttrBytes, len(data)),
+		tracing.Int64(tracing.AttrRetries, retries.Load()),
+		tracing.Bool(tracing.AttrCacheHit, coalesced),
+	)
+	tracing.End(span, err)
+	return data, err
+}
+
+// getWithRetry 读取块数据，块不存在时按指数退避重试。
+// retries 不为 nil 时记录重试的次数。
+func (r *Repository) getWithRetry(ctx context.Context, c cid2.Cid, retries *atomic.Int64) ([]byte, error) {
+	var data []byte
+	err := r.retryNotFound(ctx, c, retries, func() error {
 		blk, err := r.blockStore.Get(ctx, c)
 		if err == nil {
-			return blk.RawData(), nil
+			data = blk.RawData()
+		}
+		return err
+	})
+	return data, err
+}
+
+// retryNotFound 执行块的读取 get，块不存在时按指数退避重试。
+// retries 不为 nil 时记录重试的次数。
+func (r *Repository) retryNotFound(ctx context.Context, c cid2.Cid, retries *atomic.Int64, get func() error) error {
+	var lastErr error
+	attempts := r.limits.GetRetryAttempts
+	for retry := 0; retry < attempts; retry++ {
+		if retries != nil {
+			retries.Store(int64(retry))
+		}
+		err := get()
+		if err == nil {
+			return nil
 		}
 
 		lastErr = err
 		if !ipld.IsNotFound(err) {
-			return nil, fmt.Errorf("failed to get block: %w", err)
+			return fmt.Errorf("failed to get block %s: %w", c, err)
 		}
 
 		// 如果不是最后一次重试，使用指数退避
-		if retry < defaultMaxRetries-1 {
-			delay := defaultBaseDelay * time.Duration(1<<uint(retry))
+		if retry < attempts-1 {
+			delay := r.limits.GetRetryBaseDelay * time.Duration(1<<uint(retry))
 			select {
 			case <-time.After(delay):
 			case <-ctx.Done():
-				return nil, ctx.Err()
+				return ctx.Err()
 			}
 		}
 	}
 
 	// 根据最后错误类型返回更准确的消息
 	if ipld.IsNotFound(lastErr) {
-		return nil, fmt.Errorf("block not found after %d retries", defaultMaxRetries)
+		return fmt.Errorf("%w after %d retries", &BlockNotFoundError{Cid: c}, attempts)
 	}
-	return nil, fmt.Errorf("failed to get block after %d retries: %w", defaultMaxRetries, lastErr)
+	return fmt.Errorf("failed to get block %s after %d retries: %w", c, attempts, lastErr)
 }
 
 // DelBlock 删除指定 CID 的块。
@@ -391,8 +1201,31 @@ func (r *Repository) DelBlock(ctx context.Context, cid string) error {
 		return err
 	}
 
+	return r.DelBlockCid(ctx, c)
+}
+
+// DelBlockCid 删除指定 CID 的块。
+//
+// 参数：
+//
+//	ctx - 用于取消操作的上下文
+//	c - CID
+//
+// 返回：
+//
+//	error - 如果删除失败，返回错误
+func (r *Repository) DelBlockCid(ctx context.Context, c cid2.Cid) (err error) {
+	if err := r.life.enter(); err != nil {
+		return err
+	}
+	defer r.life.leave()
+	if r.metrics != nil {
+		start := time.Now()
+		defer func() { r.metrics.ObserveDelete(time.Since(start), err) }()
+	}
+
 	if err := r.blockStore.DeleteBlock(ctx, c); err != nil {
-		return fmt.Errorf("failed to delete block: %w", err)
+		return fmt.Errorf("failed to delete block %s: %w", c, err)
 	}
 
 	return nil
@@ -413,7 +1246,42 @@ func (r *Repository) DelBlock(ctx context.Context, cid string) error {
 func (r *Repository) parseCID(cidStr string) (cid2.Cid, error) {
 	c, err := cid2.Parse(cidStr)
 	if err != nil {
-		return cid2.Cid{}, fmt.Errorf("invalid CID %q: %w", cidStr, err)
+		return cid2.Cid{}, fmt.Errorf("%w %q: %w", ErrInvalidCID, cidStr, err)
 	}
 	return c, nil
 }
+
+// parseCIDs 解析一组 CID 字符串。
+//
+// 参数：
+//
+//	cids - CID 字符串列表
+//
+// 返回：
+//
+//	[]cid2.Cid - 解析后的 CID，与输入一一对应
+//	error - 如果有解析失败的 CID，返回列出所有无效项的 *InvalidCIDsError
+func (r *Repository) parseCIDs(cids []string) ([]cid2.Cid, error) {
+	parsed := make([]cid2.Cid, len(cids))
+	var invalid *InvalidCIDsError
+	var seen map[string]struct{} // 已记录的无效 CID
+	for i, cidStr := range cids {
+		c, err := cid2.Parse(cidStr)
+		if err != nil {
+			if invalid == nil {
+				invalid = &InvalidCIDsError{Err: err}
+				seen = make(map[string]struct{})
+			}
+			if _, ok := seen[cidStr]; !ok {
+				seen[cidStr] = struct{}{}
+				invalid.CIDs = append(invalid.CIDs, cidStr)
+			}
+			continue
+		}
+		parsed[i] = c
+	}
+	if invalid != nil {
+		return nil, invalid
+	}
+	return parsed, nil
+}
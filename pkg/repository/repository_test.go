@@ -1,10 +1,22 @@
 package repository
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	cid2 "github.com/ipfs/go-cid"
+	"github.com/tragoedia0722/repository/pkg/importer"
 )
 
 func cleanupRepo(t *testing.T, path string) {
@@ -431,6 +443,112 @@ func TestRepository_GetRawData(t *testing.T) {
 	})
 }
 
+func TestRepository_GetManyRawData(t *testing.T) {
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+	var cids []string
+	want := make(map[string][]byte)
+	for i := 0; i < 150; i++ {
+		data := []byte(fmt.Sprintf("block %d", i))
+		c, err := repo.PutBlock(ctx, data)
+		if err != nil {
+			t.Fatalf("PutBlock failed: %v", err)
+		}
+		cids = append(cids, c.String())
+		want[c.String()] = data
+	}
+	empty, err := repo.PutBlock(ctx, []byte{})
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+	want[empty.String()] = []byte{}
+
+	absent := make([]string, 2)
+	for i := range absent {
+		c, err := repo.builder.Sum([]byte(fmt.Sprintf("absent %d", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		absent[i] = c.String()
+	}
+
+	// 缺失的块不中止其余读取，重复的 CID 只出现一次
+	query := append([]string{absent[1]}, cids...)
+	query = append(query, empty.String(), cids[0], absent[0])
+	found, missing, err := repo.GetManyRawData(ctx, query)
+	if err != nil {
+		t.Fatalf("GetManyRawData failed: %v", err)
+	}
+	if len(found) != len(want) {
+		t.Errorf("found %d blocks, want %d", len(found), len(want))
+	}
+	for c, data := range want {
+		if got, ok := found[c]; !ok || !bytes.Equal(got, data) {
+			t.Errorf("block %s = %q (found %v), want %q", c, got, ok, data)
+		}
+	}
+	if len(missing) != 2 || missing[0] != absent[1] || missing[1] != absent[0] {
+		t.Errorf("missing = %v, want %v in input order", missing, []string{absent[1], absent[0]})
+	}
+
+	t.Run("invalid CID", func(t *testing.T) {
+		if _, _, err := repo.GetManyRawData(ctx, []string{cids[0], "invalid-cid"}); err == nil {
+			t.Error("expected error for invalid CID, got nil")
+		}
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, _, err := repo.GetManyRawData(cancelled, cids); !errors.Is(err, context.Canceled) {
+			t.Errorf("GetManyRawData error = %v, want context.Canceled", err)
+		}
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		errDisk := errors.New("input/output error")
+		failing, err := cid2.Decode(cids[3])
+		if err != nil {
+			t.Fatal(err)
+		}
+		orig := repo.blockStore
+		repo.blockStore = &failingGetBlockstore{Blockstore: orig, fail: failing, err: errDisk}
+		defer func() { repo.blockStore = orig }()
+
+		// 读取失败不是缺失
+		if _, _, err := repo.GetManyRawData(ctx, cids); !errors.Is(err, errDisk) {
+			t.Errorf("GetManyRawData error = %v, want the read error", err)
+		}
+	})
+
+	t.Run("empty input", func(t *testing.T) {
+		found, missing, err := repo.GetManyRawData(ctx, nil)
+		if err != nil || len(found) != 0 || len(missing) != 0 {
+			t.Errorf("GetManyRawData(nil) = %v, %v, %v", found, missing, err)
+		}
+	})
+}
+
+// failingGetBlockstore 读取 fail 时返回 err。
+type failingGetBlockstore struct {
+	blockstore.Blockstore
+
+	fail cid2.Cid
+	err  error
+}
+
+func (b *failingGetBlockstore) Get(ctx context.Context, c cid2.Cid) (blocks.Block, error) {
+	if c.Equals(b.fail) {
+		return nil, b.err
+	}
+	return b.Blockstore.Get(ctx, c)
+}
+
 func TestRepository_DelBlock(t *testing.T) {
 	tmpDir := filepath.Join(os.TempDir(), "test-repo-del")
 	defer cleanupRepo(t, tmpDir)
@@ -525,6 +643,71 @@ func TestRepository_Usage(t *testing.T) {
 	}
 }
 
+func TestRepository_UsageDetail(t *testing.T) {
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+	ctx := context.Background()
+
+	src := t.TempDir()
+	rng := rand.New(rand.NewSource(1))
+	const size = 4 * 1024 * 1024
+	for i := 0; i < 3; i++ {
+		data := make([]byte, size)
+		rng.Read(data)
+		if err := os.WriteFile(filepath.Join(src, fmt.Sprintf("f%d.bin", i)), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	result, err := importer.NewImporter(repo.BlockStore(), src).Import(ctx)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	detail, err := repo.UsageDetail(ctx)
+	if err != nil {
+		t.Fatalf("UsageDetail failed: %v", err)
+	}
+	if detail.BlocksBytes < 3*size {
+		t.Errorf("BlocksBytes = %d, want at least the %d bytes imported", detail.BlocksBytes, 3*size)
+	}
+	if detail.BlocksBytes <= detail.MetadataBytes {
+		t.Errorf("BlocksBytes = %d, want it to dominate MetadataBytes = %d", detail.BlocksBytes, detail.MetadataBytes)
+	}
+	if detail.TotalBytes != detail.BlocksBytes+detail.MetadataBytes {
+		t.Errorf("TotalBytes = %d, want %d + %d", detail.TotalBytes, detail.BlocksBytes, detail.MetadataBytes)
+	}
+
+	var blockCount uint64
+	for _, pkg := range result.Packages {
+		blockCount += uint64(len(pkg.Blocks))
+	}
+	if detail.BlockCount != blockCount {
+		t.Errorf("BlockCount = %d, want the %d blocks of the import's packages", detail.BlockCount, blockCount)
+	}
+
+	// An in-memory repository reports its blocks only, and TotalBytes matches Usage
+	mem := NewMemoryRepository()
+	defer mem.Close()
+	if _, err := mem.PutBlock(ctx, make([]byte, 1000)); err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+	detail, err = mem.UsageDetail(ctx)
+	if err != nil {
+		t.Fatalf("UsageDetail failed: %v", err)
+	}
+	usage, err := mem.Usage(ctx)
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	want := UsageDetail{BlocksBytes: 1000, BlockCount: 1, TotalBytes: 1000}
+	if detail != want || usage != detail.TotalBytes {
+		t.Errorf("UsageDetail = %+v, Usage = %d, want %+v", detail, usage, want)
+	}
+}
+
 func TestRepository_Close(t *testing.T) {
 	tmpDir := filepath.Join(os.TempDir(), "test-repo-close")
 	defer cleanupRepo(t, tmpDir)
@@ -594,6 +777,110 @@ func TestRepository_Destroy(t *testing.T) {
 	})
 }
 
+func TestRepository_Destroy_InUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repo")
+	ctx := context.Background()
+
+	// A second handle on the same path, closed before the first one opens
+	second, err := NewRepository(path)
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	if err := second.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	first, err := NewRepositoryWithOptions(path, RepoOptions{LockMetadata: true, LockHostname: true})
+	if err != nil {
+		t.Fatalf("NewRepositoryWithOptions failed: %v", err)
+	}
+	c, err := first.PutBlock(ctx, []byte("still in use"))
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+
+	err = second.Destroy()
+	var inUse *InUseError
+	if !errors.As(err, &inUse) || !errors.Is(err, ErrRepositoryInUse) {
+		t.Fatalf("Destroy() error = %v, want an *InUseError wrapping ErrRepositoryInUse", err)
+	}
+	if inUse.PID != os.Getpid() {
+		t.Errorf("lock holder PID = %d, want %d", inUse.PID, os.Getpid())
+	}
+	if host, _ := os.Hostname(); inUse.Hostname != host {
+		t.Errorf("lock holder host = %q, want %q", inUse.Hostname, host)
+	}
+
+	// Nothing was removed from under the open handle
+	if data, err := first.GetRawDataCid(ctx, *c); err != nil || !bytes.Equal(data, []byte("still in use")) {
+		t.Fatalf("GetRawDataCid after the refused Destroy = %q, %v", data, err)
+	}
+
+	if err := first.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := second.Destroy(); err != nil {
+		t.Fatalf("Destroy after the other handle closed failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("repository directory still exists: %v", err)
+	}
+
+	// Running Destroy again is a no-op
+	if err := second.Destroy(); err != nil {
+		t.Errorf("second Destroy failed: %v", err)
+	}
+	if err := first.Destroy(); err != nil {
+		t.Errorf("Destroy of the other closed handle failed: %v", err)
+	}
+}
+
+func TestRepository_ForceDestroy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repo")
+
+	second, err := NewRepository(path)
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	_ = second.Close()
+
+	first, err := NewRepository(path)
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer first.Close()
+
+	if err := second.ForceDestroy(); err != nil {
+		t.Fatalf("ForceDestroy failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("repository directory still exists: %v", err)
+	}
+}
+
+func TestRepository_Destroy_PartiallyDeleted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repo")
+
+	repo, err := NewRepository(path)
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	if err := repo.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Simulate an earlier Destroy that stopped half way
+	if err := os.RemoveAll(filepath.Join(path, "blocks")); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Destroy(); err != nil {
+		t.Fatalf("Destroy failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("repository directory still exists: %v", err)
+	}
+}
+
 func TestRepository_CIDConsistency(t *testing.T) {
 	tmpDir := filepath.Join(os.TempDir(), "test-repo-cid")
 	defer cleanupRepo(t, tmpDir)
@@ -701,3 +988,282 @@ func BenchmarkPutBlock(b *testing.B) {
 		}
 	}
 }
+
+// benchmarkCids 生成 n 个不存在于仓库中的 CID
+func benchmarkCids(b *testing.B, repo *Repository, n int) []cid2.Cid {
+	b.Helper()
+
+	cids := make([]cid2.Cid, n)
+	for i := range cids {
+		c, err := repo.builder.Sum([]byte(fmt.Sprintf("block-%d", i)))
+		if err != nil {
+			b.Fatalf("Sum failed: %v", err)
+		}
+		cids[i] = c
+	}
+	return cids
+}
+
+// Benchmark HasAllBlocks with 100k string CIDs
+func BenchmarkHasAllBlocks(b *testing.B) {
+	tmpDir := filepath.Join(os.TempDir(), "bench-repo-hasall")
+	defer os.RemoveAll(tmpDir)
+
+	repo, err := NewRepository(tmpDir)
+	if err != nil {
+		b.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	cids := benchmarkCids(b, repo, 100000)
+	strs := make([]string, len(cids))
+	for i, c := range cids {
+		strs[i] = c.String()
+	}
+
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.HasAllBlocks(ctx, strs); err != nil {
+			b.Fatalf("HasAllBlocks failed: %v", err)
+		}
+	}
+}
+
+// Benchmark HasAllBlockCids with 100k parsed CIDs
+func BenchmarkHasAllBlockCids(b *testing.B) {
+	tmpDir := filepath.Join(os.TempDir(), "bench-repo-hasall-cids")
+	defer os.RemoveAll(tmpDir)
+
+	repo, err := NewRepository(tmpDir)
+	if err != nil {
+		b.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	cids := benchmarkCids(b, repo, 100000)
+
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.HasAllBlockCids(ctx, cids); err != nil {
+			b.Fatalf("HasAllBlockCids failed: %v", err)
+		}
+	}
+}
+
+func TestRepository_TypedCidMethods(t *testing.T) {
+	tmpDir := filepath.Join(os.TempDir(), "test-repo-typed-cid")
+	defer cleanupRepo(t, tmpDir)
+
+	repo, err := NewRepository(tmpDir)
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+
+	present, err := repo.PutBlock(ctx, []byte("present"))
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+	absent, err := repo.builder.Sum([]byte("absent"))
+	if err != nil {
+		t.Fatalf("Sum failed: %v", err)
+	}
+
+	has, err := repo.HasBlockCid(ctx, *present)
+	if err != nil || !has {
+		t.Errorf("HasBlockCid(present) = %v, %v", has, err)
+	}
+
+	has, err = repo.HasAllBlockCids(ctx, []cid2.Cid{*present, absent})
+	if err != nil || has {
+		t.Errorf("HasAllBlockCids with an absent block = %v, %v", has, err)
+	}
+
+	missing, err := repo.MissingBlockCids(ctx, []cid2.Cid{*present, absent})
+	if err != nil {
+		t.Fatalf("MissingBlockCids failed: %v", err)
+	}
+	if len(missing) != 1 || !missing[0].Equals(absent) {
+		t.Errorf("MissingBlockCids = %v, want [%s]", missing, absent)
+	}
+
+	missingStrs, err := repo.MissingBlocks(ctx, []string{present.String(), absent.String()})
+	if err != nil {
+		t.Fatalf("MissingBlocks failed: %v", err)
+	}
+	if len(missingStrs) != 1 || missingStrs[0] != absent.String() {
+		t.Errorf("MissingBlocks = %v, want [%s]", missingStrs, absent)
+	}
+
+	if _, err := repo.MissingBlocks(ctx, []string{"not-a-cid"}); err == nil {
+		t.Error("MissingBlocks should reject invalid CIDs")
+	}
+
+	data, err := repo.GetRawDataCid(ctx, *present)
+	if err != nil || string(data) != "present" {
+		t.Errorf("GetRawDataCid = %q, %v", data, err)
+	}
+
+	// 错误信息中包含 CID 字符串
+	if _, err := repo.GetRawDataCid(ctx, absent); err == nil || !strings.Contains(err.Error(), absent.String()) {
+		t.Errorf("GetRawDataCid error should name the CID, got %v", err)
+	}
+
+	if err := repo.DelBlockCid(ctx, *present); err != nil {
+		t.Fatalf("DelBlockCid failed: %v", err)
+	}
+	if has, _ := repo.HasBlockCid(ctx, *present); has {
+		t.Error("block still present after DelBlockCid")
+	}
+}
+
+func TestRepository_MissingBlocks(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	defer repo.Close()
+
+	present, err := repo.PutBlock(ctx, []byte("present"))
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+	var absent []string
+	for _, s := range []string{"absent-1", "absent-2"} {
+		c, err := repo.builder.Sum([]byte(s))
+		if err != nil {
+			t.Fatalf("Sum failed: %v", err)
+		}
+		absent = append(absent, c.String())
+	}
+
+	// 重复的 CID 只列出一次，保持第一次出现的顺序
+	input := []string{absent[1], present.String(), absent[0], absent[1], present.String(), absent[0]}
+	missing, err := repo.MissingBlocks(ctx, input)
+	if err != nil {
+		t.Fatalf("MissingBlocks failed: %v", err)
+	}
+	if want := []string{absent[1], absent[0]}; !reflect.DeepEqual(missing, want) {
+		t.Errorf("MissingBlocks = %v, want %v", missing, want)
+	}
+	if has, err := repo.HasAllBlocks(ctx, input); err != nil || has {
+		t.Errorf("HasAllBlocks = %v, %v, want false", has, err)
+	}
+
+	missing, err = repo.MissingBlocks(ctx, []string{present.String(), present.String()})
+	if err != nil || len(missing) != 0 {
+		t.Errorf("MissingBlocks of present blocks = %v, %v, want none", missing, err)
+	}
+
+	// 同一 CID 的不同编码被视为重复，结果中使用第一次出现的字符串
+	upper := strings.ToUpper(absent[0]) // base32upper 的多基前缀为 'B'
+	missing, err = repo.MissingBlocks(ctx, []string{upper, absent[0]})
+	if err != nil {
+		t.Fatalf("MissingBlocks failed: %v", err)
+	}
+	if want := []string{upper}; !reflect.DeepEqual(missing, want) {
+		t.Errorf("MissingBlocks with two encodings = %v, want %v", missing, want)
+	}
+
+	// 所有无效的 CID 都被列出
+	_, err = repo.MissingBlocks(ctx, []string{"bad-1", present.String(), "bad-2", "bad-1", absent[0]})
+	if !errors.Is(err, ErrInvalidCID) {
+		t.Fatalf("MissingBlocks error = %v, want ErrInvalidCID", err)
+	}
+	var invalid *InvalidCIDsError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("MissingBlocks error = %v, want *InvalidCIDsError", err)
+	}
+	if want := []string{"bad-1", "bad-2"}; !reflect.DeepEqual(invalid.CIDs, want) {
+		t.Errorf("InvalidCIDsError.CIDs = %v, want %v", invalid.CIDs, want)
+	}
+	if _, err := repo.HasAllBlocks(ctx, []string{"bad-1"}); !errors.As(err, &invalid) || len(invalid.CIDs) != 1 {
+		t.Errorf("HasAllBlocks error = %v, want one invalid CID", err)
+	}
+}
+
+func TestRepository_Foreground(t *testing.T) {
+	tmpDir := filepath.Join(os.TempDir(), "test-repo-foreground")
+	defer cleanupRepo(t, tmpDir)
+
+	repo, err := NewRepository(tmpDir)
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	if repo.ForegroundActive() {
+		t.Fatal("new repository should have no foreground activity")
+	}
+
+	repo.AcquireForeground()
+	repo.AcquireForeground()
+	repo.ReleaseForeground()
+	if !repo.ForegroundActive() {
+		t.Error("expected foreground activity with one outstanding acquire")
+	}
+
+	repo.ReleaseForeground()
+	repo.ReleaseForeground() // extra release must not go negative
+	if repo.ForegroundActive() {
+		t.Error("expected no foreground activity after releases")
+	}
+
+	repo.AcquireForeground()
+	if !repo.ForegroundActive() {
+		t.Error("acquire after extra release should be visible")
+	}
+	repo.ReleaseForeground()
+}
+
+func TestRepository_ContextCancellation(t *testing.T) {
+	path := t.TempDir()
+	repo, err := NewRepository(path)
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	t.Run("lock wait", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		if _, err := NewRepositoryWithContext(ctx, path, RepoOptions{}); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("NewRepositoryWithContext error = %v, want DeadlineExceeded", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("NewRepositoryWithContext returned after %v", elapsed)
+		}
+	})
+
+	t.Run("sharded lock wait", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := NewShardedRepositoryWithContext(ctx, []string{t.TempDir(), path}, RepoOptions{})
+		var shardErr *ShardError
+		if !errors.As(err, &shardErr) || shardErr.Index != 1 || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("NewShardedRepositoryWithContext error = %v, want DeadlineExceeded for shard 1", err)
+		}
+	})
+
+	t.Run("cancelled destroy", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := repo.DestroyWithContext(ctx); !errors.Is(err, context.Canceled) {
+			t.Fatalf("DestroyWithContext error = %v, want Canceled", err)
+		}
+		if _, err := repo.PutBlock(context.Background(), []byte("still open")); err != nil {
+			t.Fatalf("PutBlock after cancelled destroy failed: %v", err)
+		}
+		if err := repo.DestroyWithContext(context.Background()); err != nil {
+			t.Fatalf("DestroyWithContext failed: %v", err)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("repository still exists after destroy: %v", err)
+		}
+	})
+}
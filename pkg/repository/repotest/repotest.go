@@ -0,0 +1,654 @@
+// Package repotest 提供 repository.Store 的内存实现，用于测试依赖仓库的代码。
+//
+// Store 的数据保存在内存中，不创建目录也不持有锁文件，因此测试之间不会
+// 互相争用。它支持按方法注入失败和延迟：
+//
+//	store := repotest.New().
+//	    FailOn("PutBlock", 2, errors.New("disk full")). // 第二次 PutBlock 失败
+//	    WithLatency(10 * time.Millisecond)
+//	defer store.Close()
+package repotest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	cid2 "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/multiformats/go-multicodec"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/tragoedia0722/repository/pkg/repository"
+)
+
+// ErrClosed 表示 Store 已经关闭或销毁，与 repository.ErrClosed 相同。
+var ErrClosed = repository.ErrClosed
+
+// fault 是一次注入的失败。
+type fault struct {
+	method string
+	nth    int // 第几次调用失败，从 1 开始；0 表示每次调用
+	err    error
+}
+
+// Store 是 repository.Store 的内存实现。
+//
+// 所有方法都可以并发调用。与 *repository.Repository 一样，数据块的读写都
+// 经过 BlockStore()，因此 PutBlock 同时计为一次 "BlockStore.Put" 调用，
+// 变更回调对直接经过 BlockStore() 的写入和删除同样生效。
+type Store struct {
+	mu         sync.Mutex
+	data       repository.Datastore
+	blocks     blockstore.Blockstore // 未经包装的 blockstore
+	wrapped    blockstore.Blockstore // 注入失败和触发回调的 blockstore，由 BlockStore 返回
+	builder    cid2.Builder
+	limits     repository.RepoLimits
+	faults     []fault
+	calls      map[string]int
+	latency    time.Duration
+	closed     bool
+	hooks      map[int]hook
+	nextHook   int
+	foreground int
+}
+
+// hook 是一个数据块变更回调。
+type hook struct {
+	deleted bool // true 表示删除回调，否则为写入回调
+	fn      func(cid2.Cid)
+}
+
+var _ repository.Store = (*Store)(nil)
+
+// New 创建一个空的内存仓库，限制与 repository 的默认限制相同。
+func New() *Store {
+	s := &Store{
+		builder: cid2.V1Builder{
+			Codec:    uint64(multicodec.DagPb),
+			MhType:   mh.SHA2_256,
+			MhLength: -1,
+		},
+		limits: repository.RepoLimits{
+			MaxBlockSize:        repository.DefaultMaxBlockSize,
+			HasCheckConcurrency: repository.DefaultHasCheckConcurrency,
+			GetRetryAttempts:    repository.DefaultGetRetryAttempts,
+			GetRetryBaseDelay:   repository.DefaultGetRetryBaseDelay,
+		},
+		calls: make(map[string]int),
+		hooks: make(map[int]hook),
+	}
+	s.reset()
+	return s
+}
+
+// reset 用空的存储替换所有数据。
+func (s *Store) reset() {
+	s.data = dssync.MutexWrap(ds.NewMapDatastore())
+	s.blocks = blockstore.NewBlockstore(s.data)
+	s.wrapped = &faultBlockstore{Blockstore: s.blocks, store: s}
+}
+
+// FailOn 使方法 method 的第 nth 次调用（从 1 开始计数）返回 err，nth 为 0 时
+// 每次调用都返回 err。method 是 Store 的方法名（例如 "PutBlock"），或者
+// "BlockStore." 加上 blockstore 的方法名（例如 "BlockStore.Get"）。
+// 失败的调用不修改数据。
+// 返回 Store 以便链式调用。
+func (s *Store) FailOn(method string, nth int, err error) *Store {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, fault{method: method, nth: nth, err: err})
+	return s
+}
+
+// WithLatency 使每次调用在执行前等待 d，等待期间 ctx 结束时返回 ctx.Err()。
+// 返回 Store 以便链式调用。
+func (s *Store) WithLatency(d time.Duration) *Store {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+	return s
+}
+
+// Calls 返回方法 method 被调用的次数，名称的格式与 FailOn 相同。
+func (s *Store) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+// enter 记录一次对 method 的调用，等待注入的延迟，并返回注入的失败。
+func (s *Store) enter(ctx context.Context, method string) error {
+	s.mu.Lock()
+	s.calls[method]++
+	n := s.calls[method]
+	var err error
+	for _, f := range s.faults {
+		if f.method == method && (f.nth == 0 || f.nth == n) {
+			err = f.err
+			break
+		}
+	}
+	if err == nil && s.closed {
+		err = ErrClosed
+	}
+	latency := s.latency
+	s.mu.Unlock()
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+// store 返回当前的 blockstore。
+func (s *Store) store() blockstore.Blockstore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.wrapped
+}
+
+// PutBlock 存储单个数据块并返回其 CID。
+func (s *Store) PutBlock(ctx context.Context, bytes []byte) (*cid2.Cid, error) {
+	if err := s.enter(ctx, "PutBlock"); err != nil {
+		return nil, err
+	}
+	blk, err := s.newBlock(bytes)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store().Put(ctx, blk); err != nil {
+		return nil, fmt.Errorf("failed to put block: %w", err)
+	}
+	c := blk.Cid()
+	return &c, nil
+}
+
+// PutBlockWithCid 使用指定 CID 存储数据块。
+func (s *Store) PutBlockWithCid(ctx context.Context, cid string, bytes []byte) error {
+	if err := s.enter(ctx, "PutBlockWithCid"); err != nil {
+		return err
+	}
+	if err := s.checkSize(bytes); err != nil {
+		return err
+	}
+	c, err := parseCID(cid)
+	if err != nil {
+		return err
+	}
+	blk, err := blocks.NewBlockWithCid(bytes, c)
+	if err != nil {
+		return fmt.Errorf("failed to create block: %w", err)
+	}
+	if err := s.store().Put(ctx, blk); err != nil {
+		return fmt.Errorf("failed to put block: %w", err)
+	}
+	return nil
+}
+
+// PutManyBlocks 批量存储数据块并返回对应的 CID 列表。
+func (s *Store) PutManyBlocks(ctx context.Context, bytes [][]byte) ([]*cid2.Cid, error) {
+	if err := s.enter(ctx, "PutManyBlocks"); err != nil {
+		return nil, err
+	}
+	if len(bytes) == 0 {
+		return nil, nil
+	}
+
+	blks := make([]blocks.Block, len(bytes))
+	cids := make([]*cid2.Cid, len(bytes))
+	for i, b := range bytes {
+		blk, err := s.newBlock(b)
+		if err != nil {
+			return nil, fmt.Errorf("block at index %d: %w", i, err)
+		}
+		c := blk.Cid()
+		blks[i], cids[i] = blk, &c
+	}
+	if err := s.store().PutMany(ctx, blks); err != nil {
+		return nil, fmt.Errorf("failed to put blocks: %w", err)
+	}
+	return cids, nil
+}
+
+// newBlock 检查数据大小并创建数据块。
+func (s *Store) newBlock(bytes []byte) (blocks.Block, error) {
+	if err := s.checkSize(bytes); err != nil {
+		return nil, err
+	}
+	sum, err := s.builder.Sum(bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate CID: %w", err)
+	}
+	return blocks.NewBlockWithCid(bytes, sum)
+}
+
+// checkSize 检查数据块不超过 MaxBlockSize，超过时返回 *repository.BlockTooLargeError。
+func (s *Store) checkSize(bytes []byte) error {
+	if len(bytes) > s.limits.MaxBlockSize {
+		return &repository.BlockTooLargeError{Size: len(bytes), Max: s.limits.MaxBlockSize}
+	}
+	return nil
+}
+
+// HasBlock 检查指定 CID 的块是否存在。
+func (s *Store) HasBlock(ctx context.Context, cid string) (bool, error) {
+	if err := s.enter(ctx, "HasBlock"); err != nil {
+		return false, err
+	}
+	c, err := parseCID(cid)
+	if err != nil {
+		return false, err
+	}
+	return s.store().Has(ctx, c)
+}
+
+// HasBlockCid 检查指定 CID 的块是否存在。
+func (s *Store) HasBlockCid(ctx context.Context, c cid2.Cid) (bool, error) {
+	if err := s.enter(ctx, "HasBlockCid"); err != nil {
+		return false, err
+	}
+	return s.store().Has(ctx, c)
+}
+
+// HasAllBlocks 检查所有指定的 CID 是否都存在。
+func (s *Store) HasAllBlocks(ctx context.Context, cids []string) (bool, error) {
+	if err := s.enter(ctx, "HasAllBlocks"); err != nil {
+		return false, err
+	}
+	parsed, err := parseCIDs(cids)
+	if err != nil {
+		return false, err
+	}
+	missing, err := s.missing(ctx, parsed)
+	return err == nil && len(missing) == 0, err
+}
+
+// HasAllBlockCids 检查所有指定的 CID 是否都存在。
+func (s *Store) HasAllBlockCids(ctx context.Context, cids []cid2.Cid) (bool, error) {
+	if err := s.enter(ctx, "HasAllBlockCids"); err != nil {
+		return false, err
+	}
+	missing, err := s.missing(ctx, cids)
+	return err == nil && len(missing) == 0, err
+}
+
+// MissingBlocks 返回指定 CID 中不存在的块，保持输入顺序。
+func (s *Store) MissingBlocks(ctx context.Context, cids []string) ([]string, error) {
+	if err := s.enter(ctx, "MissingBlocks"); err != nil {
+		return nil, err
+	}
+	parsed, err := parseCIDs(cids)
+	if err != nil {
+		return nil, err
+	}
+	missing, err := s.missing(ctx, parsed)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, i := range missing {
+		out = append(out, cids[i])
+	}
+	return out, nil
+}
+
+// MissingBlockCids 返回指定 CID 中不存在的块，保持输入顺序。
+func (s *Store) MissingBlockCids(ctx context.Context, cids []cid2.Cid) ([]cid2.Cid, error) {
+	if err := s.enter(ctx, "MissingBlockCids"); err != nil {
+		return nil, err
+	}
+	missing, err := s.missing(ctx, cids)
+	if err != nil {
+		return nil, err
+	}
+	var out []cid2.Cid
+	for _, i := range missing {
+		out = append(out, cids[i])
+	}
+	return out, nil
+}
+
+// missing 返回 cids 中不存在的块的下标。
+func (s *Store) missing(ctx context.Context, cids []cid2.Cid) ([]int, error) {
+	bs := s.store()
+	var missing []int
+	for i, c := range cids {
+		has, err := bs.Has(ctx, c)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check block %s: %w", c, err)
+		}
+		if !has {
+			missing = append(missing, i)
+		}
+	}
+	return missing, nil
+}
+
+// GetRawData 获取指定 CID 的原始数据。块不存在时不像 *repository.Repository
+// 那样重试，直接返回 *repository.BlockNotFoundError，ipld.IsNotFound 也可以识别。
+func (s *Store) GetRawData(ctx context.Context, cid string) ([]byte, error) {
+	if err := s.enter(ctx, "GetRawData"); err != nil {
+		return nil, err
+	}
+	c, err := parseCID(cid)
+	if err != nil {
+		return nil, err
+	}
+	return s.get(ctx, c)
+}
+
+// GetRawDataCid 获取指定 CID 的原始数据，块不存在时的行为与 GetRawData 相同。
+func (s *Store) GetRawDataCid(ctx context.Context, c cid2.Cid) ([]byte, error) {
+	if err := s.enter(ctx, "GetRawDataCid"); err != nil {
+		return nil, err
+	}
+	return s.get(ctx, c)
+}
+
+// GetBlockReader 返回指定 CID 的块数据的读取器及其长度，块不存在时的行为
+// 与 GetRawData 相同。
+func (s *Store) GetBlockReader(ctx context.Context, cid string) (io.ReadCloser, int64, error) {
+	if err := s.enter(ctx, "GetBlockReader"); err != nil {
+		return nil, 0, err
+	}
+	c, err := parseCID(cid)
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := s.get(ctx, c)
+	if err != nil {
+		return nil, 0, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), int64(len(data)), nil
+}
+
+// GetManyRawData 获取多个 CID 的原始数据，返回找到的块和按输入顺序排列的
+// 缺失 CID。只有不存在的块记入缺失列表，其他读取错误直接返回。
+func (s *Store) GetManyRawData(ctx context.Context, cids []string) (map[string][]byte, []string, error) {
+	if err := s.enter(ctx, "GetManyRawData"); err != nil {
+		return nil, nil, err
+	}
+	parsed, err := parseCIDs(cids)
+	if err != nil {
+		return nil, nil, err
+	}
+	found := make(map[string][]byte, len(cids))
+	var missing []string
+	for i, c := range parsed {
+		if _, ok := found[cids[i]]; ok {
+			continue
+		}
+		data, err := s.get(ctx, c)
+		if ipld.IsNotFound(err) {
+			missing = append(missing, cids[i])
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		found[cids[i]] = data
+	}
+	return found, missing, nil
+}
+
+// get 读取块数据。
+func (s *Store) get(ctx context.Context, c cid2.Cid) ([]byte, error) {
+	blk, err := s.store().Get(ctx, c)
+	if ipld.IsNotFound(err) {
+		return nil, &repository.BlockNotFoundError{Cid: c}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block %s: %w", c, err)
+	}
+	return blk.RawData(), nil
+}
+
+// DelBlock 删除指定 CID 的块。
+func (s *Store) DelBlock(ctx context.Context, cid string) error {
+	if err := s.enter(ctx, "DelBlock"); err != nil {
+		return err
+	}
+	c, err := parseCID(cid)
+	if err != nil {
+		return err
+	}
+	return s.del(ctx, c)
+}
+
+// DelBlockCid 删除指定 CID 的块。
+func (s *Store) DelBlockCid(ctx context.Context, c cid2.Cid) error {
+	if err := s.enter(ctx, "DelBlockCid"); err != nil {
+		return err
+	}
+	return s.del(ctx, c)
+}
+
+// del 删除块。
+func (s *Store) del(ctx context.Context, c cid2.Cid) error {
+	if err := s.store().DeleteBlock(ctx, c); err != nil {
+		return fmt.Errorf("failed to delete block %s: %w", c, err)
+	}
+	return nil
+}
+
+// OnBlockPut 注册数据块写入成功后调用的回调，返回注销函数。
+func (s *Store) OnBlockPut(fn func(c cid2.Cid)) func() {
+	return s.addHook(hook{fn: fn})
+}
+
+// OnBlockDeleted 注册数据块删除后调用的回调，返回注销函数。
+func (s *Store) OnBlockDeleted(fn func(c cid2.Cid)) func() {
+	return s.addHook(hook{deleted: true, fn: fn})
+}
+
+// addHook 注册 h 并返回注销函数。
+func (s *Store) addHook(h hook) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextHook
+	s.nextHook++
+	s.hooks[id] = h
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.hooks, id)
+	}
+}
+
+// notify 对 cids 调用写入或删除回调。
+func (s *Store) notify(deleted bool, cids ...cid2.Cid) {
+	s.mu.Lock()
+	var fns []func(cid2.Cid)
+	for _, h := range s.hooks {
+		if h.deleted == deleted {
+			fns = append(fns, h.fn)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, fn := range fns {
+		for _, c := range cids {
+			fn(c)
+		}
+	}
+}
+
+// AcquireForeground 标记一个前台操作开始。
+func (s *Store) AcquireForeground() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.foreground++
+}
+
+// ReleaseForeground 标记一个前台操作结束，多余的调用会被忽略。
+func (s *Store) ReleaseForeground() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.foreground > 0 {
+		s.foreground--
+	}
+}
+
+// ForegroundActive 返回当前是否有前台操作正在进行。
+func (s *Store) ForegroundActive() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.foreground > 0
+}
+
+// BlockStore 返回注入失败和触发回调的 blockstore。
+func (s *Store) BlockStore() blockstore.Blockstore {
+	return s.store()
+}
+
+// DataStore 返回内存中的元数据存储，数据块也保存在其中。
+func (s *Store) DataStore() repository.Datastore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data
+}
+
+// Limits 返回 Store 使用的限制，即 repository 的默认限制。
+func (s *Store) Limits() repository.RepoLimits {
+	return s.limits
+}
+
+// Usage 返回所有数据块的字节数之和。
+func (s *Store) Usage(ctx context.Context) (uint64, error) {
+	if err := s.enter(ctx, "Usage"); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	bs := s.blocks
+	s.mu.Unlock()
+
+	keys, err := bs.AllKeysChan(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var total uint64
+	for c := range keys {
+		size, err := bs.GetSize(ctx, c)
+		if err != nil {
+			return 0, err
+		}
+		total += uint64(size)
+	}
+	return total, ctx.Err()
+}
+
+// Close 关闭 Store，之后的调用返回 ErrClosed。Close 是幂等的。
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+// Destroy 删除所有数据并关闭 Store。Destroy 是幂等的。
+func (s *Store) Destroy() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reset()
+	s.closed = true
+	return nil
+}
+
+// parseCID 解析 CID 字符串，错误信息与 *repository.Repository 相同。
+func parseCID(cidStr string) (cid2.Cid, error) {
+	c, err := cid2.Parse(cidStr)
+	if err != nil {
+		return cid2.Cid{}, fmt.Errorf("%w %q: %w", repository.ErrInvalidCID, cidStr, err)
+	}
+	return c, nil
+}
+
+// parseCIDs 解析一组 CID 字符串。
+func parseCIDs(cids []string) ([]cid2.Cid, error) {
+	parsed := make([]cid2.Cid, len(cids))
+	for i, cidStr := range cids {
+		c, err := parseCID(cidStr)
+		if err != nil {
+			return nil, err
+		}
+		parsed[i] = c
+	}
+	return parsed, nil
+}
+
+// faultBlockstore 为 blockstore 的调用注入失败和延迟，并触发变更回调。
+type faultBlockstore struct {
+	blockstore.Blockstore
+	store *Store
+}
+
+func (b *faultBlockstore) Has(ctx context.Context, c cid2.Cid) (bool, error) {
+	if err := b.store.enter(ctx, "BlockStore.Has"); err != nil {
+		return false, err
+	}
+	return b.Blockstore.Has(ctx, c)
+}
+
+func (b *faultBlockstore) Get(ctx context.Context, c cid2.Cid) (blocks.Block, error) {
+	if err := b.store.enter(ctx, "BlockStore.Get"); err != nil {
+		return nil, err
+	}
+	return b.Blockstore.Get(ctx, c)
+}
+
+func (b *faultBlockstore) GetSize(ctx context.Context, c cid2.Cid) (int, error) {
+	if err := b.store.enter(ctx, "BlockStore.GetSize"); err != nil {
+		return 0, err
+	}
+	return b.Blockstore.GetSize(ctx, c)
+}
+
+func (b *faultBlockstore) Put(ctx context.Context, blk blocks.Block) error {
+	if err := b.store.enter(ctx, "BlockStore.Put"); err != nil {
+		return err
+	}
+	if err := b.Blockstore.Put(ctx, blk); err != nil {
+		return err
+	}
+	b.store.notify(false, blk.Cid())
+	return nil
+}
+
+func (b *faultBlockstore) PutMany(ctx context.Context, blks []blocks.Block) error {
+	if err := b.store.enter(ctx, "BlockStore.PutMany"); err != nil {
+		return err
+	}
+	if err := b.Blockstore.PutMany(ctx, blks); err != nil {
+		return err
+	}
+	cids := make([]cid2.Cid, len(blks))
+	for i, blk := range blks {
+		cids[i] = blk.Cid()
+	}
+	b.store.notify(false, cids...)
+	return nil
+}
+
+func (b *faultBlockstore) DeleteBlock(ctx context.Context, c cid2.Cid) error {
+	if err := b.store.enter(ctx, "BlockStore.DeleteBlock"); err != nil {
+		return err
+	}
+	if err := b.Blockstore.DeleteBlock(ctx, c); err != nil {
+		return err
+	}
+	b.store.notify(true, c)
+	return nil
+}
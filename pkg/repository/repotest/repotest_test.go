@@ -0,0 +1,112 @@
+package repotest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	cid2 "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/tragoedia0722/repository/pkg/repository"
+)
+
+func TestStore_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	defer s.Close()
+
+	var put, deleted []cid2.Cid
+	s.OnBlockPut(func(c cid2.Cid) { put = append(put, c) })
+	s.OnBlockDeleted(func(c cid2.Cid) { deleted = append(deleted, c) })
+
+	c, err := s.PutBlock(ctx, []byte("hello"))
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+	data, err := s.GetRawData(ctx, c.String())
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("GetRawData = %q, %v", data, err)
+	}
+	rc, size, err := s.GetBlockReader(ctx, c.String())
+	if err != nil || size != 5 {
+		t.Fatalf("GetBlockReader = %d, %v", size, err)
+	}
+	if got, err := io.ReadAll(rc); err != nil || string(got) != "hello" {
+		t.Errorf("GetBlockReader read %q, %v", got, err)
+	}
+	if usage, err := s.Usage(ctx); err != nil || usage != 5 {
+		t.Errorf("Usage = %d, %v, want 5", usage, err)
+	}
+
+	if err := s.DelBlockCid(ctx, *c); err != nil {
+		t.Fatalf("DelBlockCid failed: %v", err)
+	}
+	if _, err := s.GetRawDataCid(ctx, *c); !ipld.IsNotFound(err) || !errors.Is(err, repository.ErrBlockNotFound) {
+		t.Errorf("GetRawDataCid after delete returned %v, want not found", err)
+	}
+	missing, err := s.MissingBlockCids(ctx, []cid2.Cid{*c})
+	if err != nil || len(missing) != 1 {
+		t.Errorf("MissingBlockCids = %v, %v", missing, err)
+	}
+
+	if len(put) != 1 || len(deleted) != 1 {
+		t.Errorf("hooks saw %d puts and %d deletes, want 1 each", len(put), len(deleted))
+	}
+}
+
+func TestStore_FailOn(t *testing.T) {
+	ctx := context.Background()
+	errFull := errors.New("disk full")
+	s := New().FailOn("PutBlock", 2, errFull)
+	defer s.Close()
+
+	if _, err := s.PutBlock(ctx, []byte("first")); err != nil {
+		t.Fatalf("first PutBlock failed: %v", err)
+	}
+	if _, err := s.PutBlock(ctx, []byte("second")); !errors.Is(err, errFull) {
+		t.Fatalf("second PutBlock returned %v, want injected error", err)
+	}
+	if _, err := s.PutBlock(ctx, []byte("third")); err != nil {
+		t.Fatalf("third PutBlock failed: %v", err)
+	}
+	if got := s.Calls("PutBlock"); got != 3 {
+		t.Errorf("Calls(PutBlock) = %d, want 3", got)
+	}
+	// The failed call did not reach the blockstore
+	if got := s.Calls("BlockStore.Put"); got != 2 {
+		t.Errorf("Calls(BlockStore.Put) = %d, want 2", got)
+	}
+
+	// Faults on the blockstore affect callers that bypass the Store methods
+	s.FailOn("BlockStore.Get", 0, errFull)
+	c, err := s.PutBlock(ctx, []byte("fourth"))
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+	if _, err := s.BlockStore().Get(ctx, *c); !errors.Is(err, errFull) {
+		t.Errorf("BlockStore().Get returned %v, want injected error", err)
+	}
+}
+
+func TestStore_WithLatency(t *testing.T) {
+	s := New().WithLatency(time.Hour)
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := s.HasBlock(ctx, "bafkqaaa"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("HasBlock returned %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestStore_Closed(t *testing.T) {
+	s := New()
+	if err := s.Destroy(); err != nil {
+		t.Fatalf("Destroy failed: %v", err)
+	}
+	if _, err := s.PutBlock(context.Background(), []byte("late")); !errors.Is(err, ErrClosed) {
+		t.Errorf("PutBlock after Destroy returned %v, want ErrClosed", err)
+	}
+}
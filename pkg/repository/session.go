@@ -0,0 +1,378 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/boxo/datastore/dshelp"
+	cid2 "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/tragoedia0722/repository/pkg/packaging"
+)
+
+var (
+	// importIntentPrefix 是进行中的提交的意图记录的命名空间。
+	importIntentPrefix = ds.NewKey("/imports/intent")
+
+	// importCommittedPrefix 是已提交导入的清单的命名空间，清单的存在即表示提交完成。
+	importCommittedPrefix = ds.NewKey("/imports/committed")
+
+	// pinPrefix 是被固定的根 CID 的命名空间。
+	pinPrefix = ds.NewKey("/pins")
+
+	// refPrefix 是块引用的命名空间，每个引用是 /refs/<块>/<根 CID> 形式的空值键，
+	// 块的引用计数即该块下键的个数。重复写入同一引用不会改变计数。
+	refPrefix = ds.NewKey("/refs")
+)
+
+var (
+	// ErrImportCommitted 表示导入已经提交，不能再用 AbortImport 撤销。
+	ErrImportCommitted = errors.New("import is already committed")
+
+	// ErrImportIncomplete 表示导入的块没有全部写入仓库，不能提交。
+	ErrImportIncomplete = errors.New("import is incomplete")
+
+	// ErrImportNotFound 表示根 CID 没有已提交的导入。
+	ErrImportNotFound = errors.New("import not found")
+)
+
+// ImportResult 是 CommitImport 和 AbortImport 需要的导入结果。
+//
+// *importer.Result 实现了该接口。仓库不能直接依赖 importer 包
+// （importer 的测试依赖仓库），因此通过接口传入。
+type ImportResult interface {
+	// ImportRoot 返回导入的根 CID。
+	ImportRoot() string
+
+	// ImportPackages 返回覆盖导入中所有块的包列表。
+	ImportPackages() []packaging.Package
+
+	// ImportProvenance 返回 JSON 编码的来源记录，没有记录时返回 nil。
+	ImportProvenance() ([]byte, error)
+}
+
+// CommitOptions 配置 CommitImport。
+type CommitOptions struct {
+	// NoPin 为 true 时不固定根 CID，只保存清单和块引用。
+	NoPin bool
+
+	// SkipCheck 为 true 时不检查包中的块是否都已存在。
+	// 调用者刚刚完成导入且确定没有并发删除时可以跳过检查。
+	SkipCheck bool
+}
+
+// ImportManifest 是已提交导入的清单。
+type ImportManifest struct {
+	RootCid    string              `json:"root_cid"`             // 根 CID
+	Packages   []packaging.Package `json:"packages"`             // 导入的包列表
+	Provenance json.RawMessage     `json:"provenance,omitempty"` // 来源记录，没有时为空
+	Pinned     bool                `json:"pinned"`               // 提交时是否固定了根 CID
+}
+
+// CommitImport 在一次调用中登记一个导入：保存清单、固定根 CID、
+// 为所有块增加引用并记录来源。
+//
+// 提交按固定顺序写入，先写意图记录，最后写清单并删除意图记录。
+// 每一步都可以重复执行，因此提交中途失败（例如进程崩溃）后再次调用
+// CommitImport 会补全剩余步骤，得到与一次成功提交相同的状态；
+// 也可以改为调用 AbortImport 撤销已写入的部分。
+// 已经提交的导入再次提交不做任何操作。
+//
+// 配额在块写入仓库时已经计入，提交不改变配额用量。
+// 除非设置 SkipCheck，包中有块缺失时返回包装 ErrImportIncomplete 的错误，不写入任何记录。
+//
+// 参数：
+//
+//	ctx - 用于取消操作的上下文
+//	res - 导入结果，通常是 *importer.Result
+//	opts - 提交选项
+//
+// 返回：
+//
+//	error - 如果块缺失或写入失败，返回错误
+func (r *Repository) CommitImport(ctx context.Context, res ImportResult, opts CommitOptions) error {
+	if err := r.life.enter(); err != nil {
+		return err
+	}
+	defer r.life.leave()
+
+	r.sessions.Lock()
+	defer r.sessions.Unlock()
+	return r.storage.RedactError(r.commitImport(ctx, r.storage.Datastore(), res, opts))
+}
+
+// commitImport 在 meta 中执行 CommitImport 的各个步骤。
+func (r *Repository) commitImport(ctx context.Context, meta ds.Datastore, res ImportResult, opts CommitOptions) error {
+	root, err := r.parseCID(res.ImportRoot())
+	if err != nil {
+		return err
+	}
+	committedKey := importCommittedPrefix.ChildString(root.String())
+	intentKey := importIntentPrefix.ChildString(root.String())
+
+	committed, err := meta.Has(ctx, committedKey)
+	if err != nil {
+		return fmt.Errorf("failed to check import %s: %w", root, err)
+	}
+	if committed {
+		// 上次提交可能在删除意图记录前中断
+		if err := meta.Delete(ctx, intentKey); err != nil {
+			return fmt.Errorf("failed to remove import intent %s: %w", root, err)
+		}
+		return nil
+	}
+
+	pkgs := res.ImportPackages()
+	blockCids, err := packageBlocks(pkgs)
+	if err != nil {
+		return err
+	}
+	if !opts.SkipCheck {
+		if err := r.checkImportBlocks(ctx, blockCids); err != nil {
+			return err
+		}
+	}
+
+	provenance, err := res.ImportProvenance()
+	if err != nil {
+		return fmt.Errorf("failed to encode provenance: %w", err)
+	}
+	manifest, err := json.Marshal(ImportManifest{
+		RootCid:    root.String(),
+		Packages:   pkgs,
+		Provenance: provenance,
+		Pinned:     !opts.NoPin,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode import manifest: %w", err)
+	}
+
+	if err := meta.Put(ctx, intentKey, manifest); err != nil {
+		return fmt.Errorf("failed to record import intent %s: %w", root, err)
+	}
+	for _, c := range blockCids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := meta.Put(ctx, refKey(c, root), nil); err != nil {
+			return fmt.Errorf("failed to add reference to block %s: %w", c, err)
+		}
+	}
+	if !opts.NoPin {
+		if err := meta.Put(ctx, pinPrefix.ChildString(root.String()), nil); err != nil {
+			return fmt.Errorf("failed to pin %s: %w", root, err)
+		}
+	}
+	if err := meta.Put(ctx, committedKey, manifest); err != nil {
+		return fmt.Errorf("failed to save import manifest %s: %w", root, err)
+	}
+	if err := meta.Delete(ctx, intentKey); err != nil {
+		return fmt.Errorf("failed to remove import intent %s: %w", root, err)
+	}
+	return nil
+}
+
+// AbortImport 撤销一个未提交的导入：删除其中没有其他引用的块，
+// 以及提交中途失败时留下的引用、固定和意图记录。
+//
+// 被其他已提交导入引用的块会被保留。不经过 CommitImport 写入的块
+// （例如 PutBlock 写入的块）没有引用记录，如果也出现在该导入中会被一起删除。
+// AbortImport 可以重复调用；已经提交的导入返回 ErrImportCommitted，不做任何修改。
+//
+// 参数：
+//
+//	ctx - 用于取消操作的上下文
+//	res - 导入结果，通常是 *importer.Result
+//
+// 返回：
+//
+//	error - 如果导入已提交或删除失败，返回错误
+func (r *Repository) AbortImport(ctx context.Context, res ImportResult) error {
+	if err := r.life.enter(); err != nil {
+		return err
+	}
+	defer r.life.leave()
+
+	r.sessions.Lock()
+	defer r.sessions.Unlock()
+	return r.storage.RedactError(r.abortImport(ctx, r.storage.Datastore(), res))
+}
+
+// abortImport 在 meta 中执行 AbortImport 的各个步骤。
+func (r *Repository) abortImport(ctx context.Context, meta ds.Datastore, res ImportResult) error {
+	root, err := r.parseCID(res.ImportRoot())
+	if err != nil {
+		return err
+	}
+
+	committed, err := meta.Has(ctx, importCommittedPrefix.ChildString(root.String()))
+	if err != nil {
+		return fmt.Errorf("failed to check import %s: %w", root, err)
+	}
+	if committed {
+		return fmt.Errorf("%w: %s", ErrImportCommitted, root)
+	}
+
+	blockCids, err := packageBlocks(res.ImportPackages())
+	if err != nil {
+		return err
+	}
+	for _, c := range blockCids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := meta.Delete(ctx, refKey(c, root)); err != nil {
+			return fmt.Errorf("failed to remove reference to block %s: %w", c, err)
+		}
+		refs, err := countRefs(ctx, meta, c)
+		if err != nil {
+			return err
+		}
+		if refs > 0 {
+			continue
+		}
+		if err := r.DelBlockCid(ctx, c); err != nil {
+			return err
+		}
+	}
+
+	if err := meta.Delete(ctx, pinPrefix.ChildString(root.String())); err != nil {
+		return fmt.Errorf("failed to unpin %s: %w", root, err)
+	}
+	if err := meta.Delete(ctx, importIntentPrefix.ChildString(root.String())); err != nil {
+		return fmt.Errorf("failed to remove import intent %s: %w", root, err)
+	}
+	return nil
+}
+
+// LoadImport 返回根 CID 已提交导入的清单。
+//
+// 参数：
+//
+//	ctx - 用于取消操作的上下文
+//	rootCid - 根 CID 字符串
+//
+// 返回：
+//
+//	*ImportManifest - 导入清单
+//	error - 如果导入未提交，返回包装 ErrImportNotFound 的错误
+func (r *Repository) LoadImport(ctx context.Context, rootCid string) (*ImportManifest, error) {
+	if err := r.life.enter(); err != nil {
+		return nil, err
+	}
+	defer r.life.leave()
+
+	root, err := r.parseCID(rootCid)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := r.storage.Datastore().Get(ctx, importCommittedPrefix.ChildString(root.String()))
+	if errors.Is(err, ds.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrImportNotFound, root)
+	}
+	if err != nil {
+		return nil, r.storage.RedactError(err)
+	}
+
+	var m ImportManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid import manifest %s: %w", root, err)
+	}
+	return &m, nil
+}
+
+// IsPinned 检查根 CID 是否被固定。
+func (r *Repository) IsPinned(ctx context.Context, rootCid string) (bool, error) {
+	if err := r.life.enter(); err != nil {
+		return false, err
+	}
+	defer r.life.leave()
+
+	root, err := r.parseCID(rootCid)
+	if err != nil {
+		return false, err
+	}
+	pinned, err := r.storage.Datastore().Has(ctx, pinPrefix.ChildString(root.String()))
+	return pinned, r.storage.RedactError(err)
+}
+
+// BlockRefs 返回引用指定块的已提交导入个数，包括提交中途失败的导入。
+func (r *Repository) BlockRefs(ctx context.Context, cid string) (int, error) {
+	if err := r.life.enter(); err != nil {
+		return 0, err
+	}
+	defer r.life.leave()
+
+	c, err := r.parseCID(cid)
+	if err != nil {
+		return 0, err
+	}
+	refs, err := countRefs(ctx, r.storage.Datastore(), c)
+	return refs, r.storage.RedactError(err)
+}
+
+// checkImportBlocks 检查 cids 是否都已存在。
+func (r *Repository) checkImportBlocks(ctx context.Context, cids []cid2.Cid) error {
+	results, err := r.checkBlocks(ctx, cids)
+	if err != nil {
+		return err
+	}
+
+	var missing []cid2.Cid
+	for i, has := range results {
+		if !has {
+			missing = append(missing, cids[i])
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %d of %d blocks missing, first %s", ErrImportIncomplete, len(missing), len(cids), missing[0])
+	}
+	return nil
+}
+
+// packageBlocks 解析包中所有块的 CID，保持包内顺序。
+func packageBlocks(pkgs []packaging.Package) ([]cid2.Cid, error) {
+	var cids []cid2.Cid
+	for _, pkg := range pkgs {
+		for _, s := range pkg.Blocks {
+			c, err := cid2.Parse(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CID %q: %w", s, err)
+			}
+			cids = append(cids, c)
+		}
+	}
+	return cids, nil
+}
+
+// refKey 返回 root 对块 c 的引用的键。
+func refKey(c, root cid2.Cid) ds.Key {
+	return refBlockKey(c).ChildString(root.String())
+}
+
+// refBlockKey 返回块 c 的所有引用所在的键前缀。
+func refBlockKey(c cid2.Cid) ds.Key {
+	return refPrefix.Child(dshelp.MultihashToDsKey(c.Hash()))
+}
+
+// countRefs 统计块 c 的引用个数。
+func countRefs(ctx context.Context, meta ds.Datastore, c cid2.Cid) (int, error) {
+	results, err := meta.Query(ctx, query.Query{Prefix: refBlockKey(c).String(), KeysOnly: true})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count references to block %s: %w", c, err)
+	}
+	defer results.Close()
+
+	refs := 0
+	for result := range results.Next() {
+		if result.Error != nil {
+			return 0, fmt.Errorf("failed to count references to block %s: %w", c, result.Error)
+		}
+		refs++
+	}
+	return refs, nil
+}
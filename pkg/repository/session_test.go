@@ -0,0 +1,245 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/tragoedia0722/repository/pkg/importer"
+)
+
+var _ ImportResult = (*importer.Result)(nil)
+
+var errInjected = errors.New("injected failure")
+
+// failingDatastore fails every write after the first limit ones; a negative
+// limit never fails. It counts the writes it was asked to make.
+type failingDatastore struct {
+	ds.Datastore
+	limit  int
+	writes int
+}
+
+func (d *failingDatastore) write() error {
+	d.writes++
+	if d.limit >= 0 && d.writes > d.limit {
+		return errInjected
+	}
+	return nil
+}
+
+func (d *failingDatastore) Put(ctx context.Context, key ds.Key, value []byte) error {
+	if err := d.write(); err != nil {
+		return err
+	}
+	return d.Datastore.Put(ctx, key, value)
+}
+
+func (d *failingDatastore) Delete(ctx context.Context, key ds.Key) error {
+	if err := d.write(); err != nil {
+		return err
+	}
+	return d.Datastore.Delete(ctx, key)
+}
+
+// importFiles imports files, keyed by slash-separated path, into repo.
+func importFiles(t *testing.T, repo *Repository, files map[string]string) *importer.Result {
+	t.Helper()
+
+	src := t.TempDir()
+	for rel, data := range files {
+		path := filepath.Join(src, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := importer.NewImporter(repo.BlockStore(), src).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	return result
+}
+
+// assertCommitted checks that every record of a commit of result is in place
+// and that no intent record is left.
+func assertCommitted(t *testing.T, repo *Repository, result *importer.Result) {
+	t.Helper()
+	ctx := context.Background()
+
+	m, err := repo.LoadImport(ctx, result.RootCid)
+	if err != nil {
+		t.Fatalf("LoadImport failed: %v", err)
+	}
+	if !reflect.DeepEqual(m.Packages, result.Packages) || !m.Pinned {
+		t.Errorf("manifest %+v does not match the import", m)
+	}
+	if pinned, err := repo.IsPinned(ctx, result.RootCid); err != nil || !pinned {
+		t.Errorf("IsPinned = %v, %v; want true", pinned, err)
+	}
+	for _, pkg := range result.Packages {
+		for _, c := range pkg.Blocks {
+			if refs, err := repo.BlockRefs(ctx, c); err != nil || refs != 1 {
+				t.Fatalf("BlockRefs(%s) = %d, %v; want 1", c, refs, err)
+			}
+		}
+	}
+	if has, err := repo.DataStore().Has(ctx, importIntentPrefix.ChildString(result.RootCid)); err != nil || has {
+		t.Errorf("intent record left behind: %v, %v", has, err)
+	}
+}
+
+func TestRepository_CommitImport(t *testing.T) {
+	ctx := context.Background()
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	result := importPackagesFixture(t, repo)
+	result.Provenance = &importer.Provenance{SourcePath: "/src"}
+
+	if err := repo.CommitImport(ctx, result, CommitOptions{}); err != nil {
+		t.Fatalf("CommitImport failed: %v", err)
+	}
+	assertCommitted(t, repo, result)
+
+	m, err := repo.LoadImport(ctx, result.RootCid)
+	if err != nil {
+		t.Fatalf("LoadImport failed: %v", err)
+	}
+	if string(m.Provenance) == "" {
+		t.Error("provenance was not recorded")
+	}
+
+	// Committing again is a no-op
+	if err := repo.CommitImport(ctx, result, CommitOptions{}); err != nil {
+		t.Fatalf("second CommitImport failed: %v", err)
+	}
+	assertCommitted(t, repo, result)
+
+	err = repo.AbortImport(ctx, result)
+	if !errors.Is(err, ErrImportCommitted) {
+		t.Fatalf("AbortImport after commit returned %v, want ErrImportCommitted", err)
+	}
+	if has, err := repo.HasBlock(ctx, result.RootCid); err != nil || !has {
+		t.Errorf("AbortImport after commit removed the root: %v, %v", has, err)
+	}
+}
+
+func TestRepository_CommitImport_Incomplete(t *testing.T) {
+	ctx := context.Background()
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	result := importFiles(t, repo, map[string]string{"a.txt": "a", "b/c.txt": "c"})
+	if err := repo.DelBlock(ctx, result.RootCid); err != nil {
+		t.Fatal(err)
+	}
+
+	err = repo.CommitImport(ctx, result, CommitOptions{})
+	if !errors.Is(err, ErrImportIncomplete) {
+		t.Fatalf("CommitImport returned %v, want ErrImportIncomplete", err)
+	}
+	if _, err := repo.LoadImport(ctx, result.RootCid); !errors.Is(err, ErrImportNotFound) {
+		t.Errorf("LoadImport returned %v, want ErrImportNotFound", err)
+	}
+}
+
+func TestRepository_CommitImport_Interrupted(t *testing.T) {
+	ctx := context.Background()
+	files := map[string]string{"a.txt": "a", "b/c.txt": "c", "b/d.txt": "d"}
+
+	// Count the writes of a complete commit
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	result := importFiles(t, repo, files)
+	counter := &failingDatastore{Datastore: repo.DataStore(), limit: -1}
+	if err := repo.commitImport(ctx, counter, result, CommitOptions{}); err != nil {
+		t.Fatalf("commitImport failed: %v", err)
+	}
+	repo.Close()
+
+	for limit := 0; limit < counter.writes; limit++ {
+		repo, err := NewRepository(t.TempDir())
+		if err != nil {
+			t.Fatalf("NewRepository failed: %v", err)
+		}
+		result := importFiles(t, repo, files)
+
+		failing := &failingDatastore{Datastore: repo.DataStore(), limit: limit}
+		if err := repo.commitImport(ctx, failing, result, CommitOptions{}); !errors.Is(err, errInjected) {
+			t.Fatalf("commit killed after %d writes returned %v", limit, err)
+		}
+		if err := repo.CommitImport(ctx, result, CommitOptions{}); err != nil {
+			t.Fatalf("CommitImport after %d writes failed: %v", limit, err)
+		}
+		assertCommitted(t, repo, result)
+		repo.Close()
+	}
+}
+
+func TestRepository_AbortImport(t *testing.T) {
+	ctx := context.Background()
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	kept := importFiles(t, repo, map[string]string{"shared.txt": "shared"})
+	if err := repo.CommitImport(ctx, kept, CommitOptions{}); err != nil {
+		t.Fatalf("CommitImport failed: %v", err)
+	}
+
+	aborted := importFiles(t, repo, map[string]string{"shared.txt": "shared", "own.txt": "own"})
+	// Leave a partial commit behind
+	failing := &failingDatastore{Datastore: repo.DataStore(), limit: 2}
+	if err := repo.commitImport(ctx, failing, aborted, CommitOptions{}); !errors.Is(err, errInjected) {
+		t.Fatalf("commitImport returned %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := repo.AbortImport(ctx, aborted); err != nil {
+			t.Fatalf("AbortImport #%d failed: %v", i+1, err)
+		}
+	}
+
+	shared := make(map[string]bool)
+	for _, pkg := range kept.Packages {
+		for _, c := range pkg.Blocks {
+			shared[c] = true
+		}
+	}
+	for _, pkg := range aborted.Packages {
+		for _, c := range pkg.Blocks {
+			has, err := repo.HasBlock(ctx, c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if has != shared[c] {
+				t.Errorf("block %s present = %v, want %v", c, has, shared[c])
+			}
+		}
+	}
+	if pinned, err := repo.IsPinned(ctx, aborted.RootCid); err != nil || pinned {
+		t.Errorf("aborted root is still pinned: %v, %v", pinned, err)
+	}
+	if has, err := repo.DataStore().Has(ctx, importIntentPrefix.ChildString(aborted.RootCid)); err != nil || has {
+		t.Errorf("intent record left behind: %v, %v", has, err)
+	}
+	assertCommitted(t, repo, kept)
+}
@@ -0,0 +1,337 @@
+package repository
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	cid2 "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/tragoedia0722/repository/internal/storage"
+	"golang.org/x/sync/errgroup"
+)
+
+// shardInfoKey 是每个分片的 datastore 中记录分片位置和分片数的键。
+var shardInfoKey = ds.NewKey("/repository/shard")
+
+var (
+	// ErrShardMissing 表示其他分片已初始化，但某个分片目录不存在或未初始化，
+	// 例如所在磁盘没有挂载。
+	ErrShardMissing = errors.New("shard is not initialized")
+
+	// ErrShardMismatch 表示分片记录的位置或分片数与打开时传入的路径列表不一致。
+	ErrShardMismatch = errors.New("shard layout does not match")
+)
+
+// ShardError 表示打开分片仓库时某个分片失败。
+type ShardError struct {
+	// Index 是分片在路径列表中的位置
+	Index int
+	// Path 是分片路径
+	Path string
+	// Err 是底层错误
+	Err error
+}
+
+// Error 实现 error 接口。
+func (e *ShardError) Error() string {
+	return fmt.Sprintf("shard %d (%s): %v", e.Index, e.Path, e.Err)
+}
+
+// Unwrap 返回底层错误，支持 errors.Is 和 errors.As。
+func (e *ShardError) Unwrap() error {
+	return e.Err
+}
+
+// NewShardedRepository 创建或打开一个分布在多个目录上的仓库。
+//
+// 每个块按 CID 的 multihash 摘要前 8 字节（大端）对分片数取模，
+// 确定性地存放在其中一个分片中。返回的仓库与 NewRepositoryWithOptions
+// 返回的仓库用法相同，导入器、提取器和校验器可以透明地使用它。
+// PutManyBlocks 按分片分组并发写入，并发数受 Limits().HasCheckConcurrency 限制；
+// Usage 返回所有分片的用量之和；DataStore 返回第一个分片的 datastore。
+//
+// 所有分片都必须能打开，否则关闭已打开的分片并返回 *ShardError，指明失败的分片；
+// 已初始化的分片仓库中某个分片目录不存在时包装 ErrShardMissing。
+// 与单目录仓库相同，被其他实例持有锁的分片会阻塞到锁释放为止；
+// 需要超时或取消控制时使用 NewShardedRepositoryWithContext。
+// 分片位置和分片数在首次打开时记录在每个分片中，之后路径顺序或数量
+// 不一致时返回包装 ErrShardMismatch 的错误。不支持重新分片。
+//
+// 配额统计整个仓库，记录在第一个分片中；VerifyMount 和 RedactPaths
+// 对每个分片分别生效。
+//
+// 参数：
+//
+//	paths - 各分片的路径，顺序决定块的分布
+//	opts - 仓库配置
+//
+// 返回：
+//
+//	*Repository - 仓库实例
+//	error - 如果任意分片打开失败，返回错误
+func NewShardedRepository(paths []string, opts RepoOptions) (*Repository, error) {
+	return NewShardedRepositoryWithContext(context.Background(), paths, opts)
+}
+
+// NewShardedRepositoryWithContext 与 NewShardedRepository 相同，支持上下文控制。
+//
+// 等待分片的锁和首次启用配额时的块统计响应 ctx，ctx 结束时关闭已打开的分片
+// 并返回包装 ctx.Err() 的错误。各分片 datastore 的打开不可中断。
+//
+// 参数：
+//
+//	ctx - 用于取消操作或设置超时的上下文
+//	paths - 各分片的路径，顺序决定块的分布
+//	opts - 仓库配置
+//
+// 返回：
+//
+//	*Repository - 仓库实例
+//	error - 如果任意分片打开失败或上下文取消，返回错误
+func NewShardedRepositoryWithContext(ctx context.Context, paths []string, opts RepoOptions) (*Repository, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("sharded repository needs at least one path")
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	// 在创建任何目录之前发现缺失的分片，避免在挂载点下写入空分片
+	if err := checkShardsPresent(paths, opts); err != nil {
+		return nil, err
+	}
+
+	stores := make([]*storage.Storage, 0, len(paths))
+	closeAll := func() {
+		for _, s := range stores {
+			_ = s.Close()
+		}
+	}
+
+	limits := defaultLimits()
+	limits.QuotaBytes = opts.QuotaBytes
+
+	shards := make([]blockstore.Blockstore, len(paths))
+	for i, path := range paths {
+		s, err := openStorage(ctx, path, opts)
+		if err != nil {
+			closeAll()
+			return nil, &ShardError{Index: i, Path: shardPath(path, opts), Err: err}
+		}
+		stores = append(stores, s)
+
+		if err := checkShardInfo(ctx, s.Datastore(), i, len(paths)); err != nil {
+			closeAll()
+			return nil, &ShardError{Index: i, Path: shardPath(path, opts), Err: s.RedactError(err)}
+		}
+		shards[i] = guardBlockstore(blockstore.NewBlockstore(s.Datastore()), s, opts)
+	}
+
+	r := &Repository{
+		storage:    stores[0],
+		shards:     stores,
+		blockStore: &shardedBlockstore{shards: shards, limit: limits.HasCheckConcurrency},
+		builder:    opts.cidBuilder(),
+		limits:     limits,
+		reads:      newReadGroup(),
+		access:     newAccessStats(opts.AccessStats),
+		tracer:     opts.Tracer,
+	}
+
+	if opts.QuotaBytes > 0 {
+		q := &quotaBlockstore{
+			Blockstore: r.blockStore,
+			meta:       stores[0].Datastore(),
+			limit:      opts.QuotaBytes,
+			soft:       int64(float64(opts.QuotaBytes) * opts.QuotaSoftPct / 100),
+		}
+		if err := q.load(ctx); err != nil {
+			_ = r.Close()
+			return nil, fmt.Errorf("failed to load quota usage: %w", stores[0].RedactError(err))
+		}
+		r.blockStore = q
+		r.quota = q
+	}
+
+	if opts.VerifyReads {
+		// 损坏块隔离在保存它的分片中
+		r.blockStore = r.verifyBlockstore(r.blockStore, func(c cid2.Cid) ds.Datastore {
+			return stores[shardIndex(c, len(stores))].Datastore()
+		})
+	}
+
+	r.blockStore = r.watchBlockstore(r.accessBlockstore(r.blockStore))
+	return r.cleanupOnOpen(ctx, opts)
+}
+
+// shardPath 返回错误信息中使用的分片路径，启用 RedactPaths 时只保留目录名。
+func shardPath(path string, opts RepoOptions) string {
+	if opts.RedactPaths {
+		return filepath.Base(path)
+	}
+	return path
+}
+
+// checkShardsPresent 检查分片是否全部初始化或全部未初始化。
+func checkShardsPresent(paths []string, opts RepoOptions) error {
+	initialized := -1
+	for i, path := range paths {
+		if path != "" && storage.FileExists(storage.DatastoreSpecPath(filepath.Clean(path))) {
+			initialized = i
+			break
+		}
+	}
+	if initialized < 0 {
+		return nil
+	}
+
+	for i, path := range paths {
+		if path == "" || !storage.FileExists(storage.DatastoreSpecPath(filepath.Clean(path))) {
+			return &ShardError{Index: i, Path: shardPath(path, opts), Err: ErrShardMissing}
+		}
+	}
+	return nil
+}
+
+// checkShardInfo 校验分片记录的位置和分片数，首次打开时写入记录。
+func checkShardInfo(ctx context.Context, d ds.Datastore, index, count int) error {
+	data, err := d.Get(ctx, shardInfoKey)
+	if errors.Is(err, ds.ErrNotFound) {
+		buf := make([]byte, 8)
+		binary.BigEndian.PutUint32(buf[0:4], uint32(index))
+		binary.BigEndian.PutUint32(buf[4:8], uint32(count))
+		if err := d.Put(ctx, shardInfoKey, buf); err != nil {
+			return fmt.Errorf("failed to record shard layout: %w", err)
+		}
+		return d.Sync(ctx, shardInfoKey)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read shard layout: %w", err)
+	}
+	if len(data) != 8 {
+		return fmt.Errorf("%w: invalid shard record of %d bytes", ErrShardMismatch, len(data))
+	}
+
+	recIndex := int(binary.BigEndian.Uint32(data[0:4]))
+	recCount := int(binary.BigEndian.Uint32(data[4:8]))
+	if recIndex != index || recCount != count {
+		return fmt.Errorf("%w: recorded as shard %d of %d, opened as shard %d of %d",
+			ErrShardMismatch, recIndex, recCount, index, count)
+	}
+	return nil
+}
+
+// shardIndex 返回 c 所在的分片：multihash 摘要前 8 字节（大端）对 n 取模。
+func shardIndex(c cid2.Cid, n int) int {
+	digest := c.Hash()
+	if decoded, err := mh.Decode(digest); err == nil {
+		digest = decoded.Digest
+	}
+
+	var v uint64
+	for i := 0; i < len(digest) && i < 8; i++ {
+		v = v<<8 | uint64(digest[i])
+	}
+	return int(v % uint64(n))
+}
+
+// shardedBlockstore 按 CID 把块操作路由到各个分片。
+type shardedBlockstore struct {
+	shards []blockstore.Blockstore
+	limit  int // PutMany 的最大并发分片数
+}
+
+func (b *shardedBlockstore) shard(c cid2.Cid) blockstore.Blockstore {
+	return b.shards[shardIndex(c, len(b.shards))]
+}
+
+func (b *shardedBlockstore) DeleteBlock(ctx context.Context, c cid2.Cid) error {
+	return b.shard(c).DeleteBlock(ctx, c)
+}
+
+func (b *shardedBlockstore) Has(ctx context.Context, c cid2.Cid) (bool, error) {
+	return b.shard(c).Has(ctx, c)
+}
+
+func (b *shardedBlockstore) Get(ctx context.Context, c cid2.Cid) (blocks.Block, error) {
+	return b.shard(c).Get(ctx, c)
+}
+
+func (b *shardedBlockstore) GetSize(ctx context.Context, c cid2.Cid) (int, error) {
+	return b.shard(c).GetSize(ctx, c)
+}
+
+func (b *shardedBlockstore) Put(ctx context.Context, blk blocks.Block) error {
+	return b.shard(blk.Cid()).Put(ctx, blk)
+}
+
+// PutMany 按分片分组，并发写入各分片。
+func (b *shardedBlockstore) PutMany(ctx context.Context, blks []blocks.Block) error {
+	groups := make([][]blocks.Block, len(b.shards))
+	for _, blk := range blks {
+		i := shardIndex(blk.Cid(), len(b.shards))
+		groups[i] = append(groups[i], blk)
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(b.limit)
+	for i, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		i, group := i, group
+		g.Go(func() error {
+			return b.shards[i].PutMany(ctx, group)
+		})
+	}
+	return g.Wait()
+}
+
+// AllKeysChan 合并所有分片的键。
+func (b *shardedBlockstore) AllKeysChan(ctx context.Context) (<-chan cid2.Cid, error) {
+	chans := make([]<-chan cid2.Cid, len(b.shards))
+	for i, s := range b.shards {
+		ch, err := s.AllKeysChan(ctx)
+		if err != nil {
+			return nil, err
+		}
+		chans[i] = ch
+	}
+
+	out := make(chan cid2.Cid)
+	var wg sync.WaitGroup
+	for _, ch := range chans {
+		wg.Add(1)
+		go func(ch <-chan cid2.Cid) {
+			defer wg.Done()
+			for c := range ch {
+				select {
+				case out <- c:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(ch)
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out, nil
+}
+
+// HashOnRead 转发给支持该设置的分片。
+func (b *shardedBlockstore) HashOnRead(enabled bool) {
+	for _, s := range b.shards {
+		if h, ok := s.(interface{ HashOnRead(bool) }); ok {
+			h.HashOnRead(enabled)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/tragoedia0722/repository/pkg/extractor"
+)
+
+// shardPaths returns n fresh shard directories.
+func shardPaths(t *testing.T, n int) []string {
+	t.Helper()
+
+	paths := make([]string, n)
+	for i := range paths {
+		paths[i] = filepath.Join(t.TempDir(), "shard")
+	}
+	return paths
+}
+
+// createShards creates a sharded repository over paths and closes it again.
+func createShards(t *testing.T, paths []string) {
+	t.Helper()
+
+	repo, err := NewShardedRepository(paths, RepoOptions{})
+	if err != nil {
+		t.Fatalf("NewShardedRepository failed: %v", err)
+	}
+	if err := repo.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+func TestShardedRepository_ImportExtract(t *testing.T) {
+	ctx := context.Background()
+	paths := shardPaths(t, 3)
+
+	repo, err := NewShardedRepository(paths, RepoOptions{})
+	if err != nil {
+		t.Fatalf("NewShardedRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	result := importPackagesFixture(t, repo)
+
+	out := filepath.Join(t.TempDir(), "out")
+	if err := extractor.NewExtractor(repo.BlockStore(), result.RootCid, out).Extract(ctx, false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(out, "d2", "f007.txt"))
+	if err != nil {
+		t.Fatalf("failed to read extracted file: %v", err)
+	}
+	if !bytes.Equal(data, []byte("content 7")) {
+		t.Errorf("extracted content = %q", data)
+	}
+
+	var all []string
+	for _, pkg := range result.Packages {
+		all = append(all, pkg.Blocks...)
+	}
+	has, err := repo.HasAllBlocks(ctx, all)
+	if err != nil || !has {
+		t.Errorf("HasAllBlocks() = %v, %v; want true", has, err)
+	}
+
+	// Every shard holds part of the blocks, and only the blocks routed to it
+	total := 0
+	for i, s := range repo.shards {
+		keys, err := blockstore.NewBlockstore(s.Datastore()).AllKeysChan(ctx)
+		if err != nil {
+			t.Fatalf("AllKeysChan on shard %d failed: %v", i, err)
+		}
+		n := 0
+		for c := range keys {
+			if got := shardIndex(c, len(paths)); got != i {
+				t.Errorf("block %s stored in shard %d, routed to %d", c, i, got)
+			}
+			n++
+		}
+		if n == 0 {
+			t.Errorf("shard %d holds no blocks", i)
+		}
+		total += n
+	}
+	if total != len(all) {
+		t.Errorf("shards hold %d blocks, import produced %d", total, len(all))
+	}
+
+	usage, err := repo.Usage(ctx)
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	for i, s := range repo.shards {
+		shardUsage, err := s.GetStorageUsage(ctx)
+		if err != nil {
+			t.Fatalf("GetStorageUsage on shard %d failed: %v", i, err)
+		}
+		if shardUsage > usage {
+			t.Errorf("shard %d uses %d bytes, more than the total %d", i, shardUsage, usage)
+		}
+	}
+}
+
+func TestShardedRepository_Reopen(t *testing.T) {
+	ctx := context.Background()
+	paths := shardPaths(t, 3)
+
+	repo, err := NewShardedRepository(paths, RepoOptions{})
+	if err != nil {
+		t.Fatalf("NewShardedRepository failed: %v", err)
+	}
+	c, err := repo.PutBlock(ctx, []byte("survives reopening"))
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+	if err := repo.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	repo, err = NewShardedRepository(paths, RepoOptions{})
+	if err != nil {
+		t.Fatalf("reopening failed: %v", err)
+	}
+	defer repo.Close()
+
+	data, err := repo.GetRawDataCid(ctx, *c)
+	if err != nil {
+		t.Fatalf("GetRawDataCid failed: %v", err)
+	}
+	if !bytes.Equal(data, []byte("survives reopening")) {
+		t.Errorf("GetRawDataCid() = %q", data)
+	}
+}
+
+func TestShardedRepository_LayoutMismatch(t *testing.T) {
+	paths := shardPaths(t, 3)
+	createShards(t, paths)
+
+	tests := map[string][]string{
+		"fewer shards":  paths[:2],
+		"more shards":   append(append([]string(nil), paths...), paths[0]),
+		"swapped order": {paths[1], paths[0], paths[2]},
+	}
+	for name, opened := range tests {
+		t.Run(name, func(t *testing.T) {
+			repo, err := NewShardedRepository(opened, RepoOptions{})
+			if err == nil {
+				_ = repo.Close()
+				t.Fatal("opening with a different layout succeeded")
+			}
+			var shardErr *ShardError
+			if !errors.As(err, &shardErr) || !errors.Is(err, ErrShardMismatch) {
+				t.Errorf("error = %v, want a *ShardError wrapping ErrShardMismatch", err)
+			}
+		})
+	}
+
+	// The failed attempts released every shard
+	createShards(t, paths)
+}
+
+func TestShardedRepository_MissingShard(t *testing.T) {
+	paths := shardPaths(t, 3)
+	createShards(t, paths)
+
+	if err := os.RemoveAll(paths[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := NewShardedRepository(paths, RepoOptions{})
+	var shardErr *ShardError
+	if !errors.As(err, &shardErr) || !errors.Is(err, ErrShardMissing) {
+		t.Fatalf("error = %v, want a *ShardError wrapping ErrShardMissing", err)
+	}
+	if shardErr.Index != 2 || shardErr.Path != paths[2] {
+		t.Errorf("error names shard %d (%s), want 2 (%s)", shardErr.Index, shardErr.Path, paths[2])
+	}
+	if _, err := os.Stat(paths[2]); !os.IsNotExist(err) {
+		t.Errorf("missing shard directory was recreated: %v", err)
+	}
+}
+
+func TestShardedRepository_UnusableShard(t *testing.T) {
+	paths := shardPaths(t, 3)
+	if err := os.WriteFile(paths[1], []byte("not a directory"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := NewShardedRepository(paths, RepoOptions{})
+	var shardErr *ShardError
+	if !errors.As(err, &shardErr) || shardErr.Index != 1 || shardErr.Path != paths[1] {
+		t.Fatalf("error = %v, want a *ShardError for shard 1", err)
+	}
+
+	// The shard opened before the failure was closed again, so its lock is free
+	opened := make(chan error, 1)
+	go func() {
+		repo, err := NewRepository(paths[0])
+		if err == nil {
+			err = repo.Close()
+		}
+		opened <- err
+	}()
+	select {
+	case err := <-opened:
+		if err != nil {
+			t.Fatalf("reopening shard 0 failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("shard 0 is still locked after the failed open")
+	}
+}
+
+func TestShardIndex_Distribution(t *testing.T) {
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	counts := make([]int, 4)
+	for i := 0; i < 400; i++ {
+		c, err := repo.builder.Sum([]byte{byte(i), byte(i >> 8)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		idx := shardIndex(c, len(counts))
+		if again := shardIndex(c, len(counts)); again != idx {
+			t.Fatalf("shardIndex is not deterministic: %d and %d", idx, again)
+		}
+		counts[idx]++
+	}
+	for i, n := range counts {
+		if n < 50 {
+			t.Errorf("shard %d got %d of 400 blocks", i, n)
+		}
+	}
+}
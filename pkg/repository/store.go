@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"io"
+
+	"github.com/ipfs/boxo/blockstore"
+	cid2 "github.com/ipfs/go-cid"
+	"github.com/tragoedia0722/repository/internal/storage"
+)
+
+// Datastore 是仓库的元数据存储，即 DataStore 返回的类型。
+type Datastore = storage.Datastore
+
+// Store 是仓库的公开接口，*Repository 实现了它。
+//
+// 依赖仓库的代码可以接受 Store 而不是 *Repository，从而在测试中使用
+// repotest 包提供的内存实现，不必在临时目录中打开真实的仓库。
+// Store 覆盖数据块的读写、存在性检查、变更回调、前台操作标记、用量和
+// 生命周期；导入会话、配额、访问统计等与磁盘仓库相关的功能仍需 *Repository。
+type Store interface {
+	PutBlock(ctx context.Context, bytes []byte) (*cid2.Cid, error)
+	PutBlockWithCid(ctx context.Context, cid string, bytes []byte) error
+	PutManyBlocks(ctx context.Context, bytes [][]byte) ([]*cid2.Cid, error)
+
+	HasBlock(ctx context.Context, cid string) (bool, error)
+	HasBlockCid(ctx context.Context, c cid2.Cid) (bool, error)
+	HasAllBlocks(ctx context.Context, cids []string) (bool, error)
+	HasAllBlockCids(ctx context.Context, cids []cid2.Cid) (bool, error)
+	MissingBlocks(ctx context.Context, cids []string) ([]string, error)
+	MissingBlockCids(ctx context.Context, cids []cid2.Cid) ([]cid2.Cid, error)
+
+	GetRawData(ctx context.Context, cid string) ([]byte, error)
+	GetRawDataCid(ctx context.Context, c cid2.Cid) ([]byte, error)
+	GetBlockReader(ctx context.Context, cid string) (io.ReadCloser, int64, error)
+	GetManyRawData(ctx context.Context, cids []string) (map[string][]byte, []string, error)
+
+	DelBlock(ctx context.Context, cid string) error
+	DelBlockCid(ctx context.Context, c cid2.Cid) error
+
+	OnBlockPut(fn func(c cid2.Cid)) func()
+	OnBlockDeleted(fn func(c cid2.Cid)) func()
+
+	AcquireForeground()
+	ReleaseForeground()
+	ForegroundActive() bool
+
+	BlockStore() blockstore.Blockstore
+	DataStore() Datastore
+	Limits() RepoLimits
+	Usage(ctx context.Context) (uint64, error)
+	Close() error
+	Destroy() error
+}
+
+var _ Store = (*Repository)(nil)
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/tragoedia0722/repository/pkg/tracing"
+	"github.com/tragoedia0722/repository/pkg/tracing/tracingtest"
+)
+
+// TestTracer_Spans 测试 PutBlock、GetRawData 和 HasAllBlocks 的 span 及其属性
+func TestTracer_Spans(t *testing.T) {
+	rec := tracingtest.NewRecorder()
+	repo, err := NewRepositoryWithOptions(t.TempDir(), RepoOptions{Tracer: rec})
+	if err != nil {
+		t.Fatalf("NewRepositoryWithOptions failed: %v", err)
+	}
+	defer repo.Close()
+	repo.limits.GetRetryBaseDelay = time.Millisecond
+
+	ctx, parent := rec.StartSpan(context.Background(), "request")
+	data := []byte("traced block")
+	c, err := repo.PutBlock(ctx, data)
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+	if _, err := repo.GetRawData(ctx, c.String()); err != nil {
+		t.Fatalf("GetRawData failed: %v", err)
+	}
+	if ok, err := repo.HasAllBlocks(ctx, []string{c.String()}); err != nil || !ok {
+		t.Fatalf("HasAllBlocks = %v, %v", ok, err)
+	}
+	missing, err := repo.PutBlock(context.Background(), []byte("deleted"))
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+	if err := repo.DelBlockCid(ctx, *missing); err != nil {
+		t.Fatalf("DelBlockCid failed: %v", err)
+	}
+	if _, err := repo.GetRawDataCid(ctx, *missing); err == nil {
+		t.Fatal("GetRawDataCid of a deleted block succeeded")
+	}
+	parent.End()
+
+	if spans := rec.Unended(); len(spans) != 0 {
+		t.Errorf("%d spans not ended", len(spans))
+	}
+
+	put := rec.Named(tracing.SpanPutBlock)[0]
+	if put.Path() != "request/"+tracing.SpanPutBlock {
+		t.Errorf("PutBlock span path = %s", put.Path())
+	}
+	assertAttr(t, put, tracing.AttrCID, c.String())
+	assertAttr(t, put, tracing.AttrBytes, int64(len(data)))
+
+	gets := rec.Named(tracing.SpanGetRawData)
+	if len(gets) != 2 {
+		t.Fatalf("%d GetRawData spans, want 2", len(gets))
+	}
+	assertAttr(t, gets[0], tracing.AttrBytes, int64(len(data)))
+	assertAttr(t, gets[0], tracing.AttrRetries, int64(0))
+	assertAttr(t, gets[0], tracing.AttrCacheHit, false)
+	assertAttr(t, gets[1], tracing.AttrRetries, int64(DefaultGetRetryAttempts-1))
+	if _, ok := gets[1].Attr(tracing.AttrError); !ok {
+		t.Error("failed GetRawData span has no error attribute")
+	}
+
+	has := rec.Named(tracing.SpanHasAllBlocks)[0]
+	assertAttr(t, has, tracing.AttrBlocks, int64(1))
+	assertAttr(t, has, tracing.AttrFound, true)
+}
+
+// TestTracer_Sharded 测试分片仓库同样使用 RepoOptions.Tracer
+func TestTracer_Sharded(t *testing.T) {
+	rec := tracingtest.NewRecorder()
+	paths := []string{t.TempDir(), t.TempDir()}
+	repo, err := NewShardedRepository(paths, RepoOptions{Tracer: rec})
+	if err != nil {
+		t.Fatalf("NewShardedRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+	c, err := repo.PutBlock(ctx, []byte("sharded block"))
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+	if _, err := repo.GetRawData(ctx, c.String()); err != nil {
+		t.Fatalf("GetRawData failed: %v", err)
+	}
+	if ok, err := repo.HasAllBlocks(ctx, []string{c.String()}); err != nil || !ok {
+		t.Fatalf("HasAllBlocks = %v, %v", ok, err)
+	}
+
+	for _, name := range []string{tracing.SpanPutBlock, tracing.SpanGetRawData, tracing.SpanHasAllBlocks} {
+		if spans := rec.Named(name); len(spans) != 1 {
+			t.Errorf("%d %s spans, want 1", len(spans), name)
+		}
+	}
+	assertAttr(t, rec.Named(tracing.SpanPutBlock)[0], tracing.AttrCID, c.String())
+}
+
+// assertAttr 检查 span 的属性值
+func assertAttr(t *testing.T, s *tracingtest.Span, key string, want any) {
+	t.Helper()
+	if got, ok := s.Attr(key); !ok || got != want {
+		t.Errorf("%s: %s = %v (set %v), want %v", s.Name, key, got, ok, want)
+	}
+}
+
+// BenchmarkGetRawData_Tracer 比较未启用和启用追踪时的读取开销，
+// 未启用时的分配次数应与没有追踪时相同
+func BenchmarkGetRawData_Tracer(b *testing.B) {
+	for _, traced := range []bool{false, true} {
+		b.Run(fmt.Sprintf("tracer=%v", traced), func(b *testing.B) {
+			var opts RepoOptions
+			if traced {
+				opts.Tracer = tracingtest.NewRecorder()
+			}
+			repo, err := NewRepositoryWithOptions(b.TempDir(), opts)
+			if err != nil {
+				b.Fatalf("NewRepositoryWithOptions failed: %v", err)
+			}
+			defer repo.Close()
+
+			ctx := context.Background()
+			c, err := repo.PutBlock(ctx, bytes.Repeat([]byte("t"), 4096))
+			if err != nil {
+				b.Fatalf("PutBlock failed: %v", err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.GetRawDataCid(ctx, *c); err != nil {
+					b.Fatalf("GetRawDataCid failed: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/datastore/dshelp"
+	blocks "github.com/ipfs/go-block-format"
+	cid2 "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	metrics "github.com/ipfs/go-metrics-interface"
+)
+
+// quarantinePrefix 是被隔离的损坏块在 datastore 中的命名空间。
+var quarantinePrefix = ds.NewKey("/quarantine")
+
+// ErrCorruptBlock 表示读取到的块数据与其 CID 不符。
+// 具体的 CID 通过 *CorruptBlockError 获取。
+var ErrCorruptBlock = errors.New("block data does not match its CID")
+
+// CorruptBlockError 描述一次读取时发现的损坏块。
+type CorruptBlockError struct {
+	Cid cid2.Cid // 损坏块的 CID
+	Err error    // 隔离失败的原因；块已被隔离时为 nil
+}
+
+func (e *CorruptBlockError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%v: %s (quarantine failed: %v)", ErrCorruptBlock, e.Cid, e.Err)
+	}
+	return fmt.Sprintf("%v: %s (quarantined)", ErrCorruptBlock, e.Cid)
+}
+
+func (e *CorruptBlockError) Unwrap() []error {
+	if e.Err != nil {
+		return []error{ErrCorruptBlock, e.Err}
+	}
+	return []error{ErrCorruptBlock}
+}
+
+// OnBlockCorrupt 注册发现损坏块并将其隔离后调用的回调。
+//
+// 只有启用 VerifyReads 时才会发现损坏块。回调在读取的 goroutine 中同步调用，
+// 不应阻塞；可以在回调中重新获取或修复该块。
+//
+// 参数：
+//
+//	fn - 回调函数，参数为损坏块的 CID
+//
+// 返回：
+//
+//	func() - 注销回调的函数，可以多次调用
+func (r *Repository) OnBlockCorrupt(fn func(c cid2.Cid)) func() {
+	return r.hooks.add(&r.hooks.corrupt, fn)
+}
+
+// CorruptBlocks 返回启用 VerifyReads 后读取时发现的损坏块数。
+// 同一计数也通过度量指标 ipfs.repository.corrupt_blocks_total 报告。
+//
+// 返回：
+//
+//	uint64 - 自仓库打开以来发现的损坏块数
+//	error - 仓库已关闭时返回 ErrClosed
+func (r *Repository) CorruptBlocks() (uint64, error) {
+	if err := r.life.enter(); err != nil {
+		return 0, err
+	}
+	defer r.life.leave()
+	if r.verify == nil {
+		return 0, nil
+	}
+	return r.verify.corrupt.Load(), nil
+}
+
+// verifyBlockstore 为 bs 加上读取校验，quarantine 返回保存 c 的隔离副本的 datastore。
+func (r *Repository) verifyBlockstore(bs blockstore.Blockstore, quarantine func(c cid2.Cid) ds.Datastore) blockstore.Blockstore {
+	r.verify = &verifyingBlockstore{
+		Blockstore: bs,
+		quarantine: quarantine,
+		hooks:      &r.hooks,
+		counter: metrics.New("ipfs.repository.corrupt_blocks_total",
+			"Number of blocks found corrupt and quarantined on read").Counter(),
+	}
+	return r.verify
+}
+
+// verifyingBlockstore 在返回块之前重新计算其哈希。
+//
+// 哈希与 CID 不符的块被移动到 quarantinePrefix 下（保留原始数据，供事后分析），
+// 并从 blockstore 中删除，之后 Has 返回 false。
+type verifyingBlockstore struct {
+	blockstore.Blockstore
+
+	quarantine func(c cid2.Cid) ds.Datastore
+	hooks      *blockHooks
+	corrupt    atomic.Uint64   // 发现的损坏块数
+	counter    metrics.Counter // 同一计数的度量指标
+}
+
+func (b *verifyingBlockstore) Get(ctx context.Context, c cid2.Cid) (blocks.Block, error) {
+	blk, err := b.Blockstore.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	sum, err := c.Prefix().Sum(blk.RawData())
+	if err == nil && sum.Equals(c) {
+		return blk, nil
+	}
+
+	b.corrupt.Add(1)
+	b.counter.Inc()
+	corruptErr := &CorruptBlockError{Cid: c, Err: b.isolate(ctx, c, blk.RawData())}
+	if corruptErr.Err == nil {
+		b.hooks.notify(&b.hooks.corrupt, c)
+	}
+	return nil, corruptErr
+}
+
+// isolate 把 c 的数据写入隔离区，再从 blockstore 中删除。
+func (b *verifyingBlockstore) isolate(ctx context.Context, c cid2.Cid, data []byte) error {
+	if err := b.quarantine(c).Put(ctx, quarantineKey(c), data); err != nil {
+		return err
+	}
+	return b.Blockstore.DeleteBlock(ctx, c)
+}
+
+// quarantineKey 返回 c 的隔离副本在 datastore 中的键，
+// 其后缀与 blockstore 中块的键相同。
+func quarantineKey(c cid2.Cid) ds.Key {
+	return quarantinePrefix.Child(dshelp.MultihashToDsKey(c.Hash()))
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/datastore/dshelp"
+	cid2 "github.com/ipfs/go-cid"
+)
+
+// corruptBlock overwrites the stored data of c directly in the datastore.
+func corruptBlock(t *testing.T, repo *Repository, c cid2.Cid, data []byte) {
+	t.Helper()
+
+	key := blockstore.BlockPrefix.Child(dshelp.MultihashToDsKey(c.Hash()))
+	if err := repo.DataStore().Put(context.Background(), key, data); err != nil {
+		t.Fatalf("failed to corrupt block: %v", err)
+	}
+}
+
+func TestRepository_VerifyReads(t *testing.T) {
+	ctx := context.Background()
+	repo, err := NewRepositoryWithOptions(t.TempDir(), RepoOptions{VerifyReads: true})
+	if err != nil {
+		t.Fatalf("NewRepositoryWithOptions failed: %v", err)
+	}
+	defer repo.Close()
+
+	good, err := repo.PutBlock(ctx, []byte("intact block"))
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+	bad, err := repo.PutBlock(ctx, []byte("block to corrupt"))
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+
+	var reported []cid2.Cid
+	repo.OnBlockCorrupt(func(c cid2.Cid) { reported = append(reported, c) })
+
+	garbage := []byte("bit rot")
+	corruptBlock(t, repo, *bad, garbage)
+
+	if data, err := repo.GetRawData(ctx, good.String()); err != nil || string(data) != "intact block" {
+		t.Fatalf("GetRawData(intact) = %q, %v", data, err)
+	}
+
+	data, err := repo.GetRawData(ctx, bad.String())
+	if !errors.Is(err, ErrCorruptBlock) {
+		t.Fatalf("GetRawData(corrupt) = %q, %v, want ErrCorruptBlock", data, err)
+	}
+	var corruptErr *CorruptBlockError
+	if !errors.As(err, &corruptErr) || !corruptErr.Cid.Equals(*bad) || corruptErr.Err != nil {
+		t.Errorf("error = %#v, want quarantined *CorruptBlockError for %s", corruptErr, bad)
+	}
+
+	quarantined, err := repo.DataStore().Get(ctx, quarantineKey(*bad))
+	if err != nil {
+		t.Fatalf("quarantine entry missing: %v", err)
+	}
+	if !bytes.Equal(quarantined, garbage) {
+		t.Errorf("quarantine entry = %q, want the corrupt bytes %q", quarantined, garbage)
+	}
+
+	if has, err := repo.HasBlock(ctx, bad.String()); err != nil || has {
+		t.Errorf("HasBlock(corrupt) = %v, %v, want false", has, err)
+	}
+	if has, err := repo.HasBlock(ctx, good.String()); err != nil || !has {
+		t.Errorf("HasBlock(intact) = %v, %v, want true", has, err)
+	}
+
+	if len(reported) != 1 || !reported[0].Equals(*bad) {
+		t.Errorf("OnBlockCorrupt reported %v, want [%s]", reported, bad)
+	}
+	if n, err := repo.CorruptBlocks(); err != nil || n != 1 {
+		t.Errorf("CorruptBlocks() = %d, %v, want 1", n, err)
+	}
+
+	// The block can be stored again after the quarantine
+	if _, err := repo.PutBlock(ctx, []byte("block to corrupt")); err != nil {
+		t.Fatalf("PutBlock after quarantine failed: %v", err)
+	}
+	if data, err := repo.GetRawData(ctx, bad.String()); err != nil || string(data) != "block to corrupt" {
+		t.Errorf("GetRawData(repaired) = %q, %v", data, err)
+	}
+}
+
+func TestRepository_VerifyReads_Disabled(t *testing.T) {
+	ctx := context.Background()
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	c, err := repo.PutBlock(ctx, []byte("block to corrupt"))
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+	corruptBlock(t, repo, *c, []byte("bit rot"))
+
+	// Without verification the stored bytes are returned as they are
+	if data, err := repo.GetRawData(ctx, c.String()); err != nil || string(data) != "bit rot" {
+		t.Errorf("GetRawData = %q, %v", data, err)
+	}
+	if n, err := repo.CorruptBlocks(); err != nil || n != 0 {
+		t.Errorf("CorruptBlocks() = %d, %v, want 0", n, err)
+	}
+}
+
+func TestShardedRepository_VerifyReads(t *testing.T) {
+	ctx := context.Background()
+	paths := []string{t.TempDir(), t.TempDir(), t.TempDir()}
+	repo, err := NewShardedRepository(paths, RepoOptions{VerifyReads: true})
+	if err != nil {
+		t.Fatalf("NewShardedRepository failed: %v", err)
+	}
+	defer repo.Close()
+
+	var cids []cid2.Cid
+	for i := 0; i < 10; i++ {
+		c, err := repo.PutBlock(ctx, []byte(fmt.Sprintf("block %d", i)))
+		if err != nil {
+			t.Fatalf("PutBlock failed: %v", err)
+		}
+		cids = append(cids, *c)
+	}
+
+	for _, c := range cids {
+		shard := repo.shards[shardIndex(c, len(paths))].Datastore()
+		key := blockstore.BlockPrefix.Child(dshelp.MultihashToDsKey(c.Hash()))
+		if err := shard.Put(ctx, key, []byte("bit rot")); err != nil {
+			t.Fatalf("failed to corrupt block: %v", err)
+		}
+
+		if _, err := repo.GetRawDataCid(ctx, c); !errors.Is(err, ErrCorruptBlock) {
+			t.Fatalf("GetRawDataCid(%s) error = %v, want ErrCorruptBlock", c, err)
+		}
+		if _, err := shard.Get(ctx, quarantineKey(c)); err != nil {
+			t.Errorf("quarantine entry of %s missing from its shard: %v", c, err)
+		}
+		if has, _ := repo.HasBlockCid(ctx, c); has {
+			t.Errorf("HasBlockCid(%s) = true after quarantine", c)
+		}
+	}
+}
+
+// Benchmark GetRawData of a 256KB block with and without VerifyReads
+func BenchmarkGetRawData_VerifyReads(b *testing.B) {
+	for _, verify := range []bool{false, true} {
+		b.Run(fmt.Sprintf("verify=%v", verify), func(b *testing.B) {
+			repo, err := NewRepositoryWithOptions(b.TempDir(), RepoOptions{VerifyReads: verify})
+			if err != nil {
+				b.Fatalf("NewRepositoryWithOptions failed: %v", err)
+			}
+			defer repo.Close()
+
+			ctx := context.Background()
+			c, err := repo.PutBlock(ctx, bytes.Repeat([]byte("v"), 256*1024))
+			if err != nil {
+				b.Fatalf("PutBlock failed: %v", err)
+			}
+
+			b.SetBytes(256 * 1024)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.GetRawDataCid(ctx, *c); err != nil {
+					b.Fatalf("GetRawDataCid failed: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/tragoedia0722/repository/internal/storage"
+)
+
+var (
+	// ErrMigrationRequired 表示仓库的磁盘格式较旧，需要先调用 Upgrade。
+	ErrMigrationRequired = storage.ErrMigrationRequired
+
+	// ErrVersionTooNew 表示仓库由更新版本的库创建，无法打开。
+	ErrVersionTooNew = storage.ErrVersionTooNew
+)
+
+// NeedsMigration 检查 path 处的仓库是否需要升级。
+//
+// 参数：
+//
+//	path - 仓库路径
+//
+// 返回：
+//
+//	from - 仓库当前的磁盘格式版本
+//	to - 本库支持的版本
+//	needed - 如果需要调用 Upgrade，返回 true
+func NeedsMigration(path string) (from, to int, needed bool) {
+	return storage.NeedsMigration(path)
+}
+
+// Upgrade 将 path 处的仓库升级到本库支持的磁盘格式版本。
+//
+// 仓库在升级期间不能被打开。升级完成后可以正常调用 NewRepository。
+//
+// 参数：
+//
+//	ctx - 用于取消操作的上下文
+//	path - 仓库路径
+//	progress - 每个升级步骤开始前调用；可以为 nil
+//
+// 返回：
+//
+//	error - 如果升级失败，返回错误
+func Upgrade(ctx context.Context, path string, progress func(step string)) error {
+	return storage.Upgrade(ctx, path, progress)
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/tragoedia0722/repository/internal/storage"
+)
+
+func TestNewRepository_LegacyLayout(t *testing.T) {
+	path := t.TempDir()
+
+	repo, err := NewRepository(path)
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	c, err := repo.PutBlock(context.Background(), []byte("legacy block"))
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+	if err := repo.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// 模拟早期布局：没有 datastore_spec 和版本文件
+	if err := os.Remove(storage.DatastoreSpecPath(path)); err != nil {
+		t.Fatalf("failed to remove spec: %v", err)
+	}
+	if err := os.Remove(storage.VersionFilePath(path)); err != nil {
+		t.Fatalf("failed to remove version file: %v", err)
+	}
+
+	if _, err := NewRepository(path); !errors.Is(err, ErrMigrationRequired) {
+		t.Fatalf("expected ErrMigrationRequired, got %v", err)
+	}
+
+	if from, _, needed := NeedsMigration(path); !needed || from != 0 {
+		t.Fatalf("NeedsMigration = %d, %v; want 0, true", from, needed)
+	}
+
+	if err := Upgrade(context.Background(), path, nil); err != nil {
+		t.Fatalf("Upgrade failed: %v", err)
+	}
+
+	repo, err = NewRepository(path)
+	if err != nil {
+		t.Fatalf("NewRepository after Upgrade failed: %v", err)
+	}
+	defer repo.Close()
+
+	data, err := repo.GetRawData(context.Background(), c.String())
+	if err != nil || string(data) != "legacy block" {
+		t.Errorf("GetRawData = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,71 @@
+// Package oteltracing reports the spans of the repository, the importer and
+// the extractor to OpenTelemetry.
+//
+// It depends only on the OpenTelemetry API; the SDK and the exporters are
+// configured by the application:
+//
+//	tracer := oteltracing.New(otel.Tracer("github.com/tragoedia0722/repository"))
+//	repo, err := repository.NewRepositoryWithOptions(path, repository.RepoOptions{Tracer: tracer})
+//	imp := importer.NewImporter(repo.BlockStore(), src).WithTracer(tracer)
+package oteltracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tragoedia0722/repository/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// New returns a tracing.Tracer that starts its spans on t.
+func New(t trace.Tracer) tracing.Tracer {
+	return &tracer{t: t}
+}
+
+// tracer adapts an OpenTelemetry tracer.
+type tracer struct {
+	t trace.Tracer
+}
+
+func (t *tracer) StartSpan(ctx context.Context, name string) (context.Context, tracing.Span) {
+	ctx, s := t.t.Start(ctx, name)
+	return ctx, span{s: s}
+}
+
+// span adapts an OpenTelemetry span. The error attribute also sets the
+// span status to Error.
+type span struct {
+	s trace.Span
+}
+
+func (s span) SetAttributes(attrs ...tracing.Attribute) {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		kvs = append(kvs, keyValue(a))
+		if a.Key == tracing.AttrError {
+			s.s.SetStatus(codes.Error, fmt.Sprint(a.Value))
+		}
+	}
+	s.s.SetAttributes(kvs...)
+}
+
+func (s span) End() {
+	s.s.End()
+}
+
+// keyValue converts an attribute. Values of unexpected types are reported
+// in their fmt form.
+func keyValue(a tracing.Attribute) attribute.KeyValue {
+	switch v := a.Value.(type) {
+	case string:
+		return attribute.String(a.Key, v)
+	case int64:
+		return attribute.Int64(a.Key, v)
+	case bool:
+		return attribute.Bool(a.Key, v)
+	default:
+		return attribute.String(a.Key, fmt.Sprint(v))
+	}
+}
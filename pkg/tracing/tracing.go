@@ -0,0 +1,89 @@
+// Package tracing lets the repository, the importer and the extractor
+// report spans to a tracing system without depending on one.
+//
+// A Tracer is passed through repository.RepoOptions, Importer.WithTracer
+// and Extractor.WithTracer. Spans nest through the context that already
+// flows through those APIs: a span started from a context carrying another
+// span is its child. The oteltracing package adapts an OpenTelemetry tracer;
+// the tracingtest package records spans for tests.
+//
+// Tracing is disabled by default. Callers check for a nil Tracer before
+// building attributes, so the hot paths allocate nothing when it is off.
+package tracing
+
+import "context"
+
+// Span names reported by this module.
+const (
+	SpanPutBlock      = "repository.PutBlock"
+	SpanGetRawData    = "repository.GetRawData"
+	SpanHasAllBlocks  = "repository.HasAllBlocks"
+	SpanImport        = "importer.Import"
+	SpanImportFile    = "importer.File"
+	SpanImportPutMany = "importer.PutMany"
+	SpanExtract       = "extractor.Extract"
+	SpanExtractFile   = "extractor.File"
+)
+
+// Attribute keys reported by this module.
+const (
+	AttrCID      = "cid"       // CID of the block, file or root
+	AttrPath     = "path"      // Slash-separated path of a file below the root
+	AttrBytes    = "bytes"     // Bytes read or written
+	AttrBlocks   = "blocks"    // Number of blocks in a batch or check
+	AttrRetries  = "retries"   // Retries needed before the operation succeeded or gave up
+	AttrCacheHit = "cache.hit" // Whether the result came from a cache or a concurrent call
+	AttrFound    = "found"     // Result of an existence check
+	AttrError    = "error"     // Error message of a failed operation
+)
+
+// Tracer starts spans.
+type Tracer interface {
+	// StartSpan starts a span named name as a child of the span in ctx, if
+	// any, and returns a context carrying the new span.
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single traced operation. End must be called exactly once.
+type Span interface {
+	// SetAttributes adds attributes to the span, replacing earlier values
+	// of the same keys.
+	SetAttributes(attrs ...Attribute)
+
+	// End completes the span.
+	End()
+}
+
+// Attribute is a key and a value of type string, int64 or bool.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// String returns a string attribute.
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int64 returns an integer attribute.
+func Int64(key string, value int64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int returns an integer attribute, stored as an int64.
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: int64(value)}
+}
+
+// Bool returns a boolean attribute.
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// End sets the error attribute if err is not nil and ends span.
+func End(span Span, err error) {
+	if err != nil {
+		span.SetAttributes(String(AttrError, err.Error()))
+	}
+	span.End()
+}
@@ -0,0 +1,125 @@
+// Package tracingtest provides a tracer that records spans for tests.
+package tracingtest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/tragoedia0722/repository/pkg/tracing"
+)
+
+// Recorder is a tracing.Tracer that keeps every span it starts. It is safe
+// for concurrent use.
+type Recorder struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+var _ tracing.Tracer = (*Recorder)(nil)
+
+// Span is a span recorded by a Recorder.
+type Span struct {
+	Name   string
+	Parent *Span // Span found in the context at start; nil for a root span
+
+	rec   *Recorder
+	attrs map[string]any
+	ended bool
+}
+
+// spanKey is the context key of the current span.
+type spanKey struct{}
+
+// NewRecorder returns an empty Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// StartSpan records a new span as a child of the span in ctx.
+func (r *Recorder) StartSpan(ctx context.Context, name string) (context.Context, tracing.Span) {
+	parent, _ := ctx.Value(spanKey{}).(*Span)
+	s := &Span{Name: name, Parent: parent, rec: r, attrs: make(map[string]any)}
+
+	r.mu.Lock()
+	r.spans = append(r.spans, s)
+	r.mu.Unlock()
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Spans returns the recorded spans in start order.
+func (r *Recorder) Spans() []*Span {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Span(nil), r.spans...)
+}
+
+// Named returns the recorded spans called name, in start order.
+func (r *Recorder) Named(name string) []*Span {
+	var out []*Span
+	for _, s := range r.Spans() {
+		if s.Name == name {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Unended returns the spans that were started but not ended.
+func (r *Recorder) Unended() []*Span {
+	var out []*Span
+	for _, s := range r.Spans() {
+		if !s.Ended() {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// SetAttributes records attrs, replacing earlier values of the same keys.
+func (s *Span) SetAttributes(attrs ...tracing.Attribute) {
+	s.rec.mu.Lock()
+	defer s.rec.mu.Unlock()
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+// End marks the span as ended. Ending a span twice panics.
+func (s *Span) End() {
+	s.rec.mu.Lock()
+	defer s.rec.mu.Unlock()
+	if s.ended {
+		panic(fmt.Sprintf("tracingtest: span %s ended twice", s.Name))
+	}
+	s.ended = true
+}
+
+// Ended reports whether End was called.
+func (s *Span) Ended() bool {
+	s.rec.mu.Lock()
+	defer s.rec.mu.Unlock()
+	return s.ended
+}
+
+// Attr returns the value of the attribute key and whether it was set.
+func (s *Span) Attr(key string) (any, bool) {
+	s.rec.mu.Lock()
+	defer s.rec.mu.Unlock()
+	v, ok := s.attrs[key]
+	return v, ok
+}
+
+// Path returns the names of the span and its ancestors from the root, such
+// as "importer.Import/importer.File/importer.PutMany".
+func (s *Span) Path() string {
+	var names []string
+	for p := s; p != nil; p = p.Parent {
+		names = append(names, p.Name)
+	}
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return strings.Join(names, "/")
+}
@@ -0,0 +1,53 @@
+package tracingtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tragoedia0722/repository/pkg/tracing"
+)
+
+func TestRecorder_Nesting(t *testing.T) {
+	rec := NewRecorder()
+	ctx, root := rec.StartSpan(context.Background(), "root")
+	_, child := rec.StartSpan(ctx, "child")
+	_, sibling := rec.StartSpan(ctx, "sibling")
+
+	child.SetAttributes(tracing.Int("n", 1), tracing.Int("n", 2), tracing.Bool("ok", true))
+	tracing.End(child, errors.New("boom"))
+	sibling.End()
+
+	if got := rec.Named("child")[0].Path(); got != "root/child" {
+		t.Errorf("Path() = %q, want root/child", got)
+	}
+	if got := rec.Named("sibling")[0].Parent; got == nil || got.Name != "root" {
+		t.Errorf("sibling parent = %v, want root", got)
+	}
+	if v, _ := rec.Named("child")[0].Attr("n"); v != int64(2) {
+		t.Errorf("n = %v, want the later value 2", v)
+	}
+	if v, _ := rec.Named("child")[0].Attr(tracing.AttrError); v != "boom" {
+		t.Errorf("error = %v, want boom", v)
+	}
+
+	unended := rec.Unended()
+	if len(unended) != 1 || unended[0].Name != "root" {
+		t.Errorf("Unended() = %v, want only root", unended)
+	}
+	root.End()
+	if len(rec.Spans()) != 3 || len(rec.Unended()) != 0 {
+		t.Errorf("spans = %d, unended = %d", len(rec.Spans()), len(rec.Unended()))
+	}
+}
+
+func TestSpan_EndTwicePanics(t *testing.T) {
+	_, s := NewRecorder().StartSpan(context.Background(), "once")
+	s.End()
+	defer func() {
+		if recover() == nil {
+			t.Error("second End did not panic")
+		}
+	}()
+	s.End()
+}
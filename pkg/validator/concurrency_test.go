@@ -9,11 +9,12 @@ import (
 	"time"
 
 	blocks "github.com/ipfs/go-block-format"
+	"github.com/tragoedia0722/repository/pkg/repository/repotest"
 )
 
 // TestConcurrent_ValidateOperations tests concurrent validation operations
 func TestConcurrent_ValidateOperations(t *testing.T) {
-	bs := newMockBlockstore()
+	bs := repotest.New().BlockStore()
 	v := NewValidator(bs)
 
 	// Create test blocks
@@ -144,7 +145,7 @@ func TestConcurrent_ResultModifications(t *testing.T) {
 
 // TestConcurrent_MixedOperations tests mixed concurrent operations
 func TestConcurrent_MixedOperations(t *testing.T) {
-	bs := newMockBlockstore()
+	bs := repotest.New().BlockStore()
 	v := NewValidator(bs)
 
 	// Create test blocks
@@ -205,7 +206,7 @@ func TestConcurrent_MixedOperations(t *testing.T) {
 
 // TestConcurrent_ContextCancellation tests concurrent context cancellation
 func TestConcurrent_ContextCancellation(t *testing.T) {
-	bs := newMockBlockstore()
+	bs := repotest.New().BlockStore()
 	v := NewValidator(bs)
 
 	// Create test blocks
@@ -278,7 +279,7 @@ func TestConcurrent_ContextCancellation(t *testing.T) {
 
 // TestConcurrent_ValidateSameData tests concurrent validation of same data
 func TestConcurrent_ValidateSameData(t *testing.T) {
-	bs := newMockBlockstore()
+	bs := repotest.New().BlockStore()
 	v := NewValidator(bs)
 
 	// Create test blocks
@@ -396,7 +397,7 @@ func TestConcurrent_StressTest(t *testing.T) {
 		t.Skip("skipping stress test in short mode")
 	}
 
-	bs := newMockBlockstore()
+	bs := repotest.New().BlockStore()
 	v := NewValidator(bs)
 
 	// Create test blocks
@@ -488,7 +489,7 @@ func TestConcurrent_RaceConditions(t *testing.T) {
 	})
 
 	t.Run("concurrent_validate_and_result_modify", func(t *testing.T) {
-		bs := newMockBlockstore()
+		bs := repotest.New().BlockStore()
 		v := NewValidator(bs)
 
 		// Create blocks
@@ -540,7 +541,7 @@ func TestConcurrent_MultipleValidators(t *testing.T) {
 
 	validators := make([]*Validator, numValidators)
 	for i := 0; i < numValidators; i++ {
-		bs := newMockBlockstore()
+		bs := repotest.New().BlockStore()
 		validators[i] = NewValidator(bs)
 
 		// Create same blocks for each validator
@@ -563,7 +564,7 @@ func TestConcurrent_MultipleValidators(t *testing.T) {
 				v := validators[j%numValidators]
 
 				// Create a simple block list with a new blockstore
-				bs := newMockBlockstore()
+				bs := repotest.New().BlockStore()
 				block := blocks.NewBlock([]byte{byte(j)})
 				bs.Put(context.Background(), block)
 
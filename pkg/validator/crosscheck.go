@@ -0,0 +1,90 @@
+package validator
+
+import (
+	"context"
+	"sort"
+
+	"github.com/ipfs/go-cid"
+	"github.com/tragoedia0722/repository/pkg/importer"
+)
+
+// CrossCheckResult compares the blocks reachable from a root with the blocks
+// listed in its package manifest.
+type CrossCheckResult struct {
+	// UnderPackaged contains blocks reachable from the root but listed in no
+	// package; a sync driven by the manifest never transfers them
+	UnderPackaged []string
+
+	// OverPackaged contains blocks listed in packages but not reachable from
+	// the root, such as blocks of a stale manifest or a foreign tree
+	OverPackaged []string
+
+	// Unresolved contains reachable blocks that are missing or could not be
+	// decoded. Blocks below them are unknown, so OverPackaged may contain
+	// blocks that are in fact reachable through them
+	Unresolved []string
+
+	// Reachable is the number of distinct blocks reachable from the root,
+	// including the unresolved ones
+	Reachable int
+
+	// Listed is the number of distinct blocks listed in the packages
+	Listed int
+
+	// NeedsRepair is set when UnderPackaged or OverPackaged is not empty,
+	// meaning the manifest does not describe the DAG and must be rebuilt
+	NeedsRepair bool
+}
+
+// CrossCheck compares the blocks reachable from rootCid with the blocks
+// listed in packages.
+//
+// Validate checks that the listed blocks exist and that the DAG is complete,
+// but not that both describe the same set of blocks; CrossCheck finds a
+// manifest that does not match the DAG, for example after a re-import or
+// tampering. Missing blocks do not abort the walk, they are reported in
+// Unresolved. All lists are sorted.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout
+//   - rootCid: The root CID of the DAG
+//   - packages: The package manifest of the root
+//
+// Returns:
+//   - *CrossCheckResult: The blocks found in only one of the two sets
+//   - error: Any critical error that prevents the comparison
+func (v *Validator) CrossCheck(ctx context.Context, rootCid string, packages []importer.Package) (*CrossCheckResult, error) {
+	tree, reachable, err := v.walkTree(ctx, rootCid)
+	if err != nil {
+		return nil, err
+	}
+
+	listed := make(map[string]bool)
+	for _, pkg := range packages {
+		for _, block := range pkg.Blocks {
+			listed[block] = true
+		}
+	}
+
+	result := &CrossCheckResult{
+		Unresolved: append(append([]string(nil), tree.MissingBlocks...), tree.InvalidBlocks...),
+		Reachable:  reachable.Len(),
+		Listed:     len(listed),
+	}
+	for _, block := range reachable.Strings() {
+		if !listed[block] {
+			result.UnderPackaged = append(result.UnderPackaged, block)
+		}
+	}
+	for block := range listed {
+		if c, err := cid.Decode(block); err != nil || !reachable.Has(c) {
+			result.OverPackaged = append(result.OverPackaged, block)
+		}
+	}
+
+	sort.Strings(result.UnderPackaged)
+	sort.Strings(result.OverPackaged)
+	sort.Strings(result.Unresolved)
+	result.NeedsRepair = len(result.UnderPackaged) > 0 || len(result.OverPackaged) > 0
+	return result, nil
+}
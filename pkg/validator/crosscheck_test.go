@@ -0,0 +1,89 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tragoedia0722/repository/pkg/importer"
+	"github.com/tragoedia0722/repository/pkg/packaging"
+)
+
+// importFiles imports n files of several blocks each and returns the result.
+func importFiles(t *testing.T, bs *mockBlockstore, prefix string, n int) *importer.Result {
+	t.Helper()
+
+	src := t.TempDir()
+	for i := 0; i < n; i++ {
+		content := strings.Repeat(fmt.Sprintf("%s %d;", prefix, i), 300000)
+		if err := os.WriteFile(filepath.Join(src, fmt.Sprintf("f%d.txt", i)), []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	res, err := importer.NewImporter(bs, src).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	return res
+}
+
+// blocksOf returns every block listed in packages.
+func blocksOf(packages []packaging.Package) []string {
+	var blocks []string
+	for _, pkg := range packages {
+		blocks = append(blocks, pkg.Blocks...)
+	}
+	return blocks
+}
+
+func TestValidator_CrossCheck(t *testing.T) {
+	bs := newMockBlockstore()
+	res := importFiles(t, bs, "file", 3)
+	foreign := importFiles(t, bs, "other", 1)
+	total := len(blocksOf(res.Packages))
+	v := NewValidator(bs)
+
+	got, err := v.CrossCheck(context.Background(), res.RootCid, res.Packages)
+	if err != nil {
+		t.Fatalf("CrossCheck failed: %v", err)
+	}
+	want := &CrossCheckResult{Reachable: total, Listed: total}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CrossCheck() = %+v, want %+v", got, want)
+	}
+
+	// Drop a block from a package: a sync would never transfer it
+	dropped := res.Packages[0].Blocks[1]
+	under := append([]packaging.Package(nil), res.Packages...)
+	under[0] = packaging.Calc(append(append([]string(nil), under[0].Blocks[:1]...), under[0].Blocks[2:]...))
+
+	got, err = v.CrossCheck(context.Background(), res.RootCid, under)
+	if err != nil {
+		t.Fatalf("CrossCheck failed: %v", err)
+	}
+	want = &CrossCheckResult{UnderPackaged: []string{dropped}, Reachable: total, Listed: total - 1, NeedsRepair: true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CrossCheck(dropped block) = %+v, want %+v", got, want)
+	}
+
+	// Append a block of another tree
+	over := append(append([]packaging.Package(nil), res.Packages...), packaging.Calc([]string{foreign.RootCid}))
+
+	got, err = v.CrossCheck(context.Background(), res.RootCid, over)
+	if err != nil {
+		t.Fatalf("CrossCheck failed: %v", err)
+	}
+	want = &CrossCheckResult{OverPackaged: []string{foreign.RootCid}, Reachable: total, Listed: total + 1, NeedsRepair: true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CrossCheck(foreign block) = %+v, want %+v", got, want)
+	}
+
+	if _, err := v.CrossCheck(context.Background(), "", res.Packages); err == nil {
+		t.Error("expected error for empty root CID")
+	}
+}
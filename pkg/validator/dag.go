@@ -0,0 +1,226 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/tragoedia0722/repository/internal/dagwalk"
+)
+
+const (
+	// DefaultMaxDAGDepth is the default limit on the number of nodes on a
+	// path from the root in ValidateDAG
+	DefaultMaxDAGDepth = 4096
+
+	// DefaultMaxDAGBlocks is the default limit on the number of distinct
+	// blocks ValidateDAG visits, about 1 GB of memory for the visited set
+	DefaultMaxDAGBlocks = 10_000_000
+)
+
+// ErrDAGLimit is returned by ValidateDAG when the DAG is deeper or has more
+// blocks than the limits set with WithDAGLimits allow.
+var ErrDAGLimit = errors.New("DAG exceeds the validation limits")
+
+// WithDAGLimits bounds the DAGs ValidateDAG walks: maxDepth is the number of
+// nodes on the longest path from the root and maxBlocks the number of
+// distinct blocks, which determines the memory the walk needs. Values <= 0
+// restore DefaultMaxDAGDepth and DefaultMaxDAGBlocks.
+// Returns the validator for method chaining.
+func (v *Validator) WithDAGLimits(maxDepth, maxBlocks int) *Validator {
+	v.maxDepth = maxDepth
+	v.maxBlocks = maxBlocks
+	return v
+}
+
+// ValidateDAG validates the structure of the DAG under rootCid.
+//
+// Unlike Validate, which checks a list of blocks against the blocks the walk
+// finds, ValidateDAG confirms that the blocks in the blockstore form a
+// complete DAG: every dag-pb node is decoded, including its UnixFS data, and
+// its links are followed recursively. A child that is not in the blockstore
+// is recorded in MissingBlocks, a node that cannot be decoded, or whose
+// UnixFS block sizes do not match its links, in InvalidBlocks. A link back
+// to a node on its own path is reported as a cycle, with the linking node in
+// InvalidBlocks, and not followed. Raw leaves are only checked for presence.
+// IsComplete and CanRestore are set only if every reachable node is present
+// and decodes successfully. The walk follows the UnixFS structure, so the
+// result also has a RepairPlan that places every missing and invalid block
+// in the file it belongs to.
+//
+// The walk is depth-first and serial. It fails with an error wrapping
+// ErrDAGLimit once the DAG is deeper or has more blocks than allowed, see
+// WithDAGLimits, so an adversarial DAG cannot exhaust memory.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout
+//   - rootCid: The root CID of the DAG to validate
+//
+// Returns:
+//   - *Result: Detailed validation results
+//   - error: Any critical error that prevents validation, including ErrDAGLimit
+func (v *Validator) ValidateDAG(ctx context.Context, rootCid string) (*Result, error) {
+	if rootCid == "" {
+		return nil, fmt.Errorf("root CID cannot be empty")
+	}
+	root, err := cid.Decode(rootCid)
+	if err != nil {
+		return nil, fmt.Errorf("invalid root CID %q: %w", rootCid, err)
+	}
+
+	w := &dagValidation{
+		v:         v,
+		result:    v.newResult(nil),
+		visited:   dagwalk.NewSet(),
+		onPath:    make(map[cid.Cid]bool),
+		maxDepth:  v.maxDepth,
+		maxBlocks: v.maxBlocks,
+	}
+	if w.maxDepth <= 0 {
+		w.maxDepth = DefaultMaxDAGDepth
+	}
+	if w.maxBlocks <= 0 {
+		w.maxBlocks = DefaultMaxDAGBlocks
+	}
+
+	w.result.planned = true
+	if err := w.walk(ctx, root); err != nil {
+		return nil, err
+	}
+	w.result.CanRestore = true
+	w.result.finalize()
+	return w.result, nil
+}
+
+// dagValidation is the state of one ValidateDAG walk.
+type dagValidation struct {
+	v         *Validator
+	result    *Result
+	visited   *dagwalk.Set
+	onPath    map[cid.Cid]bool // Nodes on the path from the root to the current node
+	maxDepth  int
+	maxBlocks int
+}
+
+// dagFrame is a node on the path of the walk with its links not yet
+// followed and their places in the UnixFS structure.
+type dagFrame struct {
+	cid       cid.Cid
+	pos       dagPos
+	links     []*ipld.Link
+	positions []dagPos
+}
+
+// walk visits root and everything below it.
+func (w *dagValidation) walk(ctx context.Context, root cid.Cid) error {
+	var path []dagFrame
+	enter := func(c cid.Cid, pos dagPos) error {
+		if w.visited.Len() >= w.maxBlocks {
+			return fmt.Errorf("%w: more than %d blocks", ErrDAGLimit, w.maxBlocks)
+		}
+		if len(path) >= w.maxDepth {
+			return fmt.Errorf("%w: deeper than %d nodes at %s", ErrDAGLimit, w.maxDepth, c)
+		}
+		w.visited.Visit(c)
+		links, fsn := w.check(ctx, c, pos)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if len(links) > 0 {
+			path = append(path, dagFrame{cid: c, pos: pos, links: links, positions: childPositions(pos, fsn, links)})
+			w.onPath[c] = true
+		}
+		return nil
+	}
+
+	if err := enter(root, dagPos{size: -1}); err != nil {
+		return err
+	}
+	for len(path) > 0 {
+		top := &path[len(path)-1]
+		if len(top.links) == 0 {
+			delete(w.onPath, top.cid)
+			path = path[:len(path)-1]
+			continue
+		}
+		next, pos := top.links[0].Cid, top.positions[0]
+		top.links, top.positions = top.links[1:], top.positions[1:]
+
+		if w.onPath[next] {
+			w.result.addInvalidBlock(top.cid.String())
+			w.result.addRepair(top.cid.String(), top.pos)
+			w.result.addError("link cycle: block %s links to %s on its own path", top.cid, next)
+			continue
+		}
+		if w.visited.Has(next) {
+			continue
+		}
+		if err := enter(next, pos); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check reads and decodes the block c at pos, records a problem with it in
+// the result and returns its links and UnixFS data, nil for a node without.
+func (w *dagValidation) check(ctx context.Context, c cid.Cid, pos dagPos) ([]*ipld.Link, *unixfs.FSNode) {
+	if c.Type() == cid.Raw {
+		size, err := w.v.blockStore.GetSize(ctx, c)
+		if err != nil {
+			w.fail(c, pos, err)
+			return nil, nil
+		}
+		w.result.ReachableSize += int64(size)
+		return nil, nil
+	}
+
+	nd, err := w.v.dagService.Get(ctx, c)
+	if err != nil {
+		w.fail(c, pos, err)
+		return nil, nil
+	}
+	w.result.ReachableSize += int64(len(nd.RawData()))
+
+	pn, ok := nd.(*merkledag.ProtoNode)
+	if !ok {
+		return nd.Links(), nil
+	}
+	fsn, err := checkUnixFS(pn)
+	if err != nil {
+		w.fail(c, pos, err)
+		return nil, nil
+	}
+	return nd.Links(), fsn
+}
+
+// fail records the error reading or decoding c at pos.
+func (w *dagValidation) fail(c cid.Cid, pos dagPos, err error) {
+	w.result.addRepair(c.String(), pos)
+	if ipld.IsNotFound(err) {
+		w.result.addMissingBlock(c.String())
+		return
+	}
+	w.result.addInvalidBlock(c.String())
+	w.result.addError("failed to decode block %s: %v", c, err)
+}
+
+// checkUnixFS checks that the data of a dag-pb node is UnixFS and that a
+// file node has a block size for every link, and returns the decoded data.
+func checkUnixFS(pn *merkledag.ProtoNode) (*unixfs.FSNode, error) {
+	fsn, err := unixfs.FSNodeFromBytes(pn.Data())
+	if err != nil {
+		return nil, fmt.Errorf("invalid UnixFS data: %w", err)
+	}
+	switch fsn.Type() {
+	case unixfs.TFile, unixfs.TRaw:
+		if fsn.NumChildren() != len(pn.Links()) {
+			return nil, fmt.Errorf("%d block sizes for %d links", fsn.NumChildren(), len(pn.Links()))
+		}
+	}
+	return fsn, nil
+}
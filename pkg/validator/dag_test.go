@@ -0,0 +1,132 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	mh "github.com/multiformats/go-multihash"
+)
+
+func TestValidator_ValidateDAG(t *testing.T) {
+	ctx := context.Background()
+	bs := newMockBlockstore()
+	res := importFiles(t, bs, "dag", 3)
+	root := res.RootCid
+
+	v := NewValidator(bs)
+	result, err := v.ValidateDAG(ctx, root)
+	if err != nil {
+		t.Fatalf("ValidateDAG failed: %v", err)
+	}
+	if !result.IsComplete || !result.CanRestore || result.ReachableSize == 0 {
+		t.Fatalf("complete DAG: IsComplete = %v, CanRestore = %v, ReachableSize = %d", result.IsComplete, result.CanRestore, result.ReachableSize)
+	}
+
+	// A leaf below the first file is missing, which listing the blocks
+	// that are present does not reveal
+	rootCid, _ := cid.Decode(root)
+	dir, err := v.dagService.Get(ctx, rootCid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := v.dagService.Get(ctx, dir.Links()[0].Cid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := file.Links()[0].Cid
+	if err := bs.DeleteBlock(ctx, leaf); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = v.ValidateDAG(ctx, root)
+	if err != nil {
+		t.Fatalf("ValidateDAG failed: %v", err)
+	}
+	if result.IsComplete || result.CanRestore {
+		t.Error("DAG with a missing leaf reported as complete")
+	}
+	if want := []string{leaf.String()}; !reflect.DeepEqual(result.MissingBlocks, want) {
+		t.Errorf("MissingBlocks = %v, want %v", result.MissingBlocks, want)
+	}
+}
+
+func TestValidator_ValidateDAG_Cycle(t *testing.T) {
+	ctx := context.Background()
+	bs := newMockBlockstore()
+
+	// The blockstore does not check hashes, so a node can be stored under
+	// a CID that one of its own descendants links to
+	hash, err := mh.Sum([]byte("cycle"), mh.SHA2_256, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	top := cid.NewCidV1(cid.DagProtobuf, hash)
+
+	child := merkledag.NodeWithData(unixfs.FolderPBData())
+	if err := child.AddRawLink("back", &ipld.Link{Cid: top}); err != nil {
+		t.Fatal(err)
+	}
+	parent := merkledag.NodeWithData(unixfs.FolderPBData())
+	if err := parent.AddNodeLink("child", child); err != nil {
+		t.Fatal(err)
+	}
+	blk, err := blocks.NewBlockWithCid(parent.RawData(), top)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bs.Put(ctx, blk); err != nil {
+		t.Fatal(err)
+	}
+	if err := bs.Put(ctx, child); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewValidator(bs).ValidateDAG(ctx, top.String())
+	if err != nil {
+		t.Fatalf("ValidateDAG failed: %v", err)
+	}
+	if result.IsComplete {
+		t.Error("DAG with a cycle reported as complete")
+	}
+	if want := []string{child.Cid().String()}; !reflect.DeepEqual(result.InvalidBlocks, want) {
+		t.Errorf("InvalidBlocks = %v, want %v", result.InvalidBlocks, want)
+	}
+	if len(result.ErrorDetails) != 1 || !strings.Contains(result.ErrorDetails[0], "cycle") {
+		t.Errorf("ErrorDetails = %v, want the cycle", result.ErrorDetails)
+	}
+}
+
+func TestValidator_ValidateDAG_Limits(t *testing.T) {
+	ctx := context.Background()
+	bs := newMockBlockstore()
+	root := importFiles(t, bs, "limits", 2).RootCid
+
+	tests := []struct {
+		name      string
+		maxDepth  int
+		maxBlocks int
+	}{
+		{name: "depth", maxDepth: 2},
+		{name: "blocks", maxBlocks: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewValidator(bs).WithDAGLimits(tt.maxDepth, tt.maxBlocks).ValidateDAG(ctx, root)
+			if !errors.Is(err, ErrDAGLimit) {
+				t.Errorf("ValidateDAG error = %v, want ErrDAGLimit", err)
+			}
+		})
+	}
+
+	if _, err := NewValidator(bs).WithDAGLimits(3, 0).ValidateDAG(ctx, root); err != nil {
+		t.Errorf("ValidateDAG within the limits failed: %v", err)
+	}
+}
@@ -8,11 +8,12 @@ import (
 	"time"
 
 	blocks "github.com/ipfs/go-block-format"
+	"github.com/tragoedia0722/repository/pkg/repository/repotest"
 )
 
 // TestEdgeCases_EmptyInputs tests various empty input scenarios
 func TestEdgeCases_EmptyInputs(t *testing.T) {
-	bs := newMockBlockstore()
+	bs := repotest.New().BlockStore()
 	v := NewValidator(bs)
 
 	t.Run("empty_blocks_slice", func(t *testing.T) {
@@ -59,7 +60,7 @@ func TestEdgeCases_EmptyInputs(t *testing.T) {
 
 // TestEdgeCases_SingleBlock tests single block scenarios
 func TestEdgeCases_SingleBlock(t *testing.T) {
-	bs := newMockBlockstore()
+	bs := repotest.New().BlockStore()
 	v := NewValidator(bs)
 
 	t.Run("single_valid_block", func(t *testing.T) {
@@ -93,7 +94,7 @@ func TestEdgeCases_SingleBlock(t *testing.T) {
 
 // TestEdgeCases_SpecialCharacters tests blocks with special characters
 func TestEdgeCases_SpecialCharacters(t *testing.T) {
-	bs := newMockBlockstore()
+	bs := repotest.New().BlockStore()
 	v := NewValidator(bs)
 
 	t.Run("block_with_null_bytes", func(t *testing.T) {
@@ -144,7 +145,7 @@ func TestEdgeCases_SpecialCharacters(t *testing.T) {
 
 // TestEdgeCases_DuplicateCIDs tests duplicate CID handling
 func TestEdgeCases_DuplicateCIDs(t *testing.T) {
-	bs := newMockBlockstore()
+	bs := repotest.New().BlockStore()
 	v := NewValidator(bs)
 
 	block := blocks.NewBlock([]byte("test data"))
@@ -176,7 +177,7 @@ func TestEdgeCases_LargeBlockList(t *testing.T) {
 		t.Skip("skipping large block list test in short mode")
 	}
 
-	bs := newMockBlockstore()
+	bs := repotest.New().BlockStore()
 	v := NewValidator(bs)
 
 	// Create 1000 blocks
@@ -207,7 +208,7 @@ func TestEdgeCases_LargeBlockList(t *testing.T) {
 
 // TestEdgeCases_ContextCancellation tests context cancellation behavior
 func TestEdgeCases_ContextCancellation(t *testing.T) {
-	bs := newMockBlockstore()
+	bs := repotest.New().BlockStore()
 	v := NewValidator(bs)
 
 	// Create a block
@@ -251,7 +252,7 @@ func TestEdgeCases_ContextCancellation(t *testing.T) {
 
 // TestEdgeCases_MixedValidInvalidCIDs tests mixed valid and invalid CIDs
 func TestEdgeCases_MixedValidInvalidCIDs(t *testing.T) {
-	bs := newMockBlockstore()
+	bs := repotest.New().BlockStore()
 	v := NewValidator(bs)
 
 	// Create valid blocks
@@ -302,7 +303,7 @@ func TestEdgeCases_MixedValidInvalidCIDs(t *testing.T) {
 
 // TestEdgeCases_ResultFinalization tests Result.finalize() behavior
 func TestEdgeCases_ResultFinalization(t *testing.T) {
-	bs := newMockBlockstore()
+	bs := repotest.New().BlockStore()
 	v := NewValidator(bs)
 
 	t.Run("complete_validation", func(t *testing.T) {
@@ -369,7 +370,7 @@ func TestEdgeCases_ResultFinalization(t *testing.T) {
 
 // TestEdgeCases_VaryingBlockSizes tests blocks of varying sizes
 func TestEdgeCases_VaryingBlockSizes(t *testing.T) {
-	bs := newMockBlockstore()
+	bs := repotest.New().BlockStore()
 	v := NewValidator(bs)
 
 	sizes := []int{1, 10, 100, 1024, 10240, 102400}
@@ -401,7 +402,7 @@ func TestEdgeCases_VaryingBlockSizes(t *testing.T) {
 
 // TestEdgeCases_InvalidCIDFormats tests various invalid CID formats
 func TestEdgeCases_InvalidCIDFormats(t *testing.T) {
-	bs := newMockBlockstore()
+	bs := repotest.New().BlockStore()
 	v := NewValidator(bs)
 
 	validBlock := blocks.NewBlock([]byte("valid"))
@@ -448,7 +449,7 @@ func TestEdgeCases_InvalidCIDFormats(t *testing.T) {
 
 // TestEdgeCases_AllBlocksMissing tests when all provided blocks are missing
 func TestEdgeCases_AllBlocksMissing(t *testing.T) {
-	bs := newMockBlockstore()
+	bs := repotest.New().BlockStore()
 	v := NewValidator(bs)
 
 	// Create a block that exists
@@ -0,0 +1,324 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	"github.com/tragoedia0722/repository/pkg/importer"
+	"github.com/tragoedia0722/repository/pkg/packaging"
+)
+
+// packageIssuePrefix marks package findings in Result.ErrorDetails.
+const packageIssuePrefix = "package: "
+
+// PackageIssueKind identifies the kind of problem found in a package manifest.
+type PackageIssueKind string
+
+const (
+	// PackageHashMismatch means the recorded hash does not match the block list.
+	PackageHashMismatch PackageIssueKind = "hash_mismatch"
+
+	// PackageDuplicateBlock means a block is listed more than once.
+	PackageDuplicateBlock PackageIssueKind = "duplicate_block"
+
+	// PackageUnsortedBlocks means blocks are not in ascending order across the
+	// manifest, so it was not produced by the importer.
+	PackageUnsortedBlocks PackageIssueKind = "unsorted_blocks"
+)
+
+// PackageIssue describes a single problem found by VerifyPackages.
+type PackageIssue struct {
+	Kind  PackageIssueKind
+	Index int // Index of the package in which the problem was found
+
+	// Expected and Actual are the recorded and recomputed hashes (PackageHashMismatch)
+	Expected string
+	Actual   string
+
+	// Block is the offending block (PackageDuplicateBlock, PackageUnsortedBlocks)
+	Block string
+
+	// FirstIndex is the package that first listed Block (PackageDuplicateBlock)
+	FirstIndex int
+}
+
+// String returns a human-readable description of the issue.
+func (i PackageIssue) String() string {
+	switch i.Kind {
+	case PackageHashMismatch:
+		return fmt.Sprintf("package %d: hash mismatch: expected %s, actual %s", i.Index, i.Expected, i.Actual)
+	case PackageDuplicateBlock:
+		return fmt.Sprintf("package %d: duplicate block %s (first listed in package %d)", i.Index, i.Block, i.FirstIndex)
+	case PackageUnsortedBlocks:
+		return fmt.Sprintf("package %d: block %s is out of order", i.Index, i.Block)
+	default:
+		return fmt.Sprintf("package %d: %s", i.Index, i.Kind)
+	}
+}
+
+// VerifyPackages checks a package manifest without a blockstore.
+//
+// It recomputes every package hash with the importer's algorithm and reports
+// mismatches, blocks listed more than once, and blocks that break the
+// ascending order the importer always produces. Only the first out-of-order
+// block of each package is reported. A nil result means the manifest is
+// consistent.
+func VerifyPackages(packages []packaging.Package) []PackageIssue {
+	var issues []PackageIssue
+
+	firstSeen := make(map[string]int)
+	prev := ""
+
+	for i, pkg := range packages {
+		if actual := packaging.Calc(pkg.Blocks).Hash; actual != pkg.Hash {
+			issues = append(issues, PackageIssue{
+				Kind:     PackageHashMismatch,
+				Index:    i,
+				Expected: pkg.Hash,
+				Actual:   actual,
+			})
+		}
+
+		unsortedReported := false
+		for _, block := range pkg.Blocks {
+			if first, ok := firstSeen[block]; ok {
+				issues = append(issues, PackageIssue{
+					Kind:       PackageDuplicateBlock,
+					Index:      i,
+					Block:      block,
+					FirstIndex: first,
+				})
+				continue
+			}
+			firstSeen[block] = i
+
+			if block < prev && !unsortedReported {
+				issues = append(issues, PackageIssue{
+					Kind:  PackageUnsortedBlocks,
+					Index: i,
+					Block: block,
+				})
+				unsortedReported = true
+			}
+			prev = block
+		}
+	}
+
+	return issues
+}
+
+// ValidateResult validates the DAG and package manifest of an import result.
+//
+// It runs Validate with every block listed in the result's packages, then
+// verifies the manifest with VerifyPackages. Package issues are added to
+// Result.ErrorDetails with a "package: " prefix and mark the result incomplete.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout
+//   - res: The import result to validate
+//
+// Returns:
+//   - *Result: Detailed validation results
+//   - error: Any critical error that prevents validation
+func (v *Validator) ValidateResult(ctx context.Context, res *importer.Result) (*Result, error) {
+	if res == nil {
+		return nil, fmt.Errorf("import result cannot be nil")
+	}
+
+	blocks := make([]string, 0)
+	for _, pkg := range res.Packages {
+		blocks = append(blocks, pkg.Blocks...)
+	}
+
+	result, err := v.Validate(ctx, res.RootCid, blocks)
+	if err != nil {
+		return nil, err
+	}
+
+	if issues := VerifyPackages(res.Packages); len(issues) > 0 {
+		for _, issue := range issues {
+			result.addError("%s%s", packageIssuePrefix, issue)
+		}
+		result.mu.Lock()
+		result.IsComplete = false
+		result.mu.Unlock()
+	}
+
+	return result, nil
+}
+
+// PackageStatus is the outcome of validating a single package.
+type PackageStatus string
+
+const (
+	// PackageStatusIntact means every block is present and the recomputed hash matches.
+	PackageStatusIntact PackageStatus = "intact"
+
+	// PackageStatusMissingBlocks means at least one block is absent or its CID is invalid.
+	PackageStatusMissingBlocks PackageStatus = "missing_blocks"
+
+	// PackageStatusHashMismatch means every block is present but the
+	// recomputed hash differs from the recorded one.
+	PackageStatusHashMismatch PackageStatus = "hash_mismatch"
+)
+
+// PackageReport is the validation outcome of one package.
+type PackageReport struct {
+	Index  int    // Index of the package in the manifest
+	Hash   string // Recorded package hash
+	Status PackageStatus
+
+	// ActualHash is the hash recomputed from the listed blocks. It is only
+	// set when every block is present, otherwise it would mean nothing
+	ActualHash string
+
+	// MissingBlocks contains listed blocks not found in the blockstore
+	MissingBlocks []string
+
+	// InvalidBlocks contains listed blocks whose CID could not be decoded
+	InvalidBlocks []string
+
+	// Unreachable contains listed blocks that are not reachable from the
+	// root. It is only filled when the whole DAG could be walked, see
+	// CrossCheckResult.Unresolved
+	Unreachable []string
+}
+
+// PackageResult contains the per-package results of ValidatePackages.
+type PackageResult struct {
+	// Reports has one entry per package, in manifest order
+	Reports []PackageReport
+
+	// Intact, Missing and Mismatched hold the indexes of the packages with
+	// the corresponding status, in ascending order
+	Intact     []int
+	Missing    []int
+	Mismatched []int
+
+	// Manifest compares the listed blocks with the blocks reachable from
+	// the root
+	Manifest *CrossCheckResult
+
+	// IsComplete indicates that every package is intact and the manifest
+	// describes the DAG of the root
+	IsComplete bool
+}
+
+// Broken returns the indexes of all packages that are not intact, in
+// ascending order. These are the packages to re-request from a peer.
+func (r *PackageResult) Broken() []int {
+	broken := make([]int, 0, len(r.Missing)+len(r.Mismatched))
+	for _, report := range r.Reports {
+		if report.Status != PackageStatusIntact {
+			broken = append(broken, report.Index)
+		}
+	}
+	return broken
+}
+
+// ValidatePackages validates each package of a manifest independently.
+//
+// For every package it checks which listed blocks are present in the
+// blockstore and recomputes the package hash with the importer's algorithm.
+// A package with an absent or undecodable block is reported as
+// PackageStatusMissingBlocks; a package whose blocks are all present but
+// whose hash differs is reported as PackageStatusHashMismatch.
+//
+// The manifest is also checked against rootCid with CrossCheck, so a
+// manifest of another root, whose packages may all be intact, is not
+// reported complete. Listed blocks not reachable from the root are reported
+// in PackageReport.Unreachable; they cannot be fixed by re-requesting the
+// package, the manifest itself must be rebuilt.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout
+//   - rootCid: The root CID the manifest belongs to
+//   - pkgs: The package manifest to validate
+//
+// Returns:
+//   - *PackageResult: Per-package validation results
+//   - error: Any critical error that prevents validation
+func (v *Validator) ValidatePackages(ctx context.Context, rootCid string, pkgs []importer.Package) (*PackageResult, error) {
+	manifest, err := v.CrossCheck(ctx, rootCid, pkgs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Blocks below unresolved ones are unknown, so nothing is unreachable for sure
+	unreachable := make(map[string]bool)
+	if len(manifest.Unresolved) == 0 {
+		for _, block := range manifest.OverPackaged {
+			unreachable[block] = true
+		}
+	}
+
+	result := &PackageResult{
+		Reports:  make([]PackageReport, 0, len(pkgs)),
+		Manifest: manifest,
+	}
+
+	for i, pkg := range pkgs {
+		report, err := v.validatePackage(ctx, i, pkg)
+		if err != nil {
+			return nil, fmt.Errorf("package %d: %w", i, err)
+		}
+		for _, block := range pkg.Blocks {
+			if unreachable[block] {
+				report.Unreachable = append(report.Unreachable, block)
+			}
+		}
+
+		switch report.Status {
+		case PackageStatusIntact:
+			result.Intact = append(result.Intact, i)
+		case PackageStatusMissingBlocks:
+			result.Missing = append(result.Missing, i)
+		case PackageStatusHashMismatch:
+			result.Mismatched = append(result.Mismatched, i)
+		}
+		result.Reports = append(result.Reports, report)
+	}
+
+	result.IsComplete = len(result.Missing) == 0 && len(result.Mismatched) == 0 && !manifest.NeedsRepair
+	return result, nil
+}
+
+// validatePackage checks the blocks of a single package and recomputes its hash.
+func (v *Validator) validatePackage(ctx context.Context, index int, pkg importer.Package) (PackageReport, error) {
+	report := PackageReport{Index: index, Hash: pkg.Hash}
+
+	for _, block := range pkg.Blocks {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		c, err := cid.Decode(block)
+		if err != nil {
+			report.InvalidBlocks = append(report.InvalidBlocks, block)
+			continue
+		}
+
+		has, err := v.blockStore.Has(ctx, c)
+		if err != nil {
+			return report, fmt.Errorf("error checking block %s: %w", block, err)
+		}
+		if !has {
+			report.MissingBlocks = append(report.MissingBlocks, block)
+		}
+	}
+
+	if len(report.MissingBlocks) > 0 || len(report.InvalidBlocks) > 0 {
+		report.Status = PackageStatusMissingBlocks
+		return report, nil
+	}
+
+	report.ActualHash = packaging.Calc(pkg.Blocks).Hash
+	if report.ActualHash != pkg.Hash {
+		report.Status = PackageStatusHashMismatch
+	} else {
+		report.Status = PackageStatusIntact
+	}
+
+	return report, nil
+}
@@ -0,0 +1,220 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/tragoedia0722/repository/pkg/importer"
+	"github.com/tragoedia0722/repository/pkg/packaging"
+)
+
+// manifest builds packages of two blocks each over blocks "b00".."bNN".
+func manifest(n int) []packaging.Package {
+	blocks := make([]string, n)
+	for i := range blocks {
+		blocks[i] = fmt.Sprintf("b%02d", i)
+	}
+	return packaging.Split(blocks, 2)
+}
+
+func TestVerifyPackages_Valid(t *testing.T) {
+	if issues := VerifyPackages(manifest(7)); issues != nil {
+		t.Errorf("expected no issues, got %v", issues)
+	}
+	if issues := VerifyPackages(nil); issues != nil {
+		t.Errorf("expected no issues for empty manifest, got %v", issues)
+	}
+}
+
+func TestVerifyPackages_HashMismatch(t *testing.T) {
+	packages := manifest(6)
+	stale := packages[1].Hash
+
+	// Edit the block list without updating the hash
+	packages[1].Blocks = []string{"b02", "b03x"}
+	actual := packaging.Calc(packages[1].Blocks).Hash
+
+	want := []PackageIssue{{Kind: PackageHashMismatch, Index: 1, Expected: stale, Actual: actual}}
+	if got := VerifyPackages(packages); !reflect.DeepEqual(got, want) {
+		t.Errorf("VerifyPackages() = %v, want %v", got, want)
+	}
+}
+
+func TestVerifyPackages_Ordering(t *testing.T) {
+	packages := manifest(6)
+
+	// Swap blocks across packages and recompute hashes so only ordering is wrong
+	packages[0].Blocks = []string{"b00", "b02"}
+	packages[1].Blocks = []string{"b01", "b03"}
+	for i := range packages {
+		packages[i].Hash = packaging.Calc(packages[i].Blocks).Hash
+	}
+
+	want := []PackageIssue{{Kind: PackageUnsortedBlocks, Index: 1, Block: "b01"}}
+	if got := VerifyPackages(packages); !reflect.DeepEqual(got, want) {
+		t.Errorf("VerifyPackages() = %v, want %v", got, want)
+	}
+}
+
+func TestVerifyPackages_Duplicates(t *testing.T) {
+	packages := []packaging.Package{
+		packaging.Calc([]string{"b00", "b01"}),
+		packaging.Calc([]string{"b01", "b02"}),
+		packaging.Calc([]string{"b03", "b03"}),
+	}
+
+	want := []PackageIssue{
+		{Kind: PackageDuplicateBlock, Index: 1, Block: "b01", FirstIndex: 0},
+		{Kind: PackageDuplicateBlock, Index: 2, Block: "b03", FirstIndex: 2},
+	}
+	if got := VerifyPackages(packages); !reflect.DeepEqual(got, want) {
+		t.Errorf("VerifyPackages() = %v, want %v", got, want)
+	}
+}
+
+func TestValidator_ValidateResult(t *testing.T) {
+	src := t.TempDir()
+	for i := 0; i < 3; i++ {
+		content := strings.Repeat(fmt.Sprintf("file %d;", i), 50000)
+		if err := os.WriteFile(filepath.Join(src, fmt.Sprintf("f%d.txt", i)), []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	bs := newMockBlockstore()
+	res, err := importer.NewImporter(bs, src).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	v := NewValidator(bs)
+
+	result, err := v.ValidateResult(context.Background(), res)
+	if err != nil {
+		t.Fatalf("ValidateResult failed: %v", err)
+	}
+	if !result.IsComplete || len(result.ErrorDetails) != 0 {
+		t.Fatalf("expected untampered result to be complete, got %+v", result.ErrorDetails)
+	}
+
+	// Tamper with the first package hash; every block is still present
+	res.Packages[0].Hash = strings.Repeat("0", 64)
+
+	result, err = v.ValidateResult(context.Background(), res)
+	if err != nil {
+		t.Fatalf("ValidateResult failed: %v", err)
+	}
+	if result.IsComplete {
+		t.Error("expected tampered manifest to be incomplete")
+	}
+	if len(result.MissingBlocks) != 0 {
+		t.Errorf("expected no missing blocks, got %v", result.MissingBlocks)
+	}
+	if len(result.ErrorDetails) != 1 || !strings.HasPrefix(result.ErrorDetails[0], "package: package 0: hash mismatch") {
+		t.Errorf("unexpected error details: %v", result.ErrorDetails)
+	}
+
+	if _, err := v.ValidateResult(context.Background(), nil); err == nil {
+		t.Error("expected error for nil result")
+	}
+}
+
+func TestValidator_ValidatePackages(t *testing.T) {
+	src := t.TempDir()
+	for i := 0; i < 3; i++ {
+		content := strings.Repeat(fmt.Sprintf("file %d;", i), 50000)
+		if err := os.WriteFile(filepath.Join(src, fmt.Sprintf("f%d.txt", i)), []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	bs := newMockBlockstore()
+	res, err := importer.NewImporter(bs, src).Import(context.Background())
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	// Split into small packages so each failure lands in its own package
+	var listed []string
+	for _, pkg := range res.Packages {
+		listed = append(listed, pkg.Blocks...)
+	}
+	packages := packaging.Split(listed, 2)
+	if len(packages) < 3 {
+		t.Fatalf("expected at least 3 packages, got %d", len(packages))
+	}
+
+	v := NewValidator(bs)
+
+	result, err := v.ValidatePackages(context.Background(), res.RootCid, packages)
+	if err != nil {
+		t.Fatalf("ValidatePackages failed: %v", err)
+	}
+	if !result.IsComplete || len(result.Intact) != len(packages) {
+		t.Fatalf("expected all packages intact, got %+v", result)
+	}
+
+	// A package of a block outside the DAG is intact but makes the manifest wrong
+	foreign := blocks.NewBlock([]byte("foreign"))
+	if err := bs.Put(context.Background(), foreign); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	extra := append(packages, packaging.Calc([]string{foreign.Cid().String()}))
+	result, err = v.ValidatePackages(context.Background(), res.RootCid, extra)
+	if err != nil {
+		t.Fatalf("ValidatePackages failed: %v", err)
+	}
+	if result.IsComplete || !result.Manifest.NeedsRepair || len(result.Intact) != len(extra) {
+		t.Errorf("expected intact packages in an incomplete manifest, got %+v", result)
+	}
+	if got := result.Reports[len(packages)].Unreachable; !reflect.DeepEqual(got, []string{foreign.Cid().String()}) {
+		t.Errorf("Unreachable = %v, want [%s]", got, foreign.Cid())
+	}
+
+	// Package 0: stale hash, package 1: a deleted block
+	packages[0].Hash = strings.Repeat("0", 64)
+	missing := packages[1].Blocks[1]
+	c, _ := cid.Decode(missing)
+	if err := bs.DeleteBlock(context.Background(), c); err != nil {
+		t.Fatalf("DeleteBlock failed: %v", err)
+	}
+
+	result, err = v.ValidatePackages(context.Background(), res.RootCid, packages)
+	if err != nil {
+		t.Fatalf("ValidatePackages failed: %v", err)
+	}
+	if result.IsComplete {
+		t.Error("expected result to be incomplete")
+	}
+	if !reflect.DeepEqual(result.Mismatched, []int{0}) {
+		t.Errorf("Mismatched = %v, want [0]", result.Mismatched)
+	}
+	if !reflect.DeepEqual(result.Missing, []int{1}) {
+		t.Errorf("Missing = %v, want [1]", result.Missing)
+	}
+	if !reflect.DeepEqual(result.Broken(), []int{0, 1}) {
+		t.Errorf("Broken() = %v, want [0 1]", result.Broken())
+	}
+
+	report := result.Reports[1]
+	if !reflect.DeepEqual(report.MissingBlocks, []string{missing}) {
+		t.Errorf("MissingBlocks = %v, want [%s]", report.MissingBlocks, missing)
+	}
+	if report.Status != PackageStatusMissingBlocks || report.ActualHash != "" {
+		t.Errorf("report = %+v, want missing blocks and no actual hash", report)
+	}
+	if result.Reports[0].ActualHash != packaging.Calc(packages[0].Blocks).Hash {
+		t.Error("expected recomputed hash for tampered package")
+	}
+
+	if _, err := v.ValidatePackages(context.Background(), "", packages); err == nil {
+		t.Error("expected error for empty root CID")
+	}
+}
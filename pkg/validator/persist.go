@@ -0,0 +1,79 @@
+package validator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+// validationKeyPrefix is the datastore namespace holding persisted validation records.
+const validationKeyPrefix = "/validations"
+
+// ValidationRecord is the persisted form of a Result for a single root CID.
+type ValidationRecord struct {
+	RootCid       string    `json:"rootCid"`
+	CheckedAt     time.Time `json:"checkedAt"`
+	IsComplete    bool      `json:"isComplete"`
+	CanRestore    bool      `json:"canRestore"`
+	ReachableSize int64     `json:"reachableSize"`
+	MissingBlocks []string  `json:"missingBlocks,omitempty"`
+	InvalidBlocks []string  `json:"invalidBlocks,omitempty"`
+	ErrorDetails  []string  `json:"errorDetails,omitempty"`
+}
+
+// validationKey returns the datastore key of the validation record for rootCid.
+func validationKey(rootCid string) ds.Key {
+	return ds.NewKey(validationKeyPrefix).ChildString(rootCid)
+}
+
+// SaveValidation persists the outcome of validating rootCid into the datastore,
+// replacing any previous record for the same root.
+func SaveValidation(ctx context.Context, store ds.Datastore, rootCid string, result *Result, checkedAt time.Time) error {
+	if rootCid == "" {
+		return fmt.Errorf("root CID cannot be empty")
+	}
+	if result == nil {
+		return fmt.Errorf("result cannot be nil")
+	}
+
+	result.mu.Lock()
+	record := ValidationRecord{
+		RootCid:       rootCid,
+		CheckedAt:     checkedAt.UTC(),
+		IsComplete:    result.IsComplete,
+		CanRestore:    result.CanRestore,
+		ReachableSize: result.ReachableSize,
+		MissingBlocks: append([]string(nil), result.MissingBlocks...),
+		InvalidBlocks: append([]string(nil), result.InvalidBlocks...),
+		ErrorDetails:  append([]string(nil), result.ErrorDetails...),
+	}
+	result.mu.Unlock()
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode validation record: %w", err)
+	}
+
+	if err := store.Put(ctx, validationKey(rootCid), data); err != nil {
+		return fmt.Errorf("failed to save validation record: %w", err)
+	}
+	return nil
+}
+
+// LoadValidation returns the most recently saved validation record for rootCid.
+// It returns ds.ErrNotFound if the root has never been validated.
+func LoadValidation(ctx context.Context, store ds.Datastore, rootCid string) (*ValidationRecord, error) {
+	data, err := store.Get(ctx, validationKey(rootCid))
+	if err != nil {
+		return nil, err
+	}
+
+	var record ValidationRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode validation record: %w", err)
+	}
+	return &record, nil
+}
@@ -0,0 +1,325 @@
+package validator
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tragoedia0722/repository/pkg/packaging"
+)
+
+const (
+	// ReportSchemaVersion is the schema version of the JSON written by RenderJSON.
+	// It is increased whenever a field is removed or changes meaning.
+	ReportSchemaVersion = 1
+
+	// defaultRenderItems is the number of list entries Render shows unless
+	// RenderOptions.MaxItems or ShowAll says otherwise.
+	defaultRenderItems = 10
+)
+
+// RenderOptions controls the plain-text report written by Result.Render.
+type RenderOptions struct {
+	// ShowAll lists every block, package and error instead of truncating lists
+	ShowAll bool
+
+	// MaxItems is the number of entries shown per list; 0 uses 10
+	MaxItems int
+
+	// TotalBlocks is the number of blocks that were checked. When 0, it is
+	// taken from Packages if present; otherwise counts are shown without totals.
+	TotalBlocks int
+
+	// Packages is the package manifest the result was validated against. When
+	// set, the report includes a per-package breakdown of missing and invalid blocks.
+	Packages []packaging.Package
+}
+
+// Report is the machine-readable form of a Result written by RenderJSON.
+//
+// Block lists and error details are sorted so the output is stable across runs.
+type Report struct {
+	SchemaVersion int      `json:"schemaVersion"`
+	IsComplete    bool     `json:"isComplete"`
+	CanRestore    bool     `json:"canRestore"`
+	ReachableSize int64    `json:"reachableSize"`
+	MissingBlocks []string `json:"missingBlocks"`
+	InvalidBlocks []string `json:"invalidBlocks"`
+	ErrorDetails  []string `json:"errorDetails"`
+}
+
+// report takes a sorted snapshot of the result.
+func (r *Result) report() Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return Report{
+		SchemaVersion: ReportSchemaVersion,
+		IsComplete:    r.IsComplete,
+		CanRestore:    r.CanRestore,
+		ReachableSize: r.ReachableSize,
+		MissingBlocks: sortedCopy(r.MissingBlocks),
+		InvalidBlocks: sortedCopy(r.InvalidBlocks),
+		ErrorDetails:  sortedCopy(r.ErrorDetails),
+	}
+}
+
+// RenderJSON writes the result as indented JSON with a schema version.
+//
+// Rendering does not touch the blockstore, so it can run anywhere the Result is
+// available.
+func (r *Result) RenderJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r.report())
+}
+
+// Render writes a human-readable plain-text report of the result.
+//
+// The report has a one-line summary followed by counts, percentages and the
+// sorted lists of missing and invalid blocks, truncated to opts.MaxItems
+// entries unless opts.ShowAll is set. When opts.Packages is given, a
+// per-package breakdown lists the packages containing missing or invalid
+// blocks. Package manifest findings from ValidateResult get their own section.
+//
+// Output for the same Result and options is byte-for-byte identical, and
+// rendering does not touch the blockstore.
+func (r *Result) Render(w io.Writer, opts RenderOptions) error {
+	rep := r.report()
+
+	total := opts.TotalBlocks
+	if total == 0 && len(opts.Packages) > 0 {
+		total = countBlocks(opts.Packages)
+	}
+	limit := opts.MaxItems
+	if limit <= 0 {
+		limit = defaultRenderItems
+	}
+	if opts.ShowAll {
+		limit = -1
+	}
+
+	var pkgIssues, errs []string
+	for _, detail := range rep.ErrorDetails {
+		if issue, ok := strings.CutPrefix(detail, packageIssuePrefix); ok {
+			pkgIssues = append(pkgIssues, issue)
+		} else {
+			errs = append(errs, detail)
+		}
+	}
+	affected := affectedPackages(opts.Packages, rep.MissingBlocks, rep.InvalidBlocks)
+
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "Validation report")
+	fmt.Fprintf(bw, "Summary:        %s\n", summaryLine(rep, total, affected))
+	fmt.Fprintf(bw, "Complete:       %s\n", yesNo(rep.IsComplete))
+	fmt.Fprintf(bw, "Can restore:    %s\n", yesNo(rep.CanRestore))
+	fmt.Fprintf(bw, "Reachable size: %s (%s bytes)\n", formatSize(rep.ReachableSize), formatCount(int(rep.ReachableSize)))
+	if total > 0 {
+		fmt.Fprintf(bw, "Blocks checked: %s\n", formatCount(total))
+	}
+	fmt.Fprintf(bw, "Missing blocks: %s\n", countOf(len(rep.MissingBlocks), total))
+	fmt.Fprintf(bw, "Invalid blocks: %s\n", countOf(len(rep.InvalidBlocks), total))
+
+	writeList(bw, "Missing blocks", rep.MissingBlocks, limit)
+	writeList(bw, "Invalid blocks", rep.InvalidBlocks, limit)
+
+	if len(affected) > 0 {
+		lines := make([]string, len(affected))
+		for i, p := range affected {
+			lines[i] = p.String()
+		}
+		writeList(bw, fmt.Sprintf("Affected packages (%d of %d)", len(affected), len(opts.Packages)), lines, limit)
+	}
+
+	writeList(bw, "Package issues", pkgIssues, limit)
+	writeList(bw, "Errors", errs, limit)
+
+	return bw.Flush()
+}
+
+// packageDamage counts the missing and invalid blocks of one package.
+type packageDamage struct {
+	index   int
+	hash    string
+	blocks  int
+	missing int
+	invalid int
+}
+
+func (p packageDamage) String() string {
+	return fmt.Sprintf("package %d (%s): %d missing, %d invalid of %s blocks",
+		p.index, shortHash(p.hash), p.missing, p.invalid, formatCount(p.blocks))
+}
+
+// affectedPackages returns the packages containing missing or invalid blocks,
+// in manifest order.
+func affectedPackages(packages []packaging.Package, missing, invalid []string) []packageDamage {
+	if len(packages) == 0 {
+		return nil
+	}
+
+	missingSet := make(map[string]bool, len(missing))
+	for _, b := range missing {
+		missingSet[b] = true
+	}
+	invalidSet := make(map[string]bool, len(invalid))
+	for _, b := range invalid {
+		invalidSet[b] = true
+	}
+
+	var affected []packageDamage
+	for i, pkg := range packages {
+		d := packageDamage{index: i, hash: pkg.Hash, blocks: len(pkg.Blocks)}
+		for _, b := range pkg.Blocks {
+			if missingSet[b] {
+				d.missing++
+			}
+			if invalidSet[b] {
+				d.invalid++
+			}
+		}
+		if d.missing > 0 || d.invalid > 0 {
+			affected = append(affected, d)
+		}
+	}
+	return affected
+}
+
+// summaryLine returns the one-line summary of a report, such as
+// "missing 12 of 4,096 blocks across 2 packages, first missing: bafy...".
+func summaryLine(rep Report, total int, affected []packageDamage) string {
+	if rep.IsComplete {
+		if total > 0 {
+			return fmt.Sprintf("all %s blocks present and valid", formatCount(total))
+		}
+		return "all blocks present and valid"
+	}
+
+	var parts []string
+	if n := len(rep.MissingBlocks); n > 0 {
+		if total > 0 {
+			parts = append(parts, fmt.Sprintf("missing %s of %s blocks", formatCount(n), formatCount(total)))
+		} else {
+			parts = append(parts, fmt.Sprintf("missing %s blocks", formatCount(n)))
+		}
+	}
+	if n := len(rep.InvalidBlocks); n > 0 {
+		parts = append(parts, fmt.Sprintf("%s invalid blocks", formatCount(n)))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "incomplete")
+	}
+
+	line := strings.Join(parts, " and ")
+	if len(affected) > 0 {
+		line += fmt.Sprintf(" across %d %s", len(affected), plural(len(affected), "package", "packages"))
+	}
+	if len(rep.MissingBlocks) > 0 {
+		line += ", first missing: " + rep.MissingBlocks[0]
+	} else if len(rep.InvalidBlocks) > 0 {
+		line += ", first invalid: " + rep.InvalidBlocks[0]
+	}
+	return line
+}
+
+// writeList writes a titled, indented list truncated to limit entries; a
+// negative limit writes every entry. Empty lists are omitted.
+func writeList(w io.Writer, title string, items []string, limit int) {
+	if len(items) == 0 {
+		return
+	}
+
+	shown := items
+	if limit >= 0 && len(items) > limit {
+		shown = items[:limit]
+		fmt.Fprintf(w, "\n%s (showing %d of %s):\n", title, limit, formatCount(len(items)))
+	} else {
+		fmt.Fprintf(w, "\n%s:\n", title)
+	}
+	for _, item := range shown {
+		fmt.Fprintf(w, "  %s\n", item)
+	}
+	if rest := len(items) - len(shown); rest > 0 {
+		fmt.Fprintf(w, "  ... and %s more (use ShowAll to list all)\n", formatCount(rest))
+	}
+}
+
+// countBlocks returns the number of distinct blocks in a package manifest.
+func countBlocks(packages []packaging.Package) int {
+	seen := make(map[string]struct{})
+	for _, pkg := range packages {
+		for _, b := range pkg.Blocks {
+			seen[b] = struct{}{}
+		}
+	}
+	return len(seen)
+}
+
+// countOf formats n, with its share of total when total is known.
+func countOf(n, total int) string {
+	if total <= 0 {
+		return formatCount(n)
+	}
+	return fmt.Sprintf("%s of %s (%.2f%%)", formatCount(n), formatCount(total), float64(n)*100/float64(total))
+}
+
+// formatCount formats n with comma thousands separators.
+func formatCount(n int) string {
+	if n < 0 {
+		return "-" + formatCount(-n)
+	}
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// formatSize formats a byte count with binary units.
+func formatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+// shortHash shortens a package hash for display.
+func shortHash(hash string) string {
+	if len(hash) <= 12 {
+		return hash
+	}
+	return hash[:12]
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+// sortedCopy returns a sorted copy of s, never nil.
+func sortedCopy(s []string) []string {
+	out := append(make([]string, 0, len(s)), s...)
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,144 @@
+package validator
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tragoedia0722/repository/pkg/packaging"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// syntheticBlock returns a fake, sortable block CID for rendering tests.
+func syntheticBlock(i int) string {
+	return fmt.Sprintf("bafkreisynthetic%04d", i)
+}
+
+// syntheticPackages splits n synthetic blocks into packages of size.
+func syntheticPackages(n, size int) []packaging.Package {
+	blocks := make([]string, n)
+	for i := range blocks {
+		blocks[i] = syntheticBlock(i)
+	}
+	return packaging.Split(blocks, size)
+}
+
+// checkGolden compares got with testdata/name, rewriting it with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatalf("failed to create testdata: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s mismatch\n--- got ---\n%s\n--- want ---\n%s", name, got, want)
+	}
+}
+
+func renderScenarios() map[string]struct {
+	result *Result
+	opts   RenderOptions
+} {
+	packages := syntheticPackages(250, 100)
+
+	// Missing blocks are listed out of order, as a concurrent walk reports them.
+	missing := []string{syntheticBlock(212), syntheticBlock(3), syntheticBlock(150), syntheticBlock(7)}
+	for i := 20; i < 30; i++ {
+		missing = append(missing, syntheticBlock(i))
+	}
+
+	return map[string]struct {
+		result *Result
+		opts   RenderOptions
+	}{
+		"complete": {
+			result: &Result{IsComplete: true, CanRestore: true, ReachableSize: 3 * 1024 * 1024},
+			opts:   RenderOptions{Packages: packages},
+		},
+		"missing": {
+			result: &Result{
+				MissingBlocks: missing,
+				ReachableSize: 1536,
+				ErrorDetails:  []string{"error checking block bafkreisynthetic0099: timeout"},
+			},
+			opts: RenderOptions{Packages: packages},
+		},
+		"missing_show_all": {
+			result: &Result{MissingBlocks: missing, ReachableSize: 1536},
+			opts:   RenderOptions{Packages: packages, ShowAll: true},
+		},
+		"invalid": {
+			result: &Result{
+				InvalidBlocks: []string{"not-a-cid", "Qm-broken"},
+				ErrorDetails: []string{
+					"invalid CID: not-a-cid",
+					"invalid CID: Qm-broken",
+					packageIssuePrefix + "package 1: hash mismatch: expected aaaa, actual bbbb",
+				},
+			},
+			opts: RenderOptions{TotalBlocks: 4096},
+		},
+	}
+}
+
+func TestResult_Render_Golden(t *testing.T) {
+	for name, sc := range renderScenarios() {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := sc.result.Render(&buf, sc.opts); err != nil {
+				t.Fatalf("Render failed: %v", err)
+			}
+			checkGolden(t, "render_"+name+".txt", buf.Bytes())
+		})
+	}
+}
+
+func TestResult_RenderJSON_Golden(t *testing.T) {
+	for name, sc := range renderScenarios() {
+		if name == "missing_show_all" {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := sc.result.RenderJSON(&buf); err != nil {
+				t.Fatalf("RenderJSON failed: %v", err)
+			}
+			checkGolden(t, "render_"+name+".json", buf.Bytes())
+		})
+	}
+}
+
+func TestResult_Render_DoesNotReorderResult(t *testing.T) {
+	result := &Result{MissingBlocks: []string{"b", "a"}}
+	if err := result.Render(&bytes.Buffer{}, RenderOptions{}); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if result.MissingBlocks[0] != "b" {
+		t.Error("Render sorted the result's own slice")
+	}
+}
+
+func TestFormatCount(t *testing.T) {
+	tests := map[int]string{0: "0", 999: "999", 1000: "1,000", 4096: "4,096", 1234567: "1,234,567", -1234: "-1,234"}
+	for n, want := range tests {
+		if got := formatCount(n); got != want {
+			t.Errorf("formatCount(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package validator
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/ipfs/boxo/ipld/unixfs"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// RepairBlock is a block that has to be fetched to repair a DAG.
+type RepairBlock struct {
+	// Cid is the CID of the missing or invalid block
+	Cid string
+
+	// Offset is the first byte of the file the block covers
+	Offset int64
+
+	// Size is the number of bytes of the file the block covers, or -1 when
+	// it is not known, as for the root of a file or a directory node
+	Size int64
+}
+
+// FileRepair lists the blocks to fetch for one file or directory.
+type FileRepair struct {
+	// Path is the slash separated path below the root, empty for the root
+	// itself and for blocks whose file is not known
+	Path string
+
+	// Blocks are in fetch order: by offset, with parents before children
+	Blocks []RepairBlock
+}
+
+// dagPos is the place of a block in the UnixFS structure below the root.
+type dagPos struct {
+	path   string
+	offset int64
+	size   int64
+	depth  int
+}
+
+// repairEntry is a block of the repair plan with its place in the DAG.
+type repairEntry struct {
+	cid string
+	pos dagPos
+}
+
+// RepairPlan returns the missing and invalid blocks grouped by the file they
+// belong to, so that fetching them in order yields a usable prefix of each
+// file early. Files are in the order the walk reached them.
+//
+// The plan is built by ValidateDAG, which follows the UnixFS structure from
+// the root. A block shared by several files is listed under the first one.
+// Blocks below a missing block cannot be known until it has been fetched,
+// so validating again after a repair may produce a new plan. For results of
+// the other validation methods, every missing and invalid block is listed
+// under an empty path with an unknown size.
+//
+// The plan is empty exactly when CanRestore is set.
+func (r *Result) RepairPlan() []FileRepair {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.planned {
+		var blocks []RepairBlock
+		for _, c := range r.MissingBlocks {
+			blocks = append(blocks, RepairBlock{Cid: c, Size: -1})
+		}
+		for _, c := range r.InvalidBlocks {
+			blocks = append(blocks, RepairBlock{Cid: c, Size: -1})
+		}
+		if len(blocks) == 0 {
+			return nil
+		}
+		return []FileRepair{{Blocks: blocks}}
+	}
+
+	var plan []FileRepair
+	var depths [][]int
+	index := make(map[string]int)
+	for _, e := range r.repair {
+		i, ok := index[e.pos.path]
+		if !ok {
+			i = len(plan)
+			index[e.pos.path] = i
+			plan = append(plan, FileRepair{Path: e.pos.path})
+			depths = append(depths, nil)
+		}
+		plan[i].Blocks = append(plan[i].Blocks, RepairBlock{Cid: e.cid, Offset: e.pos.offset, Size: e.pos.size})
+		depths[i] = append(depths[i], e.pos.depth)
+	}
+	for i := range plan {
+		sort.Sort(repairOrder{blocks: plan[i].Blocks, depths: depths[i]})
+	}
+	return plan
+}
+
+// addRepair adds the block c at pos to the repair plan.
+func (r *Result) addRepair(c string, pos dagPos) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.repair = append(r.repair, repairEntry{cid: c, pos: pos})
+}
+
+// repairOrder sorts the blocks of a file by offset, parents first.
+type repairOrder struct {
+	blocks []RepairBlock
+	depths []int
+}
+
+func (o repairOrder) Len() int { return len(o.blocks) }
+
+func (o repairOrder) Less(i, j int) bool {
+	if o.blocks[i].Offset != o.blocks[j].Offset {
+		return o.blocks[i].Offset < o.blocks[j].Offset
+	}
+	return o.depths[i] < o.depths[j]
+}
+
+func (o repairOrder) Swap(i, j int) {
+	o.blocks[i], o.blocks[j] = o.blocks[j], o.blocks[i]
+	o.depths[i], o.depths[j] = o.depths[j], o.depths[i]
+}
+
+// childPositions returns the places of the links of the node at pos. fsn is
+// the UnixFS data of the node, nil if it has none: the links of a file
+// cover consecutive byte ranges after the data of the node itself, those of
+// a directory or HAMT shard name its entries.
+func childPositions(pos dagPos, fsn *unixfs.FSNode, links []*ipld.Link) []dagPos {
+	positions := make([]dagPos, len(links))
+	offset := pos.offset
+	if fsn != nil {
+		offset += int64(len(fsn.Data()))
+	}
+	for i, l := range links {
+		child := dagPos{path: pos.path, size: -1, depth: pos.depth + 1}
+		switch {
+		case fsn == nil:
+		case fsn.Type() == unixfs.TFile || fsn.Type() == unixfs.TRaw:
+			child.offset = offset
+			child.size = int64(fsn.BlockSize(i))
+			offset += child.size
+		case fsn.Type() == unixfs.TDirectory:
+			child.path = path.Join(pos.path, l.Name)
+		case fsn.Type() == unixfs.THAMTShard && fsn.Fanout() > 0:
+			// Entries are prefixed with their slot in hex, links to child
+			// shards consist of the prefix alone
+			pad := len(fmt.Sprintf("%X", fsn.Fanout()-1))
+			if len(l.Name) > pad {
+				child.path = path.Join(pos.path, l.Name[pad:])
+			}
+		}
+		positions[i] = child
+	}
+	return positions
+}
@@ -0,0 +1,95 @@
+package validator
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+)
+
+func TestResult_RepairPlan(t *testing.T) {
+	ctx := context.Background()
+	bs := newMockBlockstore()
+	root := importFiles(t, bs, "repair", 3).RootCid
+	v := NewValidator(bs)
+
+	rootCid, _ := cid.Decode(root)
+	dir, err := v.dagService.Get(ctx, rootCid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := v.dagService.Get(ctx, dir.Links()[1].Cid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsn, err := unixfs.FSNodeFromBytes(file.(*merkledag.ProtoNode).Data())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Links()) < 3 {
+		t.Fatalf("file has %d links, want at least 3", len(file.Links()))
+	}
+
+	// Delete two leaves of the second file, the later one first, and the
+	// root of the third file
+	var deleted []blocks.Block
+	remove := func(c cid.Cid) {
+		blk, err := bs.Get(ctx, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := bs.DeleteBlock(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+		deleted = append(deleted, blk)
+	}
+	remove(file.Links()[2].Cid)
+	remove(file.Links()[1].Cid)
+	remove(dir.Links()[2].Cid)
+
+	result, err := v.ValidateDAG(ctx, root)
+	if err != nil {
+		t.Fatalf("ValidateDAG failed: %v", err)
+	}
+	if result.CanRestore {
+		t.Error("CanRestore set with missing blocks")
+	}
+
+	first := int64(fsn.BlockSize(0))
+	want := []FileRepair{
+		{Path: dir.Links()[1].Name, Blocks: []RepairBlock{
+			{Cid: file.Links()[1].Cid.String(), Offset: first, Size: int64(fsn.BlockSize(1))},
+			{Cid: file.Links()[2].Cid.String(), Offset: first + int64(fsn.BlockSize(1)), Size: int64(fsn.BlockSize(2))},
+		}},
+		{Path: dir.Links()[2].Name, Blocks: []RepairBlock{
+			{Cid: dir.Links()[2].Cid.String(), Offset: 0, Size: -1},
+		}},
+	}
+	if got := result.RepairPlan(); !reflect.DeepEqual(got, want) {
+		t.Errorf("RepairPlan() = %+v, want %+v", got, want)
+	}
+
+	// Results of other validation methods list the blocks without places
+	listed := &Result{MissingBlocks: []string{"missing"}, InvalidBlocks: []string{"invalid"}}
+	wantListed := []FileRepair{{Blocks: []RepairBlock{{Cid: "missing", Size: -1}, {Cid: "invalid", Size: -1}}}}
+	if got := listed.RepairPlan(); !reflect.DeepEqual(got, wantListed) {
+		t.Errorf("RepairPlan() without a walk = %+v, want %+v", got, wantListed)
+	}
+
+	for _, blk := range deleted {
+		if err := bs.Put(ctx, blk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	result, err = v.ValidateDAG(ctx, root)
+	if err != nil {
+		t.Fatalf("ValidateDAG failed: %v", err)
+	}
+	if plan := result.RepairPlan(); len(plan) != 0 || !result.CanRestore {
+		t.Errorf("after repair: RepairPlan() = %+v, CanRestore = %v", plan, result.CanRestore)
+	}
+}
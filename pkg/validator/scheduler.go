@@ -0,0 +1,370 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/tragoedia0722/repository/pkg/clock"
+	"github.com/tragoedia0722/repository/pkg/repository"
+)
+
+const (
+	// defaultForegroundPollInterval is how often a paused scheduler re-checks
+	// whether foreground activity has finished.
+	defaultForegroundPollInterval = 100 * time.Millisecond
+
+	// defaultIdleInterval is the wait between rediscovery attempts when there
+	// are no roots to validate.
+	defaultIdleInterval = time.Minute
+)
+
+// SchedulerOptions configures a background validation Scheduler.
+type SchedulerOptions struct {
+	// Roots is an explicit list of root CIDs to validate. When empty, Discover is used.
+	Roots []string
+
+	// Discover returns the roots to validate at the start of every cycle
+	// (for example from saved manifests). Ignored when Roots is set.
+	Discover func(ctx context.Context) ([]string, error)
+
+	// Concurrency is the number of DAG walk workers used inside each validation.
+	// Zero uses the validator default.
+	Concurrency int
+
+	// Pause is the wait between two consecutive root validations.
+	Pause time.Duration
+
+	// MaxOpsPerSecond caps blockstore operations issued by the scheduler.
+	// Zero means unlimited.
+	MaxOpsPerSecond int
+
+	// ForegroundPollInterval is how often a paused scheduler re-checks the
+	// repository's foreground hint. Zero uses 100ms.
+	ForegroundPollInterval time.Duration
+
+	// IdleInterval is the wait before rediscovering roots when there are none.
+	// Zero uses one minute.
+	IdleInterval time.Duration
+
+	// OnResult is called after every validation, successful or not.
+	OnResult func(rootCid string, result *Result)
+
+	// OnFailure is called for validations whose result is not complete.
+	OnFailure func(rootCid string, result *Result)
+
+	// OnError is called when a root could not be validated at all
+	// (invalid CID, discovery failure, persistence failure).
+	OnError func(rootCid string, err error)
+
+	// Clock overrides the time source, for example with a clocktest.Fake.
+	// Nil uses the real clock.
+	Clock clock.Clock
+}
+
+// SchedulerStore is the part of repository.Store a Scheduler uses. Both
+// *repository.Repository and repotest.Store implement it.
+type SchedulerStore interface {
+	BlockStore() blockstore.Blockstore
+	DataStore() repository.Datastore
+	ForegroundActive() bool
+}
+
+// Scheduler continuously validates a set of roots in the background.
+//
+// Roots are validated one at a time in round-robin order. The scheduler pauses
+// while the repository reports foreground activity (see
+// Repository.AcquireForeground), also in the middle of a validation before its
+// next blockstore operation, throttles its blockstore operations, and
+// persists every result with SaveValidation.
+type Scheduler struct {
+	repo      SchedulerStore
+	opts      SchedulerOptions
+	clock     clock.Clock
+	validator *Validator
+
+	mu      sync.Mutex
+	next    int // index of the next root to validate within the current list
+	cancel  context.CancelFunc
+	done    chan struct{}
+	running bool
+}
+
+// NewScheduler creates a Scheduler validating roots stored in repo.
+// The scheduler does not run until Start is called.
+func NewScheduler(repo SchedulerStore, opts SchedulerOptions) *Scheduler {
+	s := &Scheduler{
+		repo:  repo,
+		opts:  opts,
+		clock: clock.OrReal(opts.Clock),
+	}
+	bs := &schedulerBlockstore{
+		Blockstore: repo.BlockStore(),
+		scheduler:  s,
+		limiter:    newOpLimiter(opts.MaxOpsPerSecond, s.clock),
+	}
+	s.validator = NewValidator(bs).WithConcurrency(opts.Concurrency)
+	return s
+}
+
+// Start launches the background loop. Calling Start on a running scheduler is a no-op.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	s.running = true
+
+	go s.loop(ctx, s.done)
+}
+
+// Stop cancels the background loop and waits for the in-flight validation to finish.
+// Stop is idempotent.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	cancel, done := s.cancel, s.done
+	s.running = false
+	s.mu.Unlock()
+
+	cancel()
+	<-done
+}
+
+// loop runs validation cycles until ctx is cancelled.
+func (s *Scheduler) loop(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	for {
+		validated, err := s.step(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		wait := s.opts.Pause
+		if err != nil || !validated {
+			wait = s.opts.IdleInterval
+			if wait <= 0 {
+				wait = defaultIdleInterval
+			}
+		}
+
+		if wait > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.clock.After(wait):
+			}
+		}
+	}
+}
+
+// RunOnce validates every currently known root exactly once, in order,
+// starting from the beginning of the list. It is intended for callers that
+// drive scheduling themselves and for tests.
+func (s *Scheduler) RunOnce(ctx context.Context) error {
+	roots, err := s.roots(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, root := range roots {
+		if err := s.waitForeground(ctx); err != nil {
+			return err
+		}
+		if err := s.validate(ctx, root); err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// step validates the next root in round-robin order.
+// It reports whether a root was validated; the error is only set when the
+// roots could not be discovered or the context ended while paused.
+func (s *Scheduler) step(ctx context.Context) (bool, error) {
+	roots, err := s.roots(ctx)
+	if err != nil {
+		s.reportError("", err)
+		return false, err
+	}
+	if len(roots) == 0 {
+		return false, nil
+	}
+
+	if err := s.waitForeground(ctx); err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	if s.next >= len(roots) {
+		s.next = 0
+	}
+	root := roots[s.next]
+	s.next++
+	s.mu.Unlock()
+
+	_ = s.validate(ctx, root)
+	return true, nil
+}
+
+// roots returns the list of roots for the current cycle.
+func (s *Scheduler) roots(ctx context.Context) ([]string, error) {
+	if len(s.opts.Roots) > 0 {
+		return s.opts.Roots, nil
+	}
+	if s.opts.Discover == nil {
+		return nil, nil
+	}
+
+	roots, err := s.opts.Discover(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover roots: %w", err)
+	}
+	return roots, nil
+}
+
+// waitForeground blocks while the repository reports foreground activity.
+// It is called before every root and before every blockstore operation of a
+// validation, so a validation that is already running yields as well.
+func (s *Scheduler) waitForeground(ctx context.Context) error {
+	interval := s.opts.ForegroundPollInterval
+	if interval <= 0 {
+		interval = defaultForegroundPollInterval
+	}
+
+	for s.repo.ForegroundActive() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.clock.After(interval):
+		}
+	}
+	return nil
+}
+
+// validate validates a single root, persists the result, and invokes callbacks.
+func (s *Scheduler) validate(ctx context.Context, root string) error {
+	result, err := s.validator.validateTree(ctx, root)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.reportError(root, err)
+		}
+		return err
+	}
+
+	if err := SaveValidation(ctx, s.repo.DataStore(), root, result, s.clock.Now()); err != nil {
+		s.reportError(root, err)
+	}
+
+	if s.opts.OnResult != nil {
+		s.opts.OnResult(root, result)
+	}
+	if !result.IsComplete && s.opts.OnFailure != nil {
+		s.opts.OnFailure(root, result)
+	}
+	return nil
+}
+
+// reportError forwards err to the OnError callback if one is configured.
+func (s *Scheduler) reportError(root string, err error) {
+	if s.opts.OnError != nil {
+		s.opts.OnError(root, err)
+	}
+}
+
+// opLimiter spaces out operations so that at most a fixed number run per second.
+type opLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+	clock    clock.Clock
+}
+
+// newOpLimiter returns a limiter for opsPerSecond, or nil when unlimited.
+func newOpLimiter(opsPerSecond int, clk clock.Clock) *opLimiter {
+	if opsPerSecond <= 0 {
+		return nil
+	}
+	return &opLimiter{
+		interval: time.Second / time.Duration(opsPerSecond),
+		clock:    clk,
+	}
+}
+
+// wait blocks until the next operation slot is available.
+func (l *opLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := l.clock.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-l.clock.After(delay):
+		return nil
+	}
+}
+
+// schedulerBlockstore is the blockstore a Scheduler validates through. Every
+// read operation first waits while foreground work is active and then for a
+// slot of the opLimiter, if any.
+type schedulerBlockstore struct {
+	blockstore.Blockstore
+	scheduler *Scheduler
+	limiter   *opLimiter // Nil when unlimited
+}
+
+// wait blocks until the next read operation may run.
+func (b *schedulerBlockstore) wait(ctx context.Context) error {
+	if err := b.scheduler.waitForeground(ctx); err != nil {
+		return err
+	}
+	if b.limiter == nil {
+		return nil
+	}
+	return b.limiter.wait(ctx)
+}
+
+func (b *schedulerBlockstore) Has(ctx context.Context, c cid.Cid) (bool, error) {
+	if err := b.wait(ctx); err != nil {
+		return false, err
+	}
+	return b.Blockstore.Has(ctx, c)
+}
+
+func (b *schedulerBlockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	if err := b.wait(ctx); err != nil {
+		return nil, err
+	}
+	return b.Blockstore.Get(ctx, c)
+}
+
+func (b *schedulerBlockstore) GetSize(ctx context.Context, c cid.Cid) (int, error) {
+	if err := b.wait(ctx); err != nil {
+		return 0, err
+	}
+	return b.Blockstore.GetSize(ctx, c)
+}
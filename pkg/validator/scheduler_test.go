@@ -0,0 +1,319 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/tragoedia0722/repository/pkg/clock/clocktest"
+	"github.com/tragoedia0722/repository/pkg/importer"
+	"github.com/tragoedia0722/repository/pkg/repository/repotest"
+)
+
+// newFakeClock returns a manually advanced clock for scheduler tests.
+func newFakeClock() *clocktest.Fake {
+	return clocktest.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+// setupSchedulerRepo creates an in-memory repository containing one imported
+// root per name.
+func setupSchedulerRepo(t *testing.T, names ...string) (*repotest.Store, map[string]*importer.Result) {
+	t.Helper()
+
+	repo := repotest.New()
+	t.Cleanup(func() { _ = repo.Close() })
+
+	results := make(map[string]*importer.Result, len(names))
+	for _, name := range names {
+		dir := filepath.Join(t.TempDir(), name)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		for i := 0; i < 2; i++ {
+			content := []byte(fmt.Sprintf("%s file %d", name, i))
+			if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("f%d.txt", i)), content, 0o644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+		}
+
+		res, err := importer.NewImporter(repo.BlockStore(), dir).Import(context.Background())
+		if err != nil {
+			t.Fatalf("Import failed: %v", err)
+		}
+		results[name] = res
+	}
+
+	return repo, results
+}
+
+func TestScheduler_RoundRobinOrder(t *testing.T) {
+	repo, results := setupSchedulerRepo(t, "a", "b", "c")
+	roots := []string{results["a"].RootCid, results["b"].RootCid, results["c"].RootCid}
+
+	clock := newFakeClock()
+	seen := make(chan string, 16)
+	s := NewScheduler(repo, SchedulerOptions{
+		Roots: roots,
+		Pause: time.Second,
+		Clock: clock,
+		OnResult: func(rootCid string, result *Result) {
+			seen <- rootCid
+		},
+	})
+
+	s.Start(context.Background())
+	defer s.Stop()
+
+	var order []string
+	for len(order) < 6 {
+		select {
+		case root := <-seen:
+			order = append(order, root)
+			if len(order) < 6 {
+				clock.BlockUntil(1)
+				clock.Advance(time.Second)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out after %d results", len(order))
+		}
+	}
+
+	for i, root := range order {
+		if root != roots[i%len(roots)] {
+			t.Fatalf("result %d: got %s, want %s (order %v)", i, root, roots[i%len(roots)], order)
+		}
+	}
+}
+
+func TestScheduler_PersistsResults(t *testing.T) {
+	repo, results := setupSchedulerRepo(t, "a", "b")
+	roots := []string{results["a"].RootCid, results["b"].RootCid}
+
+	clock := newFakeClock()
+	s := NewScheduler(repo, SchedulerOptions{Roots: roots, Clock: clock})
+
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+
+	for _, root := range roots {
+		record, err := LoadValidation(context.Background(), repo.DataStore(), root)
+		if err != nil {
+			t.Fatalf("LoadValidation(%s) failed: %v", root, err)
+		}
+		if !record.IsComplete {
+			t.Errorf("root %s: expected complete record, got %+v", root, record)
+		}
+		if !record.CheckedAt.Equal(clock.Now()) {
+			t.Errorf("root %s: CheckedAt = %v, want %v", root, record.CheckedAt, clock.Now())
+		}
+		if record.ReachableSize == 0 {
+			t.Errorf("root %s: expected non-zero reachable size", root)
+		}
+	}
+}
+
+func TestScheduler_DeletedBlockTriggersFailure(t *testing.T) {
+	repo, results := setupSchedulerRepo(t, "a", "b")
+	roots := []string{results["a"].RootCid, results["b"].RootCid}
+
+	var victim string
+	for _, pkg := range results["b"].Packages {
+		for _, blk := range pkg.Blocks {
+			if blk != results["b"].RootCid {
+				victim = blk
+				break
+			}
+		}
+	}
+	if victim == "" {
+		t.Fatal("no non-root block to delete")
+	}
+
+	var mu sync.Mutex
+	failures := make(map[string]*Result)
+	s := NewScheduler(repo, SchedulerOptions{
+		Roots: roots,
+		Clock: newFakeClock(),
+		OnFailure: func(rootCid string, result *Result) {
+			mu.Lock()
+			failures[rootCid] = result
+			mu.Unlock()
+		},
+	})
+
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if len(failures) != 0 {
+		t.Fatalf("expected no failures before deletion, got %d", len(failures))
+	}
+
+	if err := repo.DelBlock(context.Background(), victim); err != nil {
+		t.Fatalf("DelBlock failed: %v", err)
+	}
+
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := failures[roots[0]]; ok {
+		t.Error("unaffected root reported as failed")
+	}
+	res, ok := failures[roots[1]]
+	if !ok {
+		t.Fatal("expected failure callback for affected root")
+	}
+	found := false
+	for _, missing := range res.MissingBlocks {
+		if missing == victim {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected %s in MissingBlocks, got %v", victim, res.MissingBlocks)
+	}
+
+	record, err := LoadValidation(context.Background(), repo.DataStore(), roots[1])
+	if err != nil {
+		t.Fatalf("LoadValidation failed: %v", err)
+	}
+	if record.IsComplete {
+		t.Error("persisted record should be incomplete after deletion")
+	}
+}
+
+func TestScheduler_PausesForForeground(t *testing.T) {
+	repo, results := setupSchedulerRepo(t, "a")
+
+	clock := newFakeClock()
+	seen := make(chan string, 4)
+	s := NewScheduler(repo, SchedulerOptions{
+		Roots:                  []string{results["a"].RootCid},
+		Pause:                  time.Hour,
+		ForegroundPollInterval: 10 * time.Millisecond,
+		Clock:                  clock,
+		OnResult: func(rootCid string, result *Result) {
+			seen <- rootCid
+		},
+	})
+
+	repo.AcquireForeground()
+	s.Start(context.Background())
+	defer s.Stop()
+
+	for i := 0; i < 3; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(10 * time.Millisecond)
+	}
+
+	select {
+	case <-seen:
+		t.Fatal("scheduler validated while foreground activity was registered")
+	default:
+	}
+
+	repo.ReleaseForeground()
+	clock.BlockUntil(1)
+	clock.Advance(10 * time.Millisecond)
+
+	select {
+	case <-seen:
+	case <-time.After(10 * time.Second):
+		t.Fatal("scheduler did not resume after foreground release")
+	}
+}
+
+func TestScheduler_ValidationYieldsToForeground(t *testing.T) {
+	repo, results := setupSchedulerRepo(t, "a")
+	root, err := cid.Decode(results["a"].RootCid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock := newFakeClock()
+	s := NewScheduler(repo, SchedulerOptions{
+		Roots:                  []string{root.String()},
+		ForegroundPollInterval: 10 * time.Millisecond,
+		Clock:                  clock,
+	})
+
+	// Foreground work that starts while a validation is running pauses its
+	// next blockstore operation, not only the next root
+	repo.AcquireForeground()
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.validator.blockStore.Get(context.Background(), root)
+		done <- err
+	}()
+
+	clock.BlockUntil(1)
+	select {
+	case err := <-done:
+		t.Fatalf("Get returned %v while foreground activity was registered", err)
+	default:
+	}
+
+	repo.ReleaseForeground()
+	clock.Advance(10 * time.Millisecond)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Get failed: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Get did not resume after foreground release")
+	}
+}
+
+func TestScheduler_StopDrains(t *testing.T) {
+	repo, results := setupSchedulerRepo(t, "a")
+
+	s := NewScheduler(repo, SchedulerOptions{
+		Roots: []string{results["a"].RootCid},
+		Pause: time.Hour,
+		Clock: newFakeClock(),
+	})
+
+	s.Start(context.Background())
+	s.Stop()
+	s.Stop() // idempotent
+
+	s.Start(context.Background())
+	s.Stop()
+}
+
+func TestOpLimiter_SpacesOperations(t *testing.T) {
+	clock := newFakeClock()
+	l := newOpLimiter(10, clock)
+
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatalf("first wait failed: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- l.wait(context.Background()) }()
+
+	clock.BlockUntil(1)
+	select {
+	case <-done:
+		t.Fatal("second operation was not throttled")
+	default:
+	}
+
+	clock.Advance(100 * time.Millisecond)
+	if err := <-done; err != nil {
+		t.Fatalf("second wait failed: %v", err)
+	}
+
+	if newOpLimiter(0, clock) != nil {
+		t.Error("expected nil limiter for unlimited rate")
+	}
+}
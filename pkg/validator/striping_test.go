@@ -0,0 +1,75 @@
+package validator
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/tragoedia0722/repository/pkg/extractor"
+	"github.com/tragoedia0722/repository/pkg/importer"
+	"github.com/tragoedia0722/repository/pkg/packaging"
+)
+
+func TestImporter_WithFileStriping(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping 64MB striping round trip in short mode")
+	}
+
+	ctx := context.Background()
+	bs := newMockBlockstore()
+
+	data := make([]byte, 64<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+	src := filepath.Join(t.TempDir(), "large.bin")
+	if err := os.WriteFile(src, data, 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	res, err := importer.NewImporter(bs, src).WithFileStriping(16 << 20).Import(ctx)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if len(res.Contents) != 1 {
+		t.Fatalf("imported %d files, want 1", len(res.Contents))
+	}
+	stripes := res.Contents[0].Stripes
+	if len(stripes) != 4 {
+		t.Fatalf("file has %d stripes, want 4", len(stripes))
+	}
+
+	// Each stripe is a complete DAG of its own
+	v := NewValidator(bs)
+	for i, stripe := range stripes {
+		c, err := cid.Decode(stripe)
+		if err != nil {
+			t.Fatalf("stripe %d has invalid CID %q: %v", i, stripe, err)
+		}
+		blocks, err := packaging.CollectBlocks(ctx, v.dagService, c)
+		if err != nil {
+			t.Fatalf("CollectBlocks(stripe %d) failed: %v", i, err)
+		}
+		result, err := v.Validate(ctx, stripe, blocks)
+		if err != nil {
+			t.Fatalf("Validate(stripe %d) failed: %v", i, err)
+		}
+		if !result.IsComplete {
+			t.Errorf("stripe %d is incomplete: %+v", i, result)
+		}
+	}
+
+	out := t.TempDir()
+	if err := extractor.NewExtractor(bs, res.RootCid, out).Extract(ctx, false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(out, "large.bin"))
+	if err != nil {
+		t.Fatalf("failed to read extracted file: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("extracted %d bytes differ from the %d imported", len(got), len(data))
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/ipfs/boxo/ipld/merkledag"
 	"github.com/ipfs/go-cid"
 	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/tragoedia0722/repository/internal/dagwalk"
 )
 
 const (
@@ -44,8 +45,12 @@ const (
 //	    fmt.Printf("Missing %d blocks\n", len(result.MissingBlocks))
 //	}
 type Validator struct {
-	blockStore blockstore.Blockstore
-	dagService ipld.DAGService
+	blockStore      blockstore.Blockstore
+	dagService      ipld.DAGService
+	walkConcurrency int // 0 uses dagwalk.DefaultConcurrency
+	walkFrontier    int // 0 uses dagwalk.DefaultMaxFrontier
+	maxDepth        int // 0 uses DefaultMaxDAGDepth
+	maxBlocks       int // 0 uses DefaultMaxDAGBlocks
 }
 
 // Result contains the validation results.
@@ -75,6 +80,9 @@ type Result struct {
 
 	// ErrorDetails contains detailed error messages for any issues encountered during validation
 	ErrorDetails []string
+
+	repair  []repairEntry // Blocks of the repair plan with their places in the DAG
+	planned bool          // Set when repair was built by a walk of the UnixFS structure
 }
 
 // NewValidator creates a new Validator with the given blockstore.
@@ -89,6 +97,24 @@ func NewValidator(blockStore blockstore.Blockstore) *Validator {
 	}
 }
 
+// WithConcurrency sets the number of concurrent workers used when walking a DAG.
+// Values <= 0 restore the default of dagwalk.DefaultConcurrency; 1 walks serially.
+// Returns the validator for method chaining.
+func (v *Validator) WithConcurrency(n int) *Validator {
+	v.walkConcurrency = n
+	return v
+}
+
+// WithWalkFrontier bounds the number of discovered CIDs waiting for a worker
+// while walking a DAG. When the frontier is full, workers descend into the
+// children themselves, so memory stays bounded for very wide and very deep
+// DAGs alike. Values <= 0 restore the default of dagwalk.DefaultMaxFrontier.
+// Returns the validator for method chaining.
+func (v *Validator) WithWalkFrontier(n int) *Validator {
+	v.walkFrontier = n
+	return v
+}
+
 // Validate performs validation of the specified blocks and DAG.
 //
 // It validates the provided blocks list, checks for missing and invalid blocks,
@@ -266,38 +292,83 @@ func (v *Validator) findRequiredBlocks(ctx context.Context, rootCid cid.Cid) (ma
 // walkDAG traverses the DAG from the root CID and calculates the total size.
 //
 // It visits each node in the DAG, adds it to the requiredBlocks map, and sums
-// the size of all blocks. Uses concurrent traversal for performance.
-// Thread-safe: protected by mutex for concurrent access.
+// the size of all blocks. The traversal is parallel with a bounded frontier,
+// see WithConcurrency and WithWalkFrontier. A missing or undecodable block
+// aborts the walk with its error.
 func (v *Validator) walkDAG(ctx context.Context, rootCid cid.Cid, requiredBlocks map[string]bool) (int64, error) {
-	var totalSize int64
-	var sizeMutex sync.Mutex
+	walked, err := dagwalk.Walk(ctx, v.dagService, rootCid, v.walkOptions(func(ctx context.Context, c cid.Cid) (int, error) {
+		// Raw leaves are listed but not checked here, a missing one is
+		// reported by checkMissingRequiredBlocks
+		size, _ := v.blockStore.GetSize(ctx, c)
+		return size, nil
+	}))
+	if err != nil {
+		return 0, err
+	}
+	if len(walked.Missing) > 0 {
+		return 0, ipld.ErrNotFound{Cid: walked.Missing[0]}
+	}
+	if len(walked.Invalid) > 0 {
+		return 0, walked.Invalid[0].Err
+	}
 
-	err := merkledag.Walk(ctx, merkledag.GetLinksWithDAG(v.dagService), rootCid, func(c cid.Cid) bool {
-		cidStr := c.String()
+	_ = walked.Visited.ForEach(func(c cid.Cid) error {
+		requiredBlocks[c.String()] = true
+		return nil
+	})
+	return walked.Size, nil
+}
 
-		// Thread-safe map access
-		sizeMutex.Lock()
-		alreadyVisited := requiredBlocks[cidStr]
-		if !alreadyVisited {
-			requiredBlocks[cidStr] = true
-		}
-		sizeMutex.Unlock()
+// walkOptions returns the dagwalk options matching the configured concurrency
+// and frontier, with stat used for raw leaves.
+func (v *Validator) walkOptions(stat func(ctx context.Context, c cid.Cid) (int, error)) dagwalk.Options {
+	return dagwalk.Options{
+		Concurrency: v.walkConcurrency,
+		MaxFrontier: v.walkFrontier,
+		Stat:        stat,
+	}
+}
 
-		if alreadyVisited {
-			return false
-		}
+// validateTree validates the DAG under rootCid without a caller-supplied block list.
+//
+// Unlike walkDAG, a missing block does not abort the traversal: it is recorded in
+// MissingBlocks and its subtree is skipped, so a single run reports every missing
+// block that is discoverable from the blocks that are present.
+func (v *Validator) validateTree(ctx context.Context, rootCid string) (*Result, error) {
+	result, _, err := v.walkTree(ctx, rootCid)
+	return result, err
+}
 
-		// Get size efficiently (without loading entire block)
-		if size, err := v.blockStore.GetSize(ctx, c); err == nil {
-			sizeMutex.Lock()
-			totalSize += int64(size)
-			sizeMutex.Unlock()
-		}
+// walkTree implements validateTree and also returns the set of CIDs it
+// visited, including the missing ones.
+func (v *Validator) walkTree(ctx context.Context, rootCid string) (*Result, *dagwalk.Set, error) {
+	if rootCid == "" {
+		return nil, nil, fmt.Errorf("root CID cannot be empty")
+	}
+
+	theRootCid, err := cid.Decode(rootCid)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid root CID %q: %w", rootCid, err)
+	}
+
+	walked, err := dagwalk.Walk(ctx, v.dagService, theRootCid, v.walkOptions(v.blockStore.GetSize))
+	if err != nil {
+		return nil, nil, err
+	}
 
-		return true
-	}, merkledag.Concurrent())
+	result := v.newResult(nil)
+	result.CanRestore = true
+	for _, c := range walked.Missing {
+		result.addMissingBlock(c.String())
+	}
+	for _, failure := range walked.Invalid {
+		result.addInvalidBlock(failure.Cid.String())
+		result.addError("failed to decode block %s: %v", failure.Cid, failure.Err)
+	}
+	result.ReachableSize = walked.Size
+	result.finalize()
 
-	return totalSize, err
+	return result, walked.Visited, nil
 }
 
 // checkMissingRequiredBlocks checks for required blocks that are missing from the provided blocks list.
@@ -0,0 +1,175 @@
+package validator
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/go-cid"
+	"github.com/tragoedia0722/repository/internal/dagwalk"
+	"github.com/tragoedia0722/repository/pkg/clock"
+)
+
+// defaultQuietPeriod is the debounce window used when WatchOptions.QuietPeriod is zero.
+const defaultQuietPeriod = 100 * time.Millisecond
+
+// WatchOptions configures Watch.
+type WatchOptions struct {
+	// QuietPeriod is how long the DAG must go without relevant block
+	// changes before it is re-validated. Zero uses 100ms.
+	QuietPeriod time.Duration
+
+	// Concurrency is the number of DAG walk workers used inside each validation.
+	// Zero uses the validator default.
+	Concurrency int
+
+	// Clock overrides the time source, for example with a clocktest.Fake.
+	// Nil uses the real clock.
+	Clock clock.Clock
+}
+
+// WatchStore holds the methods of repository.Store that Watch needs: the
+// blockstore to validate and the hooks reporting block changes.
+type WatchStore interface {
+	BlockStore() blockstore.Blockstore
+	OnBlockPut(fn func(c cid.Cid)) func()
+	OnBlockDeleted(fn func(c cid.Cid)) func()
+}
+
+// Watch validates the DAG under rootCid and re-validates it whenever a block
+// belonging to it is put into or deleted from repo.
+//
+// The initial validation runs before Watch returns; its result is the first
+// value on the returned channel, and an error is returned instead if it
+// cannot be performed. Afterwards, block changes reported by
+// Repository.OnBlockPut and Repository.OnBlockDeleted are matched against
+// the CIDs visited by the latest validation, including missing ones, and
+// bursts of matching changes are coalesced until QuietPeriod has passed
+// without another one. The set of watched CIDs is refreshed by every
+// validation, so blocks that become reachable are watched from then on.
+// Changes to unrelated blocks never trigger a validation.
+//
+// A validation that fails outright produces a Result whose ErrorDetails
+// describe the failure. The channel is closed when ctx is cancelled; results
+// are delivered in order and a slow receiver delays the next validation.
+func Watch(ctx context.Context, repo WatchStore, rootCid string, opts WatchOptions) (<-chan *Result, error) {
+	clk := clock.OrReal(opts.Clock)
+	quiet := opts.QuietPeriod
+	if quiet <= 0 {
+		quiet = defaultQuietPeriod
+	}
+
+	w := &watcher{
+		validator: NewValidator(repo.BlockStore()).WithConcurrency(opts.Concurrency),
+		root:      rootCid,
+		changed:   make(chan struct{}, 1),
+	}
+
+	// Subscribe first so that no change made during the initial walk is lost
+	removePut := repo.OnBlockPut(w.notify)
+	removeDeleted := repo.OnBlockDeleted(w.notify)
+
+	result, err := w.validate(ctx)
+	if err != nil {
+		removePut()
+		removeDeleted()
+		return nil, err
+	}
+
+	out := make(chan *Result, 1)
+	out <- result
+
+	go func() {
+		defer close(out)
+		defer removeDeleted()
+		defer removePut()
+
+		for {
+			if !w.waitQuiet(ctx, clk, quiet) {
+				return
+			}
+
+			result, err := w.validate(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				result = w.validator.newResult(nil)
+				result.addError("validation failed: %v", err)
+				result.finalize()
+			}
+
+			select {
+			case out <- result:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// watcher tracks the CIDs of a watched DAG and signals relevant changes.
+type watcher struct {
+	validator *Validator
+	root      string
+	changed   chan struct{} // Holds a token while a relevant change is pending
+
+	mu      sync.Mutex
+	watched *dagwalk.Set // CIDs visited by the latest validation, nil before the first
+}
+
+// notify signals a change if c belongs to the watched DAG. Changes are
+// assumed relevant until the first validation has completed.
+func (w *watcher) notify(c cid.Cid) {
+	w.mu.Lock()
+	relevant := w.watched == nil || w.watched.Has(c)
+	w.mu.Unlock()
+
+	if relevant {
+		select {
+		case w.changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// validate validates the watched root and refreshes the set of watched CIDs.
+func (w *watcher) validate(ctx context.Context) (*Result, error) {
+	result, visited, err := w.validator.walkTree(ctx, w.root)
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	w.watched = visited
+	w.mu.Unlock()
+	return result, nil
+}
+
+// waitQuiet waits for a relevant change followed by a quiet period without
+// further changes. It returns false when ctx is done.
+func (w *watcher) waitQuiet(ctx context.Context, clk clock.Clock, quiet time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-w.changed:
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-w.changed:
+		case <-clk.After(quiet):
+			// The validation that follows also covers a change pending now
+			select {
+			case <-w.changed:
+			default:
+			}
+			return true
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package validator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tragoedia0722/repository/pkg/clock/clocktest"
+)
+
+func TestWatch_ReportsDeletedBlock(t *testing.T) {
+	repo, results := setupSchedulerRepo(t, "a")
+	root := results["a"].RootCid
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const quiet = 20 * time.Millisecond
+	updates, err := Watch(ctx, repo, root, WatchOptions{QuietPeriod: quiet})
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	initial := <-updates
+	if !initial.IsComplete {
+		t.Fatalf("initial result incomplete: %+v", initial)
+	}
+
+	// Changes to blocks outside the DAG produce no event
+	unrelated, err := repo.PutBlock(ctx, []byte("not part of the watched DAG"))
+	if err != nil {
+		t.Fatalf("PutBlock failed: %v", err)
+	}
+	if err := repo.DelBlockCid(ctx, *unrelated); err != nil {
+		t.Fatalf("DelBlockCid failed: %v", err)
+	}
+	select {
+	case result := <-updates:
+		t.Fatalf("unexpected result after unrelated change: %+v", result)
+	case <-time.After(10 * quiet):
+	}
+
+	_, visited, err := NewValidator(repo.BlockStore()).walkTree(ctx, root)
+	if err != nil {
+		t.Fatalf("walkTree failed: %v", err)
+	}
+	var leaf string
+	for _, c := range visited.Strings() {
+		if c != root {
+			leaf = c
+			break
+		}
+	}
+	if err := repo.DelBlock(ctx, leaf); err != nil {
+		t.Fatalf("DelBlock failed: %v", err)
+	}
+
+	select {
+	case result := <-updates:
+		if result.IsComplete {
+			t.Fatalf("result after deletion is complete: %+v", result)
+		}
+		if len(result.MissingBlocks) != 1 || result.MissingBlocks[0] != leaf {
+			t.Errorf("MissingBlocks = %v, want [%s]", result.MissingBlocks, leaf)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no result after deleting a block of the DAG")
+	}
+
+	cancel()
+	select {
+	case _, ok := <-updates:
+		if ok {
+			t.Fatal("received a result after cancellation")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("channel not closed after cancellation")
+	}
+}
+
+func TestWatch_DebouncesBursts(t *testing.T) {
+	repo, results := setupSchedulerRepo(t, "a")
+	root := results["a"].RootCid
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := newFakeClock()
+	updates, err := Watch(ctx, repo, root, WatchOptions{QuietPeriod: time.Second, Clock: clock})
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	<-updates
+
+	_, visited, err := NewValidator(repo.BlockStore()).walkTree(ctx, root)
+	if err != nil {
+		t.Fatalf("walkTree failed: %v", err)
+	}
+	for _, c := range visited.Strings() {
+		if err := repo.DelBlock(ctx, c); err != nil {
+			t.Fatalf("DelBlock failed: %v", err)
+		}
+	}
+
+	// One validation follows the burst once the quiet period has passed
+	result := advanceUntilResult(t, clock, updates)
+	if len(result.MissingBlocks) != 1 || result.MissingBlocks[0] != root {
+		t.Errorf("MissingBlocks = %v, want only the root", result.MissingBlocks)
+	}
+
+	for i := 0; i < 5; i++ {
+		clock.Advance(time.Second)
+		select {
+		case result := <-updates:
+			t.Fatalf("second result for a single burst: %+v", result)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// advanceUntilResult advances clock a second at a time until a result arrives.
+func advanceUntilResult(t *testing.T, clock *clocktest.Fake, updates <-chan *Result) *Result {
+	t.Helper()
+
+	deadline := time.After(5 * time.Second)
+	for {
+		clock.Advance(time.Second)
+		select {
+		case result := <-updates:
+			return result
+		case <-time.After(10 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("no result after the quiet period")
+		}
+	}
+}
+
+func TestWatch_InvalidRoot(t *testing.T) {
+	repo, _ := setupSchedulerRepo(t)
+
+	if _, err := Watch(context.Background(), repo, "not-a-cid", WatchOptions{}); err == nil {
+		t.Fatal("Watch accepted an invalid root CID")
+	}
+}
@@ -0,0 +1,66 @@
+// Package xattr preserves extended attributes through an import and
+// extraction, which UnixFS cannot represent natively.
+//
+// The importer records the attributes of every imported file and directory
+// in a single metadata file linked from the root directory under
+// ReservedName. The extractor reads that file and re-applies the
+// attributes. Both sides share the encoding defined here so that the
+// metadata file is deterministic for a given tree.
+//
+// Attributes are read and written without following symlinks. POSIX ACLs
+// are preserved through their system.posix_acl_* attributes where the
+// filesystem exposes them. Only Linux is supported; elsewhere List and Set
+// return ErrUnsupported.
+package xattr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ReservedName is the name of the metadata file in the root directory of
+// an import. The importer refuses source trees that contain it, and the
+// extractor never writes it as a regular file.
+const ReservedName = ".repository-xattrs.json"
+
+// formatVersion is the version of the metadata encoding.
+const formatVersion = 1
+
+// ErrUnsupported is returned on platforms or filesystems without extended
+// attributes.
+var ErrUnsupported = errors.New("extended attributes are not supported")
+
+// Attrs maps attribute names to their values.
+type Attrs map[string][]byte
+
+// Metadata maps slash-separated paths relative to the import root ("" for
+// the root itself) to their attributes.
+type Metadata map[string]Attrs
+
+// document is the encoded form of Metadata.
+type document struct {
+	Version int      `json:"version"`
+	Entries Metadata `json:"entries"`
+}
+
+// Encode returns the metadata file content for m. Map keys are sorted, so
+// equal metadata always encodes to the same bytes.
+func Encode(m Metadata) ([]byte, error) {
+	return json.Marshal(document{Version: formatVersion, Entries: m})
+}
+
+// Decode parses a metadata file produced by Encode.
+func Decode(data []byte) (Metadata, error) {
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid xattr metadata: %w", err)
+	}
+	if doc.Version != formatVersion {
+		return nil, fmt.Errorf("unsupported xattr metadata version %d", doc.Version)
+	}
+	if doc.Entries == nil {
+		doc.Entries = Metadata{}
+	}
+	return doc.Entries, nil
+}
@@ -0,0 +1,92 @@
+package xattr
+
+import (
+	"bytes"
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// List returns the extended attributes of path without following a final
+// symlink. Attributes that disappear or cannot be read while listing are
+// skipped. It returns ErrUnsupported if the filesystem has no xattr support.
+func List(path string) (Attrs, error) {
+	names, err := listNames(path)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := make(Attrs, len(names))
+	for _, name := range names {
+		value, err := get(path, name)
+		if err != nil {
+			continue
+		}
+		attrs[name] = value
+	}
+	return attrs, nil
+}
+
+// Set sets the extended attribute name of path without following a final
+// symlink. It returns ErrUnsupported if the filesystem has no xattr support.
+func Set(path, name string, value []byte) error {
+	return convert(unix.Lsetxattr(path, name, value, 0))
+}
+
+// listNames returns the attribute names of path.
+func listNames(path string) ([]string, error) {
+	buf, err := readSized(func(dest []byte) (int, error) {
+		return unix.Llistxattr(path, dest)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, name := range bytes.Split(buf, []byte{0}) {
+		if len(name) > 0 {
+			names = append(names, string(name))
+		}
+	}
+	return names, nil
+}
+
+// get returns the value of the attribute name of path.
+func get(path, name string) ([]byte, error) {
+	return readSized(func(dest []byte) (int, error) {
+		return unix.Lgetxattr(path, name, dest)
+	})
+}
+
+// readSized calls read first to learn the size of the result and then to
+// fetch it, retrying if the value grew in between.
+func readSized(read func(dest []byte) (int, error)) ([]byte, error) {
+	for {
+		size, err := read(nil)
+		if err != nil {
+			return nil, convert(err)
+		}
+		if size == 0 {
+			return []byte{}, nil
+		}
+
+		buf := make([]byte, size)
+		n, err := read(buf)
+		if errors.Is(err, unix.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return nil, convert(err)
+		}
+		return buf[:n], nil
+	}
+}
+
+// convert maps the errors of filesystems without xattr support to
+// ErrUnsupported.
+func convert(err error) error {
+	if errors.Is(err, unix.ENOTSUP) {
+		return ErrUnsupported
+	}
+	return err
+}
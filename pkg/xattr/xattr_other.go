@@ -0,0 +1,13 @@
+//go:build !linux
+
+package xattr
+
+// List returns ErrUnsupported outside Linux.
+func List(path string) (Attrs, error) {
+	return nil, ErrUnsupported
+}
+
+// Set returns ErrUnsupported outside Linux.
+func Set(path, name string, value []byte) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,47 @@
+package xattr
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	m := Metadata{
+		"":          {"user.root": []byte("r")},
+		"sub/b.txt": {"user.b": []byte{0, 1, 2}, "user.a": []byte("a")},
+	}
+
+	data, err := Encode(m)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	again, err := Encode(Metadata{
+		"sub/b.txt": {"user.a": []byte("a"), "user.b": []byte{0, 1, 2}},
+		"":          {"user.root": []byte("r")},
+	})
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if !bytes.Equal(data, again) {
+		t.Errorf("equal metadata encoded differently:\n%s\n%s", data, again)
+	}
+
+	got, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if len(got) != 2 || !bytes.Equal(got["sub/b.txt"]["user.b"], []byte{0, 1, 2}) || string(got[""]["user.root"]) != "r" {
+		t.Errorf("Decode() = %v", got)
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	for _, data := range []string{"", "not json", `{"version":2,"entries":{}}`} {
+		if _, err := Decode([]byte(data)); err == nil {
+			t.Errorf("Decode(%q) succeeded", data)
+		}
+	}
+	if m, err := Decode([]byte(`{"version":1}`)); err != nil || m == nil {
+		t.Errorf("Decode without entries = %v, %v", m, err)
+	}
+}